@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+
+	"github.com/joho/godotenv"
+
+	"github.com/subculture-collective/epstein-db/api/internal/auth"
+	"github.com/subculture-collective/epstein-db/api/internal/db"
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, `Usage: apikey <command> [flags]
+
+Commands:
+  create -name NAME -role ROLE  Issue a key (roles: public, researcher, admin)
+  list                          Show issued keys
+  revoke ID                     Disable a key
+`)
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	// Load .env file
+	if err := godotenv.Load(); err != nil {
+		log.Println("No .env file found, using environment variables")
+	}
+
+	ctx := context.Background()
+
+	// Initialize database connection
+	if err := db.Initialize(ctx); err != nil {
+		log.Fatalf("Failed to initialize database: %v", err)
+	}
+	defer db.Close()
+
+	var err error
+	switch os.Args[1] {
+	case "create":
+		err = create(ctx, os.Args[2:])
+	case "list":
+		err = list(ctx)
+	case "revoke":
+		if len(os.Args) != 3 {
+			usage()
+			os.Exit(2)
+		}
+		err = revoke(ctx, os.Args[2])
+	default:
+		usage()
+		os.Exit(2)
+	}
+
+	if err != nil {
+		log.Fatalf("%s: %v", os.Args[1], err)
+	}
+}
+
+func create(ctx context.Context, args []string) error {
+	var name, roleName string
+
+	fs := flag.NewFlagSet("create", flag.ExitOnError)
+	fs.StringVar(&name, "name", "", "who the key is for")
+	fs.StringVar(&roleName, "role", "researcher", "public, researcher or admin")
+	fs.Parse(args)
+
+	if name == "" {
+		return fmt.Errorf("-name is required")
+	}
+	role, err := auth.ParseRole(roleName)
+	if err != nil {
+		return err
+	}
+
+	id, key, err := auth.Create(ctx, db.Pool(), name, role)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Created %s key %d for %s. It won't be shown again:", role, id, name)
+	fmt.Println(key)
+	return nil
+}
+
+func list(ctx context.Context) error {
+	keys, err := auth.List(ctx, db.Pool())
+	if err != nil {
+		return err
+	}
+
+	for _, k := range keys {
+		state := "active"
+		if k.RevokedAt != nil {
+			state = "revoked"
+		}
+		lastUsed := "never"
+		if k.LastUsedAt != nil {
+			lastUsed = k.LastUsedAt.Format("2006-01-02 15:04")
+		}
+		fmt.Printf("%-5d %-12s %-10s %-8s %-16s %s\n", k.ID, k.Prefix, k.Role, state, lastUsed, k.Name)
+	}
+	return nil
+}
+
+func revoke(ctx context.Context, arg string) error {
+	id, err := strconv.Atoi(arg)
+	if err != nil {
+		return fmt.Errorf("invalid id %q", arg)
+	}
+
+	if err := auth.Revoke(ctx, db.Pool(), id); err != nil {
+		return err
+	}
+	log.Printf("Revoked key %d", id)
+	return nil
+}
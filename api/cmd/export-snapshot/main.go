@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os/signal"
+	"slices"
+	"syscall"
+	"time"
+
+	"github.com/joho/godotenv"
+
+	"github.com/subculture-collective/epstein-db/api/internal/config"
+	"github.com/subculture-collective/epstein-db/api/internal/db"
+	"github.com/subculture-collective/epstein-db/api/internal/jobs"
+	"github.com/subculture-collective/epstein-db/api/internal/snapshot"
+)
+
+func main() {
+	var format, dir string
+	var queue bool
+
+	flag.StringVar(&format, "format", snapshot.FormatSQLite, "sqlite or parquet")
+	flag.StringVar(&dir, "dir", "", "directory to write to (default SNAPSHOT_DIR)")
+	flag.BoolVar(&queue, "queue", false, "process queued snapshot jobs until interrupted")
+	flag.Parse()
+
+	if !slices.Contains(snapshot.Formats, format) {
+		log.Fatalf("Invalid -format %q", format)
+	}
+
+	// Load .env file
+	if err := godotenv.Load(); err != nil {
+		log.Println("No .env file found, using environment variables")
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if dir == "" {
+		dir = cfg.Snapshots.Dir
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	// Initialize database connection
+	if err := db.Initialize(ctx); err != nil {
+		log.Fatalf("Failed to initialize database: %v", err)
+	}
+	defer db.Close()
+
+	if queue {
+		err := jobs.NewQueue(db.Pool()).Work(ctx, snapshot.JobKind, 10*time.Second,
+			func(ctx context.Context, job *jobs.Job) (any, error) {
+				var params snapshot.Params
+				if err := job.DecodeParams(&params); err != nil {
+					return nil, err
+				}
+				return snapshot.Write(ctx, db.Pool(), dir, params.Format)
+			})
+		if err != nil {
+			log.Fatalf("export-snapshot: %v", err)
+		}
+		return
+	}
+
+	s, err := snapshot.Write(ctx, db.Pool(), dir, format)
+	if err != nil {
+		log.Fatalf("export-snapshot: %v", err)
+	}
+
+	for _, t := range s.Tables {
+		log.Printf("export-snapshot: %s %d rows", t.Name, t.Rows)
+	}
+	log.Printf("export-snapshot: wrote %s (%d bytes, sha256 %s)", s.Name, s.Size, s.SHA256)
+}
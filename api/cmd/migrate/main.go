@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+
+	"github.com/joho/godotenv"
+
+	"github.com/subculture-collective/epstein-db/api/internal/db"
+	"github.com/subculture-collective/epstein-db/api/internal/migrations"
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, `Usage: migrate <command>
+
+Commands:
+  up             Apply all pending migrations
+  status         Show the current and expected schema versions
+  force VERSION  Mark the database as migrated to VERSION without running SQL
+`)
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	// Load .env file
+	if err := godotenv.Load(); err != nil {
+		log.Println("No .env file found, using environment variables")
+	}
+
+	ctx := context.Background()
+
+	// Initialize database connection
+	if err := db.Initialize(ctx); err != nil {
+		log.Fatalf("Failed to initialize database: %v", err)
+	}
+	defer db.Close()
+
+	var err error
+	switch os.Args[1] {
+	case "up":
+		err = up(ctx)
+	case "status":
+		err = status(ctx)
+	case "force":
+		if len(os.Args) != 3 {
+			usage()
+			os.Exit(2)
+		}
+		err = force(ctx, os.Args[2])
+	default:
+		usage()
+		os.Exit(2)
+	}
+
+	if err != nil {
+		log.Fatalf("%s: %v", os.Args[1], err)
+	}
+}
+
+func up(ctx context.Context) error {
+	applied, err := migrations.Up(ctx, db.Pool())
+	for _, m := range applied {
+		log.Printf("Applied %s", m.Name)
+	}
+	if err != nil {
+		return err
+	}
+
+	if len(applied) == 0 {
+		log.Println("Schema is up to date")
+	}
+	return nil
+}
+
+func status(ctx context.Context) error {
+	current, err := migrations.Current(ctx, db.Pool())
+	if err != nil {
+		return err
+	}
+
+	all, err := migrations.All()
+	if err != nil {
+		return err
+	}
+
+	for _, m := range all {
+		state := "pending"
+		if m.Version <= current {
+			state = "applied"
+		}
+		fmt.Printf("%-8s %s\n", state, m.Name)
+	}
+	fmt.Printf("\nCurrent version: %d, expected: %d\n", current, migrations.Latest())
+	return nil
+}
+
+func force(ctx context.Context, arg string) error {
+	version, err := strconv.Atoi(arg)
+	if err != nil || version < 0 || version > migrations.Latest() {
+		return fmt.Errorf("invalid version %q", arg)
+	}
+
+	if err := migrations.Force(ctx, db.Pool(), version); err != nil {
+		return err
+	}
+	log.Printf("Schema version set to %d", version)
+	return nil
+}
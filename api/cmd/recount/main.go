@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/joho/godotenv"
+
+	"github.com/subculture-collective/epstein-db/api/internal/db"
+	"github.com/subculture-collective/epstein-db/api/internal/jobs"
+	"github.com/subculture-collective/epstein-db/api/internal/recount"
+)
+
+func main() {
+	var opts recount.Options
+	var since, documents string
+	var queue bool
+
+	flag.StringVar(&since, "since", "", "only recount entities in documents created or updated since this RFC 3339 time")
+	flag.StringVar(&documents, "documents", "", "only recount entities in these comma-separated document IDs")
+	flag.BoolVar(&opts.DryRun, "dry-run", false, "report discrepancies without fixing them")
+	flag.BoolVar(&queue, "queue", false, "process queued recount jobs until interrupted")
+	flag.Parse()
+
+	if since != "" {
+		t, err := time.Parse(time.RFC3339, since)
+		if err != nil {
+			log.Fatalf("Invalid -since: %v", err)
+		}
+		opts.Since = &t
+	}
+	for _, s := range strings.Split(documents, ",") {
+		if s = strings.TrimSpace(s); s == "" {
+			continue
+		}
+		id, err := strconv.Atoi(s)
+		if err != nil {
+			log.Fatalf("Invalid document ID %q", s)
+		}
+		opts.DocumentIDs = append(opts.DocumentIDs, id)
+	}
+
+	// Load .env file
+	if err := godotenv.Load(); err != nil {
+		log.Println("No .env file found, using environment variables")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	// Initialize database connection
+	if err := db.Initialize(ctx); err != nil {
+		log.Fatalf("Failed to initialize database: %v", err)
+	}
+	defer db.Close()
+
+	if queue {
+		err := jobs.NewQueue(db.Pool()).Work(ctx, recount.JobKind, 10*time.Second,
+			func(ctx context.Context, job *jobs.Job) (any, error) {
+				var opts recount.Options
+				if err := job.DecodeParams(&opts); err != nil {
+					return nil, err
+				}
+				return recount.Run(ctx, db.Pool(), opts)
+			})
+		if err != nil {
+			log.Fatalf("recount: %v", err)
+		}
+		return
+	}
+
+	report, err := recount.Run(ctx, db.Pool(), opts)
+	if err != nil {
+		log.Fatalf("recount: %v", err)
+	}
+
+	for _, d := range report.Sample {
+		log.Printf("recount: %d %q documents %d -> %d, connections %d -> %d",
+			d.EntityID, d.CanonicalName,
+			d.StoredDocumentCount, d.ActualDocumentCount,
+			d.StoredConnectionCount, d.ActualConnectionCount)
+	}
+	log.Printf("recount: %d discrepancies (%d document counts, %d connection counts), %d updated",
+		report.Discrepancies, report.DocumentCounts, report.ConnectionCounts, report.Updated)
+}
@@ -2,104 +2,672 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
+	"fmt"
 	"log"
+	"net"
+	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
+	"github.com/99designs/gqlgen/graphql/playground"
 	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+	"github.com/gofiber/fiber/v2/middleware/compress"
 	"github.com/gofiber/fiber/v2/middleware/cors"
-	"github.com/gofiber/fiber/v2/middleware/logger"
 	"github.com/gofiber/fiber/v2/middleware/recover"
 	"github.com/joho/godotenv"
+	"google.golang.org/grpc"
 
+	"github.com/subculture-collective/epstein-db/api/internal/adminnet"
+	"github.com/subculture-collective/epstein-db/api/internal/apierr"
+	"github.com/subculture-collective/epstein-db/api/internal/audit"
+	"github.com/subculture-collective/epstein-db/api/internal/auth"
+	"github.com/subculture-collective/epstein-db/api/internal/blobs"
+	"github.com/subculture-collective/epstein-db/api/internal/bookmarks"
+	"github.com/subculture-collective/epstein-db/api/internal/captcha"
+	"github.com/subculture-collective/epstein-db/api/internal/cdn"
+	"github.com/subculture-collective/epstein-db/api/internal/chat"
+	"github.com/subculture-collective/epstein-db/api/internal/config"
 	"github.com/subculture-collective/epstein-db/api/internal/db"
+	"github.com/subculture-collective/epstein-db/api/internal/embeddings"
+	"github.com/subculture-collective/epstein-db/api/internal/events"
+	"github.com/subculture-collective/epstein-db/api/internal/graph"
 	"github.com/subculture-collective/epstein-db/api/internal/handlers"
+	"github.com/subculture-collective/epstein-db/api/internal/health"
+	"github.com/subculture-collective/epstein-db/api/internal/llm"
+	"github.com/subculture-collective/epstein-db/api/internal/logging"
+	"github.com/subculture-collective/epstein-db/api/internal/media"
+	"github.com/subculture-collective/epstein-db/api/internal/metrics"
+	"github.com/subculture-collective/epstein-db/api/internal/migrations"
+	"github.com/subculture-collective/epstein-db/api/internal/mirror"
+	"github.com/subculture-collective/epstein-db/api/internal/openapi"
+	"github.com/subculture-collective/epstein-db/api/internal/overload"
+	"github.com/subculture-collective/epstein-db/api/internal/rag"
+	"github.com/subculture-collective/epstein-db/api/internal/ratelimit"
+	"github.com/subculture-collective/epstein-db/api/internal/rpc"
+	"github.com/subculture-collective/epstein-db/api/internal/signedurl"
+	"github.com/subculture-collective/epstein-db/api/internal/store"
+	"github.com/subculture-collective/epstein-db/api/internal/timeout"
+	"github.com/subculture-collective/epstein-db/api/internal/tracing"
+	"github.com/subculture-collective/epstein-db/api/internal/trending"
+	"github.com/subculture-collective/epstein-db/api/internal/upload"
 )
 
+// formOverhead is the room an upload route's body limit leaves for the
+// multipart framing and form fields around the file
+const formOverhead = 64 << 10
+
 func main() {
+	mirrorMode := flag.Bool("mirror", false, "serve as a read-only public mirror (same as MIRROR_MODE=true)")
+	flag.Parse()
+
 	// Load .env file
 	if err := godotenv.Load(); err != nil {
 		log.Println("No .env file found, using environment variables")
 	}
+	if *mirrorMode {
+		os.Setenv("MIRROR_MODE", "true")
+	}
+
+	logging.Setup("epstein-api")
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal(err)
+	}
+	handlers.SetConfig(cfg)
+	// A mirror only reads, and leaves out everything that writes, needs a
+	// role above public or calls a model
+	writable := !cfg.Mirror.Enabled
 
-	// Initialize database connection
-	if err := db.Initialize(context.Background()); err != nil {
+	// Export traces when OTEL_EXPORTER_OTLP_ENDPOINT is set
+	shutdownTracing, err := tracing.Init(context.Background(), "epstein-api")
+	if err != nil {
+		log.Fatalf("Failed to initialize tracing: %v", err)
+	}
+	defer shutdownTracing(context.Background())
+
+	// Initialize database connection. Postgres enforces the statement
+	// timeout itself, so queries stop even if the server loses track of them.
+	if err := db.InitializeWith(context.Background(), cfg.DB()); err != nil {
 		log.Fatalf("Failed to initialize database: %v", err)
 	}
 	defer db.Close()
 
+	// Refuse to serve against a schema this build doesn't understand
+	if _, err := migrations.Check(context.Background(), db.Pool()); err != nil {
+		log.Fatalf("Schema check failed: %v", err)
+	}
+
+	data := store.New(db.Pool(), db.ReadPool())
+	handlers.SetStore(data)
+
+	checks, err := readinessChecks(cfg)
+	if err != nil {
+		log.Fatalf("Failed to set up health checks: %v", err)
+	}
+	handlers.SetHealthChecks(checks)
+
+	// Answer questions, chat and check claims when models are configured; without
+	// an embedding provider retrieval falls back to full-text search alone.
+	// Mirrors don't serve the model-backed endpoints, so skip the models.
+	embedder, err := embeddings.NewProviderFromEnv()
+	if err != nil && !errors.Is(err, embeddings.ErrNotConfigured) {
+		log.Fatalf("Failed to set up embeddings: %v", err)
+	}
+	retriever := rag.NewRetriever(db.ReadPool(), embedder)
+	handlers.SetRetriever(retriever)
+
+	if writable {
+		models := cfg.LLMSettings()
+		models.Recorder = llm.NewDBRecorder(db.Pool())
+		if client, err := llm.New(models, llm.TaskAsk); err == nil {
+			handlers.SetAsker(rag.NewAsker(retriever, client, rag.DefaultConfig()))
+		} else if !errors.Is(err, llm.ErrNotConfigured) {
+			log.Fatalf("Failed to set up the %s model: %v", llm.TaskAsk, err)
+		}
+		if client, err := llm.New(models, llm.TaskChat); err == nil {
+			handlers.SetChat(chat.New(db.Pool(), data, retriever, client, chat.DefaultConfig()))
+		} else if !errors.Is(err, llm.ErrNotConfigured) {
+			log.Fatalf("Failed to set up the %s model: %v", llm.TaskChat, err)
+		}
+		if client, err := llm.New(models, llm.TaskVerify); err == nil {
+			handlers.SetVerifier(rag.NewVerifier(retriever, client, rag.DefaultConfig()))
+		} else if !errors.Is(err, llm.ErrNotConfigured) {
+			log.Fatalf("Failed to set up the %s model: %v", llm.TaskVerify, err)
+		}
+	}
+
+	// Relay database events to /api/events subscribers
+	hub := events.NewHub()
+	hubCtx, stopHub := context.WithCancel(context.Background())
+	go hub.Run(hubCtx, db.Pool())
+	handlers.SetEvents(hub)
+
+	// Landing page lists. Entity views are counted in memory and saved every
+	// minute; a mirror's database may be a replica, so mirrors don't count.
+	var views *trending.Counter
+	viewsCtx, stopViews := context.WithCancel(context.Background())
+	viewsDone := make(chan struct{})
+	if writable {
+		views = trending.NewCounter()
+		go func() {
+			views.Run(viewsCtx, db.Pool(), time.Minute)
+			close(viewsDone)
+		}()
+	} else {
+		close(viewsDone)
+	}
+	handlers.SetTrending(trending.NewCache(db.ReadPool(), time.Minute), views)
+
+	// Tips and correction requests need a captcha in production; without a
+	// secret they're taken unchecked
+	if writable {
+		verifier, err := captcha.New(cfg.Tips.CaptchaProvider, cfg.Tips.CaptchaSecret)
+		switch {
+		case err == nil:
+			handlers.SetCaptcha(verifier)
+		case errors.Is(err, captcha.ErrNotConfigured):
+			log.Printf("CAPTCHA_SECRET is not set; tips and correction requests are accepted without a captcha")
+		default:
+			log.Fatalf("Failed to set up the captcha: %v", err)
+		}
+	}
+
+	// Downloads are handed out as signed links
+	signer, err := signedurl.New(cfg.Downloads.SigningKey, cfg.Downloads.URLTTL)
+	if err != nil {
+		log.Fatalf("Failed to set up download signing: %v", err)
+	}
+	if cfg.Downloads.SigningKey == "" {
+		log.Printf("DOWNLOAD_SIGNING_KEY is not set; download links only work on this instance until it restarts")
+	}
+	handlers.SetSigner(signer)
+
+	// Files are sent from object storage when it's configured
+	blobStore, err := blobs.Open(db.Pool(), cfg.Storage, cfg.Downloads.URLTTL)
+	if err != nil {
+		log.Fatalf("Failed to set up object storage: %v", err)
+	}
+	switch {
+	case !blobStore.Enabled():
+		log.Printf("S3_BUCKET is not set; documents' files, thumbnails and exports are sent by the API")
+	case cfg.Storage.Proxy:
+		log.Printf("BLOB_PROXY is set; files in %s are streamed through the API", cfg.Storage.Bucket)
+	}
+	handlers.SetBlobs(blobStore)
+
+	// The CDN in front, if any, is purged through its API
+	if purger, err := cdn.New(cfg.CDN); err == nil {
+		handlers.SetPurger(purger)
+	} else if !errors.Is(err, cdn.ErrNotConfigured) {
+		log.Fatalf("Failed to set up the CDN: %v", err)
+	}
+
 	// Create Fiber app
 	app := fiber.New(fiber.Config{
-		AppName: "Epstein Files API",
+		AppName:      "Epstein Files API",
+		ErrorHandler: apierr.Handler,
+		// Behind a reverse proxy, e.g. X-Forwarded-For, so that rate limits
+		// apply to clients rather than the proxy
+		ProxyHeader: cfg.ProxyHeader,
+		// Bodies are read as handlers ask for them rather than up front, and
+		// multipart files are spooled to disk, so uploads aren't held in
+		// memory. upload.Limit enforces the size limits.
+		BodyLimit:                    cfg.Uploads.BodyLimit,
+		StreamRequestBody:            true,
+		DisablePreParseMultipartForm: true,
 	})
 
 	// Middleware
+	app.Use(logging.Middleware())
 	app.Use(recover.New())
-	app.Use(logger.New())
+	app.Use(compress.New(compress.Config{
+		Level: compress.LevelBestSpeed,
+		// Compression buffers, which would hold back server-sent events
+		Next: func(c *fiber.Ctx) bool { return c.Path() == "/api/events" },
+	}))
+	app.Use(metrics.Middleware())
+	app.Use(tracing.Middleware())
 	app.Use(cors.New(cors.Config{
-		AllowOrigins: "*",
-		AllowMethods: "GET,POST,PUT,DELETE,OPTIONS",
-		AllowHeaders: "Origin, Content-Type, Accept, Authorization",
+		AllowOrigins:  cfg.CORSOrigins,
+		AllowMethods:  "GET,POST,PUT,DELETE,OPTIONS",
+		AllowHeaders:  "Origin, Content-Type, Accept, Authorization, X-API-Key, X-Request-ID",
+		ExposeHeaders: "ETag, X-Request-ID, X-RateLimit-Limit, X-RateLimit-Remaining, X-RateLimit-Reset, Retry-After",
 	}))
+	app.Use(upload.Limit(int64(cfg.Uploads.BodyLimit),
+		upload.Route{Pattern: "/api/admin/ocr", Max: int64(cfg.Uploads.MaxScan) + formOverhead},
+		upload.Route{Pattern: "/api/admin/entities/*/media", Max: media.MaxBytes + formOverhead},
+	))
+
+	// Queue requests in front of the pool rather than on it, so a saturated
+	// database answers 503 quickly. Streams would hold a slot indefinitely,
+	// and batches are gated request by request.
+	if cfg.Database.AcquireTimeout > 0 {
+		gate := overload.New(overload.Config{
+			Capacity: int(db.Pool().Config().MaxConns),
+			Wait:     cfg.Database.AcquireTimeout,
+			Cooldown: cfg.Database.BreakerCooldown,
+		})
+		metrics.RegisterAdmission(gate)
+		app.Use(gate.Middleware("/api/events", "/api/export/rdf", "/api/batch", "/health", "/metrics"))
+	}
+
+	// Identify the caller; routes below declare the role they need. A
+	// mirror's database may be a replica, so it doesn't record key use.
+	authenticator := auth.New(db.Pool())
+	if !writable {
+		authenticator = auth.NewReadOnly(db.Pool())
+	}
+	app.Use(ratelimit.FailedAuth(cfg.RateLimit.FailedAuthPerMinute))
+	app.Use(authenticator.Middleware())
+	app.Use(ratelimit.Middleware(ratelimit.New(), cfg.RateLimit))
+	// Ahead of the CDN headers and the mirror cache, so that a keyed
+	// caller's response is marked private before either sees it
+	app.Use(handlers.RestrictedAccess)
+	if cfg.CDN.Headers {
+		// Outside the mirror cache, to see the final Cache-Control
+		app.Use(cdn.Headers(cfg.CDN.Provider))
+	}
+	if !writable {
+		log.Printf("Serving as a read-only mirror")
+		// Batches, name resolution, matrices and GraphQL (which has no
+		// mutations) read over POST
+		app.Use(mirror.ReadOnly("/api/batch", "/api/entities/resolve", "/api/network/matrix", "/graphql"))
+		// Streams are never cached, nor are responses to keyed callers,
+		// which may include restricted content
+		app.Use(mirror.NewCache(cfg.Mirror.CacheTTL, cfg.Mirror.CacheSize, "/api/events", "/api/export/rdf").Middleware())
+	}
+	app.Use(timeout.Middleware(cfg.Timeouts.Request))
+	app.Use(handlers.CollectWarnings)
+	app.Use(handlers.ConfidenceFilter)
+	app.Use(handlers.SensitivityFilter)
+	researcher := auth.Require(auth.RoleResearcher)
+	admin := auth.Require(auth.RoleAdmin)
+	// Maintenance routes may also be kept to their own listener and networks
+	adminOnly := adminnet.Guard(cfg.Admin)
 
-	// Routes
-	api := app.Group("/api")
+	// Routes are documented as they're registered
+	spec := openapi.New("Epstein Files API", "1.0.0")
+	api := openapi.NewRouter(app.Group("/api"), spec, "/api")
 
 	// Stats
-	api.Get("/stats", handlers.GetStats)
+	api.Get("/stats", handlers.GetStatsSpec, handlers.GetStats)
+	api.Get("/trending", handlers.GetTrendingSpec, handlers.GetTrending)
+	api.Get("/analytics/mentions", handlers.GetMentionsSpec, handlers.GetMentions)
 
 	// Entities
-	api.Get("/entities", handlers.SearchEntities)
-	api.Get("/entities/:id", handlers.GetEntity)
-	api.Get("/entities/:id/connections", handlers.GetEntityConnections)
-	api.Get("/entities/:id/documents", handlers.GetEntityDocuments)
+	api.Get("/entities", handlers.SearchEntitiesSpec, handlers.SearchEntities)
+	api.Post("/entities/resolve", handlers.ResolveEntitiesSpec, handlers.ResolveEntities)
+	api.Get("/entities/:id", handlers.GetEntitySpec, handlers.TrackView(bookmarks.TypeEntity), handlers.CountEntityView, handlers.GetEntity)
+	api.Get("/entities/:id/connections", handlers.GetEntityConnectionsSpec, handlers.GetEntityConnections)
+	api.Get("/entities/:id/network.svg", handlers.GetEntityNetworkSVGSpec, handlers.GetEntityNetworkSVG)
+	api.Get("/entities/:id/documents", handlers.GetEntityDocumentsSpec, handlers.GetEntityDocuments)
+	api.Get("/entities/:id/bio", handlers.GetEntityBioSpec, handlers.GetEntityBio)
+	api.Get("/entities/:id/narrative", handlers.GetEntityNarrativeSpec, handlers.GetEntityNarrative)
+	api.Get("/entities/:id/media", handlers.ListEntityMediaSpec, handlers.ListEntityMedia)
+	api.Get("/media/:id", handlers.ServeMediaSpec, handlers.ServeMedia)
+	if writable {
+		api.Post("/entities/:id/bio/generate", handlers.QueueEntityBioSpec, admin, audit.Middleware(db.Pool()), handlers.QueueEntityBio)
+		api.Post("/entities/:id/narrative/generate", handlers.QueueEntityNarrativeSpec, admin, audit.Middleware(db.Pool()), handlers.QueueEntityNarrative)
+	}
+	api.Get("/export/rdf", handlers.ExportRDFSpec, researcher, handlers.ExportRDF)
 
 	// Documents
-	api.Get("/documents", handlers.ListDocuments)
-	api.Get("/documents/:id", handlers.GetDocument)
-	api.Get("/documents/:id/text", handlers.GetDocumentText)
-	api.Get("/documents/:id/entities", handlers.GetDocumentEntities)
+	api.Get("/documents", handlers.ListDocumentsSpec, handlers.ListDocuments)
+	api.Get("/documents/random", handlers.RandomDocumentSpec, handlers.RandomDocument)
+	api.Get("/documents/:id", handlers.GetDocumentSpec, handlers.TrackView(bookmarks.TypeDocument), handlers.GetDocument)
+	api.Get("/documents/:id/text", handlers.GetDocumentTextSpec, handlers.GetDocumentText)
+	api.Get("/documents/:id/entities", handlers.GetDocumentEntitiesSpec, handlers.GetDocumentEntities)
+	api.Get("/documents/:id/file", handlers.GetDocumentFileSpec, handlers.GetDocumentFile)
+	api.Get("/documents/:id/provenance", handlers.GetDocumentProvenanceSpec, handlers.GetDocumentProvenance)
+	api.Get("/documents/:id/duplicates", handlers.GetDocumentDuplicatesSpec, handlers.GetDocumentDuplicates)
+	api.Get("/documents/:id/pages/:n/thumbnail", handlers.GetPageThumbnailSpec, handlers.GetPageThumbnail)
+	api.Get("/documents/:id/cite", handlers.CiteDocumentSpec, handlers.CiteDocument)
+	api.Get("/documents/:id/chunks", handlers.GetDocumentChunksSpec, handlers.GetDocumentChunks)
+
+	// Datasets
+	api.Get("/datasets", handlers.ListDatasetsSpec, handlers.ListDatasets)
+	api.Get("/datasets/:id/analytics", handlers.GetDatasetAnalyticsSpec, handlers.GetDatasetAnalytics)
+
+	// Link previews
+	api.Get("/meta/entity-types", handlers.GetEntityTypesSpec, handlers.GetEntityTypes)
+	api.Get("/meta/document-types", handlers.GetDocumentTypesSpec, handlers.GetDocumentTypes)
+	api.Get("/tags", handlers.ListTagsSpec, handlers.ListTags)
+	api.Get("/meta/:type/:id", handlers.GetMetaSpec, handlers.GetMeta)
 
 	// Graph/Network
-	api.Get("/network", handlers.GetNetwork)
-	api.Get("/network/layers", handlers.GetNetworkByLayer)
+	api.Get("/network", handlers.GetNetworkSpec, handlers.GetNetwork)
+	api.Get("/network/layers", handlers.GetNetworkByLayerSpec, handlers.GetNetworkByLayer)
+	api.Post("/network/matrix", handlers.MatrixSpec, handlers.GetMatrix)
+	api.Get("/network/financial", handlers.GetFinancialNetworkSpec, handlers.GetFinancialNetwork)
+
+	// Triples
+	api.Get("/triples", handlers.SearchTriplesSpec, handlers.SearchTriples)
+	api.Get("/triples/predicates", handlers.ListPredicatesSpec, handlers.ListPredicates)
 
 	// Cross-references
-	api.Get("/crossref/ppp", handlers.SearchPPP)
-	api.Get("/crossref/fec", handlers.SearchFEC)
-	api.Get("/crossref/grants", handlers.SearchGrants)
+	api.Get("/crossref/ppp", handlers.SearchPPPSpec, handlers.SearchPPP)
+	api.Get("/crossref/fec", handlers.SearchFECSpec, handlers.SearchFEC)
+	api.Get("/crossref/grants", handlers.SearchGrantsSpec, handlers.SearchGrants)
+	api.Get("/analysis/shared-attributes", handlers.GetSharedAttributesSpec, handlers.GetSharedAttributes)
+	api.Get("/analysis/shell-candidates", handlers.ListShellCandidatesSpec, handlers.ListShellCandidates)
+	api.Get("/analysis/anomalies", handlers.ListAnomaliesSpec, handlers.ListAnomalies)
 
 	// Patterns
-	api.Get("/patterns", handlers.ListPatterns)
-	api.Get("/patterns/:id", handlers.GetPattern)
+	api.Get("/patterns", handlers.ListPatternsSpec, handlers.ListPatterns)
+	api.Get("/patterns/:id", handlers.GetPatternSpec, handlers.GetPattern)
+
+	// Timeline
+	api.Get("/timeline", handlers.GetTimelineSpec, handlers.GetTimeline)
+	if writable {
+		api.Post("/timeline/events", handlers.CreateTimelineEventSpec, researcher, audit.Middleware(db.Pool()), handlers.CreateTimelineEvent)
+		api.Put("/timeline/events/:id", handlers.UpdateTimelineEventSpec, researcher, audit.Middleware(db.Pool()), handlers.UpdateTimelineEvent)
+		api.Delete("/timeline/events/:id", handlers.DeleteTimelineEventSpec, researcher, audit.Middleware(db.Pool()), handlers.DeleteTimelineEvent)
+	}
 
 	// Search
-	api.Get("/search", handlers.FullTextSearch)
+	api.Get("/search", handlers.FullTextSearchSpec, handlers.FullTextSearch)
+	api.Get("/chunks/search", handlers.SearchChunksSpec, handlers.SearchChunks)
+	if writable {
+		api.Post("/ask", handlers.AskSpec, researcher, handlers.Ask)
+		api.Post("/verify", handlers.VerifySpec, researcher, handlers.Verify)
 
-	// Health check
-	app.Get("/health", func(c *fiber.Ctx) error {
-		return c.JSON(fiber.Map{"status": "ok"})
-	})
+		// Research chat
+		api.Post("/chat/sessions", handlers.CreateSessionSpec, researcher, handlers.CreateSession)
+		api.Get("/chat/sessions", handlers.ListSessionsSpec, researcher, handlers.ListSessions)
+		api.Get("/chat/sessions/:id", handlers.GetSessionSpec, researcher, handlers.GetSession)
+		api.Delete("/chat/sessions/:id", handlers.DeleteSessionSpec, researcher, handlers.DeleteSession)
+		api.Post("/chat/sessions/:id/messages", handlers.SendMessageSpec, researcher, handlers.SendMessage)
+		api.Get("/chat/sessions/:id/transcript", handlers.ExportSessionSpec, researcher, handlers.ExportSession)
+
+		// Webhooks, per API key
+		api.Post("/webhooks", handlers.CreateWebhookSpec, researcher, audit.Middleware(db.Pool()), handlers.CreateWebhook)
+		api.Get("/webhooks", handlers.ListWebhooksSpec, researcher, handlers.ListWebhooks)
+		api.Delete("/webhooks/:id", handlers.DeleteWebhookSpec, researcher, audit.Middleware(db.Pool()), handlers.DeleteWebhook)
+		api.Get("/webhooks/:id/deliveries", handlers.ListDeliveriesSpec, researcher, handlers.ListDeliveries)
 
-	// Get port from environment
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "3001"
+		// Research collections, per API key, readable by anyone with a share
+		// token
+		api.Post("/collections", handlers.CreateCollectionSpec, researcher, audit.Middleware(db.Pool()), handlers.CreateCollection)
+		api.Get("/collections", handlers.ListCollectionsSpec, researcher, handlers.ListCollections)
+		api.Get("/collections/:id", handlers.GetCollectionSpec, researcher, handlers.GetCollection)
+		api.Put("/collections/:id", handlers.UpdateCollectionSpec, researcher, audit.Middleware(db.Pool()), handlers.UpdateCollection)
+		api.Delete("/collections/:id", handlers.DeleteCollectionSpec, researcher, audit.Middleware(db.Pool()), handlers.DeleteCollection)
+		api.Post("/collections/:id/items", handlers.AddCollectionItemSpec, researcher, audit.Middleware(db.Pool()), handlers.AddCollectionItem)
+		api.Delete("/collections/:id/items/:itemId", handlers.RemoveCollectionItemSpec, researcher, audit.Middleware(db.Pool()), handlers.RemoveCollectionItem)
+		api.Post("/collections/:id/share", handlers.ShareCollectionSpec, researcher, audit.Middleware(db.Pool()), handlers.ShareCollection)
+		api.Delete("/collections/:id/share", handlers.UnshareCollectionSpec, researcher, audit.Middleware(db.Pool()), handlers.UnshareCollection)
+		api.Get("/collections/:id/export", handlers.ExportCollectionSpec, researcher, handlers.ExportCollection)
+		api.Get("/shared/:token", handlers.GetSharedCollectionSpec, handlers.GetSharedCollection)
+		api.Get("/shared/:token/export", handlers.ExportSharedCollectionSpec, handlers.ExportSharedCollection)
+
+		// Bookmarks and reading history, per API key
+		api.Get("/me", handlers.GetMeSpec, handlers.GetMe)
+		api.Get("/bookmarks", handlers.ListBookmarksSpec, researcher, handlers.ListBookmarks)
+		api.Put("/bookmarks/:type/:id", handlers.PutBookmarkSpec, researcher, audit.Middleware(db.Pool()), handlers.PutBookmark)
+		api.Delete("/bookmarks/:type/:id", handlers.DeleteBookmarkSpec, researcher, audit.Middleware(db.Pool()), handlers.DeleteBookmark)
+		api.Get("/history", handlers.ListHistorySpec, researcher, handlers.ListHistory)
+		api.Delete("/history", handlers.ClearHistorySpec, researcher, audit.Middleware(db.Pool()), handlers.ClearHistory)
+
+		// Email digests, per API key, with one-click unsubscribe
+		api.Post("/digests", handlers.SubscribeSpec, researcher, audit.Middleware(db.Pool()), handlers.Subscribe)
+		api.Get("/digests", handlers.ListSubscriptionsSpec, researcher, handlers.ListSubscriptions)
+		api.Get("/digests/unsubscribe", handlers.UnsubscribeSpec, handlers.Unsubscribe)
+		api.Post("/digests/unsubscribe", handlers.UnsubscribeSpec, handlers.Unsubscribe)
+		api.Delete("/digests/:id", handlers.DeleteSubscriptionSpec, researcher, audit.Middleware(db.Pool()), handlers.DeleteSubscription)
+
+		// Tips from the public, held for moderation
+		api.Post("/tips", handlers.SubmitTipSpec, ratelimit.Route(cfg.Tips.PerHour, time.Hour), handlers.SubmitTip)
+
+		// Corrections requested by subjects or their counsel
+		api.Post("/requests/correction", handlers.RequestCorrectionSpec, ratelimit.Route(cfg.Tips.PerHour, time.Hour), handlers.RequestCorrection)
+		api.Get("/requests/correction/:id", handlers.GetCorrectionCaseSpec, handlers.GetCorrectionCase)
+
+		// Bulk exports, written in the background and fetched by signed link
+		api.Post("/exports", handlers.CreateExportSpec, researcher, audit.Middleware(db.Pool()), handlers.CreateExport)
+		api.Get("/exports/:id", handlers.GetExportSpec, researcher, handlers.GetExport)
+		api.Get("/exports/:id/download", handlers.DownloadExportSpec, handlers.DownloadExport)
+
+		// Reports on sets of entities, built in the background
+		api.Post("/reports", handlers.CreateReportSpec, researcher, audit.Middleware(db.Pool()), handlers.CreateReport)
+		api.Get("/reports/:id", handlers.GetReportSpec, researcher, handlers.GetReport)
+		api.Get("/reports/:id/download", handlers.DownloadReportSpec, researcher, handlers.DownloadReport)
+	}
+
+	// Several reads in one request
+	api.Post("/batch", handlers.BatchSpec, handlers.Batch(app))
+
+	// Live updates
+	api.Get("/events", handlers.StreamEventsSpec, handlers.StreamEvents)
+
+	// Change feed for mirrors
+	api.Get("/changes", handlers.ListChangesSpec, handlers.ListChanges)
+
+	// Admin: maintenance and anything that changes data. Every change is
+	// recorded in the audit log.
+	if writable {
+		adminAPI := api.Group("/admin", adminOnly, admin, audit.Middleware(db.Pool()))
+		adminAPI.Get("/audit", handlers.GetAuditLogSpec, handlers.GetAuditLog)
+		adminAPI.Get("/config", handlers.GetConfigSpec, handlers.GetConfig)
+		adminAPI.Get("/jobs", handlers.ListJobsSpec, handlers.ListJobs)
+		adminAPI.Get("/jobs/:id", handlers.GetJobSpec, handlers.GetJob)
+		adminAPI.Get("/jobs/:id/usage", handlers.GetJobUsageSpec, handlers.GetJobUsage)
+		adminAPI.Post("/summaries", handlers.QueueSummarizationSpec, handlers.QueueSummarization)
+		adminAPI.Post("/roles", handlers.QueueRoleInferenceSpec, handlers.QueueRoleInference)
+		adminAPI.Post("/embeddings", handlers.QueueEmbeddingSpec, handlers.QueueEmbedding)
+		adminAPI.Get("/embeddings", handlers.GetEmbeddingStatusSpec, handlers.GetEmbeddingStatus)
+		adminAPI.Get("/quality", handlers.GetQualityReportSpec, handlers.GetQualityReport)
+		adminAPI.Get("/quality/history", handlers.GetQualityHistorySpec, handlers.GetQualityHistory)
+		adminAPI.Post("/quality", handlers.QueueQualityReportSpec, handlers.QueueQualityReport)
+		adminAPI.Get("/dates", handlers.GetDateReportSpec, handlers.GetDateReport)
+		adminAPI.Get("/worklist", handlers.GetWorklistSpec, handlers.GetWorklist)
+		adminAPI.Post("/worklist/claims", handlers.ClaimWorklistSpec, handlers.ClaimWorklist)
+		adminAPI.Delete("/worklist/claims/:id", handlers.ReleaseWorklistClaimSpec, handlers.ReleaseWorklistClaim)
+		adminAPI.Post("/analysis/shells", handlers.QueueShellAnalysisSpec, handlers.QueueShellAnalysis)
+		adminAPI.Post("/analysis/anomalies", handlers.QueueAnomalyDetectionSpec, handlers.QueueAnomalyDetection)
+		adminAPI.Get("/patterns/temporal", handlers.GetTemporalSettingsSpec, handlers.GetTemporalSettings)
+		adminAPI.Put("/patterns/temporal", handlers.UpdateTemporalSettingsSpec, handlers.UpdateTemporalSettings)
+		adminAPI.Post("/patterns/run", handlers.QueuePatternDetectionSpec, handlers.QueuePatternDetection)
+		adminAPI.Post("/recount", handlers.RecountEntitiesSpec, handlers.RecountEntities)
+		adminAPI.Post("/cache/purge", handlers.PurgeCacheSpec, handlers.PurgeCache)
+		adminAPI.Post("/export/snapshot", handlers.QueueSnapshotSpec, handlers.QueueSnapshot)
+		adminAPI.Get("/export/snapshots", handlers.ListSnapshotsSpec, handlers.ListSnapshots)
+		adminAPI.Get("/export/snapshots/:name", handlers.DownloadSnapshotSpec, handlers.DownloadSnapshot)
+		adminAPI.Post("/ocr", handlers.UploadScanSpec, upload.Accept(fiber.MIMEMultipartForm), handlers.UploadScan)
+		adminAPI.Get("/tips", handlers.ListTipsSpec, handlers.ListTips)
+		adminAPI.Get("/tips/:id", handlers.GetTipSpec, handlers.GetTip)
+		adminAPI.Put("/tips/:id", handlers.ReviewTipSpec, handlers.ReviewTip)
+		adminAPI.Post("/tips/:id/pattern", handlers.ConvertTipSpec, handlers.ConvertTip)
+		adminAPI.Get("/corrections", handlers.ListCorrectionsSpec, handlers.ListCorrections)
+		adminAPI.Get("/corrections/:id", handlers.GetCorrectionSpec, handlers.GetCorrection)
+		adminAPI.Put("/corrections/:id", handlers.ReviewCorrectionSpec, handlers.ReviewCorrection)
+		adminAPI.Get("/stoplist", handlers.ListStoplistSpec, handlers.ListStoplist)
+		adminAPI.Post("/stoplist", handlers.BlockNameSpec, handlers.BlockName)
+		adminAPI.Put("/stoplist/:id", handlers.ReviewStoplistSpec, handlers.ReviewStoplist)
+		adminAPI.Post("/entities/:id/media", handlers.AttachMediaSpec, upload.Accept(fiber.MIMEMultipartForm), handlers.AttachMedia)
+		adminAPI.Get("/entities/:id/description/revisions", handlers.GetDescriptionHistorySpec, handlers.GetDescriptionHistory)
+		adminAPI.Get("/entities/:id/description/diff", handlers.GetDescriptionDiffSpec, handlers.GetDescriptionDiff)
+		adminAPI.Put("/entities/:id/description", handlers.UpdateDescriptionSpec, handlers.UpdateDescription)
+		adminAPI.Post("/entities/:id/description/revert", handlers.RevertDescriptionSpec, handlers.RevertDescription)
+		adminAPI.Put("/documents/:id/tags", handlers.TagDocumentSpec, handlers.TagDocument)
+		adminAPI.Post("/tags/bulk", handlers.BulkTagSpec, handlers.BulkTagDocuments)
+		adminAPI.Put("/media/:id", handlers.UpdateMediaSpec, handlers.UpdateMedia)
+		adminAPI.Delete("/media/:id", handlers.DeleteMediaSpec, handlers.DeleteMedia)
+		adminAPI.Put("/datasets/:id/visibility", handlers.UpdateDatasetVisibilitySpec, handlers.UpdateDatasetVisibility)
+		adminAPI.Get("/trash", handlers.ListTrashSpec, handlers.ListTrash)
+		adminAPI.Delete("/documents/:id", handlers.DeleteDocumentSpec, handlers.DeleteDocument)
+		adminAPI.Post("/documents/:id/restore", handlers.RestoreDocumentSpec, handlers.RestoreDocument)
+		adminAPI.Delete("/entities/:id", handlers.DeleteEntitySpec, handlers.DeleteEntity)
+		adminAPI.Post("/entities/:id/restore", handlers.RestoreEntitySpec, handlers.RestoreEntity)
+		adminAPI.Get("/protected", handlers.ListProtectedSpec, handlers.ListProtected)
+		adminAPI.Put("/entities/:id/protected", handlers.ProtectEntitySpec, handlers.ProtectEntity)
+	}
+
+	// Health checks. /health predates the split and stays as readiness.
+	root := openapi.NewRouter(app, spec, "")
+	root.Get("/health/live", handlers.LiveSpec, handlers.Live)
+	root.Get("/health/ready", handlers.ReadySpec, handlers.Ready)
+	root.Get("/health", handlers.ReadySpec, handlers.Ready)
+
+	// Atom feeds for feed readers
+	root.Get("/feeds/documents.atom", handlers.DocumentsFeedSpec, handlers.DocumentsFeed)
+	root.Get("/feeds/patterns.atom", handlers.PatternsFeedSpec, handlers.PatternsFeed)
+
+	// Sitemaps for search engines
+	root.Get("/sitemap.xml", handlers.SitemapIndexSpec, handlers.SitemapIndex)
+	root.Get("/sitemaps/:kind/:page", handlers.SitemapPageSpec, handlers.SitemapPage)
+
+	// GraphQL
+	if cfg.Features.GraphQL {
+		app.All("/graphql", withUserContext(graph.Handler(db.Pool())))
+		app.Get("/graphql/playground", adaptor.HTTPHandler(playground.Handler("Epstein Files GraphQL", "/graphql")))
+	}
+
+	// Prometheus metrics
+	metrics.RegisterPool(db.Pool())
+	if replica := db.Replica(); replica != nil {
+		metrics.RegisterReplica(replica)
+	}
+	if writable {
+		app.Get("/metrics", adminOnly, admin, metrics.Handler())
+	}
+
+	// API documentation
+	if cfg.Features.Docs {
+		app.Get("/api/openapi.json", spec.Handler())
+		app.Get("/docs", openapi.DocsHandler("/api/openapi.json"))
+	}
+
+	// gRPC runs alongside HTTP on its own port. It only reads, and serves
+	// the bulk exports, so it uses the replica when there is one. Calls need
+	// an API key and get the keyed rate limit and the request timeout.
+	var grpcServer *grpc.Server
+	if cfg.Features.GRPC {
+		grpcServer = rpc.NewServer(db.ReadPool(), authenticator, rpc.Config{
+			Timeout:   cfg.Timeouts.Request,
+			PerMinute: cfg.RateLimit.KeyedPerMinute,
+		})
+		grpcListener, err := net.Listen("tcp", ":"+cfg.GRPCPort)
+		if err != nil {
+			log.Fatalf("gRPC listen error: %v", err)
+		}
+		go func() {
+			log.Printf("Starting gRPC server on port %s", cfg.GRPCPort)
+			if err := grpcServer.Serve(grpcListener); err != nil {
+				log.Printf("gRPC server error: %v", err)
+			}
+		}()
+	}
+
+	// Admin routes get their own listener when ADMIN_ADDR is set. It serves
+	// the same app; Guard turns admin requests away from the public port.
+	if writable && cfg.Admin.Addr != "" {
+		adminListener, err := adminnet.Listen(cfg.Admin)
+		if err != nil {
+			log.Fatalf("Admin listen error: %v", err)
+		}
+		go func() {
+			log.Printf("Starting admin listener on %s", cfg.Admin.Addr)
+			if err := app.Listener(adminListener); err != nil {
+				log.Printf("Admin listener error: %v", err)
+			}
+		}()
 	}
 
-	// Graceful shutdown
+	// Graceful shutdown: stop accepting connections, then give in-flight
+	// requests and streams up to SHUTDOWN_TIMEOUT to finish
+	drained := make(chan struct{})
 	go func() {
 		sigChan := make(chan os.Signal, 1)
 		signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 		<-sigChan
 		log.Println("Shutting down...")
-		app.Shutdown()
+
+		grace := cfg.Timeouts.Shutdown
+
+		// End event streams, which would otherwise hold shutdown open
+		stopHub()
+		hub.Close()
+
+		grpcStopped := make(chan struct{})
+		go func() {
+			if grpcServer != nil {
+				grpcServer.GracefulStop()
+			}
+			close(grpcStopped)
+		}()
+
+		if err := app.ShutdownWithTimeout(grace); err != nil {
+			log.Printf("HTTP shutdown: %v", err)
+		}
+		select {
+		case <-grpcStopped:
+		case <-time.After(grace):
+			grpcServer.Stop()
+		}
+
+		// Save the views counted since the last flush
+		stopViews()
+		<-viewsDone
+		close(drained)
 	}()
 
 	// Start server
-	log.Printf("Starting server on port %s", port)
-	if err := app.Listen(":" + port); err != nil {
+	log.Printf("Starting server on port %s", cfg.Port)
+	if err := app.Listen(":" + cfg.Port); err != nil {
 		log.Fatalf("Server error: %v", err)
 	}
+
+	// Listen returns as soon as shutdown begins; keep the pool open until
+	// requests have drained
+	<-drained
+}
+
+// readinessChecks lists the dependencies /health/ready probes: Postgres
+// always, the others only when configured. A lost replica degrades
+// readiness rather than failing it, since reads fall back to the primary.
+func readinessChecks(cfg *config.Config) ([]health.Checker, error) {
+	checks := []health.Checker{
+		{Name: "postgres", Required: true, Check: health.Postgres(db.Pool())},
+	}
+
+	if cfg.Database.ReplicaURL != "" {
+		check := func(context.Context) error {
+			return errors.New("not connected; reads use the primary")
+		}
+		if replica := db.Replica(); replica != nil {
+			check = health.Postgres(replica)
+		}
+		checks = append(checks, health.Checker{Name: "replica", Check: check})
+	}
+
+	if cfg.Redis.URL != "" {
+		checks = append(checks, health.Checker{Name: "redis", Required: true, Check: health.Redis(cfg.Redis.URL)})
+	}
+
+	if cfg.Neo4j.URI != "" {
+		check, err := health.Neo4j(cfg.Neo4j.URI, cfg.Neo4j.User, cfg.Neo4j.Password)
+		if err != nil {
+			return nil, fmt.Errorf("neo4j: %w", err)
+		}
+		checks = append(checks, health.Checker{Name: "neo4j", Required: true, Check: check})
+	}
+
+	return checks, nil
+}
+
+// withUserContext serves an http.Handler with the request's user context,
+// so its queries share the request's deadline, ID and trace
+func withUserContext(h http.Handler) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx := c.UserContext()
+		return adaptor.HTTPHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h.ServeHTTP(w, r.WithContext(ctx))
+		})(c)
+	}
 }
@@ -0,0 +1,1021 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/joho/godotenv"
+
+	"github.com/subculture-collective/epstein-db/api/internal/anomalies"
+	"github.com/subculture-collective/epstein-db/api/internal/bio"
+	"github.com/subculture-collective/epstein-db/api/internal/blobs"
+	"github.com/subculture-collective/epstein-db/api/internal/cdn"
+	"github.com/subculture-collective/epstein-db/api/internal/changes"
+	"github.com/subculture-collective/epstein-db/api/internal/config"
+	"github.com/subculture-collective/epstein-db/api/internal/dates"
+	"github.com/subculture-collective/epstein-db/api/internal/db"
+	"github.com/subculture-collective/epstein-db/api/internal/dedup"
+	"github.com/subculture-collective/epstein-db/api/internal/digest"
+	"github.com/subculture-collective/epstein-db/api/internal/duplicates"
+	"github.com/subculture-collective/epstein-db/api/internal/email"
+	"github.com/subculture-collective/epstein-db/api/internal/embeddings"
+	"github.com/subculture-collective/epstein-db/api/internal/exports"
+	"github.com/subculture-collective/epstein-db/api/internal/jobs"
+	"github.com/subculture-collective/epstein-db/api/internal/llm"
+	"github.com/subculture-collective/epstein-db/api/internal/logging"
+	"github.com/subculture-collective/epstein-db/api/internal/narrative"
+	"github.com/subculture-collective/epstein-db/api/internal/ocr"
+	"github.com/subculture-collective/epstein-db/api/internal/pages"
+	"github.com/subculture-collective/epstein-db/api/internal/patterns"
+	"github.com/subculture-collective/epstein-db/api/internal/quality"
+	"github.com/subculture-collective/epstein-db/api/internal/reports"
+	"github.com/subculture-collective/epstein-db/api/internal/roles"
+	"github.com/subculture-collective/epstein-db/api/internal/scheduler"
+	"github.com/subculture-collective/epstein-db/api/internal/sensitivity"
+	"github.com/subculture-collective/epstein-db/api/internal/separation"
+	"github.com/subculture-collective/epstein-db/api/internal/shells"
+	"github.com/subculture-collective/epstein-db/api/internal/stoplist"
+	"github.com/subculture-collective/epstein-db/api/internal/store"
+	"github.com/subculture-collective/epstein-db/api/internal/strength"
+	"github.com/subculture-collective/epstein-db/api/internal/summarize"
+	"github.com/subculture-collective/epstein-db/api/internal/thumbnails"
+	"github.com/subculture-collective/epstein-db/api/internal/timeline"
+	"github.com/subculture-collective/epstein-db/api/internal/tracing"
+	"github.com/subculture-collective/epstein-db/api/internal/trending"
+	"github.com/subculture-collective/epstein-db/api/internal/triples"
+	"github.com/subculture-collective/epstein-db/api/internal/watcher"
+	"github.com/subculture-collective/epstein-db/api/internal/webhooks"
+)
+
+// models configures the LLM clients; usage is recorded per job
+var models llm.Settings
+
+// settings is the loaded configuration
+var settings *config.Config
+
+func usage() {
+	fmt.Fprintf(os.Stderr, `Usage: worker <command> [flags]
+
+Commands:
+  dedup      Ask the LLM to review high-similarity entity pairs
+  triples    Extract subject-predicate-object triples from document text
+  summarize  Generate document summaries with cited source passages
+  bio        Generate sourced entity biographies
+  narrative  Generate cited entity chronologies
+  roles      Infer the roles entities play in the documents that mention them
+  timeline   Add timeline events for new dated relationships and records
+  changes    Publish the change log to NATS until interrupted
+  webhooks   Queue and send webhook deliveries until interrupted
+  cdn        Purge the CDN's copies of changed data until interrupted
+  digest     Email the digests that are due
+  embed      Chunk documents and store their embeddings
+  quality    Run the data quality checks
+  shells     Score organizations for signs of shell companies
+  anomalies  Flag outlying contribution and loan amounts
+  patterns   Run the pattern detectors
+  distances  Measure each entity's distance from the core and set layers
+  pages      Index which pages of each document name its entities
+  edges      Score the strength of relationships between entities
+  stoplist   Suggest boilerplate names for the entity stoplist
+  duplicates Find near-duplicate documents
+  dates      Find the dates documents mention and fill missing document dates
+  sensitivity Label documents needing content warnings
+  thumbnails Render page thumbnails of stored PDFs and images
+  schedule   Run recurring maintenance tasks until interrupted
+  watch      Ingest new dataset releases as they appear
+  ocr        OCR a scanned PDF or image into a document
+  export     Write a bulk export of documents, entities or triples
+  report     Build reports on sets of entities
+  blobs      Copy document files, thumbnails and exports to object storage
+`)
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	// Load .env file
+	if err := godotenv.Load(); err != nil {
+		log.Println("No .env file found, using environment variables")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	logging.Setup("epstein-worker")
+
+	// Export traces when OTEL_EXPORTER_OTLP_ENDPOINT is set
+	shutdownTracing, err := tracing.Init(ctx, "epstein-worker")
+	if err != nil {
+		log.Fatalf("Failed to initialize tracing: %v", err)
+	}
+	defer shutdownTracing(context.Background())
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Initialize database connection
+	if err := db.InitializeWith(ctx, cfg.DB()); err != nil {
+		log.Fatalf("Failed to initialize database: %v", err)
+	}
+	defer db.Close()
+
+	settings = cfg
+	models = cfg.LLMSettings()
+	models.Recorder = llm.NewDBRecorder(db.Pool())
+
+	switch os.Args[1] {
+	case "dedup":
+		err = runDedup(ctx, os.Args[2:])
+	case "triples":
+		err = runTriples(ctx, os.Args[2:])
+	case "summarize":
+		err = runSummarize(ctx, os.Args[2:])
+	case "bio":
+		err = runBio(ctx, os.Args[2:])
+	case "narrative":
+		err = runNarrative(ctx, os.Args[2:])
+	case "roles":
+		err = runRoles(ctx, os.Args[2:])
+	case "timeline":
+		err = runTimeline(ctx, os.Args[2:])
+	case "changes":
+		err = runChanges(ctx, os.Args[2:])
+	case "webhooks":
+		err = runWebhooks(ctx, os.Args[2:])
+	case "cdn":
+		err = runCDN(ctx, os.Args[2:])
+	case "digest":
+		err = runDigest(ctx, os.Args[2:])
+	case "embed":
+		err = runEmbed(ctx, os.Args[2:])
+	case "quality":
+		err = runQuality(ctx, os.Args[2:])
+	case "shells":
+		err = runShells(ctx, os.Args[2:])
+	case "anomalies":
+		err = runAnomalies(ctx, os.Args[2:])
+	case "distances":
+		err = runDistances(ctx, os.Args[2:])
+	case "pages":
+		err = runPages(ctx, os.Args[2:])
+	case "edges":
+		err = runEdges(ctx, os.Args[2:])
+	case "stoplist":
+		err = runStoplist(ctx, os.Args[2:])
+	case "duplicates":
+		err = runDuplicates(ctx, os.Args[2:])
+	case "dates":
+		err = runDates(ctx, os.Args[2:])
+	case "sensitivity":
+		err = runSensitivity(ctx, os.Args[2:])
+	case "thumbnails":
+		err = runThumbnails(ctx, os.Args[2:])
+	case "patterns":
+		err = runPatterns(ctx, os.Args[2:])
+	case "schedule":
+		err = runSchedule(ctx, os.Args[2:])
+	case "watch":
+		err = runWatch(ctx, os.Args[2:])
+	case "ocr":
+		err = runOCR(ctx, os.Args[2:])
+	case "export":
+		err = runExport(ctx, os.Args[2:])
+	case "report":
+		err = runReport(ctx, os.Args[2:])
+	case "blobs":
+		err = runBlobs(ctx, os.Args[2:])
+	default:
+		usage()
+		os.Exit(2)
+	}
+
+	if err != nil {
+		log.Fatalf("%s: %v", os.Args[1], err)
+	}
+}
+
+func runDedup(ctx context.Context, args []string) error {
+	cfg := dedup.DefaultConfig()
+
+	fs := flag.NewFlagSet("dedup", flag.ExitOnError)
+	fs.StringVar(&cfg.EntityType, "type", cfg.EntityType, "entity type to deduplicate")
+	fs.Float64Var(&cfg.MinSimilarity, "min-similarity", cfg.MinSimilarity, "minimum trigram similarity of candidate pairs")
+	fs.IntVar(&cfg.BatchSize, "batch", cfg.BatchSize, "number of pairs to review")
+	fs.IntVar(&cfg.MaxAttempts, "max-attempts", cfg.MaxAttempts, "failed judgements of a pair before it is skipped")
+	fs.DurationVar(&cfg.Backoff, "backoff", cfg.Backoff, "wait before retrying a failed pair, doubled after each failure")
+	fs.Parse(args)
+
+	client, err := llm.New(models, llm.TaskDedup)
+	if err != nil {
+		return err
+	}
+
+	stats, err := dedup.NewWorker(db.Pool(), client, cfg).Run(ctx)
+	log.Printf("dedup: %d candidates, %d same, %d different, %d uncertain, %d failed",
+		stats.Candidates, stats.Same, stats.Different, stats.Uncertain, stats.Failed)
+	return err
+}
+
+func runTriples(ctx context.Context, args []string) error {
+	cfg := triples.DefaultConfig()
+
+	fs := flag.NewFlagSet("triples", flag.ExitOnError)
+	fs.IntVar(&cfg.BatchSize, "batch", cfg.BatchSize, "number of documents to process")
+	fs.BoolVar(&cfg.UseRules, "rules", cfg.UseRules, "apply pattern rules")
+	fs.BoolVar(&cfg.UseLLM, "llm", cfg.UseLLM, "ask the LLM for additional triples")
+	fs.Parse(args)
+
+	var client llm.Client
+	if cfg.UseLLM {
+		var err error
+		if client, err = llm.New(models, llm.TaskTriples); err != nil {
+			return err
+		}
+	}
+
+	stats, err := triples.NewWorker(db.Pool(), client, cfg).Run(ctx)
+	log.Printf("triples: %d documents, %d triples, %d failed", stats.Documents, stats.Triples, stats.Failed)
+	return err
+}
+
+func runSummarize(ctx context.Context, args []string) error {
+	cfg := summarize.DefaultConfig()
+	var params summarize.Params
+	var queue bool
+
+	fs := flag.NewFlagSet("summarize", flag.ExitOnError)
+	fs.BoolVar(&queue, "queue", false, "process queued summarize jobs until interrupted")
+	fs.IntVar(&params.DatasetID, "dataset", 0, "only summarize this dataset (0 for all)")
+	fs.BoolVar(&params.Force, "force", false, "re-summarize documents that already have summaries")
+	fs.IntVar(&cfg.RequestsPerMinute, "rpm", cfg.RequestsPerMinute, "maximum LLM requests per minute")
+	fs.IntVar(&cfg.MaxTokens, "max-tokens", cfg.MaxTokens, "token budget per run (0 for unlimited)")
+	fs.Parse(args)
+
+	client, err := llm.New(models, llm.TaskSummarize)
+	if err != nil {
+		return err
+	}
+
+	worker := summarize.NewWorker(db.Pool(), client, cfg)
+	if queue {
+		return jobs.NewQueue(db.Pool()).Work(ctx, summarize.JobKind, 10*time.Second, worker.HandleJob)
+	}
+
+	result, err := worker.Run(ctx, params, nil)
+	log.Printf("summarize: %d summarized, %d failed, %d input tokens, %d output tokens",
+		result.Summarized, result.Failed, result.InputTokens, result.OutputTokens)
+	return err
+}
+
+func runRoles(ctx context.Context, args []string) error {
+	cfg := roles.DefaultConfig()
+	var params roles.Params
+	var queue bool
+
+	fs := flag.NewFlagSet("roles", flag.ExitOnError)
+	fs.BoolVar(&queue, "queue", false, "process queued role inference jobs until interrupted")
+	fs.IntVar(&params.DatasetID, "dataset", 0, "only classify this dataset (0 for all)")
+	fs.BoolVar(&params.Force, "force", false, "reclassify entities that already have a role")
+	fs.IntVar(&cfg.RequestsPerMinute, "rpm", cfg.RequestsPerMinute, "maximum LLM requests per minute")
+	fs.IntVar(&cfg.MaxTokens, "max-tokens", cfg.MaxTokens, "token budget per run (0 for unlimited)")
+	fs.Parse(args)
+
+	client, err := llm.New(models, llm.TaskRoles)
+	if err != nil {
+		return err
+	}
+
+	worker := roles.NewWorker(db.Pool(), client, cfg)
+	if queue {
+		return jobs.NewQueue(db.Pool()).Work(ctx, roles.JobKind, 10*time.Second, worker.HandleJob)
+	}
+
+	result, err := worker.Run(ctx, params, nil)
+	log.Printf("roles: %d documents, %d roles, %d without, %d failed, %d input tokens, %d output tokens",
+		result.Documents, result.Classified, result.Unclassified, result.Failed, result.InputTokens, result.OutputTokens)
+	return err
+}
+
+func runBio(ctx context.Context, args []string) error {
+	cfg := bio.DefaultConfig()
+	var entityID int
+	var queue bool
+
+	fs := flag.NewFlagSet("bio", flag.ExitOnError)
+	fs.BoolVar(&queue, "queue", false, "process queued bio jobs until interrupted")
+	fs.IntVar(&entityID, "entity", 0, "generate the biography of this entity")
+	fs.IntVar(&cfg.MaxDocuments, "documents", cfg.MaxDocuments, "documents to excerpt")
+	fs.Parse(args)
+
+	if !queue && entityID == 0 {
+		return errors.New("either -entity or -queue is required")
+	}
+
+	client, err := llm.New(models, llm.TaskBio)
+	if err != nil {
+		return err
+	}
+
+	worker := bio.NewWorker(db.Pool(), client, cfg)
+	if queue {
+		return jobs.NewQueue(db.Pool()).Work(ctx, bio.JobKind, 10*time.Second, worker.HandleJob)
+	}
+
+	b, err := worker.Generate(ctx, entityID)
+	if err != nil {
+		return err
+	}
+	fmt.Println(b.Text)
+	return nil
+}
+
+func runNarrative(ctx context.Context, args []string) error {
+	cfg := narrative.DefaultConfig()
+	var entityID int
+	var queue, stale bool
+
+	fs := flag.NewFlagSet("narrative", flag.ExitOnError)
+	fs.BoolVar(&queue, "queue", false, "process queued narrative jobs until interrupted")
+	fs.BoolVar(&stale, "stale", false, "regenerate every narrative outdated by new documents")
+	fs.IntVar(&entityID, "entity", 0, "generate the narrative of this entity")
+	fs.IntVar(&cfg.MaxMentions, "mentions", cfg.MaxMentions, "dated document mentions to include")
+	fs.Parse(args)
+
+	if !queue && !stale && entityID == 0 {
+		return errors.New("one of -entity, -stale or -queue is required")
+	}
+
+	client, err := llm.New(models, llm.TaskNarrative)
+	if err != nil {
+		return err
+	}
+
+	worker := narrative.NewWorker(db.Pool(), client, cfg)
+	switch {
+	case queue:
+		return jobs.NewQueue(db.Pool()).Work(ctx, narrative.JobKind, 10*time.Second, worker.HandleJob)
+	case stale:
+		n, err := worker.RefreshStale(ctx)
+		log.Printf("narrative: regenerated %d stale narratives", n)
+		return err
+	}
+
+	n, err := worker.Generate(ctx, entityID)
+	if err != nil {
+		return err
+	}
+	for _, s := range n.Sentences {
+		fmt.Printf("%s  %s [%d]\n", s.Date, s.Text, s.Citation.Marker)
+	}
+	return nil
+}
+
+func runTimeline(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("timeline", flag.ExitOnError)
+	fs.Parse(args)
+
+	r, err := timeline.Extract(ctx, db.Pool())
+	if err != nil {
+		return err
+	}
+	log.Printf("timeline: %d relationship events, %d record events added", r.Relationships, r.Records)
+	return nil
+}
+
+func runChanges(ctx context.Context, args []string) error {
+	var url, subject, name string
+	var interval time.Duration
+	var batch int
+
+	fs := flag.NewFlagSet("changes", flag.ExitOnError)
+	fs.StringVar(&url, "nats", os.Getenv("NATS_URL"), "NATS server URL")
+	fs.StringVar(&subject, "subject", "epstein.changes", "subject prefix; changes go to <prefix>.<table>.<op>")
+	fs.StringVar(&name, "name", "nats", "name to save this publisher's cursor under")
+	fs.DurationVar(&interval, "interval", 5*time.Second, "how often to look for new changes")
+	fs.IntVar(&batch, "batch", 500, "changes to publish at a time")
+	fs.Parse(args)
+
+	if url == "" {
+		return errors.New("-nats or NATS_URL is required")
+	}
+
+	pub, err := changes.NewNATS(url, subject)
+	if err != nil {
+		return err
+	}
+	defer pub.Close()
+
+	return changes.Follow(ctx, db.Pool(), name, pub, interval, batch)
+}
+
+func runWebhooks(ctx context.Context, args []string) error {
+	cfg := webhooks.DefaultConfig()
+	interval := 5 * time.Second
+
+	fs := flag.NewFlagSet("webhooks", flag.ExitOnError)
+	fs.DurationVar(&interval, "interval", interval, "how often to look for changes and due deliveries")
+	fs.IntVar(&cfg.MaxAttempts, "attempts", cfg.MaxAttempts, "attempts before a delivery is marked failed")
+	fs.DurationVar(&cfg.Backoff, "backoff", cfg.Backoff, "wait after the first failed attempt, doubled after each")
+	fs.DurationVar(&cfg.Timeout, "timeout", cfg.Timeout, "timeout of each request")
+	fs.Parse(args)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Turn changes into deliveries alongside sending them, stopping both if
+	// the change log can't be followed
+	dispatched := make(chan error, 1)
+	go func() {
+		dispatched <- changes.Follow(ctx, db.Pool(), "webhooks", webhooks.NewDispatcher(db.Pool()), interval, 500)
+		cancel()
+	}()
+
+	if err := webhooks.NewSender(db.Pool(), cfg).Run(ctx, interval); err != nil {
+		return err
+	}
+	return <-dispatched
+}
+
+func runCDN(ctx context.Context, args []string) error {
+	interval := 5 * time.Second
+
+	fs := flag.NewFlagSet("cdn", flag.ExitOnError)
+	fs.DurationVar(&interval, "interval", interval, "how often to look for changes")
+	fs.Parse(args)
+
+	purger, err := cdn.New(settings.CDN)
+	if err != nil {
+		return err
+	}
+	return changes.Follow(ctx, db.Pool(), "cdn", purger, interval, 500)
+}
+
+func runDigest(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("digest", flag.ExitOnError)
+	fs.Parse(args)
+
+	sender, err := mailer()
+	if err != nil {
+		return err
+	}
+	n, err := digest.SendDue(ctx, db.Pool(), sender, settings.PublicURL, time.Now())
+	if err != nil {
+		return err
+	}
+	log.Printf("digest: %d sent", n)
+	return nil
+}
+
+// mailer returns the configured email sender. Digests link back to the
+// API, so PUBLIC_URL is required too.
+func mailer() (email.Sender, error) {
+	if settings.PublicURL == "" {
+		return nil, errors.New("PUBLIC_URL is required for links in emails")
+	}
+	return email.New(settings.Email.URL, settings.Email.From)
+}
+
+func runEmbed(ctx context.Context, args []string) error {
+	cfg := embeddings.ConfigFromEnv()
+	var params embeddings.Params
+	var queue bool
+
+	fs := flag.NewFlagSet("embed", flag.ExitOnError)
+	fs.BoolVar(&queue, "queue", false, "process queued embed jobs until interrupted")
+	fs.IntVar(&params.DatasetID, "dataset", 0, "only embed this dataset (0 for all)")
+	fs.BoolVar(&params.Force, "force", false, "re-embed documents that aren't stale")
+	fs.IntVar(&cfg.ChunkSize, "chunk-size", cfg.ChunkSize, "chunk size in characters")
+	fs.IntVar(&cfg.Overlap, "overlap", cfg.Overlap, "overlap between chunks in characters")
+	fs.Parse(args)
+
+	provider, err := embeddings.NewProviderFromEnv()
+	if err != nil {
+		return err
+	}
+
+	worker := embeddings.NewWorker(db.Pool(), provider, cfg)
+	if queue {
+		return jobs.NewQueue(db.Pool()).Work(ctx, embeddings.JobKind, 10*time.Second, worker.HandleJob)
+	}
+
+	result, err := worker.Run(ctx, params, nil)
+	log.Printf("embed: %d documents, %d chunks, %d failed", result.Documents, result.Chunks, result.Failed)
+	return err
+}
+
+func runQuality(ctx context.Context, args []string) error {
+	var queue bool
+
+	fs := flag.NewFlagSet("quality", flag.ExitOnError)
+	fs.BoolVar(&queue, "queue", false, "process queued quality jobs until interrupted")
+	fs.Parse(args)
+
+	if queue {
+		return jobs.NewQueue(db.Pool()).Work(ctx, quality.JobKind, 10*time.Second,
+			func(ctx context.Context, job *jobs.Job) (any, error) {
+				report, err := quality.Run(ctx, db.Pool())
+				if err != nil {
+					return nil, err
+				}
+				return map[string]int{"reportId": report.ID, "failing": report.Failing}, nil
+			})
+	}
+
+	report, err := quality.Run(ctx, db.Pool())
+	if err != nil {
+		return err
+	}
+	for _, r := range report.Checks {
+		log.Printf("quality: %-28s %-8s %d", r.Name, r.Severity, r.Count)
+	}
+	return nil
+}
+
+func runShells(ctx context.Context, args []string) error {
+	var queue bool
+
+	fs := flag.NewFlagSet("shells", flag.ExitOnError)
+	fs.BoolVar(&queue, "queue", false, "process queued shell company analyses until interrupted")
+	fs.Parse(args)
+
+	if queue {
+		return jobs.NewQueue(db.Pool()).Work(ctx, shells.JobKind, 10*time.Second,
+			func(ctx context.Context, job *jobs.Job) (any, error) {
+				return shells.Run(ctx, db.Pool())
+			})
+	}
+
+	result, err := shells.Run(ctx, db.Pool())
+	if err != nil {
+		return err
+	}
+	log.Printf("shells: %d organizations scored, %d candidates", result.Organizations, result.Candidates)
+	return nil
+}
+
+func runAnomalies(ctx context.Context, args []string) error {
+	var queue bool
+
+	fs := flag.NewFlagSet("anomalies", flag.ExitOnError)
+	fs.BoolVar(&queue, "queue", false, "process queued anomaly detection runs until interrupted")
+	fs.Parse(args)
+
+	if queue {
+		return jobs.NewQueue(db.Pool()).Work(ctx, anomalies.JobKind, 10*time.Second,
+			func(ctx context.Context, job *jobs.Job) (any, error) {
+				return anomalies.Run(ctx, db.Pool())
+			})
+	}
+
+	result, err := anomalies.Run(ctx, db.Pool())
+	if err != nil {
+		return err
+	}
+	log.Printf("anomalies: %d anomalies, %d findings, %d removed", result.Anomalies, result.Findings, result.Removed)
+	return nil
+}
+
+func runPatterns(ctx context.Context, args []string) error {
+	var queue bool
+
+	fs := flag.NewFlagSet("patterns", flag.ExitOnError)
+	fs.BoolVar(&queue, "queue", false, "process queued pattern detection runs until interrupted")
+	fs.Parse(args)
+
+	if queue {
+		return jobs.NewQueue(db.Pool()).Work(ctx, patterns.JobKind, 10*time.Second,
+			func(ctx context.Context, job *jobs.Job) (any, error) {
+				return patterns.Run(ctx, db.Pool())
+			})
+	}
+
+	result, err := patterns.Run(ctx, db.Pool())
+	if err != nil {
+		return err
+	}
+	log.Printf("patterns: %d events, %d bursts, %d findings, %d removed",
+		result.Events, result.Bursts, result.Findings, result.Removed)
+	return nil
+}
+
+func runDistances(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("distances", flag.ExitOnError)
+	fs.Parse(args)
+
+	result, err := separation.Run(ctx, db.Pool(), settings.Network.CoreEntities)
+	if err != nil {
+		return err
+	}
+	log.Printf("distances: %d entities within reach of %d core entities (%v by distance), %d layers changed",
+		result.Reached, result.Core, result.ByDistance, result.LayersChanged)
+	return nil
+}
+
+func runPages(ctx context.Context, args []string) error {
+	var force bool
+
+	fs := flag.NewFlagSet("pages", flag.ExitOnError)
+	fs.BoolVar(&force, "force", false, "reindex every document with pages, not just changed ones")
+	fs.Parse(args)
+
+	result, err := pages.Run(ctx, db.Pool(), force)
+	if err != nil {
+		return err
+	}
+	log.Printf("pages: %d documents indexed, %d page mentions, in %dms",
+		result.Documents, result.Mentions, result.DurationMs)
+	return nil
+}
+
+func runDates(ctx context.Context, args []string) error {
+	var force bool
+
+	fs := flag.NewFlagSet("dates", flag.ExitOnError)
+	fs.BoolVar(&force, "force", false, "read every document, not just changed ones")
+	fs.Parse(args)
+
+	result, err := dates.Run(ctx, db.Pool(), force)
+	if err != nil {
+		return err
+	}
+	log.Printf("dates: %d documents read, %d dated from their text; %d mention a date, in %dms",
+		result.Documents, result.Filled, result.Dated, result.DurationMs)
+	return nil
+}
+
+func runSensitivity(ctx context.Context, args []string) error {
+	var force bool
+
+	fs := flag.NewFlagSet("sensitivity", flag.ExitOnError)
+	fs.BoolVar(&force, "force", false, "label every document, not just changed ones")
+	fs.Parse(args)
+
+	result, err := sensitivity.Run(ctx, db.Pool(), force)
+	if err != nil {
+		return err
+	}
+	log.Printf("sensitivity: %d documents read; %d labeled, in %dms",
+		result.Documents, result.Labeled, result.DurationMs)
+	return nil
+}
+
+func runDuplicates(ctx context.Context, args []string) error {
+	var force bool
+
+	fs := flag.NewFlagSet("duplicates", flag.ExitOnError)
+	fs.BoolVar(&force, "force", false, "fingerprint every document, not just changed ones")
+	fs.Parse(args)
+
+	result, err := duplicates.Run(ctx, db.Pool(), force)
+	if err != nil {
+		return err
+	}
+	log.Printf("duplicates: %d documents fingerprinted, %d in %d clusters, in %dms",
+		result.Fingerprinted, result.Documents, result.Clusters, result.DurationMs)
+	return nil
+}
+
+func runThumbnails(ctx context.Context, args []string) error {
+	var force bool
+
+	fs := flag.NewFlagSet("thumbnails", flag.ExitOnError)
+	fs.BoolVar(&force, "force", false, "render every document, not just changed ones")
+	fs.Parse(args)
+
+	result, err := thumbnails.Run(ctx, db.Pool(), settings.Media.Dir, force)
+	if err != nil {
+		return err
+	}
+	log.Printf("thumbnails: %d pages of %d documents rendered, %d skipped, in %dms",
+		result.Pages, result.Documents, result.Skipped, result.DurationMs)
+	return nil
+}
+
+func runEdges(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("edges", flag.ExitOnError)
+	fs.Parse(args)
+
+	result, err := strength.Run(ctx, db.Pool(), edgeWeights())
+	if err != nil {
+		return err
+	}
+	log.Printf("edges: %d scored, %d sharing a page, %d with triples, in %dms",
+		result.Edges, result.SamePage, result.Typed, result.DurationMs)
+	return nil
+}
+
+// edgeWeights reads the relationship strength weights from the settings
+func edgeWeights() strength.Weights {
+	return strength.Weights{
+		PageBoost:    settings.Network.PageBoost,
+		TripleWeight: settings.Network.TripleWeight,
+	}
+}
+
+func runStoplist(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("stoplist", flag.ExitOnError)
+	fs.Parse(args)
+
+	result, err := stoplist.Suggest(ctx, db.Pool())
+	if err != nil {
+		return err
+	}
+	log.Printf("stoplist: %d names suggested", result.Suggested)
+	return nil
+}
+
+func runSchedule(ctx context.Context, args []string) error {
+	statsInterval := 15 * time.Minute
+	timelineInterval := time.Hour
+	changesRetention := 30 * 24 * time.Hour
+	blobsInterval := 15 * time.Minute
+
+	fs := flag.NewFlagSet("schedule", flag.ExitOnError)
+	fs.DurationVar(&statsInterval, "stats-interval", statsInterval, "how often to refresh the /api/stats counts")
+	fs.DurationVar(&timelineInterval, "timeline-interval", timelineInterval, "how often to extract new timeline events")
+	fs.DurationVar(&changesRetention, "changes-retention", changesRetention, "how long to keep the change log")
+	fs.DurationVar(&blobsInterval, "blobs-interval", blobsInterval, "how often to copy new files to object storage")
+	fs.Parse(args)
+
+	s := scheduler.New()
+	s.Every("stats", statsInterval, store.New(db.Pool(), db.Pool()).RefreshStats)
+	s.Every("timeline", timelineInterval, func(ctx context.Context) error {
+		_, err := timeline.Extract(ctx, db.Pool())
+		return err
+	})
+	s.Daily("dates", 1, 45, func(ctx context.Context) error {
+		_, err := dates.Run(ctx, db.Pool(), false)
+		return err
+	})
+	s.Daily("sensitivity", 1, 50, func(ctx context.Context) error {
+		_, err := sensitivity.Run(ctx, db.Pool(), false)
+		return err
+	})
+	s.Daily("pages", 2, 0, func(ctx context.Context) error {
+		_, err := pages.Run(ctx, db.Pool(), false)
+		return err
+	})
+	s.Daily("edges", 2, 15, func(ctx context.Context) error {
+		_, err := strength.Run(ctx, db.Pool(), edgeWeights())
+		return err
+	})
+	s.Daily("distances", 2, 30, func(ctx context.Context) error {
+		_, err := separation.Run(ctx, db.Pool(), settings.Network.CoreEntities)
+		return err
+	})
+	s.Daily("quality", 3, 0, func(ctx context.Context) error {
+		_, err := quality.Run(ctx, db.Pool())
+		return err
+	})
+	s.Daily("anomalies", 3, 15, func(ctx context.Context) error {
+		_, err := anomalies.Run(ctx, db.Pool())
+		return err
+	})
+	s.Daily("shells", 3, 30, func(ctx context.Context) error {
+		_, err := shells.Run(ctx, db.Pool())
+		return err
+	})
+	s.Daily("patterns", 3, 45, func(ctx context.Context) error {
+		_, err := patterns.Run(ctx, db.Pool())
+		return err
+	})
+	s.Daily("stoplist", 3, 50, func(ctx context.Context) error {
+		_, err := stoplist.Suggest(ctx, db.Pool())
+		return err
+	})
+	s.Daily("duplicates", 3, 55, func(ctx context.Context) error {
+		_, err := duplicates.Run(ctx, db.Pool(), false)
+		return err
+	})
+	s.Daily("changes", 4, 0, func(ctx context.Context) error {
+		_, err := changes.Prune(ctx, db.Pool(), time.Now().Add(-changesRetention))
+		return err
+	})
+	s.Daily("thumbnails", 4, 15, func(ctx context.Context) error {
+		_, err := thumbnails.Run(ctx, db.Pool(), settings.Media.Dir, false)
+		return err
+	})
+	s.Daily("views", 4, 30, func(ctx context.Context) error {
+		_, err := trending.Prune(ctx, db.Pool(), time.Now())
+		return err
+	})
+	s.Daily("exports", 4, 45, func(ctx context.Context) error {
+		_, err := exports.Clean(settings.Exports.Dir, settings.Exports.Retention)
+		return err
+	})
+
+	// Files are copied to object storage once it's configured
+	files, err := openBlobs()
+	if err != nil {
+		return err
+	}
+	if files.Enabled() {
+		s.Every("blobs", blobsInterval, func(ctx context.Context) error {
+			_, err := files.Sync(ctx, settings.Media.Dir, settings.Exports.Dir)
+			return err
+		})
+	}
+
+	// Digests are sent once email is configured; weekly ones go out on the
+	// first run a week after the last
+	if settings.Email.URL != "" {
+		sender, err := mailer()
+		if err != nil {
+			return err
+		}
+		s.Daily("digest", 7, 0, func(ctx context.Context) error {
+			_, err := digest.SendDue(ctx, db.Pool(), sender, settings.PublicURL, time.Now())
+			return err
+		})
+	}
+
+	s.Run(ctx)
+	return nil
+}
+
+func runWatch(ctx context.Context, args []string) error {
+	cfg := watcher.ConfigFromEnv()
+	var once bool
+
+	fs := flag.NewFlagSet("watch", flag.ExitOnError)
+	fs.StringVar(&cfg.Dir, "dir", cfg.Dir, "directory of release .txt files")
+	fs.StringVar(&cfg.ManifestURL, "manifest", cfg.ManifestURL, "URL of a JSON release manifest")
+	fs.DurationVar(&cfg.Interval, "interval", cfg.Interval, "how often to look for new releases")
+	fs.BoolVar(&once, "once", false, "scan once and exit")
+	fs.Parse(args)
+
+	w := watcher.New(db.Pool(), cfg)
+	if once {
+		return w.Scan(ctx)
+	}
+	return w.Run(ctx)
+}
+
+func runOCR(ctx context.Context, args []string) error {
+	var in ocr.Input
+	var queue bool
+
+	fs := flag.NewFlagSet("ocr", flag.ExitOnError)
+	fs.BoolVar(&queue, "queue", false, "process uploaded scans until interrupted")
+	fs.StringVar(&in.DocID, "doc", "", "document ID to store the text under, e.g. EFTA00009001")
+	fs.IntVar(&in.DatasetID, "dataset", 0, "dataset the document belongs to")
+	fs.Parse(args)
+
+	processor := ocr.NewProcessor(db.Pool(), ocr.NewTesseractFromEnv())
+	if queue {
+		return jobs.NewQueue(db.Pool()).Work(ctx, ocr.JobKind, 10*time.Second, processor.HandleJob)
+	}
+
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: worker ocr -doc ID -dataset N FILE")
+	}
+	in.Path = fs.Arg(0)
+
+	_, err := processor.Process(ctx, in)
+	return err
+}
+
+func runExport(ctx context.Context, args []string) error {
+	p := exports.Params{Set: exports.SetDocuments, Format: exports.FormatNDJSON}
+	var queue bool
+
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	fs.BoolVar(&queue, "queue", false, "process queued exports until interrupted")
+	fs.StringVar(&p.Set, "set", p.Set, "documents, entities or triples")
+	fs.StringVar(&p.Format, "format", p.Format, "ndjson or csv")
+	fs.IntVar(&p.DatasetID, "dataset", 0, "only this dataset")
+	fs.StringVar(&p.EntityType, "type", "", "only this entity type")
+	fs.BoolVar(&p.IncludeText, "text", false, "include documents' full text")
+	fs.BoolVar(&p.ExcludeSensitive, "exclude-sensitive", false, "leave out documents with content warnings")
+	fs.Parse(args)
+
+	dir := settings.Exports.Dir
+	if queue {
+		// Exports are uploaded as soon as they're written, so that servers
+		// on other machines can hand them out
+		files, err := openBlobs()
+		if err != nil {
+			return err
+		}
+		q := jobs.NewQueue(db.Pool())
+		return q.Work(ctx, exports.JobKind, 10*time.Second,
+			func(ctx context.Context, job *jobs.Job) (any, error) {
+				var params exports.Params
+				if err := job.DecodeParams(&params); err != nil {
+					return nil, err
+				}
+				result, err := exports.Write(ctx, db.Pool(), dir, job.ID, params, func(done, total int64) error {
+					return q.SetProgress(ctx, job.ID, int(done), int(total))
+				})
+				if err != nil {
+					return nil, err
+				}
+				path, err := exports.Path(dir, result.File)
+				if err != nil {
+					return nil, err
+				}
+				if _, err := files.Upload(ctx, blobs.ExportKey(result.File), path); err != nil {
+					return nil, err
+				}
+				return result, nil
+			})
+	}
+
+	// Run directly, the file is named after the time rather than a job
+	result, err := exports.Write(ctx, db.Pool(), dir, time.Now().Unix(), p, nil)
+	if err != nil {
+		return err
+	}
+	log.Printf("export: wrote %d rows to %s (%d bytes, sha256 %s)", result.Rows, result.File, result.Size, result.SHA256)
+	return nil
+}
+
+func runReport(ctx context.Context, args []string) error {
+	var p reports.Params
+	var queue bool
+	var ids, sections, from, to string
+
+	fs := flag.NewFlagSet("report", flag.ExitOnError)
+	fs.BoolVar(&queue, "queue", false, "process queued reports until interrupted")
+	fs.StringVar(&ids, "entities", "", "comma-separated entity IDs")
+	fs.StringVar(&from, "from", "", "earliest date covered, YYYY-MM-DD")
+	fs.StringVar(&to, "to", "", "latest date covered, YYYY-MM-DD")
+	fs.StringVar(&sections, "sections", strings.Join(reports.Sections, ","), "comma-separated sections")
+	fs.StringVar(&p.Title, "title", "", "report title")
+	fs.Parse(args)
+
+	data := store.New(db.Pool(), db.Pool())
+	if queue {
+		q := jobs.NewQueue(db.Pool())
+		return q.Work(ctx, reports.JobKind, 10*time.Second,
+			func(ctx context.Context, job *jobs.Job) (any, error) {
+				var params reports.Params
+				if err := job.DecodeParams(&params); err != nil {
+					return nil, err
+				}
+				return reports.Build(ctx, data, params, func(done, total int) error {
+					return q.SetProgress(ctx, job.ID, done, total)
+				})
+			})
+	}
+
+	// Run directly, the report is written to stdout as Markdown
+	for _, s := range strings.Split(ids, ",") {
+		id, err := strconv.Atoi(strings.TrimSpace(s))
+		if err != nil {
+			return errors.New("-entities must list entity IDs, e.g. -entities 12,345")
+		}
+		p.EntityIDs = append(p.EntityIDs, id)
+	}
+	if from != "" {
+		p.From = &from
+	}
+	if to != "" {
+		p.To = &to
+	}
+	p.Sections = strings.Split(sections, ",")
+	report, err := reports.Build(ctx, data, p, nil)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(os.Stdout, report.Markdown(settings.PublicURL))
+	return err
+}
+
+// openBlobs returns the object storage configured by S3_ENDPOINT and
+// S3_BUCKET, which does nothing when they aren't set
+func openBlobs() (*blobs.Store, error) {
+	return blobs.Open(db.Pool(), settings.Storage, settings.Downloads.URLTTL)
+}
+
+func runBlobs(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("blobs", flag.ExitOnError)
+	fs.Parse(args)
+
+	files, err := openBlobs()
+	if err != nil {
+		return err
+	}
+	result, err := files.Sync(ctx, settings.Media.Dir, settings.Exports.Dir)
+	if err != nil {
+		return err
+	}
+	log.Printf("blobs: %d uploaded, %d current, %d failed, %d removed, in %dms",
+		result.Uploaded, result.Current, result.Failed, result.Removed, result.DurationMs)
+	return nil
+}
@@ -0,0 +1,125 @@
+// Package adminnet keeps maintenance routes off the public network. They
+// can be served on a listener of their own, bound to a private address and
+// optionally requiring TLS client certificates, and limited to clients from
+// an allowlist of networks.
+package adminnet
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"os"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/subculture-collective/epstein-db/api/internal/apierr"
+	"github.com/subculture-collective/epstein-db/api/internal/config"
+)
+
+// Listen opens the admin listener at cfg.Addr, over TLS when a certificate
+// is configured, and requiring a client certificate from ClientCA when that
+// is too. Connections it accepts are recognised by Guard.
+func Listen(cfg config.Admin) (net.Listener, error) {
+	var tlsConfig *tls.Config
+	if cfg.TLSCert != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.TLSCert, cfg.TLSKey)
+		if err != nil {
+			return nil, fmt.Errorf("admin certificate: %w", err)
+		}
+		tlsConfig = &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+		if cfg.ClientCA != "" {
+			pem, err := os.ReadFile(cfg.ClientCA)
+			if err != nil {
+				return nil, fmt.Errorf("admin client CA: %w", err)
+			}
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(pem) {
+				return nil, errors.New("admin client CA: no certificates found")
+			}
+			tlsConfig.ClientCAs = pool
+			tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+	}
+
+	ln, err := net.Listen("tcp", cfg.Addr)
+	if err != nil {
+		return nil, err
+	}
+	ln = listener{ln}
+	if tlsConfig != nil {
+		ln = tls.NewListener(ln, tlsConfig)
+	}
+	return ln, nil
+}
+
+// listener marks the connections it accepts as admin connections
+type listener struct {
+	net.Listener
+}
+
+func (l listener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return conn{c}, nil
+}
+
+type conn struct {
+	net.Conn
+}
+
+// Guard admits requests to admin routes. With an admin listener configured
+// it answers 404 to requests that came in on any other, as if the routes
+// didn't exist; with an allowlist it answers 403 to clients outside it.
+// Client addresses come from PROXY_HEADER when set, so the allowlist is only
+// as trustworthy as the proxy that sets it.
+func Guard(cfg config.Admin) fiber.Handler {
+	allowed := make([]netip.Prefix, 0, len(cfg.AllowIPs))
+	for _, s := range cfg.AllowIPs {
+		// config.Load has validated these
+		if p, err := netip.ParsePrefix(s); err == nil {
+			allowed = append(allowed, p.Masked())
+		} else if a, err := netip.ParseAddr(s); err == nil {
+			allowed = append(allowed, netip.PrefixFrom(a, a.BitLen()))
+		}
+	}
+	separate := cfg.Addr != ""
+
+	return func(c *fiber.Ctx) error {
+		if separate && !onAdminListener(c) {
+			return fiber.ErrNotFound
+		}
+		if len(allowed) > 0 && !allows(allowed, c.IP()) {
+			return apierr.Forbidden("admin access is not allowed from this address")
+		}
+		return c.Next()
+	}
+}
+
+// onAdminListener reports whether c came in on a listener from Listen
+func onAdminListener(c *fiber.Ctx) bool {
+	nc := c.Context().Conn()
+	if t, ok := nc.(*tls.Conn); ok {
+		nc = t.NetConn()
+	}
+	_, ok := nc.(conn)
+	return ok
+}
+
+func allows(allowed []netip.Prefix, ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, p := range allowed {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,448 @@
+// Package anomalies flags outlying contribution and loan amounts among the
+// cross-reference records matched to entities, and feeds the entities with
+// the most of them to the pattern queue as hypotheses.
+package anomalies
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/subculture-collective/epstein-db/api/internal/patterns"
+)
+
+// JobKind is the jobs.kind for on-demand anomaly detection runs
+const JobKind = "anomalies"
+
+// Detector is the pattern_type and discovered_by of the pattern findings
+// the run records
+const Detector = "amount_anomaly"
+
+// Kind is a kind of anomaly
+type Kind struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// Kind names
+const (
+	KindUnderThreshold = "under_threshold"
+	KindRepeatedAmount = "repeated_amount"
+	KindLoanPayroll    = "loan_payroll"
+)
+
+// Kinds are the anomalies looked for
+var Kinds = []Kind{
+	{KindUnderThreshold, "A contribution just under the $200 FEC itemization threshold, or a PPP loan just under $150,000 " +
+		"(simplified forgiveness) or $2 million (automatic review)"},
+	{KindRepeatedAmount, "Contributions of the same amount to the same committee on the same day from 3 or more contributors, " +
+		"at least one matched to an entity"},
+	{KindLoanPayroll, "A PPP loan over $20,833 per job reported, the most 2.5 months of payroll could be per employee"},
+}
+
+// KindNames lists the kinds by name
+func KindNames() []string {
+	names := make([]string, len(Kinds))
+	for i, k := range Kinds {
+		names[i] = k.Name
+	}
+	return names
+}
+
+// threshold is an amount records are kept under to avoid scrutiny
+type threshold struct {
+	source string
+	amount float64
+	what   string
+}
+
+var thresholds = []threshold{
+	{"fec", 200, "the FEC itemization threshold"},
+	{"ppp", 150000, "the PPP simplified forgiveness threshold"},
+	{"ppp", 2000000, "the PPP automatic review threshold"},
+}
+
+// underMargin is how far under a threshold, as a fraction of it, an amount
+// counts as just under
+const underMargin = 0.05
+
+// minContributors is the fewest contributors giving the same amount to the
+// same committee on the same day that count as repeated
+const minContributors = 3
+
+// maxLoanPerJob is the most a PPP loan could be per employee: 2.5 months of
+// the $100,000 yearly salary cap
+const maxLoanPerJob = 20833
+
+// MinConfidence is the lowest confidence an entity's anomalies are recorded
+// as a pattern finding with. Amounts just under a threshold score 0.6 at
+// most, so one alone is not enough.
+const MinConfidence = 0.75
+
+// Anomaly is an outlying amount
+type Anomaly struct {
+	ID          int       `json:"id"`
+	Kind        string    `json:"kind"`
+	Source      string    `json:"source" enum:"ppp,fec"`
+	RecordIDs   []int     `json:"recordIds" doc:"PPP loan or FEC contribution IDs"`
+	EntityIDs   []int     `json:"entityIds" doc:"Entities the records are matched to"`
+	Amount      float64   `json:"amount"`
+	Score       float64   `json:"score" doc:"0 to 1, how far out of the ordinary"`
+	Rationale   string    `json:"rationale"`
+	GeneratedAt time.Time `json:"generatedAt"`
+}
+
+// Result summarizes a run
+type Result struct {
+	Anomalies  int            `json:"anomalies"`
+	Kinds      map[string]int `json:"kinds" doc:"Anomalies of each kind"`
+	Findings   int            `json:"findings" doc:"Entities recorded as pattern findings"`
+	Removed    int            `json:"removed" doc:"Unreviewed findings of earlier runs no longer found"`
+	DurationMs int            `json:"durationMs"`
+}
+
+// underThresholdSQL finds matched records just under a threshold; the
+// table and amount column are filled in per source
+const underThresholdSQL = `
+	SELECT r.id, r.%[2]s::float8, array_agg(DISTINCT m.entity_id ORDER BY m.entity_id)
+	FROM %[1]s r
+	JOIN entity_crossref_matches m
+	  ON m.source = $3 AND m.source_id = r.id AND m.false_positive IS NOT TRUE
+	WHERE r.%[2]s >= $1 AND r.%[2]s < $2
+	GROUP BY r.id
+`
+
+// repeatedAmountSQL finds same-day contributions of one amount to one
+// committee by several contributors, at least one of them matched
+const repeatedAmountSQL = `
+	WITH keys AS (
+		SELECT DISTINCT f.committee_name, f.contribution_date, f.amount
+		FROM fec_contributions f
+		JOIN entity_crossref_matches m
+		  ON m.source = 'fec' AND m.source_id = f.id AND m.false_positive IS NOT TRUE
+		WHERE f.amount > 0 AND f.committee_name IS NOT NULL AND f.contribution_date IS NOT NULL
+	)
+	SELECT array_agg(DISTINCT f.id ORDER BY f.id), k.amount::float8, k.committee_name, k.contribution_date,
+		   COUNT(DISTINCT f.contributor_name),
+		   array_agg(DISTINCT m.entity_id ORDER BY m.entity_id) FILTER (WHERE m.entity_id IS NOT NULL)
+	FROM keys k
+	JOIN fec_contributions f
+	  ON f.committee_name = k.committee_name AND f.contribution_date = k.contribution_date AND f.amount = k.amount
+	LEFT JOIN entity_crossref_matches m
+	  ON m.source = 'fec' AND m.source_id = f.id AND m.false_positive IS NOT TRUE
+	GROUP BY k.committee_name, k.contribution_date, k.amount
+	HAVING COUNT(DISTINCT f.contributor_name) >= $1
+`
+
+// loanPayrollSQL finds matched PPP loans over the most payroll could
+// justify for the jobs reported, counting none reported as one
+const loanPayrollSQL = `
+	SELECT p.id, p.loan_amount::float8, COALESCE(p.jobs_retained, 0),
+		   array_agg(DISTINCT m.entity_id ORDER BY m.entity_id)
+	FROM ppp_loans p
+	JOIN entity_crossref_matches m
+	  ON m.source = 'ppp' AND m.source_id = p.id AND m.false_positive IS NOT TRUE
+	WHERE p.loan_amount > $1 * GREATEST(COALESCE(p.jobs_retained, 0), 1)
+	GROUP BY p.id
+`
+
+// Run looks for anomalies, replaces the stored ones, and records the
+// entities whose anomalies add up to MinConfidence as pattern findings
+func Run(ctx context.Context, pool *pgxpool.Pool) (*Result, error) {
+	started := time.Now()
+
+	var found []Anomaly
+	for _, find := range []func(context.Context, *pgxpool.Pool) ([]Anomaly, error){
+		underThreshold, repeatedAmounts, loanPayroll,
+	} {
+		a, err := find(ctx, pool)
+		if err != nil {
+			return nil, err
+		}
+		found = append(found, a...)
+	}
+
+	err := pgx.BeginFunc(ctx, pool, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, `DELETE FROM amount_anomalies`); err != nil {
+			return err
+		}
+		for _, a := range found {
+			_, err := tx.Exec(ctx, `
+				INSERT INTO amount_anomalies (kind, source, record_ids, entity_ids, amount, score, rationale)
+				VALUES ($1, $2, $3, $4, $5, $6, $7)
+			`, a.Kind, a.Source, a.RecordIDs, a.EntityIDs, a.Amount, a.Score, a.Rationale)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	findings, err := entityFindings(ctx, pool, found)
+	if err != nil {
+		return nil, err
+	}
+	removed, err := patterns.Record(ctx, pool, Detector, findings)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Result{
+		Anomalies:  len(found),
+		Kinds:      map[string]int{},
+		Findings:   len(findings),
+		Removed:    removed,
+		DurationMs: int(time.Since(started).Milliseconds()),
+	}
+	for _, a := range found {
+		result.Kinds[a.Kind]++
+	}
+	return result, nil
+}
+
+// underThreshold finds matched records just under a threshold. The closer
+// to it, the higher the score, from 0.3 to 0.6: many honest amounts are
+// round numbers just under one.
+func underThreshold(ctx context.Context, pool *pgxpool.Pool) ([]Anomaly, error) {
+	tables := map[string][2]string{
+		"fec": {"fec_contributions", "amount"},
+		"ppp": {"ppp_loans", "loan_amount"},
+	}
+
+	var found []Anomaly
+	for _, t := range thresholds {
+		table := tables[t.source]
+		margin := t.amount * underMargin
+		rows, err := pool.Query(ctx, fmt.Sprintf(underThresholdSQL, table[0], table[1]),
+			t.amount-margin, t.amount, t.source)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			a := Anomaly{Kind: KindUnderThreshold, Source: t.source, RecordIDs: make([]int, 1)}
+			if err := rows.Scan(&a.RecordIDs[0], &a.Amount, &a.EntityIDs); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			a.Score = 0.3 + 0.3*(1-(t.amount-a.Amount)/margin)
+			a.Rationale = fmt.Sprintf("$%s is $%s under %s of $%s",
+				dollars(a.Amount), dollars(t.amount-a.Amount), t.what, dollars(t.amount))
+			found = append(found, a)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return found, nil
+}
+
+// repeatedAmounts finds same-day contributions of one amount to one
+// committee by several contributors. The score rises with the number of
+// contributors: 0.5 for 3, 0.75 for 5, 0.9 for 11.
+func repeatedAmounts(ctx context.Context, pool *pgxpool.Pool) ([]Anomaly, error) {
+	rows, err := pool.Query(ctx, repeatedAmountSQL, minContributors)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var found []Anomaly
+	for rows.Next() {
+		a := Anomaly{Kind: KindRepeatedAmount, Source: "fec"}
+		var committee string
+		var date time.Time
+		var contributors int
+		if err := rows.Scan(&a.RecordIDs, &a.Amount, &committee, &date, &contributors, &a.EntityIDs); err != nil {
+			return nil, err
+		}
+		a.Score = 1 - 1/float64(contributors-1)
+		a.Rationale = fmt.Sprintf("%d contributors each gave $%s to %s on %s",
+			contributors, dollars(a.Amount), committee, date.Format(time.DateOnly))
+		found = append(found, a)
+	}
+	return found, rows.Err()
+}
+
+// loanPayroll finds matched PPP loans larger than payroll could justify.
+// The score rises with the loan per job: 0.5 at twice the most, 0.75 at
+// four times.
+func loanPayroll(ctx context.Context, pool *pgxpool.Pool) ([]Anomaly, error) {
+	rows, err := pool.Query(ctx, loanPayrollSQL, maxLoanPerJob)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var found []Anomaly
+	for rows.Next() {
+		a := Anomaly{Kind: KindLoanPayroll, Source: "ppp", RecordIDs: make([]int, 1)}
+		var jobs int
+		if err := rows.Scan(&a.RecordIDs[0], &a.Amount, &jobs, &a.EntityIDs); err != nil {
+			return nil, err
+		}
+		perJob := a.Amount / float64(max(jobs, 1))
+		a.Score = 1 - maxLoanPerJob/perJob
+		if jobs > 0 {
+			a.Rationale = fmt.Sprintf("$%s for %d jobs is $%s a job", dollars(a.Amount), jobs, dollars(perJob))
+		} else {
+			a.Rationale = fmt.Sprintf("$%s with no jobs reported", dollars(a.Amount))
+		}
+		found = append(found, a)
+	}
+	return found, rows.Err()
+}
+
+// entityFindings combines each entity's anomalies into a finding, as if
+// they were independent signs: confidence is 1 - Π(1 - score)
+func entityFindings(ctx context.Context, pool *pgxpool.Pool, found []Anomaly) ([]patterns.Finding, error) {
+	byEntity := map[int][]Anomaly{}
+	for _, a := range found {
+		for _, id := range a.EntityIDs {
+			byEntity[id] = append(byEntity[id], a)
+		}
+	}
+	confidence := map[int]float64{}
+	var ids []int
+	for id, anomalies := range byEntity {
+		p := 1.0
+		for _, a := range anomalies {
+			p *= 1 - a.Score
+		}
+		if 1-p >= MinConfidence {
+			confidence[id] = 1 - p
+			ids = append(ids, id)
+		}
+	}
+	slices.Sort(ids)
+
+	names, err := patterns.EntityNames(ctx, pool, ids)
+	if err != nil {
+		return nil, err
+	}
+	findings := make([]patterns.Finding, 0, len(ids))
+	for _, id := range ids {
+		anomalies := byEntity[id]
+		slices.SortStableFunc(anomalies, func(x, y Anomaly) int {
+			switch {
+			case x.Score > y.Score:
+				return -1
+			case x.Score < y.Score:
+				return 1
+			}
+			return 0
+		})
+		name := names[id]
+		if name == "" {
+			name = "#" + strconv.Itoa(id)
+		}
+
+		var kinds []string
+		for _, k := range Kinds {
+			n := 0
+			for _, a := range anomalies {
+				if a.Kind == k.Name {
+					n++
+				}
+			}
+			if n > 0 {
+				kinds = append(kinds, fmt.Sprintf("%d %s", n, strings.ReplaceAll(k.Name, "_", " ")))
+			}
+		}
+
+		findings = append(findings, patterns.Finding{
+			Key:   strconv.Itoa(id),
+			Title: "Unusual contribution and loan amounts: " + name,
+			Description: fmt.Sprintf("Records matched to %s show %d anomalous amounts (%s). Strongest: %s.",
+				name, len(anomalies), strings.Join(kinds, ", "), anomalies[0].Rationale),
+			EntityIDs:  []int{id},
+			Evidence:   Evidence{evidence(anomalies)},
+			Confidence: confidence[id],
+		})
+	}
+	return findings, nil
+}
+
+// Evidence is the evidence of an entity's anomalies finding
+type Evidence struct {
+	Anomalies []EvidenceAnomaly `json:"anomalies"`
+}
+
+// EvidenceAnomaly is one anomaly of a finding
+type EvidenceAnomaly struct {
+	Kind      string  `json:"kind"`
+	Source    string  `json:"source"`
+	RecordIDs []int   `json:"recordIds"`
+	Amount    float64 `json:"amount"`
+	Score     float64 `json:"score"`
+	Rationale string  `json:"rationale"`
+}
+
+// evidence keeps what a finding needs of each anomaly
+func evidence(anomalies []Anomaly) []EvidenceAnomaly {
+	e := make([]EvidenceAnomaly, len(anomalies))
+	for i, a := range anomalies {
+		e[i] = EvidenceAnomaly{a.Kind, a.Source, a.RecordIDs, a.Amount, a.Score, a.Rationale}
+	}
+	return e
+}
+
+// dollars formats an amount with thousands separators, and cents only when
+// it has them
+func dollars(amount float64) string {
+	s := strconv.FormatFloat(amount, 'f', 2, 64)
+	whole, cents, _ := strings.Cut(s, ".")
+	for i := len(whole) - 3; i > 0; i -= 3 {
+		whole = whole[:i] + "," + whole[i:]
+	}
+	if cents == "00" {
+		return whole
+	}
+	return whole + "." + cents
+}
+
+// Filter narrows List
+type Filter struct {
+	Kind     string
+	EntityID int
+	MinScore float64
+	Limit    int
+	Offset   int
+}
+
+// List returns the anomalies of the last run, highest scores first
+func List(ctx context.Context, pool *pgxpool.Pool, f Filter) ([]Anomaly, error) {
+	rows, err := pool.Query(ctx, `
+		SELECT id, kind, source::text, record_ids, entity_ids, amount::float8, score, rationale, generated_at
+		FROM amount_anomalies
+		WHERE ($1 = '' OR kind = $1)
+		  AND ($2 = 0 OR entity_ids @> ARRAY[$2::int])
+		  AND score >= $3
+		ORDER BY score DESC, id
+		LIMIT $4 OFFSET $5
+	`, f.Kind, f.EntityID, f.MinScore, f.Limit, f.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	anomalies := []Anomaly{}
+	for rows.Next() {
+		var a Anomaly
+		if err := rows.Scan(&a.ID, &a.Kind, &a.Source, &a.RecordIDs, &a.EntityIDs, &a.Amount,
+			&a.Score, &a.Rationale, &a.GeneratedAt); err != nil {
+			return nil, err
+		}
+		anomalies = append(anomalies, a)
+	}
+	return anomalies, rows.Err()
+}
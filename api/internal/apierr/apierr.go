@@ -0,0 +1,210 @@
+// Package apierr defines the error body every HTTP endpoint returns and
+// maps internal errors, including database errors, onto it
+package apierr
+
+import (
+	"context"
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/subculture-collective/epstein-db/api/internal/logging"
+)
+
+// Error codes
+const (
+	CodeBadRequest   = "bad_request"
+	CodeInvalidParam = "invalid_parameter"
+	CodeUnauthorized = "unauthorized"
+	CodeForbidden    = "forbidden"
+	CodeNotFound     = "not_found"
+	CodeConflict     = "conflict"
+	CodeGone         = "gone"
+	CodeUnsupported  = "unsupported_media_type"
+	CodeTooLarge     = "payload_too_large"
+	CodeRateLimited  = "rate_limited"
+	CodeTooExpensive = "too_expensive"
+	CodeTimeout      = "timeout"
+	CodeUnavailable  = "unavailable"
+	CodeInternal     = "internal"
+)
+
+// Error is an error with an HTTP status and a message safe to show clients
+type Error struct {
+	Status    int    `json:"-"`
+	Code      string `json:"code" enum:"bad_request,invalid_parameter,unauthorized,forbidden,not_found,conflict,gone,unsupported_media_type,rate_limited,too_expensive,timeout,unavailable,internal"`
+	Message   string `json:"message"`
+	Details   any    `json:"details,omitempty" doc:"Extra context, e.g. the offending parameter"`
+	RequestID string `json:"requestId,omitempty"`
+
+	// cause is logged but never sent
+	cause error
+}
+
+func (e *Error) Error() string {
+	if e.cause != nil {
+		return e.Message + ": " + e.cause.Error()
+	}
+	return e.Message
+}
+
+func (e *Error) UnWrap() error {
+	return e.cause
+}
+
+// ErrorResponse is the body of every error response
+type ErrorResponse struct {
+	Error *Error `json:"error"`
+}
+
+// New creates an error with the given status, code and message
+func New(status int, code, message string) *Error {
+	return &Error{Status: status, Code: code, Message: message}
+}
+
+// BadRequest is a malformed request body or form
+func BadRequest(message string) *Error {
+	return New(fiber.StatusBadRequest, CodeBadRequest, message)
+}
+
+// InvalidParam is a query or path parameter that failed validation
+func InvalidParam(param, message string) *Error {
+	e := New(fiber.StatusBadRequest, CodeInvalidParam, param+" "+message)
+	e.Details = fiber.Map{"param": param}
+	return e
+}
+
+// NotFound is a missing resource, e.g. NotFound("document")
+func NotFound(what string) *Error {
+	return New(fiber.StatusNotFound, CodeNotFound, what+" not found")
+}
+
+// Gone is a resource that existed but has been removed for good, with a
+// hint on what to do instead
+func Gone(message, hint string) *Error {
+	e := New(fiber.StatusGone, CodeGone, message)
+	e.Details = fiber.Map{"hint": hint}
+	return e
+}
+
+// TooExpensive rejects a request whose query would cost too much to run,
+// with a hint on how to narrow it
+func TooExpensive(message, hint string) *Error {
+	e := New(fiber.StatusUnprocessableEntity, CodeTooExpensive, message)
+	e.Details = fiber.Map{"hint": hint}
+	return e
+}
+
+// Unauthorized is a missing or invalid credential
+func Unauthorized(message string) *Error {
+	return New(fiber.StatusUnauthorized, CodeUnauthorized, message)
+}
+
+// Forbidden is a valid credential without the needed access
+func Forbidden(message string) *Error {
+	return New(fiber.StatusForbidden, CodeForbidden, message)
+}
+
+// Internal hides err from the client behind a generic message
+func Internal(err error) *Error {
+	e := New(fiber.StatusInternalServerError, CodeInternal, "internal error")
+	e.cause = err
+	return e
+}
+
+// From converts any error into an *Error. Database errors become the
+// closest 4xx or 5xx status without exposing SQL to the client.
+func From(err error) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+
+	var fe *fiber.Error
+	if errors.As(err, &fe) {
+		return New(fe.Code, codeForStatus(fe.Code), fe.Message)
+	}
+
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		return NotFound("resource")
+	case errors.Is(err, context.DeadlineExceeded):
+		return Wrap(New(fiber.StatusGatewayTimeout, CodeTimeout, "request timed out"), err)
+	case errors.Is(err, context.Canceled):
+		// The client has gone; the status is only for logs
+		return Wrap(New(499, CodeTimeout, "request cancelled"), err)
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return fromPg(pgErr)
+	}
+
+	return Internal(err)
+}
+
+// fromPg maps a Postgres error by SQLSTATE
+// (https://www.postgresql.org/docs/current/errcodes-appendix.html)
+func fromPg(err *pgconn.PgError) *Error {
+	switch {
+	case err.Code == "57014": // query_canceled, including statement_timeout
+		return Wrap(New(fiber.StatusGatewayTimeout, CodeTimeout, "query timed out"), err)
+	case err.Code == "23505", err.Code == "23503": // unique, foreign key
+		return Wrap(New(fiber.StatusConflict, CodeConflict, "conflicts with existing data"), err)
+	case err.Code[:2] == "22", err.Code[:2] == "23": // data exception, other constraints
+		return Wrap(New(fiber.StatusBadRequest, CodeInvalidParam, "invalid parameter value"), err)
+	case err.Code[:2] == "53", err.Code[:2] == "40", err.Code == "57P03": // resources, rollbacks, starting up
+		return Wrap(New(fiber.StatusServiceUnavailable, CodeUnavailable, "database busy, try again"), err)
+	}
+	return Internal(err)
+}
+
+// Wrap gives e a cause, which is logged and can be matched with errors.Is
+// but is never sent
+func Wrap(e *Error, cause error) *Error {
+	e.cause = cause
+	return e
+}
+
+func codeForStatus(status int) string {
+	switch status {
+	case fiber.StatusBadRequest:
+		return CodeBadRequest
+	case fiber.StatusUnauthorized:
+		return CodeUnauthorized
+	case fiber.StatusForbidden:
+		return CodeForbidden
+	case fiber.StatusNotFound, fiber.StatusMethodNotAllowed:
+		return CodeNotFound
+	case fiber.StatusConflict:
+		return CodeConflict
+	case fiber.StatusGone:
+		return CodeGone
+	case fiber.StatusUnsupportedMediaType:
+		return CodeUnsupported
+	case fiber.StatusRequestEntityTooLarge:
+		return CodeTooLarge
+	case fiber.StatusTooManyRequests:
+		return CodeRateLimited
+	case fiber.StatusRequestTimeout, fiber.StatusGatewayTimeout:
+		return CodeTimeout
+	case fiber.StatusServiceUnavailable:
+		return CodeUnavailable
+	}
+	if status < 500 {
+		return CodeBadRequest
+	}
+	return CodeInternal
+}
+
+// Handler is the app's fiber.ErrorHandler. It renders err as an
+// ErrorResponse tagged with the request ID; the request log line carries the
+// full error, cause included.
+func Handler(c *fiber.Ctx, err error) error {
+	e := From(err)
+	body := *e
+	body.RequestID = logging.FromContext(c)
+	return c.Status(e.Status).JSON(ErrorResponse{Error: &body})
+}
@@ -0,0 +1,181 @@
+// Package audit records admin actions taken through the API
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/subculture-collective/epstein-db/api/internal/auth"
+	"github.com/subculture-collective/epstein-db/api/internal/logging"
+)
+
+// maxParamsSize caps how much of a request body is stored
+const maxParamsSize = 64 << 10
+
+// writeTimeout bounds the insert made after each action
+const writeTimeout = 5 * time.Second
+
+const (
+	affectedKey = "audit.affected"
+	jobKey      = "audit.job"
+)
+
+// Entry is one recorded action
+type Entry struct {
+	ID           int64           `json:"id"`
+	Actor        string          `json:"actor"`
+	KeyID        *int            `json:"keyId"`
+	Action       string          `json:"action"`
+	Params       json.RawMessage `json:"params"`
+	Status       int             `json:"status"`
+	AffectedRows *int64          `json:"affectedRows"`
+	JobID        *int64          `json:"jobId"`
+	RequestID    *string         `json:"requestId"`
+	CreatedAt    time.Time       `json:"createdAt"`
+}
+
+// SetAffected records how many rows the current action changed
+func SetAffected(c *fiber.Ctx, n int64) {
+	c.Locals(affectedKey, n)
+}
+
+// SetJob records the job the current action queued
+func SetJob(c *fiber.Ctx, id int64) {
+	c.Locals(jobKey, id)
+}
+
+// Middleware records every request other than GET and HEAD once it has
+// been handled, failures included. Mount it after the admin check so that
+// only authorized actions are logged.
+func Middleware(pool *pgxpool.Pool) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if c.Method() == fiber.MethodGet || c.Method() == fiber.MethodHead {
+			return c.Next()
+		}
+
+		// Read the parameters first; handlers may consume a multipart body
+		params := requestParams(c)
+
+		if err := c.Next(); err != nil {
+			if err := c.App().ErrorHandler(c, err); err != nil {
+				return err
+			}
+		}
+
+		p := auth.FromContext(c)
+		var keyID *int
+		if p.KeyID != 0 {
+			keyID = &p.KeyID
+		}
+		var requestID *string
+		if id := logging.FromContext(c); id != "" {
+			requestID = &id
+		}
+		affected, _ := c.Locals(affectedKey).(int64)
+		job, _ := c.Locals(jobKey).(int64)
+
+		// The request may already be cancelled; the record should still land
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(c.UserContext()), writeTimeout)
+		defer cancel()
+
+		_, err := pool.Exec(ctx, `
+			INSERT INTO audit_log (actor, key_id, action, params, status, affected_rows, job_id, request_id)
+			VALUES ($1, $2, $3, $4, $5, NULLIF($6, 0), NULLIF($7, 0), $8)
+		`, p.Name, keyID, c.Method()+" "+c.Route().Path, params, c.Response().StatusCode(), affected, job, requestID)
+		if err != nil {
+			slog.ErrorContext(ctx, "audit log write failed", "error", err, "action", c.Route().Path)
+		}
+		return nil
+	}
+}
+
+// requestParams captures the query string and body as JSON. Uploaded files
+// are recorded by name only.
+func requestParams(c *fiber.Ctx) json.RawMessage {
+	params := map[string]any{}
+
+	if query := c.Queries(); len(query) > 0 {
+		params["query"] = query
+	}
+
+	// A multipart body is parsed straight from the stream, spooling files
+	// to disk; reading it whole first would hold uploads in memory
+	if strings.HasPrefix(c.Get(fiber.HeaderContentType), fiber.MIMEMultipartForm) {
+		if form, err := c.MultipartForm(); err == nil {
+			fields := map[string]any{}
+			for k, v := range form.Value {
+				fields[k] = strings.Join(v, ",")
+			}
+			for k, files := range form.File {
+				var names []string
+				for _, f := range files {
+					names = append(names, f.Filename)
+				}
+				fields[k] = names
+			}
+			params["form"] = fields
+		}
+		return marshalParams(params)
+	}
+
+	body := c.Body()
+	switch {
+	case len(body) > maxParamsSize:
+		params["body"] = map[string]any{"truncated": true, "size": len(body)}
+	case json.Valid(body):
+		params["body"] = json.RawMessage(body)
+	case len(body) > 0:
+		params["body"] = string(body)
+	}
+	return marshalParams(params)
+}
+
+func marshalParams(params map[string]any) json.RawMessage {
+	raw, err := json.Marshal(params)
+	if err != nil {
+		return json.RawMessage(`{}`)
+	}
+	return raw
+}
+
+// Filter selects entries to list
+type Filter struct {
+	Action string // substring of the action, e.g. "recount"
+	KeyID  int
+	Limit  int
+	Offset int
+}
+
+// List returns recorded actions, newest first
+func List(ctx context.Context, pool *pgxpool.Pool, f Filter) ([]Entry, error) {
+	rows, err := pool.Query(ctx, `
+		SELECT id, actor, key_id, action, params, status, affected_rows, job_id, request_id, created_at
+		FROM audit_log
+		WHERE ($1 = '' OR action ILIKE '%' || $1 || '%')
+		  AND ($2 = 0 OR key_id = $2)
+		ORDER BY created_at DESC, id DESC
+		LIMIT $3 OFFSET $4
+	`, f.Action, f.KeyID, f.Limit, f.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []Entry
+	for rows.Next() {
+		var e Entry
+		if err := rows.Scan(&e.ID, &e.Actor, &e.KeyID, &e.Action, &e.Params, &e.Status,
+			&e.AffectedRows, &e.JobID, &e.RequestID, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+
+	return entries, rows.Err()
+}
@@ -0,0 +1,274 @@
+// Package auth authenticates API keys and enforces role requirements
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/subculture-collective/epstein-db/api/internal/apierr"
+)
+
+// Role is an access level. Each role includes the permissions of the
+// roles below it.
+type Role int
+
+const (
+	// RolePublic is anonymous, read-only access
+	RolePublic Role = iota
+	// RoleResearcher may also write annotations and flags
+	RoleResearcher
+	// RoleAdmin may also merge entities and run jobs
+	RoleAdmin
+)
+
+var roleNames = map[Role]string{
+	RolePublic:     "public",
+	RoleResearcher: "researcher",
+	RoleAdmin:      "admin",
+}
+
+func (r Role) String() string {
+	return roleNames[r]
+}
+
+// ParseRole converts a role name to a Role
+func ParseRole(name string) (Role, error) {
+	for role, n := range roleNames {
+		if n == name {
+			return role, nil
+		}
+	}
+	return RolePublic, fmt.Errorf("unknown role %q", name)
+}
+
+// Principal is the caller of a request
+type Principal struct {
+	KeyID int    // 0 for anonymous callers
+	Name  string // key name
+	Role  Role
+}
+
+// Anonymous is the principal of requests without a key
+var Anonymous = Principal{Role: RolePublic}
+
+// keyPrefix marks keys issued by this API so they're easy to spot in
+// configs and secret scanners
+const keyPrefix = "edb_"
+
+// cacheTTL bounds how long a revoked key keeps working on a running server
+const cacheTTL = time.Minute
+
+const localsKey = "auth.principal"
+
+// ErrInvalidKey is returned for unknown or revoked keys
+var ErrInvalidKey = errors.New("invalid API key")
+
+// HashKey returns the stored form of a key
+func HashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+type cacheEntry struct {
+	principal Principal
+	expires   time.Time
+}
+
+// Authenticator resolves API keys to principals
+type Authenticator struct {
+	pool     *pgxpool.Pool
+	readOnly bool
+
+	mu    sync.Mutex
+	cache map[string]cacheEntry
+}
+
+// New creates an authenticator backed by the api_keys table
+func New(pool *pgxpool.Pool) *Authenticator {
+	return &Authenticator{pool: pool, cache: make(map[string]cacheEntry)}
+}
+
+// NewReadOnly creates an authenticator that doesn't record when keys were
+// last used, for servers whose database is a read-only replica
+func NewReadOnly(pool *pgxpool.Pool) *Authenticator {
+	a := New(pool)
+	a.readOnly = true
+	return a
+}
+
+// Authenticate looks up a key, caching the result briefly so that every
+// request doesn't hit the database
+func (a *Authenticator) Authenticate(ctx context.Context, key string) (Principal, error) {
+	hash := HashKey(key)
+
+	a.mu.Lock()
+	entry, ok := a.cache[hash]
+	a.mu.Unlock()
+	if ok && time.Now().Before(entry.expires) {
+		return entry.principal, nil
+	}
+
+	query := `
+		UPDATE api_keys SET last_used_at = NOW()
+		WHERE key_hash = $1 AND revoked_at IS NULL
+		RETURNING id, name, role
+	`
+	if a.readOnly {
+		query = `SELECT id, name, role FROM api_keys WHERE key_hash = $1 AND revoked_at IS NULL`
+	}
+
+	var p Principal
+	var role string
+	err := a.pool.QueryRow(ctx, query, hash).Scan(&p.KeyID, &p.Name, &role)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Anonymous, ErrInvalidKey
+	}
+	if err != nil {
+		return Anonymous, err
+	}
+	if p.Role, err = ParseRole(role); err != nil {
+		return Anonymous, err
+	}
+
+	a.mu.Lock()
+	a.cache[hash] = cacheEntry{principal: p, expires: time.Now().Add(cacheTTL)}
+	a.mu.Unlock()
+
+	return p, nil
+}
+
+// keyFromRequest reads a key from X-API-Key or an Authorization bearer token
+func keyFromRequest(c *fiber.Ctx) string {
+	if key := c.Get("X-API-Key"); key != "" {
+		return key
+	}
+	if bearer, ok := strings.CutPrefix(c.Get(fiber.HeaderAuthorization), "Bearer "); ok {
+		return strings.TrimSpace(bearer)
+	}
+	return ""
+}
+
+// Middleware identifies the caller of every request. Requests without a key
+// continue as Anonymous; requests with a bad key are rejected.
+func (a *Authenticator) Middleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		key := keyFromRequest(c)
+		if key == "" {
+			c.Locals(localsKey, Anonymous)
+			return c.Next()
+		}
+
+		p, err := a.Authenticate(c.Context(), key)
+		if errors.Is(err, ErrInvalidKey) {
+			return apierr.Wrap(apierr.Unauthorized("invalid API key"), ErrInvalidKey)
+		}
+		if err != nil {
+			return err
+		}
+
+		c.Locals(localsKey, p)
+		return c.Next()
+	}
+}
+
+// Require rejects requests whose caller has less than role
+func Require(role Role) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		p := FromContext(c)
+		if p.Role >= role {
+			return c.Next()
+		}
+		if p.KeyID == 0 {
+			return apierr.Unauthorized("API key required")
+		}
+		return apierr.Forbidden(role.String() + " role required")
+	}
+}
+
+// FromContext returns the caller of a request
+func FromContext(c *fiber.Ctx) Principal {
+	if p, ok := c.Locals(localsKey).(Principal); ok {
+		return p
+	}
+	return Anonymous
+}
+
+// Key is an issued API key as listed by admins
+type Key struct {
+	ID         int        `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Role       string     `json:"role"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	LastUsedAt *time.Time `json:"lastUsedAt"`
+	RevokedAt  *time.Time `json:"revokedAt"`
+}
+
+// Create issues a new key and returns it. The key can't be recovered later.
+func Create(ctx context.Context, pool *pgxpool.Pool, name string, role Role) (int, string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return 0, "", err
+	}
+	key := keyPrefix + base64.RawURLEncoding.EncodeToString(buf)
+
+	var id int
+	err := pool.QueryRow(ctx, `
+		INSERT INTO api_keys (name, key_hash, key_prefix, role)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id
+	`, name, HashKey(key), key[:len(keyPrefix)+6], role.String()).Scan(&id)
+	if err != nil {
+		return 0, "", err
+	}
+	return id, key, nil
+}
+
+// List returns every issued key
+func List(ctx context.Context, pool *pgxpool.Pool) ([]Key, error) {
+	rows, err := pool.Query(ctx, `
+		SELECT id, name, key_prefix, role, created_at, last_used_at, revoked_at
+		FROM api_keys
+		ORDER BY id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []Key
+	for rows.Next() {
+		var k Key
+		if err := rows.Scan(&k.ID, &k.Name, &k.Prefix, &k.Role, &k.CreatedAt, &k.LastUsedAt, &k.RevokedAt); err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+	return keys, rows.Err()
+}
+
+// Revoke disables a key. Running servers stop accepting it within cacheTTL.
+func Revoke(ctx context.Context, pool *pgxpool.Pool, id int) error {
+	tag, err := pool.Exec(ctx, `
+		UPDATE api_keys SET revoked_at = NOW() WHERE id = $1 AND revoked_at IS NULL
+	`, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("no active key with id %d", id)
+	}
+	return nil
+}
@@ -0,0 +1,359 @@
+// Package bio writes sourced entity biographies. The model sees only
+// excerpts of documents that mention the entity and its human-verified
+// cross-reference matches, and every citation it gives is checked against
+// those sources before the biography is stored.
+package bio
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/subculture-collective/epstein-db/api/internal/fold"
+	"github.com/subculture-collective/epstein-db/api/internal/jobs"
+	"github.com/subculture-collective/epstein-db/api/internal/llm"
+)
+
+// JobKind is the jobs.kind for biography jobs
+const JobKind = "bio"
+
+// Notice accompanies every biography served
+const Notice = "Generated by a language model from the documents and verified cross-references linked to this entity. Verify each statement against its cited source before relying on it."
+
+const systemPrompt = `You write short factual biographies of people and organizations that appear in documents related to the Jeffrey Epstein case.
+
+Use only the numbered sources you are given, never outside knowledge. After each sentence, cite the source it comes from as [n], and quote the exact supporting passage for every source you cite. Report what the sources say without speculating about guilt or motive. If the sources say little, write little.`
+
+// ErrNoSources is returned when an entity has nothing to write from
+var ErrNoSources = errors.New("bio: entity has no documents or verified cross-references")
+
+// Params are the options accepted by a biography job
+type Params struct {
+	EntityID int `json:"entityId"`
+}
+
+// Config bounds the material given to the model
+type Config struct {
+	MaxDocuments   int // documents to excerpt, most mentions first
+	ExcerptsPerDoc int
+	ExcerptRadius  int // characters either side of a mention
+	MaxCrossrefs   int
+	MaxTokens      int
+}
+
+// DefaultConfig returns the settings used by the worker
+func DefaultConfig() Config {
+	return Config{
+		MaxDocuments:   20,
+		ExcerptsPerDoc: 2,
+		ExcerptRadius:  600,
+		MaxCrossrefs:   20,
+		MaxTokens:      2000,
+	}
+}
+
+// Citation ties a marker in the biography to the passage it rests on. Document
+// citations carry offsets into the document's full text; cross-reference
+// citations name the matched record.
+type Citation struct {
+	Marker     int    `json:"marker" doc:"The [n] used in the biography"`
+	Quote      string `json:"quote"`
+	DocumentID int    `json:"documentId,omitempty"`
+	DocID      string `json:"docId,omitempty"`
+	Start      int    `json:"start,omitempty"`
+	End        int    `json:"end,omitempty"`
+	Crossref   string `json:"crossref,omitempty" enum:"ppp,fec,grants"`
+	CrossrefID int    `json:"crossrefId,omitempty"`
+}
+
+// Bio is a stored biography
+type Bio struct {
+	EntityID    int        `json:"entityId"`
+	Text        string     `json:"bio" doc:"Biography with [n] citation markers"`
+	Citations   []Citation `json:"citations"`
+	Sources     int        `json:"sources" doc:"Number of sources the model was given"`
+	Model       string     `json:"model"`
+	GeneratedAt time.Time  `json:"generatedAt"`
+}
+
+// source is one excerpt or cross-reference record shown to the model
+type source struct {
+	text       string
+	documentID int
+	docID      string
+	start      int // offset of text in the document's full text
+	crossref   string
+	crossrefID int
+}
+
+type reply struct {
+	Bio       string `json:"bio"`
+	Citations []struct {
+		Source int    `json:"source"`
+		Quote  string `json:"quote"`
+	} `json:"citations"`
+}
+
+// Worker generates biographies
+type Worker struct {
+	pool   *pgxpool.Pool
+	queue  *jobs.Queue
+	client llm.Client
+	cfg    Config
+}
+
+// NewWorker creates a biography worker
+func NewWorker(pool *pgxpool.Pool, client llm.Client, cfg Config) *Worker {
+	return &Worker{
+		pool:   pool,
+		queue:  jobs.NewQueue(pool),
+		client: client,
+		cfg:    cfg,
+	}
+}
+
+// HandleJob processes a queued biography job
+func (w *Worker) HandleJob(ctx context.Context, job *jobs.Job) (any, error) {
+	var params Params
+	if err := job.DecodeParams(&params); err != nil {
+		return nil, err
+	}
+
+	bio, err := w.Generate(ctx, params.EntityID)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]int{"entityId": bio.EntityID, "citations": len(bio.Citations)}, nil
+}
+
+// Generate writes and stores the biography of an entity, replacing any
+// earlier one
+func (w *Worker) Generate(ctx context.Context, entityID int) (*Bio, error) {
+	var name, entityType string
+	var aliases []string
+	err := w.pool.QueryRow(ctx, `
+		SELECT canonical_name, entity_type::text,
+			   COALESCE((SELECT array_agg(a) FROM jsonb_array_elements_text(aliases) a), '{}')
+		FROM entities WHERE id = $1
+	`, entityID).Scan(&name, &entityType, &aliases)
+	if err != nil {
+		return nil, err
+	}
+
+	sources, err := w.excerpts(ctx, entityID, append([]string{name}, aliases...))
+	if err != nil {
+		return nil, err
+	}
+	crossrefs, err := w.crossrefs(ctx, entityID)
+	if err != nil {
+		return nil, err
+	}
+	sources = append(sources, crossrefs...)
+	if len(sources) == 0 {
+		return nil, ErrNoSources
+	}
+
+	var prompt strings.Builder
+	fmt.Fprintf(&prompt, "Write a biography of %s (%s).\n\nSources:\n\n", name, entityType)
+	for i, s := range sources {
+		label := "document " + s.docID
+		if s.crossref != "" {
+			label = s.crossref + " record"
+		}
+		fmt.Fprintf(&prompt, "<source n=\"%d\" from=\"%s\">\n%s\n</source>\n\n", i+1, label, s.text)
+	}
+	prompt.WriteString(`Respond with a JSON object:
+{
+  "bio": "One to three paragraphs, with [n] after each sentence",
+  "citations": [{"source": n, "quote": "exact passage from source n"}]
+}
+
+Return ONLY valid JSON.`)
+
+	resp, err := w.client.Complete(ctx, llm.UserPrompt(systemPrompt, prompt.String(), w.cfg.MaxTokens))
+	if err != nil {
+		return nil, err
+	}
+
+	var r reply
+	if err := llm.DecodeJSON(resp.Text, &r); err != nil {
+		return nil, err
+	}
+	bio := &Bio{
+		EntityID:  entityID,
+		Text:      strings.TrimSpace(r.Bio),
+		Citations: cite(sources, r),
+		Sources:   len(sources),
+		Model:     resp.Model,
+	}
+	if bio.Text == "" {
+		return nil, errors.New("bio: empty biography")
+	}
+	if len(bio.Citations) == 0 {
+		return nil, errors.New("bio: no citation matched its source")
+	}
+
+	jobID, _ := jobs.IDFromContext(ctx)
+	err = w.pool.QueryRow(ctx, `
+		INSERT INTO entity_bios (entity_id, bio, citations, sources, model, job_id)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, 0))
+		ON CONFLICT (entity_id) DO UPDATE SET
+			bio = EXCLUDED.bio,
+			citations = EXCLUDED.citations,
+			sources = EXCLUDED.sources,
+			model = EXCLUDED.model,
+			job_id = EXCLUDED.job_id,
+			generated_at = NOW()
+		RETURNING generated_at
+	`, entityID, bio.Text, bio.Citations, bio.Sources, bio.Model, jobID).Scan(&bio.GeneratedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("bio: entity %d: %d sources, %d citations", entityID, len(sources), len(bio.Citations))
+	return bio, nil
+}
+
+// excerpts cuts windows around the first mentions of any of names in the
+// documents that mention the entity most. Only documents anyone may read
+// are used: not deleted, not of a restricted dataset and not naming a
+// protected entity, whose name the biography could otherwise repeat.
+func (w *Worker) excerpts(ctx context.Context, entityID int, names []string) ([]source, error) {
+	rows, err := w.pool.Query(ctx, `
+		SELECT d.id, d.doc_id, d.full_text
+		FROM document_entities de
+		JOIN documents d ON d.id = de.document_id
+		WHERE de.entity_id = $1 AND d.full_text IS NOT NULL AND d.full_text != ''
+		  AND d.deleted_at IS NULL AND NOT dataset_restricted(d.dataset_id)
+		  AND NOT EXISTS (
+			  SELECT 1 FROM document_entities pe
+			  JOIN entities p ON p.id = pe.entity_id
+			  WHERE pe.document_id = d.id AND p.protected
+		  )
+		ORDER BY de.mention_count DESC, d.id
+		LIMIT $2
+	`, entityID, w.cfg.MaxDocuments)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sources []source
+	for rows.Next() {
+		var id int
+		var docID, text string
+		if err := rows.Scan(&id, &docID, &text); err != nil {
+			return nil, err
+		}
+		for _, span := range mentions(text, names, w.cfg.ExcerptRadius, w.cfg.ExcerptsPerDoc) {
+			sources = append(sources, source{
+				text:       text[span[0]:span[1]],
+				documentID: id,
+				docID:      docID,
+				start:      span[0],
+			})
+		}
+	}
+	return sources, rows.Err()
+}
+
+// mentions returns up to limit non-overlapping windows of radius bytes
+// either side of case-insensitive matches of names
+func mentions(text string, names []string, radius, limit int) [][2]int {
+	var spans [][2]int
+	for pos := 0; len(spans) < limit; {
+		at := -1
+		var length int
+		for _, name := range names {
+			name = strings.TrimSpace(name)
+			if name == "" {
+				continue
+			}
+			if i, j := fold.Index(text[pos:], name); i >= 0 && (at < 0 || pos+i < at) {
+				at, length = pos+i, j-i
+			}
+		}
+		if at < 0 {
+			break
+		}
+
+		start, end := max(at-radius, 0), min(at+length+radius, len(text))
+		if len(spans) > 0 && start < spans[len(spans)-1][1] {
+			start = spans[len(spans)-1][1]
+		}
+		spans = append(spans, [2]int{start, end})
+		pos = end
+	}
+	return spans
+}
+
+// crossrefs describes the entity's verified public-record matches
+func (w *Worker) crossrefs(ctx context.Context, entityID int) ([]source, error) {
+	rows, err := w.pool.Query(ctx, `
+		SELECT m.source::text, m.source_id,
+			   CASE m.source
+				   WHEN 'ppp' THEN format('PPP loan to %s (%s, %s) for $%s from %s, approved %s, status %s',
+					   p.borrower_name, p.borrower_city, p.borrower_state, p.loan_amount, p.lender, p.date_approved, p.loan_status)
+				   WHEN 'fec' THEN format('Political contribution of $%s by %s (%s, %s; employer %s) to %s for candidate %s on %s',
+					   f.amount, f.contributor_name, f.contributor_city, f.contributor_state, f.contributor_employer,
+					   f.committee_name, f.candidate_name, f.contribution_date)
+				   WHEN 'grants' THEN format('Federal grant of $%s to %s (%s, %s) from %s on %s: %s',
+					   g.award_amount, g.recipient_name, g.recipient_city, g.recipient_state, g.awarding_agency, g.award_date, g.description)
+			   END
+		FROM entity_crossref_matches m
+		LEFT JOIN ppp_loans p ON m.source = 'ppp' AND p.id = m.source_id
+		LEFT JOIN fec_contributions f ON m.source = 'fec' AND f.id = m.source_id
+		LEFT JOIN federal_grants g ON m.source = 'grants' AND g.id = m.source_id
+		WHERE m.entity_id = $1 AND m.verified AND NOT m.false_positive
+		ORDER BY m.match_score DESC, m.id
+		LIMIT $2
+	`, entityID, w.cfg.MaxCrossrefs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sources []source
+	for rows.Next() {
+		var s source
+		var text *string
+		if err := rows.Scan(&s.crossref, &s.crossrefID, &text); err != nil {
+			return nil, err
+		}
+		if text != nil {
+			s.text = *text
+			sources = append(sources, s)
+		}
+	}
+	return sources, rows.Err()
+}
+
+// cite keeps the citations whose quotes appear verbatim in their source
+func cite(sources []source, r reply) []Citation {
+	citations := []Citation{}
+	for _, q := range r.Citations {
+		if q.Source < 1 || q.Source > len(sources) {
+			continue
+		}
+		quote := strings.TrimSpace(q.Quote)
+		s := sources[q.Source-1]
+		i := strings.Index(s.text, quote)
+		if quote == "" || i < 0 {
+			continue
+		}
+		c := Citation{Marker: q.Source, Quote: quote}
+		if s.crossref != "" {
+			c.Crossref, c.CrossrefID = s.crossref, s.crossrefID
+		} else {
+			c.DocumentID, c.DocID = s.documentID, s.docID
+			c.Start, c.End = s.start+i, s.start+i+len(quote)
+		}
+		citations = append(citations, c)
+	}
+	return citations
+}
@@ -0,0 +1,316 @@
+// Package blobs copies the files the API serves — documents' originals,
+// page thumbnails and exports — to an S3-compatible bucket, and sends
+// clients to them with short-lived presigned links rather than passing the
+// bytes through the API. Without a bucket, or with proxying switched on,
+// files are sent from local disk, or streamed from the bucket when they
+// aren't there. Uploaded keys are recorded in stored_blobs, so serving a
+// file costs a lookup rather than a request to the bucket.
+package blobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"mime"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/subculture-collective/epstein-db/api/internal/config"
+	"github.com/subculture-collective/epstein-db/api/internal/thumbnails"
+)
+
+// ErrNotFound is returned for a file that is neither on local disk nor in
+// the bucket
+var ErrNotFound = errors.New("blobs: file not found")
+
+// Store serves files from local disk or a bucket
+type Store struct {
+	pool   *pgxpool.Pool
+	bucket *Bucket
+	ttl    time.Duration
+	proxy  bool
+}
+
+// File is a local file kept in the bucket under Key
+type File struct {
+	Key  string
+	Path string
+}
+
+// Result summarizes a sync
+type Result struct {
+	Uploaded   int `json:"uploaded" doc:"Files uploaded or replaced"`
+	Current    int `json:"current" doc:"Files whose copy was already up to date"`
+	Failed     int `json:"failed" doc:"Files that couldn't be read or uploaded"`
+	Removed    int `json:"removed" doc:"Copies of deleted files removed"`
+	DurationMs int `json:"durationMs"`
+}
+
+// Open returns a Store for cfg whose presigned links last ttl. Without a
+// bucket configured it only serves local files.
+func Open(pool *pgxpool.Pool, cfg config.Storage, ttl time.Duration) (*Store, error) {
+	s := &Store{pool: pool, ttl: ttl, proxy: cfg.Proxy}
+	if cfg.Bucket == "" {
+		return s, nil
+	}
+	b, err := NewBucket(BucketConfig{
+		Endpoint:  cfg.Endpoint,
+		Name:      cfg.Bucket,
+		Region:    cfg.Region,
+		AccessKey: cfg.AccessKey,
+		SecretKey: cfg.SecretKey,
+		PathStyle: cfg.PathStyle,
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.bucket = b
+	return s, nil
+}
+
+// Enabled reports whether a bucket is configured
+func (s *Store) Enabled() bool {
+	return s != nil && s.bucket != nil
+}
+
+// DocumentKey is the key of a document's original file at path
+func DocumentKey(documentID int, path string) string {
+	return "documents/" + strconv.Itoa(documentID) + "/" + filepath.Base(path)
+}
+
+// ThumbnailKey is the key of the thumbnail of page n of a document at size
+func ThumbnailKey(documentID, page int, size string) string {
+	return filepath.ToSlash(thumbnails.Path("", documentID, page, size))
+}
+
+// ExportKey is the key of the export file name
+func ExportKey(name string) string {
+	return "exports/" + name
+}
+
+// Send answers c with the file stored as key: a redirect to a presigned
+// link when it's in the bucket and proxying is off, and otherwise the local
+// file at path, or the bucket's copy streamed through. A non-empty filename
+// sends it as an attachment of that name. It returns ErrNotFound when
+// there's no copy anywhere.
+func (s *Store) Send(c *fiber.Ctx, key, path, filename string) error {
+	ctx := c.UserContext()
+	stored := false
+	if s.Enabled() {
+		var err error
+		if stored, err = s.stored(ctx, key); err != nil {
+			return err
+		}
+	}
+
+	if stored && !s.proxy {
+		// The link is good for a while but is itself a credential of sorts
+		c.Set(fiber.HeaderCacheControl, "private, no-store")
+		return c.Redirect(s.bucket.Presign(key, s.ttl, time.Now(), filename), fiber.StatusFound)
+	}
+
+	if path != "" {
+		if _, err := os.Stat(path); err == nil {
+			if filename != "" {
+				return c.Download(path, filename)
+			}
+			return c.SendFile(path)
+		}
+	}
+	if !stored {
+		return ErrNotFound
+	}
+
+	res, err := s.bucket.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	c.Set(fiber.HeaderContentType, res.Header.Get("Content-Type"))
+	if filename != "" {
+		c.Attachment(filename)
+	}
+	// fasthttp closes the body once it has been sent
+	return c.SendStream(res.Body, int(res.ContentLength))
+}
+
+// stored reports whether key has been uploaded
+func (s *Store) stored(ctx context.Context, key string) (bool, error) {
+	var exists bool
+	err := s.pool.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM stored_blobs WHERE key = $1)`, key).Scan(&exists)
+	return exists, err
+}
+
+// Upload copies the file at path to the bucket as key unless the copy there
+// is current, and reports whether it did. It does nothing without a bucket.
+func (s *Store) Upload(ctx context.Context, key, path string) (bool, error) {
+	if !s.Enabled() {
+		return false, nil
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return false, err
+	}
+	modified := info.ModTime().UTC().Truncate(time.Microsecond)
+
+	var current bool
+	err = s.pool.QueryRow(ctx, `
+		SELECT EXISTS (SELECT 1 FROM stored_blobs WHERE key = $1 AND size = $2 AND modified_at = $3)
+	`, key, info.Size(), modified).Scan(&current)
+	if err != nil || current {
+		return false, err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	contentType := mime.TypeByExtension(filepath.Ext(path))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	if err := s.bucket.Put(ctx, key, f, info.Size(), contentType); err != nil {
+		return false, err
+	}
+
+	_, err = s.pool.Exec(ctx, `
+		INSERT INTO stored_blobs (key, size, modified_at) VALUES ($1, $2, $3)
+		ON CONFLICT (key) DO UPDATE SET
+			size = EXCLUDED.size,
+			modified_at = EXCLUDED.modified_at,
+			uploaded_at = NOW()
+	`, key, info.Size(), modified)
+	return err == nil, err
+}
+
+// Remove deletes key from the bucket
+func (s *Store) Remove(ctx context.Context, key string) error {
+	if !s.Enabled() {
+		return nil
+	}
+	if err := s.bucket.Delete(ctx, key); err != nil {
+		return err
+	}
+	_, err := s.pool.Exec(ctx, `DELETE FROM stored_blobs WHERE key = $1`, key)
+	return err
+}
+
+// Sync uploads every document file, page thumbnail under mediaDir and
+// export under exportDir whose copy in the bucket is missing or stale, and
+// removes the copies of exports that have been cleaned up. A file that
+// can't be read or uploaded is logged and tried again on the next sync.
+func (s *Store) Sync(ctx context.Context, mediaDir, exportDir string) (*Result, error) {
+	started := time.Now()
+	result := &Result{}
+	if !s.Enabled() {
+		return nil, errors.New("blobs: no bucket configured; set S3_ENDPOINT and S3_BUCKET")
+	}
+
+	files, err := s.documentFiles(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, dir := range []struct{ root, prefix string }{
+		{filepath.Join(mediaDir, "pages"), "pages/"},
+		{exportDir, "exports/"},
+	} {
+		found, err := walk(dir.root, dir.prefix)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, found...)
+	}
+
+	exports := map[string]bool{}
+	for _, f := range files {
+		if strings.HasPrefix(f.Key, "exports/") {
+			exports[f.Key] = true
+		}
+		uploaded, err := s.Upload(ctx, f.Key, f.Path)
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		switch {
+		case err != nil:
+			log.Printf("blobs: %s: %v", f.Key, err)
+			result.Failed++
+		case uploaded:
+			result.Uploaded++
+		default:
+			result.Current++
+		}
+	}
+
+	rows, err := s.pool.Query(ctx, `SELECT key FROM stored_blobs WHERE key LIKE 'exports/%'`)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range keys {
+		if exports[key] {
+			continue
+		}
+		if err := s.Remove(ctx, key); err != nil {
+			return nil, fmt.Errorf("removing %s: %w", key, err)
+		}
+		result.Removed++
+	}
+
+	result.DurationMs = int(time.Since(started).Milliseconds())
+	return result, nil
+}
+
+// documentFiles lists the original files of documents that haven't been
+// deleted
+func (s *Store) documentFiles(ctx context.Context) ([]File, error) {
+	rows, err := s.pool.Query(ctx, `
+		SELECT id, file_path FROM documents
+		WHERE file_path IS NOT NULL AND file_path <> '' AND deleted_at IS NULL
+		ORDER BY id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (File, error) {
+		var id int
+		var f File
+		if err := row.Scan(&id, &f.Path); err != nil {
+			return f, err
+		}
+		f.Key = DocumentKey(id, f.Path)
+		return f, nil
+	})
+}
+
+// walk lists the finished files under root, keyed by prefix and their path
+// below it. A missing root has no files.
+func walk(root, prefix string) ([]File, error) {
+	var files []File
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if errors.Is(err, fs.ErrNotExist) && path == root {
+			return fs.SkipDir
+		}
+		if err != nil || d.IsDir() || strings.HasSuffix(path, ".partial") {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		files = append(files, File{Key: prefix + filepath.ToSlash(rel), Path: path})
+		return nil
+	})
+	return files, err
+}
@@ -0,0 +1,225 @@
+package blobs
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// unsignedPayload stands in for the body's hash; S3 accepts it over TLS and
+// in presigned URLs
+const unsignedPayload = "UNSIGNED-PAYLOAD"
+
+// BucketConfig locates an S3-compatible bucket
+type BucketConfig struct {
+	Endpoint  string // e.g. https://s3.us-east-1.amazonaws.com
+	Name      string
+	Region    string
+	AccessKey string
+	SecretKey string
+	// PathStyle addresses the bucket as endpoint/name rather than
+	// name.endpoint, as MinIO and most other S3 work-alikes want
+	PathStyle bool
+}
+
+// Bucket reads, writes and presigns objects in an S3-compatible bucket,
+// signing requests with AWS Signature Version 4
+type Bucket struct {
+	cfg      BucketConfig
+	endpoint *url.URL
+	client   *http.Client
+}
+
+// NewBucket returns a client for the bucket cfg names
+func NewBucket(cfg BucketConfig) (*Bucket, error) {
+	u, err := url.Parse(cfg.Endpoint)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("blobs: invalid endpoint %q", cfg.Endpoint)
+	}
+	return &Bucket{cfg: cfg, endpoint: u, client: &http.Client{Timeout: 10 * time.Minute}}, nil
+}
+
+// objectURL is the URL of key, without a query
+func (b *Bucket) objectURL(key string) *url.URL {
+	u := *b.endpoint
+	path := strings.TrimSuffix(u.Path, "/")
+	if b.cfg.PathStyle {
+		path += "/" + b.cfg.Name
+	} else {
+		u.Host = b.cfg.Name + "." + u.Host
+	}
+	u.Path = path + "/" + key
+	u.RawPath = uriEncode(path, false) + "/" + uriEncode(key, false)
+	return &u
+}
+
+// Presign returns a URL that fetches key without credentials until ttl
+// after now. A non-empty filename makes the response an attachment of that
+// name.
+func (b *Bucket) Presign(key string, ttl time.Duration, now time.Time, filename string) string {
+	now = now.UTC()
+	u := b.objectURL(key)
+	q := map[string]string{
+		"X-Amz-Algorithm":     "AWS4-HMAC-SHA256",
+		"X-Amz-Credential":    b.cfg.AccessKey + "/" + b.scope(now),
+		"X-Amz-Date":          now.Format("20060102T150405Z"),
+		"X-Amz-Expires":       strconv.Itoa(int(ttl.Seconds())),
+		"X-Amz-SignedHeaders": "host",
+	}
+	if filename != "" {
+		q["response-content-disposition"] = `attachment; filename="` + filename + `"`
+	}
+	query := canonicalQuery(q)
+	signature := b.sign(now, http.MethodGet, u, query, map[string]string{"host": u.Host}, unsignedPayload)
+	u.RawQuery = query + "&X-Amz-Signature=" + signature
+	return u.String()
+}
+
+// Put uploads size bytes of body as key
+func (b *Bucket) Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error {
+	res, err := b.do(ctx, http.MethodPut, key, body, size, contentType)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	return nil
+}
+
+// Get opens key for reading; the caller closes it
+func (b *Bucket) Get(ctx context.Context, key string) (*http.Response, error) {
+	return b.do(ctx, http.MethodGet, key, nil, 0, "")
+}
+
+// Delete removes key
+func (b *Bucket) Delete(ctx context.Context, key string) error {
+	res, err := b.do(ctx, http.MethodDelete, key, nil, 0, "")
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	return nil
+}
+
+// do sends a request signed in its headers, failing on any status but 2xx
+func (b *Bucket) do(ctx context.Context, method, key string, body io.Reader, size int64, contentType string) (*http.Response, error) {
+	u := b.objectURL(key)
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	req.URL = u
+	if body != nil {
+		req.ContentLength = size
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	now := time.Now().UTC()
+	headers := map[string]string{
+		"host":                 u.Host,
+		"x-amz-content-sha256": unsignedPayload,
+		"x-amz-date":           now.Format("20060102T150405Z"),
+	}
+	signature := b.sign(now, method, u, "", headers, unsignedPayload)
+	req.Header.Set("X-Amz-Content-Sha256", headers["x-amz-content-sha256"])
+	req.Header.Set("X-Amz-Date", headers["x-amz-date"])
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+b.cfg.AccessKey+"/"+b.scope(now)+
+		", SignedHeaders="+signedHeaders(headers)+", Signature="+signature)
+
+	res, err := b.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+		res.Body.Close()
+		return nil, fmt.Errorf("blobs: %s %s: %s: %s", method, key, res.Status, strings.TrimSpace(string(msg)))
+	}
+	return res, nil
+}
+
+func (b *Bucket) scope(now time.Time) string {
+	return now.Format("20060102") + "/" + b.cfg.Region + "/s3/aws4_request"
+}
+
+// sign returns the Signature Version 4 signature of a request
+func (b *Bucket) sign(now time.Time, method string, u *url.URL, query string, headers map[string]string, payload string) string {
+	var canonicalHeaders strings.Builder
+	for _, name := range sortedKeys(headers) {
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(headers[name]) + "\n")
+	}
+	request := strings.Join([]string{
+		method,
+		u.EscapedPath(),
+		query,
+		canonicalHeaders.String(),
+		signedHeaders(headers),
+		payload,
+	}, "\n")
+	hashed := sha256.Sum256([]byte(request))
+
+	toSign := "AWS4-HMAC-SHA256\n" + now.Format("20060102T150405Z") + "\n" + b.scope(now) + "\n" + hex.EncodeToString(hashed[:])
+
+	key := hmacSHA256([]byte("AWS4"+b.cfg.SecretKey), now.Format("20060102"))
+	key = hmacSHA256(key, b.cfg.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	return hex.EncodeToString(hmacSHA256(key, toSign))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+func signedHeaders(headers map[string]string) string {
+	return strings.Join(sortedKeys(headers), ";")
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// canonicalQuery encodes q sorted by name, as Signature Version 4 wants
+func canonicalQuery(q map[string]string) string {
+	parts := make([]string, 0, len(q))
+	for _, k := range sortedKeys(q) {
+		parts = append(parts, uriEncode(k, true)+"="+uriEncode(q[k], true))
+	}
+	return strings.Join(parts, "&")
+}
+
+// uriEncode percent-encodes everything but unreserved characters, and
+// slashes unless encodeSlash
+func uriEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,201 @@
+// Package bookmarks keeps, for each API key, the documents and entities it
+// has bookmarked and those it has recently viewed, so a researcher can pick
+// up where they left off.
+package bookmarks
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Item types
+const (
+	TypeDocument = "document"
+	TypeEntity   = "entity"
+)
+
+// Types lists every kind of item that can be bookmarked or viewed
+var Types = []string{TypeDocument, TypeEntity}
+
+// HistorySize is how many viewed items are kept per key; older views are
+// forgotten
+const HistorySize = 500
+
+// ErrNoItem is returned when bookmarking a document or entity that doesn't
+// exist
+var ErrNoItem = errors.New("bookmarks: no such item")
+
+// Bookmark is a document or entity a key has bookmarked
+type Bookmark struct {
+	Type      string    `json:"type" enum:"document,entity"`
+	RefID     int       `json:"refId" doc:"ID of the document or entity"`
+	Note      string    `json:"note"`
+	Label     string    `json:"label" doc:"Document ID or entity name"`
+	Summary   string    `json:"summary" doc:"Document summary or entity description"`
+	Missing   bool      `json:"missing" doc:"The row has since been deleted or merged away"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// View is a document or entity a key has viewed
+type View struct {
+	Type          string    `json:"type" enum:"document,entity"`
+	RefID         int       `json:"refId" doc:"ID of the document or entity"`
+	Label         string    `json:"label" doc:"Document ID or entity name"`
+	Summary       string    `json:"summary" doc:"Document summary or entity description"`
+	Missing       bool      `json:"missing" doc:"The row has since been deleted or merged away"`
+	Views         int       `json:"views"`
+	FirstViewedAt time.Time `json:"firstViewedAt"`
+	ViewedAt      time.Time `json:"viewedAt"`
+}
+
+// labels joins an item's document or entity for its label and summary
+const labels = `
+	LEFT JOIN documents d ON x.item_type = 'document' AND d.id = x.item_id
+	LEFT JOIN entities e ON x.item_type = 'entity' AND e.id = x.item_id
+`
+
+// Add bookmarks a document or entity for keyID. Bookmarking one again
+// replaces its note. It returns ErrNoItem if the item doesn't exist.
+func Add(ctx context.Context, pool *pgxpool.Pool, keyID int, itemType string, refID int, note string) (*Bookmark, error) {
+	var b Bookmark
+	err := pool.QueryRow(ctx, `
+		WITH x AS (
+			INSERT INTO bookmarks (key_id, item_type, item_id, note)
+			SELECT $1, $2, $3, $4
+			WHERE CASE $2::text
+				WHEN 'document' THEN EXISTS (SELECT 1 FROM documents WHERE id = $3)
+				WHEN 'entity' THEN EXISTS (SELECT 1 FROM entities WHERE id = $3)
+			END
+			ON CONFLICT (key_id, item_type, item_id) DO UPDATE SET note = EXCLUDED.note
+			RETURNING item_type, item_id, note, created_at
+		)
+		SELECT x.item_type, x.item_id, x.note, x.created_at,
+			   COALESCE(d.doc_id, e.canonical_name, ''),
+			   COALESCE(d.summary, e.description, ''),
+			   d.id IS NULL AND e.id IS NULL
+		FROM x`+labels, keyID, itemType, refID, note).
+		Scan(&b.Type, &b.RefID, &b.Note, &b.CreatedAt, &b.Label, &b.Summary, &b.Missing)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNoItem
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+// Remove deletes a bookmark of keyID, or returns pgx.ErrNoRows
+func Remove(ctx context.Context, pool *pgxpool.Pool, keyID int, itemType string, refID int) error {
+	tag, err := pool.Exec(ctx, `
+		DELETE FROM bookmarks WHERE key_id = $1 AND item_type = $2 AND item_id = $3
+	`, keyID, itemType, refID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+// List returns the bookmarks of keyID, newest first, optionally of one type
+func List(ctx context.Context, pool *pgxpool.Pool, keyID int, itemType string, limit, offset int) ([]Bookmark, error) {
+	rows, err := pool.Query(ctx, `
+		SELECT x.item_type, x.item_id, x.note, x.created_at,
+			   COALESCE(d.doc_id, e.canonical_name, ''),
+			   COALESCE(d.summary, e.description, ''),
+			   d.id IS NULL AND e.id IS NULL
+		FROM bookmarks x`+labels+`
+		WHERE x.key_id = $1 AND ($2 = '' OR x.item_type = $2)
+		ORDER BY x.created_at DESC, x.item_type, x.item_id
+		LIMIT $3 OFFSET $4
+	`, keyID, itemType, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []Bookmark{}
+	for rows.Next() {
+		var b Bookmark
+		if err := rows.Scan(&b.Type, &b.RefID, &b.Note, &b.CreatedAt, &b.Label, &b.Summary, &b.Missing); err != nil {
+			return nil, err
+		}
+		list = append(list, b)
+	}
+	return list, rows.Err()
+}
+
+// Record notes that keyID viewed a document or entity, forgetting its
+// oldest views beyond HistorySize
+func Record(ctx context.Context, pool *pgxpool.Pool, keyID int, itemType string, refID int) error {
+	batch := &pgx.Batch{}
+	batch.Queue(`
+		INSERT INTO reading_history (key_id, item_type, item_id) VALUES ($1, $2, $3)
+		ON CONFLICT (key_id, item_type, item_id)
+		DO UPDATE SET views = reading_history.views + 1, viewed_at = NOW()
+	`, keyID, itemType, refID)
+	batch.Queue(`
+		DELETE FROM reading_history
+		WHERE key_id = $1 AND viewed_at < (
+			SELECT viewed_at FROM reading_history
+			WHERE key_id = $1
+			ORDER BY viewed_at DESC
+			OFFSET $2 LIMIT 1
+		)
+	`, keyID, HistorySize-1)
+	return pool.SendBatch(ctx, batch).Close()
+}
+
+// History returns what keyID has viewed, most recent first, optionally of
+// one type
+func History(ctx context.Context, pool *pgxpool.Pool, keyID int, itemType string, limit, offset int) ([]View, error) {
+	rows, err := pool.Query(ctx, `
+		SELECT x.item_type, x.item_id, x.views, x.first_viewed_at, x.viewed_at,
+			   COALESCE(d.doc_id, e.canonical_name, ''),
+			   COALESCE(d.summary, e.description, ''),
+			   d.id IS NULL AND e.id IS NULL
+		FROM reading_history x`+labels+`
+		WHERE x.key_id = $1 AND ($2 = '' OR x.item_type = $2)
+		ORDER BY x.viewed_at DESC, x.item_type, x.item_id
+		LIMIT $3 OFFSET $4
+	`, keyID, itemType, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []View{}
+	for rows.Next() {
+		var v View
+		if err := rows.Scan(&v.Type, &v.RefID, &v.Views, &v.FirstViewedAt, &v.ViewedAt,
+			&v.Label, &v.Summary, &v.Missing); err != nil {
+			return nil, err
+		}
+		list = append(list, v)
+	}
+	return list, rows.Err()
+}
+
+// ClearHistory forgets everything keyID has viewed and returns how many
+// items were removed
+func ClearHistory(ctx context.Context, pool *pgxpool.Pool, keyID int) (int64, error) {
+	tag, err := pool.Exec(ctx, `DELETE FROM reading_history WHERE key_id = $1`, keyID)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+// Counts returns how many bookmarks and viewed items keyID has
+func Counts(ctx context.Context, pool *pgxpool.Pool, keyID int) (bookmarks, viewed int, err error) {
+	err = pool.QueryRow(ctx, `
+		SELECT (SELECT COUNT(*) FROM bookmarks WHERE key_id = $1),
+			   (SELECT COUNT(*) FROM reading_history WHERE key_id = $1)
+	`, keyID).Scan(&bookmarks, &viewed)
+	return bookmarks, viewed, err
+}
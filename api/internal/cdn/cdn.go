@@ -0,0 +1,156 @@
+// Package cdn lets a CDN or caching proxy in front of the API keep public
+// responses. Routes are grouped in families, each cached for its own time;
+// responses are tagged with Surrogate-Key headers naming their family and
+// the entity, document or pattern they show, so that a change purges just
+// what it affects. The network and statistics, which are costly to build
+// and change in bulk, may be served stale while the cache revalidates.
+package cdn
+
+import (
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/subculture-collective/epstein-db/api/internal/auth"
+)
+
+// Family is a group of routes cached alike
+type Family struct {
+	Name string
+	// Patterns are path.Match patterns of the family's paths
+	Patterns []string
+	// MaxAge is how long shared caches may keep a response
+	MaxAge time.Duration
+	// Stale is how long past MaxAge they may still serve it while fetching
+	// a fresh one
+	Stale time.Duration
+}
+
+// Families lists the cacheable routes. Routes not listed, such as anything
+// personal to a key, get no CDN headers.
+var Families = []Family{
+	{Name: "stats", Patterns: []string{"/api/stats", "/api/trending", "/api/analytics/*", "/api/datasets/*/analytics"},
+		MaxAge: 5 * time.Minute, Stale: time.Hour},
+	{Name: "network", Patterns: []string{"/api/network", "/api/network/*", "/api/entities/*/connections", "/api/entities/*/network.svg"},
+		MaxAge: 10 * time.Minute, Stale: time.Hour},
+	{Name: "entities", Patterns: []string{"/api/entities", "/api/entities/*", "/api/entities/*/*", "/api/media/*"},
+		MaxAge: time.Hour},
+	{Name: "documents", Patterns: []string{"/api/documents", "/api/documents/*", "/api/documents/*/*", "/api/documents/*/pages/*/thumbnail"},
+		MaxAge: time.Hour},
+	{Name: "meta", Patterns: []string{"/api/meta/*", "/api/meta/*/*", "/api/tags", "/api/datasets"},
+		MaxAge: time.Hour},
+	{Name: "triples", Patterns: []string{"/api/triples", "/api/triples/*"},
+		MaxAge: time.Hour},
+	{Name: "crossref", Patterns: []string{"/api/crossref/*", "/api/analysis/*"},
+		MaxAge: time.Hour},
+	{Name: "patterns", Patterns: []string{"/api/patterns", "/api/patterns/*"},
+		MaxAge: time.Hour},
+	{Name: "timeline", Patterns: []string{"/api/timeline"},
+		MaxAge: time.Hour},
+	{Name: "search", Patterns: []string{"/api/search", "/api/chunks/search"},
+		MaxAge: 5 * time.Minute},
+}
+
+// rowKeys tags a response with the row it shows: /api/entities/42/bio is
+// entity-42
+var rowKeys = regexp.MustCompile(`^/api/(entities|documents|patterns)/(\d+)(?:/|$)`)
+
+// Key is the surrogate key of one entity, document or pattern
+func Key(kind string, id int64) string {
+	return kind + "-" + strconv.FormatInt(id, 10)
+}
+
+// Match returns the family of path, if any
+func Match(p string) (Family, bool) {
+	for _, f := range Families {
+		for _, pattern := range f.Patterns {
+			if ok, _ := path.Match(pattern, p); ok {
+				return f, true
+			}
+		}
+	}
+	return Family{}, false
+}
+
+// Keys returns the surrogate keys of a response from path in family f
+func Keys(f Family, p string) []string {
+	keys := []string{f.Name}
+	if m := rowKeys.FindStringSubmatch(p); m != nil {
+		id, _ := strconv.ParseInt(m[2], 10, 64)
+		keys = append(keys, Key(strings.TrimSuffix(m[1], "s"), id))
+	}
+	return keys
+}
+
+// Headers marks anonymous GET responses of the listed families as
+// cacheable by shared caches for their family's time, keeping the max-age a
+// handler gave clients, and tags them with their surrogate keys.
+// Cloudflare, which reads tags from Cache-Tag, gets them there too.
+// Responses a handler marked private or no-store, and those to requests
+// with an API key, are left alone.
+func Headers(provider string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if err := c.Next(); err != nil {
+			return err
+		}
+		if c.Method() != fiber.MethodGet && c.Method() != fiber.MethodHead {
+			return nil
+		}
+		status := c.Response().StatusCode()
+		if status != fiber.StatusOK && status != fiber.StatusNotModified {
+			return nil
+		}
+		if auth.FromContext(c).KeyID != 0 {
+			return nil
+		}
+		family, ok := Match(c.Path())
+		if !ok {
+			return nil
+		}
+
+		control := string(c.Response().Header.Peek(fiber.HeaderCacheControl))
+		if strings.Contains(control, "private") || strings.Contains(control, "no-store") {
+			return nil
+		}
+		c.Set(fiber.HeaderCacheControl, cacheControl(control, family))
+		// Anonymous responses differ from keyed ones, which caches mustn't
+		// be handed
+		c.Vary("X-API-Key", fiber.HeaderAuthorization)
+
+		keys := Keys(family, c.Path())
+		c.Set("Surrogate-Key", strings.Join(keys, " "))
+		if provider == "cloudflare" {
+			c.Set("Cache-Tag", strings.Join(keys, ","))
+		}
+		return nil
+	}
+}
+
+// cacheControl adds the shared cache directives of f to a handler's
+// Cache-Control. Clients keep the handler's max-age, or revalidate every
+// time without one.
+func cacheControl(control string, f Family) string {
+	directives := []string{"public"}
+	maxAge := "max-age=0"
+	for _, d := range strings.Split(control, ",") {
+		d = strings.TrimSpace(d)
+		if strings.HasPrefix(d, "max-age=") {
+			maxAge = d
+		}
+	}
+	directives = append(directives, maxAge, "s-maxage="+seconds(f.MaxAge))
+	if f.Stale > 0 {
+		directives = append(directives,
+			"stale-while-revalidate="+seconds(f.Stale),
+			"stale-if-error="+seconds(f.Stale))
+	}
+	return strings.Join(directives, ", ")
+}
+
+func seconds(d time.Duration) string {
+	return strconv.Itoa(int(d.Seconds()))
+}
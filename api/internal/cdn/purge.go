@@ -0,0 +1,172 @@
+package cdn
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/subculture-collective/epstein-db/api/internal/changes"
+	"github.com/subculture-collective/epstein-db/api/internal/config"
+)
+
+// ErrNotConfigured is returned by New when no CDN provider is set
+var ErrNotConfigured = errors.New("cdn: CDN_PROVIDER is not set")
+
+// Purger purges cached responses through a CDN's API
+type Purger struct {
+	provider  string
+	serviceID string
+	token     string
+	client    *http.Client
+	// base is the URL of the provider's API
+	base string
+}
+
+// New returns a Purger for the CDN cfg configures, or ErrNotConfigured
+func New(cfg config.CDN) (*Purger, error) {
+	p := &Purger{
+		provider:  cfg.Provider,
+		serviceID: cfg.ServiceID,
+		token:     cfg.APIToken,
+		client:    &http.Client{Timeout: 30 * time.Second},
+	}
+	switch cfg.Provider {
+	case "fastly":
+		p.base = "https://api.fastly.com"
+	case "cloudflare":
+		p.base = "https://api.cloudflare.com/client/v4"
+	case "":
+		return nil, ErrNotConfigured
+	default:
+		return nil, fmt.Errorf("cdn: unknown provider %q", cfg.Provider)
+	}
+	return p, nil
+}
+
+// batchSize is the most keys purged per request; Cloudflare takes 30 tags
+// at a time, Fastly 256 keys
+func (p *Purger) batchSize() int {
+	if p.provider == "cloudflare" {
+		return 30
+	}
+	return 256
+}
+
+// Purge drops the responses tagged with any of keys
+func (p *Purger) Purge(ctx context.Context, keys []string) error {
+	for start := 0; start < len(keys); start += p.batchSize() {
+		batch := keys[start:min(start+p.batchSize(), len(keys))]
+		var err error
+		if p.provider == "cloudflare" {
+			err = p.cloudflare(ctx, map[string]any{"tags": batch})
+		} else {
+			err = p.send(ctx, "/service/"+url.PathEscape(p.serviceID)+"/purge", map[string]string{
+				"Surrogate-Key": strings.Join(batch, " "),
+			}, nil)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PurgeAll drops every cached response
+func (p *Purger) PurgeAll(ctx context.Context) error {
+	if p.provider == "cloudflare" {
+		return p.cloudflare(ctx, map[string]any{"purge_everything": true})
+	}
+	return p.send(ctx, "/service/"+url.PathEscape(p.serviceID)+"/purge_all", nil, nil)
+}
+
+func (p *Purger) cloudflare(ctx context.Context, body map[string]any) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	return p.send(ctx, "/zones/"+url.PathEscape(p.serviceID)+"/purge_cache", map[string]string{
+		"Content-Type": "application/json",
+	}, data)
+}
+
+// send POSTs to the provider's API, failing on any status but 2xx
+func (p *Purger) send(ctx context.Context, path string, headers map[string]string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.base+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	if p.provider == "cloudflare" {
+		req.Header.Set("Authorization", "Bearer "+p.token)
+	} else {
+		req.Header.Set("Fastly-Key", p.token)
+	}
+
+	res, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+		return fmt.Errorf("cdn: %s purge: %s: %s", p.provider, res.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// Publish purges what a batch of changes affects, so that Purger can
+// follow the change log with changes.Follow. A change to a row purges the
+// row's own key and the families that list or count it.
+func (p *Purger) Publish(ctx context.Context, list []changes.Change) error {
+	keys := ChangedKeys(list)
+	if len(keys) == 0 {
+		return nil
+	}
+	return p.Purge(ctx, keys)
+}
+
+// ChangedKeys returns the surrogate keys a batch of changes makes stale,
+// sorted
+func ChangedKeys(list []changes.Change) []string {
+	set := map[string]bool{}
+	add := func(keys ...string) {
+		for _, k := range keys {
+			set[k] = true
+		}
+	}
+	for _, c := range list {
+		switch c.Table {
+		case "documents":
+			add(Key("document", c.RowID), "documents", "search", "timeline", "meta", "stats")
+		case "entities":
+			add(Key("entity", c.RowID), "entities", "network", "search", "meta", "stats")
+		case "triples":
+			add("triples", "network")
+		case "pattern_findings":
+			add("patterns")
+		case "entity_crossref_matches":
+			add("crossref")
+		case "document_entities":
+			add("documents", "entities", "network")
+		case "entity_edges":
+			add("network")
+		}
+	}
+
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,231 @@
+// Package changes reads the change log that triggers keep of documents,
+// entities, triples, pattern findings, cross-reference matches, document
+// mentions and entity edges (migrations 021, 022 and 053), so that mirrors
+// and downstream indexes can follow the database instead of re-dumping it.
+//
+// A reader keeps the Cursor of the last change it has seen and asks for
+// what comes after it. Changes are only handed out once every transaction
+// that started before them has finished, so a change that commits late
+// still comes after any cursor a reader could hold.
+package changes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Tables whose changes are logged
+var Tables = []string{"documents", "entities", "triples", "pattern_findings", "entity_crossref_matches", "document_entities", "entity_edges"}
+
+// Operations
+const (
+	OpInsert = "insert"
+	OpUpdate = "update"
+	OpDelete = "delete"
+)
+
+// prunedName is the change_log_cursors row recording how far the log has
+// been pruned
+const prunedName = "_pruned"
+
+// ErrExpired is returned for a cursor older than the retained log
+var ErrExpired = errors.New("changes: cursor is older than the retained log")
+
+// ErrCursor is returned for a cursor that doesn't parse
+var ErrCursor = errors.New("changes: malformed cursor")
+
+// Cursor is a position in the log: the transaction and sequence number of
+// a change. The zero Cursor is before every change.
+type Cursor struct {
+	TxID int64
+	ID   int64
+}
+
+// String is the opaque form handed to readers
+func (c Cursor) String() string {
+	if c == (Cursor{}) {
+		return ""
+	}
+	return strconv.FormatInt(c.TxID, 10) + "-" + strconv.FormatInt(c.ID, 10)
+}
+
+// Before reports whether c comes before d
+func (c Cursor) Before(d Cursor) bool {
+	return c.TxID < d.TxID || (c.TxID == d.TxID && c.ID < d.ID)
+}
+
+// ParseCursor parses a cursor from String. An empty string is the start.
+func ParseCursor(s string) (Cursor, error) {
+	if s == "" {
+		return Cursor{}, nil
+	}
+	tx, id, ok := strings.Cut(s, "-")
+	if !ok {
+		return Cursor{}, ErrCursor
+	}
+	var c Cursor
+	var err error
+	if c.TxID, err = strconv.ParseInt(tx, 10, 64); err != nil {
+		return Cursor{}, ErrCursor
+	}
+	if c.ID, err = strconv.ParseInt(id, 10, 64); err != nil {
+		return Cursor{}, ErrCursor
+	}
+	return c, nil
+}
+
+// Change is one logged insert, update or delete of a row
+type Change struct {
+	Cursor    string    `json:"cursor"`
+	Table     string    `json:"table" enum:"documents,entities,triples,pattern_findings,entity_crossref_matches,document_entities,entity_edges"`
+	RowID     int64     `json:"rowId"`
+	Op        string    `json:"op" enum:"insert,update,delete"`
+	ChangedAt time.Time `json:"changedAt"`
+}
+
+// Filter narrows Read
+type Filter struct {
+	After  Cursor
+	Tables []string // empty for all
+	Limit  int
+}
+
+// Read returns up to f.Limit changes after f.After, oldest first, and the
+// cursor to read from next. With nothing new the cursor is f.After.
+func Read(ctx context.Context, pool *pgxpool.Pool, f Filter) ([]Change, Cursor, error) {
+	if f.After != (Cursor{}) {
+		pruned, err := prunedTo(ctx, pool)
+		if err != nil {
+			return nil, f.After, err
+		}
+		if f.After.Before(pruned) {
+			return nil, f.After, ErrExpired
+		}
+	}
+
+	rows, err := pool.Query(ctx, `
+		SELECT txid::text::bigint, id, table_name, row_id, op, changed_at
+		FROM change_log
+		WHERE (txid, id) > ($1::bigint::text::xid8, $2)
+		  AND txid < pg_snapshot_xmin(pg_current_snapshot())
+		  AND (cardinality($3::text[]) = 0 OR table_name = ANY($3))
+		ORDER BY txid, id
+		LIMIT $4
+	`, f.After.TxID, f.After.ID, f.Tables, f.Limit)
+	if err != nil {
+		return nil, f.After, err
+	}
+	defer rows.Close()
+
+	next := f.After
+	changes := []Change{}
+	for rows.Next() {
+		var c Change
+		if err := rows.Scan(&next.TxID, &next.ID, &c.Table, &c.RowID, &c.Op, &c.ChangedAt); err != nil {
+			return nil, f.After, err
+		}
+		c.Cursor = next.String()
+		changes = append(changes, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, f.After, err
+	}
+	return changes, next, nil
+}
+
+// Head returns the cursor of the latest change a reader could be handed
+// now, as seen by q. Inside a snapshot's transaction it is where a mirror
+// loaded from the snapshot should start following; changes just after it
+// may already be in the snapshot, so applying them must be idempotent.
+func Head(ctx context.Context, tx pgx.Tx) (Cursor, error) {
+	var c Cursor
+	err := tx.QueryRow(ctx, `
+		SELECT txid::text::bigint, id
+		FROM change_log
+		WHERE txid < pg_snapshot_xmin(pg_current_snapshot())
+		ORDER BY txid DESC, id DESC
+		LIMIT 1
+	`).Scan(&c.TxID, &c.ID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Cursor{}, nil
+	}
+	return c, err
+}
+
+// Prune deletes changes made before cutoff, remembering how far it got so
+// that readers holding older cursors are told to start over
+func Prune(ctx context.Context, pool *pgxpool.Pool, cutoff time.Time) (int64, error) {
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	var last Cursor
+	err = tx.QueryRow(ctx, `
+		SELECT txid::text::bigint, id
+		FROM change_log
+		WHERE changed_at < $1
+		ORDER BY txid DESC, id DESC
+		LIMIT 1
+	`, cutoff).Scan(&last.TxID, &last.ID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	// Delete by position, not time, so nothing before the watermark survives
+	tag, err := tx.Exec(ctx, `
+		DELETE FROM change_log WHERE (txid, id) <= ($1::bigint::text::xid8, $2)
+	`, last.TxID, last.ID)
+	if err != nil {
+		return 0, err
+	}
+	if err := saveCursor(ctx, tx, prunedName, last); err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), tx.Commit(ctx)
+}
+
+func prunedTo(ctx context.Context, pool *pgxpool.Pool) (Cursor, error) {
+	return loadCursor(ctx, pool, prunedName)
+}
+
+func loadCursor(ctx context.Context, pool *pgxpool.Pool, name string) (Cursor, error) {
+	var c Cursor
+	err := pool.QueryRow(ctx, `
+		SELECT txid::text::bigint, change_id FROM change_log_cursors WHERE name = $1
+	`, name).Scan(&c.TxID, &c.ID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Cursor{}, nil
+	}
+	return c, err
+}
+
+// execer is a pool or a transaction
+type execer interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+}
+
+func saveCursor(ctx context.Context, q execer, name string, c Cursor) error {
+	_, err := q.Exec(ctx, `
+		INSERT INTO change_log_cursors (name, txid, change_id)
+		VALUES ($1, $2::bigint::text::xid8, $3)
+		ON CONFLICT (name) DO UPDATE
+		SET txid = EXCLUDED.txid, change_id = EXCLUDED.change_id, updated_at = NOW()
+	`, name, c.TxID, c.ID)
+	if err != nil {
+		return fmt.Errorf("changes: saving cursor %s: %w", name, err)
+	}
+	return nil
+}
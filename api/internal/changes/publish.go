@@ -0,0 +1,95 @@
+package changes
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/nats-io/nats.go"
+)
+
+// Publisher delivers changes downstream, e.g. to a message broker
+type Publisher interface {
+	Publish(ctx context.Context, changes []Change) error
+}
+
+// Follow hands changes to pub in batches of up to batch, oldest first,
+// until ctx is done. It resumes from the cursor saved under name and saves
+// it after each delivered batch, so delivery is at least once: a batch may
+// be sent again after a crash.
+func Follow(ctx context.Context, pool *pgxpool.Pool, name string, pub Publisher, interval time.Duration, batch int) error {
+	cursor, err := loadCursor(ctx, pool, name)
+	if err != nil {
+		return err
+	}
+
+	for {
+		changes, next, err := Read(ctx, pool, Filter{After: cursor, Limit: batch})
+		if errors.Is(err, ErrExpired) {
+			return err
+		}
+		if err != nil {
+			log.Printf("changes: %s: %v", name, err)
+		} else if len(changes) > 0 {
+			if err := pub.Publish(ctx, changes); err != nil {
+				log.Printf("changes: %s: publishing: %v", name, err)
+			} else if err := saveCursor(ctx, pool, name, next); err != nil {
+				log.Printf("changes: %s: %v", name, err)
+			} else {
+				cursor = next
+			}
+		}
+
+		// A full batch means there is probably more waiting
+		if err == nil && len(changes) == batch {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(interval):
+		}
+	}
+}
+
+// NATS publishes each change on <subject>.<table>.<op>, with the change's
+// cursor as its Nats-Msg-Id so that a JetStream stream on the subject drops
+// redeliveries
+type NATS struct {
+	conn    *nats.Conn
+	subject string
+}
+
+// NewNATS connects to the NATS server at url
+func NewNATS(url, subject string) (*NATS, error) {
+	conn, err := nats.Connect(url, nats.Name("epstein-db changes"))
+	if err != nil {
+		return nil, err
+	}
+	return &NATS{conn: conn, subject: subject}, nil
+}
+
+// Publish sends changes and waits for the server to have them all
+func (n *NATS) Publish(ctx context.Context, changes []Change) error {
+	for _, c := range changes {
+		data, err := json.Marshal(c)
+		if err != nil {
+			return err
+		}
+		msg := nats.NewMsg(n.subject + "." + c.Table + "." + c.Op)
+		msg.Header.Set(nats.MsgIdHdr, c.Cursor)
+		msg.Data = data
+		if err := n.conn.PublishMsg(msg); err != nil {
+			return err
+		}
+	}
+	return n.conn.FlushWithContext(ctx)
+}
+
+// Close flushes and closes the connection
+func (n *NATS) Close() error {
+	return n.conn.Drain()
+}
@@ -0,0 +1,261 @@
+// Package chat runs multi-turn research sessions over the documents. Each
+// reply may call tools (document search, entity lookup, an entity's
+// connections) before answering, and, as with /api/ask, citations are kept
+// only when their quotes appear verbatim in a retrieved passage.
+package chat
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/subculture-collective/epstein-db/api/internal/llm"
+	"github.com/subculture-collective/epstein-db/api/internal/rag"
+	"github.com/subculture-collective/epstein-db/api/internal/store"
+)
+
+// Tools the model may call
+const (
+	ToolSearch  = "search_documents"
+	ToolEntity  = "lookup_entity"
+	ToolNetwork = "entity_network"
+)
+
+const systemPrompt = `You are a research assistant helping investigators work through documents related to the Jeffrey Epstein case, over several turns of conversation.
+
+Answer strictly from the numbered sources and tool results of the current turn, never from outside knowledge or your own earlier replies; source numbers restart every turn. After each claim taken from a source, cite it as [n] and quote the exact supporting passage. If what you have doesn't answer the question, say so plainly.
+
+Reply with exactly one JSON object. To gather more material, call one tool:
+{"tool": "search_documents", "query": "..."}  searches document passages; results are added as numbered sources
+{"tool": "lookup_entity", "query": "name"}     finds people, organizations and places by name, with their ids
+{"tool": "entity_network", "entityId": n}      lists the entities sharing the most documents with entity n
+To finish, answer:
+{"answer": "The answer, with [n] after each claim", "citations": [{"source": n, "quote": "exact passage from source n"}]}`
+
+// Config tunes each reply
+type Config struct {
+	Chunks     int // passages added per document search
+	Candidates int // candidates each search method contributes
+	Steps      int // model calls per reply; the last must answer
+	History    int // earlier messages replayed to the model
+	Entities   int // results per entity lookup or network call
+	MaxTokens  int
+}
+
+// DefaultConfig returns the settings used by the API
+func DefaultConfig() Config {
+	return Config{
+		Chunks:     6,
+		Candidates: 40,
+		Steps:      4,
+		History:    12,
+		Entities:   10,
+		MaxTokens:  1500,
+	}
+}
+
+// ToolCall records a tool the model called while writing a reply
+type ToolCall struct {
+	Tool     string `json:"tool" enum:"search_documents,lookup_entity,entity_network"`
+	Query    string `json:"query,omitempty"`
+	EntityID int    `json:"entityId,omitempty"`
+	Results  int    `json:"results"`
+}
+
+// action is one model reply: a tool call or the answer
+type action struct {
+	Tool      string         `json:"tool"`
+	Query     string         `json:"query"`
+	EntityID  int            `json:"entityId"`
+	Answer    string         `json:"answer"`
+	Citations []rag.QuoteRef `json:"citations"`
+}
+
+// turn is the material gathered while writing one reply
+type turn struct {
+	chunks  []rag.Chunk
+	results []string
+	calls   []ToolCall
+}
+
+// Chat writes replies in research sessions
+type Chat struct {
+	pool      *pgxpool.Pool
+	store     *store.Store
+	retriever *rag.Retriever
+	client    llm.Client
+	cfg       Config
+}
+
+// New creates a chat
+func New(pool *pgxpool.Pool, st *store.Store, retriever *rag.Retriever, client llm.Client, cfg Config) *Chat {
+	return &Chat{pool: pool, store: st, retriever: retriever, client: client, cfg: cfg}
+}
+
+// Send adds a user message to a session owned by keyID and returns the
+// reply. Both are stored only once the reply is written, so a failed call
+// leaves the session as it was.
+func (c *Chat) Send(ctx context.Context, keyID int, sessionID int64, content string) (*Message, error) {
+	history, err := recent(ctx, c.pool, keyID, sessionID, c.cfg.History)
+	if err != nil {
+		return nil, err
+	}
+	messages := make([]llm.Message, 0, len(history)+1)
+	for _, m := range history {
+		messages = append(messages, llm.Message{Role: m.Role, Content: m.Content})
+	}
+
+	reply := &Message{Role: RoleAssistant, Citations: []rag.Citation{}, ToolCalls: []ToolCall{}}
+	var t turn
+	var a action
+	for step := 1; step <= c.cfg.Steps; step++ {
+		final := step == c.cfg.Steps
+		resp, err := c.client.Complete(ctx, llm.Request{
+			System:    systemPrompt,
+			Messages:  append(messages, llm.Message{Role: RoleUser, Content: t.prompt(content, final)}),
+			MaxTokens: c.cfg.MaxTokens,
+		})
+		if err != nil {
+			return nil, err
+		}
+		reply.Model = resp.Model
+		reply.InputTokens += resp.InputTokens
+		reply.OutputTokens += resp.OutputTokens
+
+		a = action{}
+		if err := llm.DecodeJSON(resp.Text, &a); err != nil {
+			return nil, fmt.Errorf("chat: %w", err)
+		}
+		if a.Tool == "" || final {
+			break
+		}
+		if err := c.call(ctx, &t, a); err != nil {
+			return nil, err
+		}
+	}
+
+	reply.Content = strings.TrimSpace(a.Answer)
+	if reply.Content == "" {
+		reply.Content = "I couldn't find an answer to that in the documents."
+	}
+	reply.Citations = rag.Cite(t.chunks, a.Citations)
+	reply.ToolCalls = append(reply.ToolCalls, t.calls...)
+
+	if err := save(ctx, c.pool, sessionID, content, reply); err != nil {
+		return nil, err
+	}
+	return reply, nil
+}
+
+// call runs a tool and adds its results to the turn. Unknown tools and bad
+// arguments are reported back to the model rather than failing the reply.
+func (c *Chat) call(ctx context.Context, t *turn, a action) error {
+	call := ToolCall{Tool: a.Tool, Query: strings.TrimSpace(a.Query), EntityID: a.EntityID}
+	var out strings.Builder
+
+	switch a.Tool {
+	case ToolSearch:
+		if call.Query == "" {
+			out.WriteString("error: query is required")
+			break
+		}
+		chunks, err := c.retriever.Search(ctx, call.Query, c.cfg.Chunks, c.cfg.Candidates)
+		if err != nil {
+			return err
+		}
+		first := len(t.chunks) + 1
+		for _, ch := range chunks {
+			if !t.has(ch.ID) {
+				t.chunks = append(t.chunks, ch)
+			}
+		}
+		call.Results = len(t.chunks) - first + 1
+		if call.Results == 0 {
+			out.WriteString("No new passages found.")
+		} else {
+			fmt.Fprintf(&out, "Added sources %d to %d.", first, len(t.chunks))
+		}
+
+	case ToolEntity:
+		if call.Query == "" {
+			out.WriteString("error: query is required")
+			break
+		}
+		entities, err := c.store.SearchEntities(ctx, store.EntityFilter{Query: call.Query, Limit: c.cfg.Entities})
+		if err != nil {
+			return err
+		}
+		call.Results = len(entities)
+		for _, e := range entities {
+			fmt.Fprintf(&out, "id %d: %s (%s), %d documents, %d connections\n",
+				e.ID, e.CanonicalName, e.EntityType, deref(e.DocumentCount), deref(e.ConnectionCount))
+		}
+		if len(entities) == 0 {
+			out.WriteString("No matching entities.")
+		}
+
+	case ToolNetwork:
+		if call.EntityID <= 0 {
+			out.WriteString("error: entityId is required")
+			break
+		}
+		connections, err := c.store.EntityConnections(ctx, call.EntityID, store.GranularityDocument, store.Sort{}, c.cfg.Entities)
+		if err != nil {
+			return err
+		}
+		call.Results = len(connections)
+		for _, conn := range connections {
+			fmt.Fprintf(&out, "id %d: %s (%s), %d shared documents\n",
+				conn.ID, conn.CanonicalName, conn.EntityType, conn.SharedDocs)
+		}
+		if len(connections) == 0 {
+			out.WriteString("No connections found.")
+		}
+
+	default:
+		fmt.Fprintf(&out, "error: unknown tool %q", a.Tool)
+	}
+
+	t.calls = append(t.calls, call)
+	t.results = append(t.results, fmt.Sprintf("<result tool=%q query=%q entityId=\"%d\">\n%s\n</result>",
+		call.Tool, call.Query, call.EntityID, strings.TrimSpace(out.String())))
+	return nil
+}
+
+// prompt renders the user's message with everything gathered so far
+func (t *turn) prompt(content string, final bool) string {
+	var prompt strings.Builder
+	if len(t.chunks) > 0 {
+		rag.WriteSources(&prompt, t.chunks)
+	}
+	if len(t.results) > 0 {
+		prompt.WriteString("Tool results:\n\n")
+		for _, r := range t.results {
+			prompt.WriteString(r + "\n\n")
+		}
+	}
+	fmt.Fprintf(&prompt, "Message: %s\n\n", content)
+	if final {
+		prompt.WriteString("You can't call any more tools; answer now from what you have.\n\n")
+	}
+	prompt.WriteString("Return ONLY valid JSON.")
+	return prompt.String()
+}
+
+func (t *turn) has(chunkID int) bool {
+	for _, c := range t.chunks {
+		if c.ID == chunkID {
+			return true
+		}
+	}
+	return false
+}
+
+func deref(n *int) int {
+	if n == nil {
+		return 0
+	}
+	return *n
+}
@@ -0,0 +1,230 @@
+package chat
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/subculture-collective/epstein-db/api/internal/rag"
+)
+
+// Message roles
+const (
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+)
+
+// maxTitle caps titles taken from a session's first message
+const maxTitle = 80
+
+// Session is a research conversation. Sessions are visible only to the API
+// key that created them.
+type Session struct {
+	ID        int64     `json:"id"`
+	Title     string    `json:"title"`
+	Messages  int       `json:"messages"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// Message is one turn of a session
+type Message struct {
+	ID           int64          `json:"id"`
+	Role         string         `json:"role" enum:"user,assistant"`
+	Content      string         `json:"content"`
+	Citations    []rag.Citation `json:"citations"`
+	ToolCalls    []ToolCall     `json:"toolCalls"`
+	Model        string         `json:"model,omitempty"`
+	InputTokens  int            `json:"inputTokens"`
+	OutputTokens int            `json:"outputTokens"`
+	CreatedAt    time.Time      `json:"createdAt"`
+}
+
+// Transcript is a session with all of its messages
+type Transcript struct {
+	Session
+	Turns []Message `json:"turns"`
+}
+
+// Create starts a session for keyID
+func Create(ctx context.Context, pool *pgxpool.Pool, keyID int, title string) (*Session, error) {
+	s := Session{Title: title}
+	err := pool.QueryRow(ctx, `
+		INSERT INTO chat_sessions (key_id, title) VALUES ($1, $2)
+		RETURNING id, created_at, updated_at
+	`, keyID, title).Scan(&s.ID, &s.CreatedAt, &s.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// List returns the sessions of keyID, most recently active first
+func List(ctx context.Context, pool *pgxpool.Pool, keyID, limit, offset int) ([]Session, error) {
+	rows, err := pool.Query(ctx, `
+		SELECT s.id, s.title, s.created_at, s.updated_at,
+			   (SELECT COUNT(*) FROM chat_messages m WHERE m.session_id = s.id)
+		FROM chat_sessions s
+		WHERE s.key_id = $1
+		ORDER BY s.updated_at DESC, s.id DESC
+		LIMIT $2 OFFSET $3
+	`, keyID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []Session{}
+	for rows.Next() {
+		var s Session
+		if err := rows.Scan(&s.ID, &s.Title, &s.CreatedAt, &s.UpdatedAt, &s.Messages); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, s)
+	}
+	return sessions, rows.Err()
+}
+
+// Get returns a session of keyID with its messages, or pgx.ErrNoRows
+func Get(ctx context.Context, pool *pgxpool.Pool, keyID int, id int64) (*Transcript, error) {
+	t := Transcript{Session: Session{ID: id}}
+	err := pool.QueryRow(ctx, `
+		SELECT title, created_at, updated_at FROM chat_sessions
+		WHERE id = $1 AND key_id = $2
+	`, id, keyID).Scan(&t.Title, &t.CreatedAt, &t.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if t.Turns, err = messages(ctx, pool, id, 0); err != nil {
+		return nil, err
+	}
+	t.Messages = len(t.Turns)
+	return &t, nil
+}
+
+// Delete removes a session of keyID and its messages, or returns
+// pgx.ErrNoRows
+func Delete(ctx context.Context, pool *pgxpool.Pool, keyID int, id int64) error {
+	tag, err := pool.Exec(ctx, `DELETE FROM chat_sessions WHERE id = $1 AND key_id = $2`, id, keyID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+// recent returns up to limit of the latest messages of a session of keyID,
+// oldest first and starting with a user message, as the model expects
+func recent(ctx context.Context, pool *pgxpool.Pool, keyID int, id int64, limit int) ([]Message, error) {
+	var exists bool
+	err := pool.QueryRow(ctx, `
+		SELECT EXISTS (SELECT 1 FROM chat_sessions WHERE id = $1 AND key_id = $2)
+	`, id, keyID).Scan(&exists)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, pgx.ErrNoRows
+	}
+
+	history, err := messages(ctx, pool, id, limit)
+	if err != nil {
+		return nil, err
+	}
+	for len(history) > 0 && history[0].Role != RoleUser {
+		history = history[1:]
+	}
+	return history, nil
+}
+
+// messages returns a session's messages in order; with a limit, only the
+// latest ones
+func messages(ctx context.Context, pool *pgxpool.Pool, id int64, limit int) ([]Message, error) {
+	rows, err := pool.Query(ctx, `
+		SELECT * FROM (
+			SELECT id, role, content, citations, tool_calls, COALESCE(model, ''),
+				   input_tokens, output_tokens, created_at
+			FROM chat_messages
+			WHERE session_id = $1
+			ORDER BY id DESC
+			LIMIT NULLIF($2, 0)
+		) m ORDER BY id
+	`, id, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []Message{}
+	for rows.Next() {
+		var m Message
+		var citations, calls []byte
+		if err := rows.Scan(&m.ID, &m.Role, &m.Content, &citations, &calls, &m.Model,
+			&m.InputTokens, &m.OutputTokens, &m.CreatedAt); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(citations, &m.Citations); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(calls, &m.ToolCalls); err != nil {
+			return nil, err
+		}
+		list = append(list, m)
+	}
+	return list, rows.Err()
+}
+
+// save stores a user message and its reply, naming the session after its
+// first message
+func save(ctx context.Context, pool *pgxpool.Pool, sessionID int64, content string, reply *Message) error {
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO chat_messages (session_id, role, content) VALUES ($1, $2, $3)
+	`, sessionID, RoleUser, content)
+	if err != nil {
+		return err
+	}
+	err = tx.QueryRow(ctx, `
+		INSERT INTO chat_messages (session_id, role, content, citations, tool_calls, model, input_tokens, output_tokens)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id, created_at
+	`, sessionID, reply.Role, reply.Content, reply.Citations, reply.ToolCalls, reply.Model,
+		reply.InputTokens, reply.OutputTokens).Scan(&reply.ID, &reply.CreatedAt)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx, `
+		UPDATE chat_sessions
+		SET updated_at = NOW(), title = CASE WHEN title = '' THEN $2 ELSE title END
+		WHERE id = $1
+	`, sessionID, title(content))
+	if err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// title shortens a message to a session title at a word boundary
+func title(content string) string {
+	runes := []rune(content)
+	if len(runes) <= maxTitle {
+		return content
+	}
+	cut := string(runes[:maxTitle])
+	if i := strings.LastIndexByte(cut, ' '); i > len(cut)/2 {
+		cut = cut[:i]
+	}
+	return strings.TrimSpace(cut) + "…"
+}
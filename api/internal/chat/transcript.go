@@ -0,0 +1,47 @@
+package chat
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Markdown renders a transcript for reading or archiving, with each reply's
+// citations listed under it
+func (t *Transcript) Markdown() string {
+	var b strings.Builder
+	title := t.Title
+	if title == "" {
+		title = fmt.Sprintf("Session %d", t.ID)
+	}
+	fmt.Fprintf(&b, "# %s\n\n_Started %s_\n", title, t.CreatedAt.UTC().Format("2006-01-02 15:04 MST"))
+
+	for _, m := range t.Turns {
+		if m.Role == RoleUser {
+			fmt.Fprintf(&b, "\n## Question\n\n%s\n", m.Content)
+			continue
+		}
+
+		fmt.Fprintf(&b, "\n## Answer\n\n%s\n", m.Content)
+		if len(m.Citations) > 0 {
+			b.WriteString("\n**Sources**\n\n")
+			for _, c := range m.Citations {
+				fmt.Fprintf(&b, "- [%d] %s, characters %d–%d: \"%s\"\n", c.Marker, c.DocID, c.Start, c.End, oneLine(c.Quote))
+			}
+		}
+		if len(m.ToolCalls) > 0 {
+			b.WriteString("\n**Tools used**\n\n")
+			for _, call := range m.ToolCalls {
+				arg := call.Query
+				if call.Tool == ToolNetwork {
+					arg = fmt.Sprintf("entity %d", call.EntityID)
+				}
+				fmt.Fprintf(&b, "- %s (%s): %d results\n", call.Tool, arg, call.Results)
+			}
+		}
+	}
+	return b.String()
+}
+
+func oneLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
@@ -0,0 +1,210 @@
+// Package cite formats citations of documents as BibTeX, CSL-JSON (for
+// Zotero, Pandoc and other CSL processors) and Chicago notes-bibliography
+// style. Documents are cited as records published by the Department of
+// Justice, found through this database, with their dataset as provenance.
+package cite
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Formats
+const (
+	FormatBibTeX  = "bibtex"
+	FormatCSLJSON = "csl-json"
+	FormatChicago = "chicago"
+)
+
+// Formats lists the citation formats
+var Formats = []string{FormatBibTeX, FormatCSLJSON, FormatChicago}
+
+// Media types of each format
+const (
+	MediaBibTeX  = "application/x-bibtex"
+	MediaCSLJSON = "application/vnd.citationstyles.csl+json"
+)
+
+// The release the documents come from and where they were found
+const (
+	publisher  = "U.S. Department of Justice"
+	collection = "DOJ Epstein Files"
+	sourceURL  = "https://www.justice.gov/epstein"
+	archive    = "Epstein Files Database"
+)
+
+// Record is what a citation of a document says
+type Record struct {
+	ID            int
+	DocID         string
+	DocumentType  string
+	DatasetID     int
+	DatasetName   string
+	DatasetSource string     // where the release was loaded from, if a URL
+	From, To      *time.Time // dates the document covers
+	Pages         *int
+	URL           string
+	Accessed      time.Time
+}
+
+// Load reads what citing document id needs, or returns pgx.ErrNoRows. url
+// is the document's permalink, and accessed the date it was consulted.
+func Load(ctx context.Context, pool *pgxpool.Pool, id int, url string, accessed time.Time) (*Record, error) {
+	r := &Record{ID: id, URL: url, Accessed: accessed.UTC()}
+	err := pool.QueryRow(ctx, `
+		SELECT d.doc_id, COALESCE(d.document_type, ''), d.dataset_id,
+			   COALESCE(ds.name, ''), COALESCE(ds.source, ''),
+			   d.date_earliest, d.date_latest, d.page_count
+		FROM documents d
+		LEFT JOIN datasets ds ON ds.id = d.dataset_id
+		WHERE d.id = $1
+	`, id).Scan(&r.DocID, &r.DocumentType, &r.DatasetID, &r.DatasetName, &r.DatasetSource,
+		&r.From, &r.To, &r.Pages)
+	if err != nil {
+		return nil, err
+	}
+	// Releases loaded from local files don't say where they came from
+	if !strings.HasPrefix(r.DatasetSource, "http://") && !strings.HasPrefix(r.DatasetSource, "https://") {
+		r.DatasetSource = ""
+	}
+	if r.DatasetName == "" {
+		r.DatasetName = "Dataset " + strconv.Itoa(r.DatasetID)
+	}
+	if r.From == nil {
+		r.From, r.To = r.To, nil
+	}
+	if r.To != nil && r.From.Equal(*r.To) {
+		r.To = nil
+	}
+	return r, nil
+}
+
+// Title is the document's ID and, if known, its type
+func (r *Record) Title() string {
+	if r.DocumentType == "" {
+		return r.DocID
+	}
+	return r.DocID + ": " + r.DocumentType
+}
+
+// location is where in the release the document sits
+func (r *Record) location() string {
+	return r.DatasetName + ", " + r.DocID
+}
+
+// BibTeX returns a @misc entry keyed by the lower-cased document ID
+func (r *Record) BibTeX() string {
+	var b strings.Builder
+	field := func(name, value string) {
+		b.WriteString("  " + name + " = {" + value + "},\n")
+	}
+
+	b.WriteString("@misc{" + strings.ToLower(r.DocID) + ",\n")
+	field("title", "{"+bibEscape(r.Title())+"}")
+	field("author", "{"+bibEscape(publisher)+"}")
+	if r.From != nil {
+		field("year", strconv.Itoa(r.From.Year()))
+		field("date", r.dateRange("2006-01-02", "/"))
+	}
+	field("howpublished", bibEscape(collection+", "+r.location()))
+	field("organization", bibEscape(publisher))
+	field("note", bibEscape(r.note()))
+	field("url", r.URL)
+	field("urldate", r.Accessed.Format("2006-01-02"))
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// CSL returns the document as a CSL-JSON item list of one
+func (r *Record) CSL() ([]byte, error) {
+	item := map[string]any{
+		"id":               r.DocID,
+		"type":             "document",
+		"title":            r.Title(),
+		"author":           []map[string]string{{"literal": publisher}},
+		"publisher":        publisher,
+		"collection-title": collection,
+		"archive":          archive,
+		"archive_location": r.location(),
+		"source":           sourceURL,
+		"note":             r.note(),
+		"URL":              r.URL,
+		"accessed":         dateParts(r.Accessed),
+	}
+	if r.DocumentType != "" {
+		item["genre"] = r.DocumentType
+	}
+	if r.From != nil {
+		issued := dateParts(*r.From)
+		if r.To != nil {
+			issued["date-parts"] = append(issued["date-parts"], dateParts(*r.To)["date-parts"]...)
+		}
+		item["issued"] = issued
+	}
+	if r.Pages != nil {
+		item["number-of-pages"] = strconv.Itoa(*r.Pages)
+	}
+	return json.MarshalIndent([]any{item}, "", "  ")
+}
+
+// Chicago returns a bibliography entry in Chicago notes-bibliography style
+func (r *Record) Chicago() string {
+	date := "n.d."
+	if r.From != nil {
+		date = r.dateRange("January 2, 2006", "–")
+	}
+	return publisher + ". “" + r.Title() + ".” " + date + ". " +
+		r.location() + ". " + collection + ". " + archive + ". " +
+		"Accessed " + r.Accessed.Format("January 2, 2006") + ". " + r.URL + ".\n"
+}
+
+// note gives the page count and the provenance of the document's dataset
+func (r *Record) note() string {
+	note := "Retrieved from the " + archive + ". Released at " + sourceURL + "."
+	if r.DatasetSource != "" {
+		note += " " + r.DatasetName + " loaded from " + r.DatasetSource + "."
+	}
+	if r.Pages != nil {
+		note = plural(*r.Pages, "page") + ". " + note
+	}
+	return note
+}
+
+func (r *Record) dateRange(layout, sep string) string {
+	s := r.From.Format(layout)
+	if r.To != nil {
+		s += sep + r.To.Format(layout)
+	}
+	return s
+}
+
+func dateParts(t time.Time) map[string][][]int {
+	return map[string][][]int{"date-parts": {{t.Year(), int(t.Month()), t.Day()}}}
+}
+
+// bibEscape escapes LaTeX's special characters
+var bibEscape = strings.NewReplacer(
+	`\`, `\textbackslash{}`,
+	`{`, `\{`,
+	`}`, `\}`,
+	`&`, `\&`,
+	`%`, `\%`,
+	`$`, `\$`,
+	`#`, `\#`,
+	`_`, `\_`,
+	`~`, `\textasciitilde{}`,
+	`^`, `\textasciicircum{}`,
+).Replace
+
+func plural(n int, noun string) string {
+	s := strconv.Itoa(n) + " " + noun
+	if n != 1 {
+		s += "s"
+	}
+	return s
+}
@@ -0,0 +1,82 @@
+package collections
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// sections orders the report, with a heading for each type of item
+var sections = []struct{ itemType, heading string }{
+	{TypeDocument, "Documents"},
+	{TypeEntity, "Entities"},
+	{TypePattern, "Patterns"},
+}
+
+// Markdown renders the collection as a report: its notes, then each kind
+// of item with its summary and the researcher's note
+func (b *Bundle) Markdown() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# %s\n\n_Updated %s_\n", b.Name, b.UpdatedAt.UTC().Format("2006-01-02 15:04 MST"))
+	if b.Notes != "" {
+		fmt.Fprintf(&sb, "\n%s\n", b.Notes)
+	}
+
+	for _, s := range sections {
+		heading := false
+		for _, it := range b.Items {
+			if it.Type != s.itemType {
+				continue
+			}
+			if !heading {
+				fmt.Fprintf(&sb, "\n## %s\n\n", s.heading)
+				heading = true
+			}
+
+			label := it.Label
+			if it.Missing {
+				label = fmt.Sprintf("%s %d (no longer available)", it.Type, it.RefID)
+			}
+			fmt.Fprintf(&sb, "- **%s**", label)
+			if it.Summary != "" {
+				fmt.Fprintf(&sb, ": %s", oneLine(it.Summary))
+			}
+			sb.WriteString("\n")
+			if it.Note != "" {
+				fmt.Fprintf(&sb, "  > %s\n", oneLine(it.Note))
+			}
+		}
+	}
+	return sb.String()
+}
+
+// WriteZip writes the bundle as a zip of collection.json and report.md
+func (b *Bundle) WriteZip(w io.Writer) error {
+	z := zip.NewWriter(w)
+
+	f, err := z.CreateHeader(&zip.FileHeader{Name: "collection.json", Method: zip.Deflate, Modified: b.UpdatedAt})
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(b); err != nil {
+		return err
+	}
+
+	f, err = z.CreateHeader(&zip.FileHeader{Name: "report.md", Method: zip.Deflate, Modified: b.UpdatedAt})
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(f, b.Markdown()); err != nil {
+		return err
+	}
+
+	return z.Close()
+}
+
+func oneLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
@@ -0,0 +1,317 @@
+// Package collections stores research workspaces: named groups of
+// documents, entities and pattern findings with notes, kept per API key.
+// A collection can be shared read-only through a token and exported as a
+// bundle of JSON and a Markdown report.
+package collections
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/subculture-collective/epstein-db/api/internal/store"
+)
+
+// Item types
+const (
+	TypeDocument = "document"
+	TypeEntity   = "entity"
+	TypePattern  = "pattern"
+)
+
+// Types lists every kind of item a collection can hold
+var Types = []string{TypeDocument, TypeEntity, TypePattern}
+
+// ErrNoItem is returned when adding a document, entity or pattern that
+// doesn't exist
+var ErrNoItem = errors.New("collections: no such item")
+
+// Collection is a research workspace. Collections are visible only to the
+// API key that created them, or to anyone with the share token.
+type Collection struct {
+	ID         int64     `json:"id"`
+	Name       string    `json:"name"`
+	Notes      string    `json:"notes"`
+	ItemCount  int       `json:"itemCount"`
+	ShareToken *string   `json:"shareToken,omitempty" doc:"Set while the collection is shared; only shown to its owner"`
+	CreatedAt  time.Time `json:"createdAt"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+}
+
+// Item is a document, entity or pattern finding in a collection, with
+// enough of it to read the collection without further requests
+type Item struct {
+	ID      int64     `json:"id"`
+	Type    string    `json:"type" enum:"document,entity,pattern"`
+	RefID   int       `json:"refId" doc:"ID of the document, entity or pattern"`
+	Note    string    `json:"note"`
+	Label   string    `json:"label" doc:"Document ID, entity name or pattern title"`
+	Summary string    `json:"summary" doc:"Document summary, entity description or pattern description"`
+	Missing bool      `json:"missing" doc:"The row has since been deleted or merged away"`
+	AddedAt time.Time `json:"addedAt"`
+}
+
+// Bundle is a collection with all of its items
+type Bundle struct {
+	Collection
+	Items []Item `json:"items"`
+}
+
+// Create starts an empty collection for keyID
+func Create(ctx context.Context, pool *pgxpool.Pool, keyID int, name, notes string) (*Collection, error) {
+	c := Collection{Name: name, Notes: notes}
+	err := pool.QueryRow(ctx, `
+		INSERT INTO collections (key_id, name, notes) VALUES ($1, $2, $3)
+		RETURNING id, created_at, updated_at
+	`, keyID, name, notes).Scan(&c.ID, &c.CreatedAt, &c.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// List returns the collections of keyID, most recently changed first
+func List(ctx context.Context, pool *pgxpool.Pool, keyID, limit, offset int) ([]Collection, error) {
+	rows, err := pool.Query(ctx, `
+		SELECT c.id, c.name, c.notes, c.share_token, c.created_at, c.updated_at,
+			   (SELECT COUNT(*) FROM collection_items i WHERE i.collection_id = c.id)
+		FROM collections c
+		WHERE c.key_id = $1
+		ORDER BY c.updated_at DESC, c.id DESC
+		LIMIT $2 OFFSET $3
+	`, keyID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []Collection{}
+	for rows.Next() {
+		var c Collection
+		if err := rows.Scan(&c.ID, &c.Name, &c.Notes, &c.ShareToken, &c.CreatedAt, &c.UpdatedAt, &c.ItemCount); err != nil {
+			return nil, err
+		}
+		list = append(list, c)
+	}
+	return list, rows.Err()
+}
+
+// Get returns a collection of keyID with its items, or pgx.ErrNoRows
+func Get(ctx context.Context, pool *pgxpool.Pool, keyID int, id int64) (*Bundle, error) {
+	b := Bundle{Collection: Collection{ID: id}}
+	err := pool.QueryRow(ctx, `
+		SELECT name, notes, share_token, created_at, updated_at FROM collections
+		WHERE id = $1 AND key_id = $2
+	`, id, keyID).Scan(&b.Name, &b.Notes, &b.ShareToken, &b.CreatedAt, &b.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return withItems(ctx, pool, &b)
+}
+
+// Shared returns the collection shared under token with its items, or
+// pgx.ErrNoRows. The token itself isn't included.
+func Shared(ctx context.Context, pool *pgxpool.Pool, token string) (*Bundle, error) {
+	var b Bundle
+	err := pool.QueryRow(ctx, `
+		SELECT id, name, notes, created_at, updated_at FROM collections
+		WHERE share_token = $1
+	`, token).Scan(&b.ID, &b.Name, &b.Notes, &b.CreatedAt, &b.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return withItems(ctx, pool, &b)
+}
+
+// Update renames a collection of keyID or replaces its notes; nil leaves a
+// field as it is. It returns pgx.ErrNoRows if keyID has no such collection.
+func Update(ctx context.Context, pool *pgxpool.Pool, keyID int, id int64, name, notes *string) error {
+	tag, err := pool.Exec(ctx, `
+		UPDATE collections
+		SET name = COALESCE($3, name), notes = COALESCE($4, notes), updated_at = NOW()
+		WHERE id = $1 AND key_id = $2
+	`, id, keyID, name, notes)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+// Delete removes a collection of keyID and its items, or returns
+// pgx.ErrNoRows
+func Delete(ctx context.Context, pool *pgxpool.Pool, keyID int, id int64) error {
+	tag, err := pool.Exec(ctx, `DELETE FROM collections WHERE id = $1 AND key_id = $2`, id, keyID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+// AddItem puts a document, entity or pattern into a collection of keyID.
+// Adding one that is already there replaces its note. It returns
+// pgx.ErrNoRows if keyID has no such collection, and ErrNoItem if the item
+// doesn't exist.
+func AddItem(ctx context.Context, pool *pgxpool.Pool, keyID int, id int64, itemType string, refID int, note string) (*Item, error) {
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	// Lock the collection so a concurrent delete can't orphan the item
+	tag, err := tx.Exec(ctx, `
+		UPDATE collections SET updated_at = NOW() WHERE id = $1 AND key_id = $2
+	`, id, keyID)
+	if err != nil {
+		return nil, err
+	}
+	if tag.RowsAffected() == 0 {
+		return nil, pgx.ErrNoRows
+	}
+
+	var exists bool
+	err = tx.QueryRow(ctx, `
+		SELECT CASE $1::text
+			WHEN 'document' THEN EXISTS (SELECT 1 FROM documents WHERE id = $2)
+			WHEN 'entity' THEN EXISTS (SELECT 1 FROM entities WHERE id = $2)
+			WHEN 'pattern' THEN EXISTS (SELECT 1 FROM pattern_findings WHERE id = $2)
+		END
+	`, itemType, refID).Scan(&exists)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrNoItem
+	}
+
+	var itemID int64
+	err = tx.QueryRow(ctx, `
+		INSERT INTO collection_items (collection_id, item_type, item_id, note)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (collection_id, item_type, item_id) DO UPDATE SET note = EXCLUDED.note
+		RETURNING id
+	`, id, itemType, refID, note).Scan(&itemID)
+	if err != nil {
+		return nil, err
+	}
+
+	added, err := items(ctx, tx, id, itemID)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return &added[0], nil
+}
+
+// RemoveItem takes an item out of a collection of keyID, or returns
+// pgx.ErrNoRows
+func RemoveItem(ctx context.Context, pool *pgxpool.Pool, keyID int, id, itemID int64) error {
+	tag, err := pool.Exec(ctx, `
+		WITH removed AS (
+			DELETE FROM collection_items i
+			USING collections c
+			WHERE i.id = $3 AND i.collection_id = $1 AND c.id = $1 AND c.key_id = $2
+			RETURNING i.collection_id
+		)
+		UPDATE collections SET updated_at = NOW() WHERE id IN (SELECT collection_id FROM removed)
+	`, id, keyID, itemID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+// Share returns the token anyone can read a collection of keyID with,
+// creating one if the collection isn't shared yet. It returns pgx.ErrNoRows
+// if keyID has no such collection.
+func Share(ctx context.Context, pool *pgxpool.Pool, keyID int, id int64) (string, error) {
+	raw := make([]byte, 16)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+
+	var token string
+	err := pool.QueryRow(ctx, `
+		UPDATE collections SET share_token = COALESCE(share_token, $3)
+		WHERE id = $1 AND key_id = $2
+		RETURNING share_token
+	`, id, keyID, "shr_"+hex.EncodeToString(raw)).Scan(&token)
+	return token, err
+}
+
+// Unshare revokes a collection's share token, or returns pgx.ErrNoRows
+func Unshare(ctx context.Context, pool *pgxpool.Pool, keyID int, id int64) error {
+	tag, err := pool.Exec(ctx, `
+		UPDATE collections SET share_token = NULL WHERE id = $1 AND key_id = $2
+	`, id, keyID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+func withItems(ctx context.Context, pool *pgxpool.Pool, b *Bundle) (*Bundle, error) {
+	var err error
+	if b.Items, err = items(ctx, pool, b.ID, 0); err != nil {
+		return nil, err
+	}
+	b.ItemCount = len(b.Items)
+	return b, nil
+}
+
+// querier is a pool or a transaction
+type querier interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+}
+
+// items returns a collection's items in the order they were added, or just
+// itemID. Documents of restricted datasets come without their summary
+// unless ctx allows it, as when a collection is read by its share link.
+func items(ctx context.Context, q querier, id, itemID int64) ([]Item, error) {
+	rows, err := q.Query(ctx, `
+		SELECT i.id, i.item_type, i.item_id, i.note, i.added_at,
+			   COALESCE(d.doc_id, e.canonical_name, p.title, ''),
+			   COALESCE(CASE WHEN `+store.Readable("d", "$3")+` THEN d.summary END, e.description, p.description, ''),
+			   d.id IS NULL AND e.id IS NULL AND p.id IS NULL
+		FROM collection_items i
+		LEFT JOIN documents d ON i.item_type = 'document' AND d.id = i.item_id
+		LEFT JOIN entities e ON i.item_type = 'entity' AND e.id = i.item_id
+		LEFT JOIN pattern_findings p ON i.item_type = 'pattern' AND p.id = i.item_id
+		WHERE i.collection_id = $1 AND ($2 = 0 OR i.id = $2)
+		ORDER BY i.id
+	`, id, itemID, store.CanReadRestricted(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []Item{}
+	for rows.Next() {
+		var it Item
+		if err := rows.Scan(&it.ID, &it.Type, &it.RefID, &it.Note, &it.AddedAt,
+			&it.Label, &it.Summary, &it.Missing); err != nil {
+			return nil, err
+		}
+		list = append(list, it)
+	}
+	return list, rows.Err()
+}
@@ -0,0 +1,295 @@
+// Package corrections keeps the cases opened by subjects, or their
+// counsel, who dispute what the database says about them: how an entity is
+// linked, to another person or to cross-reference records, or its
+// association with documents. A case moves from open to reviewing and is
+// then upheld or rejected by an admin, each step recorded as an event.
+// While a case is pending the records it names read as disputed, and once
+// upheld as corrected, so readers know to weigh them.
+package corrections
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Kinds
+const (
+	KindLinkage     = "linkage"     // the entity is wrongly linked or identified
+	KindAssociation = "association" // the entity is wrongly associated with documents
+)
+
+// Kinds lists every kind of request
+var Kinds = []string{KindLinkage, KindAssociation}
+
+// Relationships of the requester to the subject
+const (
+	RelationshipSubject = "subject"
+	RelationshipCounsel = "counsel"
+	RelationshipOther   = "other"
+)
+
+// Relationships lists every relationship
+var Relationships = []string{RelationshipSubject, RelationshipCounsel, RelationshipOther}
+
+// Statuses
+const (
+	StatusOpen      = "open"
+	StatusReviewing = "reviewing"
+	StatusUpheld    = "upheld"
+	StatusRejected  = "rejected"
+)
+
+// Statuses lists every case status
+var Statuses = []string{StatusOpen, StatusReviewing, StatusUpheld, StatusRejected}
+
+// Dispute statuses of the records a case names
+const (
+	DisputeDisputed  = "disputed"
+	DisputeCorrected = "corrected"
+)
+
+var (
+	// ErrNoReference is returned when a request names an entity or
+	// document that doesn't exist, or a document that doesn't mention the
+	// entity
+	ErrNoReference = errors.New("corrections: no such entity or document")
+	// ErrNotFound is returned for an unknown case or a wrong token
+	ErrNotFound = errors.New("corrections: not found")
+)
+
+// Request is a case as admins see it
+type Request struct {
+	ID           int64      `json:"id"`
+	Kind         string     `json:"kind" enum:"linkage,association"`
+	EntityID     int        `json:"entityId"`
+	DocumentIDs  []int      `json:"documentIds"`
+	Requester    string     `json:"requester"`
+	Relationship string     `json:"relationship" enum:"subject,counsel,other"`
+	Contact      string     `json:"contact"`
+	Body         string     `json:"body"`
+	KeyID        *int       `json:"keyId" doc:"API key of the submitter, if any"`
+	Status       string     `json:"status" enum:"open,reviewing,upheld,rejected"`
+	Resolution   *string    `json:"resolution" doc:"Note shown to the submitter"`
+	SubmittedAt  time.Time  `json:"submittedAt"`
+	UpdatedAt    time.Time  `json:"updatedAt"`
+	ResolvedAt   *time.Time `json:"resolvedAt"`
+	ResolvedBy   *int       `json:"resolvedBy"`
+	Events       []Event    `json:"events,omitempty" doc:"The case's history, oldest first"`
+}
+
+// Event is one step of a case
+type Event struct {
+	Status    string    `json:"status"`
+	Note      *string   `json:"note"`
+	KeyID     *int      `json:"keyId"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Case is a case as its submitter sees it
+type Case struct {
+	ID          int64      `json:"id"`
+	Kind        string     `json:"kind" enum:"linkage,association"`
+	EntityID    int        `json:"entityId"`
+	DocumentIDs []int      `json:"documentIds"`
+	Status      string     `json:"status" enum:"open,reviewing,upheld,rejected"`
+	Resolution  *string    `json:"resolution"`
+	SubmittedAt time.Time  `json:"submittedAt"`
+	UpdatedAt   time.Time  `json:"updatedAt"`
+	ResolvedAt  *time.Time `json:"resolvedAt"`
+}
+
+// Submission is a new request
+type Submission struct {
+	Kind         string
+	EntityID     int
+	DocumentIDs  []int
+	Requester    string
+	Relationship string
+	Contact      string
+	Body         string
+	KeyID        int // 0 when anonymous
+}
+
+const columns = `id, kind, entity_id, document_ids, requester, relationship, contact, body, key_id,
+	status, resolution, submitted_at, updated_at, resolved_at, resolved_by`
+
+func scan(row pgx.Row) (*Request, error) {
+	var r Request
+	err := row.Scan(&r.ID, &r.Kind, &r.EntityID, &r.DocumentIDs, &r.Requester, &r.Relationship, &r.Contact,
+		&r.Body, &r.KeyID, &r.Status, &r.Resolution, &r.SubmittedAt, &r.UpdatedAt, &r.ResolvedAt, &r.ResolvedBy)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// Submit opens a case and returns it with the token its submitter follows
+// it with. It returns ErrNoReference if the entity doesn't exist or a
+// document doesn't mention it.
+func Submit(ctx context.Context, pool *pgxpool.Pool, s Submission) (*Request, string, error) {
+	var ok bool
+	err := pool.QueryRow(ctx, `
+		SELECT EXISTS (SELECT 1 FROM entities WHERE id = $1 AND deleted_at IS NULL)
+		   AND (SELECT COUNT(*) FROM document_entities WHERE entity_id = $1 AND document_id = ANY($2)) = cardinality($2)
+	`, s.EntityID, s.DocumentIDs).Scan(&ok)
+	if err != nil {
+		return nil, "", err
+	}
+	if !ok {
+		return nil, "", ErrNoReference
+	}
+
+	raw := make([]byte, 24)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, "", err
+	}
+	token := hex.EncodeToString(raw)
+
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+	defer tx.Rollback(ctx)
+
+	r, err := scan(tx.QueryRow(ctx, `
+		INSERT INTO correction_requests
+			(kind, entity_id, document_ids, requester, relationship, contact, body, key_id, token)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, NULLIF($8, 0), $9)
+		RETURNING `+columns,
+		s.Kind, s.EntityID, s.DocumentIDs, s.Requester, s.Relationship, s.Contact, s.Body, s.KeyID, token))
+	if err != nil {
+		return nil, "", err
+	}
+	if err := record(ctx, tx, r, 0, ""); err != nil {
+		return nil, "", err
+	}
+	return r, token, tx.Commit(ctx)
+}
+
+// record adds an event for r's status and touches the records r names, so
+// that cached copies pick up their new dispute status
+func record(ctx context.Context, tx pgx.Tx, r *Request, keyID int, note string) error {
+	_, err := tx.Exec(ctx, `
+		INSERT INTO correction_events (request_id, status, note, key_id)
+		VALUES ($1, $2, NULLIF($3, ''), NULLIF($4, 0))
+	`, r.ID, r.Status, note, keyID)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, `UPDATE entities SET updated_at = NOW() WHERE id = $1`, r.EntityID); err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx, `UPDATE documents SET updated_at = NOW() WHERE id = ANY($1)`, r.DocumentIDs)
+	return err
+}
+
+// Lookup returns the case with token, or ErrNotFound
+func Lookup(ctx context.Context, pool *pgxpool.Pool, id int64, token string) (*Case, error) {
+	var c Case
+	var stored string
+	err := pool.QueryRow(ctx, `
+		SELECT id, kind, entity_id, document_ids, status, resolution, submitted_at, updated_at, resolved_at, token
+		FROM correction_requests WHERE id = $1
+	`, id).Scan(&c.ID, &c.Kind, &c.EntityID, &c.DocumentIDs, &c.Status, &c.Resolution,
+		&c.SubmittedAt, &c.UpdatedAt, &c.ResolvedAt, &stored)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare([]byte(stored), []byte(token)) != 1 {
+		return nil, ErrNotFound
+	}
+	return &c, nil
+}
+
+// List returns one page of cases, optionally in one status, oldest first
+// so the queue is worked in order
+func List(ctx context.Context, pool *pgxpool.Pool, status string, limit, offset int) ([]Request, error) {
+	rows, err := pool.Query(ctx, `
+		SELECT `+columns+`
+		FROM correction_requests
+		WHERE $1 = '' OR status = $1
+		ORDER BY submitted_at, id
+		LIMIT $2 OFFSET $3
+	`, status, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []Request{}
+	for rows.Next() {
+		r, err := scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *r)
+	}
+	return list, rows.Err()
+}
+
+// Get returns one case with its history, or ErrNotFound
+func Get(ctx context.Context, pool *pgxpool.Pool, id int64) (*Request, error) {
+	r, err := scan(pool.QueryRow(ctx, `SELECT `+columns+` FROM correction_requests WHERE id = $1`, id))
+	if err != nil {
+		return nil, err
+	}
+	rows, err := pool.Query(ctx, `
+		SELECT status, note, key_id, created_at
+		FROM correction_events WHERE request_id = $1
+		ORDER BY created_at, id
+	`, id)
+	if err != nil {
+		return nil, err
+	}
+	r.Events, err = pgx.CollectRows(rows, func(row pgx.CollectableRow) (Event, error) {
+		var e Event
+		return e, row.Scan(&e.Status, &e.Note, &e.KeyID, &e.CreatedAt)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Resolve moves a case to status as reviewerKey, with an internal note and,
+// when resolution isn't empty, the note shown to the submitter. Upholding
+// or rejecting a case resolves it; moving it back to open or reviewing
+// reopens it. It returns ErrNotFound for an unknown case.
+func Resolve(ctx context.Context, pool *pgxpool.Pool, id int64, reviewerKey int, status, note, resolution string) (*Request, error) {
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	r, err := scan(tx.QueryRow(ctx, `
+		UPDATE correction_requests SET
+			status = $2,
+			resolution = COALESCE(NULLIF($3, ''), resolution),
+			updated_at = NOW(),
+			resolved_at = CASE WHEN $2 IN ('upheld', 'rejected') THEN NOW() END,
+			resolved_by = CASE WHEN $2 IN ('upheld', 'rejected') THEN NULLIF($4, 0) END
+		WHERE id = $1
+		RETURNING `+columns,
+		id, status, resolution, reviewerKey))
+	if err != nil {
+		return nil, err
+	}
+	if err := record(ctx, tx, r, reviewerKey, note); err != nil {
+		return nil, err
+	}
+	return r, tx.Commit(ctx)
+}
@@ -0,0 +1,233 @@
+// Package dates finds the dates a document's text mentions, in the many
+// formats the OCR'd releases use, and normalizes them to the range of days
+// they cover: an exact date is one day, "March 2005" a month, "in 2005" or
+// "1999-2003" whole years. Each date has a confidence, lower for vaguer and
+// ambiguous forms such as 03/04/2005, which could be either of two days.
+//
+// A document's range runs from the earliest to the latest date it
+// mentions. Documents ingested without dates, or whose analysis found
+// none, get that range as their date_earliest and date_latest when it is
+// confident enough.
+package dates
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Confidence levels
+const (
+	LevelHigh   = "high"   // 0.8 and up: exact dates and named months
+	LevelMedium = "medium" // 0.6 and up: numeric months, years and year ranges
+	LevelLow    = "low"    // ambiguous numeric dates
+)
+
+// FillConfidence is the least confidence a range needs to be copied to a
+// document's dates
+const FillConfidence = 0.6
+
+// highConfidence is where LevelHigh starts
+const highConfidence = 0.8
+
+// Range is the span of the dates a text mentions
+type Range struct {
+	Earliest   time.Time
+	Latest     time.Time
+	Precision  string  // the coarser of the two bounds'
+	Confidence float64 // the lower of the two bounds'
+	Ambiguous  bool    // either bound is ambiguous
+	Mentions   int     // dates found
+}
+
+// Normalize returns the range of dates, or false if there are none. When
+// some dates are highly confident only those count, so that a stray "in
+// 2005" doesn't widen an exactly dated letter to the whole year.
+func Normalize(dates []Date) (Range, bool) {
+	if len(dates) == 0 {
+		return Range{}, false
+	}
+
+	use := dates
+	var confident []Date
+	for _, d := range dates {
+		if d.Confidence >= highConfidence {
+			confident = append(confident, d)
+		}
+	}
+	if len(confident) > 0 {
+		use = confident
+	}
+
+	first, last := use[0], use[0]
+	for _, d := range use[1:] {
+		if d.Earliest.Before(first.Earliest) {
+			first = d
+		}
+		if d.Latest.After(last.Latest) {
+			last = d
+		}
+	}
+
+	r := Range{
+		Earliest:   first.Earliest,
+		Latest:     last.Latest,
+		Precision:  coarser(first.Precision, last.Precision),
+		Confidence: min(first.Confidence, last.Confidence),
+		Ambiguous:  first.Ambiguous || last.Ambiguous,
+		Mentions:   len(dates),
+	}
+	return r, true
+}
+
+// coarser returns the less precise of two precisions
+func coarser(a, b string) string {
+	rank := map[string]int{PrecisionDay: 0, PrecisionMonth: 1, PrecisionYear: 2}
+	if rank[b] > rank[a] {
+		return b
+	}
+	return a
+}
+
+// Update records the dates in a document's text and fills the document's
+// dates from them if it has none, or if they were filled from an earlier
+// version of its text. It reports whether the document's dates are now
+// the ones found.
+func Update(ctx context.Context, tx pgx.Tx, documentID int, text string) (bool, error) {
+	r, found := Normalize(Extract(text))
+
+	var prev struct {
+		applied          bool
+		earliest, latest *time.Time
+	}
+	err := tx.QueryRow(ctx, `
+		SELECT applied, earliest, latest FROM document_dates WHERE document_id = $1
+	`, documentID).Scan(&prev.applied, &prev.earliest, &prev.latest)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return false, err
+	}
+
+	var earliest, latest *time.Time
+	if found && r.Confidence >= FillConfidence {
+		earliest, latest = &r.Earliest, &r.Latest
+	}
+	tag, err := tx.Exec(ctx, `
+		UPDATE documents SET date_earliest = $2::date, date_latest = $3::date
+		WHERE id = $1
+		  AND (($2::date IS NOT NULL AND date_earliest IS NULL AND date_latest IS NULL)
+		       OR ($4 AND date_earliest IS NOT DISTINCT FROM $5::date AND date_latest IS NOT DISTINCT FROM $6::date))
+	`, documentID, earliest, latest, prev.applied, prev.earliest, prev.latest)
+	if err != nil {
+		return false, err
+	}
+	applied := earliest != nil && tag.RowsAffected() > 0
+
+	var precision *string
+	var confidence *float64
+	if found {
+		precision, confidence = &r.Precision, &r.Confidence
+	} else {
+		r = Range{}
+	}
+	// Stored after the update above, in the same transaction, so the
+	// document's updated_at isn't later than computed_at
+	_, err = tx.Exec(ctx, `
+		INSERT INTO document_dates
+			(document_id, earliest, latest, precision, confidence, ambiguous, mentions, applied)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (document_id) DO UPDATE SET
+			earliest = EXCLUDED.earliest, latest = EXCLUDED.latest,
+			precision = EXCLUDED.precision, confidence = EXCLUDED.confidence,
+			ambiguous = EXCLUDED.ambiguous, mentions = EXCLUDED.mentions,
+			applied = EXCLUDED.applied, computed_at = NOW()
+	`, documentID, nullDate(found, r.Earliest), nullDate(found, r.Latest),
+		precision, confidence, r.Ambiguous, r.Mentions, applied)
+	return applied, err
+}
+
+func nullDate(ok bool, t time.Time) *time.Time {
+	if !ok {
+		return nil
+	}
+	return &t
+}
+
+// Result summarizes a run
+type Result struct {
+	Documents  int `json:"documents" doc:"Documents read"`
+	Dated      int `json:"dated" doc:"Documents mentioning a date, this run or earlier"`
+	Filled     int `json:"filled" doc:"Documents whose dates are the ones found"`
+	DurationMs int `json:"durationMs"`
+}
+
+// batchSize bounds the documents read per query
+const batchSize = 500
+
+// staleSQL reads the next batch of documents after $2 whose text changed
+// since their dates were found, or every document if $1
+const staleSQL = `
+	SELECT d.id, COALESCE(d.full_text, '')
+	FROM documents d
+	LEFT JOIN document_dates dd ON dd.document_id = d.id
+	WHERE d.id > $2
+	  AND ($1 OR dd.document_id IS NULL OR COALESCE(d.updated_at, d.created_at) > dd.computed_at)
+	ORDER BY d.id
+	LIMIT $3
+`
+
+// Run finds the dates of the documents whose text changed since the last
+// run, or of every document when force is set. Documents ingested before
+// this package existed are backfilled by the first run.
+func Run(ctx context.Context, pool *pgxpool.Pool, force bool) (*Result, error) {
+	started := time.Now()
+	result := &Result{}
+
+	after := 0
+	for {
+		rows, err := pool.Query(ctx, staleSQL, force, after, batchSize)
+		if err != nil {
+			return nil, err
+		}
+		type doc struct {
+			id   int
+			text string
+		}
+		docs, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (doc, error) {
+			var d doc
+			return d, row.Scan(&d.id, &d.text)
+		})
+		if err != nil {
+			return nil, err
+		}
+		if len(docs) == 0 {
+			break
+		}
+
+		for _, d := range docs {
+			var applied bool
+			err := pgx.BeginFunc(ctx, pool, func(tx pgx.Tx) error {
+				var err error
+				applied, err = Update(ctx, tx, d.id, d.text)
+				return err
+			})
+			if err != nil {
+				return nil, err
+			}
+			result.Documents++
+			if applied {
+				result.Filled++
+			}
+		}
+		after = docs[len(docs)-1].id
+	}
+
+	err := pool.QueryRow(ctx, `SELECT COUNT(*) FROM document_dates WHERE mentions > 0`).Scan(&result.Dated)
+	if err != nil {
+		return nil, err
+	}
+	result.DurationMs = int(time.Since(started).Milliseconds())
+	return result, nil
+}
@@ -0,0 +1,217 @@
+package dates
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Precisions
+const (
+	PrecisionDay   = "day"
+	PrecisionMonth = "month"
+	PrecisionYear  = "year"
+)
+
+// MinYear is the earliest year taken as a date. Earlier four-digit numbers
+// in the corpus are nearly all case numbers, amounts and addresses.
+const MinYear = 1900
+
+// Date is one date found in a text. Earliest and Latest are the same day
+// for an exact date, and span the month, the year or the years otherwise.
+type Date struct {
+	Text       string    `json:"text"`
+	Earliest   time.Time `json:"earliest"`
+	Latest     time.Time `json:"latest"`
+	Precision  string    `json:"precision"`
+	Confidence float64   `json:"confidence"`
+	Ambiguous  bool      `json:"ambiguous,omitempty" doc:"Day and month could be swapped; the range covers both readings"`
+}
+
+// month matches an English month name or abbreviation
+const month = `(jan(?:uary)?|feb(?:ruary)?|mar(?:ch)?|apr(?:il)?|may|june?|july?|aug(?:ust)?|sep(?:t(?:ember)?)?|oct(?:ober)?|nov(?:ember)?|dec(?:ember)?)`
+
+// format is one way of writing a date. Formats are tried in order and
+// later ones skip text an earlier one matched, so "March 3, 2005" isn't
+// also read as March 2005.
+type format struct {
+	re    *regexp.Regexp
+	parse func(m []string) (Date, bool)
+}
+
+var formats = []format{
+	// 2005-03-14
+	{regexp.MustCompile(`\b(\d{4})-(\d{2})-(\d{2})\b`), func(m []string) (Date, bool) {
+		return day(atoi(m[1]), atoi(m[2]), atoi(m[3]), 0.95)
+	}},
+	// March 14, 2005; Mar. 14th 2005
+	{regexp.MustCompile(`(?i)\b` + month + `\.?\s+(\d{1,2})(?:st|nd|rd|th)?,?\s+(\d{4})\b`), func(m []string) (Date, bool) {
+		return day(atoi(m[3]), monthNumber(m[1]), atoi(m[2]), 0.95)
+	}},
+	// 14 March 2005; 14th of March, 2005
+	{regexp.MustCompile(`(?i)\b(\d{1,2})(?:st|nd|rd|th)?\s+(?:of\s+)?` + month + `\.?,?\s+(\d{4})\b`), func(m []string) (Date, bool) {
+		return day(atoi(m[3]), monthNumber(m[2]), atoi(m[1]), 0.9)
+	}},
+	// 03/14/2005, 14.03.2005, 3-14-05
+	{regexp.MustCompile(`\b(\d{1,2})[/.-](\d{1,2})[/.-](\d{4}|\d{2})\b`), numeric},
+	// March 2005; March of 2005
+	{regexp.MustCompile(`(?i)\b` + month + `\.?,?\s+(?:of\s+)?(\d{4})\b`), func(m []string) (Date, bool) {
+		return monthOf(atoi(m[2]), monthNumber(m[1]), 0.8)
+	}},
+	// 03/2005
+	{regexp.MustCompile(`\b(\d{1,2})/(\d{4})\b`), func(m []string) (Date, bool) {
+		return monthOf(atoi(m[2]), atoi(m[1]), 0.7)
+	}},
+	// between 1999 and 2003
+	{regexp.MustCompile(`(?i)\bbetween\s+(\d{4})\s+and\s+(\d{4})\b`), func(m []string) (Date, bool) {
+		return years(atoi(m[1]), atoi(m[2]), 0.7)
+	}},
+	// 1999-2003; from 1999 to 2003
+	{regexp.MustCompile(`(?i)\b(\d{4})\s*(?:-|–|—|to|through|until)\s*(\d{4})\b`), func(m []string) (Date, bool) {
+		return years(atoi(m[1]), atoi(m[2]), 0.7)
+	}},
+	// in 2004; during 2004; circa 2004; mid-2004. A bare four-digit number
+	// is as often a count or an amount, so a year needs one of these cues.
+	{regexp.MustCompile(`(?i)\b(?:(?:in|during|circa|around|early|late|year|fy)\s+|(?:c\.|mid-)\s*)(\d{4})\b`), func(m []string) (Date, bool) {
+		return years(atoi(m[1]), atoi(m[1]), 0.6)
+	}},
+}
+
+// Extract returns the dates in text, in the order they appear
+func Extract(text string) []Date {
+	type found struct {
+		start, end int
+		date       Date
+	}
+	var all []found
+	taken := func(start, end int) bool {
+		for _, f := range all {
+			if start < f.end && f.start < end {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, f := range formats {
+		for _, loc := range f.re.FindAllStringSubmatchIndex(text, -1) {
+			if taken(loc[0], loc[1]) {
+				continue
+			}
+			m := make([]string, len(loc)/2)
+			for i := range m {
+				if loc[2*i] >= 0 {
+					m[i] = text[loc[2*i]:loc[2*i+1]]
+				}
+			}
+			d, ok := f.parse(m)
+			if !ok {
+				continue
+			}
+			d.Text = m[0]
+			all = append(all, found{loc[0], loc[1], d})
+		}
+	}
+
+	sort.Slice(all, func(i, j int) bool { return all[i].start < all[j].start })
+	dates := make([]Date, len(all))
+	for i, f := range all {
+		dates[i] = f.date
+	}
+	return dates
+}
+
+// numeric reads an all-numeric date. Month first is assumed, as in most of
+// the corpus, unless the first number can't be a month; when both can, the
+// date is ambiguous and spans both readings.
+func numeric(m []string) (Date, bool) {
+	a, b, y := atoi(m[1]), atoi(m[2]), atoi(m[3])
+	confidence := 0.85
+	if len(m[3]) == 2 {
+		// Two-digit years: 00-29 are this century
+		if y < 30 {
+			y += 2000
+		} else {
+			y += 1900
+		}
+		confidence = 0.75
+	}
+
+	us, usOK := day(y, a, b, confidence)
+	eu, euOK := day(y, b, a, confidence)
+	switch {
+	case usOK && euOK && a != b:
+		d := us
+		if eu.Earliest.Before(d.Earliest) {
+			d.Earliest = eu.Earliest
+		}
+		if eu.Latest.After(d.Latest) {
+			d.Latest = eu.Latest
+		}
+		d.Confidence = 0.5
+		d.Ambiguous = true
+		return d, true
+	case usOK:
+		return us, true
+	case euOK:
+		return eu, true
+	}
+	return Date{}, false
+}
+
+// day is an exact date, if it exists
+func day(y, m, d int, confidence float64) (Date, bool) {
+	if !validYear(y) || m < 1 || m > 12 || d < 1 {
+		return Date{}, false
+	}
+	t := time.Date(y, time.Month(m), d, 0, 0, 0, 0, time.UTC)
+	if t.Day() != d {
+		return Date{}, false // February 30th
+	}
+	return Date{Earliest: t, Latest: t, Precision: PrecisionDay, Confidence: confidence}, true
+}
+
+// monthOf is a whole month
+func monthOf(y, m int, confidence float64) (Date, bool) {
+	if !validYear(y) || m < 1 || m > 12 {
+		return Date{}, false
+	}
+	t := time.Date(y, time.Month(m), 1, 0, 0, 0, 0, time.UTC)
+	return Date{Earliest: t, Latest: t.AddDate(0, 1, -1), Precision: PrecisionMonth, Confidence: confidence}, true
+}
+
+// years spans whole years, from to through
+func years(from, through int, confidence float64) (Date, bool) {
+	if !validYear(from) || !validYear(through) || through < from {
+		return Date{}, false
+	}
+	return Date{
+		Earliest:   time.Date(from, 1, 1, 0, 0, 0, 0, time.UTC),
+		Latest:     time.Date(through, 12, 31, 0, 0, 0, 0, time.UTC),
+		Precision:  PrecisionYear,
+		Confidence: confidence,
+	}, true
+}
+
+// validYear rules out years before MinYear and after next year
+func validYear(y int) bool {
+	return y >= MinYear && y <= time.Now().Year()+1
+}
+
+// monthNumber returns the month a name matched by month stands for
+func monthNumber(name string) int {
+	prefix := strings.ToLower(name[:3])
+	for m := time.January; m <= time.December; m++ {
+		if strings.ToLower(m.String()[:3]) == prefix {
+			return int(m)
+		}
+	}
+	return 0
+}
+
+func atoi(s string) int {
+	n, _ := strconv.Atoi(s)
+	return n
+}
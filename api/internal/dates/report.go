@@ -0,0 +1,102 @@
+package dates
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// sampleSize is how many document IDs each report sample holds
+const sampleSize = 20
+
+// Report shows how well the corpus is dated and where the dates came from
+type Report struct {
+	Documents   int            `json:"documents"`
+	Dated       int            `json:"dated" doc:"Documents with date_earliest or date_latest"`
+	Undated     int            `json:"undated"`
+	Read        int            `json:"read" doc:"Documents whose text has been searched for dates"`
+	Mentioning  int            `json:"mentioning" doc:"Documents whose text mentions a date"`
+	Filled      int            `json:"filled" doc:"Documents dated from their text rather than by analysis or an editor"`
+	Unconfident int            `json:"unconfident" doc:"Undated documents whose dates were too uncertain to fill"`
+	Ambiguous   int            `json:"ambiguous" doc:"Documents whose range starts or ends at an ambiguous numeric date"`
+	Disagreeing int            `json:"disagreeing" doc:"Documents dated otherwise whose dates fall outside the range found in their text"`
+	Precision   map[string]int `json:"precision" doc:"Documents mentioning a date, by the precision of their range"`
+	Confidence  map[string]int `json:"confidence" doc:"Documents mentioning a date, by confidence level: high, medium or low"`
+	Samples     ReportSamples  `json:"samples"`
+}
+
+// ReportSamples are IDs of documents worth checking by hand
+type ReportSamples struct {
+	Ambiguous   []int `json:"ambiguous"`
+	Unconfident []int `json:"unconfident"`
+	Disagreeing []int `json:"disagreeing"`
+}
+
+// disagreeSQL matches documents whose own dates fall outside the range
+// their text mentions
+const disagreeSQL = `
+	NOT dd.applied AND dd.earliest IS NOT NULL
+	AND (d.date_earliest < dd.earliest OR d.date_latest > dd.latest
+	     OR d.date_earliest > dd.latest OR d.date_latest < dd.earliest)`
+
+// unconfidentSQL matches undated documents whose range wasn't filled
+const unconfidentSQL = `
+	d.date_earliest IS NULL AND d.date_latest IS NULL
+	AND dd.earliest IS NOT NULL AND NOT dd.applied`
+
+// BuildReport reads the normalization report
+func BuildReport(ctx context.Context, pool *pgxpool.Pool) (*Report, error) {
+	r := &Report{}
+	var day, month, year, high, medium, low int
+	err := pool.QueryRow(ctx, `
+		SELECT COUNT(*),
+			   COUNT(*) FILTER (WHERE d.date_earliest IS NOT NULL OR d.date_latest IS NOT NULL),
+			   COUNT(dd.document_id),
+			   COUNT(*) FILTER (WHERE dd.mentions > 0),
+			   COUNT(*) FILTER (WHERE dd.applied),
+			   COUNT(*) FILTER (WHERE `+unconfidentSQL+`),
+			   COUNT(*) FILTER (WHERE dd.ambiguous),
+			   COUNT(*) FILTER (WHERE `+disagreeSQL+`),
+			   COUNT(*) FILTER (WHERE dd.precision = 'day'),
+			   COUNT(*) FILTER (WHERE dd.precision = 'month'),
+			   COUNT(*) FILTER (WHERE dd.precision = 'year'),
+			   COUNT(*) FILTER (WHERE dd.confidence >= $1),
+			   COUNT(*) FILTER (WHERE dd.confidence >= $2 AND dd.confidence < $1),
+			   COUNT(*) FILTER (WHERE dd.confidence < $2)
+		FROM documents d
+		LEFT JOIN document_dates dd ON dd.document_id = d.id
+	`, highConfidence, FillConfidence).Scan(
+		&r.Documents, &r.Dated, &r.Read, &r.Mentioning, &r.Filled, &r.Unconfident, &r.Ambiguous, &r.Disagreeing,
+		&day, &month, &year, &high, &medium, &low)
+	if err != nil {
+		return nil, err
+	}
+	r.Precision = map[string]int{PrecisionDay: day, PrecisionMonth: month, PrecisionYear: year}
+	r.Confidence = map[string]int{LevelHigh: high, LevelMedium: medium, LevelLow: low}
+	r.Undated = r.Documents - r.Dated
+
+	for _, s := range []struct {
+		ids   *[]int
+		where string
+	}{
+		{&r.Samples.Ambiguous, "dd.ambiguous"},
+		{&r.Samples.Unconfident, unconfidentSQL},
+		{&r.Samples.Disagreeing, disagreeSQL},
+	} {
+		rows, err := pool.Query(ctx, `
+			SELECT d.id FROM documents d
+			JOIN document_dates dd ON dd.document_id = d.id
+			WHERE `+s.where+`
+			ORDER BY d.id
+			LIMIT $1
+		`, sampleSize)
+		if err != nil {
+			return nil, err
+		}
+		if *s.ids, err = pgx.CollectRows(rows, pgx.RowTo[int]); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
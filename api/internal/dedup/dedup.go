@@ -0,0 +1,294 @@
+package dedup
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/subculture-collective/epstein-db/api/internal/llm"
+)
+
+const systemPrompt = `You are an expert at identifying when two name records refer to the same real-world entity. You are given two entities extracted from documents related to the Jeffrey Epstein case, along with their known aliases and the passages where they are mentioned.
+
+Consider:
+- Name variations (J. Smith, John Smith, John Q. Smith)
+- Nicknames and aliases
+- Typos and OCR errors
+- Contradicting context (different roles, places, or time periods)
+
+Be conservative - only answer "same" when the context supports it. Two different people can share a name.`
+
+// Config controls which candidate pairs the worker considers
+type Config struct {
+	EntityType    string
+	MinSimilarity float64
+	BatchSize     int
+	SnippetLimit  int
+	MaxAttempts   int           // failed judgements of a pair before it is skipped
+	Backoff       time.Duration // wait after a pair's first failure, doubled after each
+}
+
+// DefaultConfig returns the settings used by the worker command
+func DefaultConfig() Config {
+	return Config{
+		EntityType:    "person",
+		MinSimilarity: 0.6,
+		BatchSize:     50,
+		SnippetLimit:  5,
+		MaxAttempts:   5,
+		Backoff:       time.Hour,
+	}
+}
+
+// Stats summarizes a worker run
+type Stats struct {
+	Candidates int
+	Same       int
+	Different  int
+	Uncertain  int
+	Failed     int
+}
+
+// Worker asks the LLM to judge high-similarity entity pairs and records its
+// decisions in dedup_review_queue for human review
+type Worker struct {
+	pool   *pgxpool.Pool
+	client llm.Client
+	cfg    Config
+}
+
+// NewWorker creates a dedup worker
+func NewWorker(pool *pgxpool.Pool, client llm.Client, cfg Config) *Worker {
+	return &Worker{pool: pool, client: client, cfg: cfg}
+}
+
+type candidate struct {
+	aID, bID     int
+	aName, bName string
+	similarity   float64
+}
+
+type entityContext struct {
+	Name     string
+	Aliases  []string
+	Snippets []string
+}
+
+type verdict struct {
+	Decision   string  `json:"decision"`
+	Confidence float64 `json:"confidence"`
+	Reasoning  string  `json:"reasoning"`
+}
+
+// Run processes one batch of candidate pairs
+func (w *Worker) Run(ctx context.Context) (Stats, error) {
+	var stats Stats
+
+	candidates, err := w.candidates(ctx)
+	if err != nil {
+		return stats, err
+	}
+	stats.Candidates = len(candidates)
+
+	for _, cand := range candidates {
+		if ctx.Err() != nil {
+			return stats, ctx.Err()
+		}
+
+		v, model, err := w.judge(ctx, cand)
+		if err != nil {
+			log.Printf("dedup: %d/%d: %v", cand.aID, cand.bID, err)
+			stats.Failed++
+			if err := w.recordFailure(ctx, cand, err); err != nil {
+				return stats, err
+			}
+			continue
+		}
+
+		if err := w.record(ctx, cand, v, model); err != nil {
+			return stats, err
+		}
+
+		switch v.Decision {
+		case "same":
+			stats.Same++
+		case "different":
+			stats.Different++
+		default:
+			stats.Uncertain++
+		}
+	}
+
+	return stats, nil
+}
+
+func (w *Worker) candidates(ctx context.Context) ([]candidate, error) {
+	rows, err := w.pool.Query(ctx, `
+		SELECT a.id, a.canonical_name, b.id, b.canonical_name,
+			   similarity(a.canonical_name, b.canonical_name) AS score
+		FROM entities a
+		JOIN entities b ON a.entity_type = b.entity_type
+			AND a.id < b.id
+			AND a.canonical_name % b.canonical_name
+		WHERE a.entity_type = $1::entity_type
+		  AND similarity(a.canonical_name, b.canonical_name) >= $2
+		  AND NOT EXISTS (
+			SELECT 1 FROM dedup_review_queue q
+			WHERE q.entity_a_id = a.id AND q.entity_b_id = b.id
+		  )
+		  AND NOT EXISTS (
+			SELECT 1 FROM dedup_failures f
+			WHERE f.entity_a_id = a.id AND f.entity_b_id = b.id
+			  AND (f.attempts >= $4 OR f.next_attempt_at > NOW())
+		  )
+		ORDER BY score DESC
+		LIMIT $3
+	`, w.cfg.EntityType, w.cfg.MinSimilarity, w.cfg.BatchSize, w.cfg.MaxAttempts)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var candidates []candidate
+	for rows.Next() {
+		var cand candidate
+		if err := rows.Scan(&cand.aID, &cand.aName, &cand.bID, &cand.bName, &cand.similarity); err != nil {
+			return nil, err
+		}
+		candidates = append(candidates, cand)
+	}
+
+	return candidates, rows.Err()
+}
+
+func (w *Worker) loadContext(ctx context.Context, id int, name string) (entityContext, error) {
+	ec := entityContext{Name: name}
+
+	err := w.pool.QueryRow(ctx, `
+		SELECT COALESCE(array_agg(DISTINCT alias), '{}')
+		FROM (
+			SELECT jsonb_array_elements_text(aliases) AS alias FROM entities WHERE id = $1
+			UNION
+			SELECT original_name FROM entity_aliases WHERE entity_id = $1
+		) a
+	`, id).Scan(&ec.Aliases)
+	if err != nil {
+		return ec, err
+	}
+
+	rows, err := w.pool.Query(ctx, `
+		SELECT d.doc_id, COALESCE(d.summary, ''), COALESCE(de.context_snippet, '')
+		FROM document_entities de
+		JOIN documents d ON d.id = de.document_id
+		WHERE de.entity_id = $1
+		ORDER BY de.mention_count DESC
+		LIMIT $2
+	`, id, w.cfg.SnippetLimit)
+	if err != nil {
+		return ec, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var docID, summary, snippet string
+		if err := rows.Scan(&docID, &summary, &snippet); err != nil {
+			return ec, err
+		}
+		ec.Snippets = append(ec.Snippets, fmt.Sprintf("[%s] %s %s", docID, summary, snippet))
+	}
+
+	return ec, rows.Err()
+}
+
+func (w *Worker) judge(ctx context.Context, cand candidate) (verdict, string, error) {
+	var v verdict
+
+	a, err := w.loadContext(ctx, cand.aID, cand.aName)
+	if err != nil {
+		return v, "", err
+	}
+	b, err := w.loadContext(ctx, cand.bID, cand.bName)
+	if err != nil {
+		return v, "", err
+	}
+
+	resp, err := w.client.Complete(ctx, llm.UserPrompt(systemPrompt, buildPrompt(a, b), 1024))
+	if err != nil {
+		return v, "", err
+	}
+
+	if err := llm.DecodeJSON(resp.Text, &v); err != nil {
+		return v, "", err
+	}
+
+	switch v.Decision {
+	case "same", "different", "uncertain":
+	default:
+		v.Decision = "uncertain"
+	}
+
+	return v, resp.Model, nil
+}
+
+func buildPrompt(a, b entityContext) string {
+	var sb strings.Builder
+
+	sb.WriteString("Do these two records refer to the same entity?\n\n")
+	for i, ec := range []entityContext{a, b} {
+		fmt.Fprintf(&sb, "<entity_%c>\n", 'a'+i)
+		fmt.Fprintf(&sb, "Name: %s\n", ec.Name)
+		if len(ec.Aliases) > 0 {
+			fmt.Fprintf(&sb, "Aliases: %s\n", strings.Join(ec.Aliases, "; "))
+		}
+		if len(ec.Snippets) > 0 {
+			sb.WriteString("Mentions:\n")
+			for _, s := range ec.Snippets {
+				fmt.Fprintf(&sb, "- %s\n", s)
+			}
+		}
+		fmt.Fprintf(&sb, "</entity_%c>\n\n", 'a'+i)
+	}
+
+	sb.WriteString(`Respond with a JSON object:
+{
+  "decision": "same|different|uncertain",
+  "confidence": 0.0-1.0,
+  "reasoning": "short explanation citing the evidence"
+}
+
+Return ONLY valid JSON.`)
+
+	return sb.String()
+}
+
+func (w *Worker) record(ctx context.Context, cand candidate, v verdict, model string) error {
+	_, err := w.pool.Exec(ctx, `
+		WITH judged AS (
+			DELETE FROM dedup_failures WHERE entity_a_id = $1 AND entity_b_id = $2
+		)
+		INSERT INTO dedup_review_queue
+			(entity_a_id, entity_b_id, similarity, decision, confidence, reasoning, model)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (entity_a_id, entity_b_id) DO NOTHING
+	`, cand.aID, cand.bID, cand.similarity, v.Decision, v.Confidence, v.Reasoning, model)
+	return err
+}
+
+// recordFailure counts a failed judgement of a pair and schedules its next
+// attempt, the backoff doubling each time
+func (w *Worker) recordFailure(ctx context.Context, cand candidate, judgeErr error) error {
+	_, err := w.pool.Exec(ctx, `
+		INSERT INTO dedup_failures (entity_a_id, entity_b_id, error, next_attempt_at)
+		VALUES ($1, $2, $3, NOW() + $4 * INTERVAL '1 millisecond')
+		ON CONFLICT (entity_a_id, entity_b_id) DO UPDATE
+		SET attempts = dedup_failures.attempts + 1,
+			error = EXCLUDED.error,
+			next_attempt_at = NOW() + $4 * INTERVAL '1 millisecond' * power(2, dedup_failures.attempts),
+			updated_at = NOW()
+	`, cand.aID, cand.bID, judgeErr.Error(), w.cfg.Backoff.Milliseconds())
+	return err
+}
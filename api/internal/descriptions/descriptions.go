@@ -0,0 +1,166 @@
+// Package descriptions keeps the history of entity descriptions. Every
+// version is a revision recording whether a person or a model wrote it,
+// what it is based on and which API key saved it. Descriptions written by
+// extraction are recorded by a database trigger; edits through the API
+// record their revision first. Reverting saves an old revision's text as a
+// new revision, so history is never rewritten.
+package descriptions
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Origins
+const (
+	OriginHuman = "human"
+	OriginLLM   = "llm"
+)
+
+// Origins lists who can write a description
+var Origins = []string{OriginHuman, OriginLLM}
+
+// ErrConflict is returned when the description changed since the revision
+// an edit was based on
+var ErrConflict = errors.New("descriptions: changed since the base revision")
+
+// Revision is one version of an entity's description
+type Revision struct {
+	Revision     int       `json:"revision"`
+	Description  *string   `json:"description" doc:"Null when the description was cleared"`
+	Origin       string    `json:"origin" enum:"human,llm"`
+	Attribution  *string   `json:"attribution" doc:"Sources, a URL or the model the text is based on"`
+	Comment      *string   `json:"comment"`
+	RevertedFrom *int      `json:"revertedFrom" doc:"The revision this one restored"`
+	AuthorKey    *int      `json:"authorKey" doc:"API key that saved it; null for extraction"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+// Edit is a new version of a description
+type Edit struct {
+	Description  *string
+	Origin       string
+	Attribution  string
+	Comment      string
+	BaseRevision int // the revision edited, checked when not 0
+	AuthorKey    int
+	revertedFrom int
+}
+
+const columns = `revision, description, origin, attribution, comment, reverted_from, author_key, created_at`
+
+func scan(row pgx.Row) (Revision, error) {
+	var r Revision
+	err := row.Scan(&r.Revision, &r.Description, &r.Origin, &r.Attribution, &r.Comment,
+		&r.RevertedFrom, &r.AuthorKey, &r.CreatedAt)
+	return r, err
+}
+
+// List returns an entity's revisions, newest first, or pgx.ErrNoRows if
+// there is no such entity
+func List(ctx context.Context, pool *pgxpool.Pool, entityID int) ([]Revision, error) {
+	var exists bool
+	err := pool.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM entities WHERE id = $1)`, entityID).Scan(&exists)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, pgx.ErrNoRows
+	}
+
+	rows, err := pool.Query(ctx, `
+		SELECT `+columns+` FROM entity_description_revisions
+		WHERE entity_id = $1
+		ORDER BY revision DESC
+	`, entityID)
+	if err != nil {
+		return nil, err
+	}
+	revisions, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (Revision, error) {
+		return scan(row)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if revisions == nil {
+		revisions = []Revision{}
+	}
+	return revisions, nil
+}
+
+// Get returns one revision, the latest when revision is 0, or
+// pgx.ErrNoRows
+func Get(ctx context.Context, pool *pgxpool.Pool, entityID, revision int) (*Revision, error) {
+	r, err := scan(pool.QueryRow(ctx, `
+		SELECT `+columns+` FROM entity_description_revisions
+		WHERE entity_id = $1 AND ($2 = 0 OR revision = $2)
+		ORDER BY revision DESC
+		LIMIT 1
+	`, entityID, revision))
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// Save records e as the entity's next revision and makes it the current
+// description. It returns pgx.ErrNoRows if there is no such entity and
+// ErrConflict if e.BaseRevision isn't the latest.
+func Save(ctx context.Context, pool *pgxpool.Pool, entityID int, e Edit) (*Revision, error) {
+	var saved Revision
+	err := pgx.BeginFunc(ctx, pool, func(tx pgx.Tx) error {
+		// Locking the entity serializes edits to it
+		var latest int
+		err := tx.QueryRow(ctx, `
+			SELECT COALESCE((SELECT MAX(revision) FROM entity_description_revisions WHERE entity_id = e.id), 0)
+			FROM entities e WHERE e.id = $1
+			FOR UPDATE OF e
+		`, entityID).Scan(&latest)
+		if err != nil {
+			return err
+		}
+		if e.BaseRevision != 0 && e.BaseRevision != latest {
+			return ErrConflict
+		}
+
+		saved, err = scan(tx.QueryRow(ctx, `
+			INSERT INTO entity_description_revisions
+				(entity_id, revision, description, origin, attribution, comment, reverted_from, author_key)
+			VALUES ($1, $2, $3, $4, NULLIF($5, ''), NULLIF($6, ''), NULLIF($7, 0), NULLIF($8, 0))
+			RETURNING `+columns,
+			entityID, latest+1, e.Description, e.Origin, e.Attribution, e.Comment, e.revertedFrom, e.AuthorKey))
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(ctx, `UPDATE entities SET description = $2 WHERE id = $1`, entityID, e.Description)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &saved, nil
+}
+
+// Revert saves revision's text, origin and attribution as the entity's
+// next revision. It returns pgx.ErrNoRows if there is no such revision.
+func Revert(ctx context.Context, pool *pgxpool.Pool, entityID, revision, authorKey int, comment string) (*Revision, error) {
+	old, err := Get(ctx, pool, entityID, revision)
+	if err != nil {
+		return nil, err
+	}
+	e := Edit{
+		Description:  old.Description,
+		Origin:       old.Origin,
+		Comment:      comment,
+		AuthorKey:    authorKey,
+		revertedFrom: revision,
+	}
+	if old.Attribution != nil {
+		e.Attribution = *old.Attribution
+	}
+	return Save(ctx, pool, entityID, e)
+}
@@ -0,0 +1,101 @@
+package descriptions
+
+import "unicode"
+
+// Diff operations
+const (
+	OpEqual  = "equal"
+	OpInsert = "insert"
+	OpDelete = "delete"
+)
+
+// Op is a run of text both versions share, or only the newer or the older
+// one has. Joining the equal and delete runs gives the older text; the
+// equal and insert runs, the newer.
+type Op struct {
+	Op   string `json:"op" enum:"equal,insert,delete"`
+	Text string `json:"text"`
+}
+
+// maxCells bounds the word-by-word table Diff builds. Longer texts are
+// shown as replaced outright.
+const maxCells = 4_000_000
+
+// Diff compares two texts word by word
+func Diff(from, to string) []Op {
+	a, b := tokens(from), tokens(to)
+	ops := []Op{}
+	add := func(op, text string) {
+		if text == "" {
+			return
+		}
+		if n := len(ops); n > 0 && ops[n-1].Op == op {
+			ops[n-1].Text += text
+			return
+		}
+		ops = append(ops, Op{op, text})
+	}
+
+	if len(a)*len(b) > maxCells {
+		add(OpDelete, from)
+		add(OpInsert, to)
+		return ops
+	}
+
+	// lcs[i][j] is the length of the longest common subsequence of a[i:]
+	// and b[j:]
+	lcs := make([][]int32, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int32, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			add(OpEqual, a[i])
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			add(OpDelete, a[i])
+			i++
+		default:
+			add(OpInsert, b[j])
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		add(OpDelete, a[i])
+	}
+	for ; j < len(b); j++ {
+		add(OpInsert, b[j])
+	}
+	return ops
+}
+
+// tokens splits text into words and the whitespace between them, so that
+// joining them gives the text back
+func tokens(text string) []string {
+	var out []string
+	start, space := 0, false
+	for i, r := range text {
+		if i > start && unicode.IsSpace(r) != space {
+			out = append(out, text[start:i])
+			start = i
+		}
+		space = unicode.IsSpace(r)
+	}
+	if start < len(text) {
+		out = append(out, text[start:])
+	}
+	return out
+}
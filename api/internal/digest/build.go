@@ -0,0 +1,159 @@
+package digest
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// listed caps how many documents, patterns or documents per watched entity
+// a digest lists; the rest are only counted
+const listed = 20
+
+// Digest is what changed between From and To
+type Digest struct {
+	From time.Time
+	To   time.Time
+
+	Documents      []Document
+	DocumentsTotal int
+	Patterns       []Pattern
+	Entities       []EntityActivity
+}
+
+// Document is a newly added document
+type Document struct {
+	ID      int
+	DocID   string
+	Type    string
+	Summary string
+}
+
+// Pattern is a newly validated pattern finding
+type Pattern struct {
+	ID          int
+	Title       string
+	Description string
+}
+
+// EntityActivity is a watched entity's new documents
+type EntityActivity struct {
+	ID        int
+	Name      string
+	Total     int
+	Documents []Document
+}
+
+// Empty reports whether nothing happened in the period
+func (d *Digest) Empty() bool {
+	return d.DocumentsTotal == 0 && len(d.Patterns) == 0 && len(d.Entities) == 0
+}
+
+// Build collects what changed in [from, to), with the activity of the
+// watched entityIDs
+func Build(ctx context.Context, pool *pgxpool.Pool, from, to time.Time, entityIDs []int) (*Digest, error) {
+	d := &Digest{From: from, To: to}
+
+	err := pool.QueryRow(ctx, `
+		SELECT COUNT(*) FROM documents WHERE created_at >= $1 AND created_at < $2
+	`, from, to).Scan(&d.DocumentsTotal)
+	if err != nil {
+		return nil, err
+	}
+	if d.DocumentsTotal > 0 {
+		d.Documents, err = documents(ctx, pool, `
+			SELECT id, doc_id, COALESCE(document_type, ''), COALESCE(summary, '')
+			FROM documents
+			WHERE created_at >= $1 AND created_at < $2
+			ORDER BY id DESC
+			LIMIT $3
+		`, from, to, listed)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	rows, err := pool.Query(ctx, `
+		SELECT id, title, description
+		FROM pattern_findings
+		WHERE status = 'validated' AND validated_at >= $1 AND validated_at < $2
+		ORDER BY validated_at DESC
+		LIMIT $3
+	`, from, to, listed)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var p Pattern
+		if err := rows.Scan(&p.ID, &p.Title, &p.Description); err != nil {
+			return nil, err
+		}
+		d.Patterns = append(d.Patterns, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(entityIDs) == 0 {
+		return d, nil
+	}
+	rows, err = pool.Query(ctx, `
+		SELECT e.id, e.canonical_name, COUNT(*)
+		FROM document_entities de
+		JOIN documents d ON d.id = de.document_id
+		JOIN entities e ON e.id = de.entity_id
+		WHERE de.entity_id = ANY($1) AND d.created_at >= $2 AND d.created_at < $3
+		GROUP BY e.id, e.canonical_name
+		ORDER BY COUNT(*) DESC, e.canonical_name
+	`, entityIDs, from, to)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var a EntityActivity
+		if err := rows.Scan(&a.ID, &a.Name, &a.Total); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		d.Entities = append(d.Entities, a)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range d.Entities {
+		d.Entities[i].Documents, err = documents(ctx, pool, `
+			SELECT d.id, d.doc_id, COALESCE(d.document_type, ''), COALESCE(d.summary, '')
+			FROM document_entities de
+			JOIN documents d ON d.id = de.document_id
+			WHERE de.entity_id = $1 AND d.created_at >= $2 AND d.created_at < $3
+			ORDER BY d.id DESC
+			LIMIT $4
+		`, d.Entities[i].ID, from, to, listed)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return d, nil
+}
+
+func documents(ctx context.Context, pool *pgxpool.Pool, query string, args ...any) ([]Document, error) {
+	rows, err := pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []Document
+	for rows.Next() {
+		var doc Document
+		if err := rows.Scan(&doc.ID, &doc.DocID, &doc.Type, &doc.Summary); err != nil {
+			return nil, err
+		}
+		list = append(list, doc)
+	}
+	return list, rows.Err()
+}
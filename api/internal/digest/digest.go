@@ -0,0 +1,131 @@
+// Package digest emails subscribers a daily or weekly summary: documents
+// added, pattern findings validated, and new documents mentioning the
+// entities they watch. Subscriptions belong to researcher API keys; every
+// digest links to a one-click unsubscribe.
+package digest
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Frequencies
+const (
+	Daily  = "daily"
+	Weekly = "weekly"
+)
+
+// Frequencies lists how often digests can be sent
+var Frequencies = []string{Daily, Weekly}
+
+// MaxEntities caps the entities one subscription can watch
+const MaxEntities = 100
+
+// ErrNoEntity is returned when subscribing to an entity that doesn't exist
+var ErrNoEntity = errors.New("digest: no such entity")
+
+// Period returns how much time each digest of frequency covers
+func Period(frequency string) time.Duration {
+	if frequency == Weekly {
+		return 7 * 24 * time.Hour
+	}
+	return 24 * time.Hour
+}
+
+// Subscription is an address receiving digests. Subscriptions are visible
+// only to the API key that created them.
+type Subscription struct {
+	ID         int64      `json:"id"`
+	Email      string     `json:"email"`
+	Frequency  string     `json:"frequency" enum:"daily,weekly"`
+	EntityIDs  []int      `json:"entityIds" doc:"Watched entities"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	LastSentAt *time.Time `json:"lastSentAt" doc:"End of the period the last digest covered"`
+}
+
+// Subscribe signs email up for digests at frequency for keyID, watching
+// entityIDs. It returns ErrNoEntity if any of them doesn't exist.
+func Subscribe(ctx context.Context, pool *pgxpool.Pool, keyID int, email, frequency string, entityIDs []int) (*Subscription, error) {
+	var found int
+	err := pool.QueryRow(ctx, `SELECT COUNT(*) FROM entities WHERE id = ANY($1)`, entityIDs).Scan(&found)
+	if err != nil {
+		return nil, err
+	}
+	if found != len(entityIDs) {
+		return nil, ErrNoEntity
+	}
+
+	token := make([]byte, 24)
+	if _, err := rand.Read(token); err != nil {
+		return nil, err
+	}
+
+	s := Subscription{Email: email, Frequency: frequency, EntityIDs: entityIDs}
+	err = pool.QueryRow(ctx, `
+		INSERT INTO digest_subscriptions (key_id, email, frequency, entity_ids, unsubscribe_token)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at
+	`, keyID, email, frequency, entityIDs, hex.EncodeToString(token)).Scan(&s.ID, &s.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// List returns the active subscriptions of keyID, newest first
+func List(ctx context.Context, pool *pgxpool.Pool, keyID int) ([]Subscription, error) {
+	rows, err := pool.Query(ctx, `
+		SELECT id, email, frequency, entity_ids, created_at, last_sent_at
+		FROM digest_subscriptions
+		WHERE key_id = $1 AND unsubscribed_at IS NULL
+		ORDER BY id DESC
+	`, keyID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []Subscription{}
+	for rows.Next() {
+		var s Subscription
+		if err := rows.Scan(&s.ID, &s.Email, &s.Frequency, &s.EntityIDs, &s.CreatedAt, &s.LastSentAt); err != nil {
+			return nil, err
+		}
+		list = append(list, s)
+	}
+	return list, rows.Err()
+}
+
+// Delete removes a subscription of keyID, or returns pgx.ErrNoRows
+func Delete(ctx context.Context, pool *pgxpool.Pool, keyID int, id int64) error {
+	tag, err := pool.Exec(ctx, `DELETE FROM digest_subscriptions WHERE id = $1 AND key_id = $2`, id, keyID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+// Unsubscribe ends the subscription with token. Unsubscribing twice is
+// fine; an unknown token returns pgx.ErrNoRows.
+func Unsubscribe(ctx context.Context, pool *pgxpool.Pool, token string) error {
+	tag, err := pool.Exec(ctx, `
+		UPDATE digest_subscriptions SET unsubscribed_at = COALESCE(unsubscribed_at, NOW())
+		WHERE unsubscribe_token = $1
+	`, token)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package digest
+
+import (
+	"fmt"
+	"html"
+	"strconv"
+	"strings"
+
+	"github.com/subculture-collective/epstein-db/api/internal/email"
+)
+
+// Render turns d into the email for s. base is the API's public URL;
+// unsubscribeURL ends the subscription in one click.
+func Render(d *Digest, s *Subscription, base, unsubscribeURL string) email.Message {
+	period := "Daily"
+	if s.Frequency == Weekly {
+		period = "Weekly"
+	}
+	subject := fmt.Sprintf("%s digest: %d new documents, %d validated patterns",
+		period, d.DocumentsTotal, len(d.Patterns))
+
+	var text, body strings.Builder
+	fmt.Fprintf(&text, "%s digest for %s to %s\n",
+		period, d.From.UTC().Format("2006-01-02 15:04"), d.To.UTC().Format("2006-01-02 15:04 MST"))
+	fmt.Fprintf(&body, "<h1>%s digest</h1>\n<p>%s to %s</p>\n",
+		period, d.From.UTC().Format("2006-01-02 15:04"), d.To.UTC().Format("2006-01-02 15:04 MST"))
+
+	documentURL := func(id int) string { return base + "/api/documents/" + strconv.Itoa(id) }
+	writeDocuments := func(docs []Document, total int) {
+		body.WriteString("<ul>\n")
+		for _, doc := range docs {
+			title := doc.DocID
+			if doc.Type != "" {
+				title += " (" + doc.Type + ")"
+			}
+			fmt.Fprintf(&text, "- %s %s\n", title, documentURL(doc.ID))
+			fmt.Fprintf(&body, `<li><a href="%s">%s</a>`, html.EscapeString(documentURL(doc.ID)), html.EscapeString(title))
+			if doc.Summary != "" {
+				fmt.Fprintf(&text, "  %s\n", doc.Summary)
+				fmt.Fprintf(&body, " %s", html.EscapeString(doc.Summary))
+			}
+			body.WriteString("</li>\n")
+		}
+		body.WriteString("</ul>\n")
+		if more := total - len(docs); more > 0 {
+			fmt.Fprintf(&text, "... and %d more\n", more)
+			fmt.Fprintf(&body, "<p>... and %d more</p>\n", more)
+		}
+	}
+
+	if len(d.Entities) > 0 {
+		text.WriteString("\nWATCHED ENTITIES\n")
+		body.WriteString("<h2>Watched entities</h2>\n")
+		for _, a := range d.Entities {
+			url := base + "/api/entities/" + strconv.Itoa(a.ID)
+			fmt.Fprintf(&text, "\n%s: %d new documents %s\n", a.Name, a.Total, url)
+			fmt.Fprintf(&body, "<h3><a href=\"%s\">%s</a>: %d new documents</h3>\n",
+				html.EscapeString(url), html.EscapeString(a.Name), a.Total)
+			writeDocuments(a.Documents, a.Total)
+		}
+	}
+
+	if d.DocumentsTotal > 0 {
+		fmt.Fprintf(&text, "\nNEW DOCUMENTS (%d)\n\n", d.DocumentsTotal)
+		fmt.Fprintf(&body, "<h2>New documents (%d)</h2>\n", d.DocumentsTotal)
+		writeDocuments(d.Documents, d.DocumentsTotal)
+	}
+
+	if len(d.Patterns) > 0 {
+		text.WriteString("\nVALIDATED PATTERNS\n\n")
+		body.WriteString("<h2>Validated patterns</h2>\n<ul>\n")
+		for _, p := range d.Patterns {
+			url := base + "/api/patterns/" + strconv.Itoa(p.ID)
+			fmt.Fprintf(&text, "- %s %s\n  %s\n", p.Title, url, p.Description)
+			fmt.Fprintf(&body, "<li><a href=\"%s\">%s</a> %s</li>\n",
+				html.EscapeString(url), html.EscapeString(p.Title), html.EscapeString(p.Description))
+		}
+		body.WriteString("</ul>\n")
+	}
+
+	fmt.Fprintf(&text, "\n--\nYou receive this because %s subscribed to %s digests.\nUnsubscribe: %s\n",
+		s.Email, s.Frequency, unsubscribeURL)
+	fmt.Fprintf(&body, "<hr>\n<p>You receive this because %s subscribed to %s digests. <a href=\"%s\">Unsubscribe</a></p>\n",
+		html.EscapeString(s.Email), s.Frequency, html.EscapeString(unsubscribeURL))
+
+	return email.Message{
+		To:      s.Email,
+		Subject: subject,
+		Text:    text.String(),
+		HTML:    "<!DOCTYPE html>\n<html><body>\n" + body.String() + "</body></html>\n",
+		Headers: map[string]string{
+			"List-Unsubscribe":      "<" + unsubscribeURL + ">",
+			"List-Unsubscribe-Post": "List-Unsubscribe=One-Click",
+		},
+	}
+}
@@ -0,0 +1,83 @@
+package digest
+
+import (
+	"context"
+	"log"
+	"net/url"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/subculture-collective/epstein-db/api/internal/email"
+)
+
+// slack lets a digest go out a little early, so one scheduled daily at a
+// fixed time doesn't skip a day when the previous run finished late
+const slack = time.Hour
+
+// UnsubscribePath is where unsubscribe links point, with ?token=
+const UnsubscribePath = "/api/digests/unsubscribe"
+
+// SendDue sends every subscription whose period has elapsed by now its
+// digest, and returns how many were sent. Empty digests aren't sent, but
+// still count as covering their period. A failed delivery is logged and
+// retried on the next run.
+func SendDue(ctx context.Context, pool *pgxpool.Pool, sender email.Sender, base string, now time.Time) (int, error) {
+	rows, err := pool.Query(ctx, `
+		SELECT id, email, frequency, entity_ids, created_at, last_sent_at, unsubscribe_token
+		FROM digest_subscriptions
+		WHERE unsubscribed_at IS NULL
+		  AND (last_sent_at IS NULL
+		       OR last_sent_at <= $1::timestamptz - CASE frequency WHEN 'weekly' THEN INTERVAL '7 days' ELSE INTERVAL '1 day' END + $2::interval)
+		ORDER BY id
+	`, now, slack)
+	if err != nil {
+		return 0, err
+	}
+	type due struct {
+		Subscription
+		token string
+	}
+	var subs []due
+	for rows.Next() {
+		var s due
+		if err := rows.Scan(&s.ID, &s.Email, &s.Frequency, &s.EntityIDs, &s.CreatedAt, &s.LastSentAt, &s.token); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		subs = append(subs, s)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for _, s := range subs {
+		if err := ctx.Err(); err != nil {
+			return sent, err
+		}
+		from := now.Add(-Period(s.Frequency))
+		if s.LastSentAt != nil {
+			from = *s.LastSentAt
+		}
+
+		d, err := Build(ctx, pool, from, now, s.EntityIDs)
+		if err != nil {
+			return sent, err
+		}
+		if !d.Empty() {
+			unsubscribe := base + UnsubscribePath + "?token=" + url.QueryEscape(s.token)
+			if err := sender.Send(ctx, Render(d, &s.Subscription, base, unsubscribe)); err != nil {
+				log.Printf("digest: subscription %d: %v", s.ID, err)
+				continue
+			}
+			sent++
+		}
+
+		_, err = pool.Exec(ctx, `UPDATE digest_subscriptions SET last_sent_at = $1 WHERE id = $2`, now, s.ID)
+		if err != nil {
+			return sent, err
+		}
+	}
+	return sent, nil
+}
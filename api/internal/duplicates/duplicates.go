@@ -0,0 +1,245 @@
+// Package duplicates finds near-duplicate documents: the same exhibit
+// released in several datasets, or OCR'd twice with slightly different
+// errors. Each document's text gets a 64-bit SimHash over three-word
+// shingles, which changes in few bits when the text changes a little, and
+// documents whose fingerprints differ in at most MaxDistance bits are
+// clustered together, transitively.
+package duplicates
+
+import (
+	"context"
+	"hash/fnv"
+	"math/bits"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// MaxDistance is the most bits two fingerprints may differ in for their
+// documents to count as duplicates
+const MaxDistance = 3
+
+// MinWords is the fewest words a text needs to be fingerprinted. Shorter
+// ones, such as blank forms and cover sheets, look alike whatever they say.
+const MinWords = 50
+
+// shingleWords is the number of words hashed together
+const shingleWords = 3
+
+// bands split a fingerprint for finding candidates. Two fingerprints
+// within MaxDistance bits agree on at least one of MaxDistance+1 bands, so
+// only documents sharing a band are compared.
+const bands = MaxDistance + 1
+
+// batchSize bounds the documents fingerprinted per query
+const batchSize = 500
+
+// Result summarizes a run
+type Result struct {
+	Fingerprinted int `json:"fingerprinted" doc:"Documents fingerprinted this run"`
+	Clusters      int `json:"clusters" doc:"Clusters of two or more documents"`
+	Documents     int `json:"documents" doc:"Documents in those clusters"`
+	DurationMs    int `json:"durationMs"`
+}
+
+// staleSQL reads the next batch of documents after $2 whose text changed
+// since they were fingerprinted, or every document if $1
+const staleSQL = `
+	SELECT d.id, d.full_text
+	FROM documents d
+	LEFT JOIN document_fingerprints f ON f.document_id = d.id
+	WHERE d.id > $2
+	  AND ($1 OR f.document_id IS NULL OR COALESCE(d.updated_at, d.created_at) > f.computed_at)
+	ORDER BY d.id
+	LIMIT $3
+`
+
+// Run fingerprints the documents whose text changed since the last run, or
+// every document when force is set, and reclusters them all
+func Run(ctx context.Context, pool *pgxpool.Pool, force bool) (*Result, error) {
+	started := time.Now()
+	result := &Result{}
+
+	after := 0
+	for {
+		rows, err := pool.Query(ctx, staleSQL, force, after, batchSize)
+		if err != nil {
+			return nil, err
+		}
+		var ids []int
+		var hashes []*int64
+		for rows.Next() {
+			var id int
+			var text *string
+			if err := rows.Scan(&id, &text); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			ids = append(ids, id)
+			hashes = append(hashes, fingerprint(text))
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+		if len(ids) == 0 {
+			break
+		}
+
+		_, err = pool.Exec(ctx, `
+			INSERT INTO document_fingerprints (document_id, simhash)
+			SELECT * FROM unnest($1::int[], $2::bigint[])
+			ON CONFLICT (document_id) DO UPDATE SET simhash = EXCLUDED.simhash, computed_at = NOW()
+		`, ids, hashes)
+		if err != nil {
+			return nil, err
+		}
+		result.Fingerprinted += len(ids)
+		after = ids[len(ids)-1]
+	}
+
+	if err := cluster(ctx, pool, result); err != nil {
+		return nil, err
+	}
+	result.DurationMs = int(time.Since(started).Milliseconds())
+	return result, nil
+}
+
+// cluster groups every fingerprinted document with its near duplicates and
+// replaces the stored clusters
+func cluster(ctx context.Context, pool *pgxpool.Pool, result *Result) error {
+	rows, err := pool.Query(ctx, `
+		SELECT document_id, simhash FROM document_fingerprints
+		WHERE simhash IS NOT NULL
+		ORDER BY document_id
+	`)
+	if err != nil {
+		return err
+	}
+	type doc struct {
+		id   int
+		hash uint64
+	}
+	docs, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (doc, error) {
+		var d doc
+		var h int64
+		err := row.Scan(&d.id, &h)
+		d.hash = uint64(h)
+		return d, err
+	})
+	if err != nil {
+		return err
+	}
+
+	// Union the documents within MaxDistance of each other, comparing only
+	// those sharing a band
+	parent := make([]int, len(docs))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	width := 64 / bands
+	for b := 0; b < bands; b++ {
+		buckets := map[uint64][]int{}
+		for i, d := range docs {
+			key := d.hash >> (b * width) & (1<<width - 1)
+			buckets[key] = append(buckets[key], i)
+		}
+		for _, bucket := range buckets {
+			for x := 0; x < len(bucket); x++ {
+				for y := x + 1; y < len(bucket); y++ {
+					i, j := bucket[x], bucket[y]
+					if bits.OnesCount64(docs[i].hash^docs[j].hash) > MaxDistance {
+						continue
+					}
+					if ri, rj := find(i), find(j); ri != rj {
+						// Keep the lower index, the lower document ID, as root
+						parent[max(ri, rj)] = min(ri, rj)
+					}
+				}
+			}
+		}
+	}
+
+	// Documents are in ID order, so a cluster's root is its first document
+	var ids, clusters []int
+	var similarities []float64
+	size := map[int]int{}
+	for i := range docs {
+		size[find(i)]++
+	}
+	for i, d := range docs {
+		root := find(i)
+		if size[root] < 2 {
+			continue
+		}
+		ids = append(ids, d.id)
+		clusters = append(clusters, docs[root].id)
+		similarities = append(similarities, 1-float64(bits.OnesCount64(d.hash^docs[root].hash))/64)
+		if root == i {
+			result.Clusters++
+		}
+	}
+	result.Documents = len(ids)
+
+	return pgx.BeginFunc(ctx, pool, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, `DELETE FROM document_duplicates`); err != nil {
+			return err
+		}
+		_, err := tx.Exec(ctx, `
+			INSERT INTO document_duplicates (document_id, cluster_id, similarity)
+			SELECT * FROM unnest($1::int[], $2::int[], $3::float8[])
+		`, ids, clusters, similarities)
+		return err
+	})
+}
+
+// fingerprint returns the SimHash of text, or nil if it has fewer than
+// MinWords words
+func fingerprint(text *string) *int64 {
+	if text == nil {
+		return nil
+	}
+	words := strings.FieldsFunc(strings.ToLower(*text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	if len(words) < MinWords {
+		return nil
+	}
+
+	var votes [64]int
+	h := fnv.New64a()
+	for i := 0; i+shingleWords <= len(words); i++ {
+		h.Reset()
+		for _, w := range words[i : i+shingleWords] {
+			h.Write([]byte(w))
+			h.Write([]byte{0})
+		}
+		sum := h.Sum64()
+		for b := 0; b < 64; b++ {
+			if sum&(1<<b) != 0 {
+				votes[b]++
+			} else {
+				votes[b]--
+			}
+		}
+	}
+
+	var hash uint64
+	for b, v := range votes {
+		if v > 0 {
+			hash |= 1 << b
+		}
+	}
+	signed := int64(hash)
+	return &signed
+}
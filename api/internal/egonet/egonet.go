@@ -0,0 +1,217 @@
+// Package egonet draws an entity's neighbourhood as a small SVG, for
+// embedding in reports and social cards where running the web app's graph
+// view isn't an option. Nodes are placed with a force-directed layout
+// (Fruchterman–Reingold) started from rings around the entity, which stays
+// pinned in the middle; the same network always gets the same picture.
+package egonet
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"io"
+	"math"
+	"unicode/utf8"
+
+	"github.com/subculture-collective/epstein-db/api/internal/models"
+)
+
+// MediaType is the content type of the drawings
+const MediaType = "image/svg+xml"
+
+// Sizes bound the width and height of a drawing, in pixels
+const (
+	MinSize     = 200
+	MaxSize     = 1600
+	DefaultSize = 600
+)
+
+// iterations of the layout; enough for the few dozen nodes drawn here
+const iterations = 300
+
+// colors of nodes by entity type
+var colors = map[string]string{
+	"person":       "#2563eb",
+	"organization": "#059669",
+	"location":     "#d97706",
+}
+
+// radii and fontSizes of nodes and their labels by ring, at DefaultSize
+var (
+	radii     = [3]float64{12, 8, 5}
+	fontSizes = [3]float64{15, 12, 10}
+)
+
+// point is a position in a unit square centred on the origin
+type point struct{ x, y float64 }
+
+// layout places nodes in the unit square around the origin. The first node
+// is the centre and stays at the origin.
+func layout(nodes []models.EgoNode, edges []models.NetworkEdge) []point {
+	n := len(nodes)
+	pos := make([]point, n)
+	if n <= 1 {
+		return pos
+	}
+	index := make(map[int]int, n)
+	for i, node := range nodes {
+		index[node.ID] = i
+	}
+
+	// Start each ring on a circle of its own, spread evenly
+	rings := map[int][]int{}
+	for i, node := range nodes[1:] {
+		rings[node.Ring] = append(rings[node.Ring], i+1)
+	}
+	for ring, members := range rings {
+		radius := 0.22 * float64(ring)
+		for k, i := range members {
+			angle := 2*math.Pi*float64(k)/float64(len(members)) + 0.3*float64(ring)
+			pos[i] = point{radius * math.Cos(angle), radius * math.Sin(angle)}
+		}
+	}
+
+	k := 0.6 * math.Sqrt(1/float64(n))
+	temperature := 0.1
+	disp := make([]point, n)
+	for iter := 0; iter < iterations; iter++ {
+		for i := range disp {
+			disp[i] = point{}
+		}
+		// Every pair repels
+		for i := 0; i < n; i++ {
+			for j := i + 1; j < n; j++ {
+				dx, dy := pos[i].x-pos[j].x, pos[i].y-pos[j].y
+				d := math.Max(math.Hypot(dx, dy), 0.001)
+				f := k * k / d
+				disp[i].x += dx / d * f
+				disp[i].y += dy / d * f
+				disp[j].x -= dx / d * f
+				disp[j].y -= dy / d * f
+			}
+		}
+		// Related entities attract, more so the stronger the relationship
+		for _, e := range edges {
+			i, ok1 := index[e.Source]
+			j, ok2 := index[e.Target]
+			if !ok1 || !ok2 {
+				continue
+			}
+			dx, dy := pos[i].x-pos[j].x, pos[i].y-pos[j].y
+			d := math.Max(math.Hypot(dx, dy), 0.001)
+			f := d * d / k * (1 + math.Log1p(score(e)))
+			disp[i].x -= dx / d * f
+			disp[i].y -= dy / d * f
+			disp[j].x += dx / d * f
+			disp[j].y += dy / d * f
+		}
+		for i := 1; i < n; i++ {
+			d := math.Max(math.Hypot(disp[i].x, disp[i].y), 0.001)
+			step := math.Min(d, temperature)
+			pos[i].x = clamp(pos[i].x + disp[i].x/d*step)
+			pos[i].y = clamp(pos[i].y + disp[i].y/d*step)
+		}
+		temperature *= 0.985
+	}
+	return pos
+}
+
+func clamp(v float64) float64 {
+	return math.Max(-0.5, math.Min(0.5, v))
+}
+
+func score(e models.NetworkEdge) float64 {
+	if e.Score == nil {
+		return 0
+	}
+	return math.Max(*e.Score, 0)
+}
+
+// SVG draws the network size pixels square to w. The first node is the
+// entity whose neighbourhood it is. Entities in the first ring are
+// labelled, and farther ones too when the drawing is large enough.
+func SVG(w io.Writer, nodes []models.EgoNode, edges []models.NetworkEdge, size int) error {
+	pos := layout(nodes, edges)
+	index := make(map[int]int, len(nodes))
+	for i, node := range nodes {
+		index[node.ID] = i
+	}
+
+	scale := float64(size) / DefaultSize
+	margin := 60 * scale
+	at := func(i int) (float64, float64) {
+		span := float64(size) - 2*margin
+		return margin + (pos[i].x+0.5)*span, margin + (pos[i].y+0.5)*span
+	}
+
+	maxScore := 0.0
+	for _, e := range edges {
+		maxScore = math.Max(maxScore, score(e))
+	}
+
+	b := bufio.NewWriter(w)
+	title := ""
+	if len(nodes) > 0 {
+		title = "Network of " + nodes[0].CanonicalName
+	}
+	fmt.Fprintf(b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" role="img" aria-label="%s">`+"\n",
+		size, size, size, size, html.EscapeString(title))
+	fmt.Fprintf(b, "<title>%s</title>\n", html.EscapeString(title))
+	b.WriteString(`<rect width="100%" height="100%" fill="#ffffff"/>` + "\n")
+
+	b.WriteString(`<g stroke="#94a3b8" stroke-opacity="0.7" stroke-linecap="round">` + "\n")
+	for _, e := range edges {
+		i, ok1 := index[e.Source]
+		j, ok2 := index[e.Target]
+		if !ok1 || !ok2 {
+			continue
+		}
+		width := 1.0
+		if maxScore > 0 {
+			width += 3 * score(e) / maxScore
+		}
+		x1, y1 := at(i)
+		x2, y2 := at(j)
+		fmt.Fprintf(b, `<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" stroke-width="%.1f"/>`+"\n", x1, y1, x2, y2, width*scale)
+	}
+	b.WriteString("</g>\n")
+
+	b.WriteString(`<g stroke="#ffffff" stroke-width="1.5">` + "\n")
+	for i, node := range nodes {
+		x, y := at(i)
+		color, ok := colors[node.EntityType]
+		if !ok {
+			color = "#6b7280"
+		}
+		radius := radii[min(node.Ring, 2)] * scale
+		fmt.Fprintf(b, `<circle cx="%.1f" cy="%.1f" r="%.1f" fill="%s"><title>%s</title></circle>`+"\n",
+			x, y, radius, color, html.EscapeString(node.CanonicalName))
+	}
+	b.WriteString("</g>\n")
+
+	fmt.Fprintf(b, `<g font-family="Helvetica, Arial, sans-serif" fill="#1f2937" text-anchor="middle" stroke="#ffffff" stroke-width="%.1f" paint-order="stroke">`+"\n", 3*scale)
+	for i, node := range nodes {
+		if node.Ring > 1 && size < 900 {
+			continue
+		}
+		x, y := at(i)
+		fontSize := fontSizes[min(node.Ring, 2)] * scale
+		radius := radii[min(node.Ring, 2)] * scale
+		weight := "normal"
+		if node.Ring == 0 {
+			weight = "bold"
+		}
+		fmt.Fprintf(b, `<text x="%.1f" y="%.1f" font-size="%.1f" font-weight="%s">%s</text>`+"\n",
+			x, y+radius+fontSize, fontSize, weight, html.EscapeString(truncate(node.CanonicalName, 28)))
+	}
+	b.WriteString("</g>\n</svg>\n")
+	return b.Flush()
+}
+
+// truncate shortens s to n characters, ending in an ellipsis
+func truncate(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n-1]) + "…"
+}
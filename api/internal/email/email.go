@@ -0,0 +1,140 @@
+// Package email sends mail through a configured provider: an SMTP server,
+// or the log during development.
+package email
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ErrNotConfigured is returned when no provider is configured
+var ErrNotConfigured = errors.New("email: no provider configured")
+
+// Message is an email with a plain-text body and, optionally, an HTML one
+type Message struct {
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+	// Headers are added as they are, e.g. List-Unsubscribe
+	Headers map[string]string
+}
+
+// Sender delivers messages
+type Sender interface {
+	Send(ctx context.Context, m Message) error
+}
+
+// New returns the sender for a provider URL (see config.Email), sending
+// from the from address
+func New(rawURL, from string) (Sender, error) {
+	if rawURL == "" {
+		return nil, ErrNotConfigured
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("email: %w", err)
+	}
+	addr, err := mail.ParseAddress(from)
+	if err != nil {
+		return nil, fmt.Errorf("email: from address: %w", err)
+	}
+
+	switch u.Scheme {
+	case "smtp", "smtps":
+		return newSMTP(u, addr), nil
+	case "log":
+		return &Log{from: addr}, nil
+	}
+	return nil, fmt.Errorf("email: unsupported provider %q", u.Scheme)
+}
+
+// Log writes messages to the log instead of sending them
+type Log struct {
+	from *mail.Address
+}
+
+// Send logs m's recipient, subject and text
+func (l *Log) Send(_ context.Context, m Message) error {
+	log.Printf("email: to %s from %s: %s\n%s", m.To, l.from, m.Subject, m.Text)
+	return nil
+}
+
+// build renders m as a MIME message
+func build(from *mail.Address, m Message) ([]byte, error) {
+	var buf bytes.Buffer
+	header := func(name, value string) {
+		fmt.Fprintf(&buf, "%s: %s\r\n", name, value)
+	}
+
+	id := make([]byte, 12)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	domain := from.Address[strings.LastIndexByte(from.Address, '@')+1:]
+
+	header("From", from.String())
+	header("To", m.To)
+	header("Subject", mime.QEncoding.Encode("utf-8", m.Subject))
+	header("Date", time.Now().Format(time.RFC1123Z))
+	header("Message-ID", "<"+hex.EncodeToString(id)+"@"+domain+">")
+	header("MIME-Version", "1.0")
+	for name, value := range m.Headers {
+		header(name, value)
+	}
+
+	if m.HTML == "" {
+		header("Content-Type", "text/plain; charset=utf-8")
+		header("Content-Transfer-Encoding", "quoted-printable")
+		buf.WriteString("\r\n")
+		if err := writeQP(&buf, m.Text); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+
+	parts := multipart.NewWriter(&buf)
+	header("Content-Type", `multipart/alternative; boundary="`+parts.Boundary()+`"`)
+	buf.WriteString("\r\n")
+	for _, p := range []struct{ contentType, body string }{
+		{"text/plain; charset=utf-8", m.Text},
+		{"text/html; charset=utf-8", m.HTML},
+	} {
+		w, err := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {p.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if err := writeQP(w, p.body); err != nil {
+			return nil, err
+		}
+	}
+	if err := parts.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeQP(w io.Writer, s string) error {
+	qp := quotedprintable.NewWriter(w)
+	if _, err := qp.Write([]byte(strings.ReplaceAll(s, "\n", "\r\n"))); err != nil {
+		return err
+	}
+	return qp.Close()
+}
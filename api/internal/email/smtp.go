@@ -0,0 +1,105 @@
+package email
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/url"
+	"time"
+)
+
+// timeout bounds each delivery, connection included
+const timeout = 30 * time.Second
+
+// SMTP sends through an SMTP server, authenticating when the URL has
+// credentials. smtp:// upgrades with STARTTLS when the server offers it;
+// smtps:// connects over TLS.
+type SMTP struct {
+	addr     string
+	host     string
+	auth     smtp.Auth
+	from     *mail.Address
+	implicit bool
+}
+
+func newSMTP(u *url.URL, from *mail.Address) *SMTP {
+	s := &SMTP{host: u.Hostname(), from: from, implicit: u.Scheme == "smtps"}
+	port := u.Port()
+	if port == "" {
+		port = "587"
+		if s.implicit {
+			port = "465"
+		}
+	}
+	s.addr = net.JoinHostPort(s.host, port)
+	if u.User != nil {
+		password, _ := u.User.Password()
+		s.auth = smtp.PlainAuth("", u.User.Username(), password, s.host)
+	}
+	return s
+}
+
+// Send delivers m
+func (s *SMTP) Send(ctx context.Context, m Message) error {
+	to, err := mail.ParseAddress(m.To)
+	if err != nil {
+		return err
+	}
+	msg, err := build(s.from, m)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", s.addr)
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	tlsConfig := &tls.Config{ServerName: s.host}
+	if s.implicit {
+		conn = tls.Client(conn, tlsConfig)
+	}
+
+	c, err := smtp.NewClient(conn, s.host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok && !s.implicit {
+		if err := c.StartTLS(tlsConfig); err != nil {
+			return err
+		}
+	}
+	if s.auth != nil {
+		if err := c.Auth(s.auth); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(s.from.Address); err != nil {
+		return err
+	}
+	if err := c.Rcpt(to.Address); err != nil {
+		return err
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
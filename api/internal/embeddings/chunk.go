@@ -0,0 +1,92 @@
+package embeddings
+
+import "unicode"
+
+// Chunk is a window of document text
+type Chunk struct {
+	Index   int
+	Content string
+	Start   int
+	End     int
+}
+
+// ChunkText splits text into windows of roughly size characters that overlap
+// by overlap characters. Window edges are moved back to the nearest
+// whitespace so that words aren't cut in half.
+func ChunkText(text string, size, overlap int) []Chunk {
+	if size <= 0 {
+		return nil
+	}
+	if overlap < 0 || overlap >= size {
+		overlap = 0
+	}
+
+	var chunks []Chunk
+	for start := 0; start < len(text); {
+		end := start + size
+		if end >= len(text) {
+			end = len(text)
+		} else {
+			end = snapBack(text, start, end)
+		}
+
+		if content := text[start:end]; !blank(content) {
+			chunks = append(chunks, Chunk{
+				Index:   len(chunks),
+				Content: content,
+				Start:   start,
+				End:     end,
+			})
+		}
+
+		if end == len(text) {
+			break
+		}
+
+		next := end - overlap
+		if overlap > 0 {
+			next = snapForward(text, next, end)
+		}
+		if next <= start {
+			next = end
+		}
+		start = next
+	}
+
+	return chunks
+}
+
+// snapBack moves end to just after the last whitespace in text[start:end],
+// leaving it unchanged when the window has no whitespace in its second half
+func snapBack(text string, start, end int) int {
+	min := start + (end-start)/2
+	for i := end; i > min; i-- {
+		if isBreak(text[i-1]) {
+			return i
+		}
+	}
+	return end
+}
+
+// snapForward moves pos forward to the start of the next word
+func snapForward(text string, pos, limit int) int {
+	for i := pos; i < limit; i++ {
+		if isBreak(text[i]) {
+			return i + 1
+		}
+	}
+	return pos
+}
+
+func isBreak(b byte) bool {
+	return b == ' ' || b == '\n' || b == '\t' || b == '\r'
+}
+
+func blank(s string) bool {
+	for _, r := range s {
+		if !unicode.IsSpace(r) {
+			return false
+		}
+	}
+	return true
+}
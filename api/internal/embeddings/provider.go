@@ -0,0 +1,152 @@
+package embeddings
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Dimensions is the size of the document_chunks.embedding column
+const Dimensions = 1536
+
+// Provider turns text into vectors
+type Provider interface {
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+	Model() string
+}
+
+// ErrNotConfigured is returned when no embedding provider is configured
+var ErrNotConfigured = errors.New("embeddings: no provider configured")
+
+// NewProviderFromEnv builds an OpenAI-compatible provider from
+// EMBEDDING_BASE_URL (or OPENAI_BASE_URL), OPENAI_API_KEY and EMBEDDING_MODEL.
+// Local servers such as Ollama expose the same API and don't need a key.
+func NewProviderFromEnv() (Provider, error) {
+	baseURL := os.Getenv("EMBEDDING_BASE_URL")
+	if baseURL == "" {
+		baseURL = os.Getenv("OPENAI_BASE_URL")
+	}
+	apiKey := os.Getenv("OPENAI_API_KEY")
+	if baseURL == "" && apiKey == "" {
+		return nil, ErrNotConfigured
+	}
+	if baseURL == "" {
+		baseURL = "https://api.openai.com/v1"
+	}
+
+	batch, _ := strconv.Atoi(os.Getenv("EMBEDDING_BATCH_SIZE"))
+	if batch <= 0 {
+		batch = 64
+	}
+
+	return &OpenAI{
+		baseURL:   baseURL,
+		apiKey:    apiKey,
+		model:     ModelFromEnv(),
+		batchSize: batch,
+		http:      &http.Client{Timeout: time.Minute},
+	}, nil
+}
+
+// ModelFromEnv returns the configured embedding model name
+func ModelFromEnv() string {
+	if model := os.Getenv("EMBEDDING_MODEL"); model != "" {
+		return model
+	}
+	return "text-embedding-3-small"
+}
+
+// OpenAI calls an OpenAI-compatible /embeddings endpoint
+type OpenAI struct {
+	baseURL   string
+	apiKey    string
+	model     string
+	batchSize int
+	http      *http.Client
+}
+
+// Model returns the embedding model name
+func (o *OpenAI) Model() string {
+	return o.model
+}
+
+// Embed returns one vector per input text, batching requests
+func (o *OpenAI) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, 0, len(texts))
+	for start := 0; start < len(texts); start += o.batchSize {
+		end := min(start+o.batchSize, len(texts))
+		batch, err := o.embedBatch(ctx, texts[start:end])
+		if err != nil {
+			return nil, err
+		}
+		vectors = append(vectors, batch...)
+	}
+	return vectors, nil
+}
+
+func (o *OpenAI) embedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	body, err := json.Marshal(map[string]any{
+		"model":      o.model,
+		"input":      texts,
+		"dimensions": Dimensions,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, o.baseURL+"/embeddings", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if o.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+o.apiKey)
+	}
+
+	resp, err := o.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("embeddings: status %d: %s", resp.StatusCode, raw)
+	}
+
+	var parsed struct {
+		Data []struct {
+			Index     int       `json:"index"`
+			Embedding []float32 `json:"embedding"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(raw, &parsed); err != nil {
+		return nil, err
+	}
+	if len(parsed.Data) != len(texts) {
+		return nil, fmt.Errorf("embeddings: got %d vectors for %d inputs", len(parsed.Data), len(texts))
+	}
+
+	vectors := make([][]float32, len(texts))
+	for _, d := range parsed.Data {
+		if d.Index < 0 || d.Index >= len(texts) {
+			return nil, fmt.Errorf("embeddings: index %d out of range", d.Index)
+		}
+		if len(d.Embedding) != Dimensions {
+			return nil, fmt.Errorf("embeddings: got %d dimensions, want %d", len(d.Embedding), Dimensions)
+		}
+		vectors[d.Index] = d.Embedding
+	}
+
+	return vectors, nil
+}
@@ -0,0 +1,224 @@
+package embeddings
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/subculture-collective/epstein-db/api/internal/jobs"
+)
+
+// JobKind is the jobs.kind for embedding jobs
+const JobKind = "embed"
+
+// Config controls chunking
+type Config struct {
+	ChunkSize int // characters
+	Overlap   int // characters shared between consecutive chunks
+}
+
+// DefaultConfig returns the chunking used by the worker command
+func DefaultConfig() Config {
+	return Config{
+		ChunkSize: 2000,
+		Overlap:   200,
+	}
+}
+
+// ConfigFromEnv applies EMBEDDING_CHUNK_SIZE and EMBEDDING_CHUNK_OVERLAP
+// on top of the defaults
+func ConfigFromEnv() Config {
+	cfg := DefaultConfig()
+	if n, err := strconv.Atoi(os.Getenv("EMBEDDING_CHUNK_SIZE")); err == nil && n > 0 {
+		cfg.ChunkSize = n
+	}
+	if n, err := strconv.Atoi(os.Getenv("EMBEDDING_CHUNK_OVERLAP")); err == nil && n >= 0 {
+		cfg.Overlap = n
+	}
+	return cfg
+}
+
+// Params are the options accepted by an embedding job
+type Params struct {
+	DatasetID int  `json:"datasetId"`
+	Force     bool `json:"force"` // re-embed documents that aren't stale
+}
+
+// Result is stored on the job when it finishes
+type Result struct {
+	Documents int `json:"documents"`
+	Chunks    int `json:"chunks"`
+	Failed    int `json:"failed"`
+}
+
+// Status reports how much of the corpus is embedded
+type Status struct {
+	Fingerprint string `json:"fingerprint"`
+	Documents   int    `json:"documents"`
+	Embedded    int    `json:"embedded"`
+	Stale       int    `json:"stale"`
+	Chunks      int    `json:"chunks"`
+}
+
+// Worker chunks documents and stores their embeddings
+type Worker struct {
+	pool     *pgxpool.Pool
+	queue    *jobs.Queue
+	provider Provider
+	cfg      Config
+}
+
+// NewWorker creates an embedding worker
+func NewWorker(pool *pgxpool.Pool, provider Provider, cfg Config) *Worker {
+	return &Worker{
+		pool:     pool,
+		queue:    jobs.NewQueue(pool),
+		provider: provider,
+		cfg:      cfg,
+	}
+}
+
+// Fingerprint identifies the model and chunking configuration so documents
+// embedded under a different configuration are treated as stale
+func Fingerprint(model string, cfg Config) string {
+	return fmt.Sprintf("%s:%d:%d", model, cfg.ChunkSize, cfg.Overlap)
+}
+
+// staleCondition matches documents whose chunks are missing or out of date
+const staleCondition = `(embedding_config IS DISTINCT FROM $1
+	OR embedded_text_hash IS DISTINCT FROM md5(full_text))`
+
+// GetStatus counts embedded and stale documents for a fingerprint
+func GetStatus(ctx context.Context, pool *pgxpool.Pool, fingerprint string) (Status, error) {
+	s := Status{Fingerprint: fingerprint}
+	err := pool.QueryRow(ctx, `
+		SELECT COUNT(*),
+			   COUNT(*) FILTER (WHERE embedded_at IS NOT NULL),
+			   COUNT(*) FILTER (WHERE `+staleCondition+`),
+			   (SELECT COUNT(*) FROM document_chunks)
+		FROM documents
+		WHERE full_text IS NOT NULL AND full_text != ''
+	`, fingerprint).Scan(&s.Documents, &s.Embedded, &s.Stale, &s.Chunks)
+	return s, err
+}
+
+// HandleJob processes a queued embedding job
+func (w *Worker) HandleJob(ctx context.Context, job *jobs.Job) (any, error) {
+	var params Params
+	if err := job.DecodeParams(&params); err != nil {
+		return nil, err
+	}
+
+	return w.Run(ctx, params, func(done, total int) {
+		if err := w.queue.SetProgress(ctx, job.ID, done, total); err != nil {
+			log.Printf("embed: job %d progress: %v", job.ID, err)
+		}
+	})
+}
+
+// Run embeds every stale document, calling progress after each one
+func (w *Worker) Run(ctx context.Context, params Params, progress func(done, total int)) (Result, error) {
+	var result Result
+	fingerprint := Fingerprint(w.provider.Model(), w.cfg)
+
+	rows, err := w.pool.Query(ctx, `
+		SELECT id FROM documents
+		WHERE full_text IS NOT NULL AND full_text != ''
+		  AND ($2 = 0 OR dataset_id = $2)
+		  AND ($3 OR `+staleCondition+`)
+		ORDER BY id
+	`, fingerprint, params.DatasetID, params.Force)
+	if err != nil {
+		return result, err
+	}
+	ids, err := pgx.CollectRows(rows, pgx.RowTo[int])
+	if err != nil {
+		return result, err
+	}
+
+	for i, id := range ids {
+		if ctx.Err() != nil {
+			return result, ctx.Err()
+		}
+
+		n, err := w.embedDocument(ctx, id, fingerprint)
+		if err != nil {
+			log.Printf("embed: document %d: %v", id, err)
+			result.Failed++
+		} else {
+			result.Documents++
+			result.Chunks += n
+		}
+
+		if progress != nil {
+			progress(i+1, len(ids))
+		}
+	}
+
+	return result, nil
+}
+
+func (w *Worker) embedDocument(ctx context.Context, id int, fingerprint string) (int, error) {
+	var text string
+	if err := w.pool.QueryRow(ctx, `SELECT full_text FROM documents WHERE id = $1`, id).Scan(&text); err != nil {
+		return 0, err
+	}
+
+	chunks := ChunkText(text, w.cfg.ChunkSize, w.cfg.Overlap)
+	contents := make([]string, len(chunks))
+	for i, c := range chunks {
+		contents[i] = c.Content
+	}
+
+	vectors, err := w.provider.Embed(ctx, contents)
+	if err != nil {
+		return 0, err
+	}
+
+	err = pgx.BeginFunc(ctx, w.pool, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, `DELETE FROM document_chunks WHERE document_id = $1`, id); err != nil {
+			return err
+		}
+
+		for i, c := range chunks {
+			if _, err := tx.Exec(ctx, `
+				INSERT INTO document_chunks
+					(document_id, chunk_index, content, char_start, char_end, embedding, model)
+				VALUES ($1, $2, $3, $4, $5, $6::vector, $7)
+			`, id, c.Index, c.Content, c.Start, c.End, VectorLiteral(vectors[i]), w.provider.Model()); err != nil {
+				return err
+			}
+		}
+
+		_, err := tx.Exec(ctx, `
+			UPDATE documents SET
+				embedded_at = NOW(),
+				embedded_text_hash = md5(full_text),
+				embedding_config = $2
+			WHERE id = $1
+		`, id, fingerprint)
+		return err
+	})
+
+	return len(chunks), err
+}
+
+// VectorLiteral formats a vector in pgvector's text representation
+func VectorLiteral(v []float32) string {
+	var sb strings.Builder
+	sb.WriteByte('[')
+	for i, f := range v {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(strconv.FormatFloat(float64(f), 'g', -1, 32))
+	}
+	sb.WriteByte(']')
+	return sb.String()
+}
@@ -0,0 +1,43 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Channel is the Postgres NOTIFY channel events are published on
+const Channel = "epstein_events"
+
+// Event types. Apart from DatasetReady these are published by database
+// triggers (migration 013), so they fire whichever process makes the change.
+const (
+	DatasetReady   = "dataset.ready"
+	DatasetStatus  = "dataset.status"
+	JobProgress    = "job.progress"
+	JobCompleted   = "job.completed"
+	JobFailed      = "job.failed"
+	PatternCreated = "pattern.created"
+	EntityUpdated  = "entity.updated"
+)
+
+// Event is a notification about a change in the database
+type Event struct {
+	Type string    `json:"type"`
+	Data any       `json:"data"`
+	Time time.Time `json:"time"`
+}
+
+// Publish broadcasts an event to every listener on Channel. Payloads must
+// stay under Postgres' 8000 byte NOTIFY limit, so send IDs rather than rows.
+func Publish(ctx context.Context, pool *pgxpool.Pool, eventType string, data any) error {
+	payload, err := json.Marshal(Event{Type: eventType, Data: data, Time: time.Now().UTC()})
+	if err != nil {
+		return err
+	}
+
+	_, err = pool.Exec(ctx, `SELECT pg_notify($1, $2)`, Channel, string(payload))
+	return err
+}
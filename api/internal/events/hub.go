@@ -0,0 +1,131 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// subscriberBuffer is how many messages a subscriber may fall behind
+// before it is dropped
+const subscriberBuffer = 64
+
+// retryInterval is how long Run waits before listening again after losing
+// its connection
+const retryInterval = 5 * time.Second
+
+// Message is an event as received: its type and the raw JSON payload
+type Message struct {
+	Type    string
+	Payload []byte
+}
+
+// Hub relays events from Channel to in-process subscribers, using a single
+// database connection however many subscribers there are
+type Hub struct {
+	mu     sync.Mutex
+	subs   map[chan Message]struct{}
+	closed bool
+}
+
+// NewHub creates a hub; start it with Run
+func NewHub() *Hub {
+	return &Hub{subs: make(map[chan Message]struct{})}
+}
+
+// Subscribe returns a channel of events and a function to unsubscribe. The
+// channel is closed when the hub closes or the subscriber falls too far
+// behind.
+func (h *Hub) Subscribe() (<-chan Message, func()) {
+	ch := make(chan Message, subscriberBuffer)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		close(ch)
+		return ch, func() {}
+	}
+	h.subs[ch] = struct{}{}
+
+	return ch, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		if _, ok := h.subs[ch]; ok {
+			delete(h.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+// Close ends every subscription, e.g. so streaming responses finish before
+// the server shuts down
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.closed = true
+	for ch := range h.subs {
+		delete(h.subs, ch)
+		close(ch)
+	}
+}
+
+func (h *Hub) broadcast(msg Message) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.subs {
+		select {
+		case ch <- msg:
+		default:
+			// Too slow; the client reconnects and refetches
+			delete(h.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+// Run listens on Channel and broadcasts each event until ctx is cancelled,
+// reconnecting if the connection drops
+func (h *Hub) Run(ctx context.Context, pool *pgxpool.Pool) {
+	for ctx.Err() == nil {
+		if err := h.listen(ctx, pool); err != nil && ctx.Err() == nil {
+			slog.Warn("events: listen failed, retrying", "error", err)
+			select {
+			case <-ctx.Done():
+			case <-time.After(retryInterval):
+			}
+		}
+	}
+}
+
+func (h *Hub) listen(ctx context.Context, pool *pgxpool.Pool) error {
+	pc, err := pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	// A listening connection mustn't go back to the pool
+	conn := pc.Hijack()
+	defer conn.Close(context.Background())
+
+	if _, err := conn.Exec(ctx, "LISTEN "+Channel); err != nil {
+		return err
+	}
+
+	for {
+		n, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+
+		var head struct {
+			Type string `json:"type"`
+		}
+		if err := json.Unmarshal([]byte(n.Payload), &head); err != nil {
+			continue
+		}
+		h.broadcast(Message{Type: head.Type, Payload: []byte(n.Payload)})
+	}
+}
@@ -0,0 +1,412 @@
+// Package exports writes bulk downloads in the background: every document,
+// entity or triple matching a few filters, as gzipped NDJSON or CSV. Like
+// the API, exports leave out deleted rows and protected entities and mask
+// protected names in text. Files are read in one transaction, so an export
+// is consistent however long it takes, and appear under their final name
+// only once complete.
+package exports
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/subculture-collective/epstein-db/api/internal/store"
+)
+
+// JobKind is the jobs.kind for export jobs
+const JobKind = "export"
+
+// Sets of rows an export can hold
+const (
+	SetDocuments = "documents"
+	SetEntities  = "entities"
+	SetTriples   = "triples"
+)
+
+// Sets lists every set
+var Sets = []string{SetDocuments, SetEntities, SetTriples}
+
+// Formats
+const (
+	FormatNDJSON = "ndjson"
+	FormatCSV    = "csv"
+)
+
+// Formats lists every format
+var Formats = []string{FormatNDJSON, FormatCSV}
+
+// progressEvery is how many rows are written between progress reports
+const progressEvery = 5000
+
+// ErrNotFound is returned for an export file that doesn't exist
+var ErrNotFound = errors.New("exports: not found")
+
+// Params are the options of an export job
+type Params struct {
+	Set              string `json:"set" enum:"documents,entities,triples"`
+	Format           string `json:"format" enum:"ndjson,csv"`
+	DatasetID        int    `json:"datasetId,omitempty" doc:"Only documents of this dataset, entities they mention and triples from them"`
+	EntityType       string `json:"entityType,omitempty" doc:"Only entities of this type, and triples with one as subject or object"`
+	IncludeText      bool   `json:"includeText,omitempty" doc:"documents only: include full text"`
+	ExcludeSensitive bool   `json:"excludeSensitive,omitempty" doc:"Leave out documents with content warnings, and triples from them"`
+	KeyID            int    `json:"keyId" doc:"API key that requested the export"`
+}
+
+// Result describes a written export
+type Result struct {
+	File   string `json:"file"`
+	Rows   int64  `json:"rows"`
+	Size   int64  `json:"size" doc:"bytes"`
+	SHA256 string `json:"sha256"`
+}
+
+// set is the query behind a set: its columns, those to mask, the FROM and
+// WHERE clauses and the arguments they take. With IncludeText, text takes
+// the place of the last column.
+type set struct {
+	columns []string
+	text    string
+	masked  []string
+	from    string
+	order   string
+	args    func(p Params) []any
+}
+
+var sets = map[string]set{
+	SetDocuments: {
+		columns: []string{
+			"d.id", "d.doc_id", "d.dataset_id", "d.document_type", "d.summary",
+			"d.date_earliest::text", "d.date_latest::text", "d.page_count",
+			"sensitivity_labels(d.id)",
+			`ARRAY(SELECT de.entity_id FROM document_entities de
+				JOIN entities e ON e.id = de.entity_id AND NOT e.protected AND e.deleted_at IS NULL
+				WHERE de.document_id = d.id ORDER BY de.entity_id)`,
+			"NULL::text",
+		},
+		text:   "d.full_text",
+		masked: []string{"summary", "full_text"},
+		from: `documents d
+			WHERE d.deleted_at IS NULL
+			  AND ($1 = 0 OR d.dataset_id = $1)
+			  AND ($2 = '' OR EXISTS (
+				  SELECT 1 FROM document_entities de JOIN entities e ON e.id = de.entity_id
+				  WHERE de.document_id = d.id AND e.entity_type::text = $2))
+			  AND NOT ($3 AND cardinality(sensitivity_labels(d.id)) > 0)`,
+		order: "d.id",
+		args: func(p Params) []any {
+			return []any{p.DatasetID, p.EntityType, p.ExcludeSensitive}
+		},
+	},
+	SetEntities: {
+		columns: []string{
+			"e.id", "e.canonical_name", "e.entity_type::text", "e.layer", "e.description",
+			"COALESCE(e.document_count, 0)", "COALESCE(e.connection_count, 0)",
+			"COALESCE(ARRAY(SELECT jsonb_array_elements_text(e.aliases)), '{}')",
+		},
+		masked: []string{"description"},
+		from: `entities e
+			WHERE e.deleted_at IS NULL AND NOT e.protected
+			  AND ($2 = '' OR e.entity_type::text = $2)
+			  AND ($1 = 0 OR EXISTS (
+				  SELECT 1 FROM document_entities de JOIN documents d ON d.id = de.document_id
+				  WHERE de.entity_id = e.id AND d.dataset_id = $1 AND d.deleted_at IS NULL))`,
+		order: "e.id",
+		args: func(p Params) []any {
+			return []any{p.DatasetID, p.EntityType}
+		},
+	},
+	SetTriples: {
+		columns: []string{
+			"t.id", "t.document_id", "t.subject_id", "s.canonical_name", "t.predicate",
+			"t.object_id", "o.canonical_name", "t.confidence::float8", "t.sentence",
+		},
+		masked: []string{"sentence"},
+		from: `triples t
+			JOIN documents d ON d.id = t.document_id AND d.deleted_at IS NULL
+			JOIN entities s ON s.id = t.subject_id AND NOT s.protected AND s.deleted_at IS NULL
+			JOIN entities o ON o.id = t.object_id AND NOT o.protected AND o.deleted_at IS NULL
+			WHERE ($1 = 0 OR d.dataset_id = $1)
+			  AND ($2 = '' OR s.entity_type::text = $2 OR o.entity_type::text = $2)
+			  AND NOT ($3 AND cardinality(sensitivity_labels(d.id)) > 0)`,
+		order: "t.id",
+		args: func(p Params) []any {
+			return []any{p.DatasetID, p.EntityType, p.ExcludeSensitive}
+		},
+	},
+}
+
+// names are the column names of a set's rows, as in the files' headers
+var names = map[string][]string{
+	SetDocuments: {"id", "doc_id", "dataset_id", "document_type", "summary", "date_earliest", "date_latest",
+		"page_count", "sensitivity", "entity_ids", "full_text"},
+	SetEntities: {"id", "canonical_name", "entity_type", "layer", "description", "document_count",
+		"connection_count", "aliases"},
+	SetTriples: {"id", "document_id", "subject_id", "subject_name", "predicate", "object_id", "object_name",
+		"confidence", "sentence"},
+}
+
+// FileName is the name of the export written for job id
+func FileName(id int64, p Params) string {
+	return fmt.Sprintf("export-%d-%s.%s.gz", id, p.Set, p.Format)
+}
+
+// Write exports the rows p selects to FileName(id, p) in dir, calling
+// progress now and then with the rows written so far and the total
+func Write(ctx context.Context, pool *pgxpool.Pool, dir string, id int64, p Params, progress func(done, total int64) error) (*Result, error) {
+	s, ok := sets[p.Set]
+	if !ok {
+		return nil, fmt.Errorf("exports: unknown set %q", p.Set)
+	}
+	if !slices.Contains(Formats, p.Format) {
+		return nil, fmt.Errorf("exports: unknown format %q", p.Format)
+	}
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, err
+	}
+
+	tx, err := pool.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	args := s.args(p)
+	var total int64
+	if err := tx.QueryRow(ctx, `SELECT COUNT(*) FROM `+s.from, args...).Scan(&total); err != nil {
+		return nil, err
+	}
+	masker, err := store.LoadMasker(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+
+	name := FileName(id, p)
+	final := filepath.Join(dir, name)
+	partial := final + ".partial"
+	defer os.Remove(partial)
+
+	f, err := os.Create(partial)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	h := sha256.New()
+	counted := &countingWriter{w: io.MultiWriter(f, h)}
+	gz := gzip.NewWriter(counted)
+	buf := bufio.NewWriterSize(gz, 64<<10)
+
+	enc := newEncoder(p.Format, buf, names[p.Set])
+	if err := enc.header(); err != nil {
+		return nil, err
+	}
+
+	var masked []int
+	for i, col := range names[p.Set] {
+		if slices.Contains(s.masked, col) {
+			masked = append(masked, i)
+		}
+	}
+
+	columns := slices.Clone(s.columns)
+	if p.IncludeText && s.text != "" {
+		columns[len(columns)-1] = s.text
+	}
+	rows, err := tx.Query(ctx, `SELECT `+strings.Join(columns, ", ")+` FROM `+s.from+` ORDER BY `+s.order, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var n int64
+	for rows.Next() {
+		values, err := rows.Values()
+		if err != nil {
+			return nil, err
+		}
+		for _, i := range masked {
+			if text, ok := values[i].(string); ok {
+				values[i] = masker.Mask(text)
+			}
+		}
+		if err := enc.row(values); err != nil {
+			return nil, err
+		}
+		n++
+		if n%progressEvery == 0 && progress != nil {
+			if err := progress(n, total); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := enc.flush(); err != nil {
+		return nil, err
+	}
+	if err := buf.Flush(); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	if err := f.Close(); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(partial, final); err != nil {
+		return nil, err
+	}
+	if progress != nil {
+		if err := progress(n, total); err != nil {
+			return nil, err
+		}
+	}
+	return &Result{File: name, Rows: n, Size: counted.n, SHA256: hex.EncodeToString(h.Sum(nil))}, nil
+}
+
+// Path returns the export file called name in dir
+func Path(dir, name string) (string, error) {
+	if name != filepath.Base(name) || !strings.HasPrefix(name, "export-") || !strings.HasSuffix(name, ".gz") {
+		return "", ErrNotFound
+	}
+	path := filepath.Join(dir, name)
+	if _, err := os.Stat(path); err != nil {
+		return "", ErrNotFound
+	}
+	return path, nil
+}
+
+// Clean removes the exports in dir, and any left half-written, older than
+// retention, and returns how many it removed
+func Clean(dir string, retention time.Duration) (int, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "export-*"))
+	if err != nil {
+		return 0, err
+	}
+	cutoff := time.Now().Add(-retention)
+	removed := 0
+	for _, path := range files {
+		info, err := os.Stat(path)
+		if err != nil || info.ModTime().After(cutoff) {
+			continue
+		}
+		if err := os.Remove(path); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// encoder writes rows in one format
+type encoder struct {
+	w       io.Writer
+	csv     *csv.Writer
+	columns []string
+	cells   []string
+}
+
+func newEncoder(format string, w io.Writer, columns []string) *encoder {
+	e := &encoder{w: w, columns: columns}
+	if format == FormatCSV {
+		e.csv = csv.NewWriter(w)
+		e.cells = make([]string, len(columns))
+	}
+	return e
+}
+
+func (e *encoder) header() error {
+	if e.csv == nil {
+		return nil
+	}
+	return e.csv.Write(e.columns)
+}
+
+// row writes one row: an NDJSON object with the columns in order, or a CSV
+// record whose cells read as the JSON values would
+func (e *encoder) row(values []any) error {
+	if e.csv != nil {
+		for i, v := range values {
+			raw, err := json.Marshal(v)
+			if err != nil {
+				return err
+			}
+			e.cells[i] = cell(raw)
+		}
+		return e.csv.Write(e.cells)
+	}
+
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, v := range values {
+		raw, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(strconv.Quote(e.columns[i]))
+		b.WriteByte(':')
+		b.Write(raw)
+	}
+	b.WriteString("}\n")
+	_, err := io.WriteString(e.w, b.String())
+	return err
+}
+
+func (e *encoder) flush() error {
+	if e.csv == nil {
+		return nil
+	}
+	e.csv.Flush()
+	return e.csv.Error()
+}
+
+// cell renders a JSON value as a CSV cell: strings unquoted, null empty,
+// arrays as JSON. Text that a spreadsheet would run as a formula is prefixed
+// with a quote, since document text is untrusted.
+func cell(raw json.RawMessage) string {
+	if string(raw) == "null" {
+		return ""
+	}
+	if raw[0] != '"' {
+		return string(raw)
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return string(raw)
+	}
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
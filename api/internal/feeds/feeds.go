@@ -0,0 +1,224 @@
+// Package feeds publishes Atom feeds (RFC 4287) of newly added documents
+// and validated pattern findings, for feed readers. Entry IDs are the
+// entries' API URLs, so they stay the same as long as PUBLIC_URL does.
+package feeds
+
+import (
+	"context"
+	"encoding/xml"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/subculture-collective/epstein-db/api/internal/store"
+)
+
+// MediaAtom is the media type of Atom feeds
+const MediaAtom = "application/atom+xml"
+
+// Size is how many entries a feed holds
+const Size = 50
+
+// author is credited on every feed
+const author = "Epstein Files Database"
+
+// Feed is an Atom feed
+type Feed struct {
+	XMLName xml.Name  `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string    `xml:"id"`
+	Title   string    `xml:"title"`
+	Updated time.Time `xml:"updated"`
+	Author  Person    `xml:"author"`
+	Links   []Link    `xml:"link"`
+	Entries []Entry   `xml:"entry"`
+}
+
+// Entry is one item of a feed
+type Entry struct {
+	ID         string     `xml:"id"`
+	Title      string     `xml:"title"`
+	Updated    time.Time  `xml:"updated"`
+	Published  time.Time  `xml:"published"`
+	Links      []Link     `xml:"link"`
+	Categories []Category `xml:"category"`
+	Summary    *Text      `xml:"summary,omitempty"`
+	Content    *Text      `xml:"content,omitempty"`
+}
+
+// Person is an author
+type Person struct {
+	Name string `xml:"name"`
+}
+
+// Link points from a feed or entry to a resource
+type Link struct {
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+	Href string `xml:"href,attr"`
+}
+
+// Category labels an entry
+type Category struct {
+	Term  string `xml:"term,attr"`
+	Label string `xml:"label,attr,omitempty"`
+}
+
+// Text is plain text
+type Text struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+// Write writes the feed as an XML document
+func (f *Feed) Write(w io.Writer) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(f); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// Documents returns the feed of the most recently added documents,
+// optionally from one dataset. base is the API's public URL. Feeds are
+// public, so documents of restricted datasets are listed without their
+// summaries, and protected names are masked in the rest.
+func Documents(ctx context.Context, pool *pgxpool.Pool, base string, datasetID int) (*Feed, error) {
+	self := base + "/feeds/documents.atom"
+	if datasetID > 0 {
+		self += "?dataset=" + strconv.Itoa(datasetID)
+	}
+	f := newFeed(self, "New documents")
+	m, err := store.LoadMasker(ctx, pool)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := pool.Query(ctx, `
+		SELECT d.id, d.doc_id, d.dataset_id, COALESCE(ds.name, ''),
+			   COALESCE(d.document_type, ''),
+			   CASE WHEN ds.visibility = 'restricted' THEN '' ELSE COALESCE(d.summary, '') END,
+			   CASE WHEN ds.visibility = 'restricted' THEN '' ELSE COALESCE(d.detailed_summary, '') END,
+			   COALESCE(d.created_at, NOW()), COALESCE(d.updated_at, d.created_at, NOW())
+		FROM documents d
+		LEFT JOIN datasets ds ON ds.id = d.dataset_id
+		WHERE ($1 = 0 OR d.dataset_id = $1) AND d.deleted_at IS NULL
+		ORDER BY d.id DESC
+		LIMIT $2
+	`, datasetID, Size)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			id, dataset                 int
+			docID, datasetName, docType string
+			summary, detailed           string
+			published, updated          time.Time
+		)
+		if err := rows.Scan(&id, &docID, &dataset, &datasetName, &docType, &summary, &detailed, &published, &updated); err != nil {
+			return nil, err
+		}
+
+		e := newEntry(base+"/api/documents/"+strconv.Itoa(id), docID, published, updated)
+		if docType != "" {
+			e.Title += ": " + docType
+			e.Categories = append(e.Categories, Category{Term: docType})
+		}
+		if datasetName == "" {
+			datasetName = "Dataset " + strconv.Itoa(dataset)
+		}
+		e.Categories = append(e.Categories, Category{Term: "dataset-" + strconv.Itoa(dataset), Label: datasetName})
+		if summary != "" {
+			e.Summary = &Text{Type: "text", Body: m.Mask(summary)}
+		}
+		if detailed != "" {
+			e.Content = &Text{Type: "text", Body: m.Mask(detailed)}
+		}
+		f.add(e)
+	}
+	return f, rows.Err()
+}
+
+// Patterns returns the feed of the most recently validated pattern
+// findings. base is the API's public URL. Protected names are masked in
+// their descriptions.
+func Patterns(ctx context.Context, pool *pgxpool.Pool, base string) (*Feed, error) {
+	f := newFeed(base+"/feeds/patterns.atom", "Validated patterns")
+	m, err := store.LoadMasker(ctx, pool)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := pool.Query(ctx, `
+		SELECT id, title, description, COALESCE(pattern_type, ''), confidence,
+			   COALESCE(discovered_at, NOW()), COALESCE(validated_at, discovered_at, NOW())
+		FROM pattern_findings
+		WHERE status = 'validated'
+		ORDER BY COALESCE(validated_at, discovered_at) DESC NULLS LAST, id DESC
+		LIMIT $1
+	`, Size)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			id                      int
+			title, description, typ string
+			confidence              *float32
+			published, updated      time.Time
+		)
+		if err := rows.Scan(&id, &title, &description, &typ, &confidence, &published, &updated); err != nil {
+			return nil, err
+		}
+
+		e := newEntry(base+"/api/patterns/"+strconv.Itoa(id), title, published, updated)
+		if typ != "" {
+			e.Categories = append(e.Categories, Category{Term: typ})
+		}
+		summary := m.Mask(description)
+		if confidence != nil {
+			summary += " (confidence " + strconv.FormatFloat(float64(*confidence), 'f', 2, 32) + ")"
+		}
+		e.Summary = &Text{Type: "text", Body: summary}
+		f.add(e)
+	}
+	return f, rows.Err()
+}
+
+func newFeed(self, title string) *Feed {
+	return &Feed{
+		ID:     self,
+		Title:  author + ": " + title,
+		Author: Person{Name: author},
+		Links:  []Link{{Rel: "self", Type: MediaAtom, Href: self}},
+	}
+}
+
+func newEntry(url, title string, published, updated time.Time) Entry {
+	return Entry{
+		ID:        url,
+		Title:     title,
+		Published: published.UTC().Truncate(time.Second),
+		Updated:   updated.UTC().Truncate(time.Second),
+		Links:     []Link{{Rel: "alternate", Type: "application/json", Href: url}},
+	}
+}
+
+// add appends e, keeping the feed's updated time at its newest entry's
+func (f *Feed) add(e Entry) {
+	f.Entries = append(f.Entries, e)
+	if e.Updated.After(f.Updated) {
+		f.Updated = e.Updated
+	}
+}
@@ -0,0 +1,32 @@
+// Package fold finds names in text regardless of case. Searching
+// strings.ToLower(text) instead gives offsets into the lowered copy, which
+// drift from the text wherever lowering changes a rune's length, as it does
+// for 'İ' and 'ẞ', so slicing the text with them cuts in the wrong place.
+package fold
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// Index returns the byte offsets in s of the first match of substr under
+// Unicode case folding, or -1, -1 if there is none
+func Index(s, substr string) (start, end int) {
+	n := utf8.RuneCountInString(substr)
+	if n == 0 {
+		return 0, 0
+	}
+	for start = 0; start < len(s); {
+		end = start
+		for i := 0; i < n && end < len(s); i++ {
+			_, size := utf8.DecodeRuneInString(s[end:])
+			end += size
+		}
+		if strings.EqualFold(s[start:end], substr) {
+			return start, end
+		}
+		_, size := utf8.DecodeRuneInString(s[start:])
+		start += size
+	}
+	return -1, -1
+}
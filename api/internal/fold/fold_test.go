@@ -0,0 +1,31 @@
+package fold
+
+import "testing"
+
+func TestIndex(t *testing.T) {
+	cases := []struct {
+		name, s, substr string
+		want            string
+	}{
+		{"ascii", "met JANE ROE there", "jane roe", "JANE ROE"},
+		{"not found", "met Jane there", "jane roe", ""},
+		{"dotted capital I before", "İİİ met Jane Roe", "jane roe", "Jane Roe"},
+		{"capital sharp s before", "ẞẞ met Jane Roe", "jane roe", "Jane Roe"},
+		{"capital sharp s in the name", "STRAẞE 5", "straße", "STRAẞE"},
+		{"accented", "from ÉLOISE", "éloise", "ÉLOISE"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			start, end := Index(tc.s, tc.substr)
+			if tc.want == "" {
+				if start != -1 || end != -1 {
+					t.Fatalf("Index(%q, %q) = %d, %d, want -1, -1", tc.s, tc.substr, start, end)
+				}
+				return
+			}
+			if start < 0 || tc.s[start:end] != tc.want {
+				t.Fatalf("Index(%q, %q) = %d, %d, want %q", tc.s, tc.substr, start, end, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package graph
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Loader batches lookups made by concurrently executing resolvers into a
+// single fetch and caches the results for the rest of the request
+type Loader[K comparable, V any] struct {
+	fetch func(ctx context.Context, keys []K) (map[K]V, error)
+	wait  time.Duration
+
+	mu    sync.Mutex
+	cache map[K]V
+	batch *batch[K, V]
+}
+
+type batch[K comparable, V any] struct {
+	keys    []K
+	seen    map[K]bool
+	done    chan struct{}
+	results map[K]V
+	err     error
+}
+
+// NewLoader creates a loader that waits up to wait for more keys before
+// calling fetch. Keys missing from fetch's result load as the zero value.
+func NewLoader[K comparable, V any](wait time.Duration, fetch func(ctx context.Context, keys []K) (map[K]V, error)) *Loader[K, V] {
+	return &Loader[K, V]{
+		fetch: fetch,
+		wait:  wait,
+		cache: make(map[K]V),
+	}
+}
+
+// Load returns the value for key, joining the pending batch
+func (l *Loader[K, V]) Load(ctx context.Context, key K) (V, error) {
+	l.mu.Lock()
+	if v, ok := l.cache[key]; ok {
+		l.mu.Unlock()
+		return v, nil
+	}
+
+	b := l.batch
+	if b == nil {
+		b = &batch[K, V]{seen: make(map[K]bool), done: make(chan struct{})}
+		l.batch = b
+		time.AfterFunc(l.wait, func() { l.run(ctx, b) })
+	}
+	if !b.seen[key] {
+		b.seen[key] = true
+		b.keys = append(b.keys, key)
+	}
+	l.mu.Unlock()
+
+	select {
+	case <-b.done:
+	case <-ctx.Done():
+		var zero V
+		return zero, ctx.Err()
+	}
+	return b.results[key], b.err
+}
+
+// Prime caches a value that was fetched some other way
+func (l *Loader[K, V]) Prime(key K, value V) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.cache[key] = value
+}
+
+func (l *Loader[K, V]) run(ctx context.Context, b *batch[K, V]) {
+	l.mu.Lock()
+	l.batch = nil
+	l.mu.Unlock()
+
+	b.results, b.err = l.fetch(ctx, b.keys)
+
+	if b.err == nil {
+		l.mu.Lock()
+		for _, k := range b.keys {
+			l.cache[k] = b.results[k]
+		}
+		l.mu.Unlock()
+	}
+	close(b.done)
+}
@@ -0,0 +1,323 @@
+package graph
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/subculture-collective/epstein-db/api/internal/graph/model"
+	"github.com/subculture-collective/epstein-db/api/internal/store"
+)
+
+// batchWait is how long a loader collects keys before querying. Sibling
+// resolvers run concurrently, so a couple of milliseconds is enough.
+const batchWait = 2 * time.Millisecond
+
+// limitKey identifies a capped list belonging to one parent
+type limitKey struct {
+	ID    int
+	Limit int
+}
+
+// Loaders are the per-request dataloaders
+type Loaders struct {
+	Entity      *Loader[int, *model.Entity]
+	Mentions    *Loader[int, []*model.Mention]
+	Connections *Loader[limitKey, []*model.Connection]
+	Documents   *Loader[limitKey, []*model.Document]
+	Crossref    *Loader[int, []*model.CrossrefMatch]
+}
+
+type loadersKey struct{}
+
+// NewLoaders creates a fresh set of loaders for one request
+func NewLoaders(pool *pgxpool.Pool) *Loaders {
+	return &Loaders{
+		Entity:      NewLoader(batchWait, entityFetcher(pool)),
+		Mentions:    NewLoader(batchWait, mentionsFetcher(pool)),
+		Connections: NewLoader(batchWait, connectionsFetcher(pool)),
+		Documents:   NewLoader(batchWait, documentsFetcher(pool)),
+		Crossref:    NewLoader(batchWait, crossrefFetcher(pool)),
+	}
+}
+
+// Middleware attaches new loaders to each request so batches and caches
+// never leak between requests
+func Middleware(pool *pgxpool.Pool, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), loadersKey{}, NewLoaders(pool))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func loadersFor(ctx context.Context) *Loaders {
+	return ctx.Value(loadersKey{}).(*Loaders)
+}
+
+const entityColumns = `id, canonical_name, entity_type::text, layer, description,
+	document_count, connection_count,
+	COALESCE(ARRAY(SELECT jsonb_array_elements_text(aliases)), '{}')`
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanEntity(row scanner) (*model.Entity, error) {
+	var e model.Entity
+	err := row.Scan(&e.ID, &e.CanonicalName, &e.EntityType, &e.Layer, &e.Description,
+		&e.DocumentCount, &e.ConnectionCount, &e.Aliases)
+	if err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+func entityFetcher(pool *pgxpool.Pool) func(context.Context, []int) (map[int]*model.Entity, error) {
+	return func(ctx context.Context, ids []int) (map[int]*model.Entity, error) {
+		rows, err := pool.Query(ctx, `SELECT `+entityColumns+` FROM entities WHERE id = ANY($1) AND deleted_at IS NULL AND NOT protected`, ids)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		out := make(map[int]*model.Entity, len(ids))
+		for rows.Next() {
+			e, err := scanEntity(rows)
+			if err != nil {
+				return nil, err
+			}
+			out[e.ID] = e
+		}
+		return out, rows.Err()
+	}
+}
+
+func mentionsFetcher(pool *pgxpool.Pool) func(context.Context, []int) (map[int][]*model.Mention, error) {
+	return func(ctx context.Context, docIDs []int) (map[int][]*model.Mention, error) {
+		rows, err := pool.Query(ctx, `
+			SELECT document_id, entity_id, mention_count
+			FROM document_entities
+			WHERE document_id = ANY($1)
+			  AND entity_id NOT IN (SELECT id FROM entities WHERE protected)
+			ORDER BY mention_count DESC
+		`, docIDs)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		out := make(map[int][]*model.Mention, len(docIDs))
+		for rows.Next() {
+			var docID int
+			var m model.Mention
+			if err := rows.Scan(&docID, &m.EntityID, &m.MentionCount); err != nil {
+				return nil, err
+			}
+			out[docID] = append(out[docID], &m)
+		}
+		return out, rows.Err()
+	}
+}
+
+// byLimit groups keys that share a limit so each group is one query
+func byLimit(keys []limitKey) map[int][]int {
+	groups := make(map[int][]int)
+	for _, k := range keys {
+		groups[k.Limit] = append(groups[k.Limit], k.ID)
+	}
+	return groups
+}
+
+func connectionsFetcher(pool *pgxpool.Pool) func(context.Context, []limitKey) (map[limitKey][]*model.Connection, error) {
+	return func(ctx context.Context, keys []limitKey) (map[limitKey][]*model.Connection, error) {
+		out := make(map[limitKey][]*model.Connection, len(keys))
+
+		for limit, ids := range byLimit(keys) {
+			rows, err := pool.Query(ctx, `
+				SELECT entity_id, other_id, shared_docs
+				FROM (
+					SELECT de1.entity_id, de2.entity_id AS other_id,
+						   COUNT(DISTINCT de1.document_id) AS shared_docs,
+						   ROW_NUMBER() OVER (
+							   PARTITION BY de1.entity_id
+							   ORDER BY COUNT(DISTINCT de1.document_id) DESC, de2.entity_id
+						   ) AS rn
+					FROM document_entities de1
+					JOIN document_entities de2 ON de1.document_id = de2.document_id AND de1.entity_id != de2.entity_id
+					WHERE de1.entity_id = ANY($1)
+					  AND de2.entity_id NOT IN (SELECT id FROM entities WHERE protected)
+					GROUP BY de1.entity_id, de2.entity_id
+				) ranked
+				WHERE rn <= $2
+				ORDER BY entity_id, rn
+			`, ids, limit)
+			if err != nil {
+				return nil, err
+			}
+
+			for rows.Next() {
+				var id int
+				var c model.Connection
+				if err := rows.Scan(&id, &c.EntityID, &c.SharedDocs); err != nil {
+					rows.Close()
+					return nil, err
+				}
+				k := limitKey{ID: id, Limit: limit}
+				out[k] = append(out[k], &c)
+			}
+			rows.Close()
+			if err := rows.Err(); err != nil {
+				return nil, err
+			}
+		}
+
+		return out, nil
+	}
+}
+
+const documentColumns = `d.id, d.doc_id, d.dataset_id, d.document_type, d.summary, d.detailed_summary,
+	d.date_earliest::text, d.date_latest::text, d.page_count, dataset_restricted(d.dataset_id), sensitivity_labels(d.id)`
+
+// scanDocument reads documentColumns and then dest, leaving out the
+// summaries of a document of a restricted dataset unless ctx allows them
+// and masking protected names in them
+func scanDocument(ctx context.Context, m *store.Masker, row scanner, dest ...any) (*model.Document, error) {
+	var d model.Document
+	var restricted bool
+	err := row.Scan(append([]any{&d.ID, &d.DocID, &d.DatasetID, &d.DocumentType, &d.Summary,
+		&d.DetailedSummary, &d.DateEarliest, &d.DateLatest, &d.PageCount, &restricted, &d.Sensitivity}, dest...)...)
+	if err != nil {
+		return nil, err
+	}
+	if restricted && !store.CanReadRestricted(ctx) {
+		d.Summary, d.DetailedSummary = nil, nil
+	}
+	m.MaskPtr(d.Summary)
+	m.MaskPtr(d.DetailedSummary)
+	return &d, nil
+}
+
+func documentsFetcher(pool *pgxpool.Pool) func(context.Context, []limitKey) (map[limitKey][]*model.Document, error) {
+	return func(ctx context.Context, keys []limitKey) (map[limitKey][]*model.Document, error) {
+		out := make(map[limitKey][]*model.Document, len(keys))
+		m, err := store.LoadMasker(ctx, pool)
+		if err != nil {
+			return nil, err
+		}
+
+		for limit, ids := range byLimit(keys) {
+			rows, err := pool.Query(ctx, `
+				SELECT `+documentColumns+`, ranked.entity_id
+				FROM (
+					SELECT de.entity_id, de.document_id,
+						   ROW_NUMBER() OVER (
+							   PARTITION BY de.entity_id
+							   ORDER BY d.date_earliest DESC NULLS LAST, d.id
+						   ) AS rn
+					FROM document_entities de
+					JOIN documents d ON d.id = de.document_id
+					WHERE de.entity_id = ANY($1) AND d.deleted_at IS NULL
+					  AND `+store.Insensitive("d", "$3")+`
+				) ranked
+				JOIN documents d ON d.id = ranked.document_id
+				WHERE ranked.rn <= $2
+				ORDER BY ranked.entity_id, ranked.rn
+			`, ids, limit, store.ExcludesSensitive(ctx))
+			if err != nil {
+				return nil, err
+			}
+
+			for rows.Next() {
+				var id int
+				d, err := scanDocument(ctx, m, rows, &id)
+				if err != nil {
+					rows.Close()
+					return nil, err
+				}
+				k := limitKey{ID: id, Limit: limit}
+				out[k] = append(out[k], d)
+			}
+			rows.Close()
+			if err := rows.Err(); err != nil {
+				return nil, err
+			}
+		}
+
+		return out, nil
+	}
+}
+
+func crossrefFetcher(pool *pgxpool.Pool) func(context.Context, []int) (map[int][]*model.CrossrefMatch, error) {
+	return func(ctx context.Context, ids []int) (map[int][]*model.CrossrefMatch, error) {
+		// The matched record is built as JSON with the model's field names
+		// so that the three sources can share one query
+		rows, err := pool.Query(ctx, `
+			SELECT m.entity_id, m.source::text, m.match_score, m.match_method, COALESCE(m.verified, FALSE),
+				CASE WHEN p.id IS NOT NULL THEN jsonb_build_object(
+					'id', p.id, 'borrowerName', p.borrower_name, 'borrowerCity', p.borrower_city,
+					'borrowerState', p.borrower_state, 'loanAmount', p.loan_amount,
+					'forgivenessAmount', p.forgiveness_amount, 'lender', p.lender,
+					'dateApproved', p.date_approved) END,
+				CASE WHEN f.id IS NOT NULL THEN jsonb_build_object(
+					'id', f.id, 'contributorName', f.contributor_name, 'contributorCity', f.contributor_city,
+					'contributorState', f.contributor_state, 'employer', f.contributor_employer,
+					'occupation', f.contributor_occupation, 'candidateName', f.candidate_name,
+					'committeeName', f.committee_name, 'amount', f.amount,
+					'contributionDate', f.contribution_date) END,
+				CASE WHEN g.id IS NOT NULL THEN jsonb_build_object(
+					'id', g.id, 'recipientName', g.recipient_name, 'recipientCity', g.recipient_city,
+					'recipientState', g.recipient_state, 'awardingAgency', g.awarding_agency,
+					'fundingAgency', g.funding_agency, 'awardAmount', g.award_amount,
+					'awardDate', g.award_date, 'description', g.description,
+					'cfdaTitle', g.cfda_title) END
+			FROM entity_crossref_matches m
+			LEFT JOIN ppp_loans p ON m.source = 'ppp' AND p.id = m.source_id
+			LEFT JOIN fec_contributions f ON m.source = 'fec' AND f.id = m.source_id
+			LEFT JOIN federal_grants g ON m.source = 'grants' AND g.id = m.source_id
+			WHERE m.entity_id = ANY($1)
+			  AND NOT COALESCE(m.false_positive, FALSE)
+			ORDER BY m.match_score DESC
+		`, ids)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		out := make(map[int][]*model.CrossrefMatch, len(ids))
+		for rows.Next() {
+			var id int
+			var m model.CrossrefMatch
+			var source string
+			var ppp, fec, grant []byte
+			if err := rows.Scan(&id, &source, &m.MatchScore, &m.MatchMethod, &m.Verified, &ppp, &fec, &grant); err != nil {
+				return nil, err
+			}
+			m.Source = model.MatchSource(source)
+
+			if ppp != nil {
+				m.PPPLoan = &model.PPPLoan{}
+				if err := json.Unmarshal(ppp, m.PPPLoan); err != nil {
+					return nil, err
+				}
+			}
+			if fec != nil {
+				m.FECContribution = &model.FECContribution{}
+				if err := json.Unmarshal(fec, m.FECContribution); err != nil {
+					return nil, err
+				}
+			}
+			if grant != nil {
+				m.Grant = &model.Grant{}
+				if err := json.Unmarshal(grant, m.Grant); err != nil {
+					return nil, err
+				}
+			}
+
+			out[id] = append(out[id], &m)
+		}
+		return out, rows.Err()
+	}
+}
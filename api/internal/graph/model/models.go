@@ -0,0 +1,118 @@
+// Package model holds the Go types bound to the GraphQL schema. Fields that
+// are missing here (relationships, full text) are resolved lazily.
+package model
+
+// Entity is a person, organization or other named thing
+type Entity struct {
+	ID              int      `json:"id"`
+	CanonicalName   string   `json:"canonicalName"`
+	EntityType      string   `json:"entityType"`
+	Layer           *int     `json:"layer"`
+	Description     *string  `json:"description"`
+	DocumentCount   *int     `json:"documentCount"`
+	ConnectionCount *int     `json:"connectionCount"`
+	Aliases         []string `json:"aliases"`
+}
+
+// Connection is an entity that shares documents with another
+type Connection struct {
+	EntityID   int `json:"-"`
+	SharedDocs int `json:"sharedDocs"`
+}
+
+// Document is a document without its full text
+type Document struct {
+	ID              int      `json:"id"`
+	DocID           string   `json:"docId"`
+	DatasetID       int      `json:"datasetId"`
+	DocumentType    *string  `json:"documentType"`
+	Summary         *string  `json:"summary"`
+	DetailedSummary *string  `json:"detailedSummary"`
+	DateEarliest    *string  `json:"dateEarliest"`
+	DateLatest      *string  `json:"dateLatest"`
+	PageCount       *int     `json:"pageCount"`
+	Sensitivity     []string `json:"sensitivity"`
+}
+
+// Mention is an entity mentioned in a document
+type Mention struct {
+	EntityID     int `json:"-"`
+	MentionCount int `json:"mentionCount"`
+}
+
+// Network is an entity co-occurrence graph
+type Network struct {
+	Nodes []*Entity `json:"nodes"`
+	Edges []*Edge   `json:"edges"`
+}
+
+// Edge links two entities that appear in the same documents
+type Edge struct {
+	SourceID int `json:"-"`
+	TargetID int `json:"-"`
+	Weight   int `json:"weight"`
+}
+
+// CrossrefMatch links an entity to a record in an external dataset. Only
+// the record for the match's source is set.
+type CrossrefMatch struct {
+	Source          MatchSource      `json:"source"`
+	MatchScore      float64          `json:"matchScore"`
+	MatchMethod     *string          `json:"matchMethod"`
+	Verified        bool             `json:"verified"`
+	PPPLoan         *PPPLoan         `json:"pppLoan"`
+	FECContribution *FECContribution `json:"fecContribution"`
+	Grant           *Grant           `json:"grant"`
+}
+
+// PPPLoan is a Paycheck Protection Program loan
+type PPPLoan struct {
+	ID                int      `json:"id"`
+	BorrowerName      string   `json:"borrowerName"`
+	BorrowerCity      *string  `json:"borrowerCity"`
+	BorrowerState     *string  `json:"borrowerState"`
+	LoanAmount        *float64 `json:"loanAmount"`
+	ForgivenessAmount *float64 `json:"forgivenessAmount"`
+	Lender            *string  `json:"lender"`
+	DateApproved      *string  `json:"dateApproved"`
+}
+
+// FECContribution is a political contribution
+type FECContribution struct {
+	ID               int      `json:"id"`
+	ContributorName  string   `json:"contributorName"`
+	ContributorCity  *string  `json:"contributorCity"`
+	ContributorState *string  `json:"contributorState"`
+	Employer         *string  `json:"employer"`
+	Occupation       *string  `json:"occupation"`
+	CandidateName    *string  `json:"candidateName"`
+	CommitteeName    *string  `json:"committeeName"`
+	Amount           *float64 `json:"amount"`
+	ContributionDate *string  `json:"contributionDate"`
+}
+
+// Grant is a federal grant award
+type Grant struct {
+	ID             int      `json:"id"`
+	RecipientName  string   `json:"recipientName"`
+	RecipientCity  *string  `json:"recipientCity"`
+	RecipientState *string  `json:"recipientState"`
+	AwardingAgency *string  `json:"awardingAgency"`
+	FundingAgency  *string  `json:"fundingAgency"`
+	AwardAmount    *float64 `json:"awardAmount"`
+	AwardDate      *string  `json:"awardDate"`
+	Description    *string  `json:"description"`
+	CFDATitle      *string  `json:"cfdaTitle"`
+}
+
+// Pattern is a pattern finding
+type Pattern struct {
+	ID           int      `json:"id"`
+	Title        string   `json:"title"`
+	Description  string   `json:"description"`
+	PatternType  *string  `json:"patternType"`
+	Confidence   *float64 `json:"confidence"`
+	Status       string   `json:"status"`
+	DiscoveredAt string   `json:"discoveredAt"`
+	EntityIDs    []int    `json:"-"`
+}
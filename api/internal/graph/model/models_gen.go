@@ -0,0 +1,55 @@
+// Code generated by github.com/99designs/gqlgen, DO NOT EDIT.
+
+package model
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+)
+
+type Query struct {
+}
+
+type MatchSource string
+
+const (
+	MatchSourcePpp    MatchSource = "ppp"
+	MatchSourceFec    MatchSource = "fec"
+	MatchSourceGrants MatchSource = "grants"
+)
+
+var AllMatchSource = []MatchSource{
+	MatchSourcePpp,
+	MatchSourceFec,
+	MatchSourceGrants,
+}
+
+func (e MatchSource) IsValid() bool {
+	switch e {
+	case MatchSourcePpp, MatchSourceFec, MatchSourceGrants:
+		return true
+	}
+	return false
+}
+
+func (e MatchSource) String() string {
+	return string(e)
+}
+
+func (e *MatchSource) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = MatchSource(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid MatchSource", str)
+	}
+	return nil
+}
+
+func (e MatchSource) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
@@ -0,0 +1,86 @@
+package graph
+
+//go:generate go run github.com/99designs/gqlgen generate
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/extension"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/subculture-collective/epstein-db/api/internal/graph/model"
+)
+
+// maxComplexity bounds how much nesting a single query may request
+const maxComplexity = 500
+
+// Resolver is the root resolver. Per-request state such as dataloaders
+// lives in the request context, not here.
+type Resolver struct {
+	pool *pgxpool.Pool
+}
+
+// NewResolver creates the root resolver
+func NewResolver(pool *pgxpool.Pool) *Resolver {
+	return &Resolver{pool: pool}
+}
+
+// Handler serves GraphQL queries against pool
+func Handler(pool *pgxpool.Pool) http.Handler {
+	srv := handler.NewDefaultServer(NewExecutableSchema(Config{Resolvers: NewResolver(pool)}))
+	srv.Use(extension.FixedComplexityLimit(maxComplexity))
+	return Middleware(pool, srv)
+}
+
+// collectEntities scans entity rows and primes the entity loader with them
+// so nested references to the same entities don't query again
+func (r *Resolver) collectEntities(ctx context.Context, rows pgx.Rows) ([]*model.Entity, error) {
+	defer rows.Close()
+
+	loader := loadersFor(ctx).Entity
+	var entities []*model.Entity
+	for rows.Next() {
+		e, err := scanEntity(rows)
+		if err != nil {
+			return nil, err
+		}
+		loader.Prime(e.ID, e)
+		entities = append(entities, e)
+	}
+	return entities, rows.Err()
+}
+
+const patternColumns = `id, title, description, pattern_type, confidence, status,
+	discovered_at::text, COALESCE(entity_ids, '{}')`
+
+func scanPattern(row scanner) (*model.Pattern, error) {
+	var p model.Pattern
+	err := row.Scan(&p.ID, &p.Title, &p.Description, &p.PatternType, &p.Confidence,
+		&p.Status, &p.DiscoveredAt, &p.EntityIDs)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// clamp returns *v, or def when v is nil, capped at max when max >= 0
+func clamp(v *int, def, max int) int {
+	n := def
+	if v != nil && *v >= 0 {
+		n = *v
+	}
+	if max >= 0 && n > max {
+		n = max
+	}
+	return n
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
@@ -0,0 +1,312 @@
+package graph
+
+// This file will be automatically regenerated based on the schema, any resolver implementations
+// will be copied through when generating and any unknown code will be moved to the end.
+// Code generated by github.com/99designs/gqlgen version v0.17.49
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/subculture-collective/epstein-db/api/internal/graph/model"
+	"github.com/subculture-collective/epstein-db/api/internal/store"
+)
+
+// Entity is the resolver for the entity field.
+func (r *connectionResolver) Entity(ctx context.Context, obj *model.Connection) (*model.Entity, error) {
+	return loadersFor(ctx).Entity.Load(ctx, obj.EntityID)
+}
+
+// Text is the resolver for the text field.
+func (r *documentResolver) Text(ctx context.Context, obj *model.Document) (*string, error) {
+	var text *string
+	var readable bool
+	err := r.pool.QueryRow(ctx, `SELECT full_text, `+store.Readable("d", "$2")+` FROM documents d WHERE id = $1 AND deleted_at IS NULL`,
+		obj.ID, store.CanReadRestricted(ctx)).Scan(&text, &readable)
+	if err == nil && !readable {
+		return nil, errors.New("an API key is required to read the text of documents of restricted datasets")
+	}
+	if err != nil || text == nil {
+		return text, err
+	}
+	m, err := store.LoadMasker(ctx, r.pool)
+	if err != nil {
+		return nil, err
+	}
+	m.MaskPtr(text)
+	return text, nil
+}
+
+// Mentions is the resolver for the mentions field.
+func (r *documentResolver) Mentions(ctx context.Context, obj *model.Document) ([]*model.Mention, error) {
+	return loadersFor(ctx).Mentions.Load(ctx, obj.ID)
+}
+
+// Source is the resolver for the source field.
+func (r *edgeResolver) Source(ctx context.Context, obj *model.Edge) (*model.Entity, error) {
+	return loadersFor(ctx).Entity.Load(ctx, obj.SourceID)
+}
+
+// Target is the resolver for the target field.
+func (r *edgeResolver) Target(ctx context.Context, obj *model.Edge) (*model.Entity, error) {
+	return loadersFor(ctx).Entity.Load(ctx, obj.TargetID)
+}
+
+// Connections is the resolver for the connections field.
+func (r *entityResolver) Connections(ctx context.Context, obj *model.Entity, limit *int) ([]*model.Connection, error) {
+	return loadersFor(ctx).Connections.Load(ctx, limitKey{ID: obj.ID, Limit: clamp(limit, 50, 200)})
+}
+
+// Documents is the resolver for the documents field.
+func (r *entityResolver) Documents(ctx context.Context, obj *model.Entity, limit *int) ([]*model.Document, error) {
+	return loadersFor(ctx).Documents.Load(ctx, limitKey{ID: obj.ID, Limit: clamp(limit, 50, 200)})
+}
+
+// CrossrefMatches is the resolver for the crossrefMatches field.
+func (r *entityResolver) CrossrefMatches(ctx context.Context, obj *model.Entity, source *model.MatchSource) ([]*model.CrossrefMatch, error) {
+	matches, err := loadersFor(ctx).Crossref.Load(ctx, obj.ID)
+	if err != nil || source == nil {
+		return matches, err
+	}
+
+	var filtered []*model.CrossrefMatch
+	for _, m := range matches {
+		if m.Source == *source {
+			filtered = append(filtered, m)
+		}
+	}
+	return filtered, nil
+}
+
+// Entity is the resolver for the entity field.
+func (r *mentionResolver) Entity(ctx context.Context, obj *model.Mention) (*model.Entity, error) {
+	return loadersFor(ctx).Entity.Load(ctx, obj.EntityID)
+}
+
+// Entities is the resolver for the entities field.
+func (r *patternResolver) Entities(ctx context.Context, obj *model.Pattern) ([]*model.Entity, error) {
+	loader := loadersFor(ctx).Entity
+
+	var entities []*model.Entity
+	for _, id := range obj.EntityIDs {
+		e, err := loader.Load(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if e != nil {
+			entities = append(entities, e)
+		}
+	}
+	return entities, nil
+}
+
+// Entity is the resolver for the entity field.
+func (r *queryResolver) Entity(ctx context.Context, id int) (*model.Entity, error) {
+	return loadersFor(ctx).Entity.Load(ctx, id)
+}
+
+// Entities is the resolver for the entities field.
+func (r *queryResolver) Entities(ctx context.Context, q *string, typeArg *string, layer *int, limit *int) ([]*model.Entity, error) {
+	if typeArg != nil && *typeArg != "" && !slices.Contains(store.EntityTypes, *typeArg) {
+		return nil, fmt.Errorf("type must be one of %s", strings.Join(store.EntityTypes, ", "))
+	}
+	rows, err := r.pool.Query(ctx, `
+		SELECT `+entityColumns+`
+		FROM entities
+		WHERE ($1 = '' OR canonical_name ILIKE $5 OR canonical_name % $1)
+		  AND ($2 = '' OR entity_type = $2::entity_type)
+		  AND ($3::int IS NULL OR layer = $3)
+		  AND deleted_at IS NULL AND NOT protected
+		ORDER BY
+			CASE WHEN $1 != '' THEN similarity(canonical_name, $1) ELSE 0 END DESC,
+			document_count DESC
+		LIMIT $4
+	`, deref(q), deref(typeArg), layer, clamp(limit, 20, 100), store.Contains(deref(q)))
+	if err != nil {
+		return nil, err
+	}
+	return r.collectEntities(ctx, rows)
+}
+
+// Document is the resolver for the document field.
+func (r *queryResolver) Document(ctx context.Context, id int) (*model.Document, error) {
+	m, err := store.LoadMasker(ctx, r.pool)
+	if err != nil {
+		return nil, err
+	}
+	d, err := scanDocument(ctx, m, r.pool.QueryRow(ctx, `
+		SELECT `+documentColumns+` FROM documents d WHERE d.id = $1 AND d.deleted_at IS NULL
+	`, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	return d, err
+}
+
+// Documents is the resolver for the documents field.
+func (r *queryResolver) Documents(ctx context.Context, dataset *int, typeArg *string, excludeSensitive *bool, limit *int, offset *int) ([]*model.Document, error) {
+	m, err := store.LoadMasker(ctx, r.pool)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := r.pool.Query(ctx, `
+		SELECT `+documentColumns+`
+		FROM documents d
+		WHERE ($1 = '' OR d.document_type = $1)
+		  AND ($2::int IS NULL OR d.dataset_id = $2)
+		  AND d.deleted_at IS NULL
+		  AND `+store.Insensitive("d", "$5")+`
+		ORDER BY d.doc_id
+		LIMIT $3 OFFSET $4
+	`, deref(typeArg), dataset, clamp(limit, 50, 200), clamp(offset, 0, -1),
+		(excludeSensitive != nil && *excludeSensitive) || store.ExcludesSensitive(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var documents []*model.Document
+	for rows.Next() {
+		d, err := scanDocument(ctx, m, rows)
+		if err != nil {
+			return nil, err
+		}
+		documents = append(documents, d)
+	}
+	return documents, rows.Err()
+}
+
+// Network is the resolver for the network field.
+func (r *queryResolver) Network(ctx context.Context, minConnections *int, limit *int) (*model.Network, error) {
+	minConn := clamp(minConnections, 2, -1)
+	maxNodes := clamp(limit, 1000, 10000)
+
+	rows, err := r.pool.Query(ctx, `
+		SELECT `+entityColumns+`
+		FROM entities
+		WHERE entity_type IN ('person', 'organization')
+		  AND connection_count >= $1
+		  AND deleted_at IS NULL AND NOT protected
+		ORDER BY connection_count DESC
+		LIMIT $2
+	`, minConn, maxNodes)
+	if err != nil {
+		return nil, err
+	}
+	nodes, err := r.collectEntities(ctx, rows)
+	if err != nil {
+		return nil, err
+	}
+
+	inGraph := make(map[int]bool, len(nodes))
+	for _, n := range nodes {
+		inGraph[n.ID] = true
+	}
+
+	edgeRows, err := r.pool.Query(ctx, `
+		SELECT de1.entity_id, de2.entity_id, COUNT(DISTINCT de1.document_id) AS weight
+		FROM document_entities de1
+		JOIN document_entities de2 ON de1.document_id = de2.document_id
+			AND de1.entity_id < de2.entity_id
+		JOIN entities e1 ON de1.entity_id = e1.id
+		JOIN entities e2 ON de2.entity_id = e2.id
+		WHERE e1.entity_type IN ('person', 'organization')
+		  AND e2.entity_type IN ('person', 'organization')
+		  AND e1.connection_count >= $1
+		  AND e2.connection_count >= $1
+		  AND e1.deleted_at IS NULL AND e2.deleted_at IS NULL
+		  AND NOT e1.protected AND NOT e2.protected
+		GROUP BY de1.entity_id, de2.entity_id
+		HAVING COUNT(DISTINCT de1.document_id) >= 2
+		ORDER BY weight DESC
+		LIMIT $2
+	`, minConn, maxNodes*3)
+	if err != nil {
+		return nil, err
+	}
+	defer edgeRows.Close()
+
+	network := &model.Network{Nodes: nodes}
+	for edgeRows.Next() {
+		var e model.Edge
+		if err := edgeRows.Scan(&e.SourceID, &e.TargetID, &e.Weight); err != nil {
+			return nil, err
+		}
+		// Only include edges where both nodes are in the node set
+		if inGraph[e.SourceID] && inGraph[e.TargetID] {
+			network.Edges = append(network.Edges, &e)
+		}
+	}
+	return network, edgeRows.Err()
+}
+
+// Pattern is the resolver for the pattern field.
+func (r *queryResolver) Pattern(ctx context.Context, id int) (*model.Pattern, error) {
+	p, err := scanPattern(r.pool.QueryRow(ctx, `
+		SELECT `+patternColumns+` FROM pattern_findings WHERE id = $1
+	`, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	return p, err
+}
+
+// Patterns is the resolver for the patterns field.
+func (r *queryResolver) Patterns(ctx context.Context, status *string, typeArg *string) ([]*model.Pattern, error) {
+	rows, err := r.pool.Query(ctx, `
+		SELECT `+patternColumns+`
+		FROM pattern_findings
+		WHERE ($1 = '' OR status = $1)
+		  AND ($2 = '' OR pattern_type = $2)
+		ORDER BY discovered_at DESC
+		LIMIT 100
+	`, deref(status), deref(typeArg))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var patterns []*model.Pattern
+	for rows.Next() {
+		p, err := scanPattern(rows)
+		if err != nil {
+			return nil, err
+		}
+		patterns = append(patterns, p)
+	}
+	return patterns, rows.Err()
+}
+
+// Connection returns ConnectionResolver implementation.
+func (r *Resolver) Connection() ConnectionResolver { return &connectionResolver{r} }
+
+// Document returns DocumentResolver implementation.
+func (r *Resolver) Document() DocumentResolver { return &documentResolver{r} }
+
+// Edge returns EdgeResolver implementation.
+func (r *Resolver) Edge() EdgeResolver { return &edgeResolver{r} }
+
+// Entity returns EntityResolver implementation.
+func (r *Resolver) Entity() EntityResolver { return &entityResolver{r} }
+
+// Mention returns MentionResolver implementation.
+func (r *Resolver) Mention() MentionResolver { return &mentionResolver{r} }
+
+// Pattern returns PatternResolver implementation.
+func (r *Resolver) Pattern() PatternResolver { return &patternResolver{r} }
+
+// Query returns QueryResolver implementation.
+func (r *Resolver) Query() QueryResolver { return &queryResolver{r} }
+
+type connectionResolver struct{ *Resolver }
+type documentResolver struct{ *Resolver }
+type edgeResolver struct{ *Resolver }
+type entityResolver struct{ *Resolver }
+type mentionResolver struct{ *Resolver }
+type patternResolver struct{ *Resolver }
+type queryResolver struct{ *Resolver }
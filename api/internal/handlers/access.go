@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/subculture-collective/epstein-db/api/internal/apierr"
+	"github.com/subculture-collective/epstein-db/api/internal/auth"
+	"github.com/subculture-collective/epstein-db/api/internal/store"
+)
+
+// RestrictedAccess lets callers with an API key read the content of
+// documents of restricted datasets. Their responses are marked private, so
+// that shared caches and mirrors don't hand that content to anonymous
+// callers.
+func RestrictedAccess(c *fiber.Ctx) error {
+	if auth.FromContext(c).KeyID == 0 {
+		return c.Next()
+	}
+	c.SetUserContext(store.WithRestricted(c.UserContext()))
+	if err := c.Next(); err != nil {
+		return err
+	}
+
+	control := string(c.Response().Header.Peek(fiber.HeaderCacheControl))
+	switch {
+	case control == "":
+		c.Set(fiber.HeaderCacheControl, "private")
+	case strings.Contains(control, "public"):
+		c.Set(fiber.HeaderCacheControl, strings.Replace(control, "public", "private", 1))
+	}
+	return nil
+}
+
+// restricted turns store.ErrRestricted into a 401 for what, passing other
+// errors on to notFound
+func restricted(err error, what string) error {
+	if errors.Is(err, store.ErrRestricted) {
+		return apierr.Unauthorized("API key required to read this " + what + "; its dataset is restricted")
+	}
+	return notFound(err, what)
+}
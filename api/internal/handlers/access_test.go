@@ -0,0 +1,265 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/subculture-collective/epstein-db/api/internal/apierr"
+	"github.com/subculture-collective/epstein-db/api/internal/models"
+	"github.com/subculture-collective/epstein-db/api/internal/store"
+)
+
+// These cases follow the fixtures' restricted document 4, protected Mary
+// Major (entity 6) and soft-deleted document 5 through the read routes, as
+// an anonymous caller and with a researcher key. Only the key opens the
+// restricted document; neither caller sees the other two.
+
+// wantNoName fails if body names the protected entity
+func wantNoName(t *testing.T, body []byte) {
+	t.Helper()
+	if strings.Contains(string(body), "Mary Major") {
+		t.Fatalf("protected name in %s", body)
+	}
+}
+
+func wantMasked(t *testing.T, got *string, want string) {
+	t.Helper()
+	if got == nil || *got != want {
+		t.Fatalf("got %v, want %q", ptrString(got), want)
+	}
+}
+
+func wantNull(t *testing.T, got *string) {
+	t.Helper()
+	if got != nil {
+		t.Fatalf("got %q, want null", *got)
+	}
+}
+
+func ptrString(s *string) string {
+	if s == nil {
+		return "null"
+	}
+	return fmt.Sprintf("%q", *s)
+}
+
+func TestAuth(t *testing.T) {
+	runCases(t, []handlerCase{
+		{name: "anonymous", target: "/api/documents/1", status: http.StatusOK},
+		{name: "key", target: "/api/documents/1", key: testKey, status: http.StatusOK},
+		{name: "unknown key", target: "/api/documents/1", key: "not-a-key", status: http.StatusUnauthorized, code: apierr.CodeUnauthorized},
+	})
+}
+
+func TestRestrictedDocument(t *testing.T) {
+	runCases(t, []handlerCase{
+		{name: "anonymous gets metadata", target: "/api/documents/4", status: http.StatusOK,
+			check: func(t *testing.T, body []byte) {
+				d := decode[models.Document](t, body)
+				if d.ID != 4 || !d.Restricted {
+					t.Fatalf("document %+v, want restricted EFTA00000004", d)
+				}
+				wantNull(t, d.Summary)
+			}},
+		{name: "key gets the masked summary", target: "/api/documents/4", key: testKey, status: http.StatusOK,
+			check: func(t *testing.T, body []byte) {
+				wantMasked(t, decode[models.Document](t, body).Summary, "John Doe and "+store.Masked+" flew to Palm Beach.")
+			}},
+		{name: "anonymous text", target: "/api/documents/4/text", status: http.StatusUnauthorized, code: apierr.CodeUnauthorized},
+		{name: "key text", target: "/api/documents/4/text", key: testKey, status: http.StatusOK,
+			check: func(t *testing.T, body []byte) {
+				wantMasked(t, decode[DocumentText](t, body).Text, "John Doe flew with "+store.Masked+" to Palm Beach.")
+			}},
+		{name: "anonymous entities without quotes", target: "/api/documents/4/entities", status: http.StatusOK,
+			check: func(t *testing.T, body []byte) {
+				list := decode[DocumentEntityList](t, body).Entities
+				ids := make([]int, len(list))
+				for i, e := range list {
+					ids[i] = e.ID
+					wantNull(t, e.RoleQuote)
+				}
+				wantIDs(t, ids, []int{4, 5})
+			}},
+		{name: "key entities with masked quotes", target: "/api/documents/4/entities", key: testKey, status: http.StatusOK,
+			check: func(t *testing.T, body []byte) {
+				list := decode[DocumentEntityList](t, body).Entities
+				if len(list) != 2 || list[0].ID != 4 {
+					t.Fatalf("entities %+v, want John Doe and Palm Beach", list)
+				}
+				wantMasked(t, list[0].RoleQuote, "John Doe flew with "+store.Masked)
+			}},
+		{name: "anonymous entity documents", target: "/api/entities/4/documents?sort=docId", status: http.StatusOK,
+			check: func(t *testing.T, body []byte) {
+				docs := decode[EntityDocumentList](t, body).Documents
+				if len(docs) != 2 || docs[1].ID != 4 {
+					t.Fatalf("documents %+v, want 3 and 4", docs)
+				}
+				wantNull(t, docs[1].Summary)
+				wantNull(t, docs[1].RoleQuote)
+			}},
+		{name: "key entity documents", target: "/api/entities/4/documents?sort=docId", key: testKey, status: http.StatusOK,
+			check: func(t *testing.T, body []byte) {
+				docs := decode[EntityDocumentList](t, body).Documents
+				if len(docs) != 2 || docs[1].ID != 4 {
+					t.Fatalf("documents %+v, want 3 and 4", docs)
+				}
+				wantMasked(t, docs[1].RoleQuote, "John Doe flew with "+store.Masked)
+			}},
+	})
+}
+
+func TestRestrictedSearch(t *testing.T) {
+	resultIDs := func(t *testing.T, body []byte) []int {
+		list := decode[SearchResults](t, body).Results
+		ids := make([]int, len(list))
+		for i, r := range list {
+			ids[i] = r.ID
+		}
+		return ids
+	}
+	// Only documents 4 (restricted) and 5 (deleted) say "flew"
+	runCases(t, []handlerCase{
+		{name: "anonymous", target: "/api/search?q=flew", status: http.StatusOK,
+			check: func(t *testing.T, body []byte) {
+				wantIDs(t, resultIDs(t, body), []int{})
+			}},
+		{name: "key", target: "/api/search?q=flew", key: testKey, status: http.StatusOK,
+			check: func(t *testing.T, body []byte) {
+				wantIDs(t, resultIDs(t, body), []int{4})
+				wantNoName(t, body)
+			}},
+	})
+}
+
+func TestRestrictedTriples(t *testing.T) {
+	triples := func(t *testing.T, body []byte) []models.Triple {
+		list := decode[TripleList](t, body).Triples
+		for _, tr := range list {
+			if tr.Subject.ID == 6 || tr.Object.ID == 6 {
+				t.Fatalf("triple %+v of a protected entity", tr)
+			}
+		}
+		return list
+	}
+	runCases(t, []handlerCase{
+		{name: "anonymous without sentences", target: "/api/triples?document=4", status: http.StatusOK,
+			check: func(t *testing.T, body []byte) {
+				list := triples(t, body)
+				if len(list) != 1 || list[0].Predicate != "flew_to" {
+					t.Fatalf("triples %+v, want John Doe flew_to Palm Beach", list)
+				}
+				wantNull(t, list[0].Provenance.Sentence)
+			}},
+		{name: "key with masked sentences", target: "/api/triples?document=4", key: testKey, status: http.StatusOK,
+			check: func(t *testing.T, body []byte) {
+				list := triples(t, body)
+				if len(list) != 1 {
+					t.Fatalf("triples %+v, want one", list)
+				}
+				wantMasked(t, list[0].Provenance.Sentence, "John Doe flew with "+store.Masked+" to Palm Beach.")
+			}},
+		{name: "public sentences for anonymous", target: "/api/triples?document=1", status: http.StatusOK,
+			check: func(t *testing.T, body []byte) {
+				list := triples(t, body)
+				if len(list) != 1 || list[0].Provenance.Sentence == nil {
+					t.Fatalf("triples %+v, want one with its sentence", list)
+				}
+			}},
+		{name: "deleted document", target: "/api/triples?document=5", key: testKey, status: http.StatusOK,
+			check: func(t *testing.T, body []byte) {
+				if list := triples(t, body); len(list) != 0 {
+					t.Fatalf("triples %+v of a deleted document", list)
+				}
+			}},
+	})
+}
+
+func TestProtectedEntity(t *testing.T) {
+	for _, key := range []string{"", testKey} {
+		caller := "anonymous"
+		if key != "" {
+			caller = "key"
+		}
+		runCases(t, []handlerCase{
+			{name: caller + " entity", target: "/api/entities/6", key: key, status: http.StatusNotFound, code: apierr.CodeNotFound},
+			{name: caller + " search", target: "/api/entities?q=Mary+Major", key: key, status: http.StatusOK,
+				check: func(t *testing.T, body []byte) {
+					wantIDs(t, entityIDs(decode[EntityList](t, body).Entities), []int{})
+				}},
+			{name: caller + " connections", target: "/api/entities/2/connections", key: key, status: http.StatusOK,
+				check: wantNoName},
+			{name: caller + " summary masked", target: "/api/documents/3", key: key, status: http.StatusOK,
+				check: func(t *testing.T, body []byte) {
+					wantMasked(t, decode[models.Document](t, body).Summary, "A deposition naming "+store.Masked+".")
+				}},
+			{name: caller + " document entities", target: "/api/documents/3/entities", key: key, status: http.StatusOK,
+				check: wantNoName},
+			{name: caller + " network", target: "/api/network", key: key, status: http.StatusOK,
+				check: func(t *testing.T, body []byte) {
+					n := decode[Network](t, body)
+					ids := make([]int, len(n.Nodes))
+					for i, node := range n.Nodes {
+						ids[i] = node.ID
+					}
+					slices.Sort(ids)
+					wantIDs(t, ids, []int{1, 2, 3, 4})
+					edges := make([]string, len(n.Edges))
+					for i, e := range n.Edges {
+						edges[i] = fmt.Sprintf("%d-%d", e.Source, e.Target)
+					}
+					slices.Sort(edges)
+					if fmt.Sprint(edges) != "[1-2 2-3]" {
+						t.Fatalf("edges %v, want [1-2 2-3]", edges)
+					}
+				}},
+		})
+	}
+}
+
+func TestDeletedDocument(t *testing.T) {
+	for _, key := range []string{"", testKey} {
+		caller := "anonymous"
+		if key != "" {
+			caller = "key"
+		}
+		runCases(t, []handlerCase{
+			{name: caller + " document", target: "/api/documents/5", key: key, status: http.StatusNotFound, code: apierr.CodeNotFound},
+			{name: caller + " text", target: "/api/documents/5/text", key: key, status: http.StatusNotFound, code: apierr.CodeNotFound},
+			{name: caller + " list", target: "/api/documents?sort=id&order=desc&limit=1", key: key, status: http.StatusOK,
+				check: func(t *testing.T, body []byte) {
+					if docs := decode[DocumentPage](t, body).Documents; len(docs) != 1 || docs[0].ID != 4 {
+						t.Fatalf("documents %+v, want 4 first", docs)
+					}
+				}},
+			{name: caller + " stats", target: "/api/stats", key: key, status: http.StatusOK,
+				check: func(t *testing.T, body []byte) {
+					s := decode[models.Stats](t, body)
+					if s.Documents != 4 {
+						t.Fatalf("%d documents, want 4", s.Documents)
+					}
+					for _, d := range s.ByDataset {
+						if d.DatasetID == 1 && d.Documents != 3 {
+							t.Fatalf("%d documents in dataset 1, want 3", d.Documents)
+						}
+					}
+				}},
+		})
+	}
+}
+
+func TestCrossref(t *testing.T) {
+	for _, key := range []string{"", testKey} {
+		runCases(t, []handlerCase{
+			{name: "ppp", target: "/api/crossref/ppp?q=acme", key: key, status: http.StatusOK,
+				check: func(t *testing.T, body []byte) {
+					list := decode[PPPResults](t, body).Results
+					if len(list) != 1 || list[0].BorrowerName != "ACME HOLDINGS LLC" {
+						t.Fatalf("loans %+v, want ACME HOLDINGS LLC", list)
+					}
+				}},
+		})
+	}
+}
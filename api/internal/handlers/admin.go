@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/subculture-collective/epstein-db/api/internal/apierr"
+	"github.com/subculture-collective/epstein-db/api/internal/audit"
+	"github.com/subculture-collective/epstein-db/api/internal/config"
+	"github.com/subculture-collective/epstein-db/api/internal/db"
+	"github.com/subculture-collective/epstein-db/api/internal/embeddings"
+	"github.com/subculture-collective/epstein-db/api/internal/jobs"
+	"github.com/subculture-collective/epstein-db/api/internal/recount"
+	"github.com/subculture-collective/epstein-db/api/internal/roles"
+	"github.com/subculture-collective/epstein-db/api/internal/summarize"
+)
+
+// settings is the server configuration; cmd/server sets it with SetConfig
+var settings *config.Config
+
+// SetConfig gives the handlers the server configuration
+func SetConfig(cfg *config.Config) {
+	settings = cfg
+}
+
+// GetConfig returns the server configuration with secrets redacted
+func GetConfig(c *fiber.Ctx) error {
+	if settings == nil {
+		return apierr.New(503, apierr.CodeUnavailable, "configuration not loaded")
+	}
+	return c.JSON(settings.Redacted())
+}
+
+// GetAuditLog lists recorded admin actions, newest first
+func GetAuditLog(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+
+	limit, err := limitQuery(c, 50, 500)
+	if err != nil {
+		return err
+	}
+	offset, err := offsetQuery(c)
+	if err != nil {
+		return err
+	}
+	keyID, err := idQuery(c, "key")
+	if err != nil {
+		return err
+	}
+
+	entries, err := audit.List(ctx, db.Pool(), audit.Filter{
+		Action: c.Query("action", ""),
+		KeyID:  keyID,
+		Limit:  limit,
+		Offset: offset,
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(AuditLog{
+		Entries: entries,
+		Count:   len(entries),
+		Offset:  offset,
+		Limit:   limit,
+	})
+}
+
+// QueueSummarization queues a (re)summarization job for a dataset
+func QueueSummarization(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+
+	var params summarize.Params
+	if err := c.BodyParser(&params); err != nil {
+		return apierr.BadRequest("invalid body")
+	}
+	if params.DatasetID < 0 {
+		return apierr.InvalidParam("datasetId", "must not be negative")
+	}
+
+	id, err := jobs.NewQueue(db.Pool()).Enqueue(ctx, summarize.JobKind, params)
+	if err != nil {
+		return err
+	}
+
+	audit.SetJob(c, id)
+
+	return c.Status(202).JSON(QueuedJob{
+		JobID:  id,
+		Status: jobs.StatusQueued,
+	})
+}
+
+// QueueRoleInference queues a role inference job
+func QueueRoleInference(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+
+	var params roles.Params
+	if err := c.BodyParser(&params); err != nil {
+		return apierr.BadRequest("invalid body")
+	}
+	if params.DatasetID < 0 {
+		return apierr.InvalidParam("datasetId", "must not be negative")
+	}
+
+	id, err := jobs.NewQueue(db.Pool()).Enqueue(ctx, roles.JobKind, params)
+	if err != nil {
+		return err
+	}
+
+	audit.SetJob(c, id)
+
+	return c.Status(202).JSON(QueuedJob{
+		JobID:  id,
+		Status: jobs.StatusQueued,
+	})
+}
+
+// QueueEmbedding queues an embedding job for stale documents in a dataset
+func QueueEmbedding(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+
+	var params embeddings.Params
+	if err := c.BodyParser(&params); err != nil {
+		return apierr.BadRequest("invalid body")
+	}
+	if params.DatasetID < 0 {
+		return apierr.InvalidParam("datasetId", "must not be negative")
+	}
+
+	id, err := jobs.NewQueue(db.Pool()).Enqueue(ctx, embeddings.JobKind, params)
+	if err != nil {
+		return err
+	}
+
+	audit.SetJob(c, id)
+
+	return c.Status(202).JSON(QueuedJob{
+		JobID:  id,
+		Status: jobs.StatusQueued,
+	})
+}
+
+// GetEmbeddingStatus reports embedded and stale document counts
+func GetEmbeddingStatus(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+
+	fingerprint := embeddings.Fingerprint(embeddings.ModelFromEnv(), embeddings.ConfigFromEnv())
+	status, err := embeddings.GetStatus(ctx, db.Pool(), fingerprint)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(status)
+}
+
+// RecountEntities recomputes entity document and connection counts. Dry
+// runs report discrepancies immediately; fixes are queued as a job.
+func RecountEntities(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+
+	var opts recount.Options
+	if err := c.BodyParser(&opts); err != nil {
+		return apierr.BadRequest("invalid body")
+	}
+
+	if opts.DryRun {
+		report, err := recount.Run(ctx, db.Pool(), opts)
+		if err != nil {
+			return err
+		}
+		return c.JSON(report)
+	}
+
+	id, err := jobs.NewQueue(db.Pool()).Enqueue(ctx, recount.JobKind, opts)
+	if err != nil {
+		return err
+	}
+
+	audit.SetJob(c, id)
+
+	return c.Status(202).JSON(QueuedJob{
+		JobID:  id,
+		Status: jobs.StatusQueued,
+	})
+}
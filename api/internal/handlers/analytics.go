@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"slices"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/subculture-collective/epstein-db/api/internal/apierr"
+	"github.com/subculture-collective/epstein-db/api/internal/store"
+)
+
+// maxMentionEntities bounds the series of one mentions chart
+const maxMentionEntities = 20
+
+// GetMentions returns mention counts per entity per period, for comparing
+// activity over time
+func GetMentions(c *fiber.Ctx) error {
+	ids, err := idsQuery(c, "entities", maxMentionEntities)
+	if err != nil {
+		return err
+	}
+	if len(ids) == 0 {
+		return apierr.InvalidParam("entities", "is required")
+	}
+	// Keep the caller's order, which is the order of the series
+	unique := ids[:0]
+	for _, id := range ids {
+		if !slices.Contains(unique, id) {
+			unique = append(unique, id)
+		}
+	}
+	interval, err := enumQuery(c, "interval", store.MentionIntervals)
+	if err != nil {
+		return err
+	}
+	if interval == "" {
+		interval = "month"
+	}
+	from, err := dateQuery(c, "from")
+	if err != nil {
+		return err
+	}
+	to, err := dateQuery(c, "to")
+	if err != nil {
+		return err
+	}
+
+	m, err := data.EntityMentions(c.UserContext(), store.MentionFilter{
+		EntityIDs: unique,
+		Interval:  interval,
+		From:      from,
+		To:        to,
+	})
+	if err != nil {
+		return err
+	}
+	return c.JSON(m)
+}
+
+// GetSharedAttributes lists groups of cross-reference records under
+// different names that share an address or employer, a common sign of shell
+// companies and straw donors
+func GetSharedAttributes(c *fiber.Ctx) error {
+	attribute, err := enumQuery(c, "attribute", store.SharedAttributes)
+	if err != nil {
+		return err
+	}
+	if attribute == "" {
+		return apierr.InvalidParam("attribute", "is required")
+	}
+
+	minEntities, err := intQuery(c, "minEntities", 1)
+	if err != nil {
+		return err
+	}
+	if minEntities < 0 {
+		return apierr.InvalidParam("minEntities", "must not be negative")
+	}
+
+	limit, err := limitQuery(c, 50, 200)
+	if err != nil {
+		return err
+	}
+	offset, err := offsetQuery(c)
+	if err != nil {
+		return err
+	}
+
+	groups, err := data.SharedAttributeGroups(c.UserContext(), store.SharedAttributeFilter{
+		Attribute:   attribute,
+		MinEntities: minEntities,
+		Limit:       limit,
+		Offset:      offset,
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(SharedGroupPage{
+		Groups:   groups,
+		Count:    len(groups),
+		Offset:   offset,
+		Limit:    limit,
+		Warnings: skipped(c),
+	})
+}
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/subculture-collective/epstein-db/api/internal/anomalies"
+	"github.com/subculture-collective/epstein-db/api/internal/audit"
+	"github.com/subculture-collective/epstein-db/api/internal/db"
+	"github.com/subculture-collective/epstein-db/api/internal/jobs"
+)
+
+// ListAnomalies returns the outlying contribution and loan amounts the last
+// anomaly detection run found, highest scores first
+func ListAnomalies(c *fiber.Ctx) error {
+	kind, err := enumQuery(c, "kind", anomalies.KindNames())
+	if err != nil {
+		return err
+	}
+	entityID, err := idQuery(c, "entity")
+	if err != nil {
+		return err
+	}
+	minScore, err := fractionQuery(c, "minScore", 0)
+	if err != nil {
+		return err
+	}
+	limit, err := limitQuery(c, 50, 200)
+	if err != nil {
+		return err
+	}
+	offset, err := offsetQuery(c)
+	if err != nil {
+		return err
+	}
+
+	found, err := anomalies.List(c.UserContext(), db.Pool(), anomalies.Filter{
+		Kind:     kind,
+		EntityID: entityID,
+		MinScore: minScore,
+		Limit:    limit,
+		Offset:   offset,
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(AnomalyPage{
+		Anomalies: found,
+		Kinds:     anomalies.Kinds,
+		Count:     len(found),
+		Offset:    offset,
+		Limit:     limit,
+	})
+}
+
+// QueueAnomalyDetection queues a background rerun of the anomaly detection
+func QueueAnomalyDetection(c *fiber.Ctx) error {
+	id, err := jobs.NewQueue(db.Pool()).Enqueue(c.UserContext(), anomalies.JobKind, struct{}{})
+	if err != nil {
+		return err
+	}
+
+	audit.SetJob(c, id)
+
+	return c.Status(202).JSON(QueuedJob{
+		JobID:  id,
+		Status: jobs.StatusQueued,
+	})
+}
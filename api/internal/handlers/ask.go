@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/subculture-collective/epstein-db/api/internal/apierr"
+	"github.com/subculture-collective/epstein-db/api/internal/rag"
+)
+
+// maxQuestionLength caps the question or claim sent to the model
+const maxQuestionLength = 1000
+
+// asker and verifier are set by cmd/server when a model is configured
+var (
+	asker    *rag.Asker
+	verifier *rag.Verifier
+)
+
+// SetAsker sets the question answering pipeline
+func SetAsker(a *rag.Asker) {
+	asker = a
+}
+
+// SetVerifier sets the claim verification pipeline
+func SetVerifier(v *rag.Verifier) {
+	verifier = v
+}
+
+// AskBody is the body of POST /api/ask
+type AskBody struct {
+	Question string `json:"question"`
+}
+
+// Ask answers a question from the documents, citing its sources
+func Ask(c *fiber.Ctx) error {
+	if asker == nil {
+		return apierr.New(fiber.StatusServiceUnavailable, apierr.CodeUnavailable, "question answering is not configured")
+	}
+
+	var body AskBody
+	if err := c.BodyParser(&body); err != nil {
+		return apierr.BadRequest("invalid body")
+	}
+	question := strings.TrimSpace(body.Question)
+	if question == "" {
+		return apierr.InvalidParam("question", "is required")
+	}
+	if len(question) > maxQuestionLength {
+		return apierr.InvalidParam("question", "must be at most 1000 characters")
+	}
+
+	answer, err := asker.Ask(c.UserContext(), question)
+	if err != nil {
+		return err
+	}
+	return c.JSON(answer)
+}
+
+// VerifyBody is the body of POST /api/verify
+type VerifyBody struct {
+	Claim string `json:"claim" doc:"e.g. \"X flew with Y in 1999\""`
+}
+
+// Verify checks a claim against the documents
+func Verify(c *fiber.Ctx) error {
+	if verifier == nil {
+		return apierr.New(fiber.StatusServiceUnavailable, apierr.CodeUnavailable, "claim verification is not configured")
+	}
+
+	var body VerifyBody
+	if err := c.BodyParser(&body); err != nil {
+		return apierr.BadRequest("invalid body")
+	}
+	claim := strings.TrimSpace(body.Claim)
+	if claim == "" {
+		return apierr.InvalidParam("claim", "is required")
+	}
+	if len(claim) > maxQuestionLength {
+		return apierr.InvalidParam("claim", "must be at most 1000 characters")
+	}
+
+	verdict, err := verifier.Verify(c.UserContext(), claim)
+	if err != nil {
+		return err
+	}
+	return c.JSON(verdict)
+}
@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/url"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/valyala/fasthttp"
+
+	"github.com/subculture-collective/epstein-db/api/internal/apierr"
+	"github.com/subculture-collective/epstein-db/api/internal/logging"
+)
+
+// maxBatch caps the sub-requests in one batch
+const maxBatch = 20
+
+// BatchRequest is one sub-request of a batch. Only GETs of API routes are
+// allowed, so a batch never changes anything.
+type BatchRequest struct {
+	Method string            `json:"method" enum:"GET"`
+	Path   string            `json:"path" doc:"API path, e.g. /api/entities/42"`
+	Params map[string]string `json:"params,omitempty" doc:"Query parameters"`
+}
+
+// BatchBody is the body of POST /api/batch
+type BatchBody struct {
+	Requests []BatchRequest `json:"requests"`
+}
+
+// BatchResponse is the result of one sub-request
+type BatchResponse struct {
+	Status int             `json:"status"`
+	ETag   string          `json:"etag,omitempty"`
+	Body   json.RawMessage `json:"body"`
+}
+
+// BatchResults are the sub-request results, in request order
+type BatchResults struct {
+	Responses []BatchResponse `json:"responses"`
+}
+
+// Batch runs up to maxBatch read requests concurrently and returns their
+// responses together. Sub-requests go through the whole app, middleware
+// included, with the caller's headers, so they are authenticated and rate
+// limited as if sent separately.
+func Batch(app *fiber.App) fiber.Handler {
+	handler := app.Handler()
+
+	return func(c *fiber.Ctx) error {
+		var body BatchBody
+		if err := c.BodyParser(&body); err != nil {
+			return apierr.BadRequest("invalid body")
+		}
+		if len(body.Requests) == 0 {
+			return apierr.InvalidParam("requests", "must not be empty")
+		}
+		if len(body.Requests) > maxBatch {
+			return apierr.InvalidParam("requests", "must have at most "+strconv.Itoa(maxBatch)+" entries")
+		}
+
+		uris := make([]string, len(body.Requests))
+		for i, r := range body.Requests {
+			uri, err := batchURI(r)
+			if err != nil {
+				return apierr.InvalidParam("requests["+strconv.Itoa(i)+"]", err.Error())
+			}
+			uris[i] = uri
+		}
+
+		requestID := logging.FromContext(c)
+		remote := c.Context().RemoteAddr()
+
+		responses := make([]BatchResponse, len(uris))
+		var wg sync.WaitGroup
+		for i, uri := range uris {
+			req := fasthttp.AcquireRequest()
+			c.Request().Header.CopyTo(&req.Header)
+			req.Header.SetMethod(fiber.MethodGet)
+			req.Header.SetRequestURI(uri)
+			req.Header.Del(fiber.HeaderContentType)
+			req.Header.Del(fiber.HeaderContentLength)
+			req.Header.Del(fiber.HeaderAcceptEncoding)
+			if requestID != "" {
+				req.Header.Set(fiber.HeaderXRequestID, requestID+"-"+strconv.Itoa(i))
+			}
+
+			wg.Add(1)
+			go func(i int, req *fasthttp.Request) {
+				defer wg.Done()
+				defer fasthttp.ReleaseRequest(req)
+
+				var sub fasthttp.RequestCtx
+				sub.Init(req, remote, nil)
+				handler(&sub)
+
+				res := BatchResponse{
+					Status: sub.Response.StatusCode(),
+					ETag:   string(sub.Response.Header.Peek(fiber.HeaderETag)),
+				}
+				out := sub.Response.Body()
+				switch {
+				case len(out) == 0:
+					res.Body = json.RawMessage("null")
+				case json.Valid(out):
+					res.Body = append(json.RawMessage(nil), out...)
+				default:
+					res.Body, _ = json.Marshal(string(out))
+				}
+				responses[i] = res
+			}(i, req)
+		}
+		wg.Wait()
+
+		return c.JSON(BatchResults{Responses: responses})
+	}
+}
+
+// unbatchedPaths are the routes under which responses are streamed or
+// binary, which a batch would buffer whole and could only return as a
+// string. Batches don't nest.
+var unbatchedPaths = []string{"/api/batch", "/api/events", "/api/export", "/api/media"}
+
+// unbatchedEndings are the last segments of the other file routes: original
+// documents, page thumbnails, network images, downloads and exports
+var unbatchedEndings = []string{"file", "thumbnail", "network.svg", "download", "export", "transcript"}
+
+// batchURI validates a sub-request and builds its request URI
+func batchURI(r BatchRequest) (string, error) {
+	if r.Method != "" && !strings.EqualFold(r.Method, fiber.MethodGet) {
+		return "", errors.New("method must be GET")
+	}
+
+	u, err := url.Parse(r.Path)
+	if err == nil {
+		u.Path = path.Clean(u.Path)
+	}
+	if err != nil || u.IsAbs() || !strings.HasPrefix(u.Path, "/api/") {
+		return "", errors.New("path must be an API path such as /api/entities/42")
+	}
+	for _, excluded := range unbatchedPaths {
+		if u.Path == excluded || strings.HasPrefix(u.Path, excluded+"/") {
+			return "", errors.New("path " + excluded + " can't be batched")
+		}
+	}
+	if slices.Contains(unbatchedEndings, path.Base(u.Path)) {
+		return "", errors.New("path " + u.Path + " is a file and can't be batched")
+	}
+
+	query := u.Query()
+	for k, v := range r.Params {
+		query.Set(k, v)
+	}
+	u.RawQuery = query.Encode()
+	return u.RequestURI(), nil
+}
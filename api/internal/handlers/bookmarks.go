@@ -0,0 +1,195 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/subculture-collective/epstein-db/api/internal/apierr"
+	"github.com/subculture-collective/epstein-db/api/internal/audit"
+	"github.com/subculture-collective/epstein-db/api/internal/auth"
+	"github.com/subculture-collective/epstein-db/api/internal/bookmarks"
+	"github.com/subculture-collective/epstein-db/api/internal/db"
+)
+
+// BookmarkBody is the body of PUT /api/bookmarks/:type/:id
+type BookmarkBody struct {
+	Note string `json:"note,omitempty"`
+}
+
+// BookmarkList is one page of bookmarks
+type BookmarkList struct {
+	Bookmarks []bookmarks.Bookmark `json:"bookmarks"`
+	Count     int                  `json:"count"`
+	Offset    int                  `json:"offset"`
+	Limit     int                  `json:"limit"`
+}
+
+// HistoryPage is one page of reading history
+type HistoryPage struct {
+	Views  []bookmarks.View `json:"views"`
+	Count  int              `json:"count"`
+	Offset int              `json:"offset"`
+	Limit  int              `json:"limit"`
+}
+
+// Me describes the caller's API key and what it has kept
+type Me struct {
+	KeyID     int    `json:"keyId"`
+	Name      string `json:"name"`
+	Role      string `json:"role" enum:"public,researcher,admin"`
+	Bookmarks int    `json:"bookmarks"`
+	Viewed    int    `json:"viewed" doc:"Documents and entities in the reading history"`
+}
+
+// TrackView records a successful keyed read of the document or entity in
+// the id parameter in the caller's reading history. Mirrors don't record.
+func TrackView(itemType string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if err := c.Next(); err != nil {
+			return err
+		}
+
+		keyID := auth.FromContext(c).KeyID
+		if keyID == 0 || c.Response().StatusCode() >= 400 || (settings != nil && settings.Mirror.Enabled) {
+			return nil
+		}
+		id, err := strconv.Atoi(c.Params("id"))
+		if err != nil {
+			return nil
+		}
+		// The response is ready; a lost view isn't worth failing it for
+		if err := bookmarks.Record(c.UserContext(), db.Pool(), keyID, itemType, id); err != nil {
+			log.Printf("history: %v", err)
+		}
+		return nil
+	}
+}
+
+// GetMe returns the caller's key and how many bookmarks and viewed items it
+// has
+func GetMe(c *fiber.Ctx) error {
+	p := auth.FromContext(c)
+	if p.KeyID == 0 {
+		return apierr.Unauthorized("API key required")
+	}
+
+	saved, viewed, err := bookmarks.Counts(c.UserContext(), db.Pool(), p.KeyID)
+	if err != nil {
+		return err
+	}
+	return c.JSON(Me{KeyID: p.KeyID, Name: p.Name, Role: p.Role.String(), Bookmarks: saved, Viewed: viewed})
+}
+
+// ListBookmarks returns the caller's bookmarks, newest first
+func ListBookmarks(c *fiber.Ctx) error {
+	itemType, err := enumQuery(c, "type", bookmarks.Types)
+	if err != nil {
+		return err
+	}
+	limit, err := limitQuery(c, 50, 200)
+	if err != nil {
+		return err
+	}
+	offset, err := offsetQuery(c)
+	if err != nil {
+		return err
+	}
+
+	list, err := bookmarks.List(c.UserContext(), db.Pool(), auth.FromContext(c).KeyID, itemType, limit, offset)
+	if err != nil {
+		return err
+	}
+	return c.JSON(BookmarkList{Bookmarks: list, Count: len(list), Offset: offset, Limit: limit})
+}
+
+// PutBookmark bookmarks a document or entity for the caller's key
+func PutBookmark(c *fiber.Ctx) error {
+	itemType, id, err := bookmarkParams(c)
+	if err != nil {
+		return err
+	}
+	var body BookmarkBody
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&body); err != nil {
+			return apierr.BadRequest("invalid body")
+		}
+	}
+	note := strings.TrimSpace(body.Note)
+	if len(note) > maxNotes {
+		return apierr.InvalidParam("note", "must be at most "+strconv.Itoa(maxNotes)+" characters")
+	}
+
+	b, err := bookmarks.Add(c.UserContext(), db.Pool(), auth.FromContext(c).KeyID, itemType, id, note)
+	if errors.Is(err, bookmarks.ErrNoItem) {
+		return apierr.NotFound(itemType)
+	}
+	if err != nil {
+		return err
+	}
+	audit.SetAffected(c, 1)
+	return c.JSON(b)
+}
+
+// DeleteBookmark removes one of the caller's bookmarks
+func DeleteBookmark(c *fiber.Ctx) error {
+	itemType, id, err := bookmarkParams(c)
+	if err != nil {
+		return err
+	}
+
+	if err := bookmarks.Remove(c.UserContext(), db.Pool(), auth.FromContext(c).KeyID, itemType, id); err != nil {
+		return notFound(err, "bookmark")
+	}
+	audit.SetAffected(c, 1)
+	return c.SendStatus(204)
+}
+
+// ListHistory returns what the caller has viewed, most recent first
+func ListHistory(c *fiber.Ctx) error {
+	itemType, err := enumQuery(c, "type", bookmarks.Types)
+	if err != nil {
+		return err
+	}
+	limit, err := limitQuery(c, 50, 200)
+	if err != nil {
+		return err
+	}
+	offset, err := offsetQuery(c)
+	if err != nil {
+		return err
+	}
+
+	views, err := bookmarks.History(c.UserContext(), db.Pool(), auth.FromContext(c).KeyID, itemType, limit, offset)
+	if err != nil {
+		return err
+	}
+	return c.JSON(HistoryPage{Views: views, Count: len(views), Offset: offset, Limit: limit})
+}
+
+// ClearHistory forgets what the caller has viewed
+func ClearHistory(c *fiber.Ctx) error {
+	n, err := bookmarks.ClearHistory(c.UserContext(), db.Pool(), auth.FromContext(c).KeyID)
+	if err != nil {
+		return err
+	}
+	audit.SetAffected(c, n)
+	return c.SendStatus(204)
+}
+
+// bookmarkParams parses the :type and :id of a bookmark
+func bookmarkParams(c *fiber.Ctx) (string, int, error) {
+	itemType := c.Params("type")
+	if !slices.Contains(bookmarks.Types, itemType) {
+		return "", 0, apierr.InvalidParam("type", "must be one of "+strings.Join(bookmarks.Types, ", "))
+	}
+	id, err := idParam(c)
+	if err != nil {
+		return "", 0, err
+	}
+	return itemType, id, nil
+}
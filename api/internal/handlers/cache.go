@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/subculture-collective/epstein-db/api/internal/apierr"
+	"github.com/subculture-collective/epstein-db/api/internal/cdn"
+)
+
+// purger purges the CDN; cmd/server sets it with SetPurger when CDN_PROVIDER
+// is configured
+var purger *cdn.Purger
+
+// SetPurger sets the CDN PurgeCache calls
+func SetPurger(p *cdn.Purger) {
+	purger = p
+}
+
+// PurgeBody is the body of POST /api/admin/cache/purge
+type PurgeBody struct {
+	Keys []string `json:"keys,omitempty" doc:"Surrogate keys, such as entity-42, document-7 or a route family like network"`
+	All  bool     `json:"all,omitempty" doc:"Purge everything instead"`
+}
+
+// PurgeResult is what was purged
+type PurgeResult struct {
+	Keys []string `json:"keys,omitempty"`
+	All  bool     `json:"all,omitempty"`
+}
+
+// PurgeCache drops cached responses from the CDN, by surrogate key or all
+// of them
+func PurgeCache(c *fiber.Ctx) error {
+	if purger == nil {
+		return apierr.New(fiber.StatusServiceUnavailable, apierr.CodeUnavailable, "no CDN is configured; set CDN_PROVIDER")
+	}
+	var body PurgeBody
+	if err := c.BodyParser(&body); err != nil {
+		return apierr.BadRequest("invalid body")
+	}
+	if body.All == (len(body.Keys) > 0) {
+		return apierr.BadRequest("give either keys or all")
+	}
+
+	if body.All {
+		if err := purger.PurgeAll(c.UserContext()); err != nil {
+			return err
+		}
+		return c.JSON(PurgeResult{All: true})
+	}
+	for _, key := range body.Keys {
+		if key == "" || len(key) > 256 || strings.ContainsAny(key, " ,") {
+			return apierr.InvalidParam("keys", "must be non-empty keys without spaces or commas")
+		}
+	}
+	if err := purger.Purge(c.UserContext(), body.Keys); err != nil {
+		return err
+	}
+	return c.JSON(PurgeResult{Keys: body.Keys})
+}
@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"errors"
+	"slices"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/subculture-collective/epstein-db/api/internal/apierr"
+	"github.com/subculture-collective/epstein-db/api/internal/changes"
+	"github.com/subculture-collective/epstein-db/api/internal/db"
+)
+
+// ListChanges returns the changes after a cursor, oldest first, for mirrors
+// following the database
+func ListChanges(c *fiber.Ctx) error {
+	since, err := changes.ParseCursor(c.Query("since", ""))
+	if err != nil {
+		return apierr.InvalidParam("since", "must be a cursor returned by this endpoint")
+	}
+
+	limit, err := limitQuery(c, 500, 5000)
+	if err != nil {
+		return err
+	}
+
+	var tables []string
+	for _, t := range strings.Split(c.Query("tables", ""), ",") {
+		if t = strings.TrimSpace(t); t == "" {
+			continue
+		}
+		if !slices.Contains(changes.Tables, t) {
+			return apierr.InvalidParam("tables", "must be a comma-separated list of "+strings.Join(changes.Tables, ", "))
+		}
+		tables = append(tables, t)
+	}
+
+	list, next, err := changes.Read(c.UserContext(), db.ReadPool(), changes.Filter{
+		After:  since,
+		Tables: tables,
+		Limit:  limit,
+	})
+	if errors.Is(err, changes.ErrExpired) {
+		return apierr.Gone("since is older than the retained change log",
+			"load a fresh snapshot and follow from its changeCursor")
+	}
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(ChangePage{
+		Changes: list,
+		Count:   len(list),
+		Cursor:  next.String(),
+		More:    len(list) == limit,
+	})
+}
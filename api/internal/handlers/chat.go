@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/subculture-collective/epstein-db/api/internal/apierr"
+	"github.com/subculture-collective/epstein-db/api/internal/auth"
+	"github.com/subculture-collective/epstein-db/api/internal/chat"
+	"github.com/subculture-collective/epstein-db/api/internal/db"
+)
+
+// chats writes replies; cmd/server sets it with SetChat when a model is
+// configured. Sessions can be listed, read and exported without it.
+var chats *chat.Chat
+
+// SetChat sets the research chat pipeline
+func SetChat(c *chat.Chat) {
+	chats = c
+}
+
+// transcriptFormats are the formats a transcript can be exported in
+var transcriptFormats = []string{"json", "markdown"}
+
+// CreateSessionBody is the body of POST /api/chat/sessions
+type CreateSessionBody struct {
+	Title string `json:"title,omitempty" doc:"Defaults to the start of the first message"`
+}
+
+// SendMessageBody is the body of POST /api/chat/sessions/:id/messages
+type SendMessageBody struct {
+	Content string `json:"content"`
+}
+
+// SessionList is a list of chat sessions
+type SessionList struct {
+	Sessions []chat.Session `json:"sessions"`
+	Count    int            `json:"count"`
+}
+
+// CreateSession starts a chat session for the caller's key
+func CreateSession(c *fiber.Ctx) error {
+	var body CreateSessionBody
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&body); err != nil {
+			return apierr.BadRequest("invalid body")
+		}
+	}
+	title := strings.TrimSpace(body.Title)
+	if len(title) > 200 {
+		return apierr.InvalidParam("title", "must be at most 200 characters")
+	}
+
+	session, err := chat.Create(c.UserContext(), db.Pool(), auth.FromContext(c).KeyID, title)
+	if err != nil {
+		return err
+	}
+	return c.Status(201).JSON(session)
+}
+
+// ListSessions returns the caller's chat sessions, most recent first
+func ListSessions(c *fiber.Ctx) error {
+	limit, err := limitQuery(c, 50, 200)
+	if err != nil {
+		return err
+	}
+	offset, err := offsetQuery(c)
+	if err != nil {
+		return err
+	}
+
+	sessions, err := chat.List(c.UserContext(), db.Pool(), auth.FromContext(c).KeyID, limit, offset)
+	if err != nil {
+		return err
+	}
+	return c.JSON(SessionList{Sessions: sessions, Count: len(sessions)})
+}
+
+// GetSession returns one of the caller's sessions with its messages
+func GetSession(c *fiber.Ctx) error {
+	id, err := idParam(c)
+	if err != nil {
+		return err
+	}
+
+	transcript, err := chat.Get(c.UserContext(), db.Pool(), auth.FromContext(c).KeyID, int64(id))
+	if err != nil {
+		return notFound(err, "session")
+	}
+	return c.JSON(transcript)
+}
+
+// DeleteSession deletes one of the caller's sessions
+func DeleteSession(c *fiber.Ctx) error {
+	id, err := idParam(c)
+	if err != nil {
+		return err
+	}
+
+	if err := chat.Delete(c.UserContext(), db.Pool(), auth.FromContext(c).KeyID, int64(id)); err != nil {
+		return notFound(err, "session")
+	}
+	return c.SendStatus(204)
+}
+
+// SendMessage adds a message to one of the caller's sessions and returns
+// the reply
+func SendMessage(c *fiber.Ctx) error {
+	if chats == nil {
+		return apierr.New(fiber.StatusServiceUnavailable, apierr.CodeUnavailable, "chat is not configured")
+	}
+
+	id, err := idParam(c)
+	if err != nil {
+		return err
+	}
+	var body SendMessageBody
+	if err := c.BodyParser(&body); err != nil {
+		return apierr.BadRequest("invalid body")
+	}
+	content := strings.TrimSpace(body.Content)
+	if content == "" {
+		return apierr.InvalidParam("content", "is required")
+	}
+	if len(content) > maxQuestionLength {
+		return apierr.InvalidParam("content", "must be at most 1000 characters")
+	}
+
+	reply, err := chats.Send(c.UserContext(), auth.FromContext(c).KeyID, int64(id), content)
+	if err != nil {
+		return notFound(err, "session")
+	}
+	return c.JSON(reply)
+}
+
+// ExportSession returns a session's transcript as JSON or Markdown
+func ExportSession(c *fiber.Ctx) error {
+	id, err := idParam(c)
+	if err != nil {
+		return err
+	}
+	format, err := enumQuery(c, "format", transcriptFormats)
+	if err != nil {
+		return err
+	}
+
+	transcript, err := chat.Get(c.UserContext(), db.Pool(), auth.FromContext(c).KeyID, int64(id))
+	if err != nil {
+		return notFound(err, "session")
+	}
+
+	if format == "markdown" {
+		c.Set(fiber.HeaderContentType, "text/markdown; charset=utf-8")
+		c.Set(fiber.HeaderContentDisposition, `attachment; filename="session-`+c.Params("id")+`.md"`)
+		return c.SendString(transcript.Markdown())
+	}
+	c.Set(fiber.HeaderContentDisposition, `attachment; filename="session-`+c.Params("id")+`.json"`)
+	return c.JSON(transcript)
+}
@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/subculture-collective/epstein-db/api/internal/apierr"
+	"github.com/subculture-collective/epstein-db/api/internal/rag"
+)
+
+// retriever searches document chunks; cmd/server sets it with SetRetriever
+var retriever *rag.Retriever
+
+// SetRetriever sets the chunk retriever shared with question answering
+func SetRetriever(r *rag.Retriever) {
+	retriever = r
+}
+
+// SearchChunks finds the document passages most relevant to a query, for
+// callers that bring their own model
+func SearchChunks(c *fiber.Ctx) error {
+	if retriever == nil {
+		return apierr.New(fiber.StatusServiceUnavailable, apierr.CodeUnavailable, "chunk search is not configured")
+	}
+
+	query := c.Query("q", "")
+	if query == "" {
+		return apierr.InvalidParam("q", "is required")
+	}
+	if len(query) > maxQuestionLength {
+		return apierr.InvalidParam("q", "must be at most 1000 characters")
+	}
+
+	limit, err := limitQuery(c, 10, 50)
+	if err != nil {
+		return err
+	}
+	datasetID, err := idQuery(c, "dataset")
+	if err != nil {
+		return err
+	}
+	documentID, err := idQuery(c, "document")
+	if err != nil {
+		return err
+	}
+
+	chunks, err := retriever.SearchIn(c.UserContext(), query, limit, max(limit*4, 40), rag.Filter{
+		DatasetID:  datasetID,
+		DocumentID: documentID,
+	})
+	if err != nil {
+		return err
+	}
+	if chunks == nil {
+		chunks = []rag.Chunk{}
+	}
+
+	return c.JSON(ChunkResults{
+		Chunks:   chunks,
+		Count:    len(chunks),
+		Semantic: retriever.Semantic(),
+	})
+}
+
+// GetDocumentChunks returns a document's chunks in order
+func GetDocumentChunks(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+
+	id, err := idParam(c)
+	if err != nil {
+		return err
+	}
+	limit, err := limitQuery(c, 100, 500)
+	if err != nil {
+		return err
+	}
+	offset, err := offsetQuery(c)
+	if err != nil {
+		return err
+	}
+
+	if _, err := data.DocumentUpdatedAt(ctx, id); err != nil {
+		return notFound(err, "document")
+	}
+	chunks, err := data.DocumentChunks(ctx, id, limit, offset)
+	if err != nil {
+		return restricted(err, "document")
+	}
+
+	return c.JSON(DocumentChunkPage{
+		DocumentID: id,
+		Chunks:     chunks,
+		Count:      len(chunks),
+		Offset:     offset,
+		Limit:      limit,
+	})
+}
@@ -0,0 +1,312 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/subculture-collective/epstein-db/api/internal/apierr"
+	"github.com/subculture-collective/epstein-db/api/internal/audit"
+	"github.com/subculture-collective/epstein-db/api/internal/auth"
+	"github.com/subculture-collective/epstein-db/api/internal/collections"
+	"github.com/subculture-collective/epstein-db/api/internal/db"
+)
+
+// bundleFormats are the formats a collection can be exported in
+var bundleFormats = []string{"zip", "json", "markdown"}
+
+// maxNotes caps a collection's notes and each item's note
+const maxNotes = 20000
+
+// CollectionBody is the body of POST /api/collections and PUT
+// /api/collections/:id. On update, omitted fields are left as they are.
+type CollectionBody struct {
+	Name  *string `json:"name"`
+	Notes *string `json:"notes,omitempty"`
+}
+
+// CollectionItemBody is the body of POST /api/collections/:id/items
+type CollectionItemBody struct {
+	Type string `json:"type" doc:"document, entity or pattern"`
+	ID   int    `json:"id" doc:"ID of the document, entity or pattern"`
+	Note string `json:"note,omitempty"`
+}
+
+// CollectionList is a list of collections
+type CollectionList struct {
+	Collections []collections.Collection `json:"collections"`
+	Count       int                      `json:"count"`
+}
+
+// ShareLink is how a shared collection can be read
+type ShareLink struct {
+	Token string `json:"token"`
+	Path  string `json:"path" doc:"Where anyone can read the collection"`
+}
+
+// CreateCollection starts a collection for the caller's key
+func CreateCollection(c *fiber.Ctx) error {
+	body, err := collectionBody(c)
+	if err != nil {
+		return err
+	}
+	if body.Name == nil {
+		return apierr.InvalidParam("name", "is required")
+	}
+	notes := ""
+	if body.Notes != nil {
+		notes = *body.Notes
+	}
+
+	col, err := collections.Create(c.UserContext(), db.Pool(), auth.FromContext(c).KeyID, *body.Name, notes)
+	if err != nil {
+		return err
+	}
+	audit.SetAffected(c, 1)
+	return c.Status(201).JSON(col)
+}
+
+// ListCollections returns the caller's collections, most recently changed
+// first
+func ListCollections(c *fiber.Ctx) error {
+	limit, err := limitQuery(c, 50, 200)
+	if err != nil {
+		return err
+	}
+	offset, err := offsetQuery(c)
+	if err != nil {
+		return err
+	}
+
+	list, err := collections.List(c.UserContext(), db.Pool(), auth.FromContext(c).KeyID, limit, offset)
+	if err != nil {
+		return err
+	}
+	return c.JSON(CollectionList{Collections: list, Count: len(list)})
+}
+
+// GetCollection returns one of the caller's collections with its items
+func GetCollection(c *fiber.Ctx) error {
+	id, err := idParam(c)
+	if err != nil {
+		return err
+	}
+
+	bundle, err := collections.Get(c.UserContext(), db.Pool(), auth.FromContext(c).KeyID, int64(id))
+	if err != nil {
+		return notFound(err, "collection")
+	}
+	return c.JSON(bundle)
+}
+
+// UpdateCollection renames one of the caller's collections or replaces its
+// notes
+func UpdateCollection(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+	keyID := auth.FromContext(c).KeyID
+
+	id, err := idParam(c)
+	if err != nil {
+		return err
+	}
+	body, err := collectionBody(c)
+	if err != nil {
+		return err
+	}
+
+	if err := collections.Update(ctx, db.Pool(), keyID, int64(id), body.Name, body.Notes); err != nil {
+		return notFound(err, "collection")
+	}
+	audit.SetAffected(c, 1)
+
+	bundle, err := collections.Get(ctx, db.Pool(), keyID, int64(id))
+	if err != nil {
+		return notFound(err, "collection")
+	}
+	return c.JSON(bundle.Collection)
+}
+
+// DeleteCollection deletes one of the caller's collections
+func DeleteCollection(c *fiber.Ctx) error {
+	id, err := idParam(c)
+	if err != nil {
+		return err
+	}
+
+	if err := collections.Delete(c.UserContext(), db.Pool(), auth.FromContext(c).KeyID, int64(id)); err != nil {
+		return notFound(err, "collection")
+	}
+	audit.SetAffected(c, 1)
+	return c.SendStatus(204)
+}
+
+// AddCollectionItem puts a document, entity or pattern into one of the
+// caller's collections
+func AddCollectionItem(c *fiber.Ctx) error {
+	id, err := idParam(c)
+	if err != nil {
+		return err
+	}
+	var body CollectionItemBody
+	if err := c.BodyParser(&body); err != nil {
+		return apierr.BadRequest("invalid body")
+	}
+	if !slices.Contains(collections.Types, body.Type) {
+		return apierr.InvalidParam("type", "must be one of "+strings.Join(collections.Types, ", "))
+	}
+	if body.ID <= 0 {
+		return apierr.InvalidParam("id", "must be a positive integer")
+	}
+	note := strings.TrimSpace(body.Note)
+	if len(note) > maxNotes {
+		return apierr.InvalidParam("note", "must be at most "+strconv.Itoa(maxNotes)+" characters")
+	}
+
+	item, err := collections.AddItem(c.UserContext(), db.Pool(), auth.FromContext(c).KeyID, int64(id), body.Type, body.ID, note)
+	if errors.Is(err, collections.ErrNoItem) {
+		return apierr.NotFound(body.Type)
+	}
+	if err != nil {
+		return notFound(err, "collection")
+	}
+	audit.SetAffected(c, 1)
+	return c.Status(201).JSON(item)
+}
+
+// RemoveCollectionItem takes an item out of one of the caller's collections
+func RemoveCollectionItem(c *fiber.Ctx) error {
+	id, err := idParam(c)
+	if err != nil {
+		return err
+	}
+	itemID, err := strconv.Atoi(c.Params("itemId"))
+	if err != nil || itemID <= 0 {
+		return apierr.InvalidParam("itemId", "must be a positive integer")
+	}
+
+	if err := collections.RemoveItem(c.UserContext(), db.Pool(), auth.FromContext(c).KeyID, int64(id), int64(itemID)); err != nil {
+		return notFound(err, "item")
+	}
+	audit.SetAffected(c, 1)
+	return c.SendStatus(204)
+}
+
+// ShareCollection makes one of the caller's collections readable by anyone
+// with its token
+func ShareCollection(c *fiber.Ctx) error {
+	id, err := idParam(c)
+	if err != nil {
+		return err
+	}
+
+	token, err := collections.Share(c.UserContext(), db.Pool(), auth.FromContext(c).KeyID, int64(id))
+	if err != nil {
+		return notFound(err, "collection")
+	}
+	audit.SetAffected(c, 1)
+	return c.JSON(ShareLink{Token: token, Path: "/api/shared/" + token})
+}
+
+// UnshareCollection revokes a collection's share token
+func UnshareCollection(c *fiber.Ctx) error {
+	id, err := idParam(c)
+	if err != nil {
+		return err
+	}
+
+	if err := collections.Unshare(c.UserContext(), db.Pool(), auth.FromContext(c).KeyID, int64(id)); err != nil {
+		return notFound(err, "collection")
+	}
+	audit.SetAffected(c, 1)
+	return c.SendStatus(204)
+}
+
+// ExportCollection returns one of the caller's collections as a bundle
+func ExportCollection(c *fiber.Ctx) error {
+	id, err := idParam(c)
+	if err != nil {
+		return err
+	}
+	format, err := enumQuery(c, "format", bundleFormats)
+	if err != nil {
+		return err
+	}
+
+	bundle, err := collections.Get(c.UserContext(), db.Pool(), auth.FromContext(c).KeyID, int64(id))
+	if err != nil {
+		return notFound(err, "collection")
+	}
+	return sendBundle(c, bundle, format)
+}
+
+// GetSharedCollection returns a shared collection with its items
+func GetSharedCollection(c *fiber.Ctx) error {
+	bundle, err := collections.Shared(c.UserContext(), db.Pool(), c.Params("token"))
+	if err != nil {
+		return notFound(err, "collection")
+	}
+	return c.JSON(bundle)
+}
+
+// ExportSharedCollection returns a shared collection as a bundle
+func ExportSharedCollection(c *fiber.Ctx) error {
+	format, err := enumQuery(c, "format", bundleFormats)
+	if err != nil {
+		return err
+	}
+
+	bundle, err := collections.Shared(c.UserContext(), db.Pool(), c.Params("token"))
+	if err != nil {
+		return notFound(err, "collection")
+	}
+	return sendBundle(c, bundle, format)
+}
+
+// sendBundle sends a collection as a zip of JSON and a Markdown report, or
+// as either one alone
+func sendBundle(c *fiber.Ctx, bundle *collections.Bundle, format string) error {
+	name := "collection-" + strconv.FormatInt(bundle.ID, 10)
+	switch format {
+	case "json":
+		c.Set(fiber.HeaderContentDisposition, `attachment; filename="`+name+`.json"`)
+		return c.JSON(bundle)
+	case "markdown":
+		c.Set(fiber.HeaderContentType, "text/markdown; charset=utf-8")
+		c.Set(fiber.HeaderContentDisposition, `attachment; filename="`+name+`.md"`)
+		return c.SendString(bundle.Markdown())
+	}
+
+	var buf bytes.Buffer
+	if err := bundle.WriteZip(&buf); err != nil {
+		return err
+	}
+	c.Set(fiber.HeaderContentType, "application/zip")
+	c.Set(fiber.HeaderContentDisposition, `attachment; filename="`+name+`.zip"`)
+	return c.Send(buf.Bytes())
+}
+
+// collectionBody parses and trims a collection's name and notes
+func collectionBody(c *fiber.Ctx) (CollectionBody, error) {
+	var body CollectionBody
+	if err := c.BodyParser(&body); err != nil {
+		return body, apierr.BadRequest("invalid body")
+	}
+	if body.Name != nil {
+		name := strings.TrimSpace(*body.Name)
+		if name == "" {
+			return body, apierr.InvalidParam("name", "must not be empty")
+		}
+		if len(name) > 200 {
+			return body, apierr.InvalidParam("name", "must be at most 200 characters")
+		}
+		body.Name = &name
+	}
+	if body.Notes != nil && len(*body.Notes) > maxNotes {
+		return body, apierr.InvalidParam("notes", "must be at most "+strconv.Itoa(maxNotes)+" characters")
+	}
+	return body, nil
+}
@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/subculture-collective/epstein-db/api/internal/store"
+)
+
+// notModified sets ETag and Last-Modified for a representation last changed
+// at modified and reports whether the client's cached copy is still current,
+// in which case the handler should reply 304 without querying further. key
+// distinguishes representations of the same row, e.g. query parameters;
+// callers who may read restricted datasets get their own. With
+// CACHE_MAX_AGE set, clients may also reuse it for that long without
+// asking; on a mirror, shared caches may too.
+func notModified(c *fiber.Ctx, key string, modified time.Time) bool {
+	modified = modified.UTC().Truncate(time.Second)
+	if store.CanReadRestricted(c.UserContext()) {
+		key += "+restricted"
+	}
+
+	sum := sha256.Sum256([]byte(key + "@" + strconv.FormatInt(modified.UnixNano(), 10)))
+	etag := `W/"` + hex.EncodeToString(sum[:12]) + `"`
+
+	c.Set(fiber.HeaderETag, etag)
+	c.Set(fiber.HeaderLastModified, modified.Format(http.TimeFormat))
+	if settings != nil && settings.Cache.MaxAge > 0 {
+		control := "max-age=" + strconv.Itoa(int(settings.Cache.MaxAge.Seconds()))
+		if settings.Mirror.Enabled {
+			control = "public, " + control
+		}
+		c.Set(fiber.HeaderCacheControl, control)
+	}
+
+	// If-None-Match takes precedence over If-Modified-Since (RFC 9110 13.2.2)
+	if match := c.Get(fiber.HeaderIfNoneMatch); match != "" {
+		for _, tag := range strings.Split(match, ",") {
+			tag = strings.TrimSpace(tag)
+			if tag == "*" || strings.TrimPrefix(tag, "W/") == strings.TrimPrefix(etag, "W/") {
+				return true
+			}
+		}
+		return false
+	}
+
+	if since := c.Get(fiber.HeaderIfModifiedSince); since != "" {
+		if t, err := http.ParseTime(since); err == nil && !modified.After(t) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,215 @@
+package handlers
+
+import (
+	"errors"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/subculture-collective/epstein-db/api/internal/apierr"
+	"github.com/subculture-collective/epstein-db/api/internal/audit"
+	"github.com/subculture-collective/epstein-db/api/internal/auth"
+	"github.com/subculture-collective/epstein-db/api/internal/corrections"
+	"github.com/subculture-collective/epstein-db/api/internal/db"
+)
+
+// Limits on a submitted correction request
+const (
+	minCorrectionLength = 20
+	maxCorrectionLength = 10000
+	maxRequesterLength  = 200
+)
+
+// CorrectionBody is the body of POST /api/requests/correction
+type CorrectionBody struct {
+	Kind         string `json:"kind" enum:"linkage,association" doc:"linkage disputes how the entity is linked or identified; association its link to documentIds"`
+	EntityID     int    `json:"entityId"`
+	DocumentIDs  []int  `json:"documentIds,omitempty" doc:"association only: the disputed documents, each of which must mention the entity"`
+	Requester    string `json:"requester" doc:"Name of the person making the request"`
+	Relationship string `json:"relationship" enum:"subject,counsel,other" doc:"The requester's relationship to the entity"`
+	Contact      string `json:"contact" doc:"How to reach the requester about the case"`
+	Body         string `json:"body" doc:"What is wrong and why, in 20 to 10000 characters"`
+	Captcha      string `json:"captcha" doc:"Token from the captcha widget"`
+}
+
+// CorrectionReceipt acknowledges a correction request
+type CorrectionReceipt struct {
+	ID     int64  `json:"id"`
+	Status string `json:"status" enum:"open"`
+	Token  string `json:"token" doc:"Pass as ?token= to follow the case; it is shown only once"`
+}
+
+// CorrectionList is one page of the corrections queue
+type CorrectionList struct {
+	Requests []corrections.Request `json:"requests"`
+	Count    int                   `json:"count"`
+	Offset   int                   `json:"offset"`
+	Limit    int                   `json:"limit"`
+}
+
+// CorrectionReviewBody is the body of PUT /api/admin/corrections/:id
+type CorrectionReviewBody struct {
+	Status     string `json:"status" enum:"open,reviewing,upheld,rejected"`
+	Note       string `json:"note,omitempty" doc:"Internal note kept in the case's history"`
+	Resolution string `json:"resolution,omitempty" doc:"Note shown to the submitter; the previous one is kept when empty"`
+}
+
+// RequestCorrection opens a case disputing an entity's linkage or its
+// association with documents
+func RequestCorrection(c *fiber.Ctx) error {
+	var body CorrectionBody
+	if err := c.BodyParser(&body); err != nil {
+		return apierr.BadRequest("invalid body")
+	}
+
+	if !slices.Contains(corrections.Kinds, body.Kind) {
+		return apierr.InvalidParam("kind", "must be one of "+strings.Join(corrections.Kinds, ", "))
+	}
+	if body.EntityID <= 0 {
+		return apierr.InvalidParam("entityId", "is required")
+	}
+	documents, err := tipRefs("documentIds", body.DocumentIDs)
+	if err != nil {
+		return err
+	}
+	if body.Kind == corrections.KindAssociation && len(documents) == 0 {
+		return apierr.InvalidParam("documentIds", "must name the disputed documents")
+	}
+	if body.Kind == corrections.KindLinkage && len(documents) > 0 {
+		return apierr.InvalidParam("documentIds", "is for association requests only")
+	}
+	if !slices.Contains(corrections.Relationships, body.Relationship) {
+		return apierr.InvalidParam("relationship", "must be one of "+strings.Join(corrections.Relationships, ", "))
+	}
+	requester := strings.TrimSpace(body.Requester)
+	if requester == "" || len(requester) > maxRequesterLength {
+		return apierr.InvalidParam("requester", "must be 1 to "+strconv.Itoa(maxRequesterLength)+" characters")
+	}
+	contact := strings.TrimSpace(body.Contact)
+	if contact == "" || len(contact) > maxTipContact {
+		return apierr.InvalidParam("contact", "must be 1 to "+strconv.Itoa(maxTipContact)+" characters")
+	}
+	text := strings.TrimSpace(body.Body)
+	if n := len([]rune(text)); n < minCorrectionLength || n > maxCorrectionLength {
+		return apierr.InvalidParam("body", "must be "+strconv.Itoa(minCorrectionLength)+" to "+strconv.Itoa(maxCorrectionLength)+" characters")
+	}
+
+	if err := checkCaptcha(c, body.Captcha); err != nil {
+		return err
+	}
+
+	r, token, err := corrections.Submit(c.UserContext(), db.Pool(), corrections.Submission{
+		Kind:         body.Kind,
+		EntityID:     body.EntityID,
+		DocumentIDs:  documents,
+		Requester:    requester,
+		Relationship: body.Relationship,
+		Contact:      contact,
+		Body:         text,
+		KeyID:        auth.FromContext(c).KeyID,
+	})
+	if errors.Is(err, corrections.ErrNoReference) {
+		return apierr.BadRequest("entityId must be an existing entity, and documentIds documents that mention it")
+	}
+	if err != nil {
+		return err
+	}
+	return c.Status(fiber.StatusAccepted).JSON(CorrectionReceipt{ID: r.ID, Status: r.Status, Token: token})
+}
+
+// GetCorrectionCase returns a case to its submitter
+func GetCorrectionCase(c *fiber.Ctx) error {
+	id, err := idParam(c)
+	if err != nil {
+		return err
+	}
+	token := c.Query("token")
+	if token == "" {
+		return apierr.InvalidParam("token", "is required")
+	}
+
+	cs, err := corrections.Lookup(c.UserContext(), db.Pool(), int64(id), token)
+	if errors.Is(err, corrections.ErrNotFound) {
+		return apierr.NotFound("correction request")
+	}
+	if err != nil {
+		return err
+	}
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	return c.JSON(cs)
+}
+
+// ListCorrections returns the corrections queue, oldest first
+func ListCorrections(c *fiber.Ctx) error {
+	status, err := enumQuery(c, "status", corrections.Statuses)
+	if err != nil {
+		return err
+	}
+	limit, err := limitQuery(c, 50, 200)
+	if err != nil {
+		return err
+	}
+	offset, err := offsetQuery(c)
+	if err != nil {
+		return err
+	}
+
+	list, err := corrections.List(c.UserContext(), db.Pool(), status, limit, offset)
+	if err != nil {
+		return err
+	}
+	return c.JSON(CorrectionList{Requests: list, Count: len(list), Offset: offset, Limit: limit})
+}
+
+// GetCorrection returns one case with its history
+func GetCorrection(c *fiber.Ctx) error {
+	id, err := idParam(c)
+	if err != nil {
+		return err
+	}
+
+	r, err := corrections.Get(c.UserContext(), db.Pool(), int64(id))
+	if errors.Is(err, corrections.ErrNotFound) {
+		return apierr.NotFound("correction request")
+	}
+	if err != nil {
+		return err
+	}
+	return c.JSON(r)
+}
+
+// ReviewCorrection moves a case along: to reviewing, upheld or rejected, or
+// back to open
+func ReviewCorrection(c *fiber.Ctx) error {
+	id, err := idParam(c)
+	if err != nil {
+		return err
+	}
+	var body CorrectionReviewBody
+	if err := c.BodyParser(&body); err != nil {
+		return apierr.BadRequest("invalid body")
+	}
+	if !slices.Contains(corrections.Statuses, body.Status) {
+		return apierr.InvalidParam("status", "must be one of "+strings.Join(corrections.Statuses, ", "))
+	}
+	note := strings.TrimSpace(body.Note)
+	if len(note) > maxNotes {
+		return apierr.InvalidParam("note", "must be at most "+strconv.Itoa(maxNotes)+" characters")
+	}
+	resolution := strings.TrimSpace(body.Resolution)
+	if len(resolution) > maxNotes {
+		return apierr.InvalidParam("resolution", "must be at most "+strconv.Itoa(maxNotes)+" characters")
+	}
+
+	r, err := corrections.Resolve(c.UserContext(), db.Pool(), int64(id), auth.FromContext(c).KeyID, body.Status, note, resolution)
+	if errors.Is(err, corrections.ErrNotFound) {
+		return apierr.NotFound("correction request")
+	}
+	if err != nil {
+		return err
+	}
+	audit.SetAffected(c, 1)
+	return c.JSON(r)
+}
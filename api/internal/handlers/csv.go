@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const mimeCSV = "text/csv"
+
+// sendList responds with body in the negotiated format. JSON keeps the
+// envelope and narrows each item to fields; CSV writes only the items of
+// the list property, one row each, with a header of their field names.
+func sendList(c *fiber.Ctx, format string, body any, list string, fields []string) error {
+	c.Vary(fiber.HeaderAccept)
+	if format != "csv" {
+		return sendFields(c, body, list, fields)
+	}
+
+	items, columns := listItems(body, list)
+	if len(fields) > 0 {
+		columns = slices.DeleteFunc(columns, func(col string) bool {
+			return !slices.Contains(fields, col)
+		})
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	if err := w.Write(columns); err != nil {
+		return err
+	}
+	row := make([]string, len(columns))
+	for i := 0; i < items.Len(); i++ {
+		// Go through JSON so cells read exactly as the JSON values would
+		raw, err := json.Marshal(items.Index(i).Interface())
+		if err != nil {
+			return err
+		}
+		var item map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &item); err != nil {
+			return err
+		}
+		for j, col := range columns {
+			row[j] = csvCell(item[col])
+		}
+		if err := w.Write(row); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return err
+	}
+
+	c.Set(fiber.HeaderContentType, mimeCSV+"; charset=utf-8")
+	return c.Send(buf.Bytes())
+}
+
+// listItems finds the slice field of struct body named list in JSON, and
+// the JSON names of its element's fields
+func listItems(body any, list string) (reflect.Value, []string) {
+	v := reflect.ValueOf(body)
+	for i := 0; i < v.NumField(); i++ {
+		name, _, _ := strings.Cut(v.Type().Field(i).Tag.Get("json"), ",")
+		if name == list {
+			items := v.Field(i)
+			return items, jsonFields(reflect.Zero(items.Type().Elem()).Interface())
+		}
+	}
+	panic("handlers: " + v.Type().String() + " has no list " + list)
+}
+
+// csvCell renders a JSON value as a cell: strings unquoted, null empty,
+// objects and arrays as JSON. Text that a spreadsheet would run as a
+// formula is prefixed with a quote, since document text is untrusted.
+func csvCell(raw json.RawMessage) string {
+	if len(raw) == 0 || string(raw) == "null" {
+		return ""
+	}
+	if raw[0] != '"' {
+		return string(raw)
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return string(raw)
+	}
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/subculture-collective/epstein-db/api/internal/apierr"
+	"github.com/subculture-collective/epstein-db/api/internal/store"
+)
+
+// DatasetVisibilityBody is the body of PUT
+// /api/admin/datasets/:id/visibility
+type DatasetVisibilityBody struct {
+	Visibility string `json:"visibility" enum:"public,restricted"`
+}
+
+// ListDatasets returns the registered dataset releases
+func ListDatasets(c *fiber.Ctx) error {
+	status, err := enumQuery(c, "status", datasetStatuses)
+	if err != nil {
+		return err
+	}
+
+	sort, err := sortQuery(c, store.DatasetSorts)
+	if err != nil {
+		return err
+	}
+
+	datasets, err := data.ListDatasets(c.UserContext(), status, sort)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(DatasetList{
+		Datasets: datasets,
+		Count:    len(datasets),
+		Warnings: skipped(c),
+	})
+}
+
+// GetDatasetAnalytics summarizes one dataset release
+func GetDatasetAnalytics(c *fiber.Ctx) error {
+	id, err := idParam(c)
+	if err != nil {
+		return err
+	}
+
+	a, err := data.DatasetAnalytics(c.UserContext(), id)
+	if err != nil {
+		return notFound(err, "dataset")
+	}
+	return c.JSON(a)
+}
+
+// UpdateDatasetVisibility makes a dataset public or restricted
+func UpdateDatasetVisibility(c *fiber.Ctx) error {
+	id, err := idParam(c)
+	if err != nil {
+		return err
+	}
+	var body DatasetVisibilityBody
+	if err := c.BodyParser(&body); err != nil {
+		return apierr.BadRequest("invalid body")
+	}
+	if !slices.Contains(store.Visibilities, body.Visibility) {
+		return apierr.InvalidParam("visibility", "must be one of "+strings.Join(store.Visibilities, ", "))
+	}
+
+	d, err := data.SetDatasetVisibility(c.UserContext(), id, body.Visibility)
+	if err != nil {
+		return notFound(err, "dataset")
+	}
+	return c.JSON(d)
+}
@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/subculture-collective/epstein-db/api/internal/apierr"
+	"github.com/subculture-collective/epstein-db/api/internal/auth"
+	"github.com/subculture-collective/epstein-db/api/internal/db"
+	"github.com/subculture-collective/epstein-db/api/internal/descriptions"
+)
+
+// Description limits
+const (
+	maxDescription = 10000
+	maxAttribution = 1000
+)
+
+// DescriptionBody is the body of PUT /api/admin/entities/:id/description
+type DescriptionBody struct {
+	Description  *string `json:"description" doc:"Null or empty clears the description"`
+	Origin       string  `json:"origin" enum:"human,llm" doc:"Who wrote the text; human by default"`
+	Attribution  string  `json:"attribution,omitempty" doc:"Sources, a URL or the model the text is based on"`
+	Comment      string  `json:"comment,omitempty" doc:"Why it changed"`
+	BaseRevision int     `json:"baseRevision,omitempty" doc:"The revision edited; the edit is refused with 409 if the description has changed since"`
+}
+
+// DescriptionRevertBody is the body of POST
+// /api/admin/entities/:id/description/revert
+type DescriptionRevertBody struct {
+	Revision int    `json:"revision"`
+	Comment  string `json:"comment,omitempty"`
+}
+
+// DescriptionHistory is every revision of an entity's description
+type DescriptionHistory struct {
+	EntityID  int                     `json:"entityId"`
+	Revisions []descriptions.Revision `json:"revisions"`
+	Count     int                     `json:"count"`
+}
+
+// DescriptionDiff compares two revisions of a description
+type DescriptionDiff struct {
+	EntityID int               `json:"entityId"`
+	From     int               `json:"from" doc:"Older revision; 0 for the empty description before the first"`
+	To       int               `json:"to"`
+	Ops      []descriptions.Op `json:"ops"`
+}
+
+// GetDescriptionHistory lists the revisions of an entity's description,
+// newest first
+func GetDescriptionHistory(c *fiber.Ctx) error {
+	id, err := idParam(c)
+	if err != nil {
+		return err
+	}
+
+	revisions, err := descriptions.List(c.UserContext(), db.Pool(), id)
+	if err != nil {
+		return notFound(err, "entity")
+	}
+	return c.JSON(DescriptionHistory{EntityID: id, Revisions: revisions, Count: len(revisions)})
+}
+
+// GetDescriptionDiff compares two revisions of an entity's description,
+// by default the latest and the one before it
+func GetDescriptionDiff(c *fiber.Ctx) error {
+	id, err := idParam(c)
+	if err != nil {
+		return err
+	}
+	to, err := intQuery(c, "to", 0)
+	if err != nil {
+		return err
+	}
+	from, err := intQuery(c, "from", -1)
+	if err != nil {
+		return err
+	}
+	if to < 0 {
+		return apierr.InvalidParam("to", "must be a positive integer")
+	}
+	if from < 0 && c.Query("from") != "" {
+		return apierr.InvalidParam("from", "must be 0 or a positive integer")
+	}
+	ctx := c.UserContext()
+
+	newer, err := descriptions.Get(ctx, db.Pool(), id, to)
+	if err != nil {
+		return notFound(err, "revision")
+	}
+	if from < 0 {
+		from = newer.Revision - 1
+	}
+	if from >= newer.Revision {
+		return apierr.InvalidParam("from", "must be before to")
+	}
+	older := &descriptions.Revision{}
+	if from > 0 {
+		if older, err = descriptions.Get(ctx, db.Pool(), id, from); err != nil {
+			return notFound(err, "revision")
+		}
+	}
+
+	text := func(r *descriptions.Revision) string {
+		if r.Description == nil {
+			return ""
+		}
+		return *r.Description
+	}
+	return c.JSON(DescriptionDiff{
+		EntityID: id,
+		From:     from,
+		To:       newer.Revision,
+		Ops:      descriptions.Diff(text(older), text(newer)),
+	})
+}
+
+// UpdateDescription saves a new revision of an entity's description
+func UpdateDescription(c *fiber.Ctx) error {
+	id, err := idParam(c)
+	if err != nil {
+		return err
+	}
+	var body DescriptionBody
+	if err := c.BodyParser(&body); err != nil {
+		return apierr.BadRequest("invalid body")
+	}
+	if body.Origin == "" {
+		body.Origin = descriptions.OriginHuman
+	}
+	if !slices.Contains(descriptions.Origins, body.Origin) {
+		return apierr.InvalidParam("origin", "must be one of "+strings.Join(descriptions.Origins, ", "))
+	}
+	if body.Description != nil {
+		text := strings.TrimSpace(*body.Description)
+		if utf8.RuneCountInString(text) > maxDescription {
+			return apierr.InvalidParam("description", "must be at most 10000 characters")
+		}
+		body.Description = &text
+		if text == "" {
+			body.Description = nil
+		}
+	}
+	if utf8.RuneCountInString(body.Attribution) > maxAttribution {
+		return apierr.InvalidParam("attribution", "must be at most 1000 characters")
+	}
+	if body.BaseRevision < 0 {
+		return apierr.InvalidParam("baseRevision", "must be a positive integer")
+	}
+
+	revision, err := descriptions.Save(c.UserContext(), db.Pool(), id, descriptions.Edit{
+		Description:  body.Description,
+		Origin:       body.Origin,
+		Attribution:  strings.TrimSpace(body.Attribution),
+		Comment:      strings.TrimSpace(body.Comment),
+		BaseRevision: body.BaseRevision,
+		AuthorKey:    auth.FromContext(c).KeyID,
+	})
+	if errors.Is(err, descriptions.ErrConflict) {
+		return apierr.New(fiber.StatusConflict, apierr.CodeConflict, "the description has changed since baseRevision")
+	}
+	if err != nil {
+		return notFound(err, "entity")
+	}
+	return c.JSON(revision)
+}
+
+// RevertDescription restores an earlier revision of an entity's
+// description as a new revision
+func RevertDescription(c *fiber.Ctx) error {
+	id, err := idParam(c)
+	if err != nil {
+		return err
+	}
+	var body DescriptionRevertBody
+	if err := c.BodyParser(&body); err != nil {
+		return apierr.BadRequest("invalid body")
+	}
+	if body.Revision <= 0 {
+		return apierr.InvalidParam("revision", "must be a positive integer")
+	}
+
+	revision, err := descriptions.Revert(c.UserContext(), db.Pool(), id, body.Revision,
+		auth.FromContext(c).KeyID, strings.TrimSpace(body.Comment))
+	if err != nil {
+		return notFound(err, "revision")
+	}
+	return c.JSON(revision)
+}
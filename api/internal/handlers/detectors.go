@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/subculture-collective/epstein-db/api/internal/apierr"
+	"github.com/subculture-collective/epstein-db/api/internal/audit"
+	"github.com/subculture-collective/epstein-db/api/internal/auth"
+	"github.com/subculture-collective/epstein-db/api/internal/db"
+	"github.com/subculture-collective/epstein-db/api/internal/jobs"
+	"github.com/subculture-collective/epstein-db/api/internal/patterns"
+)
+
+// GetTemporalSettings returns the temporal cluster detector's settings
+func GetTemporalSettings(c *fiber.Ctx) error {
+	s, err := patterns.LoadTemporal(c.UserContext(), db.Pool())
+	if err != nil {
+		return err
+	}
+	return c.JSON(s)
+}
+
+// UpdateTemporalSettings changes the temporal cluster detector's settings.
+// Fields left out of the body keep their current values.
+func UpdateTemporalSettings(c *fiber.Ctx) error {
+	s, err := patterns.LoadTemporal(c.UserContext(), db.Pool())
+	if err != nil {
+		return err
+	}
+	if err := c.BodyParser(&s); err != nil {
+		return apierr.BadRequest("invalid body")
+	}
+
+	err = patterns.SaveTemporal(c.UserContext(), db.Pool(), s, auth.FromContext(c).KeyID)
+	var invalid *patterns.SettingsError
+	if errors.As(err, &invalid) {
+		return apierr.InvalidParam(invalid.Field, invalid.Message)
+	}
+	if err != nil {
+		return err
+	}
+	audit.SetAffected(c, 1)
+	return c.JSON(s)
+}
+
+// QueuePatternDetection queues a background run of the pattern detectors
+func QueuePatternDetection(c *fiber.Ctx) error {
+	id, err := jobs.NewQueue(db.Pool()).Enqueue(c.UserContext(), patterns.JobKind, struct{}{})
+	if err != nil {
+		return err
+	}
+
+	audit.SetJob(c, id)
+
+	return c.Status(202).JSON(QueuedJob{
+		JobID:  id,
+		Status: jobs.StatusQueued,
+	})
+}
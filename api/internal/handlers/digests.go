@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"errors"
+	"net/mail"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/subculture-collective/epstein-db/api/internal/apierr"
+	"github.com/subculture-collective/epstein-db/api/internal/auth"
+	"github.com/subculture-collective/epstein-db/api/internal/db"
+	"github.com/subculture-collective/epstein-db/api/internal/digest"
+)
+
+// SubscribeBody is the body of POST /api/digests
+type SubscribeBody struct {
+	Email     string `json:"email"`
+	Frequency string `json:"frequency" enum:"daily,weekly"`
+	EntityIDs []int  `json:"entityIds,omitempty" doc:"Entities whose new documents to include"`
+}
+
+// SubscriptionList is a list of digest subscriptions
+type SubscriptionList struct {
+	Subscriptions []digest.Subscription `json:"subscriptions"`
+	Count         int                   `json:"count"`
+}
+
+// Subscribe signs an address up for digests for the caller's key
+func Subscribe(c *fiber.Ctx) error {
+	var body SubscribeBody
+	if err := c.BodyParser(&body); err != nil {
+		return apierr.BadRequest("invalid body")
+	}
+
+	addr, err := mail.ParseAddress(body.Email)
+	if err != nil {
+		return apierr.InvalidParam("email", "must be an email address")
+	}
+	if !slices.Contains(digest.Frequencies, body.Frequency) {
+		return apierr.InvalidParam("frequency", "must be one of "+strings.Join(digest.Frequencies, ", "))
+	}
+	slices.Sort(body.EntityIDs)
+	body.EntityIDs = slices.Compact(body.EntityIDs)
+	if len(body.EntityIDs) > digest.MaxEntities {
+		return apierr.InvalidParam("entityIds", "must list at most "+strconv.Itoa(digest.MaxEntities)+" entities")
+	}
+	if body.EntityIDs == nil {
+		body.EntityIDs = []int{}
+	}
+
+	s, err := digest.Subscribe(c.UserContext(), db.Pool(), auth.FromContext(c).KeyID, addr.Address, body.Frequency, body.EntityIDs)
+	if errors.Is(err, digest.ErrNoEntity) {
+		return apierr.InvalidParam("entityIds", "must be existing entities")
+	}
+	if err != nil {
+		return err
+	}
+	return c.Status(201).JSON(s)
+}
+
+// ListSubscriptions returns the caller's active digest subscriptions
+func ListSubscriptions(c *fiber.Ctx) error {
+	list, err := digest.List(c.UserContext(), db.Pool(), auth.FromContext(c).KeyID)
+	if err != nil {
+		return err
+	}
+	return c.JSON(SubscriptionList{Subscriptions: list, Count: len(list)})
+}
+
+// DeleteSubscription removes one of the caller's digest subscriptions
+func DeleteSubscription(c *fiber.Ctx) error {
+	id, err := idParam(c)
+	if err != nil {
+		return err
+	}
+
+	if err := digest.Delete(c.UserContext(), db.Pool(), auth.FromContext(c).KeyID, int64(id)); err != nil {
+		return notFound(err, "subscription")
+	}
+	return c.SendStatus(204)
+}
+
+// Unsubscribe ends the subscription a digest's unsubscribe link names. It
+// answers both the link (GET) and mail clients' one-click POST (RFC 8058).
+func Unsubscribe(c *fiber.Ctx) error {
+	token := c.Query("token")
+	if token == "" {
+		return apierr.InvalidParam("token", "is required")
+	}
+
+	if err := digest.Unsubscribe(c.UserContext(), db.Pool(), token); err != nil {
+		return notFound(err, "subscription")
+	}
+	return c.SendString("You have been unsubscribed and will receive no more digests.\n")
+}
@@ -1,238 +1,302 @@
 package handlers
 
 import (
-	"context"
 	"strconv"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/subculture-collective/epstein-db/api/internal/apierr"
+	"github.com/subculture-collective/epstein-db/api/internal/cite"
 	"github.com/subculture-collective/epstein-db/api/internal/db"
+	"github.com/subculture-collective/epstein-db/api/internal/models"
+	"github.com/subculture-collective/epstein-db/api/internal/roles"
+	"github.com/subculture-collective/epstein-db/api/internal/store"
 )
 
 // ListDocuments returns a paginated list of documents
 func ListDocuments(c *fiber.Ctx) error {
-	ctx := context.Background()
-	pool := db.Pool()
-
-	limitStr := c.Query("limit", "50")
-	limit, _ := strconv.Atoi(limitStr)
-	if limit > 200 {
-		limit = 200
+	limit, err := limitQuery(c, 50, 200)
+	if err != nil {
+		return err
 	}
 
-	offsetStr := c.Query("offset", "0")
-	offset, _ := strconv.Atoi(offsetStr)
+	offset, err := offsetQuery(c)
+	if err != nil {
+		return err
+	}
 
-	docType := c.Query("type", "")
-	dataset := c.Query("dataset", "")
+	datasetID, err := idQuery(c, "dataset")
+	if err != nil {
+		return err
+	}
 
-	rows, err := pool.Query(ctx, `
-		SELECT id, doc_id, dataset_id, document_type, summary, date_earliest, date_latest
-		FROM documents
-		WHERE ($1 = '' OR document_type = $1)
-		  AND ($2 = '' OR dataset_id = $2::int)
-		ORDER BY doc_id
-		LIMIT $3 OFFSET $4
-	`, docType, dataset, limit, offset)
+	sort, err := sortQuery(c, store.DocumentSorts)
 	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+		return err
 	}
-	defer rows.Close()
 
-	var documents []fiber.Map
-	for rows.Next() {
-		var id, datasetID int
-		var docID string
-		var docType, summary *string
-		var dateEarliest, dateLatest *string
+	fields, err := fieldsQuery(c, documentFields)
+	if err != nil {
+		return err
+	}
 
-		if err := rows.Scan(&id, &docID, &datasetID, &docType, &summary, &dateEarliest, &dateLatest); err != nil {
-			continue
-		}
+	format, err := formatQuery(c)
+	if err != nil {
+		return err
+	}
 
-		documents = append(documents, fiber.Map{
-			"id":           id,
-			"docId":        docID,
-			"datasetId":    datasetID,
-			"documentType": docType,
-			"summary":      summary,
-			"dateEarliest": dateEarliest,
-			"dateLatest":   dateLatest,
-		})
-	}
-
-	return c.JSON(fiber.Map{
-		"documents": documents,
-		"count":     len(documents),
-		"offset":    offset,
-		"limit":     limit,
+	documents, err := data.ListDocuments(c.UserContext(), store.DocumentFilter{
+		Type:      c.Query("type", ""),
+		DatasetID: datasetID,
+		Tag:       c.Query("tag", ""),
+		Sort:      sort,
+		Limit:     limit,
+		Offset:    offset,
 	})
+	if err != nil {
+		return err
+	}
+
+	return sendList(c, format, DocumentPage{
+		Documents: documents,
+		Count:     len(documents),
+		Offset:    offset,
+		Limit:     limit,
+		Warnings:  skipped(c),
+	}, "documents", fields)
 }
 
 // GetDocument returns a single document by ID
 func GetDocument(c *fiber.Ctx) error {
-	ctx := context.Background()
-	pool := db.Pool()
+	id, err := idParam(c)
+	if err != nil {
+		return err
+	}
 
-	id, err := strconv.Atoi(c.Params("id"))
+	doc, err := data.GetDocument(c.UserContext(), id)
 	if err != nil {
-		return c.Status(400).JSON(fiber.Map{"error": "invalid id"})
+		return notFound(err, "document")
 	}
 
-	var doc struct {
-		ID              int     `json:"id"`
-		DocID           string  `json:"docId"`
-		DatasetID       int     `json:"datasetId"`
-		DocumentType    *string `json:"documentType"`
-		Summary         *string `json:"summary"`
-		DetailedSummary *string `json:"detailedSummary"`
-		DateEarliest    *string `json:"dateEarliest"`
-		DateLatest      *string `json:"dateLatest"`
-		ContentTags     []byte  `json:"contentTags"`
-		PageCount       *int    `json:"pageCount"`
+	if notModified(c, "document:"+strconv.Itoa(id), doc.UpdatedAt) {
+		return c.SendStatus(304)
 	}
 
-	err = pool.QueryRow(ctx, `
-		SELECT id, doc_id, dataset_id, document_type, summary, detailed_summary,
-			   date_earliest::text, date_latest::text, content_tags, page_count
-		FROM documents WHERE id = $1
-	`, id).Scan(
-		&doc.ID, &doc.DocID, &doc.DatasetID, &doc.DocumentType,
-		&doc.Summary, &doc.DetailedSummary, &doc.DateEarliest,
-		&doc.DateLatest, &doc.ContentTags, &doc.PageCount,
-	)
+	return c.JSON(doc)
+}
+
+// RandomDocument returns a random document, optionally of one type or
+// dataset. Every request picks anew, so the response isn't cacheable.
+func RandomDocument(c *fiber.Ctx) error {
+	datasetID, err := idQuery(c, "dataset")
+	if err != nil {
+		return err
+	}
 
+	doc, err := data.RandomDocument(c.UserContext(), store.DocumentFilter{
+		Type:      c.Query("type", ""),
+		DatasetID: datasetID,
+	})
 	if err != nil {
-		return c.Status(404).JSON(fiber.Map{"error": "document not found"})
+		return notFound(err, "document")
 	}
 
+	c.Set(fiber.HeaderCacheControl, "no-store")
 	return c.JSON(doc)
 }
 
+// CiteDocument returns a citation of a document in ?format=, BibTeX by
+// default, with today as the access date
+func CiteDocument(c *fiber.Ctx) error {
+	id, err := idParam(c)
+	if err != nil {
+		return err
+	}
+	format, err := enumQuery(c, "format", cite.Formats)
+	if err != nil {
+		return err
+	}
+
+	r, err := cite.Load(c.UserContext(), db.ReadPool(), id, siteURL(c)+"/documents/"+strconv.Itoa(id), time.Now())
+	if err != nil {
+		return notFound(err, "document")
+	}
+
+	switch format {
+	case cite.FormatCSLJSON:
+		body, err := r.CSL()
+		if err != nil {
+			return err
+		}
+		c.Set(fiber.HeaderContentType, cite.MediaCSLJSON)
+		return c.Send(body)
+	case cite.FormatChicago:
+		c.Set(fiber.HeaderContentType, fiber.MIMETextPlainCharsetUTF8)
+		return c.SendString(r.Chicago())
+	}
+	c.Set(fiber.HeaderContentType, cite.MediaBibTeX+"; charset=utf-8")
+	return c.SendString(r.BibTeX())
+}
+
 // GetDocumentText returns the full text of a document
 func GetDocumentText(c *fiber.Ctx) error {
-	ctx := context.Background()
-	pool := db.Pool()
+	ctx := c.UserContext()
 
-	id, err := strconv.Atoi(c.Params("id"))
+	id, err := idParam(c)
 	if err != nil {
-		return c.Status(400).JSON(fiber.Map{"error": "invalid id"})
+		return err
+	}
+
+	// Check freshness before reading the text, which can be large
+	updatedAt, err := data.DocumentUpdatedAt(ctx, id)
+	if err != nil {
+		return notFound(err, "document")
+	}
+	if notModified(c, "document-text:"+strconv.Itoa(id), updatedAt) {
+		return c.SendStatus(304)
 	}
 
-	var text *string
-	err = pool.QueryRow(ctx, "SELECT full_text FROM documents WHERE id = $1", id).Scan(&text)
+	text, err := data.DocumentText(ctx, id)
 	if err != nil {
-		return c.Status(404).JSON(fiber.Map{"error": "document not found"})
+		return restricted(err, "document")
 	}
 
-	return c.JSON(fiber.Map{
-		"id":   id,
-		"text": text,
+	return c.JSON(DocumentText{
+		ID:   id,
+		Text: text,
 	})
 }
 
 // GetDocumentEntities returns entities mentioned in a document
 func GetDocumentEntities(c *fiber.Ctx) error {
-	ctx := context.Background()
-	pool := db.Pool()
-
-	id, err := strconv.Atoi(c.Params("id"))
+	id, err := idParam(c)
 	if err != nil {
-		return c.Status(400).JSON(fiber.Map{"error": "invalid id"})
+		return err
 	}
 
-	rows, err := pool.Query(ctx, `
-		SELECT e.id, e.canonical_name, e.entity_type, e.layer, de.mention_count
-		FROM entities e
-		JOIN document_entities de ON e.id = de.entity_id
-		WHERE de.document_id = $1
-		ORDER BY de.mention_count DESC
-	`, id)
+	role, err := enumQuery(c, "role", roles.Names())
 	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+		return err
 	}
-	defer rows.Close()
-
-	var entities []fiber.Map
-	for rows.Next() {
-		var entityID int
-		var name, etype string
-		var layer *int
-		var mentions int
 
-		if err := rows.Scan(&entityID, &name, &etype, &layer, &mentions); err != nil {
-			continue
-		}
-
-		entities = append(entities, fiber.Map{
-			"id":            entityID,
-			"canonicalName": name,
-			"entityType":    etype,
-			"layer":         layer,
-			"mentionCount":  mentions,
-		})
+	entities, err := data.DocumentEntities(c.UserContext(), id, role)
+	if err != nil {
+		return err
 	}
 
-	return c.JSON(fiber.Map{
-		"entities": entities,
-		"count":    len(entities),
+	return c.JSON(DocumentEntityList{
+		Entities: entities,
+		Count:    len(entities),
+		Warnings: skipped(c),
 	})
 }
 
 // FullTextSearch searches document text
 func FullTextSearch(c *fiber.Ctx) error {
-	ctx := context.Background()
-	pool := db.Pool()
-
 	query := c.Query("q", "")
 	if query == "" {
-		return c.Status(400).JSON(fiber.Map{"error": "query required"})
+		return apierr.InvalidParam("q", "is required")
 	}
 
-	limitStr := c.Query("limit", "20")
-	limit, _ := strconv.Atoi(limitStr)
-	if limit > 100 {
-		limit = 100
+	limit, err := limitQuery(c, 20, 100)
+	if err != nil {
+		return err
 	}
 
-	rows, err := pool.Query(ctx, `
-		SELECT id, doc_id, document_type, summary,
-			   ts_rank(to_tsvector('english', full_text), plainto_tsquery('english', $1)) AS rank,
-			   ts_headline('english', full_text, plainto_tsquery('english', $1), 
-			   			   'MaxWords=50, MinWords=20, StartSel=<mark>, StopSel=</mark>') AS snippet
-		FROM documents
-		WHERE to_tsvector('english', full_text) @@ plainto_tsquery('english', $1)
-		ORDER BY rank DESC
-		LIMIT $2
-	`, query, limit)
+	mode, err := enumQuery(c, "mode", searchModes)
 	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+		return err
 	}
-	defer rows.Close()
 
-	var results []fiber.Map
-	for rows.Next() {
-		var id int
-		var docID string
-		var docType, summary, snippet *string
-		var rank float64
+	datasetID, err := idQuery(c, "dataset")
+	if err != nil {
+		return err
+	}
+	entityID, err := idQuery(c, "entityId")
+	if err != nil {
+		return err
+	}
+
+	filter := store.SearchFilter{
+		Query:     query,
+		DatasetID: datasetID,
+		Type:      c.Query("type", ""),
+		EntityID:  entityID,
+		Tag:       c.Query("tag", ""),
+		Dedupe:    c.QueryBool("dedupe", false),
+		Limit:     limit,
+	}
 
-		if err := rows.Scan(&id, &docID, &docType, &summary, &rank, &snippet); err != nil {
-			continue
+	var results []models.SearchResult
+	if mode == searchNgram {
+		filter.Fuzziness, err = fractionQuery(c, "fuzziness", defaultFuzziness)
+		if err != nil {
+			return err
+		}
+		if err := guardNgramSearch(c, filter); err != nil {
+			return err
+		}
+		results, err = data.SearchNgrams(c.UserContext(), filter)
+	} else {
+		if err := guardSearch(c, filter); err != nil {
+			return err
 		}
+		results, err = data.SearchText(c.UserContext(), filter)
+	}
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(SearchResults{
+		Results:  results,
+		Count:    len(results),
+		Query:    query,
+		Warnings: skipped(c),
+	})
+}
+
+// GetDocumentProvenance traces a document back to its original source file
+func GetDocumentProvenance(c *fiber.Ctx) error {
+	id, err := idParam(c)
+	if err != nil {
+		return err
+	}
 
-		results = append(results, fiber.Map{
-			"id":           id,
-			"docId":        docID,
-			"documentType": docType,
-			"summary":      summary,
-			"rank":         rank,
-			"snippet":      snippet,
-		})
+	p, err := data.DocumentProvenance(c.UserContext(), id)
+	if err != nil {
+		return notFound(err, "document")
 	}
 
-	return c.JSON(fiber.Map{
-		"results": results,
-		"count":   len(results),
-		"query":   query,
+	return c.JSON(p)
+}
+
+// GetDocumentDuplicates lists the near duplicates of a document
+func GetDocumentDuplicates(c *fiber.Ctx) error {
+	id, err := idParam(c)
+	if err != nil {
+		return err
+	}
+
+	documents, err := data.DocumentDuplicates(c.UserContext(), id)
+	if err != nil {
+		return err
+	}
+	if documents == nil {
+		documents = []models.DuplicateDocument{}
+	}
+
+	return c.JSON(DuplicateList{
+		Documents: documents,
+		Count:     len(documents),
+		Warnings:  skipped(c),
 	})
 }
+
+// GetDocumentTypes lists the document types in use with their counts
+func GetDocumentTypes(c *fiber.Ctx) error {
+	types, err := data.DocumentTypeCounts(c.UserContext())
+	if err != nil {
+		return err
+	}
+	return c.JSON(TypeList{Types: types, Count: len(types)})
+}
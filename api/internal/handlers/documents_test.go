@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/subculture-collective/epstein-db/api/internal/apierr"
+	"github.com/subculture-collective/epstein-db/api/internal/models"
+)
+
+func TestListDocuments(t *testing.T) {
+	page := func(t *testing.T, body []byte) (DocumentPage, []int) {
+		p := decode[DocumentPage](t, body)
+		ids := make([]int, len(p.Documents))
+		for i, d := range p.Documents {
+			ids[i] = d.ID
+		}
+		return p, ids
+	}
+	runCases(t, []handlerCase{
+		{name: "all, by doc ID", target: "/api/documents", status: http.StatusOK,
+			check: func(t *testing.T, body []byte) {
+				p, ids := page(t, body)
+				wantIDs(t, ids, []int{1, 2, 3, 4})
+				if p.Count != 4 || p.Offset != 0 || p.Limit != 50 {
+					t.Errorf("page %d at %d of %d, want 4 at 0 of 50", p.Count, p.Offset, p.Limit)
+				}
+			}},
+		{name: "first page", target: "/api/documents?limit=2", status: http.StatusOK,
+			check: func(t *testing.T, body []byte) {
+				_, ids := page(t, body)
+				wantIDs(t, ids, []int{1, 2})
+			}},
+		{name: "second page", target: "/api/documents?limit=2&offset=2", status: http.StatusOK,
+			check: func(t *testing.T, body []byte) {
+				p, ids := page(t, body)
+				wantIDs(t, ids, []int{3, 4})
+				if p.Offset != 2 || p.Limit != 2 {
+					t.Errorf("offset %d and limit %d, want 2 and 2", p.Offset, p.Limit)
+				}
+			}},
+		{name: "past the end", target: "/api/documents?offset=10", status: http.StatusOK,
+			check: func(t *testing.T, body []byte) {
+				_, ids := page(t, body)
+				wantIDs(t, ids, []int{})
+			}},
+		{name: "limit capped", target: "/api/documents?limit=1000", status: http.StatusOK,
+			check: func(t *testing.T, body []byte) {
+				if p, _ := page(t, body); p.Limit != 200 {
+					t.Errorf("limit %d, want 200", p.Limit)
+				}
+			}},
+		{name: "by type", target: "/api/documents?type=deposition", status: http.StatusOK,
+			check: func(t *testing.T, body []byte) {
+				_, ids := page(t, body)
+				wantIDs(t, ids, []int{3})
+			}},
+		{name: "by dataset", target: "/api/documents?dataset=2", status: http.StatusOK,
+			check: func(t *testing.T, body []byte) {
+				_, ids := page(t, body)
+				wantIDs(t, ids, []int{})
+			}},
+		{name: "sorted by id", target: "/api/documents?sort=id&order=desc", status: http.StatusOK,
+			check: func(t *testing.T, body []byte) {
+				_, ids := page(t, body)
+				wantIDs(t, ids, []int{4, 3, 2, 1})
+			}},
+		{name: "negative offset", target: "/api/documents?offset=-1", status: http.StatusBadRequest, code: apierr.CodeInvalidParam},
+		{name: "malformed limit", target: "/api/documents?limit=ten", status: http.StatusBadRequest, code: apierr.CodeInvalidParam},
+		{name: "malformed dataset", target: "/api/documents?dataset=x", status: http.StatusBadRequest, code: apierr.CodeInvalidParam},
+		{name: "unknown sort", target: "/api/documents?sort=title", status: http.StatusBadRequest, code: apierr.CodeInvalidParam},
+	})
+}
+
+func TestGetDocument(t *testing.T) {
+	runCases(t, []handlerCase{
+		{name: "found", target: "/api/documents/2", status: http.StatusOK,
+			check: func(t *testing.T, body []byte) {
+				d := decode[models.Document](t, body)
+				if d.ID != 2 || d.DocID != "EFTA00000002" || d.Summary == nil || *d.Summary != "A wire transfer." {
+					t.Fatalf("document %+v, want EFTA00000002", d)
+				}
+			}},
+		{name: "missing", target: "/api/documents/999", status: http.StatusNotFound, code: apierr.CodeNotFound},
+		{name: "malformed id", target: "/api/documents/-1", status: http.StatusBadRequest, code: apierr.CodeInvalidParam},
+	})
+}
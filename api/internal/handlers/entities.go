@@ -1,136 +1,169 @@
 package handlers
 
 import (
-	"context"
+	"slices"
 	"strconv"
+	"strings"
 
 	"github.com/gofiber/fiber/v2"
+
+	"github.com/subculture-collective/epstein-db/api/internal/apierr"
+	"github.com/subculture-collective/epstein-db/api/internal/audit"
+	"github.com/subculture-collective/epstein-db/api/internal/bio"
 	"github.com/subculture-collective/epstein-db/api/internal/db"
+	"github.com/subculture-collective/epstein-db/api/internal/jobs"
+	"github.com/subculture-collective/epstein-db/api/internal/narrative"
+	"github.com/subculture-collective/epstein-db/api/internal/roles"
+	"github.com/subculture-collective/epstein-db/api/internal/store"
 )
 
 // GetStats returns database statistics
 func GetStats(c *fiber.Ctx) error {
-	ctx := context.Background()
-	pool := db.Pool()
-
-	var stats struct {
-		Documents    int64  `json:"documents"`
-		Entities     int64  `json:"entities"`
-		Triples      int64  `json:"triples"`
-		PPPLoans     int64  `json:"pppLoans"`
-		FECRecords   int64  `json:"fecRecords"`
-		Grants       int64  `json:"grants"`
-		Patterns     int64  `json:"patterns"`
-	}
-
-	pool.QueryRow(ctx, "SELECT COUNT(*) FROM documents").Scan(&stats.Documents)
-	pool.QueryRow(ctx, "SELECT COUNT(*) FROM entities").Scan(&stats.Entities)
-	pool.QueryRow(ctx, "SELECT COUNT(*) FROM triples").Scan(&stats.Triples)
-	pool.QueryRow(ctx, "SELECT COUNT(*) FROM ppp_loans").Scan(&stats.PPPLoans)
-	pool.QueryRow(ctx, "SELECT COUNT(*) FROM fec_contributions").Scan(&stats.FECRecords)
-	pool.QueryRow(ctx, "SELECT COUNT(*) FROM federal_grants").Scan(&stats.Grants)
-	pool.QueryRow(ctx, "SELECT COUNT(*) FROM pattern_findings").Scan(&stats.Patterns)
+	stats, err := data.Stats(c.UserContext())
+	if err != nil {
+		return err
+	}
 
 	return c.JSON(stats)
 }
 
 // SearchEntities searches for entities by name
 func SearchEntities(c *fiber.Ctx) error {
-	ctx := context.Background()
-	pool := db.Pool()
-
-	query := c.Query("q", "")
-	limitStr := c.Query("limit", "20")
-	limit, _ := strconv.Atoi(limitStr)
-	if limit > 100 {
-		limit = 100
+	limit, err := limitQuery(c, 20, 100)
+	if err != nil {
+		return err
 	}
 
-	entityType := c.Query("type", "")
-	layer := c.Query("layer", "")
+	entityType, err := enumQuery(c, "type", entityTypes)
+	if err != nil {
+		return err
+	}
+	layer, err := enumQuery(c, "layer", layers)
+	if err != nil {
+		return err
+	}
+	match, err := enumQuery(c, "match", store.MatchModes)
+	if err != nil {
+		return err
+	}
+	sort, err := sortQuery(c, store.EntitySorts)
+	if err != nil {
+		return err
+	}
+	fields, err := fieldsQuery(c, entityFields)
+	if err != nil {
+		return err
+	}
 
-	sqlQuery := `
-		SELECT id, canonical_name, entity_type, layer, document_count, connection_count
-		FROM entities
-		WHERE ($1 = '' OR canonical_name ILIKE '%' || $1 || '%' OR canonical_name % $1)
-		  AND ($2 = '' OR entity_type = $2::entity_type)
-		  AND ($3 = '' OR layer = $3::int)
-		ORDER BY 
-			CASE WHEN $1 != '' THEN similarity(canonical_name, $1) ELSE 0 END DESC,
-			document_count DESC
-		LIMIT $4
-	`
+	format, err := formatQuery(c)
+	if err != nil {
+		return err
+	}
 
-	rows, err := pool.Query(ctx, sqlQuery, query, entityType, layer, limit)
+	entities, err := data.SearchEntities(c.UserContext(), store.EntityFilter{
+		Query: c.Query("q", ""),
+		Match: match,
+		Type:  entityType,
+		Layer: layer,
+		Sort:  sort,
+		Limit: limit,
+	})
 	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+		return err
 	}
-	defer rows.Close()
 
-	var entities []fiber.Map
-	for rows.Next() {
-		var id int
-		var name, etype string
-		var layerVal, docCount, connCount *int
+	return sendList(c, format, EntityList{
+		Entities: entities,
+		Count:    len(entities),
+		Warnings: skipped(c),
+	}, "entities", fields)
+}
+
+// maxResolveNames bounds the names resolved in one request
+const maxResolveNames = 200
 
-		if err := rows.Scan(&id, &name, &etype, &layerVal, &docCount, &connCount); err != nil {
-			continue
-		}
+// ResolveBody is the body of POST /api/entities/resolve
+type ResolveBody struct {
+	Names        []string `json:"names" doc:"1 to 200 names as written in the external list"`
+	Type         string   `json:"type" doc:"Only entities of this type"`
+	Match        string   `json:"match" doc:"fuzzy (default), prefix or exact"`
+	Alternatives *int     `json:"alternatives" doc:"Further candidates per name, 0 to 10; default 3"`
+}
 
-		entities = append(entities, fiber.Map{
-			"id":              id,
-			"canonicalName":   name,
-			"entityType":      etype,
-			"layer":           layerVal,
-			"documentCount":   docCount,
-			"connectionCount": connCount,
-		})
+// ResolveEntities matches a list of names, such as a flight manifest or a
+// donor list, to the entities they most likely refer to
+func ResolveEntities(c *fiber.Ctx) error {
+	var body ResolveBody
+	if err := c.BodyParser(&body); err != nil {
+		return apierr.BadRequest("invalid body")
+	}
+	if len(body.Names) == 0 || len(body.Names) > maxResolveNames {
+		return apierr.InvalidParam("names", "must list 1 to "+strconv.Itoa(maxResolveNames)+" names")
+	}
+	if body.Type != "" && !slices.Contains(entityTypes, body.Type) {
+		return apierr.InvalidParam("type", "must be one of "+strings.Join(entityTypes, ", "))
+	}
+	if body.Match != "" && !slices.Contains(store.MatchModes, body.Match) {
+		return apierr.InvalidParam("match", "must be one of "+strings.Join(store.MatchModes, ", "))
+	}
+	alternatives := 3
+	if body.Alternatives != nil {
+		alternatives = *body.Alternatives
+	}
+	if alternatives < 0 || alternatives > 10 {
+		return apierr.InvalidParam("alternatives", "must be between 0 and 10")
+	}
+
+	resolutions, err := data.ResolveEntities(c.UserContext(), store.ResolveFilter{
+		Names:      body.Names,
+		Match:      body.Match,
+		Type:       body.Type,
+		Candidates: alternatives + 1,
+	})
+	if err != nil {
+		return err
 	}
 
-	return c.JSON(fiber.Map{
-		"entities": entities,
-		"count":    len(entities),
+	matched := 0
+	for _, r := range resolutions {
+		if r.Match != nil {
+			matched++
+		}
+	}
+	return c.JSON(ResolveResults{
+		Results:  resolutions,
+		Count:    len(resolutions),
+		Matched:  matched,
+		Warnings: skipped(c),
 	})
 }
 
 // GetEntity returns a single entity by ID
 func GetEntity(c *fiber.Ctx) error {
-	ctx := context.Background()
-	pool := db.Pool()
+	id, err := idParam(c)
+	if err != nil {
+		return err
+	}
 
-	id, err := strconv.Atoi(c.Params("id"))
+	format, err := entityFormat(c)
 	if err != nil {
-		return c.Status(400).JSON(fiber.Map{"error": "invalid id"})
+		return err
 	}
 
-	var entity struct {
-		ID              int     `json:"id"`
-		CanonicalName   string  `json:"canonicalName"`
-		EntityType      string  `json:"entityType"`
-		Layer           *int    `json:"layer"`
-		Description     *string `json:"description"`
-		DocumentCount   *int    `json:"documentCount"`
-		ConnectionCount *int    `json:"connectionCount"`
-		Aliases         []byte  `json:"aliases"`
-		PPPMatches      []byte  `json:"pppMatches"`
-		FECMatches      []byte  `json:"fecMatches"`
-		GrantsMatches   []byte  `json:"grantsMatches"`
+	entity, err := data.GetEntity(c.UserContext(), id)
+	if err != nil {
+		return notFound(err, "entity")
 	}
 
-	err = pool.QueryRow(ctx, `
-		SELECT id, canonical_name, entity_type, layer, description, 
-			   document_count, connection_count, aliases,
-			   ppp_matches, fec_matches, grants_matches
-		FROM entities WHERE id = $1
-	`, id).Scan(
-		&entity.ID, &entity.CanonicalName, &entity.EntityType,
-		&entity.Layer, &entity.Description, &entity.DocumentCount,
-		&entity.ConnectionCount, &entity.Aliases,
-		&entity.PPPMatches, &entity.FECMatches, &entity.GrantsMatches,
-	)
+	c.Vary(fiber.HeaderAccept)
+	if format == "jsonld" {
+		// Relationships change without touching the entity, so there's no
+		// validator to offer
+		return sendEntityJSONLD(c, entity)
+	}
 
-	if err != nil {
-		return c.Status(404).JSON(fiber.Map{"error": "entity not found"})
+	if notModified(c, "entity:"+strconv.Itoa(id), entity.UpdatedAt) {
+		return c.SendStatus(304)
 	}
 
 	return c.JSON(entity)
@@ -138,113 +171,180 @@ func GetEntity(c *fiber.Ctx) error {
 
 // GetEntityConnections returns entities connected to a given entity
 func GetEntityConnections(c *fiber.Ctx) error {
-	ctx := context.Background()
-	pool := db.Pool()
-
-	id, err := strconv.Atoi(c.Params("id"))
+	id, err := idParam(c)
 	if err != nil {
-		return c.Status(400).JSON(fiber.Map{"error": "invalid id"})
+		return err
 	}
 
-	limitStr := c.Query("limit", "50")
-	limit, _ := strconv.Atoi(limitStr)
-	if limit > 200 {
-		limit = 200
+	limit, err := limitQuery(c, 50, 200)
+	if err != nil {
+		return err
 	}
 
-	rows, err := pool.Query(ctx, `
-		SELECT 
-			e2.id, e2.canonical_name, e2.entity_type, e2.layer,
-			COUNT(DISTINCT d.id) AS shared_docs
-		FROM document_entities de1
-		JOIN document_entities de2 ON de1.document_id = de2.document_id AND de1.entity_id != de2.entity_id
-		JOIN entities e2 ON de2.entity_id = e2.id
-		JOIN documents d ON de1.document_id = d.id
-		WHERE de1.entity_id = $1
-		GROUP BY e2.id, e2.canonical_name, e2.entity_type, e2.layer
-		ORDER BY shared_docs DESC
-		LIMIT $2
-	`, id, limit)
+	granularity, err := enumQuery(c, "granularity", store.Granularities)
 	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+		return err
 	}
-	defer rows.Close()
 
-	var connections []fiber.Map
-	for rows.Next() {
-		var connID int
-		var name, etype string
-		var layerVal *int
-		var sharedDocs int
+	sort, err := sortQuery(c, store.ConnectionSorts)
+	if err != nil {
+		return err
+	}
 
-		if err := rows.Scan(&connID, &name, &etype, &layerVal, &sharedDocs); err != nil {
-			continue
-		}
+	format, err := formatQuery(c)
+	if err != nil {
+		return err
+	}
 
-		connections = append(connections, fiber.Map{
-			"id":            connID,
-			"canonicalName": name,
-			"entityType":    etype,
-			"layer":         layerVal,
-			"sharedDocs":    sharedDocs,
-		})
+	connections, err := data.EntityConnections(c.UserContext(), id, granularity, sort, limit)
+	if err != nil {
+		return err
 	}
 
-	return c.JSON(fiber.Map{
-		"connections": connections,
-		"count":       len(connections),
-	})
+	return sendList(c, format, ConnectionList{
+		Connections: connections,
+		Count:       len(connections),
+		Warnings:    skipped(c),
+	}, "connections", nil)
 }
 
 // GetEntityDocuments returns documents mentioning an entity
 func GetEntityDocuments(c *fiber.Ctx) error {
-	ctx := context.Background()
-	pool := db.Pool()
+	id, err := idParam(c)
+	if err != nil {
+		return err
+	}
 
-	id, err := strconv.Atoi(c.Params("id"))
+	role, err := enumQuery(c, "role", roles.Names())
 	if err != nil {
-		return c.Status(400).JSON(fiber.Map{"error": "invalid id"})
+		return err
 	}
 
-	limitStr := c.Query("limit", "50")
-	limit, _ := strconv.Atoi(limitStr)
+	sort, err := sortQuery(c, store.EntityDocumentSorts)
+	if err != nil {
+		return err
+	}
 
-	rows, err := pool.Query(ctx, `
-		SELECT d.id, d.doc_id, d.document_type, d.summary, d.date_earliest, d.date_latest
-		FROM documents d
-		JOIN document_entities de ON d.id = de.document_id
-		WHERE de.entity_id = $1
-		ORDER BY d.date_earliest DESC NULLS LAST
-		LIMIT $2
-	`, id, limit)
+	limit, err := limitQuery(c, 50, 200)
 	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+		return err
 	}
-	defer rows.Close()
 
-	var documents []fiber.Map
-	for rows.Next() {
-		var docID int
-		var docIdStr string
-		var docType, summary *string
-		var dateEarliest, dateLatest *string
+	documents, err := data.EntityDocuments(c.UserContext(), id, store.EntityDocumentFilter{Role: role, Sort: sort, Limit: limit})
+	if err != nil {
+		return err
+	}
 
-		if err := rows.Scan(&docID, &docIdStr, &docType, &summary, &dateEarliest, &dateLatest); err != nil {
-			continue
-		}
+	return c.JSON(EntityDocumentList{
+		Documents: documents,
+		Count:     len(documents),
+		Warnings:  skipped(c),
+	})
+}
+
+// GetEntityBio returns an entity's generated biography with its citations
+// and a notice that it was machine-written
+func GetEntityBio(c *fiber.Ctx) error {
+	id, err := idParam(c)
+	if err != nil {
+		return err
+	}
+
+	b, err := data.EntityBio(c.UserContext(), id)
+	if err != nil {
+		return notFound(err, "biography")
+	}
+
+	return c.JSON(EntityBio{
+		Bio: *b,
+		Provenance: Generation{
+			Generated:   true,
+			Notice:      bio.Notice,
+			Model:       b.Model,
+			GeneratedAt: b.GeneratedAt,
+		},
+	})
+}
+
+// QueueEntityBio queues generation of an entity's biography
+func QueueEntityBio(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+
+	id, err := idParam(c)
+	if err != nil {
+		return err
+	}
+	if _, err := data.GetEntity(ctx, id); err != nil {
+		return notFound(err, "entity")
+	}
+
+	jobID, err := jobs.NewQueue(db.Pool()).Enqueue(ctx, bio.JobKind, bio.Params{EntityID: id})
+	if err != nil {
+		return err
+	}
 
-		documents = append(documents, fiber.Map{
-			"id":           docID,
-			"docId":        docIdStr,
-			"documentType": docType,
-			"summary":      summary,
-			"dateEarliest": dateEarliest,
-			"dateLatest":   dateLatest,
-		})
+	audit.SetJob(c, jobID)
+
+	return c.Status(202).JSON(QueuedJob{
+		JobID:  jobID,
+		Status: jobs.StatusQueued,
+	})
+}
+
+// GetEntityNarrative returns an entity's generated chronology, one cited
+// sentence per event
+func GetEntityNarrative(c *fiber.Ctx) error {
+	id, err := idParam(c)
+	if err != nil {
+		return err
+	}
+
+	n, err := data.EntityNarrative(c.UserContext(), id)
+	if err != nil {
+		return notFound(err, "narrative")
+	}
+
+	return c.JSON(EntityNarrative{
+		Narrative: *n,
+		Provenance: Generation{
+			Generated:   true,
+			Notice:      narrative.Notice,
+			Model:       n.Model,
+			GeneratedAt: n.GeneratedAt,
+		},
+	})
+}
+
+// QueueEntityNarrative queues generation of an entity's chronology
+func QueueEntityNarrative(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+
+	id, err := idParam(c)
+	if err != nil {
+		return err
+	}
+	if _, err := data.GetEntity(ctx, id); err != nil {
+		return notFound(err, "entity")
+	}
+
+	jobID, err := jobs.NewQueue(db.Pool()).Enqueue(ctx, narrative.JobKind, narrative.Params{EntityID: id})
+	if err != nil {
+		return err
 	}
 
-	return c.JSON(fiber.Map{
-		"documents": documents,
-		"count":     len(documents),
+	audit.SetJob(c, jobID)
+
+	return c.Status(202).JSON(QueuedJob{
+		JobID:  jobID,
+		Status: jobs.StatusQueued,
 	})
 }
+
+// GetEntityTypes lists the entity types with how many entities have each
+func GetEntityTypes(c *fiber.Ctx) error {
+	types, err := data.EntityTypeCounts(c.UserContext())
+	if err != nil {
+		return err
+	}
+	return c.JSON(TypeList{Types: types, Count: len(types)})
+}
@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/subculture-collective/epstein-db/api/internal/apierr"
+	"github.com/subculture-collective/epstein-db/api/internal/models"
+)
+
+func entityIDs(list []models.EntitySummary) []int {
+	ids := make([]int, len(list))
+	for i, e := range list {
+		ids[i] = e.ID
+	}
+	return ids
+}
+
+func TestSearchEntities(t *testing.T) {
+	runCases(t, []handlerCase{
+		{name: "all, most mentioned first", target: "/api/entities", status: http.StatusOK,
+			check: func(t *testing.T, body []byte) {
+				list := decode[EntityList](t, body)
+				wantIDs(t, entityIDs(list.Entities), []int{2, 1, 3, 4, 5})
+				if list.Count != 5 {
+					t.Errorf("count %d, want 5", list.Count)
+				}
+			}},
+		{name: "limit", target: "/api/entities?limit=2", status: http.StatusOK,
+			check: func(t *testing.T, body []byte) {
+				wantIDs(t, entityIDs(decode[EntityList](t, body).Entities), []int{2, 1})
+			}},
+		{name: "by name", target: "/api/entities?q=Jane+Roe", status: http.StatusOK,
+			check: func(t *testing.T, body []byte) {
+				list := decode[EntityList](t, body).Entities
+				if len(list) == 0 || list[0].CanonicalName != "Jane Roe" {
+					t.Fatalf("entities %v, want Jane Roe first", list)
+				}
+			}},
+		{name: "by type", target: "/api/entities?type=organization", status: http.StatusOK,
+			check: func(t *testing.T, body []byte) {
+				wantIDs(t, entityIDs(decode[EntityList](t, body).Entities), []int{3})
+			}},
+		{name: "by layer", target: "/api/entities?layer=2", status: http.StatusOK,
+			check: func(t *testing.T, body []byte) {
+				wantIDs(t, entityIDs(decode[EntityList](t, body).Entities), []int{4})
+			}},
+		{name: "sorted by name", target: "/api/entities?sort=name", status: http.StatusOK,
+			check: func(t *testing.T, body []byte) {
+				wantIDs(t, entityIDs(decode[EntityList](t, body).Entities), []int{3, 2, 1, 4, 5})
+			}},
+		{name: "unknown type", target: "/api/entities?type=planet", status: http.StatusBadRequest, code: apierr.CodeInvalidParam},
+		{name: "unknown sort", target: "/api/entities?sort=bogus", status: http.StatusBadRequest, code: apierr.CodeInvalidParam},
+		{name: "zero limit", target: "/api/entities?limit=0", status: http.StatusBadRequest, code: apierr.CodeInvalidParam},
+	})
+}
+
+func TestGetEntity(t *testing.T) {
+	runCases(t, []handlerCase{
+		{name: "found", target: "/api/entities/2", status: http.StatusOK,
+			check: func(t *testing.T, body []byte) {
+				e := decode[models.Entity](t, body)
+				if e.ID != 2 || e.CanonicalName != "Jane Roe" || e.EntityType != "person" {
+					t.Fatalf("entity %+v, want Jane Roe", e)
+				}
+			}},
+		{name: "missing", target: "/api/entities/999", status: http.StatusNotFound, code: apierr.CodeNotFound},
+		{name: "malformed id", target: "/api/entities/abc", status: http.StatusBadRequest, code: apierr.CodeInvalidParam},
+	})
+}
+
+func TestGetEntityConnections(t *testing.T) {
+	connectionIDs := func(t *testing.T, body []byte) []int {
+		list := decode[ConnectionList](t, body)
+		ids := make([]int, len(list.Connections))
+		for i, c := range list.Connections {
+			ids[i] = c.ID
+		}
+		return ids
+	}
+	runCases(t, []handlerCase{
+		{name: "most shared documents first", target: "/api/entities/2/connections", status: http.StatusOK,
+			check: func(t *testing.T, body []byte) {
+				wantIDs(t, connectionIDs(t, body), []int{1, 3, 4, 5})
+			}},
+		{name: "limit", target: "/api/entities/2/connections?limit=1", status: http.StatusOK,
+			check: func(t *testing.T, body []byte) {
+				wantIDs(t, connectionIDs(t, body), []int{1})
+			}},
+		{name: "sorted by name", target: "/api/entities/2/connections?sort=name", status: http.StatusOK,
+			check: func(t *testing.T, body []byte) {
+				wantIDs(t, connectionIDs(t, body), []int{3, 1, 4, 5})
+			}},
+		{name: "no connections", target: "/api/entities/999/connections", status: http.StatusOK,
+			check: func(t *testing.T, body []byte) {
+				wantIDs(t, connectionIDs(t, body), []int{})
+			}},
+		{name: "unknown granularity", target: "/api/entities/2/connections?granularity=line", status: http.StatusBadRequest, code: apierr.CodeInvalidParam},
+	})
+}
+
+func TestGetEntityDocuments(t *testing.T) {
+	documentIDs := func(t *testing.T, body []byte) []int {
+		list := decode[EntityDocumentList](t, body)
+		ids := make([]int, len(list.Documents))
+		for i, d := range list.Documents {
+			ids[i] = d.ID
+		}
+		return ids
+	}
+	runCases(t, []handlerCase{
+		{name: "all", target: "/api/entities/3/documents?sort=docId", status: http.StatusOK,
+			check: func(t *testing.T, body []byte) {
+				wantIDs(t, documentIDs(t, body), []int{2, 3})
+			}},
+		{name: "limit", target: "/api/entities/2/documents?sort=docId&limit=2", status: http.StatusOK,
+			check: func(t *testing.T, body []byte) {
+				wantIDs(t, documentIDs(t, body), []int{1, 2})
+			}},
+		{name: "unknown sort", target: "/api/entities/2/documents?sort=bogus", status: http.StatusBadRequest, code: apierr.CodeInvalidParam},
+		{name: "malformed id", target: "/api/entities/0/documents", status: http.StatusBadRequest, code: apierr.CodeInvalidParam},
+	})
+}
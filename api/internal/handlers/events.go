@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"bufio"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/subculture-collective/epstein-db/api/internal/apierr"
+	"github.com/subculture-collective/epstein-db/api/internal/events"
+)
+
+// heartbeatInterval keeps idle streams from being closed by proxies
+const heartbeatInterval = 15 * time.Second
+
+// hub relays database events; cmd/server sets it with SetEvents
+var hub *events.Hub
+
+// SetEvents sets the hub StreamEvents subscribes to
+func SetEvents(h *events.Hub) {
+	hub = h
+}
+
+// StreamEvents streams live events as server-sent events. types narrows the
+// stream to a comma-separated list of event types or prefixes, e.g.
+// "job,pattern.created".
+func StreamEvents(c *fiber.Ctx) error {
+	if hub == nil {
+		return apierr.New(fiber.StatusServiceUnavailable, apierr.CodeUnavailable, "events unavailable")
+	}
+
+	var types []string
+	for _, t := range strings.Split(c.Query("types", ""), ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			types = append(types, t)
+		}
+	}
+
+	ch, unsubscribe := hub.Subscribe()
+
+	c.Set(fiber.HeaderContentType, "text/event-stream")
+	c.Set(fiber.HeaderCacheControl, "no-cache")
+	c.Set(fiber.HeaderConnection, "keep-alive")
+	// Stop nginx from buffering the stream
+	c.Set("X-Accel-Buffering", "no")
+
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer unsubscribe()
+
+		heartbeat := time.NewTicker(heartbeatInterval)
+		defer heartbeat.Stop()
+
+		fmt.Fprint(w, "retry: 5000\n\n")
+		if w.Flush() != nil {
+			return
+		}
+
+		for {
+			select {
+			case msg, ok := <-ch:
+				if !ok {
+					return
+				}
+				if !wantEvent(types, msg.Type) {
+					continue
+				}
+				fmt.Fprintf(w, "event: %s\ndata: %s\n\n", msg.Type, msg.Payload)
+			case <-heartbeat.C:
+				fmt.Fprint(w, ": heartbeat\n\n")
+			}
+			// A failed flush means the client has gone
+			if w.Flush() != nil {
+				return
+			}
+		}
+	})
+
+	return nil
+}
+
+// wantEvent reports whether eventType matches one of types, exactly or as
+// a dotted prefix. No types means every event.
+func wantEvent(types []string, eventType string) bool {
+	if len(types) == 0 {
+		return true
+	}
+	for _, t := range types {
+		if eventType == t || strings.HasPrefix(eventType, t+".") {
+			return true
+		}
+	}
+	return false
+}
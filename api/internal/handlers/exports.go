@@ -0,0 +1,191 @@
+package handlers
+
+import (
+	"errors"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/subculture-collective/epstein-db/api/internal/apierr"
+	"github.com/subculture-collective/epstein-db/api/internal/auth"
+	"github.com/subculture-collective/epstein-db/api/internal/blobs"
+	"github.com/subculture-collective/epstein-db/api/internal/db"
+	"github.com/subculture-collective/epstein-db/api/internal/exports"
+	"github.com/subculture-collective/epstein-db/api/internal/jobs"
+	"github.com/subculture-collective/epstein-db/api/internal/signedurl"
+	"github.com/subculture-collective/epstein-db/api/internal/store"
+)
+
+// signer signs download links; cmd/server sets it with SetSigner
+var signer *signedurl.Signer
+
+// SetSigner sets the signer of download links
+func SetSigner(s *signedurl.Signer) {
+	signer = s
+}
+
+// exportDir is where exports are written and served from
+func exportDir() string {
+	if settings == nil {
+		return "exports"
+	}
+	return settings.Exports.Dir
+}
+
+// ExportBody is the body of POST /api/exports
+type ExportBody struct {
+	Set              string `json:"set" enum:"documents,entities,triples"`
+	Format           string `json:"format,omitempty" enum:"ndjson,csv" doc:"Defaults to ndjson"`
+	DatasetID        int    `json:"datasetId,omitempty" doc:"Only documents of this dataset, entities they mention and triples from them"`
+	EntityType       string `json:"entityType,omitempty" doc:"Only entities of this type, documents mentioning one, and triples with one as subject or object"`
+	IncludeText      bool   `json:"includeText,omitempty" doc:"documents only: include full text"`
+	ExcludeSensitive bool   `json:"excludeSensitive,omitempty" doc:"Leave out documents with content warnings, and triples from them"`
+}
+
+// ExportStatus is an export job as its requester sees it
+type ExportStatus struct {
+	ID           int64           `json:"id"`
+	Status       string          `json:"status" enum:"queued,running,completed,failed"`
+	Params       exports.Params  `json:"params"`
+	Progress     int             `json:"progress" doc:"Rows written so far"`
+	Total        *int            `json:"total" doc:"Rows the export will hold, once known"`
+	Result       *exports.Result `json:"result,omitempty"`
+	ErrorMessage *string         `json:"errorMessage,omitempty"`
+	CreatedAt    time.Time       `json:"createdAt"`
+	FinishedAt   *time.Time      `json:"finishedAt"`
+	DownloadURL  string          `json:"downloadUrl,omitempty" doc:"Signed link to the file, needing no API key, once completed"`
+	ExpiresAt    *time.Time      `json:"expiresAt,omitempty" doc:"When downloadUrl stops working; fetch the export again for a fresh one"`
+}
+
+// CreateExport queues an export of every document, entity or triple
+// matching the filters
+func CreateExport(c *fiber.Ctx) error {
+	var body ExportBody
+	if err := c.BodyParser(&body); err != nil {
+		return apierr.BadRequest("invalid body")
+	}
+	if !slices.Contains(exports.Sets, body.Set) {
+		return apierr.InvalidParam("set", "must be one of "+strings.Join(exports.Sets, ", "))
+	}
+	if body.Format == "" {
+		body.Format = exports.FormatNDJSON
+	}
+	if !slices.Contains(exports.Formats, body.Format) {
+		return apierr.InvalidParam("format", "must be one of "+strings.Join(exports.Formats, ", "))
+	}
+	if body.DatasetID < 0 {
+		return apierr.InvalidParam("datasetId", "must be a positive integer")
+	}
+	if body.EntityType != "" && !slices.Contains(store.EntityTypes, body.EntityType) {
+		return apierr.InvalidParam("entityType", "must be one of "+strings.Join(store.EntityTypes, ", "))
+	}
+	if body.IncludeText && body.Set != exports.SetDocuments {
+		return apierr.InvalidParam("includeText", "is for document exports only")
+	}
+
+	params := exports.Params{
+		Set:              body.Set,
+		Format:           body.Format,
+		DatasetID:        body.DatasetID,
+		EntityType:       body.EntityType,
+		IncludeText:      body.IncludeText,
+		ExcludeSensitive: body.ExcludeSensitive,
+		KeyID:            auth.FromContext(c).KeyID,
+	}
+	id, err := jobs.NewQueue(db.Pool()).Enqueue(c.UserContext(), exports.JobKind, params)
+	if err != nil {
+		return err
+	}
+
+	c.Location("/api/exports/" + strconv.FormatInt(id, 10))
+	return c.Status(fiber.StatusAccepted).JSON(ExportStatus{
+		ID:        id,
+		Status:    jobs.StatusQueued,
+		Params:    params,
+		CreatedAt: time.Now().UTC(),
+	})
+}
+
+// GetExport returns an export's progress and, once it has completed, a
+// signed link to download it. Only the key that requested an export, or an
+// admin, can see it.
+func GetExport(c *fiber.Ctx) error {
+	id, err := idParam(c)
+	if err != nil {
+		return err
+	}
+
+	job, err := jobs.NewQueue(db.Pool()).Get(c.UserContext(), int64(id))
+	if err != nil {
+		return notFound(err, "export")
+	}
+	var params exports.Params
+	if err := job.DecodeParams(&params); err != nil {
+		return err
+	}
+	p := auth.FromContext(c)
+	if job.Kind != exports.JobKind || (params.KeyID != p.KeyID && p.Role < auth.RoleAdmin) {
+		return apierr.NotFound("export")
+	}
+
+	status := ExportStatus{
+		ID:           job.ID,
+		Status:       job.Status,
+		Params:       params,
+		Progress:     job.Progress,
+		Total:        job.Total,
+		ErrorMessage: job.ErrorMessage,
+		CreatedAt:    job.CreatedAt,
+		FinishedAt:   job.FinishedAt,
+	}
+	if job.Status == jobs.StatusCompleted {
+		if err := job.DecodeResult(&status.Result); err != nil {
+			return err
+		}
+		link, expires := signer.Sign("/api/exports/"+strconv.Itoa(id)+"/download", time.Now())
+		status.DownloadURL = publicURL(c) + link
+		status.ExpiresAt = &expires
+	}
+
+	// The link in it is a credential of sorts
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	return c.JSON(status)
+}
+
+// DownloadExport sends an export's file to whoever holds a signed link to it
+func DownloadExport(c *fiber.Ctx) error {
+	id, err := idParam(c)
+	if err != nil {
+		return err
+	}
+	err = signer.Verify(c.Path(), c.Query("expires"), c.Query("signature"), time.Now())
+	if errors.Is(err, signedurl.ErrExpired) {
+		return apierr.Forbidden("this download link has expired; fetch the export again for a new one")
+	}
+	if err != nil {
+		return apierr.Forbidden("invalid download link")
+	}
+
+	job, err := jobs.NewQueue(db.Pool()).Get(c.UserContext(), int64(id))
+	if err != nil || job.Kind != exports.JobKind || job.Status != jobs.StatusCompleted {
+		return apierr.NotFound("export")
+	}
+	var result exports.Result
+	if err := job.DecodeResult(&result); err != nil {
+		return err
+	}
+	// Path fails for a file cleaned up here, which may still be in the
+	// bucket if another machine's worker wrote it
+	path, _ := exports.Path(exportDir(), result.File)
+
+	c.Set("Digest", "sha-256="+result.SHA256)
+	c.Set(fiber.HeaderCacheControl, "private, no-store")
+	err = files.Send(c, blobs.ExportKey(result.File), path, result.File)
+	if errors.Is(err, blobs.ErrNotFound) {
+		return apierr.Gone("this export has been deleted", "request a new export with POST /api/exports")
+	}
+	return err
+}
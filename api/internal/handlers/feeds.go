@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"bytes"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/subculture-collective/epstein-db/api/internal/db"
+	"github.com/subculture-collective/epstein-db/api/internal/feeds"
+)
+
+// DocumentsFeed returns the Atom feed of newly added documents
+func DocumentsFeed(c *fiber.Ctx) error {
+	dataset, err := intQuery(c, "dataset", 0)
+	if err != nil {
+		return err
+	}
+
+	feed, err := feeds.Documents(c.UserContext(), db.ReadPool(), publicURL(c), dataset)
+	if err != nil {
+		return err
+	}
+	return sendFeed(c, feed)
+}
+
+// PatternsFeed returns the Atom feed of validated pattern findings
+func PatternsFeed(c *fiber.Ctx) error {
+	feed, err := feeds.Patterns(c.UserContext(), db.ReadPool(), publicURL(c))
+	if err != nil {
+		return err
+	}
+	return sendFeed(c, feed)
+}
+
+// sendFeed sends a feed, or 304 if the reader already has its latest entry
+func sendFeed(c *fiber.Ctx, feed *feeds.Feed) error {
+	if notModified(c, c.OriginalURL()+"#"+strconv.Itoa(len(feed.Entries)), feed.Updated) {
+		return c.SendStatus(fiber.StatusNotModified)
+	}
+
+	var buf bytes.Buffer
+	if err := feed.Write(&buf); err != nil {
+		return err
+	}
+	c.Set(fiber.HeaderContentType, feeds.MediaAtom+"; charset=utf-8")
+	return c.Send(buf.Bytes())
+}
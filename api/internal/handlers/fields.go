@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"encoding/json"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// jsonFields lists the JSON names of the fields of struct v
+func jsonFields(v any) []string {
+	t := reflect.TypeOf(v)
+	var names []string
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name != "" && name != "-" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// sendFields responds with body, keeping only fields in each item of its
+// list property. Projection happens on the encoded JSON, so it costs a
+// second encoding pass, but only when fields were requested.
+func sendFields(c *fiber.Ctx, body any, list string, fields []string) error {
+	if len(fields) == 0 {
+		return c.JSON(body)
+	}
+
+	raw, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	var envelope map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &envelope); err != nil {
+		return err
+	}
+
+	var items []map[string]json.RawMessage
+	if err := json.Unmarshal(envelope[list], &items); err != nil {
+		return err
+	}
+	for _, item := range items {
+		for k := range item {
+			if !slices.Contains(fields, k) {
+				delete(item, k)
+			}
+		}
+	}
+	if envelope[list], err = json.Marshal(items); err != nil {
+		return err
+	}
+
+	return c.JSON(envelope)
+}
@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/subculture-collective/epstein-db/api/internal/apierr"
+	"github.com/subculture-collective/epstein-db/api/internal/blobs"
+	"github.com/subculture-collective/epstein-db/api/internal/store"
+)
+
+// files sends documents' files, thumbnails and exports, from the bucket
+// when there is one; cmd/server sets it with SetBlobs
+var files *blobs.Store
+
+// SetBlobs sets where files are sent from
+func SetBlobs(s *blobs.Store) {
+	files = s
+}
+
+// GetDocumentFile sends a document's original PDF or scan, or redirects to
+// a short-lived link to it in object storage
+func GetDocumentFile(c *fiber.Ctx) error {
+	id, err := idParam(c)
+	if err != nil {
+		return err
+	}
+
+	path, err := data.DocumentFile(c.UserContext(), id)
+	if errors.Is(err, store.ErrProtected) {
+		return apierr.Forbidden("this document names a protected person and its original can't be redacted; read its text instead")
+	}
+	if err != nil {
+		return restricted(err, "document")
+	}
+	if path == "" {
+		return apierr.NotFound("file")
+	}
+
+	err = files.Send(c, blobs.DocumentKey(id, path), path, "")
+	if errors.Is(err, blobs.ErrNotFound) {
+		return apierr.NotFound("file")
+	}
+	return err
+}
@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/subculture-collective/epstein-db/api/internal/apierr"
+	"github.com/subculture-collective/epstein-db/api/internal/store"
+)
+
+// Cost guards for /api/search and /api/network. Before running an expensive
+// query the handler asks the planner what it would cost and turns the
+// request away with a 422 and a hint when the estimate is over the
+// configured cap, rather than let one request saturate the database.
+
+// maxNetworkNodes is the largest network that may include entities with a
+// single connection; those make the edge join cover most of the mentions
+const maxNetworkNodes = 1000
+
+// checkPlan rejects plan if its cost is over the configured cap
+func checkPlan(plan store.Plan, hint string) error {
+	if settings == nil || settings.Guard.MaxCost == 0 || plan.Cost <= float64(settings.Guard.MaxCost) {
+		return nil
+	}
+	err := apierr.TooExpensive("query would be too expensive to run", hint)
+	err.Details = fiber.Map{
+		"hint":          hint,
+		"estimatedCost": plan.Cost,
+		"maxCost":       settings.Guard.MaxCost,
+		"seqScans":      plan.SeqScans,
+	}
+	return err
+}
+
+// guardSearch rejects full-text queries without searchable terms, which
+// can't use the text index and scan every document, and ones the planner
+// expects to be expensive
+func guardSearch(c *fiber.Ctx, f store.SearchFilter) error {
+	terms, err := data.SearchTerms(c.UserContext(), f.Query)
+	if err != nil {
+		return err
+	}
+	if terms == 0 {
+		return apierr.TooExpensive("q has no searchable terms",
+			"q is only stop words or punctuation; search for names or other distinctive words")
+	}
+
+	plan, err := data.SearchTextPlan(c.UserContext(), f)
+	if err != nil {
+		return err
+	}
+	return checkPlan(plan, "add more specific terms or lower limit")
+}
+
+// guardNgramSearch rejects n-gram queries too short to have a trigram,
+// which would match nothing, and ones the planner expects to be expensive
+func guardNgramSearch(c *fiber.Ctx, f store.SearchFilter) error {
+	if store.Trigrams(f.Query) == 0 {
+		return apierr.InvalidParam("q", "must contain letters or digits")
+	}
+
+	plan, err := data.SearchNgramsPlan(c.UserContext(), f)
+	if err != nil {
+		return err
+	}
+	return checkPlan(plan, "search for a longer phrase or lower limit")
+}
+
+// guardNetwork rejects large networks of weakly connected entities and
+// ones the planner expects to be expensive
+func guardNetwork(c *fiber.Ctx, f store.NetworkFilter) error {
+	if f.MinConnections < 2 && f.Limit > maxNetworkNodes {
+		return apierr.TooExpensive("network too large for minConnections below 2",
+			"raise minConnections to 2 or more, or lower limit to "+strconv.Itoa(maxNetworkNodes)+" or less")
+	}
+
+	plan, err := data.NetworkPlan(c.UserContext(), f)
+	if err != nil {
+		return err
+	}
+	return checkPlan(plan, "raise minConnections or lower limit")
+}
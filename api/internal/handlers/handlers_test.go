@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/subculture-collective/epstein-db/api/internal/apierr"
+	"github.com/subculture-collective/epstein-db/api/internal/auth"
+	"github.com/subculture-collective/epstein-db/api/internal/store"
+	"github.com/subculture-collective/epstein-db/api/internal/testdb"
+)
+
+// The handler tests run against testdb's fixtures: five entities named
+// across three documents of dataset 1, and one pattern, plus a document of
+// restricted dataset 3, a protected entity and a soft-deleted document.
+// Without a database (TEST_DATABASE_URL or docker) they are skipped.
+
+// testKey is the fixtures' researcher API key
+const testKey = "test-researcher-key"
+
+var (
+	testApp   *fiber.App
+	testDBErr error
+)
+
+func TestMain(m *testing.M) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	db, err := testdb.Start(ctx)
+	cancel()
+	if err != nil {
+		testDBErr = err
+		os.Exit(m.Run())
+	}
+
+	SetStore(store.New(db.Pool, db.Pool))
+	testApp = newTestApp(auth.New(db.Pool))
+	code := m.Run()
+	db.Close()
+	os.Exit(code)
+}
+
+// newTestApp serves the read routes under test with the middleware the
+// server puts in front of them
+func newTestApp(authenticator *auth.Authenticator) *fiber.App {
+	app := fiber.New(fiber.Config{ErrorHandler: apierr.Handler})
+	app.Use(authenticator.Middleware())
+	app.Use(CollectWarnings)
+	app.Use(ConfidenceFilter)
+	app.Use(SensitivityFilter)
+	app.Use(RestrictedAccess)
+
+	api := app.Group("/api")
+	api.Get("/stats", GetStats)
+	api.Get("/search", FullTextSearch)
+	api.Get("/entities", SearchEntities)
+	api.Get("/entities/:id", GetEntity)
+	api.Get("/entities/:id/connections", GetEntityConnections)
+	api.Get("/entities/:id/documents", GetEntityDocuments)
+	api.Get("/documents", ListDocuments)
+	api.Get("/documents/:id", GetDocument)
+	api.Get("/documents/:id/text", GetDocumentText)
+	api.Get("/documents/:id/entities", GetDocumentEntities)
+	api.Get("/network", GetNetwork)
+	api.Get("/triples", SearchTriples)
+	api.Get("/crossref/ppp", SearchPPP)
+	api.Get("/patterns", ListPatterns)
+	api.Get("/patterns/:id", GetPattern)
+	return app
+}
+
+// handlerCase is a GET request, anonymous or with an API key, and the
+// response it should get: a status, and either the error code or, on
+// success, a check of the body
+type handlerCase struct {
+	name   string
+	target string
+	key    string
+	status int
+	code   string
+	check  func(t *testing.T, body []byte)
+}
+
+func runCases(t *testing.T, cases []handlerCase) {
+	t.Helper()
+	if testApp == nil {
+		t.Skipf("no test database: %v", testDBErr)
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tc.target, nil)
+			if tc.key != "" {
+				req.Header.Set("X-API-Key", tc.key)
+			}
+			resp, err := testApp.Test(req, -1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			body, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tc.status {
+				t.Fatalf("GET %s: status %d, want %d: %s", tc.target, resp.StatusCode, tc.status, body)
+			}
+			if tc.code != "" {
+				e := decode[apierr.ErrorResponse](t, body)
+				if e.Error == nil || e.Error.Code != tc.code {
+					t.Fatalf("GET %s: error %s, want code %s", tc.target, body, tc.code)
+				}
+				return
+			}
+			if tc.check != nil {
+				tc.check(t, body)
+			}
+		})
+	}
+}
+
+func decode[T any](t *testing.T, body []byte) T {
+	t.Helper()
+	var v T
+	if err := json.Unmarshal(body, &v); err != nil {
+		t.Fatalf("decode %T: %v: %s", v, err, body)
+	}
+	return v
+}
+
+// wantIDs fails unless got lists exactly want, in order
+func wantIDs(t *testing.T, got, want []int) {
+	t.Helper()
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("ids %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/subculture-collective/epstein-db/api/internal/db"
+	"github.com/subculture-collective/epstein-db/api/internal/health"
+	"github.com/subculture-collective/epstein-db/api/internal/migrations"
+)
+
+// readiness are the dependency checks behind Ready; cmd/server sets them
+// with SetHealthChecks
+var readiness []health.Checker
+
+// SetHealthChecks sets the dependency checks Ready runs
+func SetHealthChecks(checks []health.Checker) {
+	readiness = checks
+}
+
+// Live reports that the process is up, without touching dependencies, so
+// orchestrators only restart it when it is truly stuck
+func Live(c *fiber.Ctx) error {
+	return c.JSON(HealthStatus{Status: health.StatusOK})
+}
+
+// Ready reports whether the server can serve traffic: each dependency's
+// status, the schema version and connection pool usage. It answers 503
+// when a required dependency is down.
+func Ready(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+
+	ready, checks := health.Run(ctx, readiness)
+
+	status := HealthStatus{
+		Status:          health.StatusOK,
+		ExpectedVersion: migrations.Latest(),
+		Checks:          checks,
+		Pools:           []PoolStats{poolStats("primary", db.Pool())},
+	}
+	if replica := db.Replica(); replica != nil {
+		status.Pools = append(status.Pools, poolStats("replica", replica))
+	}
+
+	version, err := migrations.Current(ctx, db.Pool())
+	if err != nil {
+		ready = false
+		status.Error = "database unavailable"
+	}
+	status.SchemaVersion = version
+
+	for _, check := range checks {
+		if check.Status == health.StatusDegraded {
+			status.Status = health.StatusDegraded
+		}
+	}
+	if !ready {
+		status.Status = health.StatusError
+		return c.Status(fiber.StatusServiceUnavailable).JSON(status)
+	}
+	return c.JSON(status)
+}
+
+func poolStats(name string, pool *pgxpool.Pool) PoolStats {
+	s := pool.Stat()
+	p := PoolStats{
+		Name:     name,
+		Acquired: s.AcquiredConns(),
+		Idle:     s.IdleConns(),
+		Total:    s.TotalConns(),
+		Max:      s.MaxConns(),
+	}
+	if p.Max > 0 {
+		p.Utilization = float64(p.Acquired) / float64(p.Max)
+	}
+	return p
+}
@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/subculture-collective/epstein-db/api/internal/db"
+	"github.com/subculture-collective/epstein-db/api/internal/jobs"
+	"github.com/subculture-collective/epstein-db/api/internal/llm"
+)
+
+// ListJobs returns recent background jobs
+func ListJobs(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+
+	limit, err := limitQuery(c, 50, 200)
+	if err != nil {
+		return err
+	}
+
+	status, err := enumQuery(c, "status", jobStatuses)
+	if err != nil {
+		return err
+	}
+
+	list, err := jobs.NewQueue(db.Pool()).List(ctx, c.Query("kind", ""), status, limit)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(JobList{
+		Jobs:  list,
+		Count: len(list),
+	})
+}
+
+// GetJob returns a single job with its progress
+func GetJob(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+
+	id, err := idParam(c)
+	if err != nil {
+		return err
+	}
+
+	job, err := jobs.NewQueue(db.Pool()).Get(ctx, int64(id))
+	if err != nil {
+		return notFound(err, "job")
+	}
+
+	return c.JSON(job)
+}
+
+// GetJobUsage returns the model tokens a job used and their estimated cost
+func GetJobUsage(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+
+	id, err := idParam(c)
+	if err != nil {
+		return err
+	}
+
+	if _, err := jobs.NewQueue(db.Pool()).Get(ctx, int64(id)); err != nil {
+		return notFound(err, "job")
+	}
+
+	totals, err := llm.JobUsage(ctx, db.Pool(), int64(id))
+	if err != nil {
+		return err
+	}
+
+	usage := JobUsage{JobID: int64(id), Usage: totals}
+	for _, t := range totals {
+		usage.InputTokens += t.InputTokens
+		usage.OutputTokens += t.OutputTokens
+		if t.CostUSD != nil {
+			usage.CostUSD += *t.CostUSD
+		}
+	}
+	return c.JSON(usage)
+}
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"bufio"
+	"log"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/subculture-collective/epstein-db/api/internal/db"
+	"github.com/subculture-collective/epstein-db/api/internal/linkeddata"
+	"github.com/subculture-collective/epstein-db/api/internal/models"
+	"github.com/subculture-collective/epstein-db/api/internal/store"
+)
+
+// relationLimit caps the triples read for an entity's JSON-LD document
+const relationLimit = 1000
+
+// publicURL is the base of linked-data IRIs: PUBLIC_URL, so that IRIs stay
+// the same behind any proxy, or else the request's own
+func publicURL(c *fiber.Ctx) string {
+	if settings != nil && settings.PublicURL != "" {
+		return settings.PublicURL
+	}
+	return c.BaseURL()
+}
+
+// entityFormat picks JSON or JSON-LD: ?format= if given, otherwise JSON-LD
+// when the Accept header prefers it
+func entityFormat(c *fiber.Ctx) (string, error) {
+	format, err := enumQuery(c, "format", entityFormats)
+	if err != nil || format != "" {
+		return format, err
+	}
+	if c.Accepts(fiber.MIMEApplicationJSON, linkeddata.MediaJSONLD) == linkeddata.MediaJSONLD {
+		return "jsonld", nil
+	}
+	return "json", nil
+}
+
+// sendEntityJSONLD responds with entity as JSON-LD, with its relationships
+func sendEntityJSONLD(c *fiber.Ctx, entity *models.Entity) error {
+	triples, err := data.SearchTriples(c.UserContext(), store.TripleFilter{
+		EntityID: entity.ID,
+		Limit:    relationLimit,
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := c.JSON(linkeddata.Entity(publicURL(c), entity, triples)); err != nil {
+		return err
+	}
+	c.Set(fiber.HeaderContentType, linkeddata.MediaJSONLD)
+	return nil
+}
+
+// ExportRDF streams the whole entity graph as N-Triples to a researcher
+func ExportRDF(c *fiber.Ctx) error {
+	base := publicURL(c)
+
+	c.Set(fiber.HeaderContentType, linkeddata.MediaNTriples)
+	c.Set(fiber.HeaderContentDisposition, `attachment; filename="epstein-db.nt"`)
+
+	// The body is written after the handler returns, once the request's
+	// timeout has been cancelled, so the dump runs on the connection's own
+	// context, done when the server shuts down. A client that goes away is
+	// noticed by the dump failing to write.
+	ctx := c.Context()
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		if err := linkeddata.Dump(ctx, db.ReadPool(), w, base); err != nil {
+			log.Printf("rdf export: %v", err)
+		}
+	})
+	return nil
+}
@@ -0,0 +1,209 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/subculture-collective/epstein-db/api/internal/apierr"
+	"github.com/subculture-collective/epstein-db/api/internal/auth"
+	"github.com/subculture-collective/epstein-db/api/internal/blobs"
+	"github.com/subculture-collective/epstein-db/api/internal/db"
+	"github.com/subculture-collective/epstein-db/api/internal/media"
+	"github.com/subculture-collective/epstein-db/api/internal/thumbnails"
+	"github.com/subculture-collective/epstein-db/api/internal/upload"
+)
+
+// MediaBody is the body of PUT /api/admin/media/:id
+type MediaBody struct {
+	SourceURL   string `json:"sourceUrl,omitempty"`
+	License     string `json:"license,omitempty"`
+	Attribution string `json:"attribution,omitempty"`
+	Caption     string `json:"caption,omitempty"`
+	Primary     bool   `json:"primary,omitempty" doc:"Make this the entity's primary image"`
+}
+
+// MediaList is the images of an entity
+type MediaList struct {
+	Media []media.Media `json:"media"`
+	Count int           `json:"count"`
+}
+
+func mediaDir() string {
+	if settings == nil {
+		return "media"
+	}
+	return settings.Media.Dir
+}
+
+// validMeta checks the description of an image
+func validMeta(m media.Meta) error {
+	if m.SourceURL != "" && !strings.HasPrefix(m.SourceURL, "https://") && !strings.HasPrefix(m.SourceURL, "http://") {
+		return apierr.InvalidParam("sourceUrl", "must be an http or https URL")
+	}
+	return nil
+}
+
+// ListEntityMedia returns the images attached to an entity
+func ListEntityMedia(c *fiber.Ctx) error {
+	id, err := idParam(c)
+	if err != nil {
+		return err
+	}
+
+	list, err := media.List(c.UserContext(), db.Pool(), id)
+	if err != nil {
+		return err
+	}
+	return c.JSON(MediaList{Media: list, Count: len(list)})
+}
+
+// ServeMedia sends an image, resized to the requested width
+func ServeMedia(c *fiber.Ctx) error {
+	id, err := idParam(c)
+	if err != nil {
+		return err
+	}
+	width, err := intQuery(c, "width", 0)
+	if err != nil {
+		return err
+	}
+	if width != 0 && !slices.Contains(media.Widths, width) {
+		return apierr.InvalidParam("width", "must be one of 64, 128, 256, 512 or 1024")
+	}
+
+	m, err := media.Get(c.UserContext(), db.Pool(), id)
+	if err != nil {
+		return notFound(err, "image")
+	}
+	path, err := media.Open(mediaDir(), m, width)
+	if err != nil {
+		return err
+	}
+
+	// Files never change; a new image gets a new ID
+	c.Set(fiber.HeaderCacheControl, "public, max-age=86400")
+	c.Set(fiber.HeaderETag, `"`+m.SHA256[:16]+"-"+strconv.Itoa(width)+`"`)
+	return c.SendFile(path)
+}
+
+// AttachMedia uploads an image and attaches it to an entity
+func AttachMedia(c *fiber.Ctx) error {
+	id, err := idParam(c)
+	if err != nil {
+		return err
+	}
+	primary, err := strconv.ParseBool(c.FormValue("primary", "false"))
+	if err != nil {
+		return apierr.InvalidParam("primary", "must be true or false")
+	}
+	meta := media.Meta{
+		SourceURL:   strings.TrimSpace(c.FormValue("sourceUrl")),
+		License:     strings.TrimSpace(c.FormValue("license")),
+		Attribution: strings.TrimSpace(c.FormValue("attribution")),
+		Caption:     strings.TrimSpace(c.FormValue("caption")),
+		Primary:     primary,
+	}
+	if err := validMeta(meta); err != nil {
+		return err
+	}
+
+	header, _, err := upload.File(c, "file", media.MaxBytes, media.Types...)
+	if err != nil {
+		return err
+	}
+	file, err := header.Open()
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	data, err := io.ReadAll(io.LimitReader(file, media.MaxBytes))
+	if err != nil {
+		return err
+	}
+
+	m, err := media.Add(c.UserContext(), db.Pool(), mediaDir(), id, auth.FromContext(c).KeyID, data, meta)
+	switch {
+	case errors.Is(err, media.ErrUnsupported):
+		return apierr.New(fiber.StatusUnsupportedMediaType, apierr.CodeUnsupported, "file must be a JPEG, PNG or GIF image")
+	case errors.Is(err, media.ErrTooLarge):
+		return apierr.InvalidParam("file", "must be at most 40 megapixels")
+	case err != nil:
+		return notFound(err, "entity")
+	}
+	return c.Status(201).JSON(m)
+}
+
+// UpdateMedia changes an image's source, license, caption or primary flag
+func UpdateMedia(c *fiber.Ctx) error {
+	id, err := idParam(c)
+	if err != nil {
+		return err
+	}
+	var body MediaBody
+	if err := c.BodyParser(&body); err != nil {
+		return apierr.BadRequest("invalid body")
+	}
+	meta := media.Meta{
+		SourceURL:   strings.TrimSpace(body.SourceURL),
+		License:     strings.TrimSpace(body.License),
+		Attribution: strings.TrimSpace(body.Attribution),
+		Caption:     strings.TrimSpace(body.Caption),
+		Primary:     body.Primary,
+	}
+	if err := validMeta(meta); err != nil {
+		return err
+	}
+
+	m, err := media.Update(c.UserContext(), db.Pool(), id, meta)
+	if err != nil {
+		return notFound(err, "image")
+	}
+	return c.JSON(m)
+}
+
+// DeleteMedia detaches an image from its entity
+func DeleteMedia(c *fiber.Ctx) error {
+	id, err := idParam(c)
+	if err != nil {
+		return err
+	}
+
+	if err := media.Delete(c.UserContext(), db.Pool(), mediaDir(), id); err != nil {
+		return notFound(err, "image")
+	}
+	return c.SendStatus(204)
+}
+
+// GetPageThumbnail sends the thumbnail of a document page
+func GetPageThumbnail(c *fiber.Ctx) error {
+	id, err := idParam(c)
+	if err != nil {
+		return err
+	}
+	page, err := strconv.Atoi(c.Params("n"))
+	if err != nil || page <= 0 {
+		return apierr.InvalidParam("n", "must be a positive integer")
+	}
+	size, err := enumQuery(c, "size", thumbnails.Sizes)
+	if err != nil {
+		return err
+	}
+	if size == "" {
+		size = thumbnails.SizeMedium
+	}
+	if err := data.DocumentReadable(c.UserContext(), id); err != nil {
+		return restricted(err, "document")
+	}
+
+	c.Set(fiber.HeaderCacheControl, "public, max-age=86400")
+	err = files.Send(c, blobs.ThumbnailKey(id, page, size), thumbnails.Path(mediaDir(), id, page, size), "")
+	if errors.Is(err, blobs.ErrNotFound) {
+		return apierr.NotFound("thumbnail")
+	}
+	return err
+}
@@ -1,282 +1,292 @@
 package handlers
 
 import (
-	"context"
+	"bytes"
+	"slices"
 	"strconv"
+	"strings"
 
 	"github.com/gofiber/fiber/v2"
-	"github.com/subculture-collective/epstein-db/api/internal/db"
+	"github.com/subculture-collective/epstein-db/api/internal/apierr"
+	"github.com/subculture-collective/epstein-db/api/internal/egonet"
+	"github.com/subculture-collective/epstein-db/api/internal/store"
 )
 
+// maxMatrixEntities bounds a co-occurrence matrix's rows
+const maxMatrixEntities = 200
+
+// MatrixBody is the body of POST /api/network/matrix
+type MatrixBody struct {
+	EntityIDs []int `json:"entityIds" doc:"2 to 200 entity IDs, in the order of the rows"`
+}
+
 // GetNetwork returns the relationship network for visualization
 func GetNetwork(c *fiber.Ctx) error {
-	ctx := context.Background()
-	pool := db.Pool()
-
-	limitStr := c.Query("limit", "1000")
-	limit, _ := strconv.Atoi(limitStr)
-	if limit > 10000 {
-		limit = 10000
-	}
-
-	minConnections := c.Query("minConnections", "2")
-	minConn, _ := strconv.Atoi(minConnections)
-
-	// Get nodes (entities with sufficient connections)
-	nodeRows, err := pool.Query(ctx, `
-		SELECT id, canonical_name, entity_type, layer, document_count, connection_count
-		FROM entities
-		WHERE entity_type IN ('person', 'organization')
-		  AND connection_count >= $1
-		ORDER BY connection_count DESC
-		LIMIT $2
-	`, minConn, limit)
+	ctx := c.UserContext()
+
+	limit, err := limitQuery(c, 1000, 10000)
 	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+		return err
 	}
-	defer nodeRows.Close()
 
-	var nodes []fiber.Map
-	nodeIDs := make(map[int]bool)
-	
-	for nodeRows.Next() {
-		var id int
-		var name, etype string
-		var layer, docCount, connCount *int
+	minConn, err := intQuery(c, "minConnections", 2)
+	if err != nil {
+		return err
+	}
 
-		if err := nodeRows.Scan(&id, &name, &etype, &layer, &docCount, &connCount); err != nil {
-			continue
+	minScore := 0.0
+	if v := c.Query("minScore"); v != "" {
+		minScore, err = strconv.ParseFloat(v, 64)
+		if err != nil || minScore < 0 {
+			return apierr.InvalidParam("minScore", "must be a non-negative number")
 		}
+	}
 
-		nodeIDs[id] = true
-		nodes = append(nodes, fiber.Map{
-			"id":              id,
-			"canonicalName":   name,
-			"entityType":      etype,
-			"layer":           layer,
-			"documentCount":   docCount,
-			"connectionCount": connCount,
-		})
+	granularity, err := enumQuery(c, "granularity", store.Granularities)
+	if err != nil {
+		return err
 	}
 
-	// Get edges (co-occurrence relationships)
-	edgeRows, err := pool.Query(ctx, `
-		SELECT 
-			de1.entity_id AS source,
-			de2.entity_id AS target,
-			COUNT(DISTINCT de1.document_id) AS weight
-		FROM document_entities de1
-		JOIN document_entities de2 ON de1.document_id = de2.document_id 
-			AND de1.entity_id < de2.entity_id
-		JOIN entities e1 ON de1.entity_id = e1.id
-		JOIN entities e2 ON de2.entity_id = e2.id
-		WHERE e1.entity_type IN ('person', 'organization')
-		  AND e2.entity_type IN ('person', 'organization')
-		  AND e1.connection_count >= $1
-		  AND e2.connection_count >= $1
-		GROUP BY de1.entity_id, de2.entity_id
-		HAVING COUNT(DISTINCT de1.document_id) >= 2
-		ORDER BY weight DESC
-		LIMIT $2
-	`, minConn, limit*3)
+	sort, err := sortQuery(c, store.EdgeSorts)
 	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+		return err
 	}
-	defer edgeRows.Close()
 
-	var edges []fiber.Map
-	for edgeRows.Next() {
-		var source, target, weight int
-		if err := edgeRows.Scan(&source, &target, &weight); err != nil {
-			continue
+	fields, err := fieldsQuery(c, entityFields)
+	if err != nil {
+		return err
+	}
+
+	updatedAt, err := data.NetworkUpdatedAt(ctx)
+	if err != nil {
+		return err
+	}
+	key := "network:" + strconv.Itoa(limit) + ":" + strconv.Itoa(minConn) + ":" + strings.Join(fields, ",") +
+		":" + strconv.FormatFloat(minScore, 'g', -1, 64) + ":" + granularity + ":" + c.Query("sort") + ":" + c.Query("order") + ":" + c.Query("minConfidence")
+	if notModified(c, key, updatedAt) {
+		return c.SendStatus(304)
+	}
+
+	f := store.NetworkFilter{
+		MinConnections: minConn,
+		Limit:          limit,
+		MinScore:       minScore,
+		Granularity:    granularity,
+		Sort:           sort,
+	}
+	if err := guardNetwork(c, f); err != nil {
+		return err
+	}
+
+	nodes, edges, err := data.Network(ctx, f)
+	if err != nil {
+		return err
+	}
+
+	return sendFields(c, Network{
+		Nodes: nodes,
+		Edges: edges,
+		Stats: NetworkStats{
+			NodeCount: len(nodes),
+			EdgeCount: len(edges),
+		},
+		Warnings: skipped(c),
+	}, "nodes", fields)
+}
+
+// GetMatrix returns the pairwise co-occurrence of a set of entities
+func GetMatrix(c *fiber.Ctx) error {
+	var body MatrixBody
+	if err := c.BodyParser(&body); err != nil {
+		return apierr.BadRequest("invalid body")
+	}
+
+	// Keep the caller's order, dropping repeats
+	ids := make([]int, 0, len(body.EntityIDs))
+	for _, id := range body.EntityIDs {
+		if id <= 0 {
+			return apierr.InvalidParam("entityIds", "must be positive integers")
+		}
+		if !slices.Contains(ids, id) {
+			ids = append(ids, id)
 		}
+	}
+	if len(ids) < 2 || len(ids) > maxMatrixEntities {
+		return apierr.InvalidParam("entityIds", "must list 2 to "+strconv.Itoa(maxMatrixEntities)+" entities")
+	}
 
-		// Only include edges where both nodes are in our node set
-		if nodeIDs[source] && nodeIDs[target] {
-			edges = append(edges, fiber.Map{
-				"source": source,
-				"target": target,
-				"weight": weight,
-			})
+	m, err := data.CooccurrenceMatrix(c.UserContext(), ids)
+	if err != nil {
+		return err
+	}
+	return c.JSON(m)
+}
+
+// GetFinancialNetwork returns the money flowing between entities and the
+// committees, agencies and lenders in the crossref data
+func GetFinancialNetwork(c *fiber.Ctx) error {
+	limit, err := limitQuery(c, 500, 5000)
+	if err != nil {
+		return err
+	}
+	ids, err := idsQuery(c, "entities", maxMatrixEntities)
+	if err != nil {
+		return err
+	}
+
+	minAmount := 0.0
+	if v := c.Query("minAmount"); v != "" {
+		minAmount, err = strconv.ParseFloat(v, 64)
+		if err != nil || minAmount < 0 {
+			return apierr.InvalidParam("minAmount", "must be a non-negative number")
 		}
 	}
 
-	return c.JSON(fiber.Map{
-		"nodes": nodes,
-		"edges": edges,
-		"stats": fiber.Map{
-			"nodeCount": len(nodes),
-			"edgeCount": len(edges),
+	nodes, edges, err := data.FinancialNetwork(c.UserContext(), store.FinancialFilter{
+		EntityIDs: ids,
+		Confirmed: c.QueryBool("confirmed", true),
+		MinAmount: minAmount,
+		Limit:     limit,
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(FinancialNetwork{
+		Nodes: nodes,
+		Edges: edges,
+		Stats: NetworkStats{
+			NodeCount: len(nodes),
+			EdgeCount: len(edges),
 		},
+		Warnings: skipped(c),
 	})
 }
 
+// GetEntityNetworkSVG draws an entity's neighbourhood as an SVG image
+func GetEntityNetworkSVG(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+
+	id, err := idParam(c)
+	if err != nil {
+		return err
+	}
+	limit, err := limitQuery(c, 20, 60)
+	if err != nil {
+		return err
+	}
+	depth, err := intQuery(c, "depth", 1)
+	if err != nil {
+		return err
+	}
+	if depth < 1 || depth > 2 {
+		return apierr.InvalidParam("depth", "must be 1 or 2")
+	}
+	size, err := intQuery(c, "size", egonet.DefaultSize)
+	if err != nil {
+		return err
+	}
+	if size < egonet.MinSize || size > egonet.MaxSize {
+		return apierr.InvalidParam("size", "must be between "+strconv.Itoa(egonet.MinSize)+" and "+strconv.Itoa(egonet.MaxSize))
+	}
+
+	updatedAt, err := data.NetworkUpdatedAt(ctx)
+	if err != nil {
+		return err
+	}
+	key := "entity-network:" + strconv.Itoa(id) + ":" + strconv.Itoa(limit) + ":" + strconv.Itoa(depth) + ":" + strconv.Itoa(size)
+	if notModified(c, key, updatedAt) {
+		return c.SendStatus(304)
+	}
+
+	nodes, edges, err := data.EgoNetwork(ctx, id, depth, limit)
+	if err != nil {
+		return notFound(err, "entity")
+	}
+
+	var b bytes.Buffer
+	if err := egonet.SVG(&b, nodes, edges, size); err != nil {
+		return err
+	}
+	// Opened directly, the image mustn't be able to run anything
+	c.Set(fiber.HeaderContentSecurityPolicy, "default-src 'none'; style-src 'unsafe-inline'")
+	c.Set(fiber.HeaderContentType, egonet.MediaType)
+	return c.Send(b.Bytes())
+}
+
 // GetNetworkByLayer returns entities organized by layer
 func GetNetworkByLayer(c *fiber.Ctx) error {
-	ctx := context.Background()
-	pool := db.Pool()
+	ctx := c.UserContext()
 
-	var layers []fiber.Map
+	var layers []Layer
 
 	for layer := 0; layer <= 3; layer++ {
-		rows, err := pool.Query(ctx, `
-			SELECT id, canonical_name, entity_type, document_count, connection_count
-			FROM entities
-			WHERE layer = $1 AND entity_type IN ('person', 'organization')
-			ORDER BY connection_count DESC
-			LIMIT 100
-		`, layer)
+		entities, err := data.LayerEntities(ctx, layer, 100)
 		if err != nil {
 			continue
 		}
 
-		var entities []fiber.Map
-		for rows.Next() {
-			var id int
-			var name, etype string
-			var docCount, connCount *int
-
-			if err := rows.Scan(&id, &name, &etype, &docCount, &connCount); err != nil {
-				continue
-			}
-
-			entities = append(entities, fiber.Map{
-				"id":              id,
-				"canonicalName":   name,
-				"entityType":      etype,
-				"documentCount":   docCount,
-				"connectionCount": connCount,
-			})
-		}
-		rows.Close()
-
-		layers = append(layers, fiber.Map{
-			"layer":    layer,
-			"entities": entities,
-			"count":    len(entities),
+		layers = append(layers, Layer{
+			Layer:    layer,
+			Entities: entities,
+			Count:    len(entities),
 		})
 	}
 
-	return c.JSON(fiber.Map{
-		"layers": layers,
+	return c.JSON(LayerList{
+		Layers:   layers,
+		Warnings: skipped(c),
 	})
 }
 
 // ListPatterns returns discovered patterns
 func ListPatterns(c *fiber.Ctx) error {
-	ctx := context.Background()
-	pool := db.Pool()
-
-	status := c.Query("status", "")
-	patternType := c.Query("type", "")
-
-	rows, err := pool.Query(ctx, `
-		SELECT id, title, description, pattern_type, confidence, status, discovered_at
-		FROM pattern_findings
-		WHERE ($1 = '' OR status = $1)
-		  AND ($2 = '' OR pattern_type = $2)
-		ORDER BY discovered_at DESC
-		LIMIT 100
-	`, status, patternType)
+	status, err := enumQuery(c, "status", patternStatuses)
 	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+		return err
+	}
+	sort, err := sortQuery(c, store.PatternSorts)
+	if err != nil {
+		return err
 	}
-	defer rows.Close()
-
-	var patterns []fiber.Map
-	for rows.Next() {
-		var id int
-		var title, description, ptype, status string
-		var confidence *float64
-		var discoveredAt string
-
-		if err := rows.Scan(&id, &title, &description, &ptype, &confidence, &status, &discoveredAt); err != nil {
-			continue
-		}
 
-		patterns = append(patterns, fiber.Map{
-			"id":           id,
-			"title":        title,
-			"description":  description,
-			"patternType":  ptype,
-			"confidence":   confidence,
-			"status":       status,
-			"discoveredAt": discoveredAt,
-		})
+	patterns, err := data.ListPatterns(c.UserContext(), store.PatternFilter{
+		Status: status,
+		Type:   c.Query("type", ""),
+		Sort:   sort,
+	})
+	if err != nil {
+		return err
 	}
 
-	return c.JSON(fiber.Map{
-		"patterns": patterns,
-		"count":    len(patterns),
+	return c.JSON(PatternList{
+		Patterns: patterns,
+		Count:    len(patterns),
+		Warnings: skipped(c),
 	})
 }
 
 // GetPattern returns a single pattern with full details
 func GetPattern(c *fiber.Ctx) error {
-	ctx := context.Background()
-	pool := db.Pool()
+	ctx := c.UserContext()
 
-	id, err := strconv.Atoi(c.Params("id"))
+	id, err := idParam(c)
 	if err != nil {
-		return c.Status(400).JSON(fiber.Map{"error": "invalid id"})
-	}
-
-	var pattern struct {
-		ID           int     `json:"id"`
-		Title        string  `json:"title"`
-		Description  string  `json:"description"`
-		PatternType  string  `json:"patternType"`
-		EntityIDs    []int   `json:"entityIds"`
-		Evidence     []byte  `json:"evidence"`
-		Confidence   *float64 `json:"confidence"`
-		Status       string  `json:"status"`
-		Notes        *string `json:"notes"`
-		DiscoveredAt string  `json:"discoveredAt"`
-		DiscoveredBy string  `json:"discoveredBy"`
-	}
-
-	err = pool.QueryRow(ctx, `
-		SELECT id, title, description, pattern_type, entity_ids, evidence,
-			   confidence, status, notes, discovered_at, discovered_by
-		FROM pattern_findings WHERE id = $1
-	`, id).Scan(
-		&pattern.ID, &pattern.Title, &pattern.Description, &pattern.PatternType,
-		&pattern.EntityIDs, &pattern.Evidence, &pattern.Confidence,
-		&pattern.Status, &pattern.Notes, &pattern.DiscoveredAt, &pattern.DiscoveredBy,
-	)
+		return err
+	}
 
+	pattern, err := data.GetPattern(ctx, id)
 	if err != nil {
-		return c.Status(404).JSON(fiber.Map{"error": "pattern not found"})
-	}
-
-	// Get entity details
-	entityRows, err := pool.Query(ctx, `
-		SELECT id, canonical_name, entity_type, layer
-		FROM entities WHERE id = ANY($1)
-	`, pattern.EntityIDs)
-	if err == nil {
-		var entities []fiber.Map
-		for entityRows.Next() {
-			var eid int
-			var name, etype string
-			var layer *int
-			if err := entityRows.Scan(&eid, &name, &etype, &layer); err != nil {
-				continue
-			}
-			entities = append(entities, fiber.Map{
-				"id":            eid,
-				"canonicalName": name,
-				"entityType":    etype,
-				"layer":         layer,
-			})
-		}
-		entityRows.Close()
+		return notFound(err, "pattern")
+	}
 
-		return c.JSON(fiber.Map{
-			"pattern":  pattern,
-			"entities": entities,
-		})
+	entities, err := data.EntitiesByID(ctx, pattern.EntityIDs)
+	if err != nil {
+		return err
 	}
 
-	return c.JSON(pattern)
+	return c.JSON(PatternDetail{
+		Pattern:  *pattern,
+		Entities: entities,
+	})
 }
@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/subculture-collective/epstein-db/api/internal/apierr"
+)
+
+func TestListPatterns(t *testing.T) {
+	count := func(want int) func(t *testing.T, body []byte) {
+		return func(t *testing.T, body []byte) {
+			if got := decode[PatternList](t, body).Count; got != want {
+				t.Fatalf("count %d, want %d", got, want)
+			}
+		}
+	}
+	runCases(t, []handlerCase{
+		{name: "all", target: "/api/patterns", status: http.StatusOK, check: count(1)},
+		{name: "by status", target: "/api/patterns?status=hypothesis", status: http.StatusOK, check: count(1)},
+		{name: "by other status", target: "/api/patterns?status=validated", status: http.StatusOK, check: count(0)},
+		{name: "by type", target: "/api/patterns?type=financial_flow", status: http.StatusOK, check: count(1)},
+		{name: "unknown status", target: "/api/patterns?status=maybe", status: http.StatusBadRequest, code: apierr.CodeInvalidParam},
+		{name: "unknown order", target: "/api/patterns?order=sideways", status: http.StatusBadRequest, code: apierr.CodeInvalidParam},
+	})
+}
+
+func TestGetPattern(t *testing.T) {
+	runCases(t, []handlerCase{
+		{name: "with its entities", target: "/api/patterns/1", status: http.StatusOK,
+			check: func(t *testing.T, body []byte) {
+				p := decode[PatternDetail](t, body)
+				if p.Pattern.Title != "Payments through Acme" {
+					t.Errorf("title %q, want Payments through Acme", p.Pattern.Title)
+				}
+				ids := make([]int, len(p.Entities))
+				for i, e := range p.Entities {
+					ids[i] = e.ID
+				}
+				wantIDs(t, ids, []int{2, 3})
+			}},
+		{name: "missing", target: "/api/patterns/999", status: http.StatusNotFound, code: apierr.CodeNotFound},
+		{name: "malformed id", target: "/api/patterns/x", status: http.StatusBadRequest, code: apierr.CodeInvalidParam},
+	})
+}
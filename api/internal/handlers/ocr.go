@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/subculture-collective/epstein-db/api/internal/apierr"
+	"github.com/subculture-collective/epstein-db/api/internal/audit"
+	"github.com/subculture-collective/epstein-db/api/internal/db"
+	"github.com/subculture-collective/epstein-db/api/internal/jobs"
+	"github.com/subculture-collective/epstein-db/api/internal/ocr"
+	"github.com/subculture-collective/epstein-db/api/internal/upload"
+)
+
+// scanLimit is the largest scan UploadScan takes
+func scanLimit() int64 {
+	if settings == nil {
+		return 100 << 20
+	}
+	return int64(settings.Uploads.MaxScan)
+}
+
+// UploadScan accepts a PDF or image upload and queues it for OCR
+func UploadScan(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+
+	docID := strings.TrimSpace(c.FormValue("docId"))
+	if docID == "" {
+		return apierr.InvalidParam("docId", "is required")
+	}
+	datasetID, err := strconv.Atoi(c.FormValue("datasetId"))
+	if err != nil || datasetID <= 0 {
+		return apierr.InvalidParam("datasetId", "must be a positive integer")
+	}
+
+	file, contentType, err := upload.File(c, "file", scanLimit(), ocr.Types...)
+	if err != nil {
+		return err
+	}
+	ext := ocr.Extensions[contentType]
+
+	dir := ocr.UploadDir()
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return err
+	}
+
+	// Never trust the client's file name; the extension comes from the content
+	path := filepath.Join(dir, fmt.Sprintf("%d-%s%s", time.Now().UnixNano(), filepath.Base(docID), ext))
+	if err := c.SaveFile(file, path); err != nil {
+		return err
+	}
+
+	id, err := jobs.NewQueue(db.Pool()).Enqueue(ctx, ocr.JobKind, ocr.Input{
+		Path:      path,
+		DocID:     docID,
+		DatasetID: datasetID,
+	})
+	if err != nil {
+		os.Remove(path)
+		return err
+	}
+
+	audit.SetJob(c, id)
+
+	return c.Status(202).JSON(QueuedJob{
+		JobID:  id,
+		Status: jobs.StatusQueued,
+	})
+}
@@ -0,0 +1,1555 @@
+package handlers
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/subculture-collective/epstein-db/api/internal/anomalies"
+	"github.com/subculture-collective/epstein-db/api/internal/bookmarks"
+	"github.com/subculture-collective/epstein-db/api/internal/changes"
+	"github.com/subculture-collective/epstein-db/api/internal/chat"
+	"github.com/subculture-collective/epstein-db/api/internal/cite"
+	"github.com/subculture-collective/epstein-db/api/internal/collections"
+	"github.com/subculture-collective/epstein-db/api/internal/config"
+	"github.com/subculture-collective/epstein-db/api/internal/corrections"
+	"github.com/subculture-collective/epstein-db/api/internal/dates"
+	"github.com/subculture-collective/epstein-db/api/internal/descriptions"
+	"github.com/subculture-collective/epstein-db/api/internal/digest"
+	"github.com/subculture-collective/epstein-db/api/internal/egonet"
+	"github.com/subculture-collective/epstein-db/api/internal/embeddings"
+	"github.com/subculture-collective/epstein-db/api/internal/events"
+	"github.com/subculture-collective/epstein-db/api/internal/feeds"
+	"github.com/subculture-collective/epstein-db/api/internal/jobs"
+	"github.com/subculture-collective/epstein-db/api/internal/media"
+	"github.com/subculture-collective/epstein-db/api/internal/models"
+	"github.com/subculture-collective/epstein-db/api/internal/openapi"
+	"github.com/subculture-collective/epstein-db/api/internal/patterns"
+	"github.com/subculture-collective/epstein-db/api/internal/quality"
+	"github.com/subculture-collective/epstein-db/api/internal/rag"
+	"github.com/subculture-collective/epstein-db/api/internal/recount"
+	"github.com/subculture-collective/epstein-db/api/internal/reports"
+	"github.com/subculture-collective/epstein-db/api/internal/roles"
+	"github.com/subculture-collective/epstein-db/api/internal/seo"
+	"github.com/subculture-collective/epstein-db/api/internal/shells"
+	"github.com/subculture-collective/epstein-db/api/internal/snapshot"
+	"github.com/subculture-collective/epstein-db/api/internal/stoplist"
+	"github.com/subculture-collective/epstein-db/api/internal/store"
+	"github.com/subculture-collective/epstein-db/api/internal/summarize"
+	"github.com/subculture-collective/epstein-db/api/internal/thumbnails"
+	"github.com/subculture-collective/epstein-db/api/internal/timeline"
+	"github.com/subculture-collective/epstein-db/api/internal/tips"
+	"github.com/subculture-collective/epstein-db/api/internal/trash"
+	"github.com/subculture-collective/epstein-db/api/internal/trending"
+	"github.com/subculture-collective/epstein-db/api/internal/webhooks"
+	"github.com/subculture-collective/epstein-db/api/internal/worklist"
+)
+
+// OpenAPI operations for each handler, registered alongside the route in
+// cmd/server. Response types are the structs the handlers actually return.
+
+func limitParam(def, max int) openapi.Param {
+	return openapi.Param{Name: "limit", Type: "integer", Default: def, Description: "Maximum results (capped at " + strconv.Itoa(max) + ")"}
+}
+
+// fieldsParam documents the fields parameter of a list endpoint
+func fieldsParam(allowed []string) openapi.Param {
+	return openapi.Param{Name: "fields", Description: "Comma-separated fields to include in each item, out of: " + strings.Join(allowed, ", ")}
+}
+
+var offsetParam = openapi.Param{Name: "offset", Type: "integer", Default: 0}
+
+var sortOrders = []string{"asc", "desc"}
+
+// sortParam documents the sort parameter of a list endpoint
+func sortParam(sorts store.Sorts) openapi.Param {
+	return openapi.Param{Name: "sort", Enum: sorts.Keys(), Default: sorts.Default, Description: "Key to sort by; ties are broken by ID so pages are stable"}
+}
+
+var matchParam = openapi.Param{Name: "match", Enum: store.MatchModes, Default: store.MatchFuzzy,
+	Description: "How q matches names: fuzzy by substring or similarity, prefix, or exact ignoring case. % and _ match themselves."}
+
+var orderParam = openapi.Param{Name: "order", Enum: sortOrders, Description: "Sort direction; defaults to the key's natural one, e.g. largest counts first"}
+
+var formats = []string{"json", "csv"}
+
+var entityFormats = []string{"json", "jsonld"}
+
+var granularityParam = openapi.Param{Name: "granularity", Enum: store.Granularities, Default: store.GranularityDocument,
+	Description: "Co-occur by sharing a document, or by being named within a few pages of each other in one; page granularity needs the page index built by `worker pages`"}
+
+var tagParam = openapi.Param{Name: "tag", Description: "Only documents with this content tag; see /api/tags"}
+
+var roleParam = openapi.Param{Name: "role", Enum: roles.Names(), Description: "Only mentions where the entity has this role, as inferred from the document's text"}
+
+// minConfidenceParam is read by ConfidenceFilter for every request, but
+// only documented where links are counted or listed
+var minConfidenceParam = openapi.Param{Name: "minConfidence", Type: "number", Default: 0,
+	Description: "Leave out document–entity links extracted with less confidence, 0 to 1; poor OCR lowers a link's confidence"}
+
+// excludeSensitiveParam is read by SensitivityFilter for every request, but
+// only documented where documents are listed or searched
+var excludeSensitiveParam = openapi.Param{Name: "excludeSensitive", Type: "boolean", Default: false,
+	Description: "Leave out documents with any content warning in sensitivity: explicit content, minors referenced or medical records"}
+
+// formatParam documents the format parameter of endpoints that also serve
+// CSV
+var formatParam = openapi.Param{Name: "format", Enum: formats, Description: "Response format; defaults to CSV when Accept prefers text/csv, otherwise JSON. CSV holds the list items only."}
+
+const conditionalNote = "Responses carry ETag and Last-Modified; send If-None-Match or If-Modified-Since to get 304 Not Modified when nothing has changed."
+
+// restrictedNote describes the content withheld from anonymous callers
+const restrictedNote = "401 without an API key for a document of a restricted dataset."
+
+const guardNote = "Requests the planner expects to be too expensive, such as a query of only stop words, get 422 too_expensive with a hint on narrowing them."
+
+var entityTypes = store.EntityTypes
+
+var layers = []string{"0", "1", "2", "3"}
+
+// Fields that list endpoints can be narrowed to with ?fields=
+var (
+	entityFields   = jsonFields(models.EntitySummary{})
+	documentFields = jsonFields(models.DocumentSummary{})
+)
+
+var datasetStatuses = []string{"registered", "ingesting", "processing", "ready", "failed"}
+
+var patternStatuses = []string{"hypothesis", "validated", "rejected"}
+
+var extractionMethods = []string{"rule", "llm"}
+
+// Modes of /api/search: full-text over stemmed words, or matching by
+// character trigrams, which tolerates OCR misreadings
+const (
+	searchText  = "text"
+	searchNgram = "ngram"
+)
+
+var searchModes = []string{searchText, searchNgram}
+
+// defaultFuzziness is pg_trgm's own default word similarity threshold, 0.6
+const defaultFuzziness = 0.4
+
+var jobStatuses = []string{jobs.StatusQueued, jobs.StatusRunning, jobs.StatusCompleted, jobs.StatusFailed}
+
+var GetStatsSpec = openapi.Operation{
+	Summary:     "Row counts for the main tables",
+	Description: "Counts are precomputed and refreshed periodically by the worker's schedule command; lastRefreshed says when, and each table's lastChanged when its data last changed. A stats view that hasn't been created or refreshed yet is listed under unavailable and its counts are left out.",
+	Tag:         "stats",
+	Response:    models.Stats{},
+}
+
+var SearchEntitiesSpec = openapi.Operation{
+	Summary: "Search entities by name",
+	Tag:     "entities",
+	Params: []openapi.Param{
+		{Name: "q", Description: "Name to match"},
+		matchParam,
+		{Name: "type", Enum: entityTypes},
+		{Name: "layer", Type: "integer", Enum: layers},
+		sortParam(store.EntitySorts),
+		orderParam,
+		limitParam(20, 100),
+		fieldsParam(entityFields),
+		formatParam,
+	},
+	Response: EntityList{},
+	CSV:      true,
+}
+
+var ResolveEntitiesSpec = openapi.Operation{
+	Summary:     "Resolve a list of names to entities",
+	Description: "Matches each name, for instance from a flight manifest or donor list, against entity names and aliases by trigram similarity and returns the best match with a score and the next best alternatives. Blank names resolve to nothing.",
+	Tag:         "entities",
+	Body:        ResolveBody{},
+	Response:    ResolveResults{},
+}
+
+var GetEntitySpec = openapi.Operation{
+	Summary: "Get an entity",
+	Description: "With format=jsonld (or Accept: application/ld+json) the entity is a JSON-LD schema.org Person, " +
+		"Organization, Place or Thing, with its extracted relationships as properties. Plain JSON is conditional: " + conditionalNote,
+	Tag: "entities",
+	Params: []openapi.Param{
+		{Name: "format", Enum: entityFormats, Default: "json"},
+	},
+	Response: models.Entity{},
+}
+
+var ExportRDFSpec = openapi.Operation{
+	Summary: "The entity graph as N-Triples",
+	Description: "Every entity, typed with schema.org and named with its aliases, and every distinct extracted " +
+		"relationship, as a property under /vocab#. Entity IRIs are their /api/entities/:id URLs. " +
+		"The dump is large, so it requires a researcher API key.",
+	Tag:         "entities",
+	ContentType: "application/n-triples",
+}
+
+var GetEntityConnectionsSpec = openapi.Operation{
+	Summary:  "Entities that share documents with an entity",
+	Tag:      "entities",
+	Params:   []openapi.Param{granularityParam, minConfidenceParam, sortParam(store.ConnectionSorts), orderParam, limitParam(50, 200), formatParam},
+	Response: ConnectionList{},
+	CSV:      true,
+}
+
+var GetEntityNetworkSVGSpec = openapi.Operation{
+	Summary:     "Image of an entity's network",
+	Description: "An SVG of the entity and the people and organizations most strongly related to it, and at depth 2 those most strongly related to them, for embedding in reports and social cards. Relationships and their strength come from `worker edges`, which runs nightly under `worker schedule`; until then the entity stands alone. " + conditionalNote,
+	Tag:         "entities",
+	Params: []openapi.Param{
+		{Name: "size", Type: "integer", Default: egonet.DefaultSize, Description: "Width and height in pixels, 200 to 1600"},
+		{Name: "depth", Type: "integer", Default: 1, Description: "1 or 2 hops from the entity"},
+		limitParam(20, 60),
+	},
+	ContentType: egonet.MediaType,
+}
+
+var GetEntityBioSpec = openapi.Operation{
+	Summary:     "Generated biography of an entity",
+	Description: "Written by a language model strictly from documents mentioning the entity and its verified cross-references. Each [n] in the text has a citation giving the document span or cross-reference record it rests on; provenance marks it as generated. 404 until one has been generated.",
+	Tag:         "entities",
+	Response:    EntityBio{},
+}
+
+var QueueEntityBioSpec = openapi.Operation{
+	Summary:  "Queue generation of an entity's biography (admin)",
+	Tag:      "entities",
+	Status:   202,
+	Response: QueuedJob{},
+}
+
+var GetEntityNarrativeSpec = openapi.Operation{
+	Summary:     "Generated chronology of an entity",
+	Description: "Written by a language model from the entity's dated document mentions, dated relationships (flights, meetings and the like) and verified cross-references. Sentences are in date order and each cites the one event it rests on. stale is set once new documents or relationships involving the entity arrive, until the narrative is regenerated. 404 until one has been generated.",
+	Tag:         "entities",
+	Response:    EntityNarrative{},
+}
+
+var QueueEntityNarrativeSpec = openapi.Operation{
+	Summary:  "Queue generation of an entity's chronology (admin)",
+	Tag:      "entities",
+	Status:   202,
+	Response: QueuedJob{},
+}
+
+var ListEntityMediaSpec = openapi.Operation{
+	Summary:     "Images of an entity",
+	Description: "Photographs, logos and the like, with where each was found and its license; the primary image first. Fetch one from /api/media/{id}.",
+	Tag:         "entities",
+	Response:    MediaList{},
+}
+
+var ServeMediaSpec = openapi.Operation{
+	Summary:     "Get an image",
+	Description: "The image as uploaded, or scaled down to width (never up). Resized JPEGs stay JPEGs; other images are resized to PNG.",
+	Tag:         "entities",
+	Params:      []openapi.Param{{Name: "width", Type: "integer", Enum: []string{"64", "128", "256", "512", "1024"}, Description: "Scale to this many pixels wide"}},
+	ContentType: "image/jpeg",
+}
+
+var AttachMediaSpec = openapi.Operation{
+	Summary:     "Attach an image to an entity",
+	Description: "A JPEG, PNG or GIF of at most 4 MB and 40 megapixels. The entity's first image becomes its primary one, shown on its page and network node; primary makes this one primary instead.",
+	Tag:         "admin",
+	Form: []openapi.Param{
+		{Name: "file", Type: "file", Required: true},
+		{Name: "sourceUrl", Description: "Where the image was found"},
+		{Name: "license", Description: "e.g. CC BY-SA 4.0 or public domain"},
+		{Name: "attribution", Description: "Credit line the license asks for"},
+		{Name: "caption"},
+		{Name: "primary", Type: "boolean", Default: false},
+	},
+	Status:   201,
+	Response: media.Media{},
+}
+
+var UpdateMediaSpec = openapi.Operation{
+	Summary:     "Describe an image",
+	Description: "Replaces its source, license, attribution and caption. primary makes it the entity's primary image; to stop it being primary, make another one primary.",
+	Tag:         "admin",
+	Body:        MediaBody{},
+	Response:    media.Media{},
+}
+
+var DeleteMediaSpec = openapi.Operation{
+	Summary:     "Detach an image",
+	Description: "The entity's oldest remaining image becomes primary if this one was. The file is deleted once no entity uses it.",
+	Tag:         "admin",
+	Status:      204,
+}
+
+var GetEntityDocumentsSpec = openapi.Operation{
+	Summary:  "Documents that mention an entity",
+	Tag:      "entities",
+	Params:   []openapi.Param{roleParam, minConfidenceParam, excludeSensitiveParam, sortParam(store.EntityDocumentSorts), orderParam, limitParam(50, 200)},
+	Response: EntityDocumentList{},
+}
+
+var ListDocumentsSpec = openapi.Operation{
+	Summary: "List documents",
+	Tag:     "documents",
+	Params: []openapi.Param{
+		{Name: "type", Description: "Document type"},
+		{Name: "dataset", Type: "integer", Description: "Dataset ID"},
+		tagParam,
+		excludeSensitiveParam,
+		sortParam(store.DocumentSorts),
+		orderParam,
+		limitParam(50, 200),
+		offsetParam,
+		fieldsParam(documentFields),
+		formatParam,
+	},
+	Response: DocumentPage{},
+	CSV:      true,
+}
+
+var GetDocumentSpec = openapi.Operation{
+	Summary:     "Get a document",
+	Description: conditionalNote,
+	Tag:         "documents",
+	Response:    models.Document{},
+}
+
+var RandomDocumentSpec = openapi.Operation{
+	Summary:     "Get a random document",
+	Description: "For exploring, or spot-checking OCR. Sampled from table pages, so documents are close to, not exactly, equally likely. 404 if no document matches.",
+	Tag:         "documents",
+	Params: []openapi.Param{
+		{Name: "type", Description: "Document type"},
+		{Name: "dataset", Type: "integer", Description: "Dataset ID"},
+		excludeSensitiveParam,
+	},
+	Response: models.Document{},
+}
+
+var GetDocumentTextSpec = openapi.Operation{
+	Summary:     "Get a document's full text",
+	Description: conditionalNote + " " + restrictedNote,
+	Tag:         "documents",
+	Response:    DocumentText{},
+}
+
+var CiteDocumentSpec = openapi.Operation{
+	Summary:     "Cite a document",
+	Description: "A citation naming the document, its dates, its dataset and the Department of Justice release, with the web app permalink and today as the access date. BibTeX by default; csl-json is a one-item CSL-JSON list, and chicago a plain-text Chicago bibliography entry.",
+	Tag:         "documents",
+	Params:      []openapi.Param{{Name: "format", Enum: cite.Formats}},
+	ContentType: cite.MediaBibTeX,
+}
+
+var GetDocumentEntitiesSpec = openapi.Operation{
+	Summary:  "Entities mentioned in a document",
+	Tag:      "documents",
+	Params:   []openapi.Param{roleParam, minConfidenceParam},
+	Response: DocumentEntityList{},
+}
+
+var GetDocumentChunksSpec = openapi.Operation{
+	Summary:     "A document's chunks",
+	Description: "The passages the document is split into for embedding, in order, with their offsets in the full text. Empty until the document has been embedded. " + restrictedNote,
+	Tag:         "documents",
+	Params:      []openapi.Param{limitParam(100, 500), offsetParam},
+	Response:    DocumentChunkPage{},
+}
+
+var GetDocumentDuplicatesSpec = openapi.Operation{
+	Summary:     "Near duplicates of a document",
+	Description: "Documents whose text is nearly the same, such as an exhibit released in several datasets, most similar first. Found by `worker duplicates`, which runs nightly under `worker schedule`; empty until then.",
+	Tag:         "documents",
+	Response:    DuplicateList{},
+}
+
+var GetPageThumbnailSpec = openapi.Operation{
+	Summary: "Thumbnail of a document page",
+	Description: "A JPEG of page n of the document's stored PDF or image: small is 200 pixels wide, medium 600 and large 1200, or narrower for a narrower page. " +
+		"Rendered by `worker thumbnails`, which runs nightly under `worker schedule`; 404 until then, and for documents without a stored PDF or image. Redirects (302) to a presigned link once copied to object storage. " + restrictedNote,
+	Tag:         "documents",
+	Params:      []openapi.Param{{Name: "size", Enum: thumbnails.Sizes, Default: thumbnails.SizeMedium}},
+	ContentType: "image/jpeg",
+}
+
+var GetDocumentFileSpec = openapi.Operation{
+	Summary: "A document's original file",
+	Description: "The PDF or scan the document's text was read from. When object storage is configured this redirects (302) to a presigned link that works for DOWNLOAD_URL_TTL; otherwise the file is sent directly. " +
+		"404 for documents without a stored file, and 403 for documents naming a protected person, whose names can only be masked in the text. " + restrictedNote,
+	Tag:         "documents",
+	ContentType: "application/pdf",
+}
+
+var GetDocumentProvenanceSpec = openapi.Operation{
+	Summary:  "Trace a document back to its source file",
+	Tag:      "documents",
+	Response: models.Provenance{},
+}
+
+var ListDatasetsSpec = openapi.Operation{
+	Summary:  "List dataset releases",
+	Tag:      "datasets",
+	Params:   []openapi.Param{{Name: "status", Enum: datasetStatuses}, sortParam(store.DatasetSorts), orderParam},
+	Response: DatasetList{},
+}
+
+var GetDatasetAnalyticsSpec = openapi.Operation{
+	Summary: "Summary of a dataset release",
+	Description: "Document count and a histogram of document dates by year; the entities the dataset introduced, meaning those no earlier dataset mentions; " +
+		"how many of its entities each other dataset shares; and the OCR quality of its pages.",
+	Tag:      "datasets",
+	Response: models.DatasetAnalytics{},
+}
+
+var UpdateDatasetVisibilitySpec = openapi.Operation{
+	Summary: "Make a dataset public or restricted",
+	Description: "Documents of a restricted dataset, one whose terms don't allow redistribution, are served metadata-only to callers without an API key: " +
+		"no summaries, text, chunks or page images, and searches don't find them. The same holds for GraphQL, gRPC, feeds and snapshots.",
+	Tag:      "admin",
+	Body:     DatasetVisibilityBody{},
+	Response: models.Dataset{},
+}
+
+var GetNetworkSpec = openapi.Operation{
+	Summary:     "Entity co-occurrence network",
+	Description: conditionalNote + " " + guardNote,
+	Tag:         "network",
+	Params: []openapi.Param{
+		limitParam(1000, 10000),
+		{Name: "minConnections", Type: "integer", Default: 2},
+		{Name: "minScore", Type: "number", Default: 0, Description: "Only edges with at least this relationship strength; unscored edges are left out when set"},
+		granularityParam,
+		minConfidenceParam,
+		sortParam(store.EdgeSorts),
+		orderParam,
+		fieldsParam(entityFields),
+	},
+	Response: Network{},
+}
+
+var GetFinancialNetworkSpec = openapi.Operation{
+	Summary: "Money flowing between entities",
+	Description: "A directed graph built from entities' crossref matches: donor to committee (FEC), agency to grantee (federal grants) and lender to borrower (PPP). " +
+		"Each edge totals the amount and records of one kind between two nodes. Committees, agencies and lenders named like an organization entity are merged into it; the rest are nodes of their own.",
+	Tag: "network",
+	Params: []openapi.Param{
+		{Name: "entities", Description: "Comma-separated entity IDs, at most 200; only flows to or from them"},
+		{Name: "confirmed", Type: "boolean", Default: true, Description: "Only matches a researcher has verified; false includes every match not marked a false positive"},
+		{Name: "minAmount", Type: "number", Default: 0, Description: "Only flows totalling at least this many dollars"},
+		limitParam(500, 5000),
+	},
+	Response: FinancialNetwork{},
+}
+
+var MatrixSpec = openapi.Operation{
+	Summary:     "Co-occurrence matrix of a set of entities",
+	Description: "Counts the documents each pair of the given entities shares, as a square matrix in the order requested, and lists the relationships extracted between them. IDs that aren't entities are returned in missing and left out of the matrix.",
+	Tag:         "network",
+	Params:      []openapi.Param{minConfidenceParam},
+	Body:        MatrixBody{},
+	Response:    models.Matrix{},
+}
+
+var GetNetworkByLayerSpec = openapi.Operation{
+	Summary:  "Top entities in each network layer",
+	Tag:      "network",
+	Response: LayerList{},
+}
+
+var SearchTriplesSpec = openapi.Operation{
+	Summary: "Search subject-predicate-object triples",
+	Tag:     "triples",
+	Params: []openapi.Param{
+		{Name: "predicate", Description: "Comma-separated predicates"},
+		{Name: "subject", Type: "integer", Description: "Subject entity ID"},
+		{Name: "object", Type: "integer", Description: "Object entity ID"},
+		{Name: "entity", Type: "integer", Description: "Entity ID on either side"},
+		{Name: "document", Type: "integer", Description: "Document ID"},
+		{Name: "method", Enum: extractionMethods},
+		sortParam(store.TripleSorts),
+		orderParam,
+		limitParam(50, 200),
+		offsetParam,
+	},
+	Response: TripleList{},
+}
+
+var ListPredicatesSpec = openapi.Operation{
+	Summary:  "Distinct predicates with usage counts",
+	Tag:      "triples",
+	Response: PredicateList{},
+}
+
+var SearchPPPSpec = openapi.Operation{
+	Summary:  "Search PPP loans by borrower",
+	Tag:      "crossref",
+	Params:   []openapi.Param{{Name: "q"}, matchParam, sortParam(store.PPPSorts), orderParam, limitParam(50, 200), formatParam},
+	Response: PPPResults{},
+	CSV:      true,
+}
+
+var SearchFECSpec = openapi.Operation{
+	Summary:  "Search FEC contributions by contributor",
+	Tag:      "crossref",
+	Params:   []openapi.Param{{Name: "q"}, matchParam, {Name: "candidate", Description: "Substring of the candidate's name"}, sortParam(store.FECSorts), orderParam, limitParam(50, 200), formatParam},
+	Response: FECResults{},
+	CSV:      true,
+}
+
+var SearchGrantsSpec = openapi.Operation{
+	Summary:  "Search federal grants by recipient",
+	Tag:      "crossref",
+	Params:   []openapi.Param{{Name: "q"}, matchParam, {Name: "agency", Description: "Substring of the awarding agency"}, sortParam(store.GrantSorts), orderParam, limitParam(50, 200), formatParam},
+	Response: GrantResults{},
+	CSV:      true,
+}
+
+var ListPatternsSpec = openapi.Operation{
+	Summary: "List discovered patterns",
+	Tag:     "patterns",
+	Params: []openapi.Param{
+		{Name: "status", Enum: patternStatuses},
+		{Name: "type"},
+		sortParam(store.PatternSorts),
+		orderParam,
+	},
+	Response: PatternList{},
+}
+
+var GetPatternSpec = openapi.Operation{
+	Summary:  "Get a pattern with its entities",
+	Tag:      "patterns",
+	Response: PatternDetail{},
+}
+
+var FullTextSearchSpec = openapi.Operation{
+	Summary:     "Full-text search of document text",
+	Description: "In text mode q's words are stemmed and must all appear. In ngram mode documents match on a passage sharing enough character trigrams with q, so OCR misreadings such as \"Epste1n\" or \"Maxvvell\" still match; rank is then the passage's similarity, from 0 to 1. " + guardNote,
+	Tag:         "search",
+	Params: []openapi.Param{
+		{Name: "q", Required: true},
+		{Name: "mode", Enum: searchModes, Default: searchText},
+		{Name: "dataset", Type: "integer", Description: "Dataset ID"},
+		{Name: "type", Description: "Document type"},
+		{Name: "entityId", Type: "integer", Description: "Only documents mentioning this entity"},
+		tagParam,
+		{Name: "dedupe", Type: "boolean", Default: false, Description: "Keep only the best match of each set of near duplicates, counting the rest in collapsed; may return fewer than limit"},
+		excludeSensitiveParam,
+		{Name: "fuzziness", Type: "number", Default: defaultFuzziness, Description: "ngram mode only: from 0, where the passage must have every trigram of q, to 1, the loosest"},
+		limitParam(20, 100),
+	},
+	Response: SearchResults{},
+}
+
+const curationNote = "Requires a researcher API key. Changes are recorded in the audit log."
+
+var GetTimelineSpec = openapi.Operation{
+	Summary:     "Corpus-wide timeline",
+	Description: "Dated events in date order: relationships extracted from documents (flights, meetings and the like), verified public records, and events curated by researchers. Each event names its source document or cross-reference record and the entities involved.",
+	Tag:         "timeline",
+	Params: []openapi.Param{
+		{Name: "from", Description: "Earliest date, YYYY-MM-DD"},
+		{Name: "to", Description: "Latest date, YYYY-MM-DD"},
+		{Name: "entities", Description: "Comma-separated entity IDs; only events involving them"},
+		{Name: "match", Enum: []string{"any", "all"}, Default: "any", Description: "Whether events must involve any or all of entities"},
+		{Name: "type", Enum: timeline.Types},
+		sortParam(store.TimelineSorts),
+		orderParam,
+		limitParam(100, 1000),
+		offsetParam,
+		formatParam,
+	},
+	Response: TimelinePage{},
+	CSV:      true,
+}
+
+var CreateTimelineEventSpec = openapi.Operation{
+	Summary:     "Add an event to the timeline",
+	Description: curationNote,
+	Tag:         "timeline",
+	Body:        TimelineEventBody{},
+	Status:      201,
+	Response:    TimelineEventRef{},
+}
+
+var UpdateTimelineEventSpec = openapi.Operation{
+	Summary:     "Correct a timeline event",
+	Description: "Replaces the event's date, description, type and entities, and its document when one is given. Extracted events can be corrected too; extraction won't overwrite them. " + curationNote,
+	Tag:         "timeline",
+	Body:        TimelineEventBody{},
+	Response:    TimelineEventRef{},
+}
+
+var DeleteTimelineEventSpec = openapi.Operation{
+	Summary:     "Remove a timeline event",
+	Description: "Curated events are deleted; extracted events are hidden so that extraction doesn't add them again. " + curationNote,
+	Tag:         "timeline",
+	Status:      204,
+}
+
+var SearchChunksSpec = openapi.Operation{
+	Summary:     "Retrieve document passages for a query",
+	Description: "The retrieval behind /api/ask, for agents that bring their own model: embedding similarity and full-text matches fused by rank, best first, each with its document and offsets in the full text. Without an embedding provider only full-text matching is used, and semantic is false.",
+	Tag:         "search",
+	Params: []openapi.Param{
+		{Name: "q", Required: true, Description: "Query"},
+		limitParam(10, 50),
+		{Name: "dataset", Type: "integer", Description: "Only passages from this dataset"},
+		{Name: "document", Type: "integer", Description: "Only passages from this document"},
+	},
+	Response: ChunkResults{},
+}
+
+var AskSpec = openapi.Operation{
+	Summary:     "Answer a question from the documents",
+	Description: "Retrieves the most relevant document passages by embedding similarity and full-text match, and has the model answer strictly from them. Each [n] in the answer has a citation giving the document and character range of the passage. When nothing relevant enough is found, or the model can't ground its answer, the response is refused with a reason instead. Each question is a paid model call, so it requires a researcher API key. Answers 503 when no model is configured.",
+	Tag:         "search",
+	Body:        AskBody{},
+	Response:    rag.Answer{},
+}
+
+var VerifySpec = openapi.Operation{
+	Summary:     "Check a claim against the documents",
+	Description: "Retrieves candidate evidence for the claim and has the model classify it as supported, contradicted or not_found, returning the exact passages on each side with their document and character range. A verdict that can't be tied to a passage is reported as not_found. Each claim is a paid model call, so it requires a researcher API key. Answers 503 when no model is configured.",
+	Tag:         "search",
+	Body:        VerifyBody{},
+	Response:    rag.Verdict{},
+}
+
+const chatNote = "Requires a researcher API key; sessions are visible only to the key that created them."
+
+var CreateSessionSpec = openapi.Operation{
+	Summary:     "Start a research chat session",
+	Description: chatNote,
+	Tag:         "chat",
+	Body:        CreateSessionBody{},
+	Status:      201,
+	Response:    chat.Session{},
+}
+
+var ListSessionsSpec = openapi.Operation{
+	Summary:     "List your chat sessions",
+	Description: "Most recently active first. " + chatNote,
+	Tag:         "chat",
+	Params:      []openapi.Param{limitParam(50, 200), offsetParam},
+	Response:    SessionList{},
+}
+
+var GetSessionSpec = openapi.Operation{
+	Summary:     "Get a chat session with its messages",
+	Description: chatNote,
+	Tag:         "chat",
+	Response:    chat.Transcript{},
+}
+
+var DeleteSessionSpec = openapi.Operation{
+	Summary:     "Delete a chat session",
+	Description: chatNote,
+	Tag:         "chat",
+	Status:      204,
+}
+
+var SendMessageSpec = openapi.Operation{
+	Summary: "Send a message and get the reply",
+	Description: "The model sees the session's recent messages and may call tools before answering: " + chat.ToolSearch + " adds document passages as sources, " +
+		chat.ToolEntity + " finds entities by name and " + chat.ToolNetwork + " lists an entity's strongest connections. " +
+		"Citations are kept only when their quote is found verbatim in a passage retrieved for this reply, and toolCalls records what was looked up. " +
+		"Answers 503 when no model is configured. " + chatNote,
+	Tag:      "chat",
+	Body:     SendMessageBody{},
+	Response: chat.Message{},
+}
+
+var ExportSessionSpec = openapi.Operation{
+	Summary:     "Export a chat session transcript",
+	Description: "JSON by default, or Markdown with each answer's sources and tools listed under it. " + chatNote,
+	Tag:         "chat",
+	Params:      []openapi.Param{{Name: "format", Enum: transcriptFormats, Default: "json"}},
+	Response:    chat.Transcript{},
+}
+
+const webhookNote = "Requires a researcher API key; webhooks are visible only to the key that created them."
+
+var CreateWebhookSpec = openapi.Operation{
+	Summary: "Subscribe a URL to data change events",
+	Description: webhookNote + " Each event is POSTed as JSON with " + webhooks.HeaderEvent + ", " + webhooks.HeaderDelivery +
+		", " + webhooks.HeaderTimestamp + " and " + webhooks.HeaderSignature + " headers. The signature is sha256= and the hex " +
+		"HMAC-SHA256, keyed by the secret, of the timestamp, a period and the body. The secret is returned only here. " +
+		"Failed deliveries are retried with exponential backoff for about a day.",
+	Tag:      "webhooks",
+	Body:     CreateWebhookBody{},
+	Status:   201,
+	Response: webhooks.Webhook{},
+}
+
+var ListWebhooksSpec = openapi.Operation{
+	Summary:     "Your webhooks",
+	Description: webhookNote,
+	Tag:         "webhooks",
+	Response:    WebhookList{},
+}
+
+var DeleteWebhookSpec = openapi.Operation{
+	Summary:     "Delete a webhook and its delivery log",
+	Description: webhookNote,
+	Tag:         "webhooks",
+	Status:      204,
+}
+
+var ListDeliveriesSpec = openapi.Operation{
+	Summary:     "Delivery log of a webhook, newest first",
+	Description: webhookNote,
+	Tag:         "webhooks",
+	Params: []openapi.Param{
+		{Name: "status", Enum: deliveryStatuses},
+		limitParam(50, 200),
+		offsetParam,
+	},
+	Response: DeliveryLog{},
+}
+
+const collectionNote = "Requires a researcher API key; collections are visible only to the key that created them, or to anyone with their share token."
+
+var CreateCollectionSpec = openapi.Operation{
+	Summary:     "Start a research collection",
+	Description: collectionNote,
+	Tag:         "collections",
+	Body:        CollectionBody{},
+	Status:      201,
+	Response:    collections.Collection{},
+}
+
+var ListCollectionsSpec = openapi.Operation{
+	Summary:     "List your collections",
+	Description: "Most recently changed first. " + collectionNote,
+	Tag:         "collections",
+	Params:      []openapi.Param{limitParam(50, 200), offsetParam},
+	Response:    CollectionList{},
+}
+
+var GetCollectionSpec = openapi.Operation{
+	Summary:     "Get a collection with its items",
+	Description: "Items are in the order they were added. One whose document, entity or pattern has since been deleted or merged away is kept, marked missing. " + collectionNote,
+	Tag:         "collections",
+	Response:    collections.Bundle{},
+}
+
+var UpdateCollectionSpec = openapi.Operation{
+	Summary:     "Rename a collection or replace its notes",
+	Description: "Omitted fields are left as they are. " + collectionNote,
+	Tag:         "collections",
+	Body:        CollectionBody{},
+	Response:    collections.Collection{},
+}
+
+var DeleteCollectionSpec = openapi.Operation{
+	Summary:     "Delete a collection",
+	Description: collectionNote,
+	Tag:         "collections",
+	Status:      204,
+}
+
+var AddCollectionItemSpec = openapi.Operation{
+	Summary:     "Add a document, entity or pattern to a collection",
+	Description: "Adding an item that is already in the collection replaces its note. " + collectionNote,
+	Tag:         "collections",
+	Body:        CollectionItemBody{},
+	Status:      201,
+	Response:    collections.Item{},
+}
+
+var RemoveCollectionItemSpec = openapi.Operation{
+	Summary:     "Remove an item from a collection",
+	Description: collectionNote,
+	Tag:         "collections",
+	Status:      204,
+}
+
+var ShareCollectionSpec = openapi.Operation{
+	Summary:     "Share a collection",
+	Description: "Anyone with the token can read and export the collection at /api/shared/{token}, without an API key. Sharing again returns the same token. " + collectionNote,
+	Tag:         "collections",
+	Response:    ShareLink{},
+}
+
+var UnshareCollectionSpec = openapi.Operation{
+	Summary:     "Stop sharing a collection",
+	Description: "The token stops working; sharing again makes a new one. " + collectionNote,
+	Tag:         "collections",
+	Status:      204,
+}
+
+var bundleFormatParam = openapi.Param{Name: "format", Enum: bundleFormats, Default: "zip", Description: "A zip of collection.json and report.md, or either alone"}
+
+var ExportCollectionSpec = openapi.Operation{
+	Summary:     "Export a collection",
+	Description: "The collection and its items as JSON, and a Markdown report listing each kind of item with its summary and note. " + collectionNote,
+	Tag:         "collections",
+	Params:      []openapi.Param{bundleFormatParam},
+	ContentType: "application/zip",
+}
+
+var shareTokenParam = openapi.Param{Name: "token", In: "path", Description: "Share token"}
+
+var GetSharedCollectionSpec = openapi.Operation{
+	Summary:  "Read a shared collection",
+	Tag:      "collections",
+	Params:   []openapi.Param{shareTokenParam},
+	Response: collections.Bundle{},
+}
+
+var ExportSharedCollectionSpec = openapi.Operation{
+	Summary:     "Export a shared collection",
+	Tag:         "collections",
+	Params:      []openapi.Param{shareTokenParam, bundleFormatParam},
+	ContentType: "application/zip",
+}
+
+const bookmarkNote = "Requires a researcher API key; bookmarks and history are visible only to the key they belong to."
+
+var GetMeSpec = openapi.Operation{
+	Summary:     "Your API key",
+	Description: "Its name and role, and how many bookmarks and viewed items it has. Requires an API key.",
+	Tag:         "bookmarks",
+	Response:    Me{},
+}
+
+var ListBookmarksSpec = openapi.Operation{
+	Summary:     "Your bookmarks, newest first",
+	Description: bookmarkNote,
+	Tag:         "bookmarks",
+	Params:      []openapi.Param{{Name: "type", Enum: bookmarks.Types}, limitParam(50, 200), offsetParam},
+	Response:    BookmarkList{},
+}
+
+var bookmarkTypeParam = openapi.Param{Name: "type", In: "path", Enum: bookmarks.Types}
+
+var PutBookmarkSpec = openapi.Operation{
+	Summary:     "Bookmark a document or entity",
+	Description: "Bookmarking it again replaces the note. " + bookmarkNote,
+	Tag:         "bookmarks",
+	Params:      []openapi.Param{bookmarkTypeParam},
+	Body:        BookmarkBody{},
+	Response:    bookmarks.Bookmark{},
+}
+
+var DeleteBookmarkSpec = openapi.Operation{
+	Summary:     "Remove a bookmark",
+	Description: bookmarkNote,
+	Tag:         "bookmarks",
+	Params:      []openapi.Param{bookmarkTypeParam},
+	Status:      204,
+}
+
+var ListHistorySpec = openapi.Operation{
+	Summary:     "Documents and entities you have viewed, most recent first",
+	Description: "Reading a document or entity with an API key records it here, with when it was first and last viewed and how often. The " + strconv.Itoa(bookmarks.HistorySize) + " most recently viewed are kept. " + bookmarkNote,
+	Tag:         "bookmarks",
+	Params:      []openapi.Param{{Name: "type", Enum: bookmarks.Types}, limitParam(50, 200), offsetParam},
+	Response:    HistoryPage{},
+}
+
+var ClearHistorySpec = openapi.Operation{
+	Summary:     "Clear your reading history",
+	Description: bookmarkNote,
+	Tag:         "bookmarks",
+	Status:      204,
+}
+
+const digestNote = "Requires a researcher API key; subscriptions are visible only to the key that created them."
+
+var SubscribeSpec = openapi.Operation{
+	Summary: "Subscribe an address to email digests",
+	Description: "A daily or weekly email of new documents, newly validated patterns and new documents mentioning the watched entities. " +
+		"Periods with nothing new send nothing. Every digest links to a one-click unsubscribe. " + digestNote,
+	Tag:      "digests",
+	Body:     SubscribeBody{},
+	Status:   201,
+	Response: digest.Subscription{},
+}
+
+var ListSubscriptionsSpec = openapi.Operation{
+	Summary:     "Your digest subscriptions",
+	Description: digestNote,
+	Tag:         "digests",
+	Response:    SubscriptionList{},
+}
+
+var DeleteSubscriptionSpec = openapi.Operation{
+	Summary:     "Delete a digest subscription",
+	Description: digestNote,
+	Tag:         "digests",
+	Status:      204,
+}
+
+var UnsubscribeSpec = openapi.Operation{
+	Summary:     "Unsubscribe from digests",
+	Description: "The link at the bottom of every digest. Needs no API key.",
+	Tag:         "digests",
+	Params:      []openapi.Param{{Name: "token", Required: true, Description: "Unsubscribe token from the digest"}},
+	ContentType: "text/plain",
+}
+
+var GetTrendingSpec = openapi.Operation{
+	Summary: "Trending entities and recent activity",
+	Description: "Everything the landing page shows in one response: the " + strconv.Itoa(trending.Size) + " entities read most over the last 7 days, " +
+		"the most recently updated entities, and the newest documents and pattern findings. Refreshed once a minute. " + conditionalNote,
+	Tag:      "stats",
+	Response: trending.Trending{},
+}
+
+var GetMentionsSpec = openapi.Operation{
+	Summary: "Mentions of entities over time",
+	Description: "Counts the documents mentioning each entity, and the mentions in them, per period of the document's date. " +
+		"Every series covers the same periods, from the first to the last with any mentions, with zeros in between; documents without a date are counted in undated.",
+	Tag: "stats",
+	Params: []openapi.Param{
+		{Name: "entities", Required: true, Description: "Comma-separated entity IDs, at most 20, in the order of the series"},
+		{Name: "interval", Enum: store.MentionIntervals, Default: "month"},
+		{Name: "from", Description: "Earliest document date, YYYY-MM-DD"},
+		{Name: "to", Description: "Latest document date, YYYY-MM-DD"},
+		minConfidenceParam,
+	},
+	Response: models.Mentions{},
+}
+
+var GetSharedAttributesSpec = openapi.Operation{
+	Summary: "Records sharing an address or employer",
+	Description: "Groups PPP borrowers by street address and ZIP code, or FEC donors by the employer they gave (with PPP borrowers and grant recipients of that name), after normalizing case, punctuation, common abbreviations and corporate suffixes. " +
+		"Only groups with at least two different names are listed, those linked to the most entities first. Groups are recomputed with the stats.",
+	Tag: "crossref",
+	Params: []openapi.Param{
+		{Name: "attribute", Required: true, Enum: store.SharedAttributes},
+		{Name: "minEntities", Type: "integer", Default: 1, Description: "Only groups whose records are matched to at least this many entities; 0 lists all"},
+		limitParam(50, 200),
+		offsetParam,
+	},
+	Response: SharedGroupPage{},
+}
+
+var ListShellCandidatesSpec = openapi.Operation{
+	Summary: "Organizations that look like shell companies",
+	Description: "PPP borrowers linked to the corpus, directly or through a shared address or employer, scored from 0 to 1 by the signals listed in signals: a registered agent's or crowded address, loans out of proportion to the jobs reported, no mention in the documents and no grant history. " +
+		"Only candidates scoring at least 0.4 are kept. The analysis runs nightly; each candidate's signals give the reasoning.",
+	Tag: "crossref",
+	Params: []openapi.Param{
+		{Name: "minScore", Type: "number", Default: 0, Description: "Lowest score, 0 to 1"},
+		{Name: "signal", Enum: shells.SignalNames(), Description: "Only candidates showing this signal"},
+		limitParam(50, 200),
+		offsetParam,
+	},
+	Response: ShellCandidatePage{},
+}
+
+var ListAnomaliesSpec = openapi.Operation{
+	Summary: "Outlying contribution and loan amounts",
+	Description: "FEC contributions and PPP loans matched to entities whose amounts stand out, of the kinds listed in kinds: just under a reporting threshold, " +
+		"the same amount given by several contributors to one committee on one day, or loans out of proportion to payroll. Each has a score from 0 to 1 and its reasoning. " +
+		"The detection runs nightly; entities whose anomalies add up are also added to /api/patterns as amount_anomaly hypotheses.",
+	Tag: "crossref",
+	Params: []openapi.Param{
+		{Name: "kind", Enum: anomalies.KindNames(), Description: "Only anomalies of this kind"},
+		{Name: "entity", Type: "integer", Description: "Only anomalies of records matched to this entity"},
+		{Name: "minScore", Type: "number", Default: 0, Description: "Lowest score, 0 to 1"},
+		limitParam(50, 200),
+		offsetParam,
+	},
+	Response: AnomalyPage{},
+}
+
+var QueueAnomalyDetectionSpec = openapi.Operation{
+	Summary:  "Queue an anomaly detection run",
+	Tag:      "admin",
+	Status:   202,
+	Response: QueuedJob{},
+}
+
+var QueueShellAnalysisSpec = openapi.Operation{
+	Summary:  "Queue a shell company analysis",
+	Tag:      "admin",
+	Status:   202,
+	Response: QueuedJob{},
+}
+
+var GetTemporalSettingsSpec = openapi.Operation{
+	Summary:  "Temporal cluster detector settings",
+	Tag:      "admin",
+	Response: patterns.TemporalSettings{},
+}
+
+var UpdateTemporalSettingsSpec = openapi.Operation{
+	Summary: "Tune the temporal cluster detector",
+	Description: "The detector records bursts, windows in which entities named together are unusually active across documents, flights and contributions, " +
+		"as pattern findings of type temporal_cluster. Fields left out keep their current values; the next run uses the new settings.",
+	Tag:      "admin",
+	Body:     patterns.TemporalSettings{},
+	Response: patterns.TemporalSettings{},
+}
+
+var QueuePatternDetectionSpec = openapi.Operation{
+	Summary:     "Queue a run of the pattern detectors",
+	Description: "Unreviewed findings of earlier runs are updated or removed; validated and rejected ones are kept.",
+	Tag:         "admin",
+	Status:      202,
+	Response:    QueuedJob{},
+}
+
+var SubmitTipSpec = openapi.Operation{
+	Summary: "Submit a tip",
+	Description: "Anyone can send a lead, optionally pointing at documents and entities. Tips are queued for moderation and not published. " +
+		"Requires a solved captcha when the server has one configured, and is limited per client per hour. IP addresses are not stored.",
+	Tag:      "tips",
+	Body:     TipBody{},
+	Status:   202,
+	Response: TipReceipt{},
+}
+
+var ListTipsSpec = openapi.Operation{
+	Summary:  "Tip moderation queue, oldest first",
+	Tag:      "admin",
+	Params:   []openapi.Param{{Name: "status", Enum: tips.Statuses}, limitParam(50, 200), offsetParam},
+	Response: TipList{},
+}
+
+var GetTipSpec = openapi.Operation{
+	Summary:  "Get a tip",
+	Tag:      "admin",
+	Response: tips.Tip{},
+}
+
+var ReviewTipSpec = openapi.Operation{
+	Summary:  "Accept, reject or requeue a tip",
+	Tag:      "admin",
+	Body:     TipReviewBody{},
+	Response: tips.Tip{},
+}
+
+var ConvertTipSpec = openapi.Operation{
+	Summary:     "Make a pattern finding from a tip",
+	Description: "Creates a hypothesis pattern finding over the tip's entities, with the tip's text and documents as evidence, and accepts the tip. 409 if the tip already has one.",
+	Tag:         "admin",
+	Body:        TipPatternBody{},
+	Status:      201,
+	Response:    tips.Tip{},
+}
+
+var RequestCorrectionSpec = openapi.Operation{
+	Summary: "Dispute an entity's linkage or its association with documents",
+	Description: "For subjects and their counsel. Opens a case that admins review; until it is resolved the entity, or the documents and their links to it, read as disputed, and as corrected once it is upheld. " +
+		"The response's token follows the case at /api/requests/correction/{id}. " +
+		"Requires a solved captcha when the server has one configured, and is limited per client per hour. IP addresses are not stored.",
+	Tag:      "corrections",
+	Body:     CorrectionBody{},
+	Status:   202,
+	Response: CorrectionReceipt{},
+}
+
+var GetCorrectionCaseSpec = openapi.Operation{
+	Summary:     "Follow a correction request",
+	Description: "The case's status and the resolution admins left for the submitter. 404 for an unknown case or a wrong token.",
+	Tag:         "corrections",
+	Params:      []openapi.Param{{Name: "token", Required: true, Description: "Token returned when the request was made"}},
+	Response:    corrections.Case{},
+}
+
+var ListCorrectionsSpec = openapi.Operation{
+	Summary:  "Correction request queue, oldest first",
+	Tag:      "admin",
+	Params:   []openapi.Param{{Name: "status", Enum: corrections.Statuses}, limitParam(50, 200), offsetParam},
+	Response: CorrectionList{},
+}
+
+var GetCorrectionSpec = openapi.Operation{
+	Summary:  "Get a correction request with its history",
+	Tag:      "admin",
+	Response: corrections.Request{},
+}
+
+var ReviewCorrectionSpec = openapi.Operation{
+	Summary: "Review, uphold, reject or reopen a correction request",
+	Description: "Each step is kept in the case's history with its note. Upholding a request marks the records it names corrected; making the correction itself, " +
+		"such as deleting a document link or protecting the entity, is done with the other admin endpoints.",
+	Tag:      "admin",
+	Body:     CorrectionReviewBody{},
+	Response: corrections.Request{},
+}
+
+var ListStoplistSpec = openapi.Operation{
+	Summary:     "Names kept out of the entity graph, newest first",
+	Description: "Blocked names, dismissed ones, and suggestions awaiting review: names in a large share of a dataset's documents, made nightly by `worker schedule`.",
+	Tag:         "admin",
+	Params:      []openapi.Param{{Name: "status", Enum: stoplist.Statuses}, limitParam(50, 200), offsetParam},
+	Response:    StoplistList{},
+}
+
+var BlockNameSpec = openapi.Operation{
+	Summary: "Block a name",
+	Description: "Puts a name on the stoplist, or blocks one already there, and removes the document links and triples of the entities with it. " +
+		"Names match after normalization, so case, accents and punctuation don't matter. Extraction skips blocked names from then on.",
+	Tag:      "admin",
+	Body:     StoplistBody{},
+	Status:   201,
+	Response: StoplistChange{},
+}
+
+var ReviewStoplistSpec = openapi.Operation{
+	Summary:     "Block or dismiss a stoplist entry",
+	Description: "Blocking removes the document links and triples of the entities with the name. Dismissing a blocked name stops suppressing it, but doesn't restore what was removed; re-extract the documents for that.",
+	Tag:         "admin",
+	Body:        StoplistReviewBody{},
+	Response:    StoplistChange{},
+}
+
+var ListTrashSpec = openapi.Operation{
+	Summary:     "Deleted documents and entities, most recently deleted first",
+	Description: "Everything soft-deleted and not yet restored, with who deleted it, why, and how many document-entity links were set aside with it.",
+	Tag:         "admin",
+	Params:      []openapi.Param{{Name: "kind", Enum: trash.Kinds}, limitParam(50, 200), offsetParam},
+	Response:    TrashList{},
+}
+
+var DeleteDocumentSpec = openapi.Operation{
+	Summary: "Delete a document",
+	Description: "Soft-deletes the document: it keeps its row but leaves search, listings, the network and every count until restored. " +
+		"Its entity links are set aside and the counts of the entities it mentions corrected. 409 if it is already deleted.",
+	Tag:      "admin",
+	Body:     DeleteBody{},
+	Response: trash.Item{},
+}
+
+var RestoreDocumentSpec = openapi.Operation{
+	Summary:     "Restore a deleted document",
+	Description: "Undeletes the document and puts back its entity links, except those to entities that are themselves deleted.",
+	Tag:         "admin",
+	Response:    trash.Item{},
+}
+
+var DeleteEntitySpec = openapi.Operation{
+	Summary: "Delete an entity",
+	Description: "Soft-deletes the entity: it keeps its row but leaves search, connections, the network and every count until restored. " +
+		"Its document links are set aside and the counts of the entities it co-occurred with corrected. 409 if it is already deleted.",
+	Tag:      "admin",
+	Body:     DeleteBody{},
+	Response: trash.Item{},
+}
+
+var RestoreEntitySpec = openapi.Operation{
+	Summary:     "Restore a deleted entity",
+	Description: "Undeletes the entity and puts back its document links, except those to documents that are themselves deleted.",
+	Tag:         "admin",
+	Response:    trash.Item{},
+}
+
+var ListProtectedSpec = openapi.Operation{
+	Summary:     "Protected entities, most recently protected first",
+	Description: "Entities, such as identified victims, that are kept out of search, connections, the network and exports, with who protected them and why.",
+	Tag:         "admin",
+	Params:      []openapi.Param{limitParam(50, 200), offsetParam},
+	Response:    ProtectedList{},
+}
+
+var ProtectEntitySpec = openapi.Operation{
+	Summary: "Protect an entity or lift its protection",
+	Description: "A protected entity is left out of entity search, connections, the network, triples, GraphQL, gRPC and snapshots, " +
+		"and its canonical name and aliases are masked as \"[protected]\" in document text, summaries, snippets, chunks and quotes. " +
+		"Its rows and links are kept, so protection can be lifted.",
+	Tag:      "admin",
+	Body:     ProtectBody{},
+	Response: models.ProtectedEntity{},
+}
+
+var GetDescriptionHistorySpec = openapi.Operation{
+	Summary: "Revisions of an entity's description, newest first",
+	Description: "Each revision records whether a person or a model wrote it, what it is based on and the API key that saved it. " +
+		"Descriptions written by extraction are recorded without a key.",
+	Tag:      "admin",
+	Response: DescriptionHistory{},
+}
+
+var GetDescriptionDiffSpec = openapi.Operation{
+	Summary: "Compare two revisions of an entity's description",
+	Description: "A word-by-word diff from one revision to another: runs of text kept, inserted and deleted. " +
+		"Compares the latest revision with the one before it by default.",
+	Tag: "admin",
+	Params: []openapi.Param{
+		{Name: "from", Type: "integer", Description: "Older revision; 0 compares with no description. Defaults to the one before to"},
+		{Name: "to", Type: "integer", Description: "Newer revision; defaults to the latest"},
+	},
+	Response: DescriptionDiff{},
+}
+
+var UpdateDescriptionSpec = openapi.Operation{
+	Summary: "Edit an entity's description",
+	Description: "Saves the description as a new revision, attributed to the caller's key, and makes it current. " +
+		"Give baseRevision to be refused with 409 rather than overwrite someone else's edit.",
+	Tag:      "admin",
+	Body:     DescriptionBody{},
+	Response: descriptions.Revision{},
+}
+
+var RevertDescriptionSpec = openapi.Operation{
+	Summary:     "Restore an earlier description",
+	Description: "Saves the text, origin and attribution of an earlier revision as a new revision; history is kept.",
+	Tag:         "admin",
+	Body:        DescriptionRevertBody{},
+	Response:    descriptions.Revision{},
+}
+
+// missingParam is the field a worklist is about
+var missingParam = openapi.Param{Name: "missing", Enum: worklist.Fields, Required: true,
+	Description: "summary, dates, type or entities: documents with no summary, neither date, no type or no linked entity"}
+
+var GetWorklistSpec = openapi.Operation{
+	Summary: "Documents missing a derived field",
+	Description: "Documents lacking the given field, oldest first, with who has claimed them and how many each dataset is missing, " +
+		"for prioritizing curation and pipeline reruns. claim=mine lists the caller's claims.",
+	Tag: "admin",
+	Params: []openapi.Param{
+		missingParam,
+		{Name: "dataset", Type: "integer"},
+		{Name: "claim", Enum: worklist.ClaimFilters, Description: "Only unclaimed documents, claimed ones, or the caller's"},
+		limitParam(50, 500), offsetParam,
+	},
+	Response: worklist.Page{},
+}
+
+var ClaimWorklistSpec = openapi.Operation{
+	Summary: "Claim or assign worklist documents",
+	Description: "Claims the missing field of up to 200 documents for the caller, or assigns it to keyId, for seven days. " +
+		"Documents another key holds are reported as taken unless reassign is set; ones no longer missing the field as complete.",
+	Tag:      "admin",
+	Body:     WorklistClaimBody{},
+	Response: worklist.ClaimResult{},
+}
+
+var ReleaseWorklistClaimSpec = openapi.Operation{
+	Summary: "Release a worklist claim",
+	Tag:     "admin",
+	Params:  []openapi.Param{missingParam},
+	Status:  204,
+}
+
+var ListJobsSpec = openapi.Operation{
+	Summary: "List background jobs",
+	Tag:     "jobs",
+	Params: []openapi.Param{
+		{Name: "kind"},
+		{Name: "status", Enum: jobStatuses},
+		limitParam(50, 200),
+	},
+	Response: JobList{},
+}
+
+var GetJobSpec = openapi.Operation{
+	Summary:  "Get a job and its progress",
+	Tag:      "jobs",
+	Response: jobs.Job{},
+}
+
+var GetJobUsageSpec = openapi.Operation{
+	Summary:  "Model tokens and estimated cost of a job",
+	Tag:      "jobs",
+	Response: JobUsage{},
+}
+
+var QueueSummarizationSpec = openapi.Operation{
+	Summary:  "Queue document summarization",
+	Tag:      "admin",
+	Body:     summarize.Params{},
+	Status:   202,
+	Response: QueuedJob{},
+}
+
+var QueueRoleInferenceSpec = openapi.Operation{
+	Summary:     "Queue entity role inference",
+	Description: "Classifies the role each person and organization plays in the documents that mention them. Already classified mentions are skipped unless force is set.",
+	Tag:         "admin",
+	Body:        roles.Params{},
+	Status:      202,
+	Response:    QueuedJob{},
+}
+
+var QueueEmbeddingSpec = openapi.Operation{
+	Summary:  "Queue embedding of stale documents",
+	Tag:      "admin",
+	Body:     embeddings.Params{},
+	Status:   202,
+	Response: QueuedJob{},
+}
+
+var GetAuditLogSpec = openapi.Operation{
+	Summary: "Recorded admin actions",
+	Tag:     "admin",
+	Params: []openapi.Param{
+		{Name: "action", Description: "Substring of the action, e.g. recount"},
+		{Name: "key", Type: "integer", Description: "API key ID of the actor"},
+		limitParam(50, 500),
+		offsetParam,
+	},
+	Response: AuditLog{},
+}
+
+var GetConfigSpec = openapi.Operation{
+	Summary:  "Server configuration with secrets redacted",
+	Tag:      "admin",
+	Response: config.Config{},
+}
+
+var GetEmbeddingStatusSpec = openapi.Operation{
+	Summary:  "Embedded and stale document counts",
+	Tag:      "admin",
+	Response: embeddings.Status{},
+}
+
+var GetQualityReportSpec = openapi.Operation{
+	Summary:  "Latest data quality report",
+	Tag:      "admin",
+	Params:   []openapi.Param{{Name: "refresh", Type: "boolean", Description: "Run the checks before responding"}},
+	Response: quality.Report{},
+}
+
+var GetDateReportSpec = openapi.Operation{
+	Summary: "Document date normalization report",
+	Description: "How many documents have dates, how many were dated from the dates their text mentions rather than by analysis, " +
+		"and the precision and confidence of those found, with sample IDs of documents whose dates are ambiguous, " +
+		"too uncertain to fill or at odds with their text. Dates are found on ingestion and nightly by `worker dates`.",
+	Tag:      "admin",
+	Response: dates.Report{},
+}
+
+var GetQualityHistorySpec = openapi.Operation{
+	Summary:  "Per-check counts of recent quality reports",
+	Tag:      "admin",
+	Params:   []openapi.Param{limitParam(30, 365)},
+	Response: QualityHistory{},
+}
+
+var QueueQualityReportSpec = openapi.Operation{
+	Summary:  "Queue a quality report",
+	Tag:      "admin",
+	Status:   202,
+	Response: QueuedJob{},
+}
+
+var RecountEntitiesSpec = openapi.Operation{
+	Summary:     "Recompute entity document and connection counts",
+	Description: "Dry runs return the report immediately; otherwise a job is queued and 202 returned.",
+	Tag:         "admin",
+	Body:        recount.Options{},
+	Status:      202,
+	Response:    QueuedJob{},
+}
+
+var PurgeCacheSpec = openapi.Operation{
+	Summary:     "Purge the CDN",
+	Description: "Drops cached responses tagged with any of the surrogate keys, or everything. Responses are tagged with their route family (stats, network, entities, documents, meta, triples, crossref, patterns, timeline, search) and the entity-<id>, document-<id> or pattern-<id> they show. `worker cdn` purges as data changes, so this is for the odd manual fix. 503 without CDN_PROVIDER.",
+	Tag:         "admin",
+	Body:        PurgeBody{},
+	Response:    PurgeResult{},
+}
+
+var ListChangesSpec = openapi.Operation{
+	Summary: "Changes to documents, entities, triples, patterns and cross-reference matches, oldest first",
+	Description: "Each change names a row and whether it was inserted, updated or deleted; fetch the row to apply it. " +
+		"Start from the changeCursor of a snapshot, or with no since to read the whole retained log. " +
+		"A since older than the retained log gets 410 gone.",
+	Tag: "changes",
+	Params: []openapi.Param{
+		{Name: "since", Description: "Cursor from a previous response or a snapshot manifest"},
+		{Name: "tables", Description: "Comma-separated tables to include: " + strings.Join(changes.Tables, ", ")},
+		limitParam(500, 5000),
+	},
+	Response: ChangePage{},
+}
+
+var QueueSnapshotSpec = openapi.Operation{
+	Summary:     "Queue a database snapshot",
+	Description: "Exports documents (without file paths or processing state), entities, mentions, triples and cross-references as one SQLite file or a zip of Parquet files. Defaults to SQLite.",
+	Tag:         "admin",
+	Body:        snapshot.Params{},
+	Status:      202,
+	Response:    QueuedJob{},
+}
+
+var ListSnapshotsSpec = openapi.Operation{
+	Summary:  "Written database snapshots, newest first",
+	Tag:      "admin",
+	Response: SnapshotList{},
+}
+
+var DownloadSnapshotSpec = openapi.Operation{
+	Summary:     "Download a database snapshot",
+	Description: "The Digest header carries the file's SHA-256, as listed.",
+	Tag:         "admin",
+	Params:      []openapi.Param{{Name: "name", In: "path", Description: "Snapshot name, e.g. epstein-db-20260101T000000Z.sqlite"}},
+	ContentType: "application/octet-stream",
+}
+
+var CreateExportSpec = openapi.Operation{
+	Summary:     "Queue a bulk export",
+	Description: "Writes every document, entity or triple matching the filters to a gzipped NDJSON or CSV file in the background, leaving out deleted rows and protected entities. Poll the export at the Location returned for its progress and, once completed, a signed download link. Requires a researcher key.",
+	Tag:         "exports",
+	Body:        ExportBody{},
+	Status:      202,
+	Response:    ExportStatus{},
+}
+
+var GetExportSpec = openapi.Operation{
+	Summary:     "Get an export's progress and download link",
+	Description: "Each fetch of a completed export signs a fresh downloadUrl. Only the key that requested the export, or an admin, can see it.",
+	Tag:         "exports",
+	Response:    ExportStatus{},
+}
+
+var DownloadExportSpec = openapi.Operation{
+	Summary:     "Download an export",
+	Description: "Linked as downloadUrl, signed so that it needs no API key; 403 once the link expires and 410 once the file has been cleaned up. The Digest header carries the file's SHA-256. With object storage configured it redirects (302) to a presigned link to the file in the bucket.",
+	Tag:         "exports",
+	Params: []openapi.Param{
+		{Name: "expires", Required: true, Description: "Set by the signed link"},
+		{Name: "signature", Required: true, Description: "Set by the signed link"},
+	},
+	ContentType: "application/gzip",
+}
+
+var CreateReportSpec = openapi.Operation{
+	Summary:     "Queue a report on a set of entities",
+	Description: "Builds a report in the background on up to 50 entities: the documents naming them, the documents they share, the relationships between them and their closest connections, the money flowing to and from them, and their timeline. With a date range, only dated documents, flows and events within it are kept. Poll the report at the Location returned; once completed, download it as JSON, Markdown or PDF. Requires a researcher key.",
+	Tag:         "reports",
+	Body:        ReportBody{},
+	Status:      202,
+	Response:    ReportStatus{},
+}
+
+var GetReportSpec = openapi.Operation{
+	Summary:     "Get a report's progress, and the report once built",
+	Description: "Only the key that requested the report, or an admin, can see it.",
+	Tag:         "reports",
+	Response:    ReportStatus{},
+}
+
+var DownloadReportSpec = openapi.Operation{
+	Summary:     "Download a report",
+	Description: "As JSON, Markdown linking to the documents and entities it names, or a PDF of the same text. 409 until the report has completed. Only the key that requested the report, or an admin, can download it.",
+	Tag:         "reports",
+	Params:      []openapi.Param{{Name: "format", Enum: reportFormats, Default: "json"}},
+	Response:    reports.Report{},
+}
+
+var UploadScanSpec = openapi.Operation{
+	Summary: "Upload a scanned PDF or image for OCR",
+	Tag:     "admin",
+	Form: []openapi.Param{
+		{Name: "file", Type: "file", Required: true},
+		{Name: "docId", Required: true, Description: "Document ID, e.g. EFTA00009001"},
+		{Name: "datasetId", Type: "integer", Required: true},
+	},
+	Status:   202,
+	Response: QueuedJob{},
+}
+
+var BatchSpec = openapi.Operation{
+	Summary:     "Run several GET requests in one round trip",
+	Description: "Sub-requests run concurrently with the caller's headers, so each is authenticated and rate limited as if sent alone. Responses are in request order. Streams, exports, files and downloads can't be batched.",
+	Tag:         "batch",
+	Body:        BatchBody{},
+	Response:    BatchResults{},
+}
+
+var StreamEventsSpec = openapi.Operation{
+	Summary: "Live updates as server-sent events",
+	Description: "Each SSE message's event field is the event type and its data is the event below. Types are " +
+		strings.Join([]string{events.DatasetReady, events.DatasetStatus, events.JobProgress, events.JobCompleted, events.JobFailed, events.PatternCreated, events.EntityUpdated}, ", ") + ".",
+	Tag:         "events",
+	Params:      []openapi.Param{{Name: "types", Description: "Comma-separated event types or prefixes to receive, e.g. job,pattern.created"}},
+	Response:    events.Event{},
+	ContentType: "text/event-stream",
+}
+
+var DocumentsFeedSpec = openapi.Operation{
+	Summary:     "Atom feed of new documents",
+	Description: "The " + strconv.Itoa(feeds.Size) + " most recently added documents, each with its summary, type and dataset. Entry IDs are the documents' API URLs. " + conditionalNote,
+	Tag:         "feeds",
+	Params:      []openapi.Param{{Name: "dataset", Type: "integer", Description: "Only documents in this dataset"}},
+	ContentType: feeds.MediaAtom,
+}
+
+var PatternsFeedSpec = openapi.Operation{
+	Summary:     "Atom feed of validated patterns",
+	Description: "The " + strconv.Itoa(feeds.Size) + " most recently validated pattern findings, with their description and confidence. Entry IDs are the patterns' API URLs. " + conditionalNote,
+	Tag:         "feeds",
+	ContentType: feeds.MediaAtom,
+}
+
+var SitemapIndexSpec = openapi.Operation{
+	Summary:     "Sitemap index",
+	Description: "Lists the sitemap pages of entity and document permalinks, each covering " + strconv.Itoa(seo.PageSize) + " IDs, with when something in it last changed. " + conditionalNote,
+	Tag:         "seo",
+	ContentType: "application/xml",
+}
+
+var SitemapPageSpec = openapi.Operation{
+	Summary:     "One sitemap page",
+	Description: "Web app permalinks of the entities or documents with IDs in the page's range, with their last modification time. Empty pages are 404. " + conditionalNote,
+	Tag:         "seo",
+	Params:      []openapi.Param{{Name: "kind", In: "path", Enum: seo.Kinds}, {Name: "page", In: "path", Description: "Page number, from 1"}},
+	ContentType: "application/xml",
+}
+
+var GetEntityTypesSpec = openapi.Operation{
+	Summary:     "Entity types with counts",
+	Description: "Every value the type parameter of /api/entities accepts, in a fixed order, including types no entity has yet. Counts are as of the last stats refresh.",
+	Tag:         "stats",
+	Response:    TypeList{},
+}
+
+var GetDocumentTypesSpec = openapi.Operation{
+	Summary:     "Document types with counts",
+	Description: "The document types in use, most common first, for the type parameter of /api/documents. Counts are as of the last stats refresh.",
+	Tag:         "stats",
+	Response:    TypeList{},
+}
+
+var ListTagsSpec = openapi.Operation{
+	Summary:     "Content tags with counts",
+	Description: "The content tags on documents, whether extracted or added by admins, most common first, for the tag parameter of /api/documents and /api/search.",
+	Tag:         "stats",
+	Params:      []openapi.Param{limitParam(100, 1000), offsetParam},
+	Response:    TagList{},
+}
+
+var TagDocumentSpec = openapi.Operation{
+	Summary:     "Add and remove a document's content tags",
+	Description: "Tags are 1 to 50 characters, at most 20 per request, and kept in alphabetical order.",
+	Tag:         "admin",
+	Body:        TagChangeBody{},
+	Response:    DocumentTags{},
+}
+
+var BulkTagSpec = openapi.Operation{
+	Summary: "Add and remove content tags on many documents",
+	Description: "Changes every document a full-text search for q finds within the scope given by dataset, type, entityId and tag, not only the first page of results; " +
+		"without q, every document in scope. At least one of them is required.",
+	Tag:      "admin",
+	Body:     BulkTagBody{},
+	Response: BulkTagResult{},
+}
+
+var GetMetaSpec = openapi.Operation{
+	Summary:     "Title and description of an entity or document",
+	Description: "For rendering link previews and search snippets server-side: a title, a description of at most " + strconv.Itoa(seo.DescriptionLength) + " characters and the web app permalink. " + conditionalNote,
+	Tag:         "seo",
+	Params:      []openapi.Param{{Name: "type", In: "path", Enum: seo.Types}},
+	Response:    seo.Meta{},
+}
+
+var LiveSpec = openapi.Operation{
+	Summary:  "Liveness: the process is up",
+	Tag:      "health",
+	Response: HealthStatus{},
+}
+
+var ReadySpec = openapi.Operation{
+	Summary:     "Readiness: dependency checks and pool usage",
+	Description: "Answers 503 when Postgres or another configured required dependency is down. An unreachable read replica only degrades the status, since reads fall back to the primary.",
+	Tag:         "health",
+	Response:    HealthStatus{},
+}
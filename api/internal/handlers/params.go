@@ -0,0 +1,242 @@
+package handlers
+
+import (
+	"errors"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/subculture-collective/epstein-db/api/internal/apierr"
+	"github.com/subculture-collective/epstein-db/api/internal/models"
+	"github.com/subculture-collective/epstein-db/api/internal/store"
+)
+
+// Query and path parameter validation. Each helper returns an
+// apierr.InvalidParam error naming the parameter, so handlers can return it
+// unchanged.
+
+// idParam parses the :id path parameter
+func idParam(c *fiber.Ctx) (int, error) {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil || id <= 0 {
+		return 0, apierr.InvalidParam("id", "must be a positive integer")
+	}
+	return id, nil
+}
+
+// limitQuery parses ?limit=, defaulting to def. Values above max are capped
+// rather than rejected, as documented on each endpoint.
+func limitQuery(c *fiber.Ctx, def, max int) (int, error) {
+	limit, err := intQuery(c, "limit", def)
+	if err != nil {
+		return 0, err
+	}
+	if limit < 1 {
+		return 0, apierr.InvalidParam("limit", "must be a positive integer")
+	}
+	return min(limit, max), nil
+}
+
+// offsetQuery parses ?offset=, defaulting to 0
+func offsetQuery(c *fiber.Ctx) (int, error) {
+	offset, err := intQuery(c, "offset", 0)
+	if err != nil {
+		return 0, err
+	}
+	if offset < 0 {
+		return 0, apierr.InvalidParam("offset", "must not be negative")
+	}
+	return offset, nil
+}
+
+// intQuery parses an integer parameter, returning def when it is absent
+func intQuery(c *fiber.Ctx, name string, def int) (int, error) {
+	v := c.Query(name)
+	if v == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, apierr.InvalidParam(name, "must be an integer")
+	}
+	return n, nil
+}
+
+// fractionQuery parses a number between 0 and 1, returning def when it is
+// absent
+func fractionQuery(c *fiber.Ctx, name string, def float64) (float64, error) {
+	v := c.Query(name)
+	if v == "" {
+		return def, nil
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil || f < 0 || f > 1 {
+		return 0, apierr.InvalidParam(name, "must be a number between 0 and 1")
+	}
+	return f, nil
+}
+
+// idQuery parses an optional ID parameter, returning 0 when it is absent
+func idQuery(c *fiber.Ctx, name string) (int, error) {
+	id, err := intQuery(c, name, 0)
+	if err != nil || id < 0 {
+		return 0, apierr.InvalidParam(name, "must be a positive integer")
+	}
+	return id, nil
+}
+
+// idsQuery parses an optional comma-separated list of up to max IDs
+func idsQuery(c *fiber.Ctx, name string, max int) ([]int, error) {
+	v := c.Query(name)
+	if v == "" {
+		return nil, nil
+	}
+	parts := strings.Split(v, ",")
+	if len(parts) > max {
+		return nil, apierr.InvalidParam(name, "must have at most "+strconv.Itoa(max)+" IDs")
+	}
+	ids := make([]int, 0, len(parts))
+	for _, p := range parts {
+		id, err := strconv.Atoi(strings.TrimSpace(p))
+		if err != nil || id < 1 {
+			return nil, apierr.InvalidParam(name, "must be comma-separated positive integers")
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// enumQuery returns an optional parameter after checking it is one of allowed
+func enumQuery(c *fiber.Ctx, name string, allowed []string) (string, error) {
+	v := c.Query(name)
+	if v != "" && !slices.Contains(allowed, v) {
+		err := apierr.InvalidParam(name, "must be one of "+strings.Join(allowed, ", "))
+		err.Details = fiber.Map{"param": name, "allowed": allowed}
+		return "", err
+	}
+	return v, nil
+}
+
+// fieldsQuery parses the optional comma-separated fields parameter. Nil
+// means every field.
+func fieldsQuery(c *fiber.Ctx, allowed []string) ([]string, error) {
+	v := c.Query("fields")
+	if v == "" {
+		return nil, nil
+	}
+
+	var fields []string
+	for _, f := range strings.Split(v, ",") {
+		f = strings.TrimSpace(f)
+		if f == "" {
+			continue
+		}
+		if !slices.Contains(allowed, f) {
+			err := apierr.InvalidParam("fields", "unknown field "+f)
+			err.Details = fiber.Map{"param": "fields", "allowed": allowed}
+			return nil, err
+		}
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
+
+// formatQuery picks the response format: ?format= if given, otherwise CSV
+// when the Accept header prefers it, otherwise JSON
+func formatQuery(c *fiber.Ctx) (string, error) {
+	format, err := enumQuery(c, "format", formats)
+	if err != nil || format != "" {
+		return format, err
+	}
+	if c.Accepts(fiber.MIMEApplicationJSON, mimeCSV) == mimeCSV {
+		return "csv", nil
+	}
+	return "json", nil
+}
+
+// sortQuery parses the optional sort and order parameters against a list's
+// sort keys
+func sortQuery(c *fiber.Ctx, sorts store.Sorts) (store.Sort, error) {
+	key, err := enumQuery(c, "sort", sorts.Keys())
+	if err != nil {
+		return store.Sort{}, err
+	}
+	order, err := enumQuery(c, "order", sortOrders)
+	if err != nil {
+		return store.Sort{}, err
+	}
+
+	s := store.Sort{Key: key}
+	if order != "" {
+		desc := order == "desc"
+		s.Desc = &desc
+	}
+	return s, nil
+}
+
+// dateQuery parses an optional YYYY-MM-DD parameter
+func dateQuery(c *fiber.Ctx, name string) (*time.Time, error) {
+	v := c.Query(name)
+	if v == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.DateOnly, v)
+	if err != nil {
+		return nil, apierr.InvalidParam(name, "must be a date in YYYY-MM-DD format")
+	}
+	return &t, nil
+}
+
+// warningsLocal holds the request's store.Warnings
+const warningsLocal = "warnings"
+
+// CollectWarnings lets the store report rows it had to skip while serving
+// the request, so list responses can say they're incomplete
+func CollectWarnings(c *fiber.Ctx) error {
+	ctx, w := store.WithWarnings(c.UserContext())
+	c.SetUserContext(ctx)
+	c.Locals(warningsLocal, w)
+	return c.Next()
+}
+
+// ConfidenceFilter applies the minConfidence parameter, which leaves
+// document–entity links extracted with less confidence out of any analysis
+// the request runs
+func ConfidenceFilter(c *fiber.Ctx) error {
+	min, err := fractionQuery(c, "minConfidence", 0)
+	if err != nil {
+		return err
+	}
+	if min > 0 {
+		c.SetUserContext(store.WithMinConfidence(c.UserContext(), min))
+	}
+	return c.Next()
+}
+
+// SensitivityFilter applies the excludeSensitive parameter, which leaves
+// documents with content warnings out of any listing or search the request
+// runs
+func SensitivityFilter(c *fiber.Ctx) error {
+	if c.QueryBool("excludeSensitive", false) {
+		c.SetUserContext(store.WithoutSensitive(c.UserContext()))
+	}
+	return c.Next()
+}
+
+// skipped returns the rows the store skipped for this request
+func skipped(c *fiber.Ctx) []models.Warning {
+	w, _ := c.Locals(warningsLocal).(*store.Warnings)
+	return w.List()
+}
+
+// notFound turns a missing row into a 404 for what, passing other errors on
+func notFound(err error, what string) error {
+	if errors.Is(err, pgx.ErrNoRows) || errors.Is(err, store.ErrNotFound) {
+		return apierr.NotFound(what)
+	}
+	return err
+}
@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/subculture-collective/epstein-db/api/internal/apierr"
+	"github.com/subculture-collective/epstein-db/api/internal/auth"
+	"github.com/subculture-collective/epstein-db/api/internal/models"
+)
+
+// maxProtectReason bounds the reason given for protecting an entity
+const maxProtectReason = 1000
+
+// ProtectBody is the body of PUT /api/admin/entities/:id/protected
+type ProtectBody struct {
+	Protected bool   `json:"protected"`
+	Reason    string `json:"reason,omitempty" doc:"Why it is protected, such as an identified victim"`
+}
+
+// ProtectedList is one page of protected entities
+type ProtectedList struct {
+	Entities []models.ProtectedEntity `json:"entities"`
+	Count    int                      `json:"count"`
+	Offset   int                      `json:"offset"`
+	Limit    int                      `json:"limit"`
+}
+
+// ListProtected returns the protected entities
+func ListProtected(c *fiber.Ctx) error {
+	limit, err := limitQuery(c, 50, 200)
+	if err != nil {
+		return err
+	}
+	offset, err := offsetQuery(c)
+	if err != nil {
+		return err
+	}
+
+	entities, err := data.ProtectedEntities(c.UserContext(), limit, offset)
+	if err != nil {
+		return err
+	}
+	return c.JSON(ProtectedList{Entities: entities, Count: len(entities), Offset: offset, Limit: limit})
+}
+
+// ProtectEntity protects an entity, or lifts its protection
+func ProtectEntity(c *fiber.Ctx) error {
+	id, err := idParam(c)
+	if err != nil {
+		return err
+	}
+	var body ProtectBody
+	if err := c.BodyParser(&body); err != nil {
+		return apierr.BadRequest("invalid body")
+	}
+	reason := strings.TrimSpace(body.Reason)
+	if len(reason) > maxProtectReason {
+		return apierr.InvalidParam("reason", "must be at most 1000 characters")
+	}
+
+	e, err := data.SetEntityProtected(c.UserContext(), id, body.Protected, auth.FromContext(c).KeyID, reason)
+	if err != nil {
+		return notFound(err, "entity")
+	}
+	return c.JSON(e)
+}
@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5"
+	"github.com/subculture-collective/epstein-db/api/internal/audit"
+	"github.com/subculture-collective/epstein-db/api/internal/dates"
+	"github.com/subculture-collective/epstein-db/api/internal/db"
+	"github.com/subculture-collective/epstein-db/api/internal/jobs"
+	"github.com/subculture-collective/epstein-db/api/internal/quality"
+)
+
+// GetQualityReport returns the latest data quality report, running the
+// checks first when refresh=true or no report exists yet
+func GetQualityReport(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+	pool := db.Pool()
+
+	report, err := quality.Latest(ctx, pool)
+	if errors.Is(err, pgx.ErrNoRows) || c.QueryBool("refresh", false) {
+		report, err = quality.Run(ctx, pool)
+	}
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(report)
+}
+
+// GetQualityHistory returns per-check counts for recent reports so that
+// ingestion regressions stand out
+func GetQualityHistory(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+
+	limit, err := limitQuery(c, 30, 365)
+	if err != nil {
+		return err
+	}
+
+	reports, err := quality.History(ctx, db.Pool(), limit)
+	if err != nil {
+		return err
+	}
+
+	var history []QualityHistoryEntry
+	for _, r := range reports {
+		counts := map[string]int{}
+		for _, check := range r.Checks {
+			counts[check.Name] = check.Count
+		}
+		history = append(history, QualityHistoryEntry{
+			ID:          r.ID,
+			GeneratedAt: r.GeneratedAt,
+			Failing:     r.Failing,
+			Counts:      counts,
+		})
+	}
+
+	return c.JSON(QualityHistory{
+		Reports: history,
+		Count:   len(history),
+	})
+}
+
+// GetDateReport shows how many documents are dated, how the dates were
+// found and which documents to check by hand
+func GetDateReport(c *fiber.Ctx) error {
+	report, err := dates.BuildReport(c.UserContext(), db.Pool())
+	if err != nil {
+		return err
+	}
+	return c.JSON(report)
+}
+
+// QueueQualityReport queues a background recomputation of the checks
+func QueueQualityReport(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+
+	id, err := jobs.NewQueue(db.Pool()).Enqueue(ctx, quality.JobKind, struct{}{})
+	if err != nil {
+		return err
+	}
+
+	audit.SetJob(c, id)
+
+	return c.Status(202).JSON(QueuedJob{
+		JobID:  id,
+		Status: jobs.StatusQueued,
+	})
+}
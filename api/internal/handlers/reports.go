@@ -0,0 +1,204 @@
+package handlers
+
+import (
+	"bytes"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/subculture-collective/epstein-db/api/internal/apierr"
+	"github.com/subculture-collective/epstein-db/api/internal/auth"
+	"github.com/subculture-collective/epstein-db/api/internal/db"
+	"github.com/subculture-collective/epstein-db/api/internal/jobs"
+	"github.com/subculture-collective/epstein-db/api/internal/reports"
+)
+
+// reportFormats are the formats a report can be downloaded in
+var reportFormats = []string{"json", "markdown", "pdf"}
+
+// ReportBody is the body of POST /api/reports
+type ReportBody struct {
+	Title            string   `json:"title,omitempty" doc:"Defaults to the entities' names"`
+	EntityIDs        []int    `json:"entityIds" doc:"1 to 50 entities"`
+	From             string   `json:"from,omitempty" doc:"Earliest date covered, YYYY-MM-DD"`
+	To               string   `json:"to,omitempty" doc:"Latest date covered, YYYY-MM-DD"`
+	Sections         []string `json:"sections,omitempty" doc:"Any of documents, network, financials and timeline; defaults to all"`
+	ExcludeSensitive bool     `json:"excludeSensitive,omitempty" doc:"Leave out documents with content warnings"`
+}
+
+// ReportStatus is a report job as its requester sees it
+type ReportStatus struct {
+	ID           int64           `json:"id"`
+	Status       string          `json:"status" enum:"queued,running,completed,failed"`
+	Params       reports.Params  `json:"params"`
+	Progress     int             `json:"progress" doc:"Sections built so far"`
+	Total        *int            `json:"total" doc:"Sections the report will hold, once started"`
+	Report       *reports.Report `json:"report,omitempty" doc:"Once completed"`
+	ErrorMessage *string         `json:"errorMessage,omitempty"`
+	CreatedAt    time.Time       `json:"createdAt"`
+	FinishedAt   *time.Time      `json:"finishedAt"`
+}
+
+// CreateReport queues a report on a set of entities
+func CreateReport(c *fiber.Ctx) error {
+	var body ReportBody
+	if err := c.BodyParser(&body); err != nil {
+		return apierr.BadRequest("invalid body")
+	}
+	if len(body.EntityIDs) == 0 || len(body.EntityIDs) > reports.MaxEntities {
+		return apierr.InvalidParam("entityIds", "must list 1 to "+strconv.Itoa(reports.MaxEntities)+" entities")
+	}
+	var ids []int
+	for _, id := range body.EntityIDs {
+		if id <= 0 {
+			return apierr.InvalidParam("entityIds", "must be positive integers")
+		}
+		if !slices.Contains(ids, id) {
+			ids = append(ids, id)
+		}
+	}
+	title := strings.TrimSpace(body.Title)
+	if len(title) > 200 {
+		return apierr.InvalidParam("title", "must be at most 200 characters")
+	}
+
+	var dates [2]*string
+	for i, d := range []struct{ name, value string }{{"from", body.From}, {"to", body.To}} {
+		if d.value == "" {
+			continue
+		}
+		if _, err := time.Parse(time.DateOnly, d.value); err != nil {
+			return apierr.InvalidParam(d.name, "must be a date in YYYY-MM-DD format")
+		}
+		value := d.value
+		dates[i] = &value
+	}
+	if dates[0] != nil && dates[1] != nil && *dates[1] < *dates[0] {
+		return apierr.InvalidParam("to", "must not be before from")
+	}
+
+	sections := reports.Sections
+	if len(body.Sections) > 0 {
+		sections = nil
+		for _, s := range reports.Sections {
+			if slices.Contains(body.Sections, s) {
+				sections = append(sections, s)
+			}
+		}
+		for _, s := range body.Sections {
+			if !slices.Contains(reports.Sections, s) {
+				return apierr.InvalidParam("sections", "must be any of "+strings.Join(reports.Sections, ", "))
+			}
+		}
+	}
+
+	params := reports.Params{
+		Title:            title,
+		EntityIDs:        ids,
+		From:             dates[0],
+		To:               dates[1],
+		Sections:         sections,
+		ExcludeSensitive: body.ExcludeSensitive,
+		KeyID:            auth.FromContext(c).KeyID,
+	}
+	id, err := jobs.NewQueue(db.Pool()).Enqueue(c.UserContext(), reports.JobKind, params)
+	if err != nil {
+		return err
+	}
+
+	c.Location("/api/reports/" + strconv.FormatInt(id, 10))
+	return c.Status(fiber.StatusAccepted).JSON(ReportStatus{
+		ID:        id,
+		Status:    jobs.StatusQueued,
+		Params:    params,
+		CreatedAt: time.Now().UTC(),
+	})
+}
+
+// GetReport returns a report's progress and, once it has completed, the
+// report. Only the key that requested a report, or an admin, can see it.
+func GetReport(c *fiber.Ctx) error {
+	job, params, err := reportJob(c)
+	if err != nil {
+		return err
+	}
+
+	status := ReportStatus{
+		ID:           job.ID,
+		Status:       job.Status,
+		Params:       params,
+		Progress:     job.Progress,
+		Total:        job.Total,
+		ErrorMessage: job.ErrorMessage,
+		CreatedAt:    job.CreatedAt,
+		FinishedAt:   job.FinishedAt,
+	}
+	if job.Status == jobs.StatusCompleted {
+		if err := job.DecodeResult(&status.Report); err != nil {
+			return err
+		}
+	}
+	return c.JSON(status)
+}
+
+// DownloadReport sends a completed report as JSON, Markdown or PDF
+func DownloadReport(c *fiber.Ctx) error {
+	format, err := enumQuery(c, "format", reportFormats)
+	if err != nil {
+		return err
+	}
+	job, _, err := reportJob(c)
+	if err != nil {
+		return err
+	}
+	if job.Status != jobs.StatusCompleted {
+		return apierr.New(fiber.StatusConflict, apierr.CodeConflict, "the report is "+job.Status+"; download it once completed")
+	}
+	var report reports.Report
+	if err := job.DecodeResult(&report); err != nil {
+		return err
+	}
+
+	name := "report-" + strconv.FormatInt(job.ID, 10)
+	switch format {
+	case "markdown":
+		c.Set(fiber.HeaderContentType, "text/markdown; charset=utf-8")
+		c.Set(fiber.HeaderContentDisposition, `attachment; filename="`+name+`.md"`)
+		return c.SendString(report.Markdown(publicURL(c)))
+	case "pdf":
+		var buf bytes.Buffer
+		if err := report.PDF(&buf); err != nil {
+			return err
+		}
+		c.Set(fiber.HeaderContentType, reports.PDFMediaType)
+		c.Set(fiber.HeaderContentDisposition, `attachment; filename="`+name+`.pdf"`)
+		return c.Send(buf.Bytes())
+	}
+	c.Set(fiber.HeaderContentDisposition, `attachment; filename="`+name+`.json"`)
+	return c.JSON(report)
+}
+
+// reportJob loads the report job named by the id parameter, as not found
+// unless it's the caller's or the caller is an admin
+func reportJob(c *fiber.Ctx) (*jobs.Job, reports.Params, error) {
+	var params reports.Params
+	id, err := idParam(c)
+	if err != nil {
+		return nil, params, err
+	}
+	job, err := jobs.NewQueue(db.Pool()).Get(c.UserContext(), int64(id))
+	if err != nil {
+		return nil, params, notFound(err, "report")
+	}
+	if err := job.DecodeParams(&params); err != nil {
+		return nil, params, err
+	}
+	p := auth.FromContext(c)
+	if job.Kind != reports.JobKind || (params.KeyID != p.KeyID && p.Role < auth.RoleAdmin) {
+		return nil, params, apierr.NotFound("report")
+	}
+	return job, params, nil
+}
@@ -0,0 +1,346 @@
+package handlers
+
+import (
+	"time"
+
+	"github.com/subculture-collective/epstein-db/api/internal/anomalies"
+	"github.com/subculture-collective/epstein-db/api/internal/audit"
+	"github.com/subculture-collective/epstein-db/api/internal/bio"
+	"github.com/subculture-collective/epstein-db/api/internal/changes"
+	"github.com/subculture-collective/epstein-db/api/internal/health"
+	"github.com/subculture-collective/epstein-db/api/internal/jobs"
+	"github.com/subculture-collective/epstein-db/api/internal/llm"
+	"github.com/subculture-collective/epstein-db/api/internal/models"
+	"github.com/subculture-collective/epstein-db/api/internal/narrative"
+	"github.com/subculture-collective/epstein-db/api/internal/rag"
+	"github.com/subculture-collective/epstein-db/api/internal/shells"
+	"github.com/subculture-collective/epstein-db/api/internal/snapshot"
+)
+
+// Response bodies. Handlers return these rather than ad-hoc maps so that the
+// OpenAPI spec, which is generated from the same types, stays accurate. Rows
+// inside them are the store's, from package models.
+
+// TypeList is the values of a type taxonomy with their counts
+type TypeList struct {
+	Types []models.TypeCount `json:"types"`
+	Count int                `json:"count"`
+}
+
+// EntityList is a list of entities
+type EntityList struct {
+	Entities []models.EntitySummary `json:"entities"`
+	Count    int                    `json:"count"`
+	Warnings []models.Warning       `json:"warnings,omitempty" doc:"Rows left out because they couldn't be read"`
+}
+
+// ResolveResults are the entities each name of a list resolved to
+type ResolveResults struct {
+	Results  []models.Resolution `json:"results" doc:"One per name, in the order given"`
+	Count    int                 `json:"count"`
+	Matched  int                 `json:"matched" doc:"Names with a match"`
+	Warnings []models.Warning    `json:"warnings,omitempty" doc:"Rows left out because they couldn't be read"`
+}
+
+// ConnectionList is a list of connections
+type ConnectionList struct {
+	Connections []models.Connection `json:"connections"`
+	Count       int                 `json:"count"`
+	Warnings    []models.Warning    `json:"warnings,omitempty" doc:"Rows left out because they couldn't be read"`
+}
+
+// DocumentList is a list of documents
+type DocumentList struct {
+	Documents []models.DocumentSummary `json:"documents"`
+	Count     int                      `json:"count"`
+	Warnings  []models.Warning         `json:"warnings,omitempty" doc:"Rows left out because they couldn't be read"`
+}
+
+// DocumentPage is one page of a paginated document list
+type DocumentPage struct {
+	Documents []models.DocumentSummary `json:"documents"`
+	Count     int                      `json:"count"`
+	Offset    int                      `json:"offset"`
+	Limit     int                      `json:"limit"`
+	Warnings  []models.Warning         `json:"warnings,omitempty" doc:"Rows left out because they couldn't be read"`
+}
+
+// DocumentText is the full text of a document
+type DocumentText struct {
+	ID   int     `json:"id"`
+	Text *string `json:"text"`
+}
+
+// SharedGroupPage is one page of groups of records sharing an attribute
+type SharedGroupPage struct {
+	Groups   []models.SharedGroup `json:"groups"`
+	Count    int                  `json:"count"`
+	Offset   int                  `json:"offset"`
+	Limit    int                  `json:"limit"`
+	Warnings []models.Warning     `json:"warnings,omitempty" doc:"Rows left out because they couldn't be read"`
+}
+
+// ShellCandidatePage is one page of possible shell companies
+type ShellCandidatePage struct {
+	Candidates []shells.Candidate `json:"candidates"`
+	Signals    []shells.Signal    `json:"signals" doc:"Every signal scored, with its weight"`
+	Count      int                `json:"count"`
+	Offset     int                `json:"offset"`
+	Limit      int                `json:"limit"`
+}
+
+// AnomalyPage is one page of outlying amounts
+type AnomalyPage struct {
+	Anomalies []anomalies.Anomaly `json:"anomalies"`
+	Kinds     []anomalies.Kind    `json:"kinds" doc:"Every kind of anomaly looked for"`
+	Count     int                 `json:"count"`
+	Offset    int                 `json:"offset"`
+	Limit     int                 `json:"limit"`
+}
+
+// DocumentChunkPage is one page of a document's chunks
+type DocumentChunkPage struct {
+	DocumentID int                    `json:"documentId"`
+	Chunks     []models.DocumentChunk `json:"chunks"`
+	Count      int                    `json:"count"`
+	Offset     int                    `json:"offset"`
+	Limit      int                    `json:"limit"`
+}
+
+// ChunkResults are the passages retrieved for a query
+type ChunkResults struct {
+	Chunks   []rag.Chunk `json:"chunks"`
+	Count    int         `json:"count"`
+	Semantic bool        `json:"semantic" doc:"Whether embedding similarity was used; without it results are full-text matches only"`
+}
+
+// EntityDocumentList is the documents that mention an entity
+type EntityDocumentList struct {
+	Documents []models.EntityDocument `json:"documents"`
+	Count     int                     `json:"count"`
+	Warnings  []models.Warning        `json:"warnings,omitempty" doc:"Rows left out because they couldn't be read"`
+}
+
+// DocumentEntityList is the entities mentioned in a document
+type DocumentEntityList struct {
+	Entities []models.DocumentEntity `json:"entities"`
+	Count    int                     `json:"count"`
+	Warnings []models.Warning        `json:"warnings,omitempty" doc:"Rows left out because they couldn't be read"`
+}
+
+// DuplicateList is the near duplicates of a document
+type DuplicateList struct {
+	Documents []models.DuplicateDocument `json:"documents"`
+	Count     int                        `json:"count"`
+	Warnings  []models.Warning           `json:"warnings,omitempty" doc:"Rows left out because they couldn't be read"`
+}
+
+// SearchResults are the results of a full-text query
+type SearchResults struct {
+	Results  []models.SearchResult `json:"results"`
+	Count    int                   `json:"count"`
+	Query    string                `json:"query"`
+	Warnings []models.Warning      `json:"warnings,omitempty" doc:"Rows left out because they couldn't be read"`
+}
+
+// DatasetList is a list of datasets
+type DatasetList struct {
+	Datasets []models.Dataset `json:"datasets"`
+	Count    int              `json:"count"`
+	Warnings []models.Warning `json:"warnings,omitempty" doc:"Rows left out because they couldn't be read"`
+}
+
+// NetworkStats are the size of a network response
+type NetworkStats struct {
+	NodeCount int `json:"nodeCount"`
+	EdgeCount int `json:"edgeCount"`
+}
+
+// Network is an entity co-occurrence graph
+type Network struct {
+	Nodes    []models.EntitySummary `json:"nodes"`
+	Edges    []models.NetworkEdge   `json:"edges"`
+	Stats    NetworkStats           `json:"stats"`
+	Warnings []models.Warning       `json:"warnings,omitempty" doc:"Rows left out because they couldn't be read"`
+}
+
+// FinancialNetwork is a directed graph of money movements
+type FinancialNetwork struct {
+	Nodes    []models.FinancialNode `json:"nodes"`
+	Edges    []models.FinancialEdge `json:"edges"`
+	Stats    NetworkStats           `json:"stats"`
+	Warnings []models.Warning       `json:"warnings,omitempty" doc:"Rows left out because they couldn't be read"`
+}
+
+// Layer is the top entities in one network layer
+type Layer struct {
+	Layer    int                    `json:"layer"`
+	Entities []models.EntitySummary `json:"entities"`
+	Count    int                    `json:"count"`
+}
+
+// LayerList is the entities in every layer
+type LayerList struct {
+	Layers   []Layer          `json:"layers"`
+	Warnings []models.Warning `json:"warnings,omitempty" doc:"Rows left out because they couldn't be read"`
+}
+
+// TripleList is one page of triples
+type TripleList struct {
+	Triples  []models.Triple  `json:"triples"`
+	Count    int              `json:"count"`
+	Offset   int              `json:"offset"`
+	Limit    int              `json:"limit"`
+	Warnings []models.Warning `json:"warnings,omitempty" doc:"Rows left out because they couldn't be read"`
+}
+
+// PredicateList is the distinct predicates
+type PredicateList struct {
+	Predicates []models.PredicateCount `json:"predicates"`
+	Count      int                     `json:"count"`
+	Warnings   []models.Warning        `json:"warnings,omitempty" doc:"Rows left out because they couldn't be read"`
+}
+
+// PPPResults are PPP loans matching a query
+type PPPResults struct {
+	Results  []models.PPPLoan `json:"results"`
+	Count    int              `json:"count"`
+	Warnings []models.Warning `json:"warnings,omitempty" doc:"Rows left out because they couldn't be read"`
+}
+
+// FECResults are contributions matching a query
+type FECResults struct {
+	Results  []models.FECContribution `json:"results"`
+	Count    int                      `json:"count"`
+	Warnings []models.Warning         `json:"warnings,omitempty" doc:"Rows left out because they couldn't be read"`
+}
+
+// GrantResults are grants matching a query
+type GrantResults struct {
+	Results  []models.Grant   `json:"results"`
+	Count    int              `json:"count"`
+	Warnings []models.Warning `json:"warnings,omitempty" doc:"Rows left out because they couldn't be read"`
+}
+
+// PatternList is a list of pattern findings
+type PatternList struct {
+	Patterns []models.PatternSummary `json:"patterns"`
+	Count    int                     `json:"count"`
+	Warnings []models.Warning        `json:"warnings,omitempty" doc:"Rows left out because they couldn't be read"`
+}
+
+// PatternDetail is a pattern and the entities involved in it
+type PatternDetail struct {
+	Pattern  models.Pattern       `json:"pattern"`
+	Entities []models.EntityBrief `json:"entities"`
+}
+
+// JobList is a list of background jobs
+type JobList struct {
+	Jobs  []*jobs.Job `json:"jobs"`
+	Count int         `json:"count"`
+}
+
+// QueuedJob is returned when an admin endpoint queues background work
+type QueuedJob struct {
+	JobID  int64  `json:"jobId"`
+	Status string `json:"status"`
+}
+
+// ChangePage is the changes after a cursor
+type ChangePage struct {
+	Changes []changes.Change `json:"changes"`
+	Count   int              `json:"count"`
+	Cursor  string           `json:"cursor" doc:"Pass as since to read on; unchanged when there is nothing new"`
+	More    bool             `json:"more" doc:"Whether more changes are probably waiting"`
+}
+
+// SnapshotList is the written database snapshots
+type SnapshotList struct {
+	Snapshots []snapshot.Snapshot `json:"snapshots"`
+	Count     int                 `json:"count"`
+}
+
+// TimelinePage is one page of the timeline
+type TimelinePage struct {
+	Events []models.TimelineEvent `json:"events"`
+	Count  int                    `json:"count"`
+	Offset int                    `json:"offset"`
+	Limit  int                    `json:"limit"`
+}
+
+// TimelineEventRef identifies a created or edited timeline event
+type TimelineEventRef struct {
+	ID int64 `json:"id"`
+}
+
+// EntityBio is a generated biography with its provenance
+type EntityBio struct {
+	bio.Bio
+	Provenance Generation `json:"provenance"`
+}
+
+// EntityNarrative is a generated chronology with its provenance
+type EntityNarrative struct {
+	narrative.Narrative
+	Provenance Generation `json:"provenance"`
+}
+
+// Generation marks machine-generated content
+type Generation struct {
+	Generated   bool      `json:"generated"`
+	Notice      string    `json:"notice"`
+	Model       string    `json:"model"`
+	GeneratedAt time.Time `json:"generatedAt"`
+}
+
+// JobUsage is the model usage of a job, by task and model
+type JobUsage struct {
+	JobID        int64            `json:"jobId"`
+	Usage        []llm.UsageTotal `json:"usage"`
+	InputTokens  int64            `json:"inputTokens"`
+	OutputTokens int64            `json:"outputTokens"`
+	CostUSD      float64          `json:"costUsd" doc:"Estimated, counting only models with a known price"`
+}
+
+// AuditLog is one page of recorded admin actions
+type AuditLog struct {
+	Entries []audit.Entry `json:"entries"`
+	Count   int           `json:"count"`
+	Offset  int           `json:"offset"`
+	Limit   int           `json:"limit"`
+}
+
+// QualityHistoryEntry is the per-check counts of one quality report
+type QualityHistoryEntry struct {
+	ID          int            `json:"id"`
+	GeneratedAt time.Time      `json:"generatedAt"`
+	Failing     int            `json:"failing"`
+	Counts      map[string]int `json:"counts"`
+}
+
+// QualityHistory is the counts of recent quality reports
+type QualityHistory struct {
+	Reports []QualityHistoryEntry `json:"reports"`
+	Count   int                   `json:"count"`
+}
+
+// HealthStatus reports whether the server and its dependencies are up
+type HealthStatus struct {
+	Status          string          `json:"status" enum:"ok,degraded,error"`
+	SchemaVersion   int             `json:"schemaVersion,omitempty"`
+	ExpectedVersion int             `json:"expectedVersion,omitempty"`
+	Checks          []health.Result `json:"checks,omitempty"`
+	Pools           []PoolStats     `json:"pools,omitempty"`
+	Error           string          `json:"error,omitempty"`
+}
+
+// PoolStats is the usage of a database connection pool
+type PoolStats struct {
+	Name        string  `json:"name" enum:"primary,replica"`
+	Acquired    int32   `json:"acquired"`
+	Idle        int32   `json:"idle"`
+	Total       int32   `json:"total"`
+	Max         int32   `json:"max"`
+	Utilization float64 `json:"utilization" doc:"Acquired as a fraction of max"`
+}
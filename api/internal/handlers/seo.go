@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"bytes"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/subculture-collective/epstein-db/api/internal/apierr"
+	"github.com/subculture-collective/epstein-db/api/internal/db"
+	"github.com/subculture-collective/epstein-db/api/internal/seo"
+)
+
+// siteURL is the base of the web app's permalinks
+func siteURL(c *fiber.Ctx) string {
+	if settings != nil && settings.SiteURL != "" {
+		return settings.SiteURL
+	}
+	return publicURL(c)
+}
+
+// SitemapIndex returns the index of sitemap pages
+func SitemapIndex(c *fiber.Ctx) error {
+	idx, err := seo.Sitemaps(c.UserContext(), db.ReadPool(), publicURL(c))
+	if err != nil {
+		return err
+	}
+	return sendSitemap(c, idx, len(idx.Sitemaps), idx.Updated)
+}
+
+// SitemapPage returns one page of entity or document permalinks
+func SitemapPage(c *fiber.Ctx) error {
+	kind := c.Params("kind")
+	if !slices.Contains(seo.Kinds, kind) {
+		return apierr.InvalidParam("kind", "must be one of "+strings.Join(seo.Kinds, ", "))
+	}
+	page, err := c.ParamsInt("page")
+	if err != nil || page < 1 {
+		return apierr.InvalidParam("page", "must be a positive integer")
+	}
+
+	set, err := seo.Page(c.UserContext(), db.ReadPool(), siteURL(c), kind, page)
+	if err != nil {
+		return notFound(err, "sitemap page")
+	}
+	return sendSitemap(c, set, len(set.URLs), set.Updated)
+}
+
+// sendSitemap sends an index or page of n entries, or 304 if none has
+// changed since the crawler's copy
+func sendSitemap(c *fiber.Ctx, v any, n int, modified time.Time) error {
+	if notModified(c, c.OriginalURL()+"#"+strconv.Itoa(n), modified) {
+		return c.SendStatus(fiber.StatusNotModified)
+	}
+
+	var buf bytes.Buffer
+	if err := seo.Write(&buf, v); err != nil {
+		return err
+	}
+	c.Set(fiber.HeaderContentType, fiber.MIMEApplicationXMLCharsetUTF8)
+	return c.Send(buf.Bytes())
+}
+
+// GetMeta returns the title and description of an entity or document, for
+// link previews rendered server-side
+func GetMeta(c *fiber.Ctx) error {
+	typ := c.Params("type")
+	if !slices.Contains(seo.Types, typ) {
+		return apierr.InvalidParam("type", "must be one of "+strings.Join(seo.Types, ", "))
+	}
+	id, err := idParam(c)
+	if err != nil {
+		return err
+	}
+
+	m, err := seo.Describe(c.UserContext(), db.ReadPool(), siteURL(c), typ, id)
+	if err != nil {
+		return notFound(err, typ)
+	}
+	if notModified(c, "meta:"+typ+":"+c.Params("id"), m.UpdatedAt) {
+		return c.SendStatus(fiber.StatusNotModified)
+	}
+	return c.JSON(m)
+}
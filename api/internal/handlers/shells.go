@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/subculture-collective/epstein-db/api/internal/audit"
+	"github.com/subculture-collective/epstein-db/api/internal/db"
+	"github.com/subculture-collective/epstein-db/api/internal/jobs"
+	"github.com/subculture-collective/epstein-db/api/internal/shells"
+)
+
+// ListShellCandidates returns the organizations the last shell company
+// analysis flagged, highest scores first, each with the signals behind its
+// score
+func ListShellCandidates(c *fiber.Ctx) error {
+	minScore, err := fractionQuery(c, "minScore", 0)
+	if err != nil {
+		return err
+	}
+	signal, err := enumQuery(c, "signal", shells.SignalNames())
+	if err != nil {
+		return err
+	}
+	limit, err := limitQuery(c, 50, 200)
+	if err != nil {
+		return err
+	}
+	offset, err := offsetQuery(c)
+	if err != nil {
+		return err
+	}
+
+	candidates, err := shells.List(c.UserContext(), db.Pool(), shells.Filter{
+		MinScore: minScore,
+		Signal:   signal,
+		Limit:    limit,
+		Offset:   offset,
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(ShellCandidatePage{
+		Candidates: candidates,
+		Signals:    shells.Signals,
+		Count:      len(candidates),
+		Offset:     offset,
+		Limit:      limit,
+	})
+}
+
+// QueueShellAnalysis queues a background rerun of the shell company analysis
+func QueueShellAnalysis(c *fiber.Ctx) error {
+	id, err := jobs.NewQueue(db.Pool()).Enqueue(c.UserContext(), shells.JobKind, struct{}{})
+	if err != nil {
+		return err
+	}
+
+	audit.SetJob(c, id)
+
+	return c.Status(202).JSON(QueuedJob{
+		JobID:  id,
+		Status: jobs.StatusQueued,
+	})
+}
@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"errors"
+	"slices"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/subculture-collective/epstein-db/api/internal/apierr"
+	"github.com/subculture-collective/epstein-db/api/internal/audit"
+	"github.com/subculture-collective/epstein-db/api/internal/db"
+	"github.com/subculture-collective/epstein-db/api/internal/jobs"
+	"github.com/subculture-collective/epstein-db/api/internal/snapshot"
+)
+
+// snapshotDir is where snapshots are written and served from
+func snapshotDir() string {
+	if settings == nil {
+		return "snapshots"
+	}
+	return settings.Snapshots.Dir
+}
+
+// QueueSnapshot queues an export of the public tables
+func QueueSnapshot(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+
+	params := snapshot.Params{Format: snapshot.FormatSQLite}
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&params); err != nil {
+			return apierr.BadRequest("invalid body")
+		}
+	}
+	if !slices.Contains(snapshot.Formats, params.Format) {
+		return apierr.InvalidParam("format", "must be one of "+strings.Join(snapshot.Formats, ", "))
+	}
+
+	id, err := jobs.NewQueue(db.Pool()).Enqueue(ctx, snapshot.JobKind, params)
+	if err != nil {
+		return err
+	}
+
+	audit.SetJob(c, id)
+
+	return c.Status(202).JSON(QueuedJob{
+		JobID:  id,
+		Status: jobs.StatusQueued,
+	})
+}
+
+// ListSnapshots lists the written snapshots, newest first
+func ListSnapshots(c *fiber.Ctx) error {
+	snapshots, err := snapshot.List(snapshotDir())
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(SnapshotList{
+		Snapshots: snapshots,
+		Count:     len(snapshots),
+	})
+}
+
+// DownloadSnapshot sends a snapshot file, with its checksum in a header
+func DownloadSnapshot(c *fiber.Ctx) error {
+	name := c.Params("name")
+	path, err := snapshot.Path(snapshotDir(), name)
+	if errors.Is(err, snapshot.ErrNotFound) {
+		return apierr.NotFound("snapshot")
+	}
+	if err != nil {
+		return err
+	}
+
+	snapshots, err := snapshot.List(snapshotDir())
+	if err != nil {
+		return err
+	}
+	for _, s := range snapshots {
+		if s.Name == name {
+			c.Set("Digest", "sha-256="+s.SHA256)
+		}
+	}
+
+	return c.Download(path, name)
+}
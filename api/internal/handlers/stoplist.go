@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"errors"
+	"slices"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/subculture-collective/epstein-db/api/internal/apierr"
+	"github.com/subculture-collective/epstein-db/api/internal/audit"
+	"github.com/subculture-collective/epstein-db/api/internal/auth"
+	"github.com/subculture-collective/epstein-db/api/internal/db"
+	"github.com/subculture-collective/epstein-db/api/internal/stoplist"
+	"github.com/subculture-collective/epstein-db/api/internal/store"
+)
+
+// maxStopName bounds a name put on the stoplist
+const maxStopName = 200
+
+// StoplistBody is the body of POST /api/admin/stoplist
+type StoplistBody struct {
+	Name       string `json:"name"`
+	EntityType string `json:"entityType,omitempty" doc:"Only block entities of this type; every type when empty"`
+	Reason     string `json:"reason,omitempty"`
+}
+
+// StoplistReviewBody is the body of PUT /api/admin/stoplist/:id
+type StoplistReviewBody struct {
+	Status string `json:"status" enum:"suggested,blocked,dismissed"`
+}
+
+// StoplistList is one page of the stoplist
+type StoplistList struct {
+	Entries []stoplist.Entry `json:"entries"`
+	Count   int              `json:"count"`
+	Offset  int              `json:"offset"`
+	Limit   int              `json:"limit"`
+}
+
+// StoplistChange is a stoplist entry after a change, with what blocking it
+// removed
+type StoplistChange struct {
+	Entry   *stoplist.Entry   `json:"entry"`
+	Removed *stoplist.Removed `json:"removed"`
+}
+
+// ListStoplist returns the stoplist, suggestions included
+func ListStoplist(c *fiber.Ctx) error {
+	status, err := enumQuery(c, "status", stoplist.Statuses)
+	if err != nil {
+		return err
+	}
+	limit, err := limitQuery(c, 50, 200)
+	if err != nil {
+		return err
+	}
+	offset, err := offsetQuery(c)
+	if err != nil {
+		return err
+	}
+
+	list, err := stoplist.List(c.UserContext(), db.Pool(), status, limit, offset)
+	if err != nil {
+		return err
+	}
+	return c.JSON(StoplistList{Entries: list, Count: len(list), Offset: offset, Limit: limit})
+}
+
+// BlockName puts a name on the stoplist and removes its entities' links
+func BlockName(c *fiber.Ctx) error {
+	var body StoplistBody
+	if err := c.BodyParser(&body); err != nil {
+		return apierr.BadRequest("invalid body")
+	}
+	name := strings.TrimSpace(body.Name)
+	if name == "" || len(name) > maxStopName {
+		return apierr.InvalidParam("name", "must be 1 to 200 characters")
+	}
+	if body.EntityType != "" && !slices.Contains(store.EntityTypes, body.EntityType) {
+		return apierr.InvalidParam("entityType", "must be one of "+strings.Join(store.EntityTypes, ", "))
+	}
+
+	entry, removed, err := stoplist.Block(c.UserContext(), db.Pool(), auth.FromContext(c).KeyID,
+		name, body.EntityType, strings.TrimSpace(body.Reason))
+	if errors.Is(err, stoplist.ErrEmptyName) {
+		return apierr.InvalidParam("name", "must contain letters or digits")
+	}
+	if err != nil {
+		return err
+	}
+	audit.SetAffected(c, int64(removed.Links+removed.Triples))
+	return c.Status(201).JSON(StoplistChange{Entry: entry, Removed: removed})
+}
+
+// ReviewStoplist blocks or dismisses a stoplist entry
+func ReviewStoplist(c *fiber.Ctx) error {
+	id, err := idParam(c)
+	if err != nil {
+		return err
+	}
+	var body StoplistReviewBody
+	if err := c.BodyParser(&body); err != nil {
+		return apierr.BadRequest("invalid body")
+	}
+	if !slices.Contains(stoplist.Statuses, body.Status) {
+		return apierr.InvalidParam("status", "must be one of "+strings.Join(stoplist.Statuses, ", "))
+	}
+
+	entry, removed, err := stoplist.Review(c.UserContext(), db.Pool(), id, auth.FromContext(c).KeyID, body.Status)
+	if err != nil {
+		return notFound(err, "stoplist entry")
+	}
+	audit.SetAffected(c, int64(removed.Links+removed.Triples))
+	return c.JSON(StoplistChange{Entry: entry, Removed: removed})
+}
@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"context"
+	"time"
+
+	"github.com/subculture-collective/epstein-db/api/internal/bio"
+	"github.com/subculture-collective/epstein-db/api/internal/models"
+	"github.com/subculture-collective/epstein-db/api/internal/narrative"
+	"github.com/subculture-collective/epstein-db/api/internal/store"
+)
+
+// The read handlers get their data through these interfaces rather than
+// the pool, so tests can substitute fakes. *store.Store implements them all.
+
+// EntityStore looks up entities
+type EntityStore interface {
+	Stats(ctx context.Context) (models.Stats, error)
+	EntityTypeCounts(ctx context.Context) ([]models.TypeCount, error)
+	DocumentTypeCounts(ctx context.Context) ([]models.TypeCount, error)
+	SearchEntities(ctx context.Context, f store.EntityFilter) ([]models.EntitySummary, error)
+	GetEntity(ctx context.Context, id int) (*models.Entity, error)
+	EntityConnections(ctx context.Context, id int, granularity string, sort store.Sort, limit int) ([]models.Connection, error)
+	EntityDocuments(ctx context.Context, id int, f store.EntityDocumentFilter) ([]models.EntityDocument, error)
+	EntitiesByID(ctx context.Context, ids []int) ([]models.EntityBrief, error)
+	EntityMentions(ctx context.Context, f store.MentionFilter) (*models.Mentions, error)
+	ResolveEntities(ctx context.Context, f store.ResolveFilter) ([]models.Resolution, error)
+	ProtectedEntities(ctx context.Context, limit, offset int) ([]models.ProtectedEntity, error)
+	SetEntityProtected(ctx context.Context, id int, protected bool, keyID int, reason string) (*models.ProtectedEntity, error)
+	EntityBio(ctx context.Context, id int) (*bio.Bio, error)
+	EntityNarrative(ctx context.Context, id int) (*narrative.Narrative, error)
+}
+
+// DocumentStore looks up documents and datasets
+type DocumentStore interface {
+	ListDocuments(ctx context.Context, f store.DocumentFilter) ([]models.DocumentSummary, error)
+	GetDocument(ctx context.Context, id int) (*models.Document, error)
+	RandomDocument(ctx context.Context, f store.DocumentFilter) (*models.Document, error)
+	DocumentUpdatedAt(ctx context.Context, id int) (time.Time, error)
+	DocumentText(ctx context.Context, id int) (*string, error)
+	DocumentReadable(ctx context.Context, id int) error
+	DocumentFile(ctx context.Context, id int) (string, error)
+	DocumentEntities(ctx context.Context, id int, role string) ([]models.DocumentEntity, error)
+	DocumentChunks(ctx context.Context, id, limit, offset int) ([]models.DocumentChunk, error)
+	Timeline(ctx context.Context, f store.TimelineFilter) ([]models.TimelineEvent, error)
+	CreateTimelineEvent(ctx context.Context, in store.TimelineEventInput, keyID int) (int64, error)
+	UpdateTimelineEvent(ctx context.Context, id int64, in store.TimelineEventInput) error
+	DeleteTimelineEvent(ctx context.Context, id int64) error
+	TagCounts(ctx context.Context, limit, offset int) ([]models.TagCount, error)
+	TagDocument(ctx context.Context, id int, add, remove []string) ([]string, error)
+	TagDocuments(ctx context.Context, f store.SearchFilter, add, remove []string) (int, error)
+	SearchText(ctx context.Context, f store.SearchFilter) ([]models.SearchResult, error)
+	SearchTextPlan(ctx context.Context, f store.SearchFilter) (store.Plan, error)
+	SearchTerms(ctx context.Context, query string) (int, error)
+	SearchNgrams(ctx context.Context, f store.SearchFilter) ([]models.SearchResult, error)
+	SearchNgramsPlan(ctx context.Context, f store.SearchFilter) (store.Plan, error)
+	DocumentProvenance(ctx context.Context, id int) (*models.Provenance, error)
+	DocumentDuplicates(ctx context.Context, id int) ([]models.DuplicateDocument, error)
+	ListDatasets(ctx context.Context, status string, sort store.Sort) ([]models.Dataset, error)
+	SetDatasetVisibility(ctx context.Context, id int, visibility string) (*models.Dataset, error)
+	DatasetAnalytics(ctx context.Context, id int) (*models.DatasetAnalytics, error)
+}
+
+// NetworkStore builds the co-occurrence network and reads patterns
+type NetworkStore interface {
+	NetworkUpdatedAt(ctx context.Context) (time.Time, error)
+	Network(ctx context.Context, f store.NetworkFilter) ([]models.EntitySummary, []models.NetworkEdge, error)
+	CooccurrenceMatrix(ctx context.Context, ids []int) (*models.Matrix, error)
+	NetworkPlan(ctx context.Context, f store.NetworkFilter) (store.Plan, error)
+	LayerEntities(ctx context.Context, layer, limit int) ([]models.EntitySummary, error)
+	EgoNetwork(ctx context.Context, id, depth, limit int) ([]models.EgoNode, []models.NetworkEdge, error)
+	ListPatterns(ctx context.Context, f store.PatternFilter) ([]models.PatternSummary, error)
+	GetPattern(ctx context.Context, id int) (*models.Pattern, error)
+	FinancialNetwork(ctx context.Context, f store.FinancialFilter) ([]models.FinancialNode, []models.FinancialEdge, error)
+}
+
+// CrossrefStore searches the public datasets entities are matched against
+type CrossrefStore interface {
+	SearchPPP(ctx context.Context, query, match string, sort store.Sort, limit int) ([]models.PPPLoan, error)
+	SearchFEC(ctx context.Context, query, match, candidate string, sort store.Sort, limit int) ([]models.FECContribution, error)
+	SearchGrants(ctx context.Context, query, match, agency string, sort store.Sort, limit int) ([]models.Grant, error)
+	SharedAttributeGroups(ctx context.Context, f store.SharedAttributeFilter) ([]models.SharedGroup, error)
+}
+
+// TripleStore searches extracted triples
+type TripleStore interface {
+	SearchTriples(ctx context.Context, f store.TripleFilter) ([]models.Triple, error)
+	Predicates(ctx context.Context) ([]models.PredicateCount, error)
+}
+
+// Store is everything the read handlers need
+type Store interface {
+	EntityStore
+	DocumentStore
+	NetworkStore
+	CrossrefStore
+	TripleStore
+}
+
+// data backs the read handlers; cmd/server sets it with SetStore
+var data Store
+
+// SetStore sets the store the handlers read from
+func SetStore(s Store) {
+	data = s
+}
@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/subculture-collective/epstein-db/api/internal/apierr"
+	"github.com/subculture-collective/epstein-db/api/internal/audit"
+	"github.com/subculture-collective/epstein-db/api/internal/models"
+	"github.com/subculture-collective/epstein-db/api/internal/store"
+)
+
+// Tag limits
+const (
+	maxTagLength = 50
+	maxTagChange = 20 // tags added plus removed in one request
+)
+
+// TagChangeBody is the body of PUT /api/admin/documents/:id/tags
+type TagChangeBody struct {
+	Add    []string `json:"add,omitempty"`
+	Remove []string `json:"remove,omitempty" doc:"Removing wins over adding the same tag"`
+}
+
+// BulkTagBody is the body of POST /api/admin/tags/bulk. The documents are
+// those a full-text search for q would find in the given scope, or every
+// document in scope without q.
+type BulkTagBody struct {
+	TagChangeBody
+	Query    string `json:"q,omitempty" doc:"Full-text query"`
+	Dataset  int    `json:"dataset,omitempty"`
+	Type     string `json:"type,omitempty" doc:"Document type"`
+	EntityID int    `json:"entityId,omitempty" doc:"Only documents mentioning this entity"`
+	Tag      string `json:"tag,omitempty" doc:"Only documents with this tag"`
+}
+
+// TagList is one page of content tags
+type TagList struct {
+	Tags   []models.TagCount `json:"tags"`
+	Count  int               `json:"count"`
+	Offset int               `json:"offset"`
+	Limit  int               `json:"limit"`
+}
+
+// DocumentTags is a document's content tags after a change
+type DocumentTags struct {
+	ID          int      `json:"id"`
+	ContentTags []string `json:"contentTags"`
+}
+
+// BulkTagResult counts the documents a bulk change touched
+type BulkTagResult struct {
+	Documents int `json:"documents" doc:"Documents whose tags changed"`
+}
+
+// tagChange trims the tags of a change and checks them
+func tagChange(body TagChangeBody) (add, remove []string, err error) {
+	if len(body.Add)+len(body.Remove) == 0 {
+		return nil, nil, apierr.BadRequest("add or remove at least one tag")
+	}
+	if len(body.Add)+len(body.Remove) > maxTagChange {
+		return nil, nil, apierr.BadRequest("at most 20 tags can be added and removed at once")
+	}
+	clean := func(name string, tags []string) ([]string, error) {
+		out := make([]string, 0, len(tags))
+		for _, t := range tags {
+			t = strings.TrimSpace(t)
+			if t == "" || utf8.RuneCountInString(t) > maxTagLength {
+				return nil, apierr.InvalidParam(name, "tags must be 1 to 50 characters")
+			}
+			out = append(out, t)
+		}
+		return out, nil
+	}
+	if add, err = clean("add", body.Add); err != nil {
+		return nil, nil, err
+	}
+	if remove, err = clean("remove", body.Remove); err != nil {
+		return nil, nil, err
+	}
+	return add, remove, nil
+}
+
+// ListTags returns the content tags in use, most common first
+func ListTags(c *fiber.Ctx) error {
+	limit, err := limitQuery(c, 100, 1000)
+	if err != nil {
+		return err
+	}
+	offset, err := offsetQuery(c)
+	if err != nil {
+		return err
+	}
+
+	tags, err := data.TagCounts(c.UserContext(), limit, offset)
+	if err != nil {
+		return err
+	}
+	return c.JSON(TagList{Tags: tags, Count: len(tags), Offset: offset, Limit: limit})
+}
+
+// TagDocument adds and removes content tags on one document
+func TagDocument(c *fiber.Ctx) error {
+	id, err := idParam(c)
+	if err != nil {
+		return err
+	}
+	var body TagChangeBody
+	if err := c.BodyParser(&body); err != nil {
+		return apierr.BadRequest("invalid body")
+	}
+	add, remove, err := tagChange(body)
+	if err != nil {
+		return err
+	}
+
+	tags, err := data.TagDocument(c.UserContext(), id, add, remove)
+	if err != nil {
+		return notFound(err, "document")
+	}
+	return c.JSON(DocumentTags{ID: id, ContentTags: tags})
+}
+
+// BulkTagDocuments adds and removes content tags on every document a search
+// finds
+func BulkTagDocuments(c *fiber.Ctx) error {
+	var body BulkTagBody
+	if err := c.BodyParser(&body); err != nil {
+		return apierr.BadRequest("invalid body")
+	}
+	add, remove, err := tagChange(body.TagChangeBody)
+	if err != nil {
+		return err
+	}
+	if body.Dataset < 0 {
+		return apierr.InvalidParam("dataset", "must be a positive integer")
+	}
+	if body.EntityID < 0 {
+		return apierr.InvalidParam("entityId", "must be a positive integer")
+	}
+
+	filter := store.SearchFilter{
+		Query:     strings.TrimSpace(body.Query),
+		DatasetID: body.Dataset,
+		Type:      body.Type,
+		EntityID:  body.EntityID,
+		Tag:       strings.TrimSpace(body.Tag),
+	}
+	if filter == (store.SearchFilter{}) {
+		return apierr.BadRequest("give q, dataset, type, entityId or tag; retagging every document at once is refused")
+	}
+	if filter.Query != "" {
+		terms, err := data.SearchTerms(c.UserContext(), filter.Query)
+		if err != nil {
+			return err
+		}
+		if terms == 0 {
+			return apierr.InvalidParam("q", "has no searchable terms")
+		}
+	}
+
+	n, err := data.TagDocuments(c.UserContext(), filter, add, remove)
+	if err != nil {
+		return err
+	}
+	audit.SetAffected(c, int64(n))
+	return c.JSON(BulkTagResult{Documents: n})
+}
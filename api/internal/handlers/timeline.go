@@ -0,0 +1,201 @@
+package handlers
+
+import (
+	"errors"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/subculture-collective/epstein-db/api/internal/apierr"
+	"github.com/subculture-collective/epstein-db/api/internal/audit"
+	"github.com/subculture-collective/epstein-db/api/internal/auth"
+	"github.com/subculture-collective/epstein-db/api/internal/store"
+	"github.com/subculture-collective/epstein-db/api/internal/timeline"
+)
+
+// maxEventEntities caps the entities linked to one curated event
+const maxEventEntities = 50
+
+// TimelineEventBody is the body of POST and PUT /api/timeline/events
+type TimelineEventBody struct {
+	Date        string `json:"date" doc:"YYYY-MM-DD"`
+	Description string `json:"description"`
+	Type        string `json:"type" enum:"flight,meeting,communication,legal,financial,relationship,other"`
+	DocumentID  *int   `json:"documentId,omitempty" doc:"Source document"`
+	EntityIDs   []int  `json:"entityIds"`
+}
+
+// GetTimeline returns events across the corpus in date order
+func GetTimeline(c *fiber.Ctx) error {
+	from, err := dateQuery(c, "from")
+	if err != nil {
+		return err
+	}
+	to, err := dateQuery(c, "to")
+	if err != nil {
+		return err
+	}
+	entityIDs, err := idsQuery(c, "entities", maxEventEntities)
+	if err != nil {
+		return err
+	}
+	match, err := enumQuery(c, "match", []string{"any", "all"})
+	if err != nil {
+		return err
+	}
+	eventType, err := enumQuery(c, "type", timeline.Types)
+	if err != nil {
+		return err
+	}
+	sort, err := sortQuery(c, store.TimelineSorts)
+	if err != nil {
+		return err
+	}
+	limit, err := limitQuery(c, 100, 1000)
+	if err != nil {
+		return err
+	}
+	offset, err := offsetQuery(c)
+	if err != nil {
+		return err
+	}
+	format, err := formatQuery(c)
+	if err != nil {
+		return err
+	}
+
+	events, err := data.Timeline(c.UserContext(), store.TimelineFilter{
+		From:      from,
+		To:        to,
+		EntityIDs: entityIDs,
+		MatchAll:  match == "all",
+		Type:      eventType,
+		Sort:      sort,
+		Limit:     limit,
+		Offset:    offset,
+	})
+	if err != nil {
+		return err
+	}
+
+	return sendList(c, format, TimelinePage{
+		Events: events,
+		Count:  len(events),
+		Offset: offset,
+		Limit:  limit,
+	}, "events", nil)
+}
+
+// CreateTimelineEvent adds a curated event to the timeline
+func CreateTimelineEvent(c *fiber.Ctx) error {
+	in, err := timelineEventInput(c)
+	if err != nil {
+		return err
+	}
+
+	id, err := data.CreateTimelineEvent(c.UserContext(), in, auth.FromContext(c).KeyID)
+	if err != nil {
+		return err
+	}
+	audit.SetAffected(c, 1)
+
+	return c.Status(201).JSON(TimelineEventRef{ID: id})
+}
+
+// UpdateTimelineEvent corrects an event's date, description, type, source
+// document or entities
+func UpdateTimelineEvent(c *fiber.Ctx) error {
+	id, err := idParam(c)
+	if err != nil {
+		return err
+	}
+	in, err := timelineEventInput(c)
+	if err != nil {
+		return err
+	}
+
+	if err := data.UpdateTimelineEvent(c.UserContext(), int64(id), in); err != nil {
+		return notFound(err, "event")
+	}
+	audit.SetAffected(c, 1)
+
+	return c.JSON(TimelineEventRef{ID: int64(id)})
+}
+
+// DeleteTimelineEvent removes a curated event or hides an extracted one
+func DeleteTimelineEvent(c *fiber.Ctx) error {
+	id, err := idParam(c)
+	if err != nil {
+		return err
+	}
+
+	if err := data.DeleteTimelineEvent(c.UserContext(), int64(id)); err != nil {
+		return notFound(err, "event")
+	}
+	audit.SetAffected(c, 1)
+
+	return c.SendStatus(204)
+}
+
+// timelineEventInput validates a curated event, checking that its document
+// and entities exist
+func timelineEventInput(c *fiber.Ctx) (store.TimelineEventInput, error) {
+	ctx := c.UserContext()
+
+	var body TimelineEventBody
+	if err := c.BodyParser(&body); err != nil {
+		return store.TimelineEventInput{}, apierr.BadRequest("invalid body")
+	}
+
+	date, err := time.Parse(time.DateOnly, body.Date)
+	if err != nil {
+		return store.TimelineEventInput{}, apierr.InvalidParam("date", "must be a date in YYYY-MM-DD format")
+	}
+	description := strings.TrimSpace(body.Description)
+	if description == "" {
+		return store.TimelineEventInput{}, apierr.InvalidParam("description", "is required")
+	}
+	if len(description) > 2000 {
+		return store.TimelineEventInput{}, apierr.InvalidParam("description", "must be at most 2000 characters")
+	}
+	if !slices.Contains(timeline.Types, body.Type) {
+		return store.TimelineEventInput{}, apierr.InvalidParam("type", "must be one of "+strings.Join(timeline.Types, ", "))
+	}
+
+	if body.DocumentID != nil {
+		_, err := data.DocumentUpdatedAt(ctx, *body.DocumentID)
+		if errors.Is(err, store.ErrNotFound) {
+			return store.TimelineEventInput{}, apierr.InvalidParam("documentId", "no such document")
+		}
+		if err != nil {
+			return store.TimelineEventInput{}, err
+		}
+	}
+
+	entityIDs := slices.Clone(body.EntityIDs)
+	slices.Sort(entityIDs)
+	entityIDs = slices.Compact(entityIDs)
+	if len(entityIDs) > maxEventEntities {
+		return store.TimelineEventInput{}, apierr.InvalidParam("entityIds", "must have at most "+strconv.Itoa(maxEventEntities)+" entries")
+	}
+	if len(entityIDs) > 0 {
+		found, err := data.EntitiesByID(ctx, entityIDs)
+		if err != nil {
+			return store.TimelineEventInput{}, err
+		}
+		if len(found) != len(entityIDs) {
+			return store.TimelineEventInput{}, apierr.InvalidParam("entityIds", "contains an unknown entity")
+		}
+	}
+
+	return store.TimelineEventInput{
+		Date:        date,
+		Description: description,
+		Type:        body.Type,
+		DocumentID:  body.DocumentID,
+		EntityIDs:   entityIDs,
+	}, nil
+}
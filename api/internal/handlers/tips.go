@@ -0,0 +1,251 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/subculture-collective/epstein-db/api/internal/apierr"
+	"github.com/subculture-collective/epstein-db/api/internal/audit"
+	"github.com/subculture-collective/epstein-db/api/internal/auth"
+	"github.com/subculture-collective/epstein-db/api/internal/captcha"
+	"github.com/subculture-collective/epstein-db/api/internal/db"
+	"github.com/subculture-collective/epstein-db/api/internal/tips"
+)
+
+// Limits on a submitted tip
+const (
+	minTipLength  = 20
+	maxTipLength  = 10000
+	maxTipContact = 200
+	maxTipRefs    = 50
+)
+
+// formCaptcha checks public submissions; cmd/server sets it with
+// SetCaptcha. Without one, tips and correction requests are taken without a
+// captcha.
+var formCaptcha *captcha.Verifier
+
+// SetCaptcha sets the verifier SubmitTip and RequestCorrection check tokens
+// with
+func SetCaptcha(v *captcha.Verifier) {
+	formCaptcha = v
+}
+
+// checkCaptcha verifies a captcha token. Tokens are single use, so check
+// it only once the rest of a submission is valid.
+func checkCaptcha(c *fiber.Ctx, token string) error {
+	if formCaptcha == nil {
+		return nil
+	}
+	err := formCaptcha.Verify(c.UserContext(), token, c.IP())
+	if errors.Is(err, captcha.ErrFailed) {
+		return apierr.InvalidParam("captcha", "was not solved")
+	}
+	if err != nil {
+		log.Printf("captcha: %v", err)
+		return apierr.New(fiber.StatusServiceUnavailable, apierr.CodeUnavailable, "captcha could not be checked; try again")
+	}
+	return nil
+}
+
+// TipBody is the body of POST /api/tips
+type TipBody struct {
+	Body        string `json:"body" doc:"The lead, in 20 to 10000 characters"`
+	Contact     string `json:"contact,omitempty" doc:"How to reach the submitter, if they want to be reached"`
+	DocumentIDs []int  `json:"documentIds,omitempty"`
+	EntityIDs   []int  `json:"entityIds,omitempty"`
+	Captcha     string `json:"captcha" doc:"Token from the captcha widget"`
+}
+
+// TipReceipt acknowledges a submitted tip
+type TipReceipt struct {
+	ID     int64  `json:"id"`
+	Status string `json:"status" enum:"pending"`
+}
+
+// TipList is one page of the moderation queue
+type TipList struct {
+	Tips   []tips.Tip `json:"tips"`
+	Count  int        `json:"count"`
+	Offset int        `json:"offset"`
+	Limit  int        `json:"limit"`
+}
+
+// TipReviewBody is the body of PUT /api/admin/tips/:id
+type TipReviewBody struct {
+	Status string `json:"status" enum:"pending,accepted,rejected"`
+	Note   string `json:"note,omitempty"`
+}
+
+// TipPatternBody is the body of POST /api/admin/tips/:id/pattern
+type TipPatternBody struct {
+	Title       string   `json:"title"`
+	Description string   `json:"description"`
+	PatternType string   `json:"patternType,omitempty"`
+	Confidence  *float64 `json:"confidence,omitempty" doc:"Between 0 and 1"`
+}
+
+// SubmitTip queues a lead from the public for moderation
+func SubmitTip(c *fiber.Ctx) error {
+	var body TipBody
+	if err := c.BodyParser(&body); err != nil {
+		return apierr.BadRequest("invalid body")
+	}
+
+	text := strings.TrimSpace(body.Body)
+	if n := len([]rune(text)); n < minTipLength || n > maxTipLength {
+		return apierr.InvalidParam("body", "must be "+strconv.Itoa(minTipLength)+" to "+strconv.Itoa(maxTipLength)+" characters")
+	}
+	contact := strings.TrimSpace(body.Contact)
+	if len(contact) > maxTipContact {
+		return apierr.InvalidParam("contact", "must be at most "+strconv.Itoa(maxTipContact)+" characters")
+	}
+	documents, err := tipRefs("documentIds", body.DocumentIDs)
+	if err != nil {
+		return err
+	}
+	entities, err := tipRefs("entityIds", body.EntityIDs)
+	if err != nil {
+		return err
+	}
+
+	if err := checkCaptcha(c, body.Captcha); err != nil {
+		return err
+	}
+
+	t, err := tips.Submit(c.UserContext(), db.Pool(), tips.Submission{
+		Body:        text,
+		Contact:     contact,
+		DocumentIDs: documents,
+		EntityIDs:   entities,
+		KeyID:       auth.FromContext(c).KeyID,
+	})
+	if errors.Is(err, tips.ErrNoReference) {
+		return apierr.BadRequest("documentIds and entityIds must be existing documents and entities")
+	}
+	if err != nil {
+		return err
+	}
+	return c.Status(fiber.StatusAccepted).JSON(TipReceipt{ID: t.ID, Status: t.Status})
+}
+
+// tipRefs deduplicates and bounds the IDs a tip points at
+func tipRefs(name string, ids []int) ([]int, error) {
+	slices.Sort(ids)
+	ids = slices.Compact(ids)
+	if len(ids) > maxTipRefs {
+		return nil, apierr.InvalidParam(name, "must list at most "+strconv.Itoa(maxTipRefs))
+	}
+	if len(ids) > 0 && ids[0] <= 0 {
+		return nil, apierr.InvalidParam(name, "must be positive integers")
+	}
+	if ids == nil {
+		ids = []int{}
+	}
+	return ids, nil
+}
+
+// ListTips returns the moderation queue, oldest first
+func ListTips(c *fiber.Ctx) error {
+	status, err := enumQuery(c, "status", tips.Statuses)
+	if err != nil {
+		return err
+	}
+	limit, err := limitQuery(c, 50, 200)
+	if err != nil {
+		return err
+	}
+	offset, err := offsetQuery(c)
+	if err != nil {
+		return err
+	}
+
+	list, err := tips.List(c.UserContext(), db.Pool(), status, limit, offset)
+	if err != nil {
+		return err
+	}
+	return c.JSON(TipList{Tips: list, Count: len(list), Offset: offset, Limit: limit})
+}
+
+// GetTip returns one tip
+func GetTip(c *fiber.Ctx) error {
+	id, err := idParam(c)
+	if err != nil {
+		return err
+	}
+
+	t, err := tips.Get(c.UserContext(), db.Pool(), int64(id))
+	if err != nil {
+		return notFound(err, "tip")
+	}
+	return c.JSON(t)
+}
+
+// ReviewTip accepts, rejects or requeues a tip
+func ReviewTip(c *fiber.Ctx) error {
+	id, err := idParam(c)
+	if err != nil {
+		return err
+	}
+	var body TipReviewBody
+	if err := c.BodyParser(&body); err != nil {
+		return apierr.BadRequest("invalid body")
+	}
+	if !slices.Contains(tips.Statuses, body.Status) {
+		return apierr.InvalidParam("status", "must be one of "+strings.Join(tips.Statuses, ", "))
+	}
+	note := strings.TrimSpace(body.Note)
+	if len(note) > maxNotes {
+		return apierr.InvalidParam("note", "must be at most "+strconv.Itoa(maxNotes)+" characters")
+	}
+
+	t, err := tips.Review(c.UserContext(), db.Pool(), int64(id), auth.FromContext(c).KeyID, body.Status, note)
+	if err != nil {
+		return notFound(err, "tip")
+	}
+	audit.SetAffected(c, 1)
+	return c.JSON(t)
+}
+
+// ConvertTip makes a pattern finding from a tip and accepts it
+func ConvertTip(c *fiber.Ctx) error {
+	id, err := idParam(c)
+	if err != nil {
+		return err
+	}
+	var body TipPatternBody
+	if err := c.BodyParser(&body); err != nil {
+		return apierr.BadRequest("invalid body")
+	}
+	title := strings.TrimSpace(body.Title)
+	if title == "" {
+		return apierr.InvalidParam("title", "must not be empty")
+	}
+	description := strings.TrimSpace(body.Description)
+	if description == "" {
+		return apierr.InvalidParam("description", "must not be empty")
+	}
+	if body.Confidence != nil && (*body.Confidence < 0 || *body.Confidence > 1) {
+		return apierr.InvalidParam("confidence", "must be between 0 and 1")
+	}
+
+	t, err := tips.Convert(c.UserContext(), db.Pool(), int64(id), auth.FromContext(c).KeyID, tips.Pattern{
+		Title:       title,
+		Description: description,
+		Type:        strings.TrimSpace(body.PatternType),
+		Confidence:  body.Confidence,
+	})
+	if errors.Is(err, tips.ErrConverted) {
+		return apierr.New(fiber.StatusConflict, apierr.CodeConflict, "tip already has a pattern finding")
+	}
+	if err != nil {
+		return notFound(err, "tip")
+	}
+	audit.SetAffected(c, 1)
+	return c.Status(fiber.StatusCreated).JSON(t)
+}
@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/subculture-collective/epstein-db/api/internal/apierr"
+	"github.com/subculture-collective/epstein-db/api/internal/audit"
+	"github.com/subculture-collective/epstein-db/api/internal/auth"
+	"github.com/subculture-collective/epstein-db/api/internal/db"
+	"github.com/subculture-collective/epstein-db/api/internal/trash"
+)
+
+// maxDeleteReason bounds the reason given for a deletion
+const maxDeleteReason = 1000
+
+// DeleteBody is the optional body of DELETE /api/admin/documents/:id and
+// /api/admin/entities/:id
+type DeleteBody struct {
+	Reason string `json:"reason,omitempty" doc:"Why it was deleted, such as the bad ingest it came from"`
+}
+
+// TrashList is one page of deleted documents and entities
+type TrashList struct {
+	Items  []trash.Item `json:"items"`
+	Count  int          `json:"count"`
+	Offset int          `json:"offset"`
+	Limit  int          `json:"limit"`
+}
+
+// ListTrash returns deleted documents and entities, most recently deleted
+// first
+func ListTrash(c *fiber.Ctx) error {
+	kind, err := enumQuery(c, "kind", trash.Kinds)
+	if err != nil {
+		return err
+	}
+	limit, err := limitQuery(c, 50, 200)
+	if err != nil {
+		return err
+	}
+	offset, err := offsetQuery(c)
+	if err != nil {
+		return err
+	}
+
+	items, err := trash.List(c.UserContext(), db.Pool(), kind, limit, offset)
+	if err != nil {
+		return err
+	}
+	return c.JSON(TrashList{Items: items, Count: len(items), Offset: offset, Limit: limit})
+}
+
+// DeleteDocument soft-deletes a document
+func DeleteDocument(c *fiber.Ctx) error {
+	return deleteItem(c, trash.KindDocument)
+}
+
+// DeleteEntity soft-deletes an entity
+func DeleteEntity(c *fiber.Ctx) error {
+	return deleteItem(c, trash.KindEntity)
+}
+
+// RestoreDocument undeletes a document
+func RestoreDocument(c *fiber.Ctx) error {
+	return restoreItem(c, trash.KindDocument)
+}
+
+// RestoreEntity undeletes an entity
+func RestoreEntity(c *fiber.Ctx) error {
+	return restoreItem(c, trash.KindEntity)
+}
+
+func deleteItem(c *fiber.Ctx, kind string) error {
+	id, err := idParam(c)
+	if err != nil {
+		return err
+	}
+	var body DeleteBody
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&body); err != nil {
+			return apierr.BadRequest("invalid body")
+		}
+	}
+	reason := strings.TrimSpace(body.Reason)
+	if len(reason) > maxDeleteReason {
+		return apierr.InvalidParam("reason", "must be at most 1000 characters")
+	}
+
+	item, err := trash.Delete(c.UserContext(), db.Pool(), kind, id, auth.FromContext(c).KeyID, reason)
+	if errors.Is(err, trash.ErrNotFound) {
+		return apierr.NotFound(kind)
+	}
+	if errors.Is(err, trash.ErrDeleted) {
+		return apierr.New(fiber.StatusConflict, apierr.CodeConflict, "the "+kind+" is already deleted")
+	}
+	if err != nil {
+		return err
+	}
+	audit.SetAffected(c, int64(item.Links))
+	return c.JSON(item)
+}
+
+func restoreItem(c *fiber.Ctx, kind string) error {
+	id, err := idParam(c)
+	if err != nil {
+		return err
+	}
+
+	item, err := trash.Restore(c.UserContext(), db.Pool(), kind, id)
+	if errors.Is(err, trash.ErrNotFound) {
+		return apierr.NotFound("deleted " + kind)
+	}
+	if err != nil {
+		return err
+	}
+	audit.SetAffected(c, int64(item.Links))
+	return c.JSON(item)
+}
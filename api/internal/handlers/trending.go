@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/subculture-collective/epstein-db/api/internal/apierr"
+	"github.com/subculture-collective/epstein-db/api/internal/trending"
+)
+
+// Trending lists and view counting; cmd/server sets them with SetTrending.
+// Mirrors have no counter, so their reads don't count.
+var (
+	trendingCache *trending.Cache
+	entityViews   *trending.Counter
+)
+
+// SetTrending sets the cache GetTrending serves and the counter
+// CountEntityView adds to, which may be nil
+func SetTrending(cache *trending.Cache, counter *trending.Counter) {
+	trendingCache, entityViews = cache, counter
+}
+
+// CountEntityView counts a successful read of the entity in the id
+// parameter, revalidations included, toward the most-viewed list
+func CountEntityView(c *fiber.Ctx) error {
+	if err := c.Next(); err != nil {
+		return err
+	}
+	if entityViews == nil || c.Response().StatusCode() >= 400 {
+		return nil
+	}
+	if id, err := strconv.Atoi(c.Params("id")); err == nil {
+		entityViews.Add(id)
+	}
+	return nil
+}
+
+// GetTrending returns the landing page's lists in one response
+func GetTrending(c *fiber.Ctx) error {
+	if trendingCache == nil {
+		return apierr.New(fiber.StatusServiceUnavailable, apierr.CodeUnavailable, "trending unavailable")
+	}
+
+	t, err := trendingCache.Get(c.UserContext())
+	if err != nil {
+		return err
+	}
+	if notModified(c, "trending", t.GeneratedAt) {
+		return c.SendStatus(fiber.StatusNotModified)
+	}
+	return c.JSON(t)
+}
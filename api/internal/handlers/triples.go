@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/subculture-collective/epstein-db/api/internal/store"
+)
+
+// SearchTriples searches extracted subject-predicate-object relationships
+func SearchTriples(c *fiber.Ctx) error {
+	limit, err := limitQuery(c, 50, 200)
+	if err != nil {
+		return err
+	}
+
+	offset, err := offsetQuery(c)
+	if err != nil {
+		return err
+	}
+
+	// predicate accepts a comma-separated list
+	var predicates []string
+	for _, p := range strings.Split(c.Query("predicate", ""), ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			predicates = append(predicates, p)
+		}
+	}
+
+	subjectID, err := idQuery(c, "subject")
+	if err != nil {
+		return err
+	}
+	objectID, err := idQuery(c, "object")
+	if err != nil {
+		return err
+	}
+	entityID, err := idQuery(c, "entity")
+	if err != nil {
+		return err
+	}
+	documentID, err := idQuery(c, "document")
+	if err != nil {
+		return err
+	}
+	method, err := enumQuery(c, "method", extractionMethods)
+	if err != nil {
+		return err
+	}
+	sort, err := sortQuery(c, store.TripleSorts)
+	if err != nil {
+		return err
+	}
+
+	triples, err := data.SearchTriples(c.UserContext(), store.TripleFilter{
+		Predicates: predicates,
+		SubjectID:  subjectID,
+		ObjectID:   objectID,
+		EntityID:   entityID,
+		DocumentID: documentID,
+		Method:     method,
+		Sort:       sort,
+		Limit:      limit,
+		Offset:     offset,
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(TripleList{
+		Triples:  triples,
+		Count:    len(triples),
+		Offset:   offset,
+		Limit:    limit,
+		Warnings: skipped(c),
+	})
+}
+
+// ListPredicates returns the distinct predicates with usage counts
+func ListPredicates(c *fiber.Ctx) error {
+	predicates, err := data.Predicates(c.UserContext())
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(PredicateList{
+		Predicates: predicates,
+		Count:      len(predicates),
+		Warnings:   skipped(c),
+	})
+}
@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/subculture-collective/epstein-db/api/internal/apierr"
+	"github.com/subculture-collective/epstein-db/api/internal/auth"
+	"github.com/subculture-collective/epstein-db/api/internal/db"
+	"github.com/subculture-collective/epstein-db/api/internal/webhooks"
+)
+
+// deliveryStatuses filter the delivery log
+var deliveryStatuses = []string{webhooks.StatusPending, webhooks.StatusDelivered, webhooks.StatusFailed}
+
+// CreateWebhookBody is the body of POST /api/webhooks
+type CreateWebhookBody struct {
+	URL       string   `json:"url" doc:"http or https URL to POST events to"`
+	Events    []string `json:"events" doc:"entity.updated, document.created, crossref.confirmed or pattern.created"`
+	DatasetID *int     `json:"datasetId,omitempty" doc:"Only announce new documents in this dataset"`
+}
+
+// WebhookList is a list of webhooks
+type WebhookList struct {
+	Webhooks []webhooks.Webhook `json:"webhooks"`
+	Count    int                `json:"count"`
+}
+
+// DeliveryLog is one page of a webhook's deliveries
+type DeliveryLog struct {
+	Deliveries []webhooks.Delivery `json:"deliveries"`
+	Count      int                 `json:"count"`
+	Offset     int                 `json:"offset"`
+	Limit      int                 `json:"limit"`
+}
+
+// CreateWebhook subscribes a URL to events for the caller's key. The
+// response holds the signing secret, which isn't shown again.
+func CreateWebhook(c *fiber.Ctx) error {
+	var body CreateWebhookBody
+	if err := c.BodyParser(&body); err != nil {
+		return apierr.BadRequest("invalid body")
+	}
+
+	u, err := url.Parse(body.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return apierr.InvalidParam("url", "must be an absolute http or https URL")
+	}
+	if !webhooks.PublicHost(u.Hostname()) {
+		return apierr.InvalidParam("url", "must be a public address")
+	}
+	if len(body.Events) == 0 {
+		return apierr.InvalidParam("events", "must not be empty")
+	}
+	for _, e := range body.Events {
+		if !slices.Contains(webhooks.EventTypes, e) {
+			return apierr.InvalidParam("events", "must be some of "+strings.Join(webhooks.EventTypes, ", "))
+		}
+	}
+	slices.Sort(body.Events)
+	body.Events = slices.Compact(body.Events)
+	if body.DatasetID != nil && *body.DatasetID <= 0 {
+		return apierr.InvalidParam("datasetId", "must be positive")
+	}
+
+	w, err := webhooks.Create(c.UserContext(), db.Pool(), auth.FromContext(c).KeyID, u.String(), body.Events, body.DatasetID)
+	if err != nil {
+		return err
+	}
+	return c.Status(201).JSON(w)
+}
+
+// ListWebhooks returns the caller's webhooks, newest first
+func ListWebhooks(c *fiber.Ctx) error {
+	list, err := webhooks.List(c.UserContext(), db.Pool(), auth.FromContext(c).KeyID)
+	if err != nil {
+		return err
+	}
+	return c.JSON(WebhookList{Webhooks: list, Count: len(list)})
+}
+
+// DeleteWebhook removes one of the caller's webhooks
+func DeleteWebhook(c *fiber.Ctx) error {
+	id, err := idParam(c)
+	if err != nil {
+		return err
+	}
+
+	if err := webhooks.Delete(c.UserContext(), db.Pool(), auth.FromContext(c).KeyID, int64(id)); err != nil {
+		return notFound(err, "webhook")
+	}
+	return c.SendStatus(204)
+}
+
+// ListDeliveries returns the delivery log of one of the caller's webhooks
+func ListDeliveries(c *fiber.Ctx) error {
+	id, err := idParam(c)
+	if err != nil {
+		return err
+	}
+	status, err := enumQuery(c, "status", deliveryStatuses)
+	if err != nil {
+		return err
+	}
+	limit, err := limitQuery(c, 50, 200)
+	if err != nil {
+		return err
+	}
+	offset, err := offsetQuery(c)
+	if err != nil {
+		return err
+	}
+
+	deliveries, err := webhooks.Deliveries(c.UserContext(), db.Pool(), auth.FromContext(c).KeyID, int64(id), status, limit, offset)
+	if err != nil {
+		return notFound(err, "webhook")
+	}
+
+	return c.JSON(DeliveryLog{
+		Deliveries: deliveries,
+		Count:      len(deliveries),
+		Offset:     offset,
+		Limit:      limit,
+	})
+}
@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"errors"
+	"slices"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/subculture-collective/epstein-db/api/internal/apierr"
+	"github.com/subculture-collective/epstein-db/api/internal/audit"
+	"github.com/subculture-collective/epstein-db/api/internal/auth"
+	"github.com/subculture-collective/epstein-db/api/internal/db"
+	"github.com/subculture-collective/epstein-db/api/internal/worklist"
+)
+
+// maxClaim bounds the documents claimed at once
+const maxClaim = 200
+
+// WorklistClaimBody is the body of POST /api/admin/worklist/claims
+type WorklistClaimBody struct {
+	Missing     string `json:"missing" enum:"summary,dates,type,entities"`
+	DocumentIDs []int  `json:"documentIds" doc:"Up to 200"`
+	KeyID       int    `json:"keyId,omitempty" doc:"Assign to this API key instead of claiming for yourself"`
+	Reassign    bool   `json:"reassign,omitempty" doc:"Take documents claimed by another key"`
+}
+
+// missingQuery reads the required missing parameter
+func missingQuery(c *fiber.Ctx) (string, error) {
+	missing, err := enumQuery(c, "missing", worklist.Fields)
+	if err != nil {
+		return "", err
+	}
+	if missing == "" {
+		return "", apierr.InvalidParam("missing", "is required: one of "+strings.Join(worklist.Fields, ", "))
+	}
+	return missing, nil
+}
+
+// GetWorklist lists documents missing a field, with who has claimed them
+func GetWorklist(c *fiber.Ctx) error {
+	missing, err := missingQuery(c)
+	if err != nil {
+		return err
+	}
+	dataset, err := intQuery(c, "dataset", 0)
+	if err != nil {
+		return err
+	}
+	claim, err := enumQuery(c, "claim", worklist.ClaimFilters)
+	if err != nil {
+		return err
+	}
+	limit, err := limitQuery(c, 50, 500)
+	if err != nil {
+		return err
+	}
+	offset, err := offsetQuery(c)
+	if err != nil {
+		return err
+	}
+
+	page, err := worklist.List(c.UserContext(), db.Pool(), worklist.Filter{
+		Field:     missing,
+		DatasetID: dataset,
+		Claim:     claim,
+		KeyID:     auth.FromContext(c).KeyID,
+		Limit:     limit,
+		Offset:    offset,
+	})
+	if err != nil {
+		return err
+	}
+	return c.JSON(page)
+}
+
+// ClaimWorklist claims documents' missing field for the caller, or assigns
+// it to another key
+func ClaimWorklist(c *fiber.Ctx) error {
+	var body WorklistClaimBody
+	if err := c.BodyParser(&body); err != nil {
+		return apierr.BadRequest("invalid body")
+	}
+	if !slices.Contains(worklist.Fields, body.Missing) {
+		return apierr.InvalidParam("missing", "must be one of "+strings.Join(worklist.Fields, ", "))
+	}
+	if len(body.DocumentIDs) == 0 || len(body.DocumentIDs) > maxClaim {
+		return apierr.InvalidParam("documentIds", "must list 1 to 200 documents")
+	}
+	ids := slices.Clone(body.DocumentIDs)
+	slices.Sort(ids)
+	ids = slices.Compact(ids)
+	if ids[0] <= 0 {
+		return apierr.InvalidParam("documentIds", "must be positive integers")
+	}
+
+	caller := auth.FromContext(c).KeyID
+	key, assignedBy := caller, 0
+	if body.KeyID != 0 && body.KeyID != caller {
+		key, assignedBy = body.KeyID, caller
+	}
+	if key == 0 {
+		return apierr.InvalidParam("keyId", "is required when calling without an API key")
+	}
+
+	result, err := worklist.ClaimDocuments(c.UserContext(), db.Pool(), body.Missing, ids, key, assignedBy, body.Reassign)
+	if errors.Is(err, worklist.ErrUnknownKey) {
+		return apierr.InvalidParam("keyId", "no such API key")
+	}
+	if err != nil {
+		return err
+	}
+	audit.SetAffected(c, int64(len(result.Claimed)))
+	return c.JSON(result)
+}
+
+// ReleaseWorklistClaim drops the claim on a document's missing field
+func ReleaseWorklistClaim(c *fiber.Ctx) error {
+	id, err := idParam(c)
+	if err != nil {
+		return err
+	}
+	missing, err := missingQuery(c)
+	if err != nil {
+		return err
+	}
+
+	if err := worklist.Release(c.UserContext(), db.Pool(), id, missing); err != nil {
+		return notFound(err, "claim")
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
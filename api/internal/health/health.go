@@ -0,0 +1,168 @@
+// Package health checks the server's dependencies for readiness probes
+package health
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+// checkTimeout bounds each dependency check
+const checkTimeout = 2 * time.Second
+
+// Check statuses
+const (
+	StatusOK       = "ok"
+	StatusError    = "error"
+	StatusDegraded = "degraded"
+)
+
+// Checker probes one dependency. A failing required checker makes the
+// server unready; an optional one only degrades it.
+type Checker struct {
+	Name     string
+	Required bool
+	Check    func(ctx context.Context) error
+}
+
+// Result is the outcome of one check
+type Result struct {
+	Name      string  `json:"name"`
+	Status    string  `json:"status" enum:"ok,error,degraded"`
+	LatencyMS float64 `json:"latencyMs"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// Run runs every checker concurrently and reports whether all required ones
+// passed
+func Run(ctx context.Context, checkers []Checker) (bool, []Result) {
+	results := make([]Result, len(checkers))
+
+	var wg sync.WaitGroup
+	for i, ch := range checkers {
+		wg.Add(1)
+		go func(i int, ch Checker) {
+			defer wg.Done()
+
+			ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+			defer cancel()
+
+			start := time.Now()
+			err := ch.Check(ctx)
+			r := Result{
+				Name:      ch.Name,
+				Status:    StatusOK,
+				LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+			}
+			if err != nil {
+				r.Status = StatusDegraded
+				if ch.Required {
+					r.Status = StatusError
+				}
+				r.Error = err.Error()
+			}
+			results[i] = r
+		}(i, ch)
+	}
+	wg.Wait()
+
+	ready := true
+	for _, r := range results {
+		if r.Status == StatusError {
+			ready = false
+		}
+	}
+	return ready, results
+}
+
+// Postgres checks that pool can run a query
+func Postgres(pool *pgxpool.Pool) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		var one int
+		return pool.QueryRow(ctx, `SELECT 1`).Scan(&one)
+	}
+}
+
+// Redis checks that the server at rawURL answers PING, e.g.
+// redis://:password@host:6379, or rediss:// for TLS. It speaks RESP directly
+// to avoid a client dependency.
+func Redis(rawURL string) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			return errors.New("invalid REDIS_URL")
+		}
+		host := u.Host
+		if u.Port() == "" {
+			host = net.JoinHostPort(u.Hostname(), "6379")
+		}
+
+		var conn net.Conn
+		if u.Scheme == "rediss" {
+			d := tls.Dialer{Config: &tls.Config{ServerName: u.Hostname()}}
+			conn, err = d.DialContext(ctx, "tcp", host)
+		} else {
+			var d net.Dialer
+			conn, err = d.DialContext(ctx, "tcp", host)
+		}
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		if deadline, ok := ctx.Deadline(); ok {
+			conn.SetDeadline(deadline)
+		}
+		r := bufio.NewReader(conn)
+
+		if password, ok := u.User.Password(); ok {
+			args := []string{"AUTH", password}
+			if name := u.User.Username(); name != "" {
+				args = []string{"AUTH", name, password}
+			}
+			if err := redisCommand(conn, r, args...); err != nil {
+				return fmt.Errorf("auth: %w", err)
+			}
+		}
+		return redisCommand(conn, r, "PING")
+	}
+}
+
+func redisCommand(conn net.Conn, r *bufio.Reader, args ...string) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, a := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(a), a)
+	}
+	if _, err := conn.Write([]byte(b.String())); err != nil {
+		return err
+	}
+
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return err
+	}
+	if strings.HasPrefix(line, "-") {
+		return errors.New(strings.TrimSpace(line[1:]))
+	}
+	return nil
+}
+
+// Neo4j checks that the server at uri accepts the credentials. The driver
+// is created once and reused by every check.
+func Neo4j(uri, user, password string) (func(ctx context.Context) error, error) {
+	driver, err := neo4j.NewDriverWithContext(uri, neo4j.BasicAuth(user, password, ""))
+	if err != nil {
+		return nil, err
+	}
+	return driver.VerifyConnectivity, nil
+}
@@ -0,0 +1,145 @@
+package ingest
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/subculture-collective/epstein-db/api/internal/dates"
+	"github.com/subculture-collective/epstein-db/api/internal/sensitivity"
+)
+
+// docIDPattern matches the document separators in DOJ OCR dumps: EFTA00000001
+var docIDPattern = regexp.MustCompile(`^EFTA\d{8}$`)
+
+// Document is one document parsed from a release file
+type Document struct {
+	DocID     string
+	Text      string
+	LineStart int // 1-based line of the doc ID separator
+	LineEnd   int // last non-blank line of the document
+}
+
+// Stats summarizes a load
+type Stats struct {
+	Documents int   `json:"documents"`
+	Skipped   int   `json:"skipped"` // repeated doc IDs within the file
+	IDs       []int `json:"-"`
+}
+
+// Parse reads a combined OCR text file in which each document starts with
+// its doc ID on a line of its own, calling fn for every document
+func Parse(r io.Reader, fn func(Document) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 1024*1024), 16*1024*1024)
+
+	var current *Document
+	var lines []string
+	lineNo := 0
+	flush := func() error {
+		if current == nil || len(lines) == 0 {
+			return nil
+		}
+		current.Text = strings.Join(lines, "\n")
+		return fn(*current)
+	}
+
+	for scanner.Scan() {
+		lineNo++
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+
+		if docIDPattern.MatchString(trimmed) {
+			if err := flush(); err != nil {
+				return err
+			}
+			current = &Document{DocID: trimmed, LineStart: lineNo, LineEnd: lineNo}
+			lines = lines[:0]
+			continue
+		}
+
+		if current != nil && trimmed != "" {
+			lines = append(lines, line)
+			current.LineEnd = lineNo
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	return flush()
+}
+
+// Load parses r and upserts its documents into the given dataset, recording
+// the file's checksum and the line range each document came from
+func Load(ctx context.Context, pool *pgxpool.Pool, datasetID int, location string, r io.Reader) (Stats, error) {
+	var stats Stats
+	seen := make(map[string]bool)
+
+	source, err := RegisterSource(ctx, pool, location, datasetID)
+	if err != nil {
+		return stats, err
+	}
+
+	tee := source.Reader(r)
+	err = Parse(tee, func(doc Document) error {
+		// The OCR sometimes repeats documents
+		if seen[doc.DocID] {
+			stats.Skipped++
+			return nil
+		}
+		seen[doc.DocID] = true
+
+		// The document's dates are found in the same transaction, so they
+		// are as current as its text
+		var id int
+		err := pgx.BeginFunc(ctx, pool, func(tx pgx.Tx) error {
+			err := tx.QueryRow(ctx, `
+				INSERT INTO documents
+					(doc_id, dataset_id, file_path, full_text, page_count,
+					 source_file_id, source_line_start, source_line_end)
+				VALUES ($1, $2, $3, $4, 1, $5, $6, $7)
+				ON CONFLICT (doc_id) DO UPDATE SET
+					full_text = COALESCE(EXCLUDED.full_text, documents.full_text),
+					source_file_id = EXCLUDED.source_file_id,
+					source_line_start = EXCLUDED.source_line_start,
+					source_line_end = EXCLUDED.source_line_end,
+					updated_at = NOW()
+				RETURNING id
+			`, doc.DocID, datasetID, location, doc.Text,
+				source.ID, doc.LineStart, doc.LineEnd).Scan(&id)
+			if err != nil {
+				return err
+			}
+			if _, err := dates.Update(ctx, tx, id, doc.Text); err != nil {
+				return err
+			}
+			_, err = sensitivity.Update(ctx, tx, id, doc.Text)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+
+		source.Produced(doc.DocID)
+		stats.Documents++
+		stats.IDs = append(stats.IDs, id)
+		return nil
+	})
+	if err != nil {
+		return stats, err
+	}
+
+	// Drain anything after the last document so the checksum covers the
+	// whole file
+	if _, err := io.Copy(io.Discard, tee); err != nil {
+		return stats, err
+	}
+
+	return stats, source.Finish(ctx, pool)
+}
@@ -0,0 +1,88 @@
+package ingest
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"io"
+	"os"
+	"path"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Source tracks the checksum and size of a file while it is read
+type Source struct {
+	ID       int
+	hash     hash.Hash
+	size     int64
+	first    string
+	last     string
+	produced int
+}
+
+// RegisterSource records a source file before its rows are written so that
+// they can reference it
+func RegisterSource(ctx context.Context, pool *pgxpool.Pool, location string, datasetID int) (*Source, error) {
+	s := &Source{hash: sha256.New()}
+	err := pool.QueryRow(ctx, `
+		INSERT INTO source_files (filename, location, dataset_id)
+		VALUES ($1, $2, $3)
+		RETURNING id
+	`, path.Base(location), location, datasetID).Scan(&s.ID)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Reader wraps r so that everything read through it is checksummed
+func (s *Source) Reader(r io.Reader) io.Reader {
+	return io.TeeReader(r, writerFunc(func(p []byte) (int, error) {
+		s.size += int64(len(p))
+		return s.hash.Write(p)
+	}))
+}
+
+// Produced records a document created from this source
+func (s *Source) Produced(docID string) {
+	if s.first == "" {
+		s.first = docID
+	}
+	s.last = docID
+	s.produced++
+}
+
+// Finish stores the checksum, size and produced row range
+func (s *Source) Finish(ctx context.Context, pool *pgxpool.Pool) error {
+	_, err := pool.Exec(ctx, `
+		UPDATE source_files SET
+			sha256 = $2,
+			byte_size = $3,
+			document_count = $4,
+			first_doc_id = NULLIF($5, ''),
+			last_doc_id = NULLIF($6, ''),
+			completed_at = NOW()
+		WHERE id = $1
+	`, s.ID, hex.EncodeToString(s.hash.Sum(nil)), s.size, s.produced, s.first, s.last)
+	return err
+}
+
+// HashFile checksums a file on disk, for sources that are read by other tools
+func (s *Source) HashFile(name string) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(io.Discard, s.Reader(f))
+	return err
+}
+
+type writerFunc func(p []byte) (int, error)
+
+func (f writerFunc) Write(p []byte) (int, error) {
+	return f(p)
+}
@@ -0,0 +1,212 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Job statuses
+const (
+	StatusQueued    = "queued"
+	StatusRunning   = "running"
+	StatusCompleted = "completed"
+	StatusFailed    = "failed"
+)
+
+// Job is a unit of background work
+type Job struct {
+	ID           int64           `json:"id"`
+	Kind         string          `json:"kind"`
+	Params       json.RawMessage `json:"params"`
+	Status       string          `json:"status"`
+	Progress     int             `json:"progress"`
+	Total        *int            `json:"total"`
+	Result       json.RawMessage `json:"result"`
+	ErrorMessage *string         `json:"errorMessage"`
+	CreatedAt    time.Time       `json:"createdAt"`
+	StartedAt    *time.Time      `json:"startedAt"`
+	FinishedAt   *time.Time      `json:"finishedAt"`
+}
+
+// DecodeParams unmarshals the job's parameters into v
+func (j *Job) DecodeParams(v any) error {
+	if len(j.Params) == 0 {
+		return nil
+	}
+	return json.Unmarshal(j.Params, v)
+}
+
+// DecodeResult unmarshals the job's result into v
+func (j *Job) DecodeResult(v any) error {
+	if len(j.Result) == 0 {
+		return nil
+	}
+	return json.Unmarshal(j.Result, v)
+}
+
+// Queue is a Postgres-backed job queue
+type Queue struct {
+	pool *pgxpool.Pool
+}
+
+// NewQueue creates a queue on top of the jobs table
+func NewQueue(pool *pgxpool.Pool) *Queue {
+	return &Queue{pool: pool}
+}
+
+const jobColumns = `id, kind, params, status, progress, total, result,
+	error_message, created_at, started_at, finished_at`
+
+func scanJob(row pgx.Row) (*Job, error) {
+	var j Job
+	err := row.Scan(&j.ID, &j.Kind, &j.Params, &j.Status, &j.Progress, &j.Total,
+		&j.Result, &j.ErrorMessage, &j.CreatedAt, &j.StartedAt, &j.FinishedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &j, nil
+}
+
+// Enqueue adds a job and returns its ID
+func (q *Queue) Enqueue(ctx context.Context, kind string, params any) (int64, error) {
+	raw, err := json.Marshal(params)
+	if err != nil {
+		return 0, err
+	}
+
+	var id int64
+	err = q.pool.QueryRow(ctx, `
+		INSERT INTO jobs (kind, params) VALUES ($1, $2) RETURNING id
+	`, kind, raw).Scan(&id)
+	return id, err
+}
+
+// Claim marks the oldest queued job of the given kind as running and returns
+// it. It returns nil when the queue is empty. Concurrent workers never
+// claim the same job.
+func (q *Queue) Claim(ctx context.Context, kind string) (*Job, error) {
+	job, err := scanJob(q.pool.QueryRow(ctx, `
+		UPDATE jobs SET status = 'running', started_at = NOW()
+		WHERE id = (
+			SELECT id FROM jobs
+			WHERE kind = $1 AND status = 'queued'
+			ORDER BY created_at
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING `+jobColumns, kind))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	return job, err
+}
+
+// SetProgress records how far a running job has got
+func (q *Queue) SetProgress(ctx context.Context, id int64, progress, total int) error {
+	_, err := q.pool.Exec(ctx, `
+		UPDATE jobs SET progress = $2, total = $3 WHERE id = $1
+	`, id, progress, total)
+	return err
+}
+
+// Finish marks a job completed, or failed when jobErr is non-nil
+func (q *Queue) Finish(ctx context.Context, id int64, result any, jobErr error) error {
+	raw, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+
+	status := StatusCompleted
+	var message *string
+	if jobErr != nil {
+		status = StatusFailed
+		msg := jobErr.Error()
+		message = &msg
+	}
+
+	_, err = q.pool.Exec(ctx, `
+		UPDATE jobs SET status = $2, result = $3, error_message = $4, finished_at = NOW()
+		WHERE id = $1
+	`, id, status, raw, message)
+	return err
+}
+
+// Get returns a single job
+func (q *Queue) Get(ctx context.Context, id int64) (*Job, error) {
+	return scanJob(q.pool.QueryRow(ctx, `SELECT `+jobColumns+` FROM jobs WHERE id = $1`, id))
+}
+
+// List returns recent jobs, optionally filtered by kind and status
+func (q *Queue) List(ctx context.Context, kind, status string, limit int) ([]*Job, error) {
+	rows, err := q.pool.Query(ctx, `
+		SELECT `+jobColumns+`
+		FROM jobs
+		WHERE ($1 = '' OR kind = $1)
+		  AND ($2 = '' OR status = $2)
+		ORDER BY created_at DESC
+		LIMIT $3
+	`, kind, status, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var jobs []*Job
+	for rows.Next() {
+		j, err := scanJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, j)
+	}
+
+	return jobs, rows.Err()
+}
+
+type contextKey struct{}
+
+// IDFromContext returns the ID of the job being run with ctx, if any
+func IDFromContext(ctx context.Context) (int64, bool) {
+	id, ok := ctx.Value(contextKey{}).(int64)
+	return id, ok
+}
+
+// Handler processes a claimed job and returns a result to store on it
+type Handler func(ctx context.Context, job *Job) (any, error)
+
+// Work claims and runs jobs of one kind until ctx is cancelled, polling the
+// queue every interval when it is empty
+func (q *Queue) Work(ctx context.Context, kind string, interval time.Duration, handle Handler) error {
+	for ctx.Err() == nil {
+		job, err := q.Claim(ctx, kind)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		if job == nil {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(interval):
+			}
+			continue
+		}
+
+		result, jobErr := handle(context.WithValue(ctx, contextKey{}, job.ID), job)
+
+		// Record the outcome even if we're shutting down
+		if err := q.Finish(context.WithoutCancel(ctx), job.ID, result, jobErr); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
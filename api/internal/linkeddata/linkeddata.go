@@ -0,0 +1,154 @@
+// Package linkeddata describes the entity graph as RDF, so it can be read by
+// linked-data tools and reconciled against Wikidata and the like. Entities
+// are typed with schema.org where a class fits; extracted relationships use
+// a vocabulary of our own under <base>/vocab#, one property per predicate.
+package linkeddata
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/subculture-collective/epstein-db/api/internal/models"
+)
+
+// MediaTypes
+const (
+	MediaJSONLD   = "application/ld+json"
+	MediaNTriples = "application/n-triples"
+)
+
+// Vocabularies
+const (
+	Schema = "https://schema.org/"
+	RDF    = "http://www.w3.org/1999/02/22-rdf-syntax-ns#"
+	RDFS   = "http://www.w3.org/2000/01/rdf-schema#"
+	XSD    = "http://www.w3.org/2001/XMLSchema#"
+)
+
+// classes maps entity types to schema.org classes; others are Things
+var classes = map[string]string{
+	"person":       "Person",
+	"organization": "Organization",
+	"location":     "Place",
+}
+
+// Class returns the schema.org class of an entity type
+func Class(entityType string) string {
+	if c, ok := classes[entityType]; ok {
+		return c
+	}
+	return "Thing"
+}
+
+// EntityIRI identifies an entity; it resolves to the entity's API resource
+func EntityIRI(base string, id int) string {
+	return strings.TrimSuffix(base, "/") + "/api/entities/" + strconv.Itoa(id)
+}
+
+// Vocab is the namespace of our own properties
+func Vocab(base string) string {
+	return strings.TrimSuffix(base, "/") + "/vocab#"
+}
+
+// Property names the property for an extracted predicate, in lower camel
+// case: "flew with" becomes flewWith
+func Property(predicate string) string {
+	var b strings.Builder
+	upper := false
+	for _, r := range predicate {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = b.Len() > 0
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		} else {
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	if b.Len() == 0 {
+		return "relatedTo"
+	}
+	return b.String()
+}
+
+// Aliases decodes an entity's aliases column
+func Aliases(raw []byte) []string {
+	var aliases []string
+	if len(raw) > 0 {
+		_ = json.Unmarshal(raw, &aliases)
+	}
+	return aliases
+}
+
+// Entity is the JSON-LD document for e and its relationships. triples may
+// repeat a relationship, once per mention; each is listed once. Relationships
+// pointing at e are under @reverse.
+func Entity(base string, e *models.Entity, triples []models.Triple) map[string]any {
+	doc := map[string]any{
+		"@context": map[string]any{
+			"@vocab": Schema,
+			"edb":    Vocab(base),
+		},
+		"@id":   EntityIRI(base, e.ID),
+		"@type": Class(e.EntityType),
+		"name":  e.CanonicalName,
+		"url":   EntityIRI(base, e.ID),
+
+		"edb:entityType": e.EntityType,
+	}
+	if len(e.Aliases) > 0 {
+		doc["alternateName"] = e.Aliases
+	}
+	if e.Description != nil {
+		doc["description"] = *e.Description
+	}
+	if e.Layer != nil {
+		doc["edb:layer"] = *e.Layer
+	}
+	if e.DocumentCount != nil {
+		doc["edb:documentCount"] = *e.DocumentCount
+	}
+	if e.ConnectionCount != nil {
+		doc["edb:connectionCount"] = *e.ConnectionCount
+	}
+
+	outgoing := map[string][]any{}
+	incoming := map[string][]any{}
+	seen := map[string]bool{}
+	for _, t := range triples {
+		key := "edb:" + Property(t.Predicate)
+		out := t.Subject.ID == e.ID
+		other := t.Object
+		if !out {
+			other = t.Subject
+		}
+		id := strconv.FormatBool(out) + key + strconv.Itoa(other.ID)
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		node := map[string]any{"@id": EntityIRI(base, other.ID), "name": other.CanonicalName}
+		if out {
+			outgoing[key] = append(outgoing[key], node)
+		} else {
+			incoming[key] = append(incoming[key], node)
+		}
+	}
+	for key, nodes := range outgoing {
+		doc[key] = nodes
+	}
+	if len(incoming) > 0 {
+		reverse := map[string]any{}
+		for key, nodes := range incoming {
+			reverse[key] = nodes
+		}
+		doc["@reverse"] = reverse
+	}
+	return doc
+}
@@ -0,0 +1,168 @@
+package linkeddata
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/subculture-collective/epstein-db/api/internal/store"
+)
+
+// Dump writes the whole graph as N-Triples: every entity with its names and
+// counts, every distinct extracted relationship, and a declaration of each
+// property used. Rows are streamed, so memory stays flat however big the
+// graph is. Protected entities and their relationships are left out, and
+// their names masked in descriptions.
+//
+// The dump reads one snapshot in a transaction of its own, without the
+// pool's statement timeout: a big graph takes longer to send than any
+// request should run, and a cancelled scan would end the N-Triples early
+// with nothing to tell the client it's incomplete.
+func Dump(ctx context.Context, pool *pgxpool.Pool, out io.Writer, base string) error {
+	tx, err := pool.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	if _, err := tx.Exec(ctx, `SET LOCAL statement_timeout = 0`); err != nil {
+		return err
+	}
+
+	w := &writer{w: bufio.NewWriter(out)}
+	vocab := Vocab(base)
+	m, err := store.LoadMasker(ctx, tx)
+	if err != nil {
+		return err
+	}
+
+	rows, err := tx.Query(ctx, `
+		SELECT id, canonical_name, entity_type, description, layer,
+			   document_count, connection_count, aliases
+		FROM entities
+		WHERE deleted_at IS NULL AND NOT protected
+		ORDER BY id
+	`)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var (
+			id                int
+			name, entityType  string
+			description       *string
+			layer, docs, conn *int
+			aliases           []byte
+		)
+		if err := rows.Scan(&id, &name, &entityType, &description, &layer, &docs, &conn, &aliases); err != nil {
+			rows.Close()
+			return err
+		}
+		// Stop reading once the client has gone
+		if w.err != nil {
+			rows.Close()
+			return w.err
+		}
+
+		s := iri(EntityIRI(base, id))
+		w.triple(s, iri(RDF+"type"), iri(Schema+Class(entityType)))
+		w.triple(s, iri(Schema+"name"), literal(name))
+		for _, alias := range Aliases(aliases) {
+			w.triple(s, iri(Schema+"alternateName"), literal(alias))
+		}
+		if description != nil {
+			w.triple(s, iri(Schema+"description"), literal(m.Mask(*description)))
+		}
+		w.triple(s, iri(vocab+"entityType"), literal(entityType))
+		if layer != nil {
+			w.triple(s, iri(vocab+"layer"), integer(*layer))
+		}
+		if docs != nil {
+			w.triple(s, iri(vocab+"documentCount"), integer(*docs))
+		}
+		if conn != nil {
+			w.triple(s, iri(vocab+"connectionCount"), integer(*conn))
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	rows, err = tx.Query(ctx, `
+		SELECT t.predicate, t.subject_id, t.object_id
+		FROM triples t
+		JOIN documents d ON d.id = t.document_id
+		JOIN entities s ON s.id = t.subject_id
+		JOIN entities o ON o.id = t.object_id
+		WHERE d.deleted_at IS NULL AND s.deleted_at IS NULL AND o.deleted_at IS NULL
+		  AND NOT s.protected AND NOT o.protected
+		GROUP BY t.predicate, t.subject_id, t.object_id
+		ORDER BY t.predicate, t.subject_id, t.object_id
+	`)
+	if err != nil {
+		return err
+	}
+	declared := map[string]bool{}
+	for rows.Next() {
+		var predicate string
+		var subject, object int
+		if err := rows.Scan(&predicate, &subject, &object); err != nil {
+			rows.Close()
+			return err
+		}
+		if w.err != nil {
+			rows.Close()
+			return w.err
+		}
+
+		p := iri(vocab + Property(predicate))
+		if !declared[p] {
+			declared[p] = true
+			w.triple(p, iri(RDF+"type"), iri(RDF+"Property"))
+			w.triple(p, iri(RDFS+"label"), literal(predicate))
+		}
+		w.triple(iri(EntityIRI(base, subject)), p, iri(EntityIRI(base, object)))
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if w.err != nil {
+		return w.err
+	}
+	return w.w.Flush()
+}
+
+// writer writes statements, keeping the first error
+type writer struct {
+	w   *bufio.Writer
+	err error
+}
+
+func (w *writer) triple(s, p, o string) {
+	if w.err == nil {
+		_, w.err = fmt.Fprintf(w.w, "%s %s %s .\n", s, p, o)
+	}
+}
+
+func iri(s string) string {
+	return "<" + s + ">"
+}
+
+func integer(n int) string {
+	return `"` + strconv.Itoa(n) + `"^^<` + XSD + `integer>`
+}
+
+// literalEscaper escapes a string for an N-Triples literal
+var literalEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`)
+
+func literal(s string) string {
+	return `"` + literalEscaper.Replace(s) + `"`
+}
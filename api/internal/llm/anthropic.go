@@ -0,0 +1,99 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+)
+
+const anthropicURL = "https://api.anthropic.com/v1/messages"
+
+// Anthropic talks to the Anthropic Messages API
+type Anthropic struct {
+	apiKey string
+	model  string
+	http   *http.Client
+}
+
+// NewAnthropic creates an Anthropic client for the given model
+func NewAnthropic(apiKey, model string) *Anthropic {
+	return &Anthropic{
+		apiKey: apiKey,
+		model:  model,
+		http:   &http.Client{Timeout: 2 * time.Minute},
+	}
+}
+
+// Complete sends a request to the Messages API
+func (a *Anthropic) Complete(ctx context.Context, req Request) (*Response, error) {
+	maxTokens := req.MaxTokens
+	if maxTokens == 0 {
+		maxTokens = 1024
+	}
+
+	body, err := json.Marshal(map[string]any{
+		"model":       a.model,
+		"system":      req.System,
+		"messages":    req.Messages,
+		"max_tokens":  maxTokens,
+		"temperature": req.Temperature,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, anthropicURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("content-type", "application/json")
+	httpReq.Header.Set("x-api-key", a.apiKey)
+	httpReq.Header.Set("anthropic-version", "2023-06-01")
+
+	resp, err := a.http.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, statusError(ProviderAnthropic, resp, raw)
+	}
+
+	var parsed struct {
+		Model   string `json:"model"`
+		Content []struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		} `json:"content"`
+		Usage struct {
+			InputTokens  int `json:"input_tokens"`
+			OutputTokens int `json:"output_tokens"`
+		} `json:"usage"`
+	}
+	if err := json.Unmarshal(raw, &parsed); err != nil {
+		return nil, err
+	}
+
+	var text bytes.Buffer
+	for _, block := range parsed.Content {
+		if block.Type == "text" {
+			text.WriteString(block.Text)
+		}
+	}
+
+	return &Response{
+		Text:         text.String(),
+		Model:        parsed.Model,
+		Provider:     ProviderAnthropic,
+		InputTokens:  parsed.Usage.InputTokens,
+		OutputTokens: parsed.Usage.OutputTokens,
+	}, nil
+}
@@ -0,0 +1,164 @@
+// Package llm is the one way the API and workers talk to language models.
+// Each task (summaries, dedup review, ...) can use its own provider and
+// model; every client retries transient failures and can record token use
+// and cost per job.
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Providers
+const (
+	ProviderAnthropic = "anthropic"
+	ProviderOpenAI    = "openai"
+	ProviderOllama    = "ollama"
+)
+
+// Tasks that use a model. Each can be pointed at its own model with
+// Settings.Tasks.
+const (
+	TaskSummarize = "summarize"
+	TaskDedup     = "dedup"
+	TaskTriples   = "triples"
+	TaskAsk       = "ask"
+	TaskBio       = "bio"
+	TaskVerify    = "verify"
+	TaskChat      = "chat"
+	TaskNarrative = "narrative"
+	TaskRoles     = "roles"
+)
+
+// Tasks lists every task, for configuration
+var Tasks = []string{TaskSummarize, TaskDedup, TaskTriples, TaskAsk, TaskBio, TaskVerify, TaskChat, TaskNarrative, TaskRoles}
+
+// Message is a single turn in a conversation with the model
+type Message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// Request describes a completion request
+type Request struct {
+	System      string
+	Messages    []Message
+	MaxTokens   int
+	Temperature float64
+}
+
+// Response is the model's reply along with token usage
+type Response struct {
+	Text         string
+	Model        string
+	Provider     string
+	InputTokens  int
+	OutputTokens int
+}
+
+// Client is implemented by every model provider
+type Client interface {
+	Complete(ctx context.Context, req Request) (*Response, error)
+}
+
+// ErrNotConfigured is returned when no provider credentials are available
+var ErrNotConfigured = errors.New("llm: no provider configured")
+
+// Settings are the credentials and model choices for every task. Models are
+// written provider:model, e.g. "openai:gpt-4o-mini" or "ollama:llama3.1"; a
+// bare model name is an Anthropic model.
+type Settings struct {
+	AnthropicAPIKey string
+	OpenAIAPIKey    string
+	OpenAIBaseURL   string // defaults to the OpenAI API
+	OllamaURL       string
+	Model           string            // used by tasks without their own
+	Tasks           map[string]string // task to model
+	MaxRetries      int
+
+	// Recorder, if set, receives the usage of every completion
+	Recorder Recorder
+}
+
+// ParseModel splits a provider:model spec
+func ParseModel(spec string) (provider, model string, err error) {
+	provider, model, found := strings.Cut(spec, ":")
+	if !found {
+		return ProviderAnthropic, spec, nil
+	}
+	switch provider {
+	case ProviderAnthropic, ProviderOpenAI, ProviderOllama:
+	default:
+		return "", "", fmt.Errorf("llm: unknown provider %q in %q", provider, spec)
+	}
+	if model == "" {
+		return "", "", fmt.Errorf("llm: no model in %q", spec)
+	}
+	return provider, model, nil
+}
+
+// New creates the client for task: its provider and model, retries and, if
+// s.Recorder is set, usage recording
+func New(s Settings, task string) (Client, error) {
+	spec := s.Tasks[task]
+	if spec == "" {
+		spec = s.Model
+	}
+	provider, model, err := ParseModel(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	var client Client
+	switch provider {
+	case ProviderAnthropic:
+		if s.AnthropicAPIKey == "" {
+			return nil, ErrNotConfigured
+		}
+		client = NewAnthropic(s.AnthropicAPIKey, model)
+	case ProviderOpenAI:
+		baseURL := s.OpenAIBaseURL
+		if baseURL == "" {
+			if s.OpenAIAPIKey == "" {
+				return nil, ErrNotConfigured
+			}
+			baseURL = "https://api.openai.com/v1"
+		}
+		client = NewOpenAI(baseURL, s.OpenAIAPIKey, model)
+	case ProviderOllama:
+		if s.OllamaURL == "" {
+			return nil, ErrNotConfigured
+		}
+		client = NewOllama(s.OllamaURL, model)
+	}
+
+	client = WithRetry(client, s.MaxRetries)
+	if s.Recorder != nil {
+		client = &metered{client: client, task: task, recorder: s.Recorder}
+	}
+	return client, nil
+}
+
+// UserPrompt is a convenience for single-turn requests
+func UserPrompt(system, prompt string, maxTokens int) Request {
+	return Request{
+		System:    system,
+		Messages:  []Message{{Role: "user", Content: prompt}},
+		MaxTokens: maxTokens,
+	}
+}
+
+// DecodeJSON extracts the first JSON object from a model reply, which is
+// sometimes wrapped in markdown or prose, and unmarshals it into v
+func DecodeJSON(text string, v any) error {
+	start := strings.Index(text, "{")
+	end := strings.LastIndex(text, "}")
+	if start < 0 || end < start {
+		return errors.New("llm: no JSON object in response")
+	}
+
+	return json.Unmarshal([]byte(text[start:end+1]), v)
+}
@@ -0,0 +1,122 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// OpenAI talks to an OpenAI-compatible chat completions API. Local servers
+// such as Ollama expose the same API.
+type OpenAI struct {
+	provider string
+	baseURL  string
+	apiKey   string
+	model    string
+	http     *http.Client
+}
+
+// NewOpenAI creates a client for the OpenAI API at baseURL
+func NewOpenAI(baseURL, apiKey, model string) *OpenAI {
+	return &OpenAI{
+		provider: ProviderOpenAI,
+		baseURL:  strings.TrimSuffix(baseURL, "/"),
+		apiKey:   apiKey,
+		model:    model,
+		http:     &http.Client{Timeout: 2 * time.Minute},
+	}
+}
+
+// NewOllama creates a client for a local Ollama server at baseURL, e.g.
+// http://localhost:11434
+func NewOllama(baseURL, model string) *OpenAI {
+	c := NewOpenAI(strings.TrimSuffix(baseURL, "/")+"/v1", "", model)
+	c.provider = ProviderOllama
+	// Local models can be slow to load and to generate
+	c.http.Timeout = 10 * time.Minute
+	return c
+}
+
+// Complete sends a request to the chat completions endpoint
+func (o *OpenAI) Complete(ctx context.Context, req Request) (*Response, error) {
+	maxTokens := req.MaxTokens
+	if maxTokens == 0 {
+		maxTokens = 1024
+	}
+
+	messages := make([]Message, 0, len(req.Messages)+1)
+	if req.System != "" {
+		messages = append(messages, Message{Role: "system", Content: req.System})
+	}
+	messages = append(messages, req.Messages...)
+
+	body, err := json.Marshal(map[string]any{
+		"model":       o.model,
+		"messages":    messages,
+		"max_tokens":  maxTokens,
+		"temperature": req.Temperature,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, o.baseURL+"/chat/completions", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if o.apiKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+o.apiKey)
+	}
+
+	resp, err := o.http.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, statusError(o.provider, resp, raw)
+	}
+
+	var parsed struct {
+		Model   string `json:"model"`
+		Choices []struct {
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+		} `json:"choices"`
+		Usage struct {
+			PromptTokens     int `json:"prompt_tokens"`
+			CompletionTokens int `json:"completion_tokens"`
+		} `json:"usage"`
+	}
+	if err := json.Unmarshal(raw, &parsed); err != nil {
+		return nil, err
+	}
+
+	var text string
+	if len(parsed.Choices) > 0 {
+		text = parsed.Choices[0].Message.Content
+	}
+	model := parsed.Model
+	if model == "" {
+		model = o.model
+	}
+
+	return &Response{
+		Text:         text,
+		Model:        model,
+		Provider:     o.provider,
+		InputTokens:  parsed.Usage.PromptTokens,
+		OutputTokens: parsed.Usage.CompletionTokens,
+	}, nil
+}
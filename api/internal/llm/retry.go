@@ -0,0 +1,97 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	retryBase = time.Second
+	retryMax  = 30 * time.Second
+)
+
+// StatusError is a non-200 reply from a provider
+type StatusError struct {
+	Provider   string
+	Status     int
+	Body       string
+	RetryAfter time.Duration // from the Retry-After header, if any
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s: status %d: %s", e.Provider, e.Status, e.Body)
+}
+
+// Temporary reports whether the request may succeed if retried: rate
+// limits, overload and server errors
+func (e *StatusError) Temporary() bool {
+	return e.Status == http.StatusTooManyRequests || e.Status >= 500
+}
+
+func statusError(provider string, resp *http.Response, body []byte) *StatusError {
+	e := &StatusError{Provider: provider, Status: resp.StatusCode, Body: string(body)}
+	if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && secs > 0 {
+		e.RetryAfter = time.Duration(secs) * time.Second
+	}
+	return e
+}
+
+// retrying retries transient failures with exponential backoff
+type retrying struct {
+	client  Client
+	retries int
+}
+
+// WithRetry wraps client to retry rate limits, server errors and network
+// failures up to retries times, backing off exponentially with jitter, or
+// as long as the provider's Retry-After asks
+func WithRetry(client Client, retries int) Client {
+	if retries <= 0 {
+		return client
+	}
+	return &retrying{client: client, retries: retries}
+}
+
+func (r *retrying) Complete(ctx context.Context, req Request) (*Response, error) {
+	for attempt := 0; ; attempt++ {
+		resp, err := r.client.Complete(ctx, req)
+		if err == nil || attempt == r.retries || !retryable(err) {
+			return resp, err
+		}
+
+		wait := min(retryBase<<attempt, retryMax)
+		wait = wait/2 + time.Duration(rand.Int63n(int64(wait/2)+1))
+		var status *StatusError
+		if errors.As(err, &status) && status.RetryAfter > wait {
+			wait = status.RetryAfter
+		}
+		slog.WarnContext(ctx, "llm request failed, retrying", "error", err, "attempt", attempt+1, "wait", wait)
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+func retryable(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var status *StatusError
+	if errors.As(err, &status) {
+		return status.Temporary()
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
@@ -0,0 +1,157 @@
+package llm
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/subculture-collective/epstein-db/api/internal/jobs"
+)
+
+// recordTimeout bounds the insert made after each completion
+const recordTimeout = 5 * time.Second
+
+// price is USD per million tokens
+type price struct {
+	input, output float64
+}
+
+// prices by model name prefix, most specific first. Models not listed, and
+// every local model, are recorded without a cost.
+var prices = []struct {
+	prefix string
+	price  price
+}{
+	{"claude-opus-4", price{15, 75}},
+	{"claude-sonnet-4", price{3, 15}},
+	{"claude-3-7-sonnet", price{3, 15}},
+	{"claude-3-5-sonnet", price{3, 15}},
+	{"claude-3-5-haiku", price{0.8, 4}},
+	{"gpt-4o-mini", price{0.15, 0.6}},
+	{"gpt-4o", price{2.5, 10}},
+	{"gpt-4.1-nano", price{0.1, 0.4}},
+	{"gpt-4.1-mini", price{0.4, 1.6}},
+	{"gpt-4.1", price{2, 8}},
+}
+
+// Cost estimates the USD cost of a completion, or nil if the model's price
+// is unknown
+func Cost(provider, model string, inputTokens, outputTokens int) *float64 {
+	if provider == ProviderOllama {
+		return nil
+	}
+	for _, p := range prices {
+		if strings.HasPrefix(model, p.prefix) {
+			cost := (float64(inputTokens)*p.price.input + float64(outputTokens)*p.price.output) / 1e6
+			return &cost
+		}
+	}
+	return nil
+}
+
+// Usage is the token use of one completion
+type Usage struct {
+	Task         string
+	Provider     string
+	Model        string
+	InputTokens  int
+	OutputTokens int
+	CostUSD      *float64
+	JobID        int64 // 0 outside a job
+}
+
+// Recorder stores usage
+type Recorder interface {
+	Record(ctx context.Context, u Usage) error
+}
+
+// metered reports the usage of each completion to a recorder
+type metered struct {
+	client   Client
+	task     string
+	recorder Recorder
+}
+
+func (m *metered) Complete(ctx context.Context, req Request) (*Response, error) {
+	resp, err := m.client.Complete(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	jobID, _ := jobs.IDFromContext(ctx)
+	u := Usage{
+		Task:         m.task,
+		Provider:     resp.Provider,
+		Model:        resp.Model,
+		InputTokens:  resp.InputTokens,
+		OutputTokens: resp.OutputTokens,
+		CostUSD:      Cost(resp.Provider, resp.Model, resp.InputTokens, resp.OutputTokens),
+		JobID:        jobID,
+	}
+
+	// A failed write shouldn't cost the completion that was paid for
+	recordCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), recordTimeout)
+	defer cancel()
+	if err := m.recorder.Record(recordCtx, u); err != nil {
+		slog.ErrorContext(ctx, "llm usage write failed", "error", err, "task", m.task)
+	}
+	return resp, nil
+}
+
+// DBRecorder stores usage in the llm_usage table
+type DBRecorder struct {
+	pool *pgxpool.Pool
+}
+
+// NewDBRecorder creates a recorder on top of pool
+func NewDBRecorder(pool *pgxpool.Pool) *DBRecorder {
+	return &DBRecorder{pool: pool}
+}
+
+// Record inserts one usage row
+func (r *DBRecorder) Record(ctx context.Context, u Usage) error {
+	_, err := r.pool.Exec(ctx, `
+		INSERT INTO llm_usage (job_id, task, provider, model, input_tokens, output_tokens, cost_usd)
+		VALUES (NULLIF($1, 0), $2, $3, $4, $5, $6, $7)
+	`, u.JobID, u.Task, u.Provider, u.Model, u.InputTokens, u.OutputTokens, u.CostUSD)
+	return err
+}
+
+// UsageTotal sums usage for one task and model
+type UsageTotal struct {
+	Task         string   `json:"task"`
+	Provider     string   `json:"provider"`
+	Model        string   `json:"model"`
+	Requests     int      `json:"requests"`
+	InputTokens  int64    `json:"inputTokens"`
+	OutputTokens int64    `json:"outputTokens"`
+	CostUSD      *float64 `json:"costUsd" doc:"Estimated; null when no model used has a known price"`
+}
+
+// JobUsage sums the usage recorded for a job by task and model
+func JobUsage(ctx context.Context, pool *pgxpool.Pool, jobID int64) ([]UsageTotal, error) {
+	rows, err := pool.Query(ctx, `
+		SELECT task, provider, model, COUNT(*), SUM(input_tokens), SUM(output_tokens), SUM(cost_usd)::float8
+		FROM llm_usage
+		WHERE job_id = $1
+		GROUP BY task, provider, model
+		ORDER BY task, provider, model
+	`, jobID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	totals := []UsageTotal{}
+	for rows.Next() {
+		var t UsageTotal
+		if err := rows.Scan(&t.Task, &t.Provider, &t.Model, &t.Requests, &t.InputTokens, &t.OutputTokens, &t.CostUSD); err != nil {
+			return nil, err
+		}
+		totals = append(totals, t)
+	}
+	return totals, rows.Err()
+}
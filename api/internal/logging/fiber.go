@@ -0,0 +1,70 @@
+package logging
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+// maxRequestIDLength bounds client-supplied IDs so they can't bloat logs
+const maxRequestIDLength = 128
+
+// Middleware assigns each request an ID, reusing the client's X-Request-ID
+// if it sent one, returns it in the response and logs the request once it
+// completes. The ID is stored in the user context so that anything logging
+// with that context is tagged with it.
+func Middleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		start := time.Now()
+
+		id := c.Get(fiber.HeaderXRequestID)
+		if id == "" || len(id) > maxRequestIDLength {
+			id = utils.UUIDv4()
+		}
+		c.Set(fiber.HeaderXRequestID, id)
+		c.Locals("requestid", id)
+
+		ctx := WithRequestID(c.UserContext(), id)
+		c.SetUserContext(ctx)
+
+		err := c.Next()
+
+		if err != nil {
+			// Render the error now so the status recorded is the one sent
+			if err := c.App().ErrorHandler(c, err); err != nil {
+				return err
+			}
+		}
+		status := c.Response().StatusCode()
+
+		level := slog.LevelInfo
+		switch {
+		case status >= 500:
+			level = slog.LevelError
+		case status >= 400:
+			level = slog.LevelWarn
+		}
+
+		attrs := []any{
+			"method", c.Method(),
+			"path", c.Path(),
+			"route", c.Route().Path,
+			"status", status,
+			"duration_ms", time.Since(start).Milliseconds(),
+			"ip", c.IP(),
+		}
+		if err != nil {
+			attrs = append(attrs, "error", err.Error())
+		}
+		slog.Log(ctx, level, "request", attrs...)
+		return nil
+	}
+}
+
+// FromContext returns the ID Middleware assigned to the request
+func FromContext(c *fiber.Ctx) string {
+	id, _ := c.Locals("requestid").(string)
+	return id
+}
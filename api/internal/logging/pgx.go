@@ -0,0 +1,41 @@
+package logging
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// sqlKey carries the statement from TraceQueryStart to TraceQueryEnd
+type sqlKey struct{}
+
+// QueryTracer logs failed SQL statements with the context's request ID
+type QueryTracer struct{}
+
+func (QueryTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	return context.WithValue(ctx, sqlKey{}, data.SQL)
+}
+
+func (QueryTracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	if data.Err == nil {
+		return
+	}
+
+	// Cancelled requests are the client's doing, not a database problem
+	level := slog.LevelError
+	if errors.Is(data.Err, context.Canceled) {
+		level = slog.LevelWarn
+	}
+
+	sql, _ := ctx.Value(sqlKey{}).(string)
+	attrs := []any{"error", data.Err.Error(), "sql", strings.Join(strings.Fields(sql), " ")}
+	var pgErr *pgconn.PgError
+	if errors.As(data.Err, &pgErr) {
+		attrs = append(attrs, "sqlstate", pgErr.Code)
+	}
+	slog.Log(ctx, level, "query failed", attrs...)
+}
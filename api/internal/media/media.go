@@ -0,0 +1,396 @@
+// Package media stores images attached to entities, such as photographs of
+// people and logos of organizations, with the source and license they were
+// found under. Files are kept on disk named by their SHA-256, so the same
+// image attached to several entities is stored once, and resized copies are
+// made on first request and kept beside the original.
+package media
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"image"
+	"image/color"
+	_ "image/gif" // registers GIF with image.Decode
+	"image/jpeg"
+	"image/png"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// MaxBytes bounds an uploaded image
+const MaxBytes = 4 << 20
+
+// MaxPixels bounds an image's width times height, so decoding one can't
+// exhaust memory
+const MaxPixels = 40_000_000
+
+// Widths are the sizes images are resized to. Serving only these keeps the
+// resized copies on disk bounded.
+var Widths = []int{64, 128, 256, 512, 1024}
+
+// ErrUnsupported is returned for a file that isn't a JPEG, PNG or GIF
+var ErrUnsupported = errors.New("media: not a JPEG, PNG or GIF image")
+
+// ErrTooLarge is returned for an image of more than MaxPixels
+var ErrTooLarge = errors.New("media: image has too many pixels")
+
+// Types are the content types of the images that can be attached
+var Types = []string{"image/jpeg", "image/png", "image/gif"}
+
+// contentTypes maps image.Decode's format names to content types
+var contentTypes = map[string]string{
+	"jpeg": "image/jpeg",
+	"png":  "image/png",
+	"gif":  "image/gif",
+}
+
+// extensions maps content types to file extensions
+var extensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+}
+
+// Media is an image attached to an entity
+type Media struct {
+	ID          int       `json:"id"`
+	EntityID    int       `json:"entityId"`
+	SHA256      string    `json:"sha256"`
+	ContentType string    `json:"contentType" enum:"image/jpeg,image/png,image/gif"`
+	Width       int       `json:"width"`
+	Height      int       `json:"height"`
+	ByteSize    int       `json:"byteSize"`
+	SourceURL   *string   `json:"sourceUrl" doc:"Where the image was found"`
+	License     *string   `json:"license" doc:"e.g. CC BY-SA 4.0 or public domain"`
+	Attribution *string   `json:"attribution" doc:"Credit line the license asks for"`
+	Caption     *string   `json:"caption"`
+	Primary     bool      `json:"primary" doc:"Shown on the entity's page and network node"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+// Meta describes an image
+type Meta struct {
+	SourceURL   string
+	License     string
+	Attribution string
+	Caption     string
+	Primary     bool
+}
+
+const columns = `id, entity_id, sha256, content_type, width, height, byte_size,
+	source_url, license, attribution, caption, is_primary, created_at`
+
+func scan(row pgx.Row) (*Media, error) {
+	var m Media
+	err := row.Scan(&m.ID, &m.EntityID, &m.SHA256, &m.ContentType, &m.Width, &m.Height, &m.ByteSize,
+		&m.SourceURL, &m.License, &m.Attribution, &m.Caption, &m.Primary, &m.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// List returns the images of an entity, the primary one first, then oldest
+// first
+func List(ctx context.Context, pool *pgxpool.Pool, entityID int) ([]Media, error) {
+	rows, err := pool.Query(ctx, `
+		SELECT `+columns+`
+		FROM entity_media
+		WHERE entity_id = $1
+		ORDER BY is_primary DESC, created_at, id
+	`, entityID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []Media{}
+	for rows.Next() {
+		m, err := scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *m)
+	}
+	return list, rows.Err()
+}
+
+// Get returns one image, or pgx.ErrNoRows
+func Get(ctx context.Context, pool *pgxpool.Pool, id int) (*Media, error) {
+	return scan(pool.QueryRow(ctx, `SELECT `+columns+` FROM entity_media WHERE id = $1`, id))
+}
+
+// Add stores data under dir and attaches it to an entity as uploaded by
+// keyID. An entity's first image becomes its primary one whatever
+// meta.Primary says. It returns ErrUnsupported or ErrTooLarge for data it
+// won't store, and pgx.ErrNoRows if there is no such entity.
+func Add(ctx context.Context, pool *pgxpool.Pool, dir string, entityID, keyID int, data []byte, meta Meta) (*Media, error) {
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupported
+	}
+	contentType, ok := contentTypes[format]
+	if !ok {
+		return nil, ErrUnsupported
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 {
+		return nil, ErrUnsupported
+	}
+	if cfg.Width*cfg.Height > MaxPixels {
+		return nil, ErrTooLarge
+	}
+
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+	if err := WriteFile(path(dir, hash, 0, contentType), data); err != nil {
+		return nil, err
+	}
+
+	var m *Media
+	err = pgx.BeginFunc(ctx, pool, func(tx pgx.Tx) error {
+		var primary bool
+		err := tx.QueryRow(ctx, `
+			SELECT $2 OR NOT EXISTS (SELECT 1 FROM entity_media WHERE entity_id = $1 AND is_primary)
+			FROM entities WHERE id = $1
+			FOR UPDATE
+		`, entityID, meta.Primary).Scan(&primary)
+		if err != nil {
+			return err
+		}
+		if primary {
+			if _, err := tx.Exec(ctx, `UPDATE entity_media SET is_primary = FALSE WHERE entity_id = $1 AND is_primary`, entityID); err != nil {
+				return err
+			}
+		}
+		m, err = scan(tx.QueryRow(ctx, `
+			INSERT INTO entity_media (entity_id, sha256, content_type, width, height, byte_size,
+				source_url, license, attribution, caption, is_primary, created_by)
+			VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''), NULLIF($8, ''), NULLIF($9, ''), NULLIF($10, ''), $11, NULLIF($12, 0))
+			RETURNING `+columns,
+			entityID, hash, contentType, cfg.Width, cfg.Height, len(data),
+			meta.SourceURL, meta.License, meta.Attribution, meta.Caption, primary, keyID))
+		if err != nil {
+			return err
+		}
+		return touch(ctx, tx, entityID)
+	})
+	if err != nil {
+		// Leave the file if another entity has the same image
+		removeUnused(ctx, pool, dir, hash)
+		return nil, err
+	}
+	return m, nil
+}
+
+// Update replaces an image's description, or returns pgx.ErrNoRows. Making
+// it primary demotes the entity's other images; an image stops being
+// primary only when another is made primary.
+func Update(ctx context.Context, pool *pgxpool.Pool, id int, meta Meta) (*Media, error) {
+	var m *Media
+	err := pgx.BeginFunc(ctx, pool, func(tx pgx.Tx) error {
+		if meta.Primary {
+			_, err := tx.Exec(ctx, `
+				UPDATE entity_media SET is_primary = FALSE
+				WHERE entity_id = (SELECT entity_id FROM entity_media WHERE id = $1) AND is_primary AND id <> $1
+			`, id)
+			if err != nil {
+				return err
+			}
+		}
+		var err error
+		m, err = scan(tx.QueryRow(ctx, `
+			UPDATE entity_media
+			SET source_url = NULLIF($2, ''), license = NULLIF($3, ''), attribution = NULLIF($4, ''),
+				caption = NULLIF($5, ''), is_primary = is_primary OR $6
+			WHERE id = $1
+			RETURNING `+columns,
+			id, meta.SourceURL, meta.License, meta.Attribution, meta.Caption, meta.Primary))
+		if err != nil {
+			return err
+		}
+		return touch(ctx, tx, m.EntityID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Delete detaches an image, making the entity's oldest remaining image
+// primary if it was, and removes its files once no entity uses them. It
+// returns pgx.ErrNoRows if there is no such image.
+func Delete(ctx context.Context, pool *pgxpool.Pool, dir string, id int) error {
+	var hash string
+	err := pgx.BeginFunc(ctx, pool, func(tx pgx.Tx) error {
+		var entityID int
+		var primary bool
+		err := tx.QueryRow(ctx, `
+			DELETE FROM entity_media WHERE id = $1
+			RETURNING entity_id, sha256, is_primary
+		`, id).Scan(&entityID, &hash, &primary)
+		if err != nil {
+			return err
+		}
+		if primary {
+			_, err = tx.Exec(ctx, `
+				UPDATE entity_media SET is_primary = TRUE
+				WHERE id = (SELECT id FROM entity_media WHERE entity_id = $1 ORDER BY created_at, id LIMIT 1)
+			`, entityID)
+			if err != nil {
+				return err
+			}
+		}
+		return touch(ctx, tx, entityID)
+	})
+	if err != nil {
+		return err
+	}
+	return removeUnused(ctx, pool, dir, hash)
+}
+
+// touch marks an entity updated, so cached copies of it and the network
+// pick up its new primary image
+func touch(ctx context.Context, tx pgx.Tx, entityID int) error {
+	_, err := tx.Exec(ctx, `UPDATE entities SET updated_at = NOW() WHERE id = $1`, entityID)
+	return err
+}
+
+// Open returns the file to serve for an image at width, one of Widths, or
+// the original for 0; its extension gives its content type. Images are never
+// enlarged, and resized copies are made on first request: JPEGs stay JPEGs,
+// other images become PNGs.
+func Open(dir string, m *Media, width int) (string, error) {
+	original := path(dir, m.SHA256, 0, m.ContentType)
+	if width == 0 || width >= m.Width {
+		return original, nil
+	}
+
+	contentType := "image/png"
+	if m.ContentType == "image/jpeg" {
+		contentType = "image/jpeg"
+	}
+	resized := path(dir, m.SHA256, width, contentType)
+	if _, err := os.Stat(resized); err == nil {
+		return resized, nil
+	}
+
+	f, err := os.Open(original)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	src, _, err := image.Decode(f)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	dst := Resize(src, width)
+	if contentType == "image/jpeg" {
+		err = jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 85})
+	} else {
+		err = png.Encode(&buf, dst)
+	}
+	if err != nil {
+		return "", err
+	}
+	if err := WriteFile(resized, buf.Bytes()); err != nil {
+		return "", err
+	}
+	return resized, nil
+}
+
+// path is where an image's file is kept: the original for width 0,
+// otherwise its copy resized to width. Files are spread over directories by
+// the first two digits of their hash.
+func path(dir, hash string, width int, contentType string) string {
+	name := hash
+	if width > 0 {
+		name += "-" + strconv.Itoa(width)
+	}
+	return filepath.Join(dir, hash[:2], name+extensions[contentType])
+}
+
+// WriteFile writes a file under a media directory unless it exists,
+// through a temporary file so readers never see part of one
+func WriteFile(name string, data []byte) error {
+	if _, err := os.Stat(name); err == nil {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(name), 0o750); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(name), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), name)
+}
+
+// removeUnused removes an image's files, resized copies included, if no
+// entity uses it
+func removeUnused(ctx context.Context, pool *pgxpool.Pool, dir, hash string) error {
+	var used bool
+	if err := pool.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM entity_media WHERE sha256 = $1)`, hash).Scan(&used); err != nil {
+		return err
+	}
+	if used {
+		return nil
+	}
+	files, err := filepath.Glob(filepath.Join(dir, hash[:2], hash+"*"))
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		if err := os.Remove(f); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+// Resize scales src down to width, keeping its aspect ratio, averaging the
+// source pixels each destination pixel covers
+func Resize(src image.Image, width int) image.Image {
+	b := src.Bounds()
+	height := max(1, int(math.Round(float64(b.Dy())*float64(width)/float64(b.Dx()))))
+	dst := image.NewRGBA64(image.Rect(0, 0, width, height))
+
+	for y := 0; y < height; y++ {
+		y0 := b.Min.Y + y*b.Dy()/height
+		y1 := max(b.Min.Y+(y+1)*b.Dy()/height, y0+1)
+		for x := 0; x < width; x++ {
+			x0 := b.Min.X + x*b.Dx()/width
+			x1 := max(b.Min.X+(x+1)*b.Dx()/width, x0+1)
+
+			var r, g, bl, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					r, g, bl, a = r+uint64(cr), g+uint64(cg), bl+uint64(cb), a+uint64(ca)
+					n++
+				}
+			}
+			dst.SetRGBA64(x, y, color.RGBA64{R: uint16(r / n), G: uint16(g / n), B: uint16(bl / n), A: uint16(a / n)})
+		}
+	}
+	return dst
+}
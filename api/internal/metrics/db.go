@@ -0,0 +1,200 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// QueryTracer counts failed statements. Set it as the pool's tracer.
+type QueryTracer struct{}
+
+func (QueryTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, _ pgx.TraceQueryStartData) context.Context {
+	return ctx
+}
+
+func (QueryTracer) TraceQueryEnd(_ context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	if data.Err == nil {
+		return
+	}
+
+	code := "other"
+	var pgErr *pgconn.PgError
+	switch {
+	case errors.As(data.Err, &pgErr):
+		code = pgErr.Code
+	case errors.Is(data.Err, context.Canceled):
+		code = "canceled"
+	case errors.Is(data.Err, context.DeadlineExceeded):
+		code = "timeout"
+	}
+	queryErrors.WithLabelValues(code).Inc()
+}
+
+// RegisterPool exports pool statistics and the job and ingestion counts
+// stored in the database. The counts are read at scrape time, so they cover
+// work done by every worker process, not just this one.
+func RegisterPool(pool *pgxpool.Pool) {
+	pools.add("primary", pool)
+	Registry.MustRegister(pools, &dbCollector{pool: pool})
+}
+
+// RegisterReplica exports statistics for the read replica's pool alongside
+// the primary's. Call it before serving.
+func RegisterReplica(pool *pgxpool.Pool) {
+	pools.add("replica", pool)
+}
+
+// Admission is the request queue in front of the database
+type Admission interface {
+	InUse() int
+	Capacity() int
+	Waiting() int
+	Rejected() int64
+	Open() bool
+}
+
+// RegisterAdmission exports the depth of the request queue in front of the
+// database and how many requests it has turned away
+func RegisterAdmission(a Admission) {
+	Registry.MustRegister(
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "db_admission_in_use",
+			Help: "Requests holding a database slot.",
+		}, func() float64 { return float64(a.InUse()) }),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "db_admission_capacity",
+			Help: "Requests that can hold a database slot at once.",
+		}, func() float64 { return float64(a.Capacity()) }),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "db_admission_waiting",
+			Help: "Requests queued for a database slot.",
+		}, func() float64 { return float64(a.Waiting()) }),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Name: "db_admission_rejected_total",
+			Help: "Requests turned away with 503 because the database was saturated.",
+		}, func() float64 { return float64(a.Rejected()) }),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "db_admission_breaker_open",
+			Help: "1 while the breaker turns away requests that would queue.",
+		}, func() float64 {
+			if a.Open() {
+				return 1
+			}
+			return 0
+		}),
+	)
+}
+
+var (
+	poolLabels       = []string{"pool"}
+	poolAcquired     = prometheus.NewDesc("db_pool_acquired_conns", "Connections currently in use.", poolLabels, nil)
+	poolIdle         = prometheus.NewDesc("db_pool_idle_conns", "Idle connections.", poolLabels, nil)
+	poolTotal        = prometheus.NewDesc("db_pool_total_conns", "Open connections.", poolLabels, nil)
+	poolMax          = prometheus.NewDesc("db_pool_max_conns", "Maximum pool size.", poolLabels, nil)
+	poolAcquires     = prometheus.NewDesc("db_pool_acquires_total", "Connections acquired from the pool.", poolLabels, nil)
+	poolEmptyWaits   = prometheus.NewDesc("db_pool_empty_acquires_total", "Acquires that had to wait for a connection.", poolLabels, nil)
+	poolWaitDuration = prometheus.NewDesc("db_pool_acquire_wait_seconds_total", "Time spent waiting to acquire connections.", poolLabels, nil)
+)
+
+// pools collects every registered pool, labelled by role
+var pools = &poolCollector{}
+
+type poolCollector struct {
+	names []string
+	pools []*pgxpool.Pool
+}
+
+func (p *poolCollector) add(name string, pool *pgxpool.Pool) {
+	p.names = append(p.names, name)
+	p.pools = append(p.pools, pool)
+}
+
+func (p *poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, d := range []*prometheus.Desc{poolAcquired, poolIdle, poolTotal, poolMax, poolAcquires, poolEmptyWaits, poolWaitDuration} {
+		ch <- d
+	}
+}
+
+func (p *poolCollector) Collect(ch chan<- prometheus.Metric) {
+	for i, pool := range p.pools {
+		name := p.names[i]
+		s := pool.Stat()
+		ch <- prometheus.MustNewConstMetric(poolAcquired, prometheus.GaugeValue, float64(s.AcquiredConns()), name)
+		ch <- prometheus.MustNewConstMetric(poolIdle, prometheus.GaugeValue, float64(s.IdleConns()), name)
+		ch <- prometheus.MustNewConstMetric(poolTotal, prometheus.GaugeValue, float64(s.TotalConns()), name)
+		ch <- prometheus.MustNewConstMetric(poolMax, prometheus.GaugeValue, float64(s.MaxConns()), name)
+		ch <- prometheus.MustNewConstMetric(poolAcquires, prometheus.CounterValue, float64(s.AcquireCount()), name)
+		ch <- prometheus.MustNewConstMetric(poolEmptyWaits, prometheus.CounterValue, float64(s.EmptyAcquireCount()), name)
+		ch <- prometheus.MustNewConstMetric(poolWaitDuration, prometheus.CounterValue, s.AcquireDuration().Seconds(), name)
+	}
+}
+
+var (
+	jobsDepth         = prometheus.NewDesc("jobs", "Queued and running background jobs.", []string{"kind", "status"}, nil)
+	documentsIngested = prometheus.NewDesc("ingest_documents_total", "Documents in the database.", nil, nil)
+	filesIngested     = prometheus.NewDesc("ingest_source_files_total", "Source files fully ingested.", nil, nil)
+	bytesIngested     = prometheus.NewDesc("ingest_source_bytes_total", "Bytes of source files fully ingested.", nil, nil)
+)
+
+// dbCollectTimeout bounds the queries run on each scrape
+const dbCollectTimeout = 5 * time.Second
+
+type dbCollector struct {
+	pool *pgxpool.Pool
+}
+
+func (d *dbCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- jobsDepth
+	ch <- documentsIngested
+	ch <- filesIngested
+	ch <- bytesIngested
+}
+
+func (d *dbCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), dbCollectTimeout)
+	defer cancel()
+
+	rows, err := d.pool.Query(ctx, `
+		SELECT kind, status, COUNT(*)
+		FROM jobs
+		WHERE status IN ('queued', 'running')
+		GROUP BY kind, status
+	`)
+	if err != nil {
+		log.Printf("metrics: job counts: %v", err)
+	} else {
+		for rows.Next() {
+			var kind, status string
+			var n int64
+			if err := rows.Scan(&kind, &status, &n); err != nil {
+				continue
+			}
+			ch <- prometheus.MustNewConstMetric(jobsDepth, prometheus.GaugeValue, float64(n), kind, status)
+		}
+		rows.Close()
+	}
+
+	var docs, files, bytes int64
+	err = d.pool.QueryRow(ctx, `
+		SELECT
+			(SELECT COUNT(*) FROM documents),
+			COUNT(*),
+			COALESCE(SUM(byte_size), 0)
+		FROM source_files
+		WHERE completed_at IS NOT NULL
+	`).Scan(&docs, &files, &bytes)
+	if err != nil {
+		log.Printf("metrics: ingestion counts: %v", err)
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(documentsIngested, prometheus.CounterValue, float64(docs))
+	ch <- prometheus.MustNewConstMetric(filesIngested, prometheus.CounterValue, float64(files))
+	ch <- prometheus.MustNewConstMetric(bytesIngested, prometheus.CounterValue, float64(bytes))
+}
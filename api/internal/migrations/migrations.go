@@ -0,0 +1,213 @@
+package migrations
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+//go:embed sql/*.sql
+var files embed.FS
+
+// lockID is the advisory lock held while migrating so that concurrent
+// deploys don't apply the same migration twice
+const lockID = 7_316_240_118
+
+// Migration is a numbered, forward-only schema change
+type Migration struct {
+	Version int
+	Name    string
+	SQL     string
+}
+
+// ErrSchemaBehind means the database needs migrations this binary expects
+var ErrSchemaBehind = errors.New("database schema is older than this build")
+
+// ErrSchemaAhead means the database was migrated by a newer build
+var ErrSchemaAhead = errors.New("database schema is newer than this build")
+
+// All returns the embedded migrations ordered by version. Files are named
+// NNN_description.sql.
+func All() ([]Migration, error) {
+	entries, err := fs.ReadDir(files, "sql")
+	if err != nil {
+		return nil, err
+	}
+
+	var migrations []Migration
+	seen := make(map[int]string)
+	for _, entry := range entries {
+		name := entry.Name()
+		prefix, _, ok := strings.Cut(name, "_")
+		version, err := strconv.Atoi(prefix)
+		if !ok || err != nil {
+			return nil, fmt.Errorf("migrations: bad file name %q", name)
+		}
+		if other, dup := seen[version]; dup {
+			return nil, fmt.Errorf("migrations: %q and %q share version %d", other, name, version)
+		}
+		seen[version] = name
+
+		sql, err := files.ReadFile(path.Join("sql", name))
+		if err != nil {
+			return nil, err
+		}
+
+		migrations = append(migrations, Migration{
+			Version: version,
+			Name:    strings.TrimSuffix(name, ".sql"),
+			SQL:     string(sql),
+		})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// Latest returns the version this build expects
+func Latest() int {
+	all, err := All()
+	if err != nil || len(all) == 0 {
+		return 0
+	}
+	return all[len(all)-1].Version
+}
+
+// Current returns the database's schema version, or 0 if it has never
+// been migrated
+func Current(ctx context.Context, pool *pgxpool.Pool) (int, error) {
+	var version int
+	err := pool.QueryRow(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version)
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "42P01" { // undefined_table
+		return 0, nil
+	}
+
+	return version, err
+}
+
+// Check verifies that the database is at exactly the version this build
+// expects
+func Check(ctx context.Context, pool *pgxpool.Pool) (int, error) {
+	current, err := Current(ctx, pool)
+	if err != nil {
+		return 0, err
+	}
+
+	switch latest := Latest(); {
+	case current < latest:
+		return current, fmt.Errorf("%w: at version %d, want %d (run cmd/migrate up)", ErrSchemaBehind, current, latest)
+	case current > latest:
+		return current, fmt.Errorf("%w: at version %d, want %d", ErrSchemaAhead, current, latest)
+	}
+
+	return current, nil
+}
+
+// Up applies every pending migration, each in its own transaction, and
+// returns the ones applied
+func Up(ctx context.Context, pool *pgxpool.Pool) ([]Migration, error) {
+	all, err := All()
+	if err != nil {
+		return nil, err
+	}
+
+	var applied []Migration
+	err = withLock(ctx, pool, func(conn *pgxpool.Conn) error {
+		current, err := Current(ctx, pool)
+		if err != nil {
+			return err
+		}
+
+		for _, m := range all {
+			if m.Version <= current {
+				continue
+			}
+
+			err := pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+				if _, err := tx.Exec(ctx, m.SQL); err != nil {
+					return err
+				}
+				_, err := tx.Exec(ctx, `
+					INSERT INTO schema_migrations (version, name) VALUES ($1, $2)
+				`, m.Version, m.Name)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("migration %s: %w", m.Name, err)
+			}
+			applied = append(applied, m)
+		}
+
+		return nil
+	})
+
+	return applied, err
+}
+
+// Force records the database as being at version without running any SQL.
+// It is used to adopt databases created before migrations were tracked.
+func Force(ctx context.Context, pool *pgxpool.Pool, version int) error {
+	all, err := All()
+	if err != nil {
+		return err
+	}
+
+	return withLock(ctx, pool, func(conn *pgxpool.Conn) error {
+		return pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+			if _, err := tx.Exec(ctx, `DELETE FROM schema_migrations`); err != nil {
+				return err
+			}
+			for _, m := range all {
+				if m.Version > version {
+					break
+				}
+				if _, err := tx.Exec(ctx, `
+					INSERT INTO schema_migrations (version, name) VALUES ($1, $2)
+				`, m.Version, m.Name); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	})
+}
+
+func withLock(ctx context.Context, pool *pgxpool.Pool, fn func(conn *pgxpool.Conn) error) error {
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	if _, err := conn.Exec(ctx, `SELECT pg_advisory_lock($1)`, lockID); err != nil {
+		return err
+	}
+	defer conn.Exec(context.WithoutCancel(ctx), `SELECT pg_advisory_unlock($1)`, lockID)
+
+	if _, err := conn.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version     INTEGER PRIMARY KEY,
+			name        TEXT NOT NULL,
+			applied_at  TIMESTAMPTZ DEFAULT NOW()
+		)
+	`); err != nil {
+		return err
+	}
+
+	return fn(conn)
+}
@@ -0,0 +1,28 @@
+-- Entity deduplication review queue
+-- Populated by the LLM-assisted dedup worker (api/cmd/worker dedup)
+
+CREATE TABLE dedup_review_queue (
+    id              SERIAL PRIMARY KEY,
+    entity_a_id     INTEGER NOT NULL REFERENCES entities(id) ON DELETE CASCADE,
+    entity_b_id     INTEGER NOT NULL REFERENCES entities(id) ON DELETE CASCADE,
+    similarity      REAL NOT NULL,                  -- Trigram similarity of canonical names
+
+    -- Model decision
+    decision        TEXT NOT NULL,                  -- same, different, uncertain
+    confidence      REAL,
+    reasoning       TEXT,
+    model           TEXT,
+
+    -- Review
+    status          TEXT DEFAULT 'pending',         -- pending, approved, rejected
+    reviewed_at     TIMESTAMPTZ,
+    reviewed_by     TEXT,
+
+    created_at      TIMESTAMPTZ DEFAULT NOW(),
+
+    UNIQUE(entity_a_id, entity_b_id),
+    CHECK (entity_a_id < entity_b_id)
+);
+
+CREATE INDEX idx_dedup_status ON dedup_review_queue(status);
+CREATE INDEX idx_dedup_decision ON dedup_review_queue(decision);
@@ -0,0 +1,14 @@
+-- Provenance for extracted triples
+-- Populated by the triple extraction worker (api/cmd/worker triples)
+
+ALTER TABLE triples
+    ADD COLUMN sentence          TEXT,              -- Source sentence
+    ADD COLUMN sentence_start    INTEGER,           -- Character offset of sentence in full_text
+    ADD COLUMN sentence_end      INTEGER,
+    ADD COLUMN extraction_method TEXT;              -- rule, llm
+
+ALTER TABLE documents
+    ADD COLUMN triples_extracted_at TIMESTAMPTZ;
+
+CREATE INDEX idx_triples_method ON triples(extraction_method);
+CREATE INDEX idx_documents_triples_pending ON documents(id) WHERE triples_extracted_at IS NULL;
@@ -0,0 +1,31 @@
+-- Background job queue and summary citations
+
+-- ============================================================================
+-- JOBS
+-- ============================================================================
+
+CREATE TABLE jobs (
+    id              BIGSERIAL PRIMARY KEY,
+    kind            TEXT NOT NULL,                  -- summarize, embed, ...
+    params          JSONB DEFAULT '{}',
+    status          TEXT DEFAULT 'queued',          -- queued, running, completed, failed
+    progress        INTEGER DEFAULT 0,
+    total           INTEGER,
+    result          JSONB,
+    error_message   TEXT,
+    created_at      TIMESTAMPTZ DEFAULT NOW(),
+    started_at      TIMESTAMPTZ,
+    finished_at     TIMESTAMPTZ
+);
+
+CREATE INDEX idx_jobs_queued ON jobs(kind, created_at) WHERE status = 'queued';
+CREATE INDEX idx_jobs_status ON jobs(status);
+
+-- ============================================================================
+-- SUMMARIES
+-- ============================================================================
+
+ALTER TABLE documents
+    ADD COLUMN summary_citations JSONB DEFAULT '[]',   -- [{quote, start, end}]
+    ADD COLUMN summary_model     TEXT,
+    ADD COLUMN summarized_at     TIMESTAMPTZ;
@@ -0,0 +1,33 @@
+-- Document chunks and embeddings for semantic search
+-- Populated by the embedding worker (api/cmd/worker embed)
+
+CREATE EXTENSION IF NOT EXISTS vector;
+
+CREATE TABLE document_chunks (
+    id              SERIAL PRIMARY KEY,
+    document_id     INTEGER NOT NULL REFERENCES documents(id) ON DELETE CASCADE,
+    chunk_index     INTEGER NOT NULL,               -- Order within document
+
+    -- Content
+    content         TEXT NOT NULL,
+    char_start      INTEGER NOT NULL,               -- Offsets into documents.full_text
+    char_end        INTEGER NOT NULL,
+
+    -- Embedding
+    embedding       vector(1536),
+    model           TEXT NOT NULL,
+
+    created_at      TIMESTAMPTZ DEFAULT NOW(),
+
+    UNIQUE(document_id, chunk_index)
+);
+
+CREATE INDEX idx_chunks_document ON document_chunks(document_id);
+CREATE INDEX idx_chunks_embedding ON document_chunks USING hnsw (embedding vector_cosine_ops);
+
+-- A document is stale when its text changed after it was embedded, or it
+-- was embedded with a different model or chunking configuration
+ALTER TABLE documents
+    ADD COLUMN embedded_at        TIMESTAMPTZ,
+    ADD COLUMN embedded_text_hash TEXT,             -- md5(full_text) at embedding time
+    ADD COLUMN embedding_config   TEXT;             -- model/size/overlap fingerprint
@@ -0,0 +1,12 @@
+-- Data quality report history
+-- Written by the quality checks (api/internal/quality), nightly and on demand
+
+CREATE TABLE quality_reports (
+    id              SERIAL PRIMARY KEY,
+    checks          JSONB NOT NULL,                 -- [{name, severity, count, sample}]
+    failing         INTEGER NOT NULL,               -- Checks with a non-zero count
+    duration_ms     INTEGER,
+    generated_at    TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX idx_quality_reports_generated ON quality_reports(generated_at DESC);
@@ -0,0 +1,21 @@
+-- Dataset registry for incremental releases
+-- Maintained by the release watcher (api/cmd/worker watch)
+
+CREATE TABLE datasets (
+    id              INTEGER PRIMARY KEY,            -- Matches documents.dataset_id
+    name            TEXT NOT NULL,
+    source          TEXT UNIQUE,                    -- File path or URL the release was loaded from
+    status          TEXT DEFAULT 'registered',      -- registered, ingesting, processing, ready, failed
+    document_count  INTEGER DEFAULT 0,
+    error_message   TEXT,
+    registered_at   TIMESTAMPTZ DEFAULT NOW(),
+    ready_at        TIMESTAMPTZ
+);
+
+CREATE INDEX idx_datasets_status ON datasets(status);
+
+-- Register datasets loaded before the registry existed
+INSERT INTO datasets (id, name, status, document_count, ready_at)
+SELECT dataset_id, 'DataSet ' || dataset_id, 'ready', COUNT(*), NOW()
+FROM documents
+GROUP BY dataset_id;
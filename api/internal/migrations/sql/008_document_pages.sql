@@ -0,0 +1,17 @@
+-- Per-page text for documents, including OCR output for raw scans
+
+CREATE TABLE document_pages (
+    id              SERIAL PRIMARY KEY,
+    document_id     INTEGER NOT NULL REFERENCES documents(id) ON DELETE CASCADE,
+    page_number     INTEGER NOT NULL,               -- 1-based
+    text            TEXT,
+    confidence      REAL,                           -- Mean OCR word confidence, 0-100
+    source          TEXT DEFAULT 'ocr',             -- ocr, text_layer
+    engine          TEXT,                           -- e.g. tesseract 5.3.0
+    created_at      TIMESTAMPTZ DEFAULT NOW(),
+
+    UNIQUE(document_id, page_number)
+);
+
+CREATE INDEX idx_pages_document ON document_pages(document_id);
+CREATE INDEX idx_pages_low_confidence ON document_pages(confidence) WHERE confidence < 60;
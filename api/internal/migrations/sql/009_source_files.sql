@@ -0,0 +1,28 @@
+-- Provenance for ingested source files
+
+CREATE TABLE source_files (
+    id              SERIAL PRIMARY KEY,
+    filename        TEXT NOT NULL,                  -- Base name of the release file or scan
+    location        TEXT,                           -- Path or URL it was read from
+    sha256          TEXT,                           -- Hex digest, set when ingestion finishes
+    byte_size       BIGINT,
+    dataset_id      INTEGER,
+
+    -- Rows produced
+    document_count  INTEGER DEFAULT 0,
+    first_doc_id    TEXT,
+    last_doc_id     TEXT,
+
+    ingested_at     TIMESTAMPTZ DEFAULT NOW(),
+    completed_at    TIMESTAMPTZ
+);
+
+CREATE INDEX idx_source_files_sha256 ON source_files(sha256);
+CREATE INDEX idx_source_files_dataset ON source_files(dataset_id);
+
+ALTER TABLE documents
+    ADD COLUMN source_file_id    INTEGER REFERENCES source_files(id) ON DELETE SET NULL,
+    ADD COLUMN source_line_start INTEGER,           -- 1-based line range within the source file
+    ADD COLUMN source_line_end   INTEGER;
+
+CREATE INDEX idx_documents_source_file ON documents(source_file_id);
@@ -0,0 +1,14 @@
+-- API keys for authenticated access. Only a SHA-256 hash of each key is
+-- stored; the key itself is shown once when it is created.
+
+CREATE TABLE api_keys (
+    id              SERIAL PRIMARY KEY,
+    name            TEXT NOT NULL,                  -- Who or what the key was issued to
+    key_hash        TEXT NOT NULL UNIQUE,           -- Hex SHA-256 of the key
+    key_prefix      TEXT NOT NULL,                  -- First characters, to recognise a key in logs
+    role            TEXT NOT NULL CHECK (role IN ('public', 'researcher', 'admin')),
+
+    created_at      TIMESTAMPTZ DEFAULT NOW(),
+    last_used_at    TIMESTAMPTZ,
+    revoked_at      TIMESTAMPTZ
+);
@@ -0,0 +1,22 @@
+-- Keep updated_at current on documents and entities, so the API can answer
+-- conditional requests from it
+
+CREATE OR REPLACE FUNCTION set_updated_at() RETURNS TRIGGER AS $$
+BEGIN
+    NEW.updated_at = NOW();
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trigger_documents_updated_at
+BEFORE UPDATE ON documents
+FOR EACH ROW WHEN (OLD.* IS DISTINCT FROM NEW.*)
+EXECUTE FUNCTION set_updated_at();
+
+CREATE TRIGGER trigger_entities_updated_at
+BEFORE UPDATE ON entities
+FOR EACH ROW WHEN (OLD.* IS DISTINCT FROM NEW.*)
+EXECUTE FUNCTION set_updated_at();
+
+-- The network is as fresh as the most recently changed entity
+CREATE INDEX idx_entities_updated_at ON entities(updated_at);
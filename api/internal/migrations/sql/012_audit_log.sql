@@ -0,0 +1,17 @@
+-- Record of admin actions taken through the API
+
+CREATE TABLE audit_log (
+    id              BIGSERIAL PRIMARY KEY,
+    actor           TEXT NOT NULL,                  -- API key name
+    key_id          INTEGER REFERENCES api_keys(id) ON DELETE SET NULL,
+    action          TEXT NOT NULL,                  -- Method and route, e.g. POST /api/admin/recount
+    params          JSONB DEFAULT '{}',             -- Request body and query
+    status          INTEGER NOT NULL,               -- HTTP status of the response
+    affected_rows   BIGINT,                         -- Rows changed, when known at request time
+    job_id          BIGINT,                         -- Job queued by the action, if any
+    request_id      TEXT,
+    created_at      TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX idx_audit_log_created ON audit_log(created_at DESC);
+CREATE INDEX idx_audit_log_action ON audit_log(action, created_at DESC);
@@ -0,0 +1,82 @@
+-- Publish changes on the epstein_events channel (see api/internal/events),
+-- whichever process makes them. Payloads carry IDs, not rows, to stay under
+-- the 8000 byte NOTIFY limit.
+
+CREATE OR REPLACE FUNCTION publish_event(event_type TEXT, data JSONB) RETURNS VOID AS $$
+BEGIN
+    PERFORM pg_notify('epstein_events', json_build_object(
+        'type', event_type,
+        'data', data,
+        'time', NOW()
+    )::text);
+END;
+$$ LANGUAGE plpgsql;
+
+-- Job progress and completion
+CREATE OR REPLACE FUNCTION notify_job_change() RETURNS TRIGGER AS $$
+BEGIN
+    IF NEW.status IS DISTINCT FROM OLD.status AND NEW.status IN ('completed', 'failed') THEN
+        PERFORM publish_event('job.' || NEW.status, jsonb_build_object(
+            'id', NEW.id, 'kind', NEW.kind, 'error', NEW.error_message));
+    ELSIF NEW.progress IS DISTINCT FROM OLD.progress THEN
+        PERFORM publish_event('job.progress', jsonb_build_object(
+            'id', NEW.id, 'kind', NEW.kind, 'progress', NEW.progress, 'total', NEW.total));
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trigger_jobs_notify
+AFTER UPDATE OF status, progress ON jobs
+FOR EACH ROW EXECUTE FUNCTION notify_job_change();
+
+-- Dataset ingestion progress
+CREATE OR REPLACE FUNCTION notify_dataset_change() RETURNS TRIGGER AS $$
+BEGIN
+    PERFORM publish_event('dataset.status', jsonb_build_object(
+        'datasetId', NEW.id, 'name', NEW.name, 'status', NEW.status, 'documents', NEW.document_count));
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trigger_datasets_notify
+AFTER INSERT OR UPDATE OF status ON datasets
+FOR EACH ROW EXECUTE FUNCTION notify_dataset_change();
+
+-- New pattern findings
+CREATE OR REPLACE FUNCTION notify_pattern_created() RETURNS TRIGGER AS $$
+BEGIN
+    PERFORM publish_event('pattern.created', jsonb_build_object(
+        'id', NEW.id, 'title', NEW.title, 'patternType', NEW.pattern_type, 'confidence', NEW.confidence));
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trigger_pattern_findings_notify
+AFTER INSERT ON pattern_findings
+FOR EACH ROW EXECUTE FUNCTION notify_pattern_created();
+
+-- Entity updates, one event per statement: recounts and merges touch
+-- thousands of rows at once
+CREATE OR REPLACE FUNCTION notify_entities_updated() RETURNS TRIGGER AS $$
+DECLARE
+    changed INTEGER;
+    ids     INTEGER[];
+BEGIN
+    SELECT COUNT(*), (array_agg(n.id ORDER BY n.id))[1:100]
+    INTO changed, ids
+    FROM new_rows n JOIN old_rows o ON o.id = n.id
+    WHERE ROW(n.*) IS DISTINCT FROM ROW(o.*);
+
+    IF changed > 0 THEN
+        PERFORM publish_event('entity.updated', jsonb_build_object(
+            'count', changed, 'ids', ids));
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trigger_entities_notify
+AFTER UPDATE ON entities
+REFERENCING OLD TABLE AS old_rows NEW TABLE AS new_rows
+FOR EACH STATEMENT EXECUTE FUNCTION notify_entities_updated();
@@ -0,0 +1,53 @@
+-- Precomputed counts for /api/stats, so the endpoint no longer scans the
+-- main tables on every request. The worker's schedule command refreshes
+-- them with refresh_stats(); each view has a unique index so the refresh
+-- can run concurrently with readers.
+
+CREATE MATERIALIZED VIEW stats_totals AS
+SELECT
+    1                                           AS id,
+    (SELECT COUNT(*) FROM documents)            AS documents,
+    (SELECT COUNT(*) FROM entities)             AS entities,
+    (SELECT COUNT(*) FROM triples)              AS triples,
+    (SELECT COUNT(*) FROM ppp_loans)            AS ppp_loans,
+    (SELECT COUNT(*) FROM fec_contributions)    AS fec_records,
+    (SELECT COUNT(*) FROM federal_grants)       AS grants,
+    (SELECT COUNT(*) FROM pattern_findings)     AS patterns,
+    NOW()                                       AS refreshed_at;
+
+CREATE UNIQUE INDEX idx_stats_totals_id ON stats_totals(id);
+
+-- Documents that predate the dataset registry still count, under their ID
+CREATE MATERIALIZED VIEW stats_by_dataset AS
+SELECT
+    d.dataset_id,
+    COALESCE(ds.name, 'DataSet ' || d.dataset_id)   AS name,
+    COUNT(*)                                        AS documents,
+    COALESCE(SUM(d.page_count), 0)                  AS pages,
+    (SELECT COUNT(DISTINCT de.entity_id)
+     FROM document_entities de
+     JOIN documents dd ON dd.id = de.document_id
+     WHERE dd.dataset_id = d.dataset_id)            AS entities
+FROM documents d
+LEFT JOIN datasets ds ON ds.id = d.dataset_id
+GROUP BY d.dataset_id, ds.name;
+
+CREATE UNIQUE INDEX idx_stats_by_dataset_id ON stats_by_dataset(dataset_id);
+
+CREATE MATERIALIZED VIEW stats_by_entity_type AS
+SELECT
+    entity_type,
+    COUNT(*)                            AS entities,
+    COALESCE(SUM(document_count), 0)    AS mentions
+FROM entities
+GROUP BY entity_type;
+
+CREATE UNIQUE INDEX idx_stats_by_entity_type ON stats_by_entity_type(entity_type);
+
+CREATE OR REPLACE FUNCTION refresh_stats() RETURNS VOID AS $$
+BEGIN
+    REFRESH MATERIALIZED VIEW CONCURRENTLY stats_totals;
+    REFRESH MATERIALIZED VIEW CONCURRENTLY stats_by_dataset;
+    REFRESH MATERIALIZED VIEW CONCURRENTLY stats_by_entity_type;
+END;
+$$ LANGUAGE plpgsql;
@@ -0,0 +1,4 @@
+-- Full-text index on chunks for hybrid retrieval (api/internal/rag), which
+-- pairs keyword matches with embedding similarity
+
+CREATE INDEX idx_chunks_fulltext ON document_chunks USING gin(to_tsvector('english', content));
@@ -0,0 +1,17 @@
+-- Token use and estimated cost of every model call, recorded by
+-- api/internal/llm. job_id is set for calls made while running a job.
+
+CREATE TABLE llm_usage (
+    id              BIGSERIAL PRIMARY KEY,
+    job_id          BIGINT REFERENCES jobs(id) ON DELETE SET NULL,
+    task            TEXT NOT NULL,                  -- summarize, dedup, triples, ask
+    provider        TEXT NOT NULL,                  -- anthropic, openai, ollama
+    model           TEXT NOT NULL,
+    input_tokens    INTEGER NOT NULL,
+    output_tokens   INTEGER NOT NULL,
+    cost_usd        NUMERIC(12, 6),                 -- Estimated; NULL when the price is unknown
+    created_at      TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX idx_llm_usage_job ON llm_usage(job_id) WHERE job_id IS NOT NULL;
+CREATE INDEX idx_llm_usage_created ON llm_usage(created_at);
@@ -0,0 +1,12 @@
+-- Generated entity biographies (api/internal/bio), one per entity, with the
+-- citations that tie each statement to a document span or cross-reference
+
+CREATE TABLE entity_bios (
+    entity_id       INTEGER PRIMARY KEY REFERENCES entities(id) ON DELETE CASCADE,
+    bio             TEXT NOT NULL,
+    citations       JSONB NOT NULL DEFAULT '[]',    -- [{marker, quote, documentId, start, end} | {marker, quote, crossref, crossrefId}]
+    sources         INTEGER NOT NULL,               -- Excerpts and records the model was given
+    model           TEXT NOT NULL,
+    job_id          BIGINT REFERENCES jobs(id) ON DELETE SET NULL,
+    generated_at    TIMESTAMPTZ DEFAULT NOW()
+);
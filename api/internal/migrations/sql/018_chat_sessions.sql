@@ -0,0 +1,28 @@
+-- Research chat sessions (api/internal/chat). Each session belongs to the
+-- API key that created it and keeps every turn with its citations and the
+-- tools the model called, so transcripts can be exported later.
+
+CREATE TABLE chat_sessions (
+    id              BIGSERIAL PRIMARY KEY,
+    key_id          INTEGER NOT NULL REFERENCES api_keys(id) ON DELETE CASCADE,
+    title           TEXT NOT NULL DEFAULT '',
+    created_at      TIMESTAMPTZ DEFAULT NOW(),
+    updated_at      TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX idx_chat_sessions_key ON chat_sessions(key_id, updated_at DESC);
+
+CREATE TABLE chat_messages (
+    id              BIGSERIAL PRIMARY KEY,
+    session_id      BIGINT NOT NULL REFERENCES chat_sessions(id) ON DELETE CASCADE,
+    role            TEXT NOT NULL CHECK (role IN ('user', 'assistant')),
+    content         TEXT NOT NULL,
+    citations       JSONB NOT NULL DEFAULT '[]',    -- [{marker, documentId, docId, quote, start, end}]
+    tool_calls      JSONB NOT NULL DEFAULT '[]',    -- [{tool, query, entityId, results}]
+    model           TEXT,
+    input_tokens    INTEGER NOT NULL DEFAULT 0,
+    output_tokens   INTEGER NOT NULL DEFAULT 0,
+    created_at      TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX idx_chat_messages_session ON chat_messages(session_id, id);
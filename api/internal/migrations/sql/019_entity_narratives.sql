@@ -0,0 +1,46 @@
+-- Generated chronological narratives of entities (api/internal/narrative),
+-- one per entity. Each sentence carries the one citation it rests on. A
+-- narrative goes stale when documents or dated relationships involving the
+-- entity arrive, and is served flagged until it is regenerated.
+
+CREATE TABLE entity_narratives (
+    entity_id       INTEGER PRIMARY KEY REFERENCES entities(id) ON DELETE CASCADE,
+    sentences       JSONB NOT NULL DEFAULT '[]',    -- [{text, date, citation: {marker, quote, documentId | crossref, crossrefId}}]
+    sources         INTEGER NOT NULL,               -- Dated events the model was given
+    model           TEXT NOT NULL,
+    job_id          BIGINT REFERENCES jobs(id) ON DELETE SET NULL,
+    stale           BOOLEAN NOT NULL DEFAULT FALSE,
+    generated_at    TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX idx_entity_narratives_stale ON entity_narratives(entity_id) WHERE stale;
+
+CREATE OR REPLACE FUNCTION mark_narratives_stale_by_mention() RETURNS TRIGGER AS $$
+BEGIN
+    UPDATE entity_narratives SET stale = TRUE
+    WHERE NOT stale AND entity_id IN (SELECT entity_id FROM new_rows);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trigger_document_entities_narratives
+AFTER INSERT ON document_entities
+REFERENCING NEW TABLE AS new_rows
+FOR EACH STATEMENT EXECUTE FUNCTION mark_narratives_stale_by_mention();
+
+CREATE OR REPLACE FUNCTION mark_narratives_stale_by_triple() RETURNS TRIGGER AS $$
+BEGIN
+    UPDATE entity_narratives SET stale = TRUE
+    WHERE NOT stale AND entity_id IN (
+        SELECT subject_id FROM new_rows WHERE timestamp IS NOT NULL
+        UNION
+        SELECT object_id FROM new_rows WHERE timestamp IS NOT NULL
+    );
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trigger_triples_narratives
+AFTER INSERT ON triples
+REFERENCING NEW TABLE AS new_rows
+FOR EACH STATEMENT EXECUTE FUNCTION mark_narratives_stale_by_triple();
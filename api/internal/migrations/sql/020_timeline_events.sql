@@ -0,0 +1,38 @@
+-- Corpus-wide timeline (api/internal/timeline). Events are extracted from
+-- dated relationships and verified cross-reference records, or curated by
+-- researchers. Extraction is idempotent: each source row yields one event,
+-- and hidden extracted events stay hidden on later runs.
+
+CREATE TABLE timeline_events (
+    id              BIGSERIAL PRIMARY KEY,
+    event_date      DATE NOT NULL,
+    description     TEXT NOT NULL,
+    event_type      TEXT NOT NULL CHECK (event_type IN
+                        ('flight', 'meeting', 'communication', 'legal', 'financial', 'relationship', 'other')),
+
+    -- Source: a document, a public record, or both for curated events
+    document_id     INTEGER REFERENCES documents(id) ON DELETE CASCADE,
+    crossref        match_source,
+    crossref_id     INTEGER,
+    triple_id       INTEGER REFERENCES triples(id) ON DELETE CASCADE,
+
+    origin          TEXT NOT NULL CHECK (origin IN ('extracted', 'manual')),
+    hidden          BOOLEAN NOT NULL DEFAULT FALSE, -- Curators hide extracted events rather than delete them
+    created_by      INTEGER REFERENCES api_keys(id) ON DELETE SET NULL,
+    created_at      TIMESTAMPTZ DEFAULT NOW(),
+    updated_at      TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX idx_timeline_events_date ON timeline_events(event_date) WHERE NOT hidden;
+CREATE INDEX idx_timeline_events_document ON timeline_events(document_id);
+CREATE UNIQUE INDEX idx_timeline_events_triple ON timeline_events(triple_id) WHERE triple_id IS NOT NULL;
+CREATE UNIQUE INDEX idx_timeline_events_crossref ON timeline_events(crossref, crossref_id)
+    WHERE crossref IS NOT NULL AND origin = 'extracted';
+
+CREATE TABLE timeline_event_entities (
+    event_id        BIGINT NOT NULL REFERENCES timeline_events(id) ON DELETE CASCADE,
+    entity_id       INTEGER NOT NULL REFERENCES entities(id) ON DELETE CASCADE,
+    PRIMARY KEY (event_id, entity_id)
+);
+
+CREATE INDEX idx_timeline_event_entities_entity ON timeline_event_entities(entity_id);
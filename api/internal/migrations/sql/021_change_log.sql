@@ -0,0 +1,109 @@
+-- Change data capture (api/internal/changes). Triggers record every insert,
+-- update and delete of documents, entities, triples and pattern findings, so
+-- mirrors and downstream indexes can follow the database incrementally.
+-- Rows carry IDs, not data; readers fetch what they need.
+--
+-- Changes are read in (txid, id) order and only once every transaction older
+-- than them has finished, so a reader's cursor never skips a change that
+-- commits late.
+
+CREATE TABLE change_log (
+    id              BIGSERIAL PRIMARY KEY,
+    txid            XID8 NOT NULL DEFAULT pg_current_xact_id(),
+    table_name      TEXT NOT NULL,
+    row_id          BIGINT NOT NULL,
+    op              TEXT NOT NULL CHECK (op IN ('insert', 'update', 'delete')),
+    changed_at      TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_change_log_cursor ON change_log(txid, id);
+CREATE INDEX idx_change_log_changed ON change_log(changed_at);
+
+-- Where each publisher (cmd/worker changes) has got to
+CREATE TABLE change_log_cursors (
+    name            TEXT PRIMARY KEY,
+    txid            XID8 NOT NULL,
+    change_id       BIGINT NOT NULL,
+    updated_at      TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- One trigger per table and operation, each per statement: ingestion and
+-- recounts touch thousands of rows at once. Updates that change nothing are
+-- skipped.
+CREATE OR REPLACE FUNCTION log_changes() RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        INSERT INTO change_log (table_name, row_id, op)
+        SELECT TG_TABLE_NAME, n.id, 'insert' FROM new_rows n;
+    ELSIF TG_OP = 'UPDATE' THEN
+        INSERT INTO change_log (table_name, row_id, op)
+        SELECT TG_TABLE_NAME, n.id, 'update'
+        FROM new_rows n JOIN old_rows o ON o.id = n.id
+        WHERE ROW(n.*) IS DISTINCT FROM ROW(o.*);
+    ELSE
+        INSERT INTO change_log (table_name, row_id, op)
+        SELECT TG_TABLE_NAME, o.id, 'delete' FROM old_rows o;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trigger_documents_log_insert
+AFTER INSERT ON documents
+REFERENCING NEW TABLE AS new_rows
+FOR EACH STATEMENT EXECUTE FUNCTION log_changes();
+
+CREATE TRIGGER trigger_documents_log_update
+AFTER UPDATE ON documents
+REFERENCING OLD TABLE AS old_rows NEW TABLE AS new_rows
+FOR EACH STATEMENT EXECUTE FUNCTION log_changes();
+
+CREATE TRIGGER trigger_documents_log_delete
+AFTER DELETE ON documents
+REFERENCING OLD TABLE AS old_rows
+FOR EACH STATEMENT EXECUTE FUNCTION log_changes();
+
+CREATE TRIGGER trigger_entities_log_insert
+AFTER INSERT ON entities
+REFERENCING NEW TABLE AS new_rows
+FOR EACH STATEMENT EXECUTE FUNCTION log_changes();
+
+CREATE TRIGGER trigger_entities_log_update
+AFTER UPDATE ON entities
+REFERENCING OLD TABLE AS old_rows NEW TABLE AS new_rows
+FOR EACH STATEMENT EXECUTE FUNCTION log_changes();
+
+CREATE TRIGGER trigger_entities_log_delete
+AFTER DELETE ON entities
+REFERENCING OLD TABLE AS old_rows
+FOR EACH STATEMENT EXECUTE FUNCTION log_changes();
+
+CREATE TRIGGER trigger_triples_log_insert
+AFTER INSERT ON triples
+REFERENCING NEW TABLE AS new_rows
+FOR EACH STATEMENT EXECUTE FUNCTION log_changes();
+
+CREATE TRIGGER trigger_triples_log_update
+AFTER UPDATE ON triples
+REFERENCING OLD TABLE AS old_rows NEW TABLE AS new_rows
+FOR EACH STATEMENT EXECUTE FUNCTION log_changes();
+
+CREATE TRIGGER trigger_triples_log_delete
+AFTER DELETE ON triples
+REFERENCING OLD TABLE AS old_rows
+FOR EACH STATEMENT EXECUTE FUNCTION log_changes();
+
+CREATE TRIGGER trigger_pattern_findings_log_insert
+AFTER INSERT ON pattern_findings
+REFERENCING NEW TABLE AS new_rows
+FOR EACH STATEMENT EXECUTE FUNCTION log_changes();
+
+CREATE TRIGGER trigger_pattern_findings_log_update
+AFTER UPDATE ON pattern_findings
+REFERENCING OLD TABLE AS old_rows NEW TABLE AS new_rows
+FOR EACH STATEMENT EXECUTE FUNCTION log_changes();
+
+CREATE TRIGGER trigger_pattern_findings_log_delete
+AFTER DELETE ON pattern_findings
+REFERENCING OLD TABLE AS old_rows
+FOR EACH STATEMENT EXECUTE FUNCTION log_changes();
@@ -0,0 +1,52 @@
+-- Webhook subscriptions (api/internal/webhooks). Events are derived from
+-- the change log, which now also covers cross-reference matches so that
+-- confirmations can be announced. Each event becomes one delivery per
+-- matching subscription; deliveries are retried with backoff until they
+-- succeed or run out of attempts, and kept as the delivery log.
+
+CREATE TRIGGER trigger_entity_crossref_matches_log_insert
+AFTER INSERT ON entity_crossref_matches
+REFERENCING NEW TABLE AS new_rows
+FOR EACH STATEMENT EXECUTE FUNCTION log_changes();
+
+CREATE TRIGGER trigger_entity_crossref_matches_log_update
+AFTER UPDATE ON entity_crossref_matches
+REFERENCING OLD TABLE AS old_rows NEW TABLE AS new_rows
+FOR EACH STATEMENT EXECUTE FUNCTION log_changes();
+
+CREATE TRIGGER trigger_entity_crossref_matches_log_delete
+AFTER DELETE ON entity_crossref_matches
+REFERENCING OLD TABLE AS old_rows
+FOR EACH STATEMENT EXECUTE FUNCTION log_changes();
+
+CREATE TABLE webhooks (
+    id              BIGSERIAL PRIMARY KEY,
+    key_id          INTEGER NOT NULL REFERENCES api_keys(id) ON DELETE CASCADE,
+    url             TEXT NOT NULL,
+    events          TEXT[] NOT NULL,                -- entity.updated, document.created, ...
+    dataset_id      INTEGER,                        -- Only documents in this dataset, when set
+    secret          TEXT NOT NULL,                  -- HMAC-SHA256 key for the signature header
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_webhooks_key ON webhooks(key_id);
+
+CREATE TABLE webhook_deliveries (
+    id              BIGSERIAL PRIMARY KEY,
+    webhook_id      BIGINT NOT NULL REFERENCES webhooks(id) ON DELETE CASCADE,
+    event_type      TEXT NOT NULL,
+    data            JSONB NOT NULL,
+    dedupe_key      TEXT,                           -- Events that must be sent at most once
+    status          TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'delivered', 'failed')),
+    attempts        INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    response_status INTEGER,                        -- HTTP status of the last attempt
+    error           TEXT,                           -- Why the last attempt failed
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    delivered_at    TIMESTAMPTZ
+);
+
+CREATE INDEX idx_webhook_deliveries_webhook ON webhook_deliveries(webhook_id, id DESC);
+CREATE INDEX idx_webhook_deliveries_due ON webhook_deliveries(next_attempt_at) WHERE status = 'pending';
+CREATE UNIQUE INDEX idx_webhook_deliveries_dedupe ON webhook_deliveries(webhook_id, dedupe_key)
+    WHERE dedupe_key IS NOT NULL;
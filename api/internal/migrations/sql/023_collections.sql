@@ -0,0 +1,28 @@
+-- Research collections (api/internal/collections). A collection belongs to
+-- the API key that created it and groups documents, entities and pattern
+-- findings, each with an optional note. Items point at their row by type and
+-- ID rather than by foreign key, so an item whose row is merged away or
+-- deleted stays in the collection and is reported missing. A share token,
+-- once set, lets anyone read the collection.
+
+CREATE TABLE collections (
+    id              BIGSERIAL PRIMARY KEY,
+    key_id          INTEGER NOT NULL REFERENCES api_keys(id) ON DELETE CASCADE,
+    name            TEXT NOT NULL,
+    notes           TEXT NOT NULL DEFAULT '',
+    share_token     TEXT UNIQUE,                    -- Public read access, when set
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at      TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_collections_key ON collections(key_id, updated_at DESC);
+
+CREATE TABLE collection_items (
+    id              BIGSERIAL PRIMARY KEY,
+    collection_id   BIGINT NOT NULL REFERENCES collections(id) ON DELETE CASCADE,
+    item_type       TEXT NOT NULL CHECK (item_type IN ('document', 'entity', 'pattern')),
+    item_id         INTEGER NOT NULL,               -- documents.id, entities.id or pattern_findings.id
+    note            TEXT NOT NULL DEFAULT '',
+    added_at        TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (collection_id, item_type, item_id)
+);
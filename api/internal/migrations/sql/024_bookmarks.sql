@@ -0,0 +1,27 @@
+-- Bookmarks and reading history (api/internal/bookmarks), per API key. Both
+-- point at documents and entities by type and ID, like collection items.
+-- History keeps one row per item with when it was last viewed and how
+-- often; only the most recent views of each key are kept.
+
+CREATE TABLE bookmarks (
+    key_id          INTEGER NOT NULL REFERENCES api_keys(id) ON DELETE CASCADE,
+    item_type       TEXT NOT NULL CHECK (item_type IN ('document', 'entity')),
+    item_id         INTEGER NOT NULL,
+    note            TEXT NOT NULL DEFAULT '',
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (key_id, item_type, item_id)
+);
+
+CREATE INDEX idx_bookmarks_recent ON bookmarks(key_id, created_at DESC);
+
+CREATE TABLE reading_history (
+    key_id          INTEGER NOT NULL REFERENCES api_keys(id) ON DELETE CASCADE,
+    item_type       TEXT NOT NULL CHECK (item_type IN ('document', 'entity')),
+    item_id         INTEGER NOT NULL,
+    views           INTEGER NOT NULL DEFAULT 1,
+    first_viewed_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    viewed_at       TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (key_id, item_type, item_id)
+);
+
+CREATE INDEX idx_reading_history_recent ON reading_history(key_id, viewed_at DESC);
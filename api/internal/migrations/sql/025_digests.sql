@@ -0,0 +1,20 @@
+-- Email digests (api/internal/digest). A researcher key subscribes an
+-- address to a daily or weekly summary of new documents, validated patterns
+-- and activity around the entities it watches. Every digest carries the
+-- subscription's unsubscribe token, which ends it in one click.
+
+CREATE TABLE digest_subscriptions (
+    id                  BIGSERIAL PRIMARY KEY,
+    key_id              INTEGER NOT NULL REFERENCES api_keys(id) ON DELETE CASCADE,
+    email               TEXT NOT NULL,
+    frequency           TEXT NOT NULL CHECK (frequency IN ('daily', 'weekly')),
+    entity_ids          INTEGER[] NOT NULL DEFAULT '{}',   -- Watched entities
+    unsubscribe_token   TEXT NOT NULL UNIQUE,
+    created_at          TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_sent_at        TIMESTAMPTZ,                        -- End of the period last covered
+    unsubscribed_at     TIMESTAMPTZ
+);
+
+CREATE INDEX idx_digest_subscriptions_key ON digest_subscriptions(key_id);
+CREATE INDEX idx_digest_subscriptions_active ON digest_subscriptions(frequency, last_sent_at)
+    WHERE unsubscribed_at IS NULL;
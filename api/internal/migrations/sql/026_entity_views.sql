@@ -0,0 +1,13 @@
+-- Entity views per day (api/internal/trending), for the most-viewed list on
+-- /api/trending. The server counts reads of /api/entities/:id in memory and
+-- adds them here every minute; days older than the trending window are
+-- pruned by the worker's schedule.
+
+CREATE TABLE entity_views (
+    entity_id   INTEGER NOT NULL REFERENCES entities(id) ON DELETE CASCADE,
+    day         DATE NOT NULL,
+    views       BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (entity_id, day)
+);
+
+CREATE INDEX idx_entity_views_day ON entity_views(day);
@@ -0,0 +1,21 @@
+-- Public tips (api/internal/tips). Anyone can submit a lead, optionally
+-- pointing at documents and entities; tips wait in a moderation queue until
+-- an admin accepts or rejects them, and an accepted tip can become a pattern
+-- finding. Submitters' IP addresses are not stored.
+
+CREATE TABLE tips (
+    id              BIGSERIAL PRIMARY KEY,
+    body            TEXT NOT NULL,
+    contact         TEXT,                               -- Optional, for follow-up
+    document_ids    INTEGER[] NOT NULL DEFAULT '{}',
+    entity_ids      INTEGER[] NOT NULL DEFAULT '{}',
+    key_id          INTEGER REFERENCES api_keys(id) ON DELETE SET NULL,
+    status          TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'accepted', 'rejected')),
+    submitted_at    TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    reviewed_at     TIMESTAMPTZ,
+    reviewed_by     INTEGER REFERENCES api_keys(id) ON DELETE SET NULL,
+    review_note     TEXT,
+    pattern_id      INTEGER REFERENCES pattern_findings(id) ON DELETE SET NULL
+);
+
+CREATE INDEX idx_tips_queue ON tips(status, submitted_at);
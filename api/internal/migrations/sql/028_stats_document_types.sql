@@ -0,0 +1,21 @@
+-- Precomputed document type counts for /api/meta/document-types, refreshed
+-- with the other stats views. Untyped documents aren't listed.
+
+CREATE MATERIALIZED VIEW stats_by_document_type AS
+SELECT
+    document_type,
+    COUNT(*)    AS documents
+FROM documents
+WHERE document_type IS NOT NULL AND document_type <> ''
+GROUP BY document_type;
+
+CREATE UNIQUE INDEX idx_stats_by_document_type ON stats_by_document_type(document_type);
+
+CREATE OR REPLACE FUNCTION refresh_stats() RETURNS VOID AS $$
+BEGIN
+    REFRESH MATERIALIZED VIEW CONCURRENTLY stats_totals;
+    REFRESH MATERIALIZED VIEW CONCURRENTLY stats_by_dataset;
+    REFRESH MATERIALIZED VIEW CONCURRENTLY stats_by_entity_type;
+    REFRESH MATERIALIZED VIEW CONCURRENTLY stats_by_document_type;
+END;
+$$ LANGUAGE plpgsql;
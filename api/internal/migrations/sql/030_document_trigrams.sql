@@ -0,0 +1,5 @@
+-- Trigram index on document text for /api/search?mode=ngram, which matches
+-- words by shared character trigrams so OCR misreadings ("Epste1n",
+-- "Maxvvell") still find the name
+
+CREATE INDEX idx_documents_fulltext_trgm ON documents USING gin(full_text gin_trgm_ops);
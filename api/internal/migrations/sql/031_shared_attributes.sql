@@ -0,0 +1,92 @@
+-- Groups of cross-reference records sharing an address or an employer, for
+-- /api/analysis/shared-attributes: several differently named borrowers at
+-- one address, or several donors giving the same employer, is how shell
+-- companies and straw donors tend to show up. Values are normalized before
+-- grouping so "100 Main Street, Suite 4" and "100 MAIN ST STE 4" agree.
+-- Refreshed with the stats views.
+
+-- Upper case, punctuation dropped, whitespace collapsed; NULL when empty
+CREATE OR REPLACE FUNCTION normalize_key(s TEXT) RETURNS TEXT AS $$
+    SELECT NULLIF(btrim(regexp_replace(upper(s), '[^A-Z0-9]+', ' ', 'g')), '')
+$$ LANGUAGE sql IMMUTABLE;
+
+-- A street address with the usual words abbreviated, followed by the
+-- five-digit ZIP code so the same street in two towns stays apart
+CREATE OR REPLACE FUNCTION normalize_address(address TEXT, zip TEXT) RETURNS TEXT AS $$
+    SELECT CASE WHEN normalize_key(address) IS NULL THEN NULL ELSE
+        regexp_replace(regexp_replace(regexp_replace(regexp_replace(regexp_replace(
+        regexp_replace(regexp_replace(regexp_replace(regexp_replace(regexp_replace(
+            normalize_key(address),
+            '\mSTREET\M', 'ST', 'g'), '\mAVENUE\M', 'AVE', 'g'), '\mROAD\M', 'RD', 'g'),
+            '\mBOULEVARD\M', 'BLVD', 'g'), '\mDRIVE\M', 'DR', 'g'), '\mLANE\M', 'LN', 'g'),
+            '\mSUITE\M', 'STE', 'g'), '\mFLOOR\M', 'FL', 'g'), '\mAPARTMENT\M', 'APT', 'g'),
+            '\mPLACE\M', 'PL', 'g')
+        || COALESCE(' ' || NULLIF(left(regexp_replace(zip, '[^0-9]', '', 'g'), 5), ''), '')
+    END
+$$ LANGUAGE sql IMMUTABLE;
+
+-- An organization's name without a leading "THE" or trailing corporate
+-- suffixes. Answers that aren't an employer (self-employed, retired and
+-- the like) are NULL.
+CREATE OR REPLACE FUNCTION normalize_employer(name TEXT) RETURNS TEXT AS $$
+    SELECT CASE WHEN k IN ('SELF', 'SELF EMPLOYED', 'RETIRED', 'NONE', 'N A', 'NA',
+                           'NOT EMPLOYED', 'UNEMPLOYED', 'HOMEMAKER', 'STUDENT',
+                           'INFORMATION REQUESTED', 'INFORMATION REQUESTED PER BEST EFFORTS')
+                THEN NULL ELSE NULLIF(k, '') END
+    FROM (SELECT regexp_replace(regexp_replace(normalize_key(name), '^THE ', ''),
+            '( (INC|INCORPORATED|LLC|L L C|CORP|CORPORATION|CO|COMPANY|LTD|LIMITED|LP|LLP|PLLC|PC))+$', '') AS k) n
+$$ LANGUAGE sql IMMUTABLE;
+
+-- role is how a record relates to the value: a borrower at the address, an
+-- employee naming the employer, or the employer itself
+CREATE MATERIALIZED VIEW shared_attribute_groups AS
+WITH members AS (
+    SELECT 'address' AS attribute, normalize_address(borrower_address, borrower_zip) AS value,
+           'ppp'::match_source AS source, id AS source_id, borrower_name AS name, 'borrower' AS role
+    FROM ppp_loans
+    UNION ALL
+    SELECT 'employer', normalize_employer(contributor_employer),
+           'fec', id, contributor_name, 'employee'
+    FROM fec_contributions
+    UNION ALL
+    SELECT 'employer', normalize_employer(borrower_name), 'ppp', id, borrower_name, 'employer'
+    FROM ppp_loans
+    UNION ALL
+    SELECT 'employer', normalize_employer(recipient_name), 'grants', id, recipient_name, 'employer'
+    FROM federal_grants
+),
+grouped AS (
+    SELECT attribute, value,
+           COUNT(*)                                                         AS records,
+           COUNT(DISTINCT normalize_key(name)) FILTER (WHERE role <> 'employer') AS names,
+           to_jsonb((array_agg(jsonb_build_object('source', source, 'id', source_id, 'name', name, 'role', role)
+                     ORDER BY role, name, source_id))[1:100])               AS members
+    FROM members
+    WHERE value IS NOT NULL
+    GROUP BY attribute, value
+    HAVING COUNT(DISTINCT normalize_key(name)) FILTER (WHERE role <> 'employer') >= 2
+),
+linked AS (
+    SELECT m.attribute, m.value, array_agg(DISTINCT x.entity_id) AS entity_ids
+    FROM members m
+    JOIN entity_crossref_matches x
+      ON x.source = m.source AND x.source_id = m.source_id AND x.false_positive IS NOT TRUE
+    WHERE m.value IS NOT NULL
+    GROUP BY m.attribute, m.value
+)
+SELECT g.attribute, g.value, g.records, g.names, g.members,
+       COALESCE(l.entity_ids, '{}') AS entity_ids
+FROM grouped g
+LEFT JOIN linked l USING (attribute, value);
+
+CREATE UNIQUE INDEX idx_shared_attribute_groups ON shared_attribute_groups(attribute, value);
+
+CREATE OR REPLACE FUNCTION refresh_stats() RETURNS VOID AS $$
+BEGIN
+    REFRESH MATERIALIZED VIEW CONCURRENTLY stats_totals;
+    REFRESH MATERIALIZED VIEW CONCURRENTLY stats_by_dataset;
+    REFRESH MATERIALIZED VIEW CONCURRENTLY stats_by_entity_type;
+    REFRESH MATERIALIZED VIEW CONCURRENTLY stats_by_document_type;
+    REFRESH MATERIALIZED VIEW CONCURRENTLY shared_attribute_groups;
+END;
+$$ LANGUAGE plpgsql;
@@ -0,0 +1,19 @@
+-- Organizations with the marks of a shell company, scored by the shells
+-- analysis (api/internal/shells), nightly and on demand. Each run replaces
+-- the previous one.
+
+CREATE TABLE shell_candidates (
+    id              SERIAL PRIMARY KEY,
+    organization    TEXT NOT NULL,                  -- normalize_employer(borrower_name)
+    name            TEXT NOT NULL,                  -- As on its first PPP loan
+    score           REAL NOT NULL,                  -- 0-1, sum of the signals' weights
+    signals         JSONB NOT NULL,                 -- [{signal, weight, rationale}]
+    loan_ids        INTEGER[] NOT NULL,             -- ppp_loans
+    entity_ids      INTEGER[] NOT NULL,             -- Entities it is linked to
+    generated_at    TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX idx_shell_candidates_score ON shell_candidates(score DESC);
+
+-- Looking up grants by organization
+CREATE INDEX idx_grants_employer ON federal_grants(normalize_employer(recipient_name));
@@ -0,0 +1,6 @@
+-- Finding organizations by normalized name, for /api/network/financial,
+-- which merges committees, agencies and lenders into the entities of the
+-- same name
+
+CREATE INDEX idx_entities_org_name ON entities(normalize_employer(canonical_name))
+    WHERE entity_type = 'organization';
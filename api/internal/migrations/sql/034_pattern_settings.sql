@@ -0,0 +1,9 @@
+-- Tuning of the pattern detectors (api/internal/patterns), set through the
+-- admin API. A detector without a row runs with its defaults.
+
+CREATE TABLE pattern_settings (
+    detector        TEXT PRIMARY KEY,               -- e.g. temporal_cluster
+    settings        JSONB NOT NULL,
+    updated_at      TIMESTAMPTZ DEFAULT NOW(),
+    updated_by      INTEGER REFERENCES api_keys(id) ON DELETE SET NULL
+);
@@ -0,0 +1,17 @@
+-- Outlying contribution and loan amounts among cross-reference records
+-- matched to entities (api/internal/anomalies), replaced on each run.
+
+CREATE TABLE amount_anomalies (
+    id              SERIAL PRIMARY KEY,
+    kind            TEXT NOT NULL,                  -- under_threshold, repeated_amount, loan_payroll
+    source          match_source NOT NULL,
+    record_ids      INTEGER[] NOT NULL,             -- IDs in the source table
+    entity_ids      INTEGER[] NOT NULL,             -- Entities the records are matched to
+    amount          NUMERIC(15,2) NOT NULL,
+    score           REAL NOT NULL,
+    rationale       TEXT NOT NULL,
+    generated_at    TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX idx_amount_anomalies_score ON amount_anomalies(score DESC);
+CREATE INDEX idx_amount_anomalies_entities ON amount_anomalies USING gin(entity_ids);
@@ -0,0 +1,12 @@
+-- Degrees of separation (api/internal/separation): hops through shared
+-- documents from the core entities to each person and organization,
+-- recomputed by the scheduler. Entities out of reach have no row. Entity
+-- layers are set from these distances.
+
+CREATE TABLE entity_distances (
+    entity_id       INTEGER PRIMARY KEY REFERENCES entities(id) ON DELETE CASCADE,
+    distance        INTEGER NOT NULL,               -- 0 for the core entities
+    computed_at     TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX idx_entity_distances_distance ON entity_distances(distance);
@@ -0,0 +1,13 @@
+-- Each person's and organization's role in a document (api/internal/roles),
+-- inferred by a model from the text around their mentions. A row classified
+-- with a NULL role had none the text supports.
+
+ALTER TABLE document_entities
+    ADD COLUMN role TEXT CHECK (role IN
+        ('witness', 'attorney', 'victim_redacted', 'staff', 'business_associate', 'pilot')),
+    ADD COLUMN role_quote TEXT,                     -- Passage the role was inferred from
+    ADD COLUMN role_model TEXT,
+    ADD COLUMN role_classified_at TIMESTAMPTZ;
+
+CREATE INDEX idx_doc_entities_role ON document_entities(entity_id, role) WHERE role IS NOT NULL;
+CREATE INDEX idx_doc_entities_unclassified ON document_entities(document_id) WHERE role_classified_at IS NULL;
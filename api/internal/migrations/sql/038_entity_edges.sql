@@ -0,0 +1,20 @@
+-- Relationship strength (api/internal/strength): a score for each pair of
+-- people and organizations that share documents, recomputed by the
+-- scheduler. Shared documents are weighted by how few names they mention
+-- and how often the pair is mentioned in them; sharing a page and
+-- extracted triples between the pair add to the score.
+
+CREATE TABLE entity_edges (
+    source_id       INTEGER NOT NULL REFERENCES entities(id) ON DELETE CASCADE,
+    target_id       INTEGER NOT NULL REFERENCES entities(id) ON DELETE CASCADE,
+    documents       INTEGER NOT NULL,               -- shared documents, the raw weight
+    same_page       INTEGER NOT NULL,               -- shared documents with a page naming both
+    triples         INTEGER NOT NULL,               -- triples between the pair, either way
+    score           REAL NOT NULL,
+    computed_at     TIMESTAMPTZ DEFAULT NOW(),
+
+    PRIMARY KEY (source_id, target_id),
+    CHECK (source_id < target_id)
+);
+
+CREATE INDEX idx_entity_edges_score ON entity_edges(score DESC);
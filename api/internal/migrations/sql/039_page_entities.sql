@@ -0,0 +1,17 @@
+-- Page-level mentions (api/internal/pages): which pages of a document name
+-- each entity it mentions, found by matching the entity's names in
+-- document_pages. Co-occurrence at page granularity is computed from here,
+-- so a 300-page file doesn't connect everyone in it.
+
+CREATE TABLE page_entities (
+    document_id     INTEGER NOT NULL REFERENCES documents(id) ON DELETE CASCADE,
+    page_number     INTEGER NOT NULL,
+    entity_id       INTEGER NOT NULL REFERENCES entities(id) ON DELETE CASCADE,
+    mention_count   INTEGER NOT NULL,
+    indexed_at      TIMESTAMPTZ DEFAULT NOW(),
+
+    PRIMARY KEY (document_id, entity_id, page_number)
+);
+
+CREATE INDEX idx_page_entities_entity ON page_entities(entity_id);
+CREATE INDEX idx_page_entities_page ON page_entities(document_id, page_number);
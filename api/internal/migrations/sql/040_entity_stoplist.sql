@@ -0,0 +1,63 @@
+-- Entity stoplist (api/internal/stoplist): names kept out of the graph, such
+-- as OCR artifacts and boilerplate like court clerks and form headers. A
+-- blocked name's entities lose their document links and triples, and the
+-- triggers below drop new ones, whichever extractor writes them. Names
+-- named in much of a dataset are suggested for review automatically.
+
+CREATE TABLE entity_stoplist (
+    id              SERIAL PRIMARY KEY,
+    name            TEXT NOT NULL,
+    name_key        TEXT NOT NULL,                  -- normalize_name(name)
+    entity_type     entity_type,                    -- NULL for every type
+    status          TEXT NOT NULL DEFAULT 'suggested' CHECK (status IN ('suggested', 'blocked', 'dismissed')),
+    reason          TEXT,
+    dataset_id      INTEGER,                        -- Suggestions: the dataset the name is frequent in
+    document_share  REAL,                           -- Suggestions: share of its documents naming it
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    reviewed_at     TIMESTAMPTZ,
+    reviewed_by     INTEGER REFERENCES api_keys(id) ON DELETE SET NULL
+);
+
+CREATE UNIQUE INDEX idx_entity_stoplist_name ON entity_stoplist(name_key, (COALESCE(entity_type::text, '')));
+CREATE INDEX idx_entity_stoplist_status ON entity_stoplist(status, created_at);
+
+-- Whether a name of a type is blocked
+CREATE OR REPLACE FUNCTION name_stopped(stop_name TEXT, stop_type entity_type) RETURNS BOOLEAN AS $$
+    SELECT EXISTS (
+        SELECT 1 FROM entity_stoplist s
+        WHERE s.status = 'blocked'
+          AND s.name_key = normalize_name(stop_name)
+          AND (s.entity_type IS NULL OR s.entity_type = stop_type)
+    );
+$$ LANGUAGE sql STABLE;
+
+-- Whether an entity's canonical name is blocked
+CREATE OR REPLACE FUNCTION entity_stopped(stop_entity INTEGER) RETURNS BOOLEAN AS $$
+    SELECT COALESCE((SELECT name_stopped(e.canonical_name, e.entity_type) FROM entities e WHERE e.id = stop_entity), FALSE);
+$$ LANGUAGE sql STABLE;
+
+CREATE OR REPLACE FUNCTION drop_stopped_mention() RETURNS TRIGGER AS $$
+BEGIN
+    IF entity_stopped(NEW.entity_id) THEN
+        RETURN NULL;
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trigger_document_entities_stoplist
+BEFORE INSERT ON document_entities
+FOR EACH ROW EXECUTE FUNCTION drop_stopped_mention();
+
+CREATE OR REPLACE FUNCTION drop_stopped_triple() RETURNS TRIGGER AS $$
+BEGIN
+    IF entity_stopped(NEW.subject_id) OR entity_stopped(NEW.object_id) THEN
+        RETURN NULL;
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trigger_triples_stoplist
+BEFORE INSERT ON triples
+FOR EACH ROW EXECUTE FUNCTION drop_stopped_triple();
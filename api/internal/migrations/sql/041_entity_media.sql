@@ -0,0 +1,25 @@
+-- Entity media (api/internal/media): images of people and logos of
+-- organizations, with where they came from and under what license. Files
+-- live on disk under MEDIA_DIR, named by their SHA-256, so the same image
+-- attached twice is stored once.
+
+CREATE TABLE entity_media (
+    id              SERIAL PRIMARY KEY,
+    entity_id       INTEGER NOT NULL REFERENCES entities(id) ON DELETE CASCADE,
+    sha256          TEXT NOT NULL,
+    content_type    TEXT NOT NULL,                  -- image/jpeg, image/png or image/gif
+    width           INTEGER NOT NULL,
+    height          INTEGER NOT NULL,
+    byte_size       INTEGER NOT NULL,
+    source_url      TEXT,                           -- Where the image was found
+    license         TEXT,                           -- e.g. CC BY-SA 4.0, public domain
+    attribution     TEXT,                           -- Credit line the license asks for
+    caption         TEXT,
+    is_primary      BOOLEAN NOT NULL DEFAULT FALSE, -- Shown on the entity's page and network node
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    created_by      INTEGER REFERENCES api_keys(id) ON DELETE SET NULL
+);
+
+CREATE INDEX idx_entity_media_entity ON entity_media(entity_id, created_at);
+CREATE INDEX idx_entity_media_sha256 ON entity_media(sha256);
+CREATE UNIQUE INDEX idx_entity_media_primary ON entity_media(entity_id) WHERE is_primary;
@@ -0,0 +1,19 @@
+-- Near-duplicate documents (api/internal/duplicates): the same exhibit
+-- released in several datasets, or re-OCR'd. Each document's text is
+-- fingerprinted with a 64-bit SimHash over word shingles, and documents
+-- whose fingerprints differ in few bits are clustered together.
+
+CREATE TABLE document_fingerprints (
+    document_id     INTEGER PRIMARY KEY REFERENCES documents(id) ON DELETE CASCADE,
+    simhash         BIGINT,                         -- NULL when the text is too short to compare
+    computed_at     TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE document_duplicates (
+    document_id     INTEGER PRIMARY KEY REFERENCES documents(id) ON DELETE CASCADE,
+    cluster_id      INTEGER NOT NULL,               -- Lowest document ID in the cluster
+    similarity      REAL NOT NULL,                  -- To the cluster's first document, 0 to 1
+    computed_at     TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_document_duplicates_cluster ON document_duplicates(cluster_id);
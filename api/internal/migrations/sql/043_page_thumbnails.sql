@@ -0,0 +1,15 @@
+-- Page thumbnails (api/internal/thumbnails): each page of a document's
+-- stored PDF or image, rendered at a few widths under MEDIA_DIR/pages for
+-- document browsers. A row records a rendered file.
+
+CREATE TABLE page_thumbnails (
+    document_id     INTEGER NOT NULL REFERENCES documents(id) ON DELETE CASCADE,
+    page_number     INTEGER NOT NULL,               -- 1-based
+    size            TEXT NOT NULL CHECK (size IN ('small', 'medium', 'large')),
+    width           INTEGER NOT NULL,
+    height          INTEGER NOT NULL,
+    byte_size       INTEGER NOT NULL,
+    rendered_at     TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+    PRIMARY KEY (document_id, page_number, size)
+);
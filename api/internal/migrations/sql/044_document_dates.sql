@@ -0,0 +1,21 @@
+-- Dates found in document text (api/internal/dates). Every document gets a
+-- row once its text has been read, whether or not it mentions a date, so
+-- the nightly job can tell which changed since. A document whose
+-- date_earliest and date_latest are both unset gets the range found here
+-- when its confidence is high enough; applied records that, so the dates
+-- can be replaced when the text changes without touching ones the analysis
+-- or an editor set.
+
+CREATE TABLE document_dates (
+    document_id     INTEGER PRIMARY KEY REFERENCES documents(id) ON DELETE CASCADE,
+    earliest        DATE,                           -- NULL when no date was found
+    latest          DATE,
+    precision       TEXT CHECK (precision IN ('day', 'month', 'year')),
+    confidence      REAL,                           -- 0 to 1
+    ambiguous       BOOLEAN NOT NULL DEFAULT FALSE, -- A bound could be read two ways, such as 03/04/2005
+    mentions        INTEGER NOT NULL DEFAULT 0,     -- Dates found in the text
+    applied         BOOLEAN NOT NULL DEFAULT FALSE, -- Copied to documents.date_earliest/date_latest
+    computed_at     TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_document_dates_ambiguous ON document_dates(document_id) WHERE ambiguous;
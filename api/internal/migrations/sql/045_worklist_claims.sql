@@ -0,0 +1,18 @@
+-- Curation worklist (api/internal/worklist): documents missing a summary,
+-- dates, a type or entities. A curator claims a document's missing field,
+-- or an admin assigns it to someone, so two people don't fill the same one.
+-- Claims lapse at expires_at; ones whose field has since been filled are
+-- ignored.
+
+CREATE TABLE worklist_claims (
+    document_id     INTEGER NOT NULL REFERENCES documents(id) ON DELETE CASCADE,
+    field           TEXT NOT NULL CHECK (field IN ('summary', 'dates', 'type', 'entities')),
+    key_id          INTEGER NOT NULL REFERENCES api_keys(id) ON DELETE CASCADE,
+    assigned_by     INTEGER REFERENCES api_keys(id) ON DELETE SET NULL,
+    claimed_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at      TIMESTAMPTZ NOT NULL,
+
+    PRIMARY KEY (document_id, field)
+);
+
+CREATE INDEX idx_worklist_claims_key ON worklist_claims(key_id, field);
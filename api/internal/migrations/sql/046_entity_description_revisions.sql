@@ -0,0 +1,54 @@
+-- Entity description history (api/internal/descriptions). Every version of
+-- an entity's description is kept with who wrote it, whether a person or a
+-- model, and what it is based on, so curated text is accountable and any
+-- version can be restored. entities.description stays the current text.
+
+CREATE TABLE entity_description_revisions (
+    id              SERIAL PRIMARY KEY,
+    entity_id       INTEGER NOT NULL REFERENCES entities(id) ON DELETE CASCADE,
+    revision        INTEGER NOT NULL,               -- 1, 2, ... per entity
+    description     TEXT,                           -- NULL when the description was cleared
+    origin          TEXT NOT NULL CHECK (origin IN ('human', 'llm')),
+    attribution     TEXT,                           -- Sources, a URL or the model, as given by the author
+    comment         TEXT,                           -- Why it changed
+    reverted_from   INTEGER,                        -- The revision this one restored
+    author_key      INTEGER REFERENCES api_keys(id) ON DELETE SET NULL,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+    UNIQUE (entity_id, revision)
+);
+
+-- Descriptions written before this table existed came from extraction
+INSERT INTO entity_description_revisions (entity_id, revision, description, origin, comment)
+SELECT id, 1, description, 'llm', 'Recorded when history began'
+FROM entities
+WHERE description IS NOT NULL;
+
+-- Descriptions written outside the API, by extraction, are recorded as
+-- model revisions. The API records its own revision first, so these
+-- triggers find the description already current and skip it.
+CREATE OR REPLACE FUNCTION record_description_revision() RETURNS TRIGGER AS $$
+DECLARE
+    latest entity_description_revisions%ROWTYPE;
+BEGIN
+    SELECT * INTO latest FROM entity_description_revisions
+    WHERE entity_id = NEW.id ORDER BY revision DESC LIMIT 1;
+    IF (FOUND AND latest.description IS NOT DISTINCT FROM NEW.description)
+       OR (NOT FOUND AND NEW.description IS NULL) THEN
+        RETURN NULL;
+    END IF;
+    INSERT INTO entity_description_revisions (entity_id, revision, description, origin)
+    VALUES (NEW.id, COALESCE(latest.revision, 0) + 1, NEW.description, 'llm');
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trigger_entities_description_insert
+AFTER INSERT ON entities
+FOR EACH ROW WHEN (NEW.description IS NOT NULL)
+EXECUTE FUNCTION record_description_revision();
+
+CREATE TRIGGER trigger_entities_description_update
+AFTER UPDATE OF description ON entities
+FOR EACH ROW WHEN (OLD.description IS DISTINCT FROM NEW.description)
+EXECUTE FUNCTION record_description_revision();
@@ -0,0 +1,101 @@
+-- Soft delete (api/internal/trash). A deleted document or entity keeps its
+-- row, marked with deleted_at, and disappears from the public API until
+-- restored, so a bad ingest can be rolled back without losing history.
+-- Its document_entities links are moved aside to deleted_document_entities
+-- rather than filtered everywhere they are joined, which takes it out of
+-- connections, the network and counts in one step; restoring moves them
+-- back. Columns added to document_entities must be added here too.
+
+ALTER TABLE documents
+    ADD COLUMN deleted_at       TIMESTAMPTZ,
+    ADD COLUMN deleted_by       INTEGER REFERENCES api_keys(id) ON DELETE SET NULL,
+    ADD COLUMN deleted_reason   TEXT;
+
+ALTER TABLE entities
+    ADD COLUMN deleted_at       TIMESTAMPTZ,
+    ADD COLUMN deleted_by       INTEGER REFERENCES api_keys(id) ON DELETE SET NULL,
+    ADD COLUMN deleted_reason   TEXT;
+
+CREATE INDEX idx_documents_deleted ON documents(deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX idx_entities_deleted ON entities(deleted_at) WHERE deleted_at IS NOT NULL;
+
+CREATE TABLE deleted_document_entities (LIKE document_entities INCLUDING DEFAULTS);
+
+ALTER TABLE deleted_document_entities
+    ADD COLUMN deleted_with     TEXT NOT NULL CHECK (deleted_with IN ('document', 'entity')),
+    ADD COLUMN deleted_with_id  INTEGER NOT NULL;
+
+CREATE INDEX idx_deleted_document_entities_with ON deleted_document_entities(deleted_with, deleted_with_id);
+
+-- The stats views count what the API shows
+DROP MATERIALIZED VIEW stats_totals;
+
+CREATE MATERIALIZED VIEW stats_totals AS
+SELECT
+    1                                                           AS id,
+    (SELECT COUNT(*) FROM documents WHERE deleted_at IS NULL)   AS documents,
+    (SELECT COUNT(*) FROM entities WHERE deleted_at IS NULL)    AS entities,
+    (SELECT COUNT(*) FROM triples t
+     WHERE NOT EXISTS (SELECT 1 FROM documents d WHERE d.id = t.document_id AND d.deleted_at IS NOT NULL)
+       AND NOT EXISTS (SELECT 1 FROM entities e
+                       WHERE e.id IN (t.subject_id, t.object_id) AND e.deleted_at IS NOT NULL)
+    )                                                           AS triples,
+    (SELECT COUNT(*) FROM ppp_loans)                            AS ppp_loans,
+    (SELECT COUNT(*) FROM fec_contributions)                    AS fec_records,
+    (SELECT COUNT(*) FROM federal_grants)                       AS grants,
+    (SELECT COUNT(*) FROM pattern_findings)                     AS patterns,
+    (SELECT MAX(updated_at) FROM documents)                     AS documents_changed_at,
+    (SELECT MAX(updated_at) FROM entities)                      AS entities_changed_at,
+    (SELECT MAX(created_at) FROM triples)                       AS triples_changed_at,
+    (SELECT MAX(created_at) FROM ppp_loans)                     AS ppp_loans_changed_at,
+    (SELECT MAX(created_at) FROM fec_contributions)             AS fec_records_changed_at,
+    (SELECT MAX(created_at) FROM federal_grants)                AS grants_changed_at,
+    (SELECT MAX(GREATEST(discovered_at, validated_at))
+     FROM pattern_findings)                                     AS patterns_changed_at,
+    NOW()                                                       AS refreshed_at;
+
+CREATE UNIQUE INDEX idx_stats_totals_id ON stats_totals(id);
+
+DROP MATERIALIZED VIEW stats_by_dataset;
+
+CREATE MATERIALIZED VIEW stats_by_dataset AS
+SELECT
+    d.dataset_id,
+    COALESCE(ds.name, 'DataSet ' || d.dataset_id)   AS name,
+    COUNT(*)                                        AS documents,
+    COALESCE(SUM(d.page_count), 0)                  AS pages,
+    (SELECT COUNT(DISTINCT de.entity_id)
+     FROM document_entities de
+     JOIN documents dd ON dd.id = de.document_id
+     WHERE dd.dataset_id = d.dataset_id)            AS entities
+FROM documents d
+LEFT JOIN datasets ds ON ds.id = d.dataset_id
+WHERE d.deleted_at IS NULL
+GROUP BY d.dataset_id, ds.name;
+
+CREATE UNIQUE INDEX idx_stats_by_dataset_id ON stats_by_dataset(dataset_id);
+
+DROP MATERIALIZED VIEW stats_by_entity_type;
+
+CREATE MATERIALIZED VIEW stats_by_entity_type AS
+SELECT
+    entity_type,
+    COUNT(*)                            AS entities,
+    COALESCE(SUM(document_count), 0)    AS mentions
+FROM entities
+WHERE deleted_at IS NULL
+GROUP BY entity_type;
+
+CREATE UNIQUE INDEX idx_stats_by_entity_type ON stats_by_entity_type(entity_type);
+
+DROP MATERIALIZED VIEW stats_by_document_type;
+
+CREATE MATERIALIZED VIEW stats_by_document_type AS
+SELECT
+    document_type,
+    COUNT(*)    AS documents
+FROM documents
+WHERE document_type IS NOT NULL AND document_type <> '' AND deleted_at IS NULL
+GROUP BY document_type;
+
+CREATE UNIQUE INDEX idx_stats_by_document_type ON stats_by_document_type(document_type);
@@ -0,0 +1,13 @@
+-- Per-dataset access. Documents of a restricted dataset, one whose terms
+-- don't allow redistribution, are served metadata-only to callers without
+-- an API key: no summaries, text, chunks, page images or search hits, in
+-- the REST API, GraphQL, gRPC, feeds and exports alike.
+
+ALTER TABLE datasets
+    ADD COLUMN visibility TEXT NOT NULL DEFAULT 'public' CHECK (visibility IN ('public', 'restricted'));
+
+-- dataset_restricted reports whether documents of a dataset are restricted.
+-- Documents of an unregistered dataset are public.
+CREATE FUNCTION dataset_restricted(dataset INTEGER) RETURNS BOOLEAN AS $$
+    SELECT EXISTS (SELECT 1 FROM datasets WHERE id = dataset AND visibility = 'restricted')
+$$ LANGUAGE sql STABLE;
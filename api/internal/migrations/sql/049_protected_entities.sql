@@ -0,0 +1,13 @@
+-- Protected entities (api/internal/store/protected.go), such as identified
+-- victims. A protected entity is left out of entity search, connections,
+-- the network, triples and exports, and its names are masked in document
+-- text, summaries and snippets. Its rows and links are kept, so protecting
+-- it can be undone.
+
+ALTER TABLE entities
+    ADD COLUMN protected        BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN protected_at     TIMESTAMPTZ,
+    ADD COLUMN protected_by     INTEGER REFERENCES api_keys(id) ON DELETE SET NULL,
+    ADD COLUMN protected_reason TEXT;
+
+CREATE INDEX idx_entities_protected ON entities(id) WHERE protected;
@@ -0,0 +1,18 @@
+-- Sensitivity labels found in document text (api/internal/sensitivity):
+-- explicit content, references to minors and medical records. Every
+-- document gets a row once its text has been read, with no labels when
+-- none apply, so the nightly job can tell which changed since.
+
+CREATE TABLE document_sensitivity (
+    document_id INTEGER PRIMARY KEY REFERENCES documents(id) ON DELETE CASCADE,
+    labels      TEXT[] NOT NULL DEFAULT '{}',
+    computed_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_document_sensitivity_labeled ON document_sensitivity(document_id) WHERE labels <> '{}';
+
+-- sensitivity_labels returns a document's labels, none when its text
+-- hasn't been read yet
+CREATE FUNCTION sensitivity_labels(document INTEGER) RETURNS TEXT[] AS $$
+    SELECT COALESCE((SELECT labels FROM document_sensitivity WHERE document_id = document), '{}')
+$$ LANGUAGE sql STABLE;
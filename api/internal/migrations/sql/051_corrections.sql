@@ -0,0 +1,69 @@
+-- Correction and takedown requests (api/internal/corrections). A subject,
+-- or their counsel, disputes how an entity is linked, to another person or
+-- to cross-reference records, or its association with documents. Each
+-- request is a case worked through open, reviewing and then upheld or
+-- rejected, with every step kept in correction_events. The submitter
+-- follows the case with the token they were given.
+
+CREATE TABLE correction_requests (
+    id            BIGSERIAL PRIMARY KEY,
+    kind          TEXT NOT NULL CHECK (kind IN ('linkage', 'association')),
+    entity_id     INTEGER NOT NULL REFERENCES entities(id) ON DELETE CASCADE,
+    document_ids  INTEGER[] NOT NULL DEFAULT '{}',      -- The disputed documents of an association
+    requester     TEXT NOT NULL,
+    relationship  TEXT NOT NULL CHECK (relationship IN ('subject', 'counsel', 'other')),
+    contact       TEXT NOT NULL,
+    body          TEXT NOT NULL,
+    key_id        INTEGER REFERENCES api_keys(id) ON DELETE SET NULL,
+    token         TEXT NOT NULL UNIQUE,
+    status        TEXT NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'reviewing', 'upheld', 'rejected')),
+    resolution    TEXT,                                 -- Shown to the submitter
+    submitted_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at    TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    resolved_at   TIMESTAMPTZ,
+    resolved_by   INTEGER REFERENCES api_keys(id) ON DELETE SET NULL
+);
+
+CREATE INDEX idx_correction_requests_queue ON correction_requests(status, submitted_at);
+CREATE INDEX idx_correction_requests_entity ON correction_requests(entity_id);
+CREATE INDEX idx_correction_requests_documents ON correction_requests USING GIN (document_ids);
+
+CREATE TABLE correction_events (
+    id          BIGSERIAL PRIMARY KEY,
+    request_id  BIGINT NOT NULL REFERENCES correction_requests(id) ON DELETE CASCADE,
+    status      TEXT NOT NULL,
+    note        TEXT,
+    key_id      INTEGER REFERENCES api_keys(id) ON DELETE SET NULL,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_correction_events_request ON correction_events(request_id, created_at);
+
+-- dispute_status sums up the requests concerning a record: 'disputed' while
+-- any is open or under review, 'corrected' once one is upheld, NULL when
+-- there are none or all were rejected
+CREATE FUNCTION dispute_status(statuses TEXT[]) RETURNS TEXT AS $$
+    SELECT CASE
+        WHEN statuses && ARRAY['open', 'reviewing'] THEN 'disputed'
+        WHEN 'upheld' = ANY(statuses) THEN 'corrected'
+    END
+$$ LANGUAGE sql IMMUTABLE;
+
+-- entity_dispute is the dispute status of an entity's linkage
+CREATE FUNCTION entity_dispute(entity INTEGER) RETURNS TEXT AS $$
+    SELECT dispute_status(ARRAY(
+        SELECT status FROM correction_requests WHERE entity_id = entity AND kind = 'linkage'))
+$$ LANGUAGE sql STABLE;
+
+-- document_dispute is the dispute status of a document's associations
+CREATE FUNCTION document_dispute(document INTEGER) RETURNS TEXT AS $$
+    SELECT dispute_status(ARRAY(
+        SELECT status FROM correction_requests WHERE document_ids @> ARRAY[document]))
+$$ LANGUAGE sql STABLE;
+
+-- link_dispute is the dispute status of one entity's association with one
+-- document
+CREATE FUNCTION link_dispute(entity INTEGER, document INTEGER) RETURNS TEXT AS $$
+    SELECT dispute_status(ARRAY(
+        SELECT status FROM correction_requests WHERE entity_id = entity AND document_ids @> ARRAY[document]))
+$$ LANGUAGE sql STABLE;
@@ -0,0 +1,11 @@
+-- Files copied to object storage (api/internal/blobs): documents' original
+-- files, page thumbnails and exports, by their key in the bucket. The size
+-- and modification time of the local file when it was uploaded tell the
+-- sync whether the copy is stale.
+
+CREATE TABLE stored_blobs (
+    key         TEXT PRIMARY KEY,
+    size        BIGINT NOT NULL,
+    modified_at TIMESTAMPTZ NOT NULL,
+    uploaded_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,40 @@
+-- Log changes to the links between rows too (api/internal/changes): which
+-- entities a document names, and the weighted edges between entities. A
+-- mirror that only follows documents and entities misses a relinked mention
+-- or a recomputed network.
+--
+-- log_changes() identifies rows by id, which entity_edges, keyed on its
+-- pair of entities, didn't have. The strength worker rebuilds every edge,
+-- so a rebuild logs each edge's deletion and its replacement's insertion.
+
+ALTER TABLE entity_edges ADD COLUMN id BIGSERIAL UNIQUE;
+
+CREATE TRIGGER trigger_document_entities_log_insert
+AFTER INSERT ON document_entities
+REFERENCING NEW TABLE AS new_rows
+FOR EACH STATEMENT EXECUTE FUNCTION log_changes();
+
+CREATE TRIGGER trigger_document_entities_log_update
+AFTER UPDATE ON document_entities
+REFERENCING OLD TABLE AS old_rows NEW TABLE AS new_rows
+FOR EACH STATEMENT EXECUTE FUNCTION log_changes();
+
+CREATE TRIGGER trigger_document_entities_log_delete
+AFTER DELETE ON document_entities
+REFERENCING OLD TABLE AS old_rows
+FOR EACH STATEMENT EXECUTE FUNCTION log_changes();
+
+CREATE TRIGGER trigger_entity_edges_log_insert
+AFTER INSERT ON entity_edges
+REFERENCING NEW TABLE AS new_rows
+FOR EACH STATEMENT EXECUTE FUNCTION log_changes();
+
+CREATE TRIGGER trigger_entity_edges_log_update
+AFTER UPDATE ON entity_edges
+REFERENCING OLD TABLE AS old_rows NEW TABLE AS new_rows
+FOR EACH STATEMENT EXECUTE FUNCTION log_changes();
+
+CREATE TRIGGER trigger_entity_edges_log_delete
+AFTER DELETE ON entity_edges
+REFERENCING OLD TABLE AS old_rows
+FOR EACH STATEMENT EXECUTE FUNCTION log_changes();
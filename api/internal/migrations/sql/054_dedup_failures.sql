@@ -0,0 +1,17 @@
+-- Pairs the dedup worker (api/internal/dedup) failed to judge, say because
+-- the model's answer didn't parse. Without a record the same pairs, the
+-- most similar, came back first on every run. A failed pair is retried
+-- after a backoff that doubles with each attempt, and given up on after the
+-- worker's -max-attempts.
+
+CREATE TABLE dedup_failures (
+    entity_a_id     INTEGER NOT NULL REFERENCES entities(id) ON DELETE CASCADE,
+    entity_b_id     INTEGER NOT NULL REFERENCES entities(id) ON DELETE CASCADE,
+    attempts        INTEGER NOT NULL DEFAULT 1,
+    error           TEXT,                           -- Why the last attempt failed
+    next_attempt_at TIMESTAMPTZ NOT NULL,
+    updated_at      TIMESTAMPTZ DEFAULT NOW(),
+
+    PRIMARY KEY (entity_a_id, entity_b_id),
+    CHECK (entity_a_id < entity_b_id)
+);
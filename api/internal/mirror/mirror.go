@@ -0,0 +1,166 @@
+// Package mirror holds the middleware for running the API as a read-only
+// public replica: a guard that turns away writes and an in-memory cache of
+// GET responses.
+package mirror
+
+import (
+	"bytes"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/subculture-collective/epstein-db/api/internal/apierr"
+	"github.com/subculture-collective/epstein-db/api/internal/auth"
+)
+
+// ReadOnly rejects requests that could change data. Reads sent as POST,
+// such as /api/batch, are let through when listed in allow.
+func ReadOnly(allow ...string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		switch c.Method() {
+		case fiber.MethodGet, fiber.MethodHead, fiber.MethodOptions:
+			return c.Next()
+		}
+		for _, path := range allow {
+			if c.Method() == fiber.MethodPost && c.Path() == path {
+				return c.Next()
+			}
+		}
+		c.Set(fiber.HeaderAllow, "GET, HEAD, OPTIONS")
+		return apierr.New(fiber.StatusMethodNotAllowed, apierr.CodeForbidden,
+			"this server is a read-only mirror")
+	}
+}
+
+// storedHeaders are the response headers replayed from the cache. Anything
+// per request, like rate limits and request IDs, is set afresh.
+var storedHeaders = []string{
+	fiber.HeaderCacheControl,
+	fiber.HeaderContentDisposition,
+	fiber.HeaderETag,
+	fiber.HeaderLastModified,
+	fiber.HeaderLink,
+	fiber.HeaderVary,
+}
+
+type entry struct {
+	body        []byte
+	contentType string
+	headers     map[string]string
+	expires     time.Time
+}
+
+// Cache keeps successful GET responses in memory for ttl, keyed by URL and
+// Accept header, up to size bytes of bodies. When it is full, new responses
+// aren't kept until old ones expire. Streamed responses, responses marked
+// no-store or private, requests with an API key and paths in skip pass
+// through untouched: a keyed caller may be shown restricted content, and
+// must not be served what was cached for an anonymous one.
+type Cache struct {
+	ttl  time.Duration
+	size int
+	skip map[string]bool
+
+	mu      sync.RWMutex
+	entries map[string]*entry
+	used    int
+}
+
+// NewCache creates an empty cache
+func NewCache(ttl time.Duration, size int, skip ...string) *Cache {
+	c := &Cache{ttl: ttl, size: size, skip: map[string]bool{}, entries: map[string]*entry{}}
+	for _, path := range skip {
+		c.skip[path] = true
+	}
+	return c
+}
+
+// Middleware serves cached responses and stores fresh ones. Responses say
+// whether they came from the cache in X-Cache, and may be reused by
+// clients and proxies for as long as the cache keeps them.
+func (m *Cache) Middleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if c.Method() != fiber.MethodGet || m.skip[c.Path()] || auth.FromContext(c).KeyID != 0 {
+			return c.Next()
+		}
+
+		key := c.OriginalURL() + "\x00" + c.Get(fiber.HeaderAccept)
+		now := time.Now()
+
+		m.mu.RLock()
+		e := m.entries[key]
+		m.mu.RUnlock()
+		if e != nil && now.Before(e.expires) {
+			c.Set("X-Cache", "hit")
+			for name, value := range e.headers {
+				c.Set(name, value)
+			}
+			if etag := e.headers[fiber.HeaderETag]; etag != "" && c.Get(fiber.HeaderIfNoneMatch) == etag {
+				return c.SendStatus(fiber.StatusNotModified)
+			}
+			c.Set(fiber.HeaderContentType, e.contentType)
+			return c.Send(e.body)
+		}
+
+		if err := c.Next(); err != nil {
+			return err
+		}
+		c.Set("X-Cache", "miss")
+
+		res := c.Response()
+		if res.StatusCode() != fiber.StatusOK || res.IsBodyStream() ||
+			bytes.Contains(res.Header.Peek(fiber.HeaderCacheControl), []byte("no-store")) ||
+			bytes.Contains(res.Header.Peek(fiber.HeaderCacheControl), []byte("private")) {
+			return nil
+		}
+		c.Vary(fiber.HeaderAccept)
+		if len(res.Header.Peek(fiber.HeaderCacheControl)) == 0 {
+			c.Set(fiber.HeaderCacheControl, "public, max-age="+strconv.Itoa(int(m.ttl.Seconds())))
+		}
+
+		e = &entry{
+			body:        append([]byte(nil), res.Body()...),
+			contentType: string(res.Header.ContentType()),
+			headers:     map[string]string{},
+			expires:     now.Add(m.ttl),
+		}
+		for _, name := range storedHeaders {
+			if v := res.Header.Peek(name); len(v) > 0 {
+				e.headers[name] = string(v)
+			}
+		}
+		m.store(key, e, now)
+		return nil
+	}
+}
+
+// store keeps e unless that would take the cache over its size
+func (m *Cache) store(key string, e *entry, now time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if old := m.entries[key]; old != nil {
+		m.used -= len(old.body)
+		delete(m.entries, key)
+	}
+	if m.used+len(e.body) > m.size {
+		m.sweep(now)
+	}
+	if m.used+len(e.body) > m.size {
+		return
+	}
+	m.entries[key] = e
+	m.used += len(e.body)
+}
+
+// sweep drops expired entries
+func (m *Cache) sweep(now time.Time) {
+	for key, e := range m.entries {
+		if !now.Before(e.expires) {
+			m.used -= len(e.body)
+			delete(m.entries, key)
+		}
+	}
+}
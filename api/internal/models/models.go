@@ -0,0 +1,614 @@
+// Package models holds the rows the store returns. The handlers serialize
+// them directly, so their JSON tags are the API's field names.
+package models
+
+import "time"
+
+// Stats are row counts for the main tables, as of the last refresh
+type Stats struct {
+	Documents     int64             `json:"documents"`
+	Entities      int64             `json:"entities"`
+	Triples       int64             `json:"triples"`
+	PPPLoans      int64             `json:"pppLoans"`
+	FECRecords    int64             `json:"fecRecords"`
+	Grants        int64             `json:"grants"`
+	Patterns      int64             `json:"patterns"`
+	Tables        []TableStats      `json:"tables"`
+	ByDataset     []DatasetStats    `json:"byDataset"`
+	ByEntityType  []EntityTypeStats `json:"byEntityType"`
+	LastRefreshed *time.Time        `json:"lastRefreshed"`
+	Unavailable   []string          `json:"unavailable,omitempty" doc:"Stats views that don't exist or were never refreshed; their counts are left out rather than reported as zero"`
+}
+
+// TableStats are the count for one of the main tables and when it last
+// changed, both as of the last refresh
+type TableStats struct {
+	Table       string     `json:"table"`
+	Rows        int64      `json:"rows"`
+	LastChanged *time.Time `json:"lastChanged" doc:"Latest insert or update; null when the table is empty"`
+}
+
+// DatasetStats are the counts for one dataset
+type DatasetStats struct {
+	DatasetID int    `json:"datasetId"`
+	Name      string `json:"name"`
+	Documents int64  `json:"documents"`
+	Pages     int64  `json:"pages"`
+	Entities  int64  `json:"entities" doc:"Distinct entities mentioned"`
+}
+
+// EntityTypeStats are the counts for one entity type
+type EntityTypeStats struct {
+	EntityType string `json:"entityType"`
+	Entities   int64  `json:"entities"`
+	Mentions   int64  `json:"mentions" doc:"Sum of document counts"`
+}
+
+// TypeCount is one value of a type taxonomy and how many rows have it
+type TypeCount struct {
+	Type  string `json:"type"`
+	Count int64  `json:"count"`
+}
+
+// EntitySummary is an entity as it appears in lists and network graphs
+type EntitySummary struct {
+	ID              int    `json:"id"`
+	CanonicalName   string `json:"canonicalName"`
+	EntityType      string `json:"entityType"`
+	Layer           *int   `json:"layer"`
+	DistanceToCore  *int   `json:"distanceToCore" doc:"Hops through shared documents from the core entities; null when out of reach"`
+	DocumentCount   *int   `json:"documentCount"`
+	ConnectionCount *int   `json:"connectionCount"`
+	ImageID         *int   `json:"imageId" doc:"Primary image, served at /api/media/{imageId}"`
+}
+
+// Entity is a single entity with its cross-reference matches
+type Entity struct {
+	ID                int          `json:"id"`
+	CanonicalName     string       `json:"canonicalName"`
+	EntityType        string       `json:"entityType"`
+	Layer             *int         `json:"layer"`
+	DistanceToCore    *int         `json:"distanceToCore" doc:"Hops through shared documents from the core entities; null when out of reach"`
+	Description       *string      `json:"description"`
+	DescriptionOrigin *string      `json:"descriptionOrigin" enum:"human,llm" doc:"Whether a person or a model wrote the description"`
+	DocumentCount     *int         `json:"documentCount"`
+	ConnectionCount   *int         `json:"connectionCount"`
+	Aliases           []string     `json:"aliases" doc:"Alternative names"`
+	PPPMatches        []PPPMatch   `json:"pppMatches"`
+	FECMatches        []FECMatch   `json:"fecMatches"`
+	GrantsMatches     []GrantMatch `json:"grantsMatches"`
+	ImageID           *int         `json:"imageId" doc:"Primary image, served at /api/media/{imageId}"`
+	Dispute           *string      `json:"dispute" enum:"disputed,corrected" doc:"disputed while a correction request against its linkage is pending, corrected once one is upheld"`
+
+	UpdatedAt time.Time `json:"-"`
+}
+
+// PPPMatch is a PPP loan matched to an entity, as summarized on the entity
+type PPPMatch struct {
+	ID       int      `json:"id"`
+	Borrower string   `json:"borrower"`
+	Amount   *float64 `json:"amount"`
+	Score    float64  `json:"score"`
+}
+
+// FECMatch is an FEC contribution matched to an entity
+type FECMatch struct {
+	ID          int      `json:"id"`
+	Contributor string   `json:"contributor"`
+	Candidate   *string  `json:"candidate"`
+	Amount      *float64 `json:"amount"`
+	Score       float64  `json:"score"`
+}
+
+// GrantMatch is a federal grant matched to an entity
+type GrantMatch struct {
+	ID        int      `json:"id"`
+	Recipient string   `json:"recipient"`
+	Agency    *string  `json:"agency"`
+	Amount    *float64 `json:"amount"`
+	Score     float64  `json:"score"`
+}
+
+// EntityRef identifies an entity by ID and name
+type EntityRef struct {
+	ID            int    `json:"id"`
+	CanonicalName string `json:"canonicalName"`
+}
+
+// EntityBrief is an entity reference with its type and layer
+type EntityBrief struct {
+	ID            int    `json:"id"`
+	CanonicalName string `json:"canonicalName"`
+	EntityType    string `json:"entityType"`
+	Layer         *int   `json:"layer"`
+}
+
+// EgoNode is an entity in another's neighbourhood, Ring hops from it
+type EgoNode struct {
+	EntityBrief
+	Ring int `json:"ring" doc:"0 for the entity itself, 1 for those related to it, 2 for theirs"`
+}
+
+// Mentions is how often a set of entities is mentioned over time
+type Mentions struct {
+	Interval string          `json:"interval" enum:"week,month,quarter,year"`
+	Periods  []string        `json:"periods" doc:"Start date of each bucket, oldest first, with no gaps"`
+	Series   []MentionSeries `json:"series"`
+	Missing  []int           `json:"missing" doc:"Requested IDs that aren't entities"`
+}
+
+// MentionSeries is one entity's counts, aligned with Mentions.Periods
+type MentionSeries struct {
+	Entity    EntityBrief `json:"entity"`
+	Documents []int       `json:"documents" doc:"Dated documents mentioning the entity in each period"`
+	Mentions  []int       `json:"mentions" doc:"Mentions across those documents"`
+	Undated   int         `json:"undated" doc:"Documents mentioning the entity that have no date"`
+}
+
+// Matrix is the pairwise co-occurrence of a set of entities
+type Matrix struct {
+	Entities  []EntityBrief `json:"entities" doc:"Rows and columns, in the order requested"`
+	Missing   []int         `json:"missing" doc:"Requested IDs that aren't entities"`
+	Shared    [][]int       `json:"shared" doc:"Documents mentioning both entities; the diagonal is each entity's documents"`
+	Relations []Relation    `json:"relations" doc:"Extracted relationships between the entities"`
+}
+
+// Relation is the extracted triples from one entity to another
+type Relation struct {
+	Subject    int      `json:"subject"`
+	Object     int      `json:"object"`
+	Predicates []string `json:"predicates"`
+	Count      int      `json:"count" doc:"Number of triples"`
+}
+
+// TimelineEvent is a dated event on the corpus-wide timeline
+type TimelineEvent struct {
+	ID          int64         `json:"id"`
+	Date        string        `json:"date" doc:"YYYY-MM-DD"`
+	Description string        `json:"description"`
+	Type        string        `json:"type" enum:"flight,meeting,communication,legal,financial,relationship,other"`
+	Origin      string        `json:"origin" enum:"extracted,manual"`
+	DocumentID  *int          `json:"documentId"`
+	DocID       *string       `json:"docId"`
+	Crossref    *string       `json:"crossref" enum:"ppp,fec,grants"`
+	CrossrefID  *int          `json:"crossrefId"`
+	Entities    []EntityBrief `json:"entities"`
+}
+
+// Connection is an entity that co-occurs with another in documents
+type Connection struct {
+	ID            int      `json:"id"`
+	CanonicalName string   `json:"canonicalName"`
+	EntityType    string   `json:"entityType"`
+	Layer         *int     `json:"layer"`
+	SharedDocs    int      `json:"sharedDocs" doc:"Documents naming both; at page granularity, only those naming both within a few pages"`
+	Proximity     *float64 `json:"proximity" doc:"Page granularity only: per shared document, 1 for mentions on the same page, halving with each page between them, summed"`
+}
+
+// DocumentSummary is a document as it appears in lists
+type DocumentSummary struct {
+	ID           int      `json:"id"`
+	DocID        string   `json:"docId"`
+	DatasetID    int      `json:"datasetId"`
+	DocumentType *string  `json:"documentType"`
+	Summary      *string  `json:"summary"`
+	DateEarliest *string  `json:"dateEarliest"`
+	DateLatest   *string  `json:"dateLatest"`
+	Restricted   bool     `json:"restricted" doc:"From a restricted dataset; without an API key only its metadata is served"`
+	Sensitivity  []string `json:"sensitivity" doc:"Content warnings: explicit, minors, medical; empty when none apply"`
+}
+
+// DuplicateDocument is a near duplicate of a document
+type DuplicateDocument struct {
+	DocumentSummary
+	Similarity float64 `json:"similarity" doc:"Share of text fingerprint bits the two agree on, 0 to 1"`
+}
+
+// EntityDocument is a document that mentions an entity, with the entity's
+// role in it
+type EntityDocument struct {
+	DocumentSummary
+	Confidence *float64 `json:"confidence" doc:"How sure extraction was of the link, 0 to 1, discounted for poor OCR"`
+	Role       *string  `json:"role" doc:"The entity's role in the document inferred from its text; null when unclassified or none"`
+	RoleQuote  *string  `json:"roleQuote" doc:"Passage the role was inferred from"`
+	Dispute    *string  `json:"dispute" enum:"disputed,corrected" doc:"Dispute status of the entity's association with the document"`
+}
+
+// Document is a single document without its full text
+type Document struct {
+	ID              int      `json:"id"`
+	DocID           string   `json:"docId"`
+	DatasetID       int      `json:"datasetId"`
+	DocumentType    *string  `json:"documentType"`
+	Summary         *string  `json:"summary"`
+	DetailedSummary *string  `json:"detailedSummary"`
+	DateEarliest    *string  `json:"dateEarliest"`
+	DateLatest      *string  `json:"dateLatest"`
+	ContentTags     []string `json:"contentTags"`
+	PageCount       *int     `json:"pageCount"`
+	Restricted      bool     `json:"restricted" doc:"From a restricted dataset; without an API key only its metadata is served"`
+	Sensitivity     []string `json:"sensitivity" doc:"Content warnings: explicit, minors, medical; empty when none apply"`
+	Dispute         *string  `json:"dispute" enum:"disputed,corrected" doc:"disputed while a correction request against an entity's association with it is pending, corrected once one is upheld"`
+
+	UpdatedAt time.Time `json:"-"`
+}
+
+// DocumentEntity is an entity mentioned in a document
+type DocumentEntity struct {
+	ID            int      `json:"id"`
+	CanonicalName string   `json:"canonicalName"`
+	EntityType    string   `json:"entityType"`
+	Layer         *int     `json:"layer"`
+	MentionCount  int      `json:"mentionCount"`
+	Confidence    *float64 `json:"confidence" doc:"How sure extraction was of the link, 0 to 1, discounted for poor OCR"`
+	Role          *string  `json:"role" doc:"Role in the document inferred from its text; null when unclassified or none"`
+	RoleQuote     *string  `json:"roleQuote" doc:"Passage the role was inferred from"`
+	Dispute       *string  `json:"dispute" enum:"disputed,corrected" doc:"Dispute status of the entity's association with the document"`
+}
+
+// DocumentChunk is one of the passages a document is split into for
+// embedding
+type DocumentChunk struct {
+	ID       int    `json:"id"`
+	Index    int    `json:"index" doc:"Order within the document"`
+	Content  string `json:"content"`
+	Start    int    `json:"start" doc:"Offset of the chunk in the document's full text"`
+	End      int    `json:"end"`
+	Model    string `json:"model" doc:"Embedding model"`
+	Embedded bool   `json:"embedded" doc:"Whether the chunk has an embedding"`
+}
+
+// SearchResult is a document matching a full-text query
+type SearchResult struct {
+	ID           int             `json:"id"`
+	DocID        string          `json:"docId"`
+	DocumentType *string         `json:"documentType"`
+	Summary      *string         `json:"summary"`
+	Sensitivity  []string        `json:"sensitivity" doc:"Content warnings: explicit, minors, medical; empty when none apply"`
+	Rank         float64         `json:"rank"`
+	Snippet      *string         `json:"snippet" doc:"Matching passage with hits wrapped in <mark> and known entities in <span data-entity-id>"`
+	Entities     []SnippetEntity `json:"entities,omitempty" doc:"Entities marked in the snippet, in order of appearance"`
+	Collapsed    int             `json:"collapsed,omitempty" doc:"dedupe: near duplicates of this document the search also found, left out"`
+}
+
+// SharedGroup is a set of cross-reference records under different names
+// that share an address or employer
+type SharedGroup struct {
+	Attribute string         `json:"attribute"`
+	Value     string         `json:"value" doc:"The shared value, normalized"`
+	Records   int64          `json:"records"`
+	Names     int64          `json:"names" doc:"Distinct names among the records, not counting the employer's own"`
+	Members   []SharedMember `json:"members" doc:"Up to 100 of the records"`
+	Entities  []EntityBrief  `json:"entities" doc:"Entities matched to any of the records, most mentioned first"`
+}
+
+// SharedMember is a cross-reference record in a SharedGroup
+type SharedMember struct {
+	Source string `json:"source" enum:"ppp,fec,grants"`
+	ID     int    `json:"id"`
+	Name   string `json:"name"`
+	Role   string `json:"role" enum:"borrower,employee,employer" doc:"A borrower at the address, a donor naming the employer, or the employer itself"`
+}
+
+// Resolution is the entities a name from an external list may refer to
+type Resolution struct {
+	Name         string             `json:"name" doc:"The name as given"`
+	Match        *ResolveCandidate  `json:"match" doc:"Best match; null when nothing matched"`
+	Alternatives []ResolveCandidate `json:"alternatives" doc:"The next best matches, best first"`
+}
+
+// ResolveCandidate is an entity a name may refer to
+type ResolveCandidate struct {
+	ID            int     `json:"id"`
+	CanonicalName string  `json:"canonicalName"`
+	EntityType    string  `json:"entityType"`
+	DocumentCount *int    `json:"documentCount"`
+	MatchedName   string  `json:"matchedName" doc:"The canonical name or alias that matched"`
+	Alias         bool    `json:"alias" doc:"Whether matchedName is an alias"`
+	Score         float64 `json:"score" doc:"Trigram similarity of matchedName to the name, 0 to 1; 1 for the same name in any case"`
+}
+
+// SnippetEntity is an entity whose name appears in a search snippet
+type SnippetEntity struct {
+	ID            int    `json:"id"`
+	CanonicalName string `json:"canonicalName"`
+	EntityType    string `json:"entityType"`
+}
+
+// SourceFile is the release file a document was ingested from
+type SourceFile struct {
+	ID            *int       `json:"id"`
+	Filename      *string    `json:"filename"`
+	Location      *string    `json:"location"`
+	SHA256        *string    `json:"sha256"`
+	ByteSize      *int64     `json:"byteSize"`
+	DocumentCount *int       `json:"documentCount"`
+	FirstDocID    *string    `json:"firstDocId"`
+	LastDocID     *string    `json:"lastDocId"`
+	IngestedAt    *time.Time `json:"ingestedAt"`
+	CompletedAt   *time.Time `json:"completedAt"`
+}
+
+// LineRange is a span of lines in a source file
+type LineRange struct {
+	Start *int `json:"start"`
+	End   *int `json:"end"`
+}
+
+// OCRSummary describes the OCR output behind a document's text
+type OCRSummary struct {
+	Pages          int      `json:"pages"`
+	MeanConfidence *float64 `json:"meanConfidence"`
+	Engine         *string  `json:"engine"`
+}
+
+// Provenance traces a document back to its source
+type Provenance struct {
+	ID         int         `json:"id"`
+	DocID      string      `json:"docId"`
+	DatasetID  int         `json:"datasetId"`
+	FilePath   *string     `json:"filePath"`
+	SourceFile *SourceFile `json:"sourceFile,omitempty"`
+	Lines      *LineRange  `json:"lines,omitempty"`
+	OCR        *OCRSummary `json:"ocr,omitempty"`
+}
+
+// Dataset is a registered dataset release
+type Dataset struct {
+	ID            int        `json:"id"`
+	Name          string     `json:"name"`
+	Status        string     `json:"status"`
+	DocumentCount *int       `json:"documentCount"`
+	RegisteredAt  time.Time  `json:"registeredAt"`
+	ReadyAt       *time.Time `json:"readyAt"`
+	Visibility    string     `json:"visibility" enum:"public,restricted" doc:"Restricted datasets' documents are metadata-only without an API key"`
+}
+
+// DatasetAnalytics summarizes what a dataset release contains
+type DatasetAnalytics struct {
+	Dataset     Dataset          `json:"dataset"`
+	Documents   int              `json:"documents"`
+	Dates       DateCoverage     `json:"dates"`
+	NewEntities NewEntities      `json:"newEntities"`
+	Overlap     []DatasetOverlap `json:"overlap" doc:"Other datasets sharing entities with this one, most shared first"`
+	OCR         DatasetOCR       `json:"ocr"`
+}
+
+// DateCoverage is how a dataset's documents spread over time, by the
+// document's earliest date (or latest, if that's all it has)
+type DateCoverage struct {
+	Earliest *string     `json:"earliest"`
+	Latest   *string     `json:"latest"`
+	Undated  int         `json:"undated" doc:"Documents with no date"`
+	Years    []YearCount `json:"years" doc:"Histogram of dated documents, oldest year first"`
+}
+
+// YearCount is the documents dated in one year
+type YearCount struct {
+	Year      int `json:"year"`
+	Documents int `json:"documents"`
+}
+
+// NewEntities are the entities a dataset introduced: those mentioned in
+// none of the datasets registered before it
+type NewEntities struct {
+	Count int                `json:"count"`
+	Top   []IntroducedEntity `json:"top" doc:"Most mentioned first"`
+}
+
+// IntroducedEntity is an entity first seen in a dataset
+type IntroducedEntity struct {
+	EntityBrief
+	Documents int `json:"documents" doc:"Documents in the dataset mentioning it"`
+}
+
+// DatasetOverlap is the entities a dataset shares with another
+type DatasetOverlap struct {
+	DatasetID      int    `json:"datasetId"`
+	Name           string `json:"name"`
+	SharedEntities int    `json:"sharedEntities"`
+}
+
+// DatasetOCR is the text quality of a dataset's pages
+type DatasetOCR struct {
+	Pages              int      `json:"pages"`
+	OCRPages           int      `json:"ocrPages" doc:"Pages read by OCR rather than from a text layer"`
+	MeanConfidence     *float64 `json:"meanConfidence" doc:"Mean OCR word confidence, 0-100"`
+	LowConfidencePages int      `json:"lowConfidencePages" doc:"Pages with confidence under 60"`
+	EmptyDocuments     int      `json:"emptyDocuments" doc:"Documents with no text"`
+}
+
+// NetworkEdge links two entities that appear in the same documents
+type NetworkEdge struct {
+	Source    int      `json:"source"`
+	Target    int      `json:"target"`
+	Weight    int      `json:"weight" doc:"Number of shared documents; at page granularity, only those naming both within a few pages"`
+	Score     *float64 `json:"score" doc:"Relationship strength: shared documents weighted by how few names they mention, with boosts for sharing a page and for extracted triples. Null until scored."`
+	Proximity *float64 `json:"proximity" doc:"Page granularity only: per shared document, 1 for mentions on the same page, halving with each page between them, summed"`
+}
+
+// FinancialNode is an entity, or a committee, agency or lender from the
+// crossref data that isn't one
+type FinancialNode struct {
+	ID         string  `json:"id" doc:"entity:<id> for entities; committee:, agency: or lender: and a key otherwise"`
+	Kind       string  `json:"kind" enum:"entity,committee,agency,lender"`
+	Label      string  `json:"label"`
+	EntityID   *int    `json:"entityId"`
+	EntityType *string `json:"entityType"`
+}
+
+// FinancialEdge is money moving from one node to another, totalled over the
+// crossref records behind it
+type FinancialEdge struct {
+	Source  string  `json:"source"`
+	Target  string  `json:"target"`
+	Kind    string  `json:"kind" enum:"contribution,grant,loan"`
+	Amount  float64 `json:"amount" doc:"Total in US dollars"`
+	Records int64   `json:"records"`
+	First   *string `json:"first" doc:"Date of the earliest record, YYYY-MM-DD"`
+	Last    *string `json:"last" doc:"Date of the latest record, YYYY-MM-DD"`
+}
+
+// TripleProvenance locates the sentence a triple was extracted from
+type TripleProvenance struct {
+	DocumentID    int     `json:"documentId"`
+	DocID         string  `json:"docId"`
+	Sentence      *string `json:"sentence"`
+	SentenceStart *int    `json:"sentenceStart"`
+	SentenceEnd   *int    `json:"sentenceEnd"`
+}
+
+// Triple is a subject-predicate-object relationship
+type Triple struct {
+	ID         int              `json:"id"`
+	Predicate  string           `json:"predicate"`
+	Confidence *float64         `json:"confidence"`
+	Method     *string          `json:"method" doc:"Extraction method; null for hand-entered triples"`
+	Subject    EntityRef        `json:"subject"`
+	Object     EntityRef        `json:"object"`
+	Provenance TripleProvenance `json:"provenance"`
+}
+
+// PredicateCount is a predicate and how often it is used
+type PredicateCount struct {
+	Predicate string `json:"predicate"`
+	Count     int    `json:"count"`
+}
+
+// PPPLoan is a Paycheck Protection Program loan
+type PPPLoan struct {
+	ID                int      `json:"id"`
+	BorrowerName      string   `json:"borrowerName"`
+	BorrowerCity      *string  `json:"borrowerCity"`
+	BorrowerState     *string  `json:"borrowerState"`
+	LoanAmount        *float64 `json:"loanAmount"`
+	ForgivenessAmount *float64 `json:"forgivenessAmount"`
+	Lender            *string  `json:"lender"`
+	DateApproved      *string  `json:"dateApproved"`
+	MatchScore        float64  `json:"matchScore"`
+}
+
+// FECContribution is a political contribution
+type FECContribution struct {
+	ID               int      `json:"id"`
+	ContributorName  string   `json:"contributorName"`
+	ContributorCity  *string  `json:"contributorCity"`
+	ContributorState *string  `json:"contributorState"`
+	Employer         *string  `json:"employer"`
+	Occupation       *string  `json:"occupation"`
+	CandidateName    *string  `json:"candidateName"`
+	CommitteeName    *string  `json:"committeeName"`
+	Amount           *float64 `json:"amount"`
+	ContributionDate *string  `json:"contributionDate"`
+	MatchScore       float64  `json:"matchScore"`
+}
+
+// Grant is a federal grant award
+type Grant struct {
+	ID             int      `json:"id"`
+	RecipientName  string   `json:"recipientName"`
+	RecipientCity  *string  `json:"recipientCity"`
+	RecipientState *string  `json:"recipientState"`
+	AwardingAgency *string  `json:"awardingAgency"`
+	FundingAgency  *string  `json:"fundingAgency"`
+	AwardAmount    *float64 `json:"awardAmount"`
+	AwardDate      *string  `json:"awardDate"`
+	Description    *string  `json:"description"`
+	CFDATitle      *string  `json:"cfdaTitle"`
+	MatchScore     float64  `json:"matchScore"`
+}
+
+// PatternSummary is a pattern finding as it appears in lists
+type PatternSummary struct {
+	ID           int      `json:"id"`
+	Title        string   `json:"title"`
+	Description  string   `json:"description"`
+	PatternType  string   `json:"patternType"`
+	Confidence   *float64 `json:"confidence"`
+	Status       string   `json:"status"`
+	DiscoveredAt string   `json:"discoveredAt"`
+}
+
+// Pattern is a single pattern finding with its evidence
+type Pattern struct {
+	ID           int      `json:"id"`
+	Title        string   `json:"title"`
+	Description  string   `json:"description"`
+	PatternType  string   `json:"patternType"`
+	EntityIDs    []int    `json:"entityIds"`
+	Evidence     Evidence `json:"evidence" doc:"What supports the finding; which fields are set depends on what found it"`
+	Confidence   *float64 `json:"confidence"`
+	Status       string   `json:"status"`
+	Notes        *string  `json:"notes"`
+	DiscoveredAt string   `json:"discoveredAt"`
+	DiscoveredBy string   `json:"discoveredBy"`
+}
+
+// Evidence is what supports a pattern finding. Each detector, the tip
+// inbox and the pattern-finder agent write their own fields; the rest are
+// left out.
+type Evidence struct {
+	Key string `json:"key,omitempty" doc:"Identifies a detector's finding across runs"`
+
+	// Temporal clusters
+	From    string          `json:"from,omitempty"`
+	To      string          `json:"to,omitempty"`
+	Ratio   float64         `json:"ratio,omitempty" doc:"The strongest burst's activity over its usual rate"`
+	Sources map[string]int  `json:"sources,omitempty" doc:"Events per source"`
+	Events  []EvidenceEvent `json:"events,omitempty"`
+
+	// Anomalies
+	Anomalies []EvidenceAnomaly `json:"anomalies,omitempty"`
+
+	// Tips
+	Source      string `json:"source,omitempty"`
+	TipID       int64  `json:"tipId,omitempty"`
+	Tip         string `json:"tip,omitempty"`
+	DocumentIDs []int  `json:"documentIds,omitempty"`
+
+	// The pattern-finder agent
+	EntityNames    []string `json:"entityNames,omitempty"`
+	EvidencePoints []string `json:"evidencePoints,omitempty"`
+}
+
+// EvidenceEvent is one event of a temporal cluster
+type EvidenceEvent struct {
+	Source    string `json:"source"`
+	ID        int64  `json:"id" doc:"Document, timeline event or FEC contribution ID"`
+	Date      string `json:"date"`
+	EntityIDs []int  `json:"entityIds"`
+}
+
+// EvidenceAnomaly is one anomaly of an entity's anomalies finding
+type EvidenceAnomaly struct {
+	Kind      string  `json:"kind"`
+	Source    string  `json:"source"`
+	RecordIDs []int   `json:"recordIds"`
+	Amount    float64 `json:"amount"`
+	Score     float64 `json:"score"`
+	Rationale string  `json:"rationale"`
+}
+
+// TagCount is a content tag with the number of documents carrying it
+type TagCount struct {
+	Tag       string `json:"tag"`
+	Documents int    `json:"documents"`
+}
+
+// ProtectedEntity is an entity and whether, when and why it was protected
+type ProtectedEntity struct {
+	ID            int        `json:"id"`
+	CanonicalName string     `json:"canonicalName"`
+	EntityType    string     `json:"entityType"`
+	Protected     bool       `json:"protected"`
+	ProtectedAt   *time.Time `json:"protectedAt"`
+	ProtectedBy   *int       `json:"protectedBy" doc:"API key that protected it"`
+	Reason        *string    `json:"reason"`
+}
+
+// Warning reports a row left out of a result because it couldn't be read
+type Warning struct {
+	Table   string `json:"table"`
+	Row     string `json:"row" doc:"The row's ID, or what identifies it, as far as it was read"`
+	Message string `json:"message"`
+}
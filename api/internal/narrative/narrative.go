@@ -0,0 +1,382 @@
+// Package narrative writes chronological accounts of entities from their
+// dated events: document mentions, dated relationships such as flights and
+// meetings, and verified public-record matches. Every sentence rests on
+// exactly one event, and sentences whose quote can't be found in that
+// event's text are dropped before the narrative is stored.
+package narrative
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/subculture-collective/epstein-db/api/internal/jobs"
+	"github.com/subculture-collective/epstein-db/api/internal/llm"
+)
+
+// JobKind is the jobs.kind for narrative jobs
+const JobKind = "narrative"
+
+// Notice accompanies every narrative served
+const Notice = "Generated by a language model from the dated documents, relationships and verified cross-references linked to this entity. Check each sentence against its cited source before relying on it."
+
+const systemPrompt = `You write chronological narratives of people and organizations that appear in documents related to the Jeffrey Epstein case.
+
+You are given numbered, dated events in date order. Write one sentence per event worth telling, in date order, each resting on exactly one event. Use only what the events say, never outside knowledge, and don't speculate about guilt or motive. Quote the exact passage of the event each sentence rests on. Skip events that add nothing.`
+
+// ErrNoEvents is returned when an entity has no dated events
+var ErrNoEvents = errors.New("narrative: entity has no dated events")
+
+// Params are the options accepted by a narrative job
+type Params struct {
+	EntityID int `json:"entityId"`
+}
+
+// Config bounds the events given to the model
+type Config struct {
+	MaxMentions  int // dated documents mentioning the entity
+	MaxRelations int // dated relationships involving the entity
+	MaxCrossrefs int
+	MaxTokens    int
+}
+
+// DefaultConfig returns the settings used by the worker
+func DefaultConfig() Config {
+	return Config{
+		MaxMentions:  60,
+		MaxRelations: 60,
+		MaxCrossrefs: 20,
+		MaxTokens:    3000,
+	}
+}
+
+// Citation ties a sentence to the event it rests on
+type Citation struct {
+	Marker     int    `json:"marker" doc:"Number of the event the model was given"`
+	Quote      string `json:"quote"`
+	DocumentID int    `json:"documentId,omitempty"`
+	DocID      string `json:"docId,omitempty"`
+	Crossref   string `json:"crossref,omitempty" enum:"ppp,fec,grants"`
+	CrossrefID int    `json:"crossrefId,omitempty"`
+}
+
+// Sentence is one step of a narrative
+type Sentence struct {
+	Text     string   `json:"text"`
+	Date     string   `json:"date" doc:"Date of the cited event, YYYY-MM-DD"`
+	Citation Citation `json:"citation"`
+}
+
+// Narrative is a stored narrative. Stale narratives predate documents or
+// relationships that now involve the entity.
+type Narrative struct {
+	EntityID    int        `json:"entityId"`
+	Sentences   []Sentence `json:"sentences"`
+	Sources     int        `json:"sources" doc:"Number of events the model was given"`
+	Model       string     `json:"model"`
+	Stale       bool       `json:"stale" doc:"New material has arrived since the narrative was generated"`
+	GeneratedAt time.Time  `json:"generatedAt"`
+}
+
+// event is one dated source shown to the model
+type event struct {
+	date       time.Time
+	text       string
+	documentID int
+	docID      string
+	crossref   string
+	crossrefID int
+}
+
+type reply struct {
+	Sentences []struct {
+		Text   string `json:"text"`
+		Source int    `json:"source"`
+		Quote  string `json:"quote"`
+	} `json:"sentences"`
+}
+
+// Worker generates narratives
+type Worker struct {
+	pool   *pgxpool.Pool
+	client llm.Client
+	cfg    Config
+}
+
+// NewWorker creates a narrative worker
+func NewWorker(pool *pgxpool.Pool, client llm.Client, cfg Config) *Worker {
+	return &Worker{pool: pool, client: client, cfg: cfg}
+}
+
+// HandleJob processes a queued narrative job
+func (w *Worker) HandleJob(ctx context.Context, job *jobs.Job) (any, error) {
+	var params Params
+	if err := job.DecodeParams(&params); err != nil {
+		return nil, err
+	}
+
+	n, err := w.Generate(ctx, params.EntityID)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]int{"entityId": n.EntityID, "sentences": len(n.Sentences)}, nil
+}
+
+// RefreshStale regenerates every stale narrative, returning how many were
+// regenerated. Entities left without dated events keep their old narrative.
+func (w *Worker) RefreshStale(ctx context.Context) (int, error) {
+	rows, err := w.pool.Query(ctx, `SELECT entity_id FROM entity_narratives WHERE stale ORDER BY entity_id`)
+	if err != nil {
+		return 0, err
+	}
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	done := 0
+	for _, id := range ids {
+		if _, err := w.Generate(ctx, id); err != nil {
+			if ctx.Err() != nil {
+				return done, ctx.Err()
+			}
+			log.Printf("narrative: entity %d: %v", id, err)
+			continue
+		}
+		done++
+	}
+	return done, nil
+}
+
+// Generate writes and stores the narrative of an entity, replacing any
+// earlier one
+func (w *Worker) Generate(ctx context.Context, entityID int) (*Narrative, error) {
+	var name, entityType string
+	err := w.pool.QueryRow(ctx, `
+		SELECT canonical_name, entity_type::text FROM entities WHERE id = $1
+	`, entityID).Scan(&name, &entityType)
+	if err != nil {
+		return nil, err
+	}
+
+	events, err := w.events(ctx, entityID)
+	if err != nil {
+		return nil, err
+	}
+	if len(events) == 0 {
+		return nil, ErrNoEvents
+	}
+
+	var prompt strings.Builder
+	fmt.Fprintf(&prompt, "Write the chronology of %s (%s).\n\nEvents:\n\n", name, entityType)
+	for i, e := range events {
+		label := "document " + e.docID
+		if e.crossref != "" {
+			label = e.crossref + " record"
+		}
+		fmt.Fprintf(&prompt, "<event n=\"%d\" date=\"%s\" from=\"%s\">\n%s\n</event>\n\n", i+1, e.date.Format(time.DateOnly), label, e.text)
+	}
+	prompt.WriteString(`Respond with a JSON object:
+{
+  "sentences": [{"text": "One sentence", "source": n, "quote": "exact passage from event n"}]
+}
+
+Return ONLY valid JSON.`)
+
+	resp, err := w.client.Complete(ctx, llm.UserPrompt(systemPrompt, prompt.String(), w.cfg.MaxTokens))
+	if err != nil {
+		return nil, err
+	}
+
+	var r reply
+	if err := llm.DecodeJSON(resp.Text, &r); err != nil {
+		return nil, err
+	}
+	n := &Narrative{
+		EntityID:  entityID,
+		Sentences: sentences(events, r),
+		Sources:   len(events),
+		Model:     resp.Model,
+	}
+	if len(n.Sentences) == 0 {
+		return nil, errors.New("narrative: no sentence matched its event")
+	}
+
+	jobID, _ := jobs.IDFromContext(ctx)
+	err = w.pool.QueryRow(ctx, `
+		INSERT INTO entity_narratives (entity_id, sentences, sources, model, job_id)
+		VALUES ($1, $2, $3, $4, NULLIF($5, 0))
+		ON CONFLICT (entity_id) DO UPDATE SET
+			sentences = EXCLUDED.sentences,
+			sources = EXCLUDED.sources,
+			model = EXCLUDED.model,
+			job_id = EXCLUDED.job_id,
+			stale = FALSE,
+			generated_at = NOW()
+		RETURNING generated_at
+	`, entityID, n.Sentences, n.Sources, n.Model, jobID).Scan(&n.GeneratedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("narrative: entity %d: %d events, %d sentences", entityID, len(events), len(n.Sentences))
+	return n, nil
+}
+
+// events gathers the entity's dated events, oldest first
+func (w *Worker) events(ctx context.Context, entityID int) ([]event, error) {
+	var events []event
+
+	// Documents mentioning the entity, told by the mention's context or the
+	// document's summary. As for biographies, only documents anyone may
+	// read, naming no protected entity, are used.
+	rows, err := w.pool.Query(ctx, `
+		SELECT d.date_earliest, d.id, d.doc_id,
+			   COALESCE(NULLIF(de.context_snippet, ''), d.summary)
+		FROM document_entities de
+		JOIN documents d ON d.id = de.document_id
+		WHERE de.entity_id = $1 AND d.date_earliest IS NOT NULL
+		  AND d.deleted_at IS NULL AND NOT dataset_restricted(d.dataset_id)
+		  AND NOT EXISTS (
+			  SELECT 1 FROM document_entities pe
+			  JOIN entities p ON p.id = pe.entity_id
+			  WHERE pe.document_id = d.id AND p.protected
+		  )
+		  AND COALESCE(NULLIF(de.context_snippet, ''), d.summary) IS NOT NULL
+		ORDER BY de.mention_count DESC, d.id
+		LIMIT $2
+	`, entityID, w.cfg.MaxMentions)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var e event
+		if err := rows.Scan(&e.date, &e.documentID, &e.docID, &e.text); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Dated relationships, e.g. flights and meetings extracted as triples
+	rows, err = w.pool.Query(ctx, `
+		SELECT t.timestamp, d.id, d.doc_id,
+			   s.canonical_name || ' ' || replace(t.predicate, '_', ' ') || ' ' || o.canonical_name ||
+			   COALESCE(' in ' || l.canonical_name, '') ||
+			   COALESCE(' (' || NULLIF(t.explicit_topic, '') || ')', '')
+		FROM triples t
+		JOIN entities s ON s.id = t.subject_id
+		JOIN entities o ON o.id = t.object_id
+		LEFT JOIN entities l ON l.id = t.location_id
+		JOIN documents d ON d.id = t.document_id
+		WHERE (t.subject_id = $1 OR t.object_id = $1) AND t.timestamp IS NOT NULL
+		  AND d.deleted_at IS NULL AND NOT dataset_restricted(d.dataset_id)
+		  AND s.deleted_at IS NULL AND NOT s.protected
+		  AND o.deleted_at IS NULL AND NOT o.protected
+		  AND (l.id IS NULL OR NOT l.protected)
+		ORDER BY t.confidence DESC, t.timestamp
+		LIMIT $2
+	`, entityID, w.cfg.MaxRelations)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var e event
+		if err := rows.Scan(&e.date, &e.documentID, &e.docID, &e.text); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Verified public-record matches
+	rows, err = w.pool.Query(ctx, `
+		SELECT m.source::text, m.source_id,
+			   COALESCE(p.date_approved, f.contribution_date, g.award_date),
+			   CASE m.source
+				   WHEN 'ppp' THEN format('PPP loan to %s (%s, %s) for $%s from %s, status %s',
+					   p.borrower_name, p.borrower_city, p.borrower_state, p.loan_amount, p.lender, p.loan_status)
+				   WHEN 'fec' THEN format('Political contribution of $%s by %s to %s for candidate %s',
+					   f.amount, f.contributor_name, f.committee_name, f.candidate_name)
+				   WHEN 'grants' THEN format('Federal grant of $%s to %s from %s: %s',
+					   g.award_amount, g.recipient_name, g.awarding_agency, g.description)
+			   END
+		FROM entity_crossref_matches m
+		LEFT JOIN ppp_loans p ON m.source = 'ppp' AND p.id = m.source_id
+		LEFT JOIN fec_contributions f ON m.source = 'fec' AND f.id = m.source_id
+		LEFT JOIN federal_grants g ON m.source = 'grants' AND g.id = m.source_id
+		WHERE m.entity_id = $1 AND m.verified AND NOT m.false_positive
+		  AND COALESCE(p.date_approved, f.contribution_date, g.award_date) IS NOT NULL
+		ORDER BY m.match_score DESC, m.id
+		LIMIT $2
+	`, entityID, w.cfg.MaxCrossrefs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var e event
+		var text *string
+		if err := rows.Scan(&e.crossref, &e.crossrefID, &e.date, &text); err != nil {
+			return nil, err
+		}
+		if text != nil {
+			e.text = *text
+			events = append(events, e)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(events, func(i, j int) bool { return events[i].date.Before(events[j].date) })
+	return events, nil
+}
+
+// sentences keeps the sentences whose quote appears verbatim in the event
+// they cite, in date order
+func sentences(events []event, r reply) []Sentence {
+	list := []Sentence{}
+	for _, s := range r.Sentences {
+		if s.Source < 1 || s.Source > len(events) {
+			continue
+		}
+		text, quote := strings.TrimSpace(s.Text), strings.TrimSpace(s.Quote)
+		e := events[s.Source-1]
+		if text == "" || quote == "" || !strings.Contains(e.text, quote) {
+			continue
+		}
+		c := Citation{Marker: s.Source, Quote: quote}
+		if e.crossref != "" {
+			c.Crossref, c.CrossrefID = e.crossref, e.crossrefID
+		} else {
+			c.DocumentID, c.DocID = e.documentID, e.docID
+		}
+		list = append(list, Sentence{Text: text, Date: e.date.Format(time.DateOnly), Citation: c})
+	}
+	sort.SliceStable(list, func(i, j int) bool { return list[i].Date < list[j].Date })
+	return list
+}
@@ -0,0 +1,193 @@
+package ocr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/subculture-collective/epstein-db/api/internal/dates"
+	"github.com/subculture-collective/epstein-db/api/internal/ingest"
+	"github.com/subculture-collective/epstein-db/api/internal/jobs"
+	"github.com/subculture-collective/epstein-db/api/internal/sensitivity"
+)
+
+// JobKind is the jobs.kind for OCR jobs
+const JobKind = "ocr"
+
+// SupportedExtensions are the file types that can be OCR'd
+var SupportedExtensions = map[string]bool{
+	".pdf": true, ".png": true, ".jpg": true, ".jpeg": true, ".tif": true, ".tiff": true,
+}
+
+// Types are the content types of the scans that can be uploaded
+var Types = []string{"application/pdf", "image/png", "image/jpeg", "image/tiff"}
+
+// Extensions maps Types to the extensions scans are saved with
+var Extensions = map[string]string{
+	"application/pdf": ".pdf",
+	"image/png":       ".png",
+	"image/jpeg":      ".jpg",
+	"image/tiff":      ".tif",
+}
+
+// Input describes a scan to OCR into a document
+type Input struct {
+	Path      string `json:"path"`
+	DocID     string `json:"docId"`
+	DatasetID int    `json:"datasetId"`
+}
+
+// Result is stored on the job when it finishes
+type Result struct {
+	DocumentID int     `json:"documentId"`
+	Pages      int     `json:"pages"`
+	Confidence float64 `json:"confidence"` // mean of page confidences
+}
+
+// UploadDir returns where uploaded scans wait for the OCR worker
+func UploadDir() string {
+	if dir := os.Getenv("OCR_UPLOAD_DIR"); dir != "" {
+		return dir
+	}
+	return filepath.Join(os.TempDir(), "epstein-ocr")
+}
+
+// Processor OCRs scans and stores them as documents
+type Processor struct {
+	pool   *pgxpool.Pool
+	engine Engine
+}
+
+// NewProcessor creates an OCR processor
+func NewProcessor(pool *pgxpool.Pool, engine Engine) *Processor {
+	return &Processor{pool: pool, engine: engine}
+}
+
+// HandleJob processes a queued OCR job
+func (p *Processor) HandleJob(ctx context.Context, job *jobs.Job) (any, error) {
+	var in Input
+	if err := job.DecodeParams(&in); err != nil {
+		return nil, err
+	}
+	return p.Process(ctx, in)
+}
+
+// Process OCRs every page of the input and upserts the document. The
+// document is left with analysis_status 'pending' so the standard
+// extraction pipeline picks it up.
+func (p *Processor) Process(ctx context.Context, in Input) (*Result, error) {
+	if in.DocID == "" || in.DatasetID <= 0 {
+		return nil, errors.New("ocr: docId and datasetId are required")
+	}
+
+	ext := strings.ToLower(filepath.Ext(in.Path))
+	if !SupportedExtensions[ext] {
+		return nil, fmt.Errorf("ocr: unsupported file type %q", ext)
+	}
+
+	images := []string{in.Path}
+	if ext == ".pdf" {
+		dir, err := os.MkdirTemp("", "ocr-pages-")
+		if err != nil {
+			return nil, err
+		}
+		defer os.RemoveAll(dir)
+
+		if images, err = rasterize(ctx, in.Path, dir); err != nil {
+			return nil, err
+		}
+	}
+
+	pages := make([]Page, 0, len(images))
+	for i, img := range images {
+		page, err := p.engine.Recognize(ctx, img)
+		if err != nil {
+			return nil, fmt.Errorf("page %d: %w", i+1, err)
+		}
+		page.Number = i + 1
+		pages = append(pages, page)
+	}
+
+	return p.store(ctx, in, pages)
+}
+
+func (p *Processor) store(ctx context.Context, in Input, pages []Page) (*Result, error) {
+	result := &Result{Pages: len(pages)}
+
+	texts := make([]string, len(pages))
+	for i, page := range pages {
+		texts[i] = page.Text
+		result.Confidence += page.Confidence
+	}
+	if len(pages) > 0 {
+		result.Confidence /= float64(len(pages))
+	}
+
+	source, err := ingest.RegisterSource(ctx, p.pool, in.Path, in.DatasetID)
+	if err != nil {
+		return nil, err
+	}
+	if err := source.HashFile(in.Path); err != nil {
+		return nil, err
+	}
+
+	engine := p.engine.Name()
+	err = pgx.BeginFunc(ctx, p.pool, func(tx pgx.Tx) error {
+		err := tx.QueryRow(ctx, `
+			INSERT INTO documents
+				(doc_id, dataset_id, file_path, full_text, page_count, analysis_status, source_file_id)
+			VALUES ($1, $2, $3, $4, $5, 'pending', $6)
+			ON CONFLICT (doc_id) DO UPDATE SET
+				full_text = EXCLUDED.full_text,
+				page_count = EXCLUDED.page_count,
+				file_path = EXCLUDED.file_path,
+				source_file_id = EXCLUDED.source_file_id,
+				source_line_start = NULL,
+				source_line_end = NULL,
+				analysis_status = 'pending',
+				updated_at = NOW()
+			RETURNING id
+		`, in.DocID, in.DatasetID, in.Path, strings.Join(texts, "\n\f\n"), len(pages), source.ID).Scan(&result.DocumentID)
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec(ctx, `DELETE FROM document_pages WHERE document_id = $1`, result.DocumentID); err != nil {
+			return err
+		}
+
+		for _, page := range pages {
+			if _, err := tx.Exec(ctx, `
+				INSERT INTO document_pages (document_id, page_number, text, confidence, source, engine)
+				VALUES ($1, $2, $3, $4, 'ocr', $5)
+			`, result.DocumentID, page.Number, page.Text, page.Confidence, engine); err != nil {
+				return err
+			}
+		}
+
+		text := strings.Join(texts, "\n")
+		if _, err := dates.Update(ctx, tx, result.DocumentID, text); err != nil {
+			return err
+		}
+		_, err = sensitivity.Update(ctx, tx, result.DocumentID, text)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	source.Produced(in.DocID)
+	if err := source.Finish(ctx, p.pool); err != nil {
+		return nil, err
+	}
+
+	log.Printf("ocr: %s: %d pages, mean confidence %.1f", in.DocID, result.Pages, result.Confidence)
+	return result, nil
+}
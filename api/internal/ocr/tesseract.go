@@ -0,0 +1,175 @@
+package ocr
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Page is the recognized text of one page image
+type Page struct {
+	Number     int
+	Text       string
+	Confidence float64 // mean word confidence, 0-100
+}
+
+// Engine recognizes text in a page image
+type Engine interface {
+	Recognize(ctx context.Context, imagePath string) (Page, error)
+	Name() string
+}
+
+// Tesseract runs the tesseract CLI
+type Tesseract struct {
+	Binary   string
+	Language string
+}
+
+// NewTesseractFromEnv reads TESSERACT_PATH and OCR_LANG
+func NewTesseractFromEnv() *Tesseract {
+	t := &Tesseract{Binary: "tesseract", Language: "eng"}
+	if p := os.Getenv("TESSERACT_PATH"); p != "" {
+		t.Binary = p
+	}
+	if lang := os.Getenv("OCR_LANG"); lang != "" {
+		t.Language = lang
+	}
+	return t
+}
+
+// Name identifies the engine and its version in stored pages
+func (t *Tesseract) Name() string {
+	out, err := exec.Command(t.Binary, "--version").CombinedOutput()
+	if err != nil {
+		return "tesseract"
+	}
+	line, _, _ := strings.Cut(string(out), "\n")
+	return strings.TrimSpace(line)
+}
+
+// Recognize runs tesseract in TSV mode so that word confidences are available
+func (t *Tesseract) Recognize(ctx context.Context, imagePath string) (Page, error) {
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, t.Binary, imagePath, "stdout", "-l", t.Language, "tsv")
+	cmd.Stderr = &stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		return Page{}, fmt.Errorf("tesseract: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	return parseTSV(out)
+}
+
+type word struct {
+	block, par, line, num int
+	text                  string
+	conf                  float64
+}
+
+// parseTSV rebuilds page text from tesseract's word-level TSV output,
+// keeping line breaks and blank lines between paragraphs
+func parseTSV(tsv []byte) (Page, error) {
+	var words []word
+
+	scanner := bufio.NewScanner(bytes.NewReader(tsv))
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	header := true
+	for scanner.Scan() {
+		if header {
+			header = false
+			continue
+		}
+
+		// level page block par line word left top width height conf text
+		cols := strings.SplitN(scanner.Text(), "\t", 12)
+		if len(cols) < 12 || cols[0] != "5" {
+			continue
+		}
+		conf, err := strconv.ParseFloat(cols[10], 64)
+		if err != nil || conf < 0 || strings.TrimSpace(cols[11]) == "" {
+			continue
+		}
+
+		w := word{text: cols[11], conf: conf}
+		w.block, _ = strconv.Atoi(cols[2])
+		w.par, _ = strconv.Atoi(cols[3])
+		w.line, _ = strconv.Atoi(cols[4])
+		w.num, _ = strconv.Atoi(cols[5])
+		words = append(words, w)
+	}
+	if err := scanner.Err(); err != nil {
+		return Page{}, err
+	}
+
+	sort.SliceStable(words, func(i, j int) bool {
+		a, b := words[i], words[j]
+		if a.block != b.block {
+			return a.block < b.block
+		}
+		if a.par != b.par {
+			return a.par < b.par
+		}
+		if a.line != b.line {
+			return a.line < b.line
+		}
+		return a.num < b.num
+	})
+
+	var sb strings.Builder
+	var total float64
+	for i, w := range words {
+		if i > 0 {
+			prev := words[i-1]
+			switch {
+			case prev.block != w.block || prev.par != w.par:
+				sb.WriteString("\n\n")
+			case prev.line != w.line:
+				sb.WriteString("\n")
+			default:
+				sb.WriteString(" ")
+			}
+		}
+		sb.WriteString(w.text)
+		total += w.conf
+	}
+
+	page := Page{Text: sb.String()}
+	if len(words) > 0 {
+		page.Confidence = total / float64(len(words))
+	}
+	return page, nil
+}
+
+// rasterize renders each PDF page to a PNG with pdftoppm and returns the
+// image paths in page order
+func rasterize(ctx context.Context, pdfPath, dir string) ([]string, error) {
+	binary := os.Getenv("PDFTOPPM_PATH")
+	if binary == "" {
+		binary = "pdftoppm"
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, binary, "-r", "300", "-png", pdfPath, filepath.Join(dir, "page"))
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("pdftoppm: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	images, err := filepath.Glob(filepath.Join(dir, "page-*.png"))
+	if err != nil {
+		return nil, err
+	}
+
+	// pdftoppm zero-pads page numbers to a common width, so lexical order
+	// is page order
+	sort.Strings(images)
+	return images, nil
+}
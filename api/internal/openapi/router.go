@@ -0,0 +1,87 @@
+package openapi
+
+import (
+	"github.com/gofiber/fiber/v2"
+)
+
+// Router registers Fiber routes and documents them in the same call
+type Router struct {
+	router fiber.Router
+	spec   *Spec
+	prefix string
+}
+
+// NewRouter wraps r, whose routes live under prefix
+func NewRouter(r fiber.Router, spec *Spec, prefix string) *Router {
+	return &Router{router: r, spec: spec, prefix: prefix}
+}
+
+// Group creates a documented sub-router
+func (r *Router) Group(prefix string, handlers ...fiber.Handler) *Router {
+	return &Router{
+		router: r.router.Group(prefix, handlers...),
+		spec:   r.spec,
+		prefix: r.prefix + prefix,
+	}
+}
+
+// Get registers a GET route
+func (r *Router) Get(path string, op Operation, handlers ...fiber.Handler) {
+	r.add(fiber.MethodGet, path, op, handlers)
+}
+
+// Post registers a POST route
+func (r *Router) Post(path string, op Operation, handlers ...fiber.Handler) {
+	r.add(fiber.MethodPost, path, op, handlers)
+}
+
+// Put registers a PUT route
+func (r *Router) Put(path string, op Operation, handlers ...fiber.Handler) {
+	r.add(fiber.MethodPut, path, op, handlers)
+}
+
+// Delete registers a DELETE route
+func (r *Router) Delete(path string, op Operation, handlers ...fiber.Handler) {
+	r.add(fiber.MethodDelete, path, op, handlers)
+}
+
+func (r *Router) add(method, path string, op Operation, handlers []fiber.Handler) {
+	r.router.Add(method, path, handlers...)
+	r.spec.Add(method, r.prefix+path, op)
+}
+
+// Handler serves the rendered spec
+func (s *Spec) Handler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		body, err := s.JSON()
+		if err != nil {
+			return err
+		}
+		c.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+		return c.Send(body)
+	}
+}
+
+// DocsHandler serves Swagger UI pointed at specURL
+func DocsHandler(specURL string) fiber.Handler {
+	page := `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>Epstein Files API</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js"></script>
+  <script>
+    SwaggerUIBundle({ url: "` + specURL + `", dom_id: "#swagger-ui" });
+  </script>
+</body>
+</html>`
+
+	return func(c *fiber.Ctx) error {
+		c.Set(fiber.HeaderContentType, fiber.MIMETextHTMLCharsetUTF8)
+		return c.SendString(page)
+	}
+}
@@ -0,0 +1,244 @@
+// Package openapi builds an OpenAPI 3 description of the API from the
+// routes as they are registered, so the spec can't drift from the handlers.
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/subculture-collective/epstein-db/api/internal/apierr"
+)
+
+// Param describes a path or query parameter
+type Param struct {
+	Name        string
+	In          string // "path" or "query", defaults to "query"
+	Type        string // "string", "integer", "number" or "boolean", defaults to "string"
+	Description string
+	Required    bool
+	Default     any
+	Enum        []string
+}
+
+// Operation documents a single route
+type Operation struct {
+	Summary     string
+	Description string
+	Tag         string
+	Params      []Param
+	Body        any     // zero value of the JSON request body type
+	Form        []Param // multipart form fields, for uploads
+	Status      int     // success status, defaults to 200
+	Response    any     // zero value of the success response type
+	ContentType string  // success content type, defaults to application/json
+	CSV         bool    // the response can also be negotiated as text/csv
+}
+
+type route struct {
+	method string
+	path   string
+	op     Operation
+}
+
+// Spec collects operations and renders them as an OpenAPI document
+type Spec struct {
+	title   string
+	version string
+
+	mu         sync.Mutex
+	routes     []route
+	components map[string]*Schema
+}
+
+// New creates an empty spec
+func New(title, version string) *Spec {
+	return &Spec{title: title, version: version}
+}
+
+// Add documents a route. Fiber-style :params in path become {params}.
+func (s *Spec) Add(method, path string, op Operation) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.routes = append(s.routes, route{method: strings.ToLower(method), path: path, op: op})
+}
+
+var pathParam = regexp.MustCompile(`:(\w+)`)
+
+// JSON renders the OpenAPI document
+func (s *Spec) JSON() ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.components = map[string]*Schema{}
+	errorRef := s.schemaFor(reflect.TypeOf(apierr.ErrorResponse{}))
+
+	paths := map[string]map[string]any{}
+	for _, r := range s.routes {
+		path := pathParam.ReplaceAllString(r.path, "{$1}")
+		if paths[path] == nil {
+			paths[path] = map[string]any{}
+		}
+		paths[path][r.method] = s.operation(r, errorRef)
+	}
+
+	return json.MarshalIndent(map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":   s.title,
+			"version": s.version,
+		},
+		"paths": paths,
+		"components": map[string]any{
+			"schemas": s.components,
+			"securitySchemes": map[string]any{
+				"apiKey": map[string]any{"type": "apiKey", "in": "header", "name": "X-API-Key"},
+			},
+		},
+		// Keys are optional for public routes
+		"security": []map[string][]string{{}, {"apiKey": {}}},
+	}, "", "  ")
+}
+
+func (s *Spec) operation(r route, errorRef *Schema) map[string]any {
+	op := r.op
+
+	var params []map[string]any
+	declared := map[string]bool{}
+	for _, p := range op.Params {
+		declared[p.Name] = true
+		params = append(params, parameter(p))
+	}
+	// Path parameters are always required; default them to integer IDs
+	for _, m := range pathParam.FindAllStringSubmatch(r.path, -1) {
+		if !declared[m[1]] {
+			params = append(params, parameter(Param{Name: m[1], In: "path", Type: "integer"}))
+		}
+	}
+
+	status := op.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	contentType := op.ContentType
+	if contentType == "" {
+		contentType = "application/json"
+	}
+
+	success := map[string]any{"description": http.StatusText(status)}
+	if op.Response != nil {
+		success["content"] = map[string]any{
+			contentType: map[string]any{"schema": s.schemaFor(reflect.TypeOf(op.Response))},
+		}
+	} else if contentType != "application/json" {
+		success["content"] = map[string]any{
+			contentType: map[string]any{"schema": &Schema{Type: "string"}},
+		}
+	}
+	if op.CSV {
+		success["content"].(map[string]any)["text/csv"] = map[string]any{"schema": &Schema{Type: "string"}}
+	}
+
+	errorResponse := map[string]any{
+		"description": "Error",
+		"content": map[string]any{
+			"application/json": map[string]any{"schema": errorRef},
+		},
+	}
+
+	out := map[string]any{
+		"summary":     op.Summary,
+		"operationId": operationID(r.method, r.path),
+		"responses": map[string]any{
+			strconv.Itoa(status): success,
+			"default":            errorResponse,
+		},
+	}
+	if op.Description != "" {
+		out["description"] = op.Description
+	}
+	if op.Tag != "" {
+		out["tags"] = []string{op.Tag}
+	}
+	if len(params) > 0 {
+		out["parameters"] = params
+	}
+
+	switch {
+	case op.Body != nil:
+		out["requestBody"] = map[string]any{
+			"content": map[string]any{
+				"application/json": map[string]any{"schema": s.schemaFor(reflect.TypeOf(op.Body))},
+			},
+		}
+	case len(op.Form) > 0:
+		form := &Schema{Type: "object", Properties: map[string]*Schema{}}
+		for _, f := range op.Form {
+			form.Properties[f.Name] = paramSchema(f)
+			if f.Required {
+				form.Required = append(form.Required, f.Name)
+			}
+		}
+		sort.Strings(form.Required)
+		out["requestBody"] = map[string]any{
+			"required": true,
+			"content": map[string]any{
+				"multipart/form-data": map[string]any{"schema": form},
+			},
+		}
+	}
+
+	return out
+}
+
+func parameter(p Param) map[string]any {
+	in := p.In
+	if in == "" {
+		in = "query"
+	}
+	out := map[string]any{
+		"name":   p.Name,
+		"in":     in,
+		"schema": paramSchema(p),
+	}
+	if p.Required || in == "path" {
+		out["required"] = true
+	}
+	if p.Description != "" {
+		out["description"] = p.Description
+	}
+	return out
+}
+
+func paramSchema(p Param) *Schema {
+	typ := p.Type
+	if typ == "" {
+		typ = "string"
+	}
+	schema := &Schema{Type: typ, Enum: p.Enum}
+	if typ == "file" {
+		schema = &Schema{Type: "string", Format: "binary"}
+	}
+	if p.Default != nil {
+		schema.Default = p.Default
+	}
+	return schema
+}
+
+// operationID derives a stable identifier such as getEntitiesIdConnections
+func operationID(method, path string) string {
+	var b strings.Builder
+	b.WriteString(method)
+	for _, part := range strings.FieldsFunc(path, func(r rune) bool { return r == '/' || r == ':' || r == '-' }) {
+		if part == "api" {
+			continue
+		}
+		b.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+	return b.String()
+}
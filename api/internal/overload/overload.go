@@ -0,0 +1,119 @@
+// Package overload keeps the API predictable when the database is
+// saturated. Requests are admitted up to the connection pool's size and the
+// rest queue for at most a short wait, so a backlog turns into quick 503s
+// with Retry-After rather than handlers blocking on the pool until they
+// time out. Once a queued request gives up, the breaker opens for a
+// cool-down, during which requests that would have to queue are turned away
+// at once.
+package overload
+
+import (
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/subculture-collective/epstein-db/api/internal/apierr"
+)
+
+// Config sizes a Gate
+type Config struct {
+	Capacity int           // requests served at once, normally the pool's MaxConns
+	Wait     time.Duration // longest a request queues for a slot
+	Cooldown time.Duration // how long the breaker stays open
+}
+
+// Gate admits requests up to its capacity
+type Gate struct {
+	cfg       Config
+	slots     chan struct{}
+	waiting   atomic.Int64
+	rejected  atomic.Int64
+	openUntil atomic.Int64 // unix nanoseconds
+}
+
+// New returns a gate for cfg. Capacity must be positive.
+func New(cfg Config) *Gate {
+	return &Gate{cfg: cfg, slots: make(chan struct{}, cfg.Capacity)}
+}
+
+// InUse is how many requests hold a slot
+func (g *Gate) InUse() int {
+	return len(g.slots)
+}
+
+// Capacity is how many requests can hold a slot at once
+func (g *Gate) Capacity() int {
+	return cap(g.slots)
+}
+
+// Waiting is how many requests are queued for a slot
+func (g *Gate) Waiting() int {
+	return int(g.waiting.Load())
+}
+
+// Rejected counts the requests turned away since the gate was made
+func (g *Gate) Rejected() int64 {
+	return g.rejected.Load()
+}
+
+// Open reports whether the breaker is open
+func (g *Gate) Open() bool {
+	return time.Now().UnixNano() < g.openUntil.Load()
+}
+
+// Middleware holds a slot for the rest of each request, except under the
+// skipped path prefixes: streams, which would hold one indefinitely, and
+// anything that re-enters the app and is gated there
+func (g *Gate) Middleware(skip ...string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		for _, prefix := range skip {
+			if strings.HasPrefix(c.Path(), prefix) {
+				return c.Next()
+			}
+		}
+
+		if !g.acquire(c) {
+			g.rejected.Add(1)
+			c.Set(fiber.HeaderRetryAfter, strconv.Itoa(g.retryAfter()))
+			return apierr.New(fiber.StatusServiceUnavailable, apierr.CodeUnavailable, "the database is busy; retry shortly")
+		}
+		defer func() { <-g.slots }()
+		return c.Next()
+	}
+}
+
+// acquire takes a slot, queueing for one unless the breaker is open
+func (g *Gate) acquire(c *fiber.Ctx) bool {
+	select {
+	case g.slots <- struct{}{}:
+		return true
+	default:
+	}
+	if g.Open() {
+		return false
+	}
+
+	g.waiting.Add(1)
+	defer g.waiting.Add(-1)
+	timer := time.NewTimer(g.cfg.Wait)
+	defer timer.Stop()
+
+	select {
+	case g.slots <- struct{}{}:
+		return true
+	case <-timer.C:
+		g.openUntil.Store(time.Now().Add(g.cfg.Cooldown).UnixNano())
+		return false
+	case <-c.UserContext().Done():
+		return false
+	}
+}
+
+// retryAfter is the whole seconds until the breaker closes, at least one
+func (g *Gate) retryAfter() int {
+	secs := int(time.Until(time.Unix(0, g.openUntil.Load())).Seconds() + 0.5)
+	return max(secs, 1)
+}
@@ -0,0 +1,97 @@
+// Package pages indexes which pages of a document name each entity the
+// document mentions. Extraction links entities to whole documents; this
+// narrows the links to pages by matching entity names in the page text, so
+// co-occurrence can be measured page by page.
+package pages
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Result summarizes a run
+type Result struct {
+	Documents  int `json:"documents" doc:"Documents indexed"`
+	Mentions   int `json:"mentions" doc:"Page mentions found"`
+	DurationMs int `json:"durationMs"`
+}
+
+// staleSQL finds the documents whose pages or mentions may have changed
+// since they were last indexed, or every document with pages if $1
+const staleSQL = `
+	SELECT p.document_id
+	FROM document_pages p
+	JOIN documents d ON d.id = p.document_id
+	LEFT JOIN (
+		SELECT document_id, MAX(indexed_at) AS indexed_at
+		FROM page_entities
+		GROUP BY document_id
+	) pe ON pe.document_id = p.document_id
+	GROUP BY p.document_id
+	HAVING $1 OR GREATEST(MAX(p.created_at), MAX(d.updated_at)) > COALESCE(MAX(pe.indexed_at), '-infinity')
+	ORDER BY p.document_id
+`
+
+// indexSQL counts the occurrences of each mentioned entity's canonical name
+// and aliases on each page of documents $1, keeping the most frequent name
+// so an alias inside the canonical name isn't counted twice. Names under
+// three characters match too much to count.
+const indexSQL = `
+	INSERT INTO page_entities (document_id, page_number, entity_id, mention_count)
+	SELECT p.document_id, p.page_number, de.entity_id, MAX(o.n)
+	FROM document_pages p
+	JOIN document_entities de ON de.document_id = p.document_id
+	JOIN entities e ON e.id = de.entity_id
+	CROSS JOIN LATERAL (
+		SELECT DISTINCT lower(trim(n)) AS name
+		FROM unnest(ARRAY[e.canonical_name] || COALESCE((SELECT array_agg(a) FROM jsonb_array_elements_text(e.aliases) a), '{}')) n
+		WHERE length(trim(n)) >= 3
+	) names
+	CROSS JOIN LATERAL (
+		SELECT (length(lower(p.text)) - length(replace(lower(p.text), names.name, ''))) / length(names.name) AS n
+	) o
+	WHERE p.document_id = ANY($1) AND o.n > 0
+	GROUP BY p.document_id, p.page_number, de.entity_id
+`
+
+// batchSize bounds the documents indexed per transaction
+const batchSize = 500
+
+// Run indexes the documents whose pages or mentions changed since they were
+// last indexed, or every document with pages when force is set. A document
+// without a single page mention is indexed again on every run.
+func Run(ctx context.Context, pool *pgxpool.Pool, force bool) (*Result, error) {
+	started := time.Now()
+	result := &Result{}
+
+	rows, err := pool.Query(ctx, staleSQL, force)
+	if err != nil {
+		return nil, err
+	}
+	ids, err := pgx.CollectRows(rows, pgx.RowTo[int])
+	if err != nil {
+		return nil, err
+	}
+
+	for start := 0; start < len(ids); start += batchSize {
+		batch := ids[start:min(start+batchSize, len(ids))]
+		err := pgx.BeginFunc(ctx, pool, func(tx pgx.Tx) error {
+			if _, err := tx.Exec(ctx, `DELETE FROM page_entities WHERE document_id = ANY($1)`, batch); err != nil {
+				return err
+			}
+			tag, err := tx.Exec(ctx, indexSQL, batch)
+			result.Mentions += int(tag.RowsAffected())
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		result.Documents += len(batch)
+	}
+
+	result.DurationMs = int(time.Since(started).Milliseconds())
+	return result, nil
+}
@@ -0,0 +1,149 @@
+// Package patterns detects patterns in the corpus and records them as
+// pattern findings for researchers to validate or reject. Each detector
+// has settings an admin can tune, stored in pattern_settings.
+package patterns
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// JobKind is the jobs.kind for on-demand detector runs
+const JobKind = "patterns"
+
+// SettingsError is a setting out of range
+type SettingsError struct {
+	Field   string
+	Message string
+}
+
+func (e *SettingsError) Error() string {
+	return e.Field + " " + e.Message
+}
+
+// loadSettings reads a detector's stored settings over v, which holds the
+// defaults; a detector never tuned keeps them
+func loadSettings(ctx context.Context, pool *pgxpool.Pool, detector string, v any) error {
+	var raw []byte
+	err := pool.QueryRow(ctx, `SELECT settings FROM pattern_settings WHERE detector = $1`, detector).Scan(&raw)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, v)
+}
+
+// saveSettings stores a detector's settings
+func saveSettings(ctx context.Context, pool *pgxpool.Pool, detector string, v any, keyID int) error {
+	_, err := pool.Exec(ctx, `
+		INSERT INTO pattern_settings (detector, settings, updated_by)
+		VALUES ($1, $2, NULLIF($3, 0))
+		ON CONFLICT (detector) DO UPDATE
+		SET settings = EXCLUDED.settings, updated_at = NOW(), updated_by = EXCLUDED.updated_by
+	`, detector, v, keyID)
+	return err
+}
+
+// Finding is a pattern a detector found. Key tells it apart from the
+// detector's other findings across runs.
+type Finding struct {
+	Key         string
+	Title       string
+	Description string
+	EntityIDs   []int
+	Evidence    any // stored with the key added
+	Confidence  float64
+}
+
+// Record stores a detector's findings as pattern findings of its type.
+// Unreviewed findings of earlier runs are updated when found again and
+// removed otherwise; validated and rejected findings are left as they are.
+// It returns how many were removed.
+func Record(ctx context.Context, pool *pgxpool.Pool, detector string, findings []Finding) (int, error) {
+	var removed int
+	err := pgx.BeginFunc(ctx, pool, func(tx pgx.Tx) error {
+		// Status of earlier runs' findings, by key
+		status := map[string]string{}
+		rows, err := tx.Query(ctx, `
+			SELECT evidence->>'key', COALESCE(status, 'hypothesis')
+			FROM pattern_findings
+			WHERE pattern_type = $1 AND discovered_by = $1
+		`, detector)
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			var key, s string
+			if err := rows.Scan(&key, &s); err != nil {
+				rows.Close()
+				return err
+			}
+			status[key] = s
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		keys := make([]string, 0, len(findings))
+		for _, f := range findings {
+			keys = append(keys, f.Key)
+			switch s, ok := status[f.Key]; {
+			case ok && s != "hypothesis":
+				continue
+			case ok:
+				_, err = tx.Exec(ctx, `
+					UPDATE pattern_findings
+					SET title = $3, description = $4, entity_ids = $5,
+						evidence = $6::jsonb || jsonb_build_object('key', $2::text), confidence = $7
+					WHERE pattern_type = $1 AND discovered_by = $1 AND evidence->>'key' = $2
+				`, detector, f.Key, f.Title, f.Description, f.EntityIDs, f.Evidence, f.Confidence)
+			default:
+				_, err = tx.Exec(ctx, `
+					INSERT INTO pattern_findings
+						(title, description, pattern_type, entity_ids, evidence, confidence, discovered_by)
+					VALUES ($3, $4, $1, $5, $6::jsonb || jsonb_build_object('key', $2::text), $7, $1)
+				`, detector, f.Key, f.Title, f.Description, f.EntityIDs, f.Evidence, f.Confidence)
+			}
+			if err != nil {
+				return err
+			}
+		}
+
+		tag, err := tx.Exec(ctx, `
+			DELETE FROM pattern_findings
+			WHERE pattern_type = $1 AND discovered_by = $1
+			  AND COALESCE(status, 'hypothesis') = 'hypothesis'
+			  AND NOT (evidence->>'key' = ANY($2))
+		`, detector, keys)
+		removed = int(tag.RowsAffected())
+		return err
+	})
+	return removed, err
+}
+
+// EntityNames returns the canonical names of entities by ID
+func EntityNames(ctx context.Context, pool *pgxpool.Pool, ids []int) (map[int]string, error) {
+	rows, err := pool.Query(ctx, `SELECT id, canonical_name FROM entities WHERE id = ANY($1)`, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	names := map[int]string{}
+	for rows.Next() {
+		var id int
+		var name string
+		if err := rows.Scan(&id, &name); err != nil {
+			return nil, err
+		}
+		names[id] = name
+	}
+	return names, rows.Err()
+}
@@ -0,0 +1,481 @@
+package patterns
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// The temporal cluster detector looks for bursts: short windows in which
+// entities that appear together in the corpus are all far more active than
+// usual, across documents, flights and political contributions. Pairs of
+// entities named together in enough events are compared; each pair's busiest
+// windows are kept when they hold enough events from enough sources at
+// MinRatio times the pair's usual rate. Overlapping bursts sharing an entity
+// are merged into one cluster, recorded as a pattern finding.
+
+// DetectorTemporal names the temporal cluster detector. It is also the
+// pattern_type and discovered_by of its findings.
+const DetectorTemporal = "temporal_cluster"
+
+// Event sources
+const (
+	SourceDocument     = "document"
+	SourceFlight       = "flight"
+	SourceContribution = "contribution"
+)
+
+// TemporalSettings tune the temporal cluster detector
+type TemporalSettings struct {
+	WindowDays         int     `json:"windowDays" doc:"Longest a burst may last, in days"`
+	MinEvents          int     `json:"minEvents" doc:"Fewest events involving the entities in a burst"`
+	MinSources         int     `json:"minSources" doc:"Fewest sources (document, flight, contribution) among those events"`
+	MinJointEvents     int     `json:"minJointEvents" doc:"Fewest events naming two entities together, over all time, before their activity is compared"`
+	MinRatio           float64 `json:"minRatio" doc:"How many times their usual rate the entities must be active in a burst"`
+	MaxEventEntities   int     `json:"maxEventEntities" doc:"Events naming more people and organizations than this are ignored"`
+	MaxClusterEntities int     `json:"maxClusterEntities" doc:"Most entities in one cluster; overlapping bursts are not merged beyond it"`
+}
+
+// DefaultTemporal are the settings the detector runs with until an admin
+// changes them
+var DefaultTemporal = TemporalSettings{
+	WindowDays:         14,
+	MinEvents:          4,
+	MinSources:         2,
+	MinJointEvents:     2,
+	MinRatio:           3,
+	MaxEventEntities:   20,
+	MaxClusterEntities: 10,
+}
+
+// Validate checks each setting is in range
+func (s TemporalSettings) Validate() error {
+	ints := []struct {
+		field    string
+		v        int
+		min, max int
+	}{
+		{"windowDays", s.WindowDays, 1, 366},
+		{"minEvents", s.MinEvents, 2, 1000},
+		{"minSources", s.MinSources, 1, 3},
+		{"minJointEvents", s.MinJointEvents, 1, 1000},
+		{"maxEventEntities", s.MaxEventEntities, 2, 1000},
+		{"maxClusterEntities", s.MaxClusterEntities, 2, 100},
+	}
+	for _, i := range ints {
+		if i.v < i.min || i.v > i.max {
+			return &SettingsError{i.field, fmt.Sprintf("must be between %d and %d", i.min, i.max)}
+		}
+	}
+	if s.MinRatio < 1 || s.MinRatio > 1000 {
+		return &SettingsError{"minRatio", "must be between 1 and 1000"}
+	}
+	return nil
+}
+
+// LoadTemporal returns the temporal cluster detector's settings
+func LoadTemporal(ctx context.Context, pool *pgxpool.Pool) (TemporalSettings, error) {
+	s := DefaultTemporal
+	err := loadSettings(ctx, pool, DetectorTemporal, &s)
+	return s, err
+}
+
+// SaveTemporal validates and stores the temporal cluster detector's
+// settings, which the next run uses
+func SaveTemporal(ctx context.Context, pool *pgxpool.Pool, s TemporalSettings, keyID int) error {
+	if err := s.Validate(); err != nil {
+		return err
+	}
+	return saveSettings(ctx, pool, DetectorTemporal, s, keyID)
+}
+
+// Result summarizes a run
+type Result struct {
+	Events     int `json:"events" doc:"Dated events read"`
+	Pairs      int `json:"pairs" doc:"Pairs of entities compared"`
+	Bursts     int `json:"bursts" doc:"Bursts found across those pairs"`
+	Findings   int `json:"findings" doc:"Clusters recorded as pattern findings"`
+	Removed    int `json:"removed" doc:"Unreviewed findings of earlier runs no longer found"`
+	DurationMs int `json:"durationMs"`
+}
+
+// Evidence is the evidence of a temporal cluster finding
+type Evidence struct {
+	From    string          `json:"from"`
+	To      string          `json:"to"`
+	Ratio   float64         `json:"ratio" doc:"The strongest burst's activity over its usual rate"`
+	Sources map[string]int  `json:"sources" doc:"Events per source"`
+	Events  []EvidenceEvent `json:"events"`
+}
+
+// EvidenceEvent is one event of a cluster
+type EvidenceEvent struct {
+	Source    string `json:"source"`
+	ID        int64  `json:"id" doc:"Document, timeline event or FEC contribution ID"`
+	Date      string `json:"date"`
+	EntityIDs []int  `json:"entityIds"`
+}
+
+// maxEvidenceEvents bounds the events listed in a finding's evidence
+const maxEvidenceEvents = 200
+
+// eventsSQL reads the dated events naming people and organizations, oldest
+// first. Documents only count when dated to within the window.
+const eventsSQL = `
+	SELECT 'document', d.id::bigint, d.date_earliest, array_agg(DISTINCT de.entity_id)
+	FROM documents d
+	JOIN document_entities de ON de.document_id = d.id
+	JOIN entities e ON e.id = de.entity_id AND e.entity_type IN ('person', 'organization')
+	WHERE d.date_earliest IS NOT NULL
+	  AND COALESCE(d.date_latest, d.date_earliest) - d.date_earliest < $1
+	GROUP BY d.id
+	HAVING COUNT(DISTINCT de.entity_id) <= $2
+	UNION ALL
+	SELECT 'flight', t.id, t.event_date, array_agg(te.entity_id)
+	FROM timeline_events t
+	JOIN timeline_event_entities te ON te.event_id = t.id
+	WHERE t.event_type = 'flight' AND NOT t.hidden
+	GROUP BY t.id
+	HAVING COUNT(*) <= $2
+	UNION ALL
+	SELECT 'contribution', f.id::bigint, f.contribution_date, array_agg(DISTINCT m.entity_id)
+	FROM fec_contributions f
+	JOIN entity_crossref_matches m
+	  ON m.source = 'fec' AND m.source_id = f.id AND m.false_positive IS NOT TRUE
+	WHERE f.contribution_date IS NOT NULL
+	GROUP BY f.id
+	HAVING COUNT(DISTINCT m.entity_id) <= $2
+	ORDER BY 3
+`
+
+// event is a dated event; day counts days since the Unix epoch
+type event struct {
+	source   string
+	id       int64
+	day      int
+	entities []int
+}
+
+// burst is a window in which a group of entities is unusually active
+type burst struct {
+	entities   []int // sorted
+	start, end int   // days
+	events     []int // indices into the events
+	ratio      float64
+}
+
+// Run runs the temporal cluster detector with its stored settings. Findings
+// are matched to earlier runs' by their entities and window: unreviewed ones
+// are updated or, when no longer found, removed; validated and rejected
+// findings are left as they are.
+func Run(ctx context.Context, pool *pgxpool.Pool) (*Result, error) {
+	started := time.Now()
+	settings, err := LoadTemporal(ctx, pool)
+	if err != nil {
+		return nil, err
+	}
+	result := &Result{}
+
+	rows, err := pool.Query(ctx, eventsSQL, settings.WindowDays, settings.MaxEventEntities)
+	if err != nil {
+		return nil, err
+	}
+	var events []event
+	for rows.Next() {
+		var e event
+		var date time.Time
+		if err := rows.Scan(&e.source, &e.id, &date, &e.entities); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		e.day = int(date.Unix() / 86400)
+		events = append(events, e)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	result.Events = len(events)
+
+	pairs := jointPairs(events, settings.MinJointEvents)
+	result.Pairs = len(pairs)
+	var bursts []burst
+	for _, p := range pairs {
+		bursts = append(bursts, pairBursts(events, p, settings)...)
+	}
+	result.Bursts = len(bursts)
+	clusters := mergeBursts(bursts, settings.MaxClusterEntities)
+
+	removed, err := saveClusters(ctx, pool, events, clusters)
+	if err != nil {
+		return nil, err
+	}
+	result.Findings = len(clusters)
+	result.Removed = removed
+	result.DurationMs = int(time.Since(started).Milliseconds())
+	return result, nil
+}
+
+// pair is two entities, the lower ID first, with each one's events
+type pair struct {
+	a, b     int
+	aEv, bEv []int
+}
+
+// jointPairs returns the pairs of entities named together in at least
+// minJoint events
+func jointPairs(events []event, minJoint int) []pair {
+	byEntity := map[int][]int{}
+	joint := map[[2]int]int{}
+	for i, e := range events {
+		for j, a := range e.entities {
+			byEntity[a] = append(byEntity[a], i)
+			for _, b := range e.entities[j+1:] {
+				joint[[2]int{min(a, b), max(a, b)}]++
+			}
+		}
+	}
+
+	var pairs []pair
+	for k, n := range joint {
+		if n >= minJoint {
+			pairs = append(pairs, pair{k[0], k[1], byEntity[k[0]], byEntity[k[1]]})
+		}
+	}
+	slices.SortFunc(pairs, func(x, y pair) int {
+		if x.a != y.a {
+			return x.a - y.a
+		}
+		return x.b - y.b
+	})
+	return pairs
+}
+
+// pairBursts slides the window over the events of either entity of p and
+// returns the qualifying windows, busiest first, that don't share events
+func pairBursts(events []event, p pair, s TemporalSettings) []burst {
+	// Both entities' events, in date order
+	merged := make([]int, 0, len(p.aEv)+len(p.bEv))
+	i, j := 0, 0
+	for i < len(p.aEv) || j < len(p.bEv) {
+		switch {
+		case j == len(p.bEv) || (i < len(p.aEv) && p.aEv[i] < p.bEv[j]):
+			merged = append(merged, p.aEv[i])
+			i++
+		case i == len(p.aEv) || p.bEv[j] < p.aEv[i]:
+			merged = append(merged, p.bEv[j])
+			j++
+		default:
+			merged = append(merged, p.aEv[i])
+			i++
+			j++
+		}
+	}
+	n := len(merged)
+	if n < s.MinEvents {
+		return nil
+	}
+	// The usual rate is over the whole span the pair is active; a pair active
+	// for no longer than the window has nothing to compare with
+	span := events[merged[n-1]].day - events[merged[0]].day + 1
+	if span <= s.WindowDays {
+		return nil
+	}
+	expected := float64(n) * float64(s.WindowDays) / float64(span)
+
+	type window struct {
+		from, to int // indices into merged, to exclusive
+		ratio    float64
+	}
+	var windows []window
+	var countA, countB int
+	sources := map[string]int{}
+	add := func(k int, d int) {
+		e := events[merged[k]]
+		if slices.Contains(e.entities, p.a) {
+			countA += d
+		}
+		if slices.Contains(e.entities, p.b) {
+			countB += d
+		}
+		sources[e.source] += d
+		if sources[e.source] == 0 {
+			delete(sources, e.source)
+		}
+	}
+	to := 0
+	for from := 0; from < n; from++ {
+		for to < n && events[merged[to]].day-events[merged[from]].day < s.WindowDays {
+			add(to, 1)
+			to++
+		}
+		count := to - from
+		ratio := float64(count) / expected
+		if count >= s.MinEvents && countA > 0 && countB > 0 && len(sources) >= s.MinSources && ratio >= s.MinRatio {
+			windows = append(windows, window{from, to, ratio})
+		}
+		add(from, -1)
+	}
+
+	slices.SortStableFunc(windows, func(x, y window) int {
+		if c := (y.to - y.from) - (x.to - x.from); c != 0 {
+			return c
+		}
+		return x.from - y.from
+	})
+	var bursts []burst
+	var taken []window
+	for _, w := range windows {
+		if slices.ContainsFunc(taken, func(t window) bool { return w.from < t.to && t.from < w.to }) {
+			continue
+		}
+		taken = append(taken, w)
+		bursts = append(bursts, burst{
+			entities: []int{p.a, p.b},
+			start:    events[merged[w.from]].day,
+			end:      events[merged[w.to-1]].day,
+			events:   slices.Clone(merged[w.from:w.to]),
+			ratio:    w.ratio,
+		})
+	}
+	return bursts
+}
+
+// mergeBursts merges bursts that overlap in time and share an entity,
+// strongest first, as long as a cluster stays within maxEntities
+func mergeBursts(bursts []burst, maxEntities int) []burst {
+	slices.SortStableFunc(bursts, func(x, y burst) int {
+		switch {
+		case x.ratio > y.ratio:
+			return -1
+		case x.ratio < y.ratio:
+			return 1
+		}
+		return x.start - y.start
+	})
+
+	var clusters []burst
+	for _, b := range bursts {
+		merged := false
+		for i := range clusters {
+			c := &clusters[i]
+			if b.start > c.end || c.start > b.end {
+				continue
+			}
+			if !slices.ContainsFunc(b.entities, func(id int) bool { return slices.Contains(c.entities, id) }) {
+				continue
+			}
+			entities := union(c.entities, b.entities)
+			if len(entities) > maxEntities {
+				continue
+			}
+			c.entities = entities
+			c.events = union(c.events, b.events)
+			c.start = min(c.start, b.start)
+			c.end = max(c.end, b.end)
+			c.ratio = max(c.ratio, b.ratio)
+			merged = true
+			break
+		}
+		if !merged {
+			clusters = append(clusters, b)
+		}
+	}
+	return clusters
+}
+
+// union returns the sorted union of two sorted slices
+func union(a, b []int) []int {
+	u := append(slices.Clone(a), b...)
+	slices.Sort(u)
+	return slices.Compact(u)
+}
+
+// saveClusters records the clusters as findings, returning how many
+// unreviewed findings of earlier runs were removed
+func saveClusters(ctx context.Context, pool *pgxpool.Pool, events []event, clusters []burst) (int, error) {
+	var ids []int
+	for _, c := range clusters {
+		ids = union(ids, c.entities)
+	}
+	names, err := EntityNames(ctx, pool, ids)
+	if err != nil {
+		return 0, err
+	}
+
+	findings := make([]Finding, len(clusters))
+	for i, c := range clusters {
+		findings[i] = finding(events, c, names)
+	}
+	return Record(ctx, pool, DetectorTemporal, findings)
+}
+
+// finding describes a cluster for pattern_findings
+func finding(events []event, c burst, names map[int]string) Finding {
+	date := func(day int) string {
+		return time.Unix(int64(day)*86400, 0).UTC().Format(time.DateOnly)
+	}
+
+	key := make([]string, len(c.entities))
+	who := make([]string, len(c.entities))
+	for i, id := range c.entities {
+		key[i] = strconv.Itoa(id)
+		who[i] = names[id]
+		if who[i] == "" {
+			who[i] = "#" + key[i]
+		}
+	}
+
+	evidence := Evidence{
+		From:    date(c.start),
+		To:      date(c.end),
+		Ratio:   c.ratio,
+		Sources: map[string]int{},
+		Events:  []EvidenceEvent{},
+	}
+	for _, i := range c.events {
+		e := events[i]
+		evidence.Sources[e.source]++
+		if len(evidence.Events) < maxEvidenceEvents {
+			evidence.Events = append(evidence.Events, EvidenceEvent{e.source, e.id, date(e.day), e.entities})
+		}
+	}
+
+	title := "Burst of activity: " + joinNames(who)
+	var counts []string
+	for _, s := range []string{SourceDocument, SourceFlight, SourceContribution} {
+		if n := evidence.Sources[s]; n > 0 {
+			counts = append(counts, fmt.Sprintf("%d %s", n, plural(s, n)))
+		}
+	}
+	return Finding{
+		Key:   strings.Join(key, ",") + ":" + evidence.From + ":" + evidence.To,
+		Title: title,
+		Description: fmt.Sprintf("%s involving %s between %s and %s, %.1f times their usual rate.",
+			joinNames(counts), joinNames(who), evidence.From, evidence.To, c.ratio),
+		EntityIDs:  c.entities,
+		Evidence:   evidence,
+		Confidence: 1 - 1/c.ratio,
+	}
+}
+
+// joinNames joins a list as "a, b and c"
+func joinNames(s []string) string {
+	if len(s) < 2 {
+		return strings.Join(s, "")
+	}
+	return strings.Join(s[:len(s)-1], ", ") + " and " + s[len(s)-1]
+}
+
+// plural returns the plural of a source name when n isn't 1
+func plural(s string, n int) string {
+	if n == 1 {
+		return s
+	}
+	return s + "s"
+}
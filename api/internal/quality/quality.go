@@ -0,0 +1,230 @@
+package quality
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// JobKind is the jobs.kind for on-demand quality reports
+const JobKind = "quality"
+
+// sampleSize is how many offending row IDs each check reports
+const sampleSize = 20
+
+// Check is an automated data quality test. Query must return the IDs of
+// offending rows.
+type Check struct {
+	Name        string
+	Description string
+	Severity    string // error, warning
+	Query       string
+}
+
+// CheckResult is the outcome of one check
+type CheckResult struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Severity    string `json:"severity"`
+	Count       int    `json:"count"`
+	Sample      []int  `json:"sample"`
+	Delta       *int   `json:"delta,omitempty"` // change since the previous report
+	Error       string `json:"error,omitempty"`
+}
+
+// Report is a full run of every check
+type Report struct {
+	ID          int           `json:"id"`
+	Checks      []CheckResult `json:"checks"`
+	Failing     int           `json:"failing"`
+	DurationMs  int           `json:"durationMs"`
+	GeneratedAt time.Time     `json:"generatedAt"`
+}
+
+// Checks are run in order for every report
+var Checks = []Check{
+	{
+		Name:        "documents_empty_text",
+		Description: "Documents with no OCR text",
+		Severity:    "warning",
+		Query: `SELECT id FROM documents
+			WHERE full_text IS NULL OR btrim(full_text) = ''`,
+	},
+	{
+		Name:        "entities_zero_documents",
+		Description: "Entities not linked to any document",
+		Severity:    "warning",
+		Query: `SELECT e.id FROM entities e
+			WHERE NOT EXISTS (SELECT 1 FROM document_entities de WHERE de.entity_id = e.id)`,
+	},
+	{
+		Name:        "orphaned_document_entities",
+		Description: "document_entities rows pointing at missing documents or entities",
+		Severity:    "error",
+		Query: `SELECT de.id FROM document_entities de
+			LEFT JOIN documents d ON d.id = de.document_id
+			LEFT JOIN entities e ON e.id = de.entity_id
+			WHERE d.id IS NULL OR e.id IS NULL`,
+	},
+	{
+		Name:        "document_count_mismatch",
+		Description: "Entities whose document_count differs from their document links",
+		Severity:    "error",
+		Query: `SELECT e.id FROM entities e
+			LEFT JOIN (
+				SELECT entity_id, COUNT(DISTINCT document_id) AS n
+				FROM document_entities GROUP BY entity_id
+			) c ON c.entity_id = e.id
+			WHERE COALESCE(e.document_count, 0) != COALESCE(c.n, 0)`,
+	},
+	{
+		Name:        "connection_count_mismatch",
+		Description: "Entities whose connection_count differs from their co-occurring entities",
+		Severity:    "error",
+		Query: `SELECT e.id FROM entities e
+			LEFT JOIN (
+				SELECT de1.entity_id, COUNT(DISTINCT de2.entity_id) AS n
+				FROM document_entities de1
+				JOIN document_entities de2 ON de1.document_id = de2.document_id
+					AND de1.entity_id != de2.entity_id
+				GROUP BY de1.entity_id
+			) c ON c.entity_id = e.id
+			WHERE COALESCE(e.connection_count, 0) != COALESCE(c.n, 0)`,
+	},
+	{
+		Name:        "duplicate_doc_ids",
+		Description: "Documents whose doc_id differs from another only by case or whitespace",
+		Severity:    "error",
+		Query: `SELECT id FROM (
+				SELECT id, COUNT(*) OVER (PARTITION BY upper(btrim(doc_id))) AS n
+				FROM documents
+			) d WHERE n > 1`,
+	},
+	{
+		Name:        "date_range_inverted",
+		Description: "Documents with date_earliest after date_latest",
+		Severity:    "error",
+		Query: `SELECT id FROM documents
+			WHERE date_earliest > date_latest`,
+	},
+	{
+		Name:        "date_out_of_range",
+		Description: "Documents dated before 1900 or in the future",
+		Severity:    "warning",
+		Query: `SELECT id FROM documents
+			WHERE date_earliest < DATE '1900-01-01'
+			   OR date_latest > CURRENT_DATE`,
+	},
+}
+
+// Run executes every check, stores the report and returns it with deltas
+// against the previous report
+func Run(ctx context.Context, pool *pgxpool.Pool) (*Report, error) {
+	started := time.Now()
+	report := &Report{Checks: make([]CheckResult, 0, len(Checks))}
+
+	for _, check := range Checks {
+		report.Checks = append(report.Checks, runCheck(ctx, pool, check))
+	}
+	for _, r := range report.Checks {
+		if r.Count > 0 || r.Error != "" {
+			report.Failing++
+		}
+	}
+	report.DurationMs = int(time.Since(started).Milliseconds())
+
+	previous, err := Latest(ctx, pool)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return nil, err
+	}
+	if previous != nil {
+		applyDeltas(report, previous)
+	}
+
+	err = pool.QueryRow(ctx, `
+		INSERT INTO quality_reports (checks, failing, duration_ms)
+		VALUES ($1, $2, $3)
+		RETURNING id, generated_at
+	`, report.Checks, report.Failing, report.DurationMs).Scan(&report.ID, &report.GeneratedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+func runCheck(ctx context.Context, pool *pgxpool.Pool, check Check) CheckResult {
+	result := CheckResult{
+		Name:        check.Name,
+		Description: check.Description,
+		Severity:    check.Severity,
+		Sample:      []int{},
+	}
+
+	err := pool.QueryRow(ctx, `
+		SELECT COUNT(*), COALESCE((array_agg(id ORDER BY id))[1:$1], '{}')
+		FROM (`+check.Query+`) q
+	`, sampleSize).Scan(&result.Count, &result.Sample)
+	if err != nil {
+		result.Error = err.Error()
+	}
+
+	return result
+}
+
+func applyDeltas(report, previous *Report) {
+	counts := make(map[string]int)
+	for _, r := range previous.Checks {
+		counts[r.Name] = r.Count
+	}
+
+	for i, r := range report.Checks {
+		if prev, ok := counts[r.Name]; ok {
+			delta := r.Count - prev
+			report.Checks[i].Delta = &delta
+		}
+	}
+}
+
+// Latest returns the most recent stored report
+func Latest(ctx context.Context, pool *pgxpool.Pool) (*Report, error) {
+	var r Report
+	err := pool.QueryRow(ctx, `
+		SELECT id, checks, failing, COALESCE(duration_ms, 0), generated_at
+		FROM quality_reports
+		ORDER BY generated_at DESC
+		LIMIT 1
+	`).Scan(&r.ID, &r.Checks, &r.Failing, &r.DurationMs, &r.GeneratedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// History returns recent reports, newest first
+func History(ctx context.Context, pool *pgxpool.Pool, limit int) ([]Report, error) {
+	rows, err := pool.Query(ctx, `
+		SELECT id, checks, failing, COALESCE(duration_ms, 0), generated_at
+		FROM quality_reports
+		ORDER BY generated_at DESC
+		LIMIT $1
+	`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var reports []Report
+	for rows.Next() {
+		var r Report
+		if err := rows.Scan(&r.ID, &r.Checks, &r.Failing, &r.DurationMs, &r.GeneratedAt); err != nil {
+			return nil, err
+		}
+		reports = append(reports, r)
+	}
+
+	return reports, rows.Err()
+}
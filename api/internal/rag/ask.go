@@ -0,0 +1,184 @@
+package rag
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/subculture-collective/epstein-db/api/internal/llm"
+)
+
+const systemPrompt = `You are a research assistant answering questions about documents related to the Jeffrey Epstein case.
+
+Answer strictly from the numbered sources you are given. After each claim, cite the source it comes from as [n]. Quote the exact supporting passage for every source you cite. If the sources don't answer the question, say so by setting "insufficient" rather than guessing or using outside knowledge.`
+
+// Config tunes retrieval and generation
+type Config struct {
+	Chunks        int     // chunks given to the model
+	Candidates    int     // candidates each search method contributes
+	MinSimilarity float64 // below this best similarity, retrieval is too weak to answer
+	MaxTokens     int     // answer length cap
+}
+
+// DefaultConfig returns the settings used by the API
+func DefaultConfig() Config {
+	return Config{
+		Chunks:        8,
+		Candidates:    40,
+		MinSimilarity: 0.35,
+		MaxTokens:     1500,
+	}
+}
+
+// Citation ties a marker in the answer to the passage it rests on
+type Citation struct {
+	Marker     int    `json:"marker" doc:"The [n] used in the answer"`
+	DocumentID int    `json:"documentId"`
+	DocID      string `json:"docId"`
+	Quote      string `json:"quote"`
+	Start      int    `json:"start" doc:"Offset of the quote in the document's full text"`
+	End        int    `json:"end"`
+}
+
+// Answer is the response to a question. When Refused is set, Text is empty
+// and Reason says why.
+type Answer struct {
+	Question     string     `json:"question"`
+	Text         string     `json:"answer" doc:"Answer with [n] citation markers"`
+	Citations    []Citation `json:"citations"`
+	Refused      bool       `json:"refused"`
+	Reason       string     `json:"reason,omitempty"`
+	Model        string     `json:"model,omitempty"`
+	InputTokens  int        `json:"inputTokens"`
+	OutputTokens int        `json:"outputTokens"`
+}
+
+// QuoteRef is a passage the model quoted from a numbered source
+type QuoteRef struct {
+	Source int    `json:"source"`
+	Quote  string `json:"quote"`
+}
+
+type reply struct {
+	Answer       string     `json:"answer"`
+	Insufficient bool       `json:"insufficient"`
+	Citations    []QuoteRef `json:"citations"`
+}
+
+// Asker answers questions with retrieval-augmented generation
+type Asker struct {
+	retriever *Retriever
+	client    llm.Client
+	cfg       Config
+}
+
+// NewAsker creates an asker
+func NewAsker(retriever *Retriever, client llm.Client, cfg Config) *Asker {
+	return &Asker{retriever: retriever, client: client, cfg: cfg}
+}
+
+// Ask answers question from retrieved chunks. It refuses, without calling
+// the model, when retrieval finds nothing relevant enough, and drops any
+// citation whose quote isn't in its source.
+func (a *Asker) Ask(ctx context.Context, question string) (*Answer, error) {
+	answer := &Answer{Question: question, Citations: []Citation{}}
+
+	chunks, err := a.retriever.Search(ctx, question, a.cfg.Chunks, a.cfg.Candidates)
+	if err != nil {
+		return nil, err
+	}
+	if !a.confident(chunks) {
+		answer.Refused = true
+		answer.Reason = "no sufficiently relevant passages were found"
+		return answer, nil
+	}
+
+	var prompt strings.Builder
+	WriteSources(&prompt, chunks)
+	fmt.Fprintf(&prompt, `Question: %s
+
+Respond with a JSON object:
+{
+  "answer": "The answer, with [n] after each claim",
+  "citations": [{"source": n, "quote": "exact passage from source n"}],
+  "insufficient": false
+}
+
+Return ONLY valid JSON.`, question)
+
+	resp, err := a.client.Complete(ctx, llm.UserPrompt(systemPrompt, prompt.String(), a.cfg.MaxTokens))
+	if err != nil {
+		return nil, err
+	}
+	answer.Model = resp.Model
+	answer.InputTokens = resp.InputTokens
+	answer.OutputTokens = resp.OutputTokens
+
+	var r reply
+	if err := llm.DecodeJSON(resp.Text, &r); err != nil {
+		return nil, fmt.Errorf("rag: %w", err)
+	}
+	if r.Insufficient || strings.TrimSpace(r.Answer) == "" {
+		answer.Refused = true
+		answer.Reason = "the retrieved passages don't answer the question"
+		return answer, nil
+	}
+
+	answer.Citations = Cite(chunks, r.Citations)
+	if len(answer.Citations) == 0 {
+		answer.Refused = true
+		answer.Reason = "the answer could not be tied to any source passage"
+		return answer, nil
+	}
+	answer.Text = r.Answer
+	return answer, nil
+}
+
+// confident reports whether retrieval is strong enough to answer from. With
+// embeddings that takes one chunk similar enough to the question; without,
+// any full-text match.
+func (a *Asker) confident(chunks []Chunk) bool {
+	for _, c := range chunks {
+		if !a.retriever.Semantic() && c.KeywordMatch {
+			return true
+		}
+		if c.Similarity != nil && *c.Similarity >= a.cfg.MinSimilarity {
+			return true
+		}
+	}
+	return false
+}
+
+// WriteSources numbers chunks for the model to cite
+func WriteSources(prompt *strings.Builder, chunks []Chunk) {
+	prompt.WriteString("Sources:\n\n")
+	for i, c := range chunks {
+		fmt.Fprintf(prompt, "<source n=\"%d\" document=\"%s\">\n%s\n</source>\n\n", i+1, c.DocID, c.Content)
+	}
+}
+
+// Cite locates each quoted passage in its source chunk, keeping only those
+// found verbatim
+func Cite(chunks []Chunk, quotes []QuoteRef) []Citation {
+	citations := []Citation{}
+	for _, q := range quotes {
+		if q.Source < 1 || q.Source > len(chunks) {
+			continue
+		}
+		quote := strings.TrimSpace(q.Quote)
+		c := chunks[q.Source-1]
+		i := strings.Index(c.Content, quote)
+		if quote == "" || i < 0 {
+			continue
+		}
+		citations = append(citations, Citation{
+			Marker:     q.Source,
+			DocumentID: c.DocumentID,
+			DocID:      c.DocID,
+			Quote:      quote,
+			Start:      c.Start + i,
+			End:        c.Start + i + len(quote),
+		})
+	}
+	return citations
+}
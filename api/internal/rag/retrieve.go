@@ -0,0 +1,138 @@
+// Package rag answers questions from the corpus: it retrieves the document
+// chunks most relevant to a question and has the model answer strictly from
+// them, citing the passages it used.
+package rag
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/subculture-collective/epstein-db/api/internal/embeddings"
+	"github.com/subculture-collective/epstein-db/api/internal/store"
+)
+
+// rrfK damps reciprocal rank fusion so that the top few ranks of one list
+// don't drown out agreement between the two
+const rrfK = 60
+
+// Chunk is a retrieved passage of a document
+type Chunk struct {
+	ID           int      `json:"id"`
+	DocumentID   int      `json:"documentId"`
+	DocID        string   `json:"docId"`
+	Content      string   `json:"content"`
+	Start        int      `json:"start" doc:"Offset of the chunk in the document's full text"`
+	End          int      `json:"end"`
+	Score        float64  `json:"score" doc:"Fused rank score; higher is better"`
+	Similarity   *float64 `json:"similarity" doc:"Cosine similarity to the query, when embeddings are configured"`
+	KeywordMatch bool     `json:"keywordMatch"`
+}
+
+// Retriever finds chunks by hybrid search: nearest neighbours of the query
+// embedding and full-text matches, merged by reciprocal rank fusion
+type Retriever struct {
+	pool     *pgxpool.Pool
+	embedder embeddings.Provider // nil for full-text only
+}
+
+// NewRetriever creates a retriever. embedder may be nil, in which case only
+// full-text search is used.
+func NewRetriever(pool *pgxpool.Pool, embedder embeddings.Provider) *Retriever {
+	return &Retriever{pool: pool, embedder: embedder}
+}
+
+// Semantic reports whether the retriever can search by embedding
+func (r *Retriever) Semantic() bool {
+	return r.embedder != nil
+}
+
+// Filter narrows a search to part of the corpus. Zero fields don't filter.
+type Filter struct {
+	DatasetID  int
+	DocumentID int
+}
+
+// Search returns up to limit chunks for query, best first, leaving out
+// documents of restricted datasets unless ctx allows them. Each method
+// contributes its top candidates before fusion.
+func (r *Retriever) Search(ctx context.Context, query string, limit, candidates int) ([]Chunk, error) {
+	return r.SearchIn(ctx, query, limit, candidates, Filter{})
+}
+
+// SearchIn is Search restricted to the chunks matching f
+func (r *Retriever) SearchIn(ctx context.Context, query string, limit, candidates int, f Filter) ([]Chunk, error) {
+	var vector *string
+	if r.embedder != nil {
+		vectors, err := r.embedder.Embed(ctx, []string{query})
+		if err != nil {
+			return nil, err
+		}
+		v := embeddings.VectorLiteral(vectors[0])
+		vector = &v
+	}
+
+	rows, err := r.pool.Query(ctx, `
+		WITH semantic AS (
+			SELECT id, similarity, ROW_NUMBER() OVER (ORDER BY similarity DESC) AS rank
+			FROM (
+				SELECT c.id, 1 - (c.embedding <=> $1::vector) AS similarity
+				FROM document_chunks c
+				JOIN documents d ON d.id = c.document_id
+				WHERE $1::vector IS NOT NULL AND c.embedding IS NOT NULL
+				  AND ($6 = 0 OR d.dataset_id = $6) AND ($7 = 0 OR c.document_id = $7)
+				  AND ($8 OR NOT dataset_restricted(d.dataset_id))
+				ORDER BY c.embedding <=> $1::vector
+				LIMIT $3
+			) nearest
+		),
+		keyword AS (
+			SELECT id, ROW_NUMBER() OVER (ORDER BY rank DESC) AS rank
+			FROM (
+				SELECT c.id, ts_rank(to_tsvector('english', c.content), q) AS rank
+				FROM document_chunks c
+				JOIN documents d ON d.id = c.document_id
+				CROSS JOIN plainto_tsquery('english', $2) q
+				WHERE to_tsvector('english', c.content) @@ q
+				  AND ($6 = 0 OR d.dataset_id = $6) AND ($7 = 0 OR c.document_id = $7)
+				  AND ($8 OR NOT dataset_restricted(d.dataset_id))
+				ORDER BY rank DESC
+				LIMIT $3
+			) matches
+		)
+		SELECT c.id, c.document_id, d.doc_id, c.content, c.char_start, c.char_end,
+			   COALESCE(1.0 / ($5 + s.rank), 0) + COALESCE(1.0 / ($5 + k.rank), 0) AS score,
+			   s.similarity, k.id IS NOT NULL
+		FROM semantic s
+		FULL JOIN keyword k ON k.id = s.id
+		JOIN document_chunks c ON c.id = COALESCE(s.id, k.id)
+		JOIN documents d ON d.id = c.document_id
+		ORDER BY score DESC, c.id
+		LIMIT $4
+	`, vector, query, candidates, limit, rrfK, f.DatasetID, f.DocumentID, store.CanReadRestricted(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var chunks []Chunk
+	for rows.Next() {
+		var c Chunk
+		if err := rows.Scan(&c.ID, &c.DocumentID, &c.DocID, &c.Content, &c.Start, &c.End,
+			&c.Score, &c.Similarity, &c.KeywordMatch); err != nil {
+			return nil, err
+		}
+		chunks = append(chunks, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	m, err := store.LoadMasker(ctx, r.pool)
+	if err != nil {
+		return nil, err
+	}
+	for i := range chunks {
+		chunks[i].Content = m.Mask(chunks[i].Content)
+	}
+	return chunks, nil
+}
@@ -0,0 +1,112 @@
+package rag
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/subculture-collective/epstein-db/api/internal/llm"
+)
+
+// Verdicts
+const (
+	Supported    = "supported"
+	Contradicted = "contradicted"
+	NotFound     = "not_found"
+)
+
+const verifySystemPrompt = `You fact-check claims against documents related to the Jeffrey Epstein case.
+
+Judge the claim strictly by the numbered sources you are given, never by outside knowledge. A claim is "supported" only if the sources state it, "contradicted" only if they state something incompatible with it, and otherwise "not_found". Quote the exact passages your verdict rests on.`
+
+// Verdict is the outcome of checking a claim against the documents
+type Verdict struct {
+	Claim         string     `json:"claim"`
+	Verdict       string     `json:"verdict" enum:"supported,contradicted,not_found"`
+	Explanation   string     `json:"explanation"`
+	Supporting    []Citation `json:"supporting"`
+	Contradicting []Citation `json:"contradicting"`
+	Model         string     `json:"model,omitempty"`
+	InputTokens   int        `json:"inputTokens"`
+	OutputTokens  int        `json:"outputTokens"`
+}
+
+type verifyReply struct {
+	Verdict       string     `json:"verdict"`
+	Explanation   string     `json:"explanation"`
+	Supporting    []QuoteRef `json:"supporting"`
+	Contradicting []QuoteRef `json:"contradicting"`
+}
+
+// Verifier checks claims against retrieved evidence
+type Verifier struct {
+	retriever *Retriever
+	client    llm.Client
+	cfg       Config
+}
+
+// NewVerifier creates a verifier
+func NewVerifier(retriever *Retriever, client llm.Client, cfg Config) *Verifier {
+	return &Verifier{retriever: retriever, client: client, cfg: cfg}
+}
+
+// Verify classifies claim as supported, contradicted or not found in the
+// documents. A verdict either way must rest on at least one passage found
+// verbatim in the evidence; otherwise it is reported as not found.
+func (v *Verifier) Verify(ctx context.Context, claim string) (*Verdict, error) {
+	verdict := &Verdict{
+		Claim:         claim,
+		Verdict:       NotFound,
+		Supporting:    []Citation{},
+		Contradicting: []Citation{},
+	}
+
+	chunks, err := v.retriever.Search(ctx, claim, v.cfg.Chunks, v.cfg.Candidates)
+	if err != nil {
+		return nil, err
+	}
+	if len(chunks) == 0 {
+		verdict.Explanation = "No passages related to the claim were found."
+		return verdict, nil
+	}
+
+	var prompt strings.Builder
+	WriteSources(&prompt, chunks)
+	fmt.Fprintf(&prompt, `Claim: %s
+
+Respond with a JSON object:
+{
+  "verdict": "supported" | "contradicted" | "not_found",
+  "explanation": "One or two sentences, citing sources as [n]",
+  "supporting": [{"source": n, "quote": "exact passage from source n"}],
+  "contradicting": [{"source": n, "quote": "exact passage from source n"}]
+}
+
+Return ONLY valid JSON.`, claim)
+
+	resp, err := v.client.Complete(ctx, llm.UserPrompt(verifySystemPrompt, prompt.String(), v.cfg.MaxTokens))
+	if err != nil {
+		return nil, err
+	}
+	verdict.Model = resp.Model
+	verdict.InputTokens = resp.InputTokens
+	verdict.OutputTokens = resp.OutputTokens
+
+	var r verifyReply
+	if err := llm.DecodeJSON(resp.Text, &r); err != nil {
+		return nil, fmt.Errorf("rag: %w", err)
+	}
+	verdict.Explanation = r.Explanation
+	verdict.Supporting = Cite(chunks, r.Supporting)
+	verdict.Contradicting = Cite(chunks, r.Contradicting)
+
+	switch {
+	case r.Verdict == Supported && len(verdict.Supporting) > 0:
+		verdict.Verdict = Supported
+	case r.Verdict == Contradicted && len(verdict.Contradicting) > 0:
+		verdict.Verdict = Contradicted
+	case r.Verdict == Supported || r.Verdict == Contradicted:
+		verdict.Explanation = "The model's verdict could not be tied to any source passage."
+	}
+	return verdict, nil
+}
@@ -0,0 +1,211 @@
+// Package ratelimit throttles clients with in-memory token buckets
+package ratelimit
+
+import (
+	"errors"
+	"hash/fnv"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/subculture-collective/epstein-db/api/internal/apierr"
+	"github.com/subculture-collective/epstein-db/api/internal/auth"
+)
+
+// shardCount spreads buckets over independently locked maps so concurrent
+// requests from different clients rarely contend
+const shardCount = 64
+
+// sweepInterval is how often a shard drops buckets that have refilled
+const sweepInterval = time.Minute
+
+// Config sets per-minute request budgets. A budget of 0 disables limiting
+// for that class of client.
+type Config struct {
+	AnonymousPerMinute  int `json:"anonymousPerMinute"`
+	KeyedPerMinute      int `json:"keyedPerMinute"`
+	FailedAuthPerMinute int `json:"failedAuthPerMinute"`
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+type shard struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+// Limiter holds one token bucket per client. Each bucket holds up to a
+// minute's budget and refills continuously.
+type Limiter struct {
+	shards [shardCount]shard
+}
+
+// New creates an empty limiter
+func New() *Limiter {
+	l := &Limiter{}
+	for i := range l.shards {
+		l.shards[i].buckets = make(map[string]*bucket)
+	}
+	return l
+}
+
+// Result describes the state of a client's bucket after a request
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	Reset     time.Duration // until the bucket is full again
+	RetryIn   time.Duration // until the next request would be allowed
+}
+
+// Allow takes a token from key's bucket if one is available
+func (l *Limiter) Allow(key string, perMinute int, now time.Time) Result {
+	return l.AllowPer(key, perMinute, time.Minute, now)
+}
+
+// AllowPer is Allow for a budget of n requests per period
+func (l *Limiter) AllowPer(key string, n int, period time.Duration, now time.Time) Result {
+	return l.take(key, n, period, now, true)
+}
+
+// Peek reports whether key's bucket has a token, without taking it
+func (l *Limiter) Peek(key string, n int, period time.Duration, now time.Time) Result {
+	return l.take(key, n, period, now, false)
+}
+
+func (l *Limiter) take(key string, n int, period time.Duration, now time.Time, consume bool) Result {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	s := &l.shards[h.Sum32()%shardCount]
+
+	capacity := float64(n)
+	rate := capacity / period.Seconds() // tokens per second
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if now.Sub(s.lastSweep) > sweepInterval {
+		s.sweep(capacity, rate, now)
+	}
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: capacity, last: now}
+		s.buckets[key] = b
+	}
+
+	b.tokens = math.Min(capacity, b.tokens+now.Sub(b.last).Seconds()*rate)
+	b.last = now
+
+	res := Result{Limit: n}
+	if b.tokens >= 1 {
+		if consume {
+			b.tokens--
+		}
+		res.Allowed = true
+	} else {
+		res.RetryIn = seconds((1 - b.tokens) / rate)
+	}
+	res.Remaining = int(b.tokens)
+	res.Reset = seconds((capacity - b.tokens) / rate)
+	return res
+}
+
+// sweep drops buckets that have refilled completely, since a new bucket
+// would be identical. Buckets for other budgets are judged by the current
+// one, which at worst forgets a few tokens of history.
+func (s *shard) sweep(capacity, rate float64, now time.Time) {
+	for key, b := range s.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*rate >= capacity {
+			delete(s.buckets, key)
+		}
+	}
+	s.lastSweep = now
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(math.Ceil(s)) * time.Second
+}
+
+// Middleware limits each API key, or each client IP for anonymous requests,
+// to its budget. It must run after auth.Middleware.
+func Middleware(l *Limiter, cfg Config) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		p := auth.FromContext(c)
+
+		key := "ip:" + c.IP()
+		budget := cfg.AnonymousPerMinute
+		if p.KeyID != 0 {
+			key = "key:" + strconv.Itoa(p.KeyID)
+			budget = cfg.KeyedPerMinute
+		}
+		if budget == 0 {
+			return c.Next()
+		}
+
+		return respond(c, l.Allow(key, budget, time.Now()))
+	}
+}
+
+// FailedAuth limits each client IP to n requests a minute with an invalid
+// API key. It runs before auth.Middleware, which looks keys up in the
+// database, so that guessing keys is throttled before it reaches it: a
+// client that has used up its budget is refused outright, and each unknown
+// or revoked key (auth.ErrInvalidKey) spends a token. Other 401s, such as a
+// missing key, don't. An n of 0 disables it.
+func FailedAuth(n int) fiber.Handler {
+	if n == 0 {
+		return func(c *fiber.Ctx) error { return c.Next() }
+	}
+	l := New()
+	return func(c *fiber.Ctx) error {
+		key := "ip:" + c.IP()
+		if res := l.Peek(key, n, time.Minute, time.Now()); !res.Allowed {
+			c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(res.RetryIn.Seconds())))
+			return apierr.New(fiber.StatusTooManyRequests, apierr.CodeRateLimited, "too many invalid API keys")
+		}
+		err := c.Next()
+		if errors.Is(err, auth.ErrInvalidKey) {
+			l.AllowPer(key, n, time.Minute, time.Now())
+		}
+		return err
+	}
+}
+
+// Route limits each API key or client IP to n requests per period on the
+// routes it guards, on top of the global budget, for endpoints the public
+// could flood. Its headers describe this tighter budget. An n of 0
+// disables it.
+func Route(n int, period time.Duration) fiber.Handler {
+	if n == 0 {
+		return func(c *fiber.Ctx) error { return c.Next() }
+	}
+	l := New()
+	return func(c *fiber.Ctx) error {
+		key := "ip:" + c.IP()
+		if p := auth.FromContext(c); p.KeyID != 0 {
+			key = "key:" + strconv.Itoa(p.KeyID)
+		}
+		return respond(c, l.AllowPer(key, n, period, time.Now()))
+	}
+}
+
+// respond sets the rate limit headers and continues, or refuses with 429
+func respond(c *fiber.Ctx, res Result) error {
+	c.Set("X-RateLimit-Limit", strconv.Itoa(res.Limit))
+	c.Set("X-RateLimit-Remaining", strconv.Itoa(res.Remaining))
+	c.Set("X-RateLimit-Reset", strconv.Itoa(int(res.Reset.Seconds())))
+
+	if !res.Allowed {
+		c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(res.RetryIn.Seconds())))
+		return apierr.New(fiber.StatusTooManyRequests, apierr.CodeRateLimited, "rate limit exceeded")
+	}
+	return c.Next()
+}
@@ -0,0 +1,156 @@
+package recount
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// JobKind is the jobs.kind for recount jobs
+const JobKind = "recount"
+
+// maxReported caps the discrepancies returned in a report
+const maxReported = 100
+
+// Options select which entities to recount. With no document or entity
+// filter every entity is checked.
+type Options struct {
+	DocumentIDs []int      `json:"documentIds"`
+	EntityIDs   []int      `json:"entityIds"`
+	Since       *time.Time `json:"since"` // documents created or updated since
+	DryRun      bool       `json:"dryRun"`
+}
+
+// Discrepancy is an entity whose stored counts differ from reality
+type Discrepancy struct {
+	EntityID              int    `json:"entityId"`
+	CanonicalName         string `json:"canonicalName"`
+	StoredDocumentCount   int    `json:"storedDocumentCount"`
+	ActualDocumentCount   int    `json:"actualDocumentCount"`
+	StoredConnectionCount int    `json:"storedConnectionCount"`
+	ActualConnectionCount int    `json:"actualConnectionCount"`
+}
+
+// Report summarizes a recount
+type Report struct {
+	Discrepancies    int           `json:"discrepancies"`
+	DocumentCounts   int           `json:"documentCounts"`   // entities with a wrong document_count
+	ConnectionCounts int           `json:"connectionCounts"` // entities with a wrong connection_count
+	Updated          int64         `json:"updated"`
+	DryRun           bool          `json:"dryRun"`
+	Sample           []Discrepancy `json:"sample"`
+}
+
+// actualCounts computes real counts for the target entities. Only entities
+// linked to the selected documents can have changed, since a document only
+// connects the entities it mentions.
+const actualCounts = `
+	WITH targets AS (
+		SELECT id FROM entities
+		WHERE cardinality($1::int[]) = 0 AND $2::timestamptz IS NULL AND cardinality($3::int[]) = 0
+		UNION
+		SELECT id FROM entities WHERE id = ANY($3)
+		UNION
+		SELECT de.entity_id FROM document_entities de
+		JOIN documents d ON d.id = de.document_id
+		WHERE d.id = ANY($1)
+		   OR d.created_at >= $2 OR d.updated_at >= $2
+	),
+	docs AS (
+		SELECT entity_id, COUNT(DISTINCT document_id) AS n
+		FROM document_entities
+		WHERE entity_id IN (SELECT id FROM targets)
+		GROUP BY entity_id
+	),
+	conns AS (
+		SELECT de1.entity_id, COUNT(DISTINCT de2.entity_id) AS n
+		FROM document_entities de1
+		JOIN document_entities de2 ON de1.document_id = de2.document_id
+			AND de1.entity_id != de2.entity_id
+		WHERE de1.entity_id IN (SELECT id FROM targets)
+		GROUP BY de1.entity_id
+	),
+	actual AS (
+		SELECT e.id, e.canonical_name,
+			   COALESCE(e.document_count, 0) AS stored_docs,
+			   COALESCE(docs.n, 0)::int AS actual_docs,
+			   COALESCE(e.connection_count, 0) AS stored_conns,
+			   COALESCE(conns.n, 0)::int AS actual_conns
+		FROM entities e
+		JOIN targets t ON t.id = e.id
+		LEFT JOIN docs ON docs.entity_id = e.id
+		LEFT JOIN conns ON conns.entity_id = e.id
+	)
+`
+
+// Run compares stored entity counts against document_entities and, unless
+// DryRun is set, corrects them in bulk
+func Run(ctx context.Context, pool *pgxpool.Pool, opts Options) (*Report, error) {
+	report := &Report{DryRun: opts.DryRun, Sample: []Discrepancy{}}
+	if opts.DocumentIDs == nil {
+		opts.DocumentIDs = []int{}
+	}
+	if opts.EntityIDs == nil {
+		opts.EntityIDs = []int{}
+	}
+
+	err := pgx.BeginFunc(ctx, pool, func(tx pgx.Tx) error {
+		rows, err := tx.Query(ctx, actualCounts+`
+			SELECT id, canonical_name, stored_docs, actual_docs, stored_conns, actual_conns
+			FROM actual
+			WHERE stored_docs != actual_docs OR stored_conns != actual_conns
+			ORDER BY id
+		`, opts.DocumentIDs, opts.Since, opts.EntityIDs)
+		if err != nil {
+			return err
+		}
+
+		for rows.Next() {
+			var d Discrepancy
+			if err := rows.Scan(&d.EntityID, &d.CanonicalName,
+				&d.StoredDocumentCount, &d.ActualDocumentCount,
+				&d.StoredConnectionCount, &d.ActualConnectionCount); err != nil {
+				rows.Close()
+				return err
+			}
+
+			report.Discrepancies++
+			if d.StoredDocumentCount != d.ActualDocumentCount {
+				report.DocumentCounts++
+			}
+			if d.StoredConnectionCount != d.ActualConnectionCount {
+				report.ConnectionCounts++
+			}
+			if len(report.Sample) < maxReported {
+				report.Sample = append(report.Sample, d)
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		if opts.DryRun || report.Discrepancies == 0 {
+			return nil
+		}
+
+		tag, err := tx.Exec(ctx, actualCounts+`
+			UPDATE entities e SET
+				document_count = a.actual_docs,
+				connection_count = a.actual_conns,
+				updated_at = NOW()
+			FROM actual a
+			WHERE e.id = a.id
+			  AND (a.stored_docs != a.actual_docs OR a.stored_conns != a.actual_conns)
+		`, opts.DocumentIDs, opts.Since, opts.EntityIDs)
+		if err != nil {
+			return err
+		}
+		report.Updated = tag.RowsAffected()
+		return nil
+	})
+
+	return report, err
+}
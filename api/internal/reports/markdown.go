@@ -0,0 +1,188 @@
+package reports
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/subculture-collective/epstein-db/api/internal/models"
+)
+
+// Markdown renders the report for reading: who it covers, then each section
+// it holds. Documents and entities are linked relative to base, the API's
+// public URL, when it's set.
+func (r *Report) Markdown(base string) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# %s\n\n_Generated %s", r.Title, r.GeneratedAt.UTC().Format("2006-01-02 15:04 MST"))
+	if span := r.span(); span != "" {
+		fmt.Fprintf(&sb, " · covering %s", span)
+	}
+	sb.WriteString("_\n")
+
+	sb.WriteString("\n## Entities\n\n")
+	for _, e := range r.Entities {
+		fmt.Fprintf(&sb, "- **%s** (%s, #%d)\n", e.CanonicalName, e.EntityType, e.ID)
+	}
+	if len(r.Missing) > 0 {
+		fmt.Fprintf(&sb, "\n_Not found or protected: %s_\n", joinInts(r.Missing))
+	}
+
+	if r.Documents != nil {
+		fmt.Fprintf(&sb, "\n## Documents (%d)\n\n", len(r.Documents))
+		for _, d := range r.Documents {
+			fmt.Fprintf(&sb, "- **%s**", link(d.DocID, base, "/api/documents/"+strconv.Itoa(d.ID)))
+			if date := documentDate(d.DocumentSummary); date != "" {
+				fmt.Fprintf(&sb, " (%s)", date)
+			}
+			fmt.Fprintf(&sb, ", naming %s", r.names(d.EntityIDs))
+			if d.Summary != nil && *d.Summary != "" {
+				fmt.Fprintf(&sb, ": %s", oneLine(*d.Summary))
+			}
+			sb.WriteString("\n")
+		}
+	}
+
+	if r.Network != nil {
+		sb.WriteString("\n## Network\n")
+		if len(r.Entities) > 1 {
+			sb.WriteString("\n| | Shared documents |\n|---|---:|\n")
+			for i := range r.Entities {
+				for j := i + 1; j < len(r.Entities); j++ {
+					if n := r.Network.Shared[i][j]; n > 0 {
+						fmt.Fprintf(&sb, "| %s – %s | %d |\n", cell(r.Entities[i].CanonicalName), cell(r.Entities[j].CanonicalName), n)
+					}
+				}
+			}
+		}
+		if len(r.Network.Relations) > 0 {
+			sb.WriteString("\n### Relationships\n\n")
+			for _, rel := range r.Network.Relations {
+				fmt.Fprintf(&sb, "- %s → %s: %s (%d)\n", r.name(rel.Subject), r.name(rel.Object), strings.Join(rel.Predicates, ", "), rel.Count)
+			}
+		}
+		for _, n := range r.Network.Neighbours {
+			if len(n.Connections) == 0 {
+				continue
+			}
+			fmt.Fprintf(&sb, "\n### Closest to %s\n\n", r.name(n.EntityID))
+			for _, c := range n.Connections {
+				fmt.Fprintf(&sb, "- %s (%s): %d shared documents\n", link(c.CanonicalName, base, "/api/entities/"+strconv.Itoa(c.ID)), c.EntityType, c.SharedDocs)
+			}
+			if base != "" {
+				fmt.Fprintf(&sb, "\n![Network of %s](%s/api/entities/%d/network.svg)\n", r.name(n.EntityID), base, n.EntityID)
+			}
+		}
+	}
+
+	if r.Financials != nil {
+		fmt.Fprintf(&sb, "\n## Financials\n\nFlows: %d, totalling %s\n", len(r.Financials.Edges), dollars(r.Financials.Total))
+		if len(r.Financials.Edges) > 0 {
+			labels := make(map[string]string, len(r.Financials.Nodes))
+			for _, n := range r.Financials.Nodes {
+				labels[n.ID] = n.Label
+			}
+			sb.WriteString("\n| From | To | Kind | Amount | Records | Dates |\n|---|---|---|---:|---:|---|\n")
+			for _, e := range r.Financials.Edges {
+				fmt.Fprintf(&sb, "| %s | %s | %s | %s | %d | %s |\n",
+					cell(labels[e.Source]), cell(labels[e.Target]), e.Kind, dollars(e.Amount), e.Records, dateSpan(e.First, e.Last))
+			}
+		}
+	}
+
+	if r.Timeline != nil {
+		fmt.Fprintf(&sb, "\n## Timeline (%d)\n\n", len(r.Timeline))
+		for _, e := range r.Timeline {
+			fmt.Fprintf(&sb, "- **%s** %s: %s", e.Date, e.Type, oneLine(e.Description))
+			if e.DocID != nil {
+				fmt.Fprintf(&sb, " (%s)", *e.DocID)
+			}
+			sb.WriteString("\n")
+		}
+	}
+	return sb.String()
+}
+
+// span describes the report's date range
+func (r *Report) span() string {
+	switch {
+	case r.From != nil && r.To != nil:
+		return *r.From + " to " + *r.To
+	case r.From != nil:
+		return "from " + *r.From
+	case r.To != nil:
+		return "up to " + *r.To
+	}
+	return ""
+}
+
+// name is the name of one of the report's entities
+func (r *Report) name(id int) string {
+	for _, e := range r.Entities {
+		if e.ID == id {
+			return e.CanonicalName
+		}
+	}
+	return "#" + strconv.Itoa(id)
+}
+
+func (r *Report) names(ids []int) string {
+	names := make([]string, len(ids))
+	for i, id := range ids {
+		names[i] = r.name(id)
+	}
+	return strings.Join(names, ", ")
+}
+
+func documentDate(d models.DocumentSummary) string {
+	return dateSpan(datePrefix(d.DateEarliest), datePrefix(d.DateLatest))
+}
+
+// dateSpan renders a range of dates, either of which may be unknown
+func dateSpan(first, last *string) string {
+	switch {
+	case first != nil && last != nil && *first != *last:
+		return *first + " – " + *last
+	case first != nil:
+		return *first
+	case last != nil:
+		return *last
+	}
+	return ""
+}
+
+// dollars renders an amount in whole US dollars with thousands separators
+func dollars(amount float64) string {
+	s := strconv.FormatInt(int64(amount+0.5), 10)
+	var b strings.Builder
+	for i, c := range s {
+		if i > 0 && (len(s)-i)%3 == 0 {
+			b.WriteByte(',')
+		}
+		b.WriteRune(c)
+	}
+	return "$" + b.String()
+}
+
+func link(text, base, path string) string {
+	if base == "" {
+		return text
+	}
+	return "[" + text + "](" + base + path + ")"
+}
+
+func joinInts(ids []int) string {
+	parts := make([]string, len(ids))
+	for i, id := range ids {
+		parts[i] = strconv.Itoa(id)
+	}
+	return strings.Join(parts, ", ")
+}
+
+func oneLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// cell makes s safe in a table cell
+func cell(s string) string {
+	return strings.ReplaceAll(oneLine(s), "|", `\|`)
+}
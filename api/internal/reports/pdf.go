@@ -0,0 +1,260 @@
+package reports
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// PDFMediaType is the content type of rendered reports
+const PDFMediaType = "application/pdf"
+
+// Pages are US Letter with inch-wide margins at the sides, in points
+const (
+	pageWidth  = 612.0
+	pageHeight = 792.0
+	margin     = 54.0
+)
+
+// style is how a line of the report is set
+type style struct {
+	font   string // F1 is Helvetica, F2 Helvetica-Bold
+	size   float64
+	before float64 // space above, in points
+	indent float64
+}
+
+var (
+	bodyStyle   = style{font: "F1", size: 10}
+	titleStyle  = style{font: "F2", size: 18, before: 4}
+	h2Style     = style{font: "F2", size: 14, before: 12}
+	h3Style     = style{font: "F2", size: 11, before: 8}
+	bulletStyle = style{font: "F1", size: 10, indent: 12}
+)
+
+// inline matches the Markdown the PDF drops: images, links, which keep
+// their text, and emphasis
+var (
+	images   = regexp.MustCompile(`!\[[^\]]*\]\([^)]*\)`)
+	links    = regexp.MustCompile(`\[([^\]]*)\]\([^)]*\)`)
+	emphasis = strings.NewReplacer("**", "")
+)
+
+// line is a line of text set on a page
+type line struct {
+	style
+	text string
+	y    float64
+}
+
+// PDF writes the report as a PDF of plain text: its Markdown with headings
+// set large and bold and the markup left out. It uses only the standard
+// Helvetica fonts, so characters outside Windows-1252 are replaced.
+func (r *Report) PDF(w io.Writer) error {
+	pages := layout(r.Markdown(""))
+
+	var objects [][]byte
+	add := func(format string, args ...any) int {
+		objects = append(objects, []byte(fmt.Sprintf(format, args...)))
+		return len(objects)
+	}
+	// The catalog, page tree, fonts and info come first, so that pages can
+	// refer to them by number
+	add("<< /Type /Catalog /Pages 2 0 R >>")
+	add("") // page tree, filled in below
+	add("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	add("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+	add("<< /Title (%s) /Producer (epstein-db) >>", escapePDF(r.Title))
+
+	var kids []string
+	for n, page := range pages {
+		var content bytes.Buffer
+		for _, l := range page {
+			fmt.Fprintf(&content, "BT /%s %.1f Tf %.1f %.1f Td (%s) Tj ET\n",
+				l.font, l.size, margin+l.indent, l.y, escapePDF(l.text))
+		}
+		fmt.Fprintf(&content, "BT /F1 8 Tf %.1f %.1f Td (%s) Tj ET\n",
+			margin, margin/2, escapePDF(fmt.Sprintf("%s · page %d of %d", r.Title, n+1, len(pages))))
+
+		var compressed bytes.Buffer
+		z := zlib.NewWriter(&compressed)
+		z.Write(content.Bytes())
+		z.Close()
+		stream := add("<< /Length %d /Filter /FlateDecode >>\nstream\n%s\nendstream", compressed.Len(), compressed.Bytes())
+		page := add("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.0f %.0f] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>",
+			pageWidth, pageHeight, stream)
+		kids = append(kids, fmt.Sprintf("%d 0 R", page))
+	}
+	objects[1] = []byte(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(kids)))
+
+	b := bufio.NewWriter(w)
+	offset := 0
+	write := func(s string) {
+		n, _ := b.WriteString(s)
+		offset += n
+	}
+	write("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = offset
+		write(fmt.Sprintf("%d 0 obj\n", i+1))
+		write(string(obj))
+		write("\nendobj\n")
+	}
+	xref := offset
+	write(fmt.Sprintf("xref\n0 %d\n0000000000 65535 f \n", len(objects)+1))
+	for _, o := range offsets {
+		write(fmt.Sprintf("%010d 00000 n \n", o))
+	}
+	write(fmt.Sprintf("trailer\n<< /Size %d /Root 1 0 R /Info 5 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref))
+	return b.Flush()
+}
+
+// layout wraps the lines of a Markdown report and breaks them into pages
+func layout(markdown string) [][]line {
+	var pages [][]line
+	var page []line
+	y := pageHeight - margin
+	place := func(s style, text string) {
+		leading := s.size * 1.35
+		if len(page) > 0 {
+			y -= s.before
+		}
+		for _, wrapped := range wrap(text, s) {
+			if y-leading < margin {
+				pages = append(pages, page)
+				page, y = nil, pageHeight-margin
+			}
+			y -= leading
+			page = append(page, line{style: s, text: wrapped, y: y})
+			// Continued bullets line up with the text after the bullet
+			if strings.HasPrefix(wrapped, "• ") {
+				s.indent += width("• ", s)
+			}
+		}
+	}
+
+	for _, text := range strings.Split(markdown, "\n") {
+		text = emphasis.Replace(links.ReplaceAllString(images.ReplaceAllString(text, ""), "$1"))
+		text = strings.TrimRight(text, " ")
+		switch {
+		case text == "":
+			y -= bodyStyle.size * 0.6
+		case strings.HasPrefix(text, "# "):
+			place(titleStyle, text[2:])
+		case strings.HasPrefix(text, "## "):
+			place(h2Style, text[3:])
+		case strings.HasPrefix(text, "### "):
+			place(h3Style, text[4:])
+		case strings.HasPrefix(text, "- "):
+			place(bulletStyle, "• "+text[2:])
+		case strings.HasPrefix(text, "|---"):
+			// A table's rule; its rows are set as plain lines
+		case strings.HasPrefix(text, "|"):
+			// Split the row at the pipes that weren't escaped in a cell
+			cells := strings.Split(strings.ReplaceAll(strings.Trim(text, "| "), `\|`, "\x00"), " | ")
+			place(bodyStyle, strings.ReplaceAll(strings.Join(cells, " · "), "\x00", "|"))
+		default:
+			place(bodyStyle, strings.Trim(text, "_"))
+		}
+	}
+	return append(pages, page)
+}
+
+// wrap breaks text into lines that fit the page in style s, breaking
+// words only when one is wider than a line
+func wrap(text string, s style) []string {
+	room := pageWidth - 2*margin - s.indent
+	var lines []string
+	current := ""
+	for _, word := range strings.Fields(text) {
+		next := word
+		if current != "" {
+			next = current + " " + word
+		}
+		if width(next, s) <= room {
+			current = next
+			continue
+		}
+		if current != "" {
+			lines = append(lines, current)
+		}
+		for width(word, s) > room {
+			cut := len([]rune(word)) - 1
+			for cut > 1 && width(string([]rune(word)[:cut]), s) > room {
+				cut--
+			}
+			lines = append(lines, string([]rune(word)[:cut]))
+			word = string([]rune(word)[cut:])
+		}
+		current = word
+	}
+	if current != "" {
+		lines = append(lines, current)
+	}
+	return lines
+}
+
+// helveticaWidths are the widths of Helvetica's printable ASCII characters,
+// from space, in thousandths of the font size
+var helveticaWidths = [95]int{
+	278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278,
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+	1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+	333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+	556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584,
+}
+
+// width estimates how wide text is set in s; bold is taken to be a little
+// wider than regular throughout
+func width(text string, s style) float64 {
+	total := 0
+	for _, c := range text {
+		if c >= ' ' && c <= '~' {
+			total += helveticaWidths[c-' ']
+		} else {
+			total += 556
+		}
+	}
+	w := float64(total) * s.size / 1000
+	if s.font == "F2" {
+		w *= 1.08
+	}
+	return w
+}
+
+// winAnsi maps the characters of Windows-1252 outside Latin-1 to their
+// bytes
+var winAnsi = map[rune]byte{
+	'€': 0x80, '‚': 0x82, 'ƒ': 0x83, '„': 0x84, '…': 0x85, '†': 0x86, '‡': 0x87,
+	'ˆ': 0x88, '‰': 0x89, 'Š': 0x8a, '‹': 0x8b, 'Œ': 0x8c, 'Ž': 0x8e,
+	'‘': 0x91, '’': 0x92, '“': 0x93, '”': 0x94, '•': 0x95, '–': 0x96, '—': 0x97,
+	'˜': 0x98, '™': 0x99, 'š': 0x9a, '›': 0x9b, 'œ': 0x9c, 'ž': 0x9e, 'Ÿ': 0x9f,
+}
+
+// escapePDF encodes s as the body of a PDF string in Windows-1252
+func escapePDF(s string) string {
+	s = strings.ReplaceAll(s, "→", "->")
+	var b strings.Builder
+	for _, c := range s {
+		switch {
+		case c == '\\' || c == '(' || c == ')':
+			b.WriteByte('\\')
+			b.WriteRune(c)
+		case c >= ' ' && c <= '~':
+			b.WriteRune(c)
+		case c >= 0xa0 && c <= 0xff:
+			fmt.Fprintf(&b, "\\%03o", c)
+		case winAnsi[c] != 0:
+			fmt.Fprintf(&b, "\\%03o", winAnsi[c])
+		default:
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
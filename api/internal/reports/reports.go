@@ -0,0 +1,343 @@
+// Package reports builds reports on any set of entities an investigation
+// turns up: the documents naming them, how they connect to each other and
+// beyond, the money flowing to and from them, and their timeline, over a
+// range of dates. Reports are built in the background and kept as their
+// job's result, and rendered as Markdown or PDF when fetched. Like the API,
+// they leave out protected entities and mask protected names.
+package reports
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/subculture-collective/epstein-db/api/internal/models"
+	"github.com/subculture-collective/epstein-db/api/internal/store"
+)
+
+// JobKind is the jobs.kind for report jobs
+const JobKind = "report"
+
+// Sections a report can hold
+const (
+	SectionDocuments  = "documents"
+	SectionNetwork    = "network"
+	SectionFinancials = "financials"
+	SectionTimeline   = "timeline"
+)
+
+// Sections lists every section, in the order reports show them
+var Sections = []string{SectionDocuments, SectionNetwork, SectionFinancials, SectionTimeline}
+
+// MaxEntities is the most entities one report covers
+const MaxEntities = 50
+
+// Limits keep a report of many prolific entities readable
+const (
+	documentsPerEntity   = 1000
+	connectionsPerEntity = 10
+	maxFlows             = 200
+	maxEvents            = 500
+)
+
+// Params are the options of a report job
+type Params struct {
+	Title            string   `json:"title,omitempty"`
+	EntityIDs        []int    `json:"entityIds"`
+	From             *string  `json:"from,omitempty" doc:"Earliest date covered, YYYY-MM-DD"`
+	To               *string  `json:"to,omitempty" doc:"Latest date covered, YYYY-MM-DD"`
+	Sections         []string `json:"sections" doc:"Any of documents, network, financials and timeline"`
+	ExcludeSensitive bool     `json:"excludeSensitive,omitempty" doc:"Leave out documents with content warnings"`
+	KeyID            int      `json:"keyId" doc:"API key that requested the report"`
+}
+
+// Report is a built report. Sections that weren't asked for are left out.
+type Report struct {
+	Title       string                 `json:"title"`
+	GeneratedAt time.Time              `json:"generatedAt"`
+	From        *string                `json:"from"`
+	To          *string                `json:"to"`
+	Entities    []models.EntityBrief   `json:"entities"`
+	Missing     []int                  `json:"missing" doc:"Requested IDs that aren't entities, or are protected"`
+	Documents   []Document             `json:"documents,omitempty"`
+	Network     *Network               `json:"network,omitempty"`
+	Financials  *Financials            `json:"financials,omitempty"`
+	Timeline    []models.TimelineEvent `json:"timeline,omitempty"`
+}
+
+// Document is a document naming one or more of a report's entities
+type Document struct {
+	models.DocumentSummary
+	EntityIDs []int `json:"entityIds" doc:"The report's entities the document names"`
+}
+
+// Network is how a report's entities connect
+type Network struct {
+	// Shared and Relations are between the report's entities, in the order
+	// of Report.Entities
+	Shared    [][]int           `json:"shared" doc:"Documents naming both entities; the diagonal is each entity's documents"`
+	Relations []models.Relation `json:"relations" doc:"Extracted relationships between the entities"`
+	// Neighbours are each entity's closest connections outside the report
+	Neighbours []Neighbours `json:"neighbours"`
+}
+
+// Neighbours are the entities sharing the most documents with one of a
+// report's
+type Neighbours struct {
+	EntityID    int                 `json:"entityId"`
+	Connections []models.Connection `json:"connections"`
+}
+
+// Financials is the money moving to and from a report's entities
+type Financials struct {
+	Nodes []models.FinancialNode `json:"nodes"`
+	Edges []models.FinancialEdge `json:"edges"`
+	Total float64                `json:"total" doc:"Sum of the flows, in US dollars"`
+}
+
+// Has reports whether p asks for section
+func (p Params) Has(section string) bool {
+	return slices.Contains(p.Sections, section)
+}
+
+// Build gathers the report p asks for from s, calling progress, if not
+// nil, after each section. Reports are requested with an API key, so they
+// include the summaries of documents of restricted datasets. Without a
+// date range every document is kept; with one, only documents dated within
+// it, along with the flows and events it covers. The network isn't
+// limited by date.
+func Build(ctx context.Context, s *store.Store, p Params, progress func(done, total int) error) (*Report, error) {
+	ctx = store.WithRestricted(ctx)
+	if p.ExcludeSensitive {
+		ctx = store.WithoutSensitive(ctx)
+	}
+
+	found, err := s.EntitiesByID(ctx, p.EntityIDs)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[int]models.EntityBrief, len(found))
+	for _, e := range found {
+		byID[e.ID] = e
+	}
+	r := &Report{
+		Title:       p.Title,
+		GeneratedAt: time.Now().UTC(),
+		From:        p.From,
+		To:          p.To,
+		Entities:    []models.EntityBrief{},
+		Missing:     []int{},
+	}
+	var ids []int
+	for _, id := range p.EntityIDs {
+		if e, ok := byID[id]; ok {
+			r.Entities = append(r.Entities, e)
+			ids = append(ids, id)
+		} else {
+			r.Missing = append(r.Missing, id)
+		}
+	}
+	if r.Title == "" {
+		r.Title = defaultTitle(r.Entities)
+	}
+
+	from, to, err := dateRange(p)
+	if err != nil {
+		return nil, err
+	}
+
+	var steps []func() error
+	if p.Has(SectionDocuments) {
+		steps = append(steps, func() (err error) {
+			r.Documents, err = documents(ctx, s, ids, from, to)
+			return err
+		})
+	}
+	if p.Has(SectionNetwork) {
+		steps = append(steps, func() (err error) {
+			r.Network, err = network(ctx, s, ids)
+			return err
+		})
+	}
+	if p.Has(SectionFinancials) {
+		steps = append(steps, func() (err error) {
+			r.Financials, err = financials(ctx, s, ids, p)
+			return err
+		})
+	}
+	if p.Has(SectionTimeline) {
+		steps = append(steps, func() (err error) {
+			r.Timeline, err = timeline(ctx, s, ids, from, to)
+			return err
+		})
+	}
+	for i, step := range steps {
+		if len(ids) == 0 {
+			break
+		}
+		if err := step(); err != nil {
+			return nil, err
+		}
+		if progress != nil {
+			if err := progress(i+1, len(steps)); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return r, nil
+}
+
+func defaultTitle(entities []models.EntityBrief) string {
+	switch len(entities) {
+	case 0:
+		return "Report"
+	case 1:
+		return "Report on " + entities[0].CanonicalName
+	case 2:
+		return "Report on " + entities[0].CanonicalName + " and " + entities[1].CanonicalName
+	}
+	return "Report on " + entities[0].CanonicalName + " and others"
+}
+
+// dateRange parses p's dates
+func dateRange(p Params) (from, to *time.Time, err error) {
+	parse := func(s *string) (*time.Time, error) {
+		if s == nil {
+			return nil, nil
+		}
+		t, err := time.Parse(time.DateOnly, *s)
+		return &t, err
+	}
+	if from, err = parse(p.From); err != nil {
+		return nil, nil, err
+	}
+	to, err = parse(p.To)
+	return from, to, err
+}
+
+// within reports whether a span of YYYY-MM-DD dates, either end of which
+// may be unknown, overlaps p's range. A wholly undated span is only within
+// an open range.
+func within(p Params, first, last *string) bool {
+	if p.From == nil && p.To == nil {
+		return true
+	}
+	if first == nil {
+		first = last
+	}
+	if last == nil {
+		last = first
+	}
+	if first == nil {
+		return false
+	}
+	if p.From != nil && *last < *p.From {
+		return false
+	}
+	return p.To == nil || *first <= *p.To
+}
+
+// documents lists the documents naming any of ids dated between from and
+// to, earliest first, merged so that each appears once with every entity
+// it names. The range is applied in the query, so that documentsPerEntity
+// counts only documents within it.
+func documents(ctx context.Context, s *store.Store, ids []int, from, to *time.Time) ([]Document, error) {
+	index := map[int]int{}
+	list := []Document{}
+	for _, id := range ids {
+		found, err := s.EntityDocuments(ctx, id, store.EntityDocumentFilter{From: from, To: to, Limit: documentsPerEntity})
+		if err != nil {
+			return nil, err
+		}
+		for _, d := range found {
+			if i, ok := index[d.ID]; ok {
+				list[i].EntityIDs = append(list[i].EntityIDs, id)
+				continue
+			}
+			index[d.ID] = len(list)
+			list = append(list, Document{DocumentSummary: d.DocumentSummary, EntityIDs: []int{id}})
+		}
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		a, b := list[i].DateEarliest, list[j].DateEarliest
+		if a == nil || b == nil {
+			return a != nil && b == nil
+		}
+		if *a != *b {
+			return *a < *b
+		}
+		return list[i].ID < list[j].ID
+	})
+	return list, nil
+}
+
+// datePrefix is the YYYY-MM-DD part of a date, which may carry a time
+func datePrefix(s *string) *string {
+	if s == nil || len(*s) <= len(time.DateOnly) {
+		return s
+	}
+	d := (*s)[:len(time.DateOnly)]
+	return &d
+}
+
+// network counts the documents ids share, the relationships between them
+// and their closest connections beyond
+func network(ctx context.Context, s *store.Store, ids []int) (*Network, error) {
+	m, err := s.CooccurrenceMatrix(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	n := &Network{Shared: m.Shared, Relations: m.Relations, Neighbours: []Neighbours{}}
+	for _, id := range ids {
+		connections, err := s.EntityConnections(ctx, id, store.GranularityDocument, store.Sort{}, connectionsPerEntity+len(ids))
+		if err != nil {
+			return nil, err
+		}
+		outside := []models.Connection{}
+		for _, c := range connections {
+			if !slices.Contains(ids, c.ID) && len(outside) < connectionsPerEntity {
+				outside = append(outside, c)
+			}
+		}
+		n.Neighbours = append(n.Neighbours, Neighbours{EntityID: id, Connections: outside})
+	}
+	return n, nil
+}
+
+// financials collects the flows to and from ids, confirmed or not, that
+// fall in p's range
+func financials(ctx context.Context, s *store.Store, ids []int, p Params) (*Financials, error) {
+	nodes, edges, err := s.FinancialNetwork(ctx, store.FinancialFilter{EntityIDs: ids, Limit: maxFlows})
+	if err != nil {
+		return nil, err
+	}
+	f := &Financials{Nodes: []models.FinancialNode{}, Edges: []models.FinancialEdge{}}
+	used := map[string]bool{}
+	for _, e := range edges {
+		if !within(p, e.First, e.Last) {
+			continue
+		}
+		f.Edges = append(f.Edges, e)
+		f.Total += e.Amount
+		used[e.Source], used[e.Target] = true, true
+	}
+	for _, n := range nodes {
+		if used[n.ID] {
+			f.Nodes = append(f.Nodes, n)
+		}
+	}
+	return f, nil
+}
+
+// timeline lists the events involving any of ids between from and to
+func timeline(ctx context.Context, s *store.Store, ids []int, from, to *time.Time) ([]models.TimelineEvent, error) {
+	events, err := s.Timeline(ctx, store.TimelineFilter{From: from, To: to, EntityIDs: ids, Limit: maxEvents})
+	if err != nil {
+		return nil, err
+	}
+	if events == nil {
+		events = []models.TimelineEvent{}
+	}
+	return events, nil
+}
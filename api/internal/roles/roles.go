@@ -0,0 +1,351 @@
+// Package roles infers the role each person and organization plays in a
+// document — witness, attorney, pilot and so on — from the text around
+// their mentions. A role is only kept when the model quotes a passage that
+// supports it and the passage is found in the document.
+package roles
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/subculture-collective/epstein-db/api/internal/fold"
+	"github.com/subculture-collective/epstein-db/api/internal/jobs"
+	"github.com/subculture-collective/epstein-db/api/internal/llm"
+)
+
+// JobKind is the jobs.kind for role inference jobs
+const JobKind = "roles"
+
+// Role is a part an entity plays in a document
+type Role struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// Roles are the roles inferred, as allowed by document_entities.role
+var Roles = []Role{
+	{"witness", "Testifies, is deposed or interviewed, or is named as a witness"},
+	{"attorney", "Represents a party, or acts as prosecutor, counsel or judge"},
+	{"victim_redacted", "A victim referred to by a redacted name or pseudonym such as Jane Doe; named people are never given this role"},
+	{"staff", "Works for a household, residence or business in the case, e.g. assistant, housekeeper or driver"},
+	{"business_associate", "Does business, invests or transacts with a party in the case"},
+	{"pilot", "Flies or crews an aircraft"},
+}
+
+// Names lists the roles by name
+func Names() []string {
+	names := make([]string, len(Roles))
+	for i, r := range Roles {
+		names[i] = r.Name
+	}
+	return names
+}
+
+const systemPrompt = `You classify the roles people and organizations play in documents related to the Jeffrey Epstein case.
+
+Use only the excerpts you are given. Give an entity a role only when an excerpt states it, and quote the exact passage that does. Never call a named person a victim: victim_redacted is only for victims the document refers to by a redacted name or pseudonym. Leave out entities whose role the excerpts don't make clear.`
+
+// ErrBudgetExhausted stops a run once its token budget is spent
+var ErrBudgetExhausted = errors.New("roles: token budget exhausted")
+
+// Params are the options accepted by a role inference job
+type Params struct {
+	DatasetID int  `json:"datasetId"`
+	Force     bool `json:"force"` // reclassify entities that already have been
+}
+
+// Config holds rate-limit and cost controls
+type Config struct {
+	RequestsPerMinute int
+	MaxTokens         int // total input+output tokens per run, 0 for unlimited
+	MaxEntities       int // per document, most mentioned first
+	ExcerptRadius     int // characters either side of an entity's first mention
+}
+
+// DefaultConfig returns conservative defaults
+func DefaultConfig() Config {
+	return Config{
+		RequestsPerMinute: 50,
+		MaxTokens:         2_000_000,
+		MaxEntities:       40,
+		ExcerptRadius:     400,
+	}
+}
+
+// Result is stored on the job when it finishes
+type Result struct {
+	Documents    int `json:"documents"`
+	Classified   int `json:"classified" doc:"Entities given a role"`
+	Unclassified int `json:"unclassified" doc:"Entities the text gave no role"`
+	Failed       int `json:"failed" doc:"Documents that couldn't be classified"`
+	InputTokens  int `json:"inputTokens"`
+	OutputTokens int `json:"outputTokens"`
+}
+
+// mention is an entity to classify in a document
+type mention struct {
+	id      int
+	name    string
+	excerpt string
+}
+
+type reply struct {
+	Roles []struct {
+		EntityID int    `json:"entityId"`
+		Role     string `json:"role"`
+		Quote    string `json:"quote"`
+	} `json:"roles"`
+}
+
+// Worker infers document_entities.role
+type Worker struct {
+	pool   *pgxpool.Pool
+	queue  *jobs.Queue
+	client llm.Client
+	cfg    Config
+}
+
+// NewWorker creates a role inference worker
+func NewWorker(pool *pgxpool.Pool, client llm.Client, cfg Config) *Worker {
+	return &Worker{
+		pool:   pool,
+		queue:  jobs.NewQueue(pool),
+		client: client,
+		cfg:    cfg,
+	}
+}
+
+// HandleJob processes a queued role inference job
+func (w *Worker) HandleJob(ctx context.Context, job *jobs.Job) (any, error) {
+	var params Params
+	if err := job.DecodeParams(&params); err != nil {
+		return nil, err
+	}
+
+	return w.Run(ctx, params, func(done, total int) {
+		if err := w.queue.SetProgress(ctx, job.ID, done, total); err != nil {
+			log.Printf("roles: job %d progress: %v", job.ID, err)
+		}
+	})
+}
+
+// Run classifies the entities of every matching document, calling
+// progress after each document
+func (w *Worker) Run(ctx context.Context, params Params, progress func(done, total int)) (Result, error) {
+	var result Result
+
+	ids, err := w.pending(ctx, params)
+	if err != nil {
+		return result, err
+	}
+
+	interval := time.Minute / time.Duration(max(w.cfg.RequestsPerMinute, 1))
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for i, id := range ids {
+		if w.cfg.MaxTokens > 0 && result.InputTokens+result.OutputTokens >= w.cfg.MaxTokens {
+			return result, ErrBudgetExhausted
+		}
+
+		select {
+		case <-ctx.Done():
+			return result, ctx.Err()
+		case <-ticker.C:
+		}
+
+		r, err := w.classifyDocument(ctx, id, params.Force)
+		result.InputTokens += r.InputTokens
+		result.OutputTokens += r.OutputTokens
+		if err != nil {
+			log.Printf("roles: document %d: %v", id, err)
+			result.Failed++
+		} else {
+			result.Documents++
+			result.Classified += r.Classified
+			result.Unclassified += r.Unclassified
+		}
+
+		if progress != nil {
+			progress(i+1, len(ids))
+		}
+	}
+
+	return result, nil
+}
+
+// pending returns the documents with people or organizations to classify
+func (w *Worker) pending(ctx context.Context, params Params) ([]int, error) {
+	rows, err := w.pool.Query(ctx, `
+		SELECT DISTINCT d.id
+		FROM documents d
+		JOIN document_entities de ON de.document_id = d.id
+		JOIN entities e ON e.id = de.entity_id AND e.entity_type IN ('person', 'organization')
+		WHERE ($1 = 0 OR d.dataset_id = $1)
+		  AND d.full_text IS NOT NULL AND d.full_text != ''
+		  AND ($2 OR de.role_classified_at IS NULL)
+		ORDER BY d.id
+	`, params.DatasetID, params.Force)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+// classifyDocument asks the model for the roles of a document's entities
+// and stores them, with NULL for those it gave none
+func (w *Worker) classifyDocument(ctx context.Context, id int, force bool) (Result, error) {
+	var result Result
+
+	var docID, text string
+	err := w.pool.QueryRow(ctx, `SELECT doc_id, full_text FROM documents WHERE id = $1`, id).Scan(&docID, &text)
+	if err != nil {
+		return result, err
+	}
+
+	rows, err := w.pool.Query(ctx, `
+		SELECT e.id, e.canonical_name,
+			   COALESCE((SELECT array_agg(a) FROM jsonb_array_elements_text(e.aliases) a), '{}'),
+			   de.context_snippet
+		FROM document_entities de
+		JOIN entities e ON e.id = de.entity_id AND e.entity_type IN ('person', 'organization')
+		WHERE de.document_id = $1 AND ($2 OR de.role_classified_at IS NULL)
+		ORDER BY de.mention_count DESC NULLS LAST, e.id
+		LIMIT $3
+	`, id, force, w.cfg.MaxEntities)
+	if err != nil {
+		return result, err
+	}
+	var mentions []mention
+	for rows.Next() {
+		var m mention
+		var aliases []string
+		var snippet *string
+		if err := rows.Scan(&m.id, &m.name, &aliases, &snippet); err != nil {
+			rows.Close()
+			return result, err
+		}
+		m.excerpt = excerpt(text, append([]string{m.name}, aliases...), w.cfg.ExcerptRadius)
+		if m.excerpt == "" && snippet != nil {
+			m.excerpt = *snippet
+		}
+		mentions = append(mentions, m)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return result, err
+	}
+	if len(mentions) == 0 {
+		return result, nil
+	}
+
+	var b strings.Builder
+	for _, m := range mentions {
+		fmt.Fprintf(&b, "<entity id=\"%d\" name=%q>\n%s\n</entity>\n\n", m.id, m.name, m.excerpt)
+	}
+	var roles strings.Builder
+	for _, r := range Roles {
+		fmt.Fprintf(&roles, "- %s: %s\n", r.Name, r.Description)
+	}
+	prompt := fmt.Sprintf(`Classify the roles of the entities in document %s. Each excerpt is the text around the entity's first mention.
+
+%s
+Roles:
+%s
+Respond with a JSON object:
+{
+  "roles": [{"entityId": 12, "role": "witness", "quote": "exact passage from the excerpt showing the role"}]
+}
+
+Return ONLY valid JSON.`, docID, b.String(), roles.String())
+
+	resp, err := w.client.Complete(ctx, llm.UserPrompt(systemPrompt, prompt, 2048))
+	if err != nil {
+		return result, err
+	}
+	result.InputTokens, result.OutputTokens = resp.InputTokens, resp.OutputTokens
+
+	var r reply
+	if err := llm.DecodeJSON(resp.Text, &r); err != nil {
+		return result, err
+	}
+
+	// Keep the roles that are allowed, for an entity asked about, with a
+	// quote found in the document
+	type role struct{ name, quote string }
+	found := map[int]role{}
+	names := Names()
+	for _, x := range r.Roles {
+		quote := strings.TrimSpace(x.Quote)
+		if !slices.Contains(names, x.Role) || quote == "" || !strings.Contains(text, quote) {
+			continue
+		}
+		if !slices.ContainsFunc(mentions, func(m mention) bool { return m.id == x.EntityID }) {
+			continue
+		}
+		if _, ok := found[x.EntityID]; !ok {
+			found[x.EntityID] = role{x.Role, quote}
+		}
+	}
+
+	err = pgx.BeginFunc(ctx, w.pool, func(tx pgx.Tx) error {
+		for _, m := range mentions {
+			var name, quote *string
+			if r, ok := found[m.id]; ok {
+				name, quote = &r.name, &r.quote
+				result.Classified++
+			} else {
+				result.Unclassified++
+			}
+			_, err := tx.Exec(ctx, `
+				UPDATE document_entities
+				SET role = $3, role_quote = $4, role_model = $5, role_classified_at = NOW()
+				WHERE document_id = $1 AND entity_id = $2
+			`, id, m.id, name, quote, resp.Model)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return result, err
+}
+
+// excerpt cuts a window of radius bytes either side of the first
+// case-insensitive match of any of names, or returns "" if none is found
+func excerpt(text string, names []string, radius int) string {
+	at, length := -1, 0
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if i, j := fold.Index(text, name); i >= 0 && (at < 0 || i < at) {
+			at, length = i, j-i
+		}
+	}
+	if at < 0 {
+		return ""
+	}
+	start, end := max(at-radius, 0), min(at+length+radius, len(text))
+	return strings.ToValidUTF8(text[start:end], "")
+}
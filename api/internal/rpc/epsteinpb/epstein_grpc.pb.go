@@ -0,0 +1,466 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.4.0
+// - protoc             v5.27.1
+// source: epstein.proto
+
+// Programmatic access to the Epstein Files database for data-science
+// pipelines and the pattern agent. Mirrors the REST API's read endpoints and
+// adds streaming exports for bulk consumers.
+
+package epsteinpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.62.0 or later.
+const _ = grpc.SupportPackageIsVersion8
+
+const (
+	Epstein_SearchEntities_FullMethodName  = "/epstein.v1.Epstein/SearchEntities"
+	Epstein_GetEntity_FullMethodName       = "/epstein.v1.Epstein/GetEntity"
+	Epstein_GetConnections_FullMethodName  = "/epstein.v1.Epstein/GetConnections"
+	Epstein_GetDocument_FullMethodName     = "/epstein.v1.Epstein/GetDocument"
+	Epstein_GetNetwork_FullMethodName      = "/epstein.v1.Epstein/GetNetwork"
+	Epstein_ExportEntities_FullMethodName  = "/epstein.v1.Epstein/ExportEntities"
+	Epstein_ExportDocuments_FullMethodName = "/epstein.v1.Epstein/ExportDocuments"
+	Epstein_ExportTriples_FullMethodName   = "/epstein.v1.Epstein/ExportTriples"
+)
+
+// EpsteinClient is the client API for Epstein service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type EpsteinClient interface {
+	SearchEntities(ctx context.Context, in *SearchEntitiesRequest, opts ...grpc.CallOption) (*SearchEntitiesResponse, error)
+	GetEntity(ctx context.Context, in *GetEntityRequest, opts ...grpc.CallOption) (*Entity, error)
+	GetConnections(ctx context.Context, in *GetConnectionsRequest, opts ...grpc.CallOption) (*GetConnectionsResponse, error)
+	GetDocument(ctx context.Context, in *GetDocumentRequest, opts ...grpc.CallOption) (*Document, error)
+	GetNetwork(ctx context.Context, in *GetNetworkRequest, opts ...grpc.CallOption) (*Network, error)
+	// Exports stream every matching row in ID order. Resume an interrupted
+	// export by passing the last ID received as after_id.
+	ExportEntities(ctx context.Context, in *ExportRequest, opts ...grpc.CallOption) (Epstein_ExportEntitiesClient, error)
+	ExportDocuments(ctx context.Context, in *ExportRequest, opts ...grpc.CallOption) (Epstein_ExportDocumentsClient, error)
+	ExportTriples(ctx context.Context, in *ExportRequest, opts ...grpc.CallOption) (Epstein_ExportTriplesClient, error)
+}
+
+type epsteinClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewEpsteinClient(cc grpc.ClientConnInterface) EpsteinClient {
+	return &epsteinClient{cc}
+}
+
+func (c *epsteinClient) SearchEntities(ctx context.Context, in *SearchEntitiesRequest, opts ...grpc.CallOption) (*SearchEntitiesResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SearchEntitiesResponse)
+	err := c.cc.Invoke(ctx, Epstein_SearchEntities_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *epsteinClient) GetEntity(ctx context.Context, in *GetEntityRequest, opts ...grpc.CallOption) (*Entity, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Entity)
+	err := c.cc.Invoke(ctx, Epstein_GetEntity_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *epsteinClient) GetConnections(ctx context.Context, in *GetConnectionsRequest, opts ...grpc.CallOption) (*GetConnectionsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetConnectionsResponse)
+	err := c.cc.Invoke(ctx, Epstein_GetConnections_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *epsteinClient) GetDocument(ctx context.Context, in *GetDocumentRequest, opts ...grpc.CallOption) (*Document, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Document)
+	err := c.cc.Invoke(ctx, Epstein_GetDocument_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *epsteinClient) GetNetwork(ctx context.Context, in *GetNetworkRequest, opts ...grpc.CallOption) (*Network, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Network)
+	err := c.cc.Invoke(ctx, Epstein_GetNetwork_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *epsteinClient) ExportEntities(ctx context.Context, in *ExportRequest, opts ...grpc.CallOption) (Epstein_ExportEntitiesClient, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Epstein_ServiceDesc.Streams[0], Epstein_ExportEntities_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &epsteinExportEntitiesClient{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Epstein_ExportEntitiesClient interface {
+	Recv() (*Entity, error)
+	grpc.ClientStream
+}
+
+type epsteinExportEntitiesClient struct {
+	grpc.ClientStream
+}
+
+func (x *epsteinExportEntitiesClient) Recv() (*Entity, error) {
+	m := new(Entity)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *epsteinClient) ExportDocuments(ctx context.Context, in *ExportRequest, opts ...grpc.CallOption) (Epstein_ExportDocumentsClient, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Epstein_ServiceDesc.Streams[1], Epstein_ExportDocuments_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &epsteinExportDocumentsClient{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Epstein_ExportDocumentsClient interface {
+	Recv() (*Document, error)
+	grpc.ClientStream
+}
+
+type epsteinExportDocumentsClient struct {
+	grpc.ClientStream
+}
+
+func (x *epsteinExportDocumentsClient) Recv() (*Document, error) {
+	m := new(Document)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *epsteinClient) ExportTriples(ctx context.Context, in *ExportRequest, opts ...grpc.CallOption) (Epstein_ExportTriplesClient, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Epstein_ServiceDesc.Streams[2], Epstein_ExportTriples_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &epsteinExportTriplesClient{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Epstein_ExportTriplesClient interface {
+	Recv() (*Triple, error)
+	grpc.ClientStream
+}
+
+type epsteinExportTriplesClient struct {
+	grpc.ClientStream
+}
+
+func (x *epsteinExportTriplesClient) Recv() (*Triple, error) {
+	m := new(Triple)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// EpsteinServer is the server API for Epstein service.
+// All implementations must embed UnimplementedEpsteinServer
+// for forward compatibility
+type EpsteinServer interface {
+	SearchEntities(context.Context, *SearchEntitiesRequest) (*SearchEntitiesResponse, error)
+	GetEntity(context.Context, *GetEntityRequest) (*Entity, error)
+	GetConnections(context.Context, *GetConnectionsRequest) (*GetConnectionsResponse, error)
+	GetDocument(context.Context, *GetDocumentRequest) (*Document, error)
+	GetNetwork(context.Context, *GetNetworkRequest) (*Network, error)
+	// Exports stream every matching row in ID order. Resume an interrupted
+	// export by passing the last ID received as after_id.
+	ExportEntities(*ExportRequest, Epstein_ExportEntitiesServer) error
+	ExportDocuments(*ExportRequest, Epstein_ExportDocumentsServer) error
+	ExportTriples(*ExportRequest, Epstein_ExportTriplesServer) error
+	mustEmbedUnimplementedEpsteinServer()
+}
+
+// UnimplementedEpsteinServer must be embedded to have forward compatible implementations.
+type UnimplementedEpsteinServer struct {
+}
+
+func (UnimplementedEpsteinServer) SearchEntities(context.Context, *SearchEntitiesRequest) (*SearchEntitiesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SearchEntities not implemented")
+}
+func (UnimplementedEpsteinServer) GetEntity(context.Context, *GetEntityRequest) (*Entity, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetEntity not implemented")
+}
+func (UnimplementedEpsteinServer) GetConnections(context.Context, *GetConnectionsRequest) (*GetConnectionsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetConnections not implemented")
+}
+func (UnimplementedEpsteinServer) GetDocument(context.Context, *GetDocumentRequest) (*Document, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetDocument not implemented")
+}
+func (UnimplementedEpsteinServer) GetNetwork(context.Context, *GetNetworkRequest) (*Network, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetNetwork not implemented")
+}
+func (UnimplementedEpsteinServer) ExportEntities(*ExportRequest, Epstein_ExportEntitiesServer) error {
+	return status.Errorf(codes.Unimplemented, "method ExportEntities not implemented")
+}
+func (UnimplementedEpsteinServer) ExportDocuments(*ExportRequest, Epstein_ExportDocumentsServer) error {
+	return status.Errorf(codes.Unimplemented, "method ExportDocuments not implemented")
+}
+func (UnimplementedEpsteinServer) ExportTriples(*ExportRequest, Epstein_ExportTriplesServer) error {
+	return status.Errorf(codes.Unimplemented, "method ExportTriples not implemented")
+}
+func (UnimplementedEpsteinServer) mustEmbedUnimplementedEpsteinServer() {}
+
+// UnsafeEpsteinServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to EpsteinServer will
+// result in compilation errors.
+type UnsafeEpsteinServer interface {
+	mustEmbedUnimplementedEpsteinServer()
+}
+
+func RegisterEpsteinServer(s grpc.ServiceRegistrar, srv EpsteinServer) {
+	s.RegisterService(&Epstein_ServiceDesc, srv)
+}
+
+func _Epstein_SearchEntities_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SearchEntitiesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EpsteinServer).SearchEntities(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Epstein_SearchEntities_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EpsteinServer).SearchEntities(ctx, req.(*SearchEntitiesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Epstein_GetEntity_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetEntityRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EpsteinServer).GetEntity(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Epstein_GetEntity_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EpsteinServer).GetEntity(ctx, req.(*GetEntityRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Epstein_GetConnections_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetConnectionsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EpsteinServer).GetConnections(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Epstein_GetConnections_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EpsteinServer).GetConnections(ctx, req.(*GetConnectionsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Epstein_GetDocument_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetDocumentRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EpsteinServer).GetDocument(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Epstein_GetDocument_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EpsteinServer).GetDocument(ctx, req.(*GetDocumentRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Epstein_GetNetwork_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetNetworkRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EpsteinServer).GetNetwork(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Epstein_GetNetwork_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EpsteinServer).GetNetwork(ctx, req.(*GetNetworkRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Epstein_ExportEntities_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ExportRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(EpsteinServer).ExportEntities(m, &epsteinExportEntitiesServer{ServerStream: stream})
+}
+
+type Epstein_ExportEntitiesServer interface {
+	Send(*Entity) error
+	grpc.ServerStream
+}
+
+type epsteinExportEntitiesServer struct {
+	grpc.ServerStream
+}
+
+func (x *epsteinExportEntitiesServer) Send(m *Entity) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Epstein_ExportDocuments_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ExportRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(EpsteinServer).ExportDocuments(m, &epsteinExportDocumentsServer{ServerStream: stream})
+}
+
+type Epstein_ExportDocumentsServer interface {
+	Send(*Document) error
+	grpc.ServerStream
+}
+
+type epsteinExportDocumentsServer struct {
+	grpc.ServerStream
+}
+
+func (x *epsteinExportDocumentsServer) Send(m *Document) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Epstein_ExportTriples_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ExportRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(EpsteinServer).ExportTriples(m, &epsteinExportTriplesServer{ServerStream: stream})
+}
+
+type Epstein_ExportTriplesServer interface {
+	Send(*Triple) error
+	grpc.ServerStream
+}
+
+type epsteinExportTriplesServer struct {
+	grpc.ServerStream
+}
+
+func (x *epsteinExportTriplesServer) Send(m *Triple) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// Epstein_ServiceDesc is the grpc.ServiceDesc for Epstein service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Epstein_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "epstein.v1.Epstein",
+	HandlerType: (*EpsteinServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "SearchEntities",
+			Handler:    _Epstein_SearchEntities_Handler,
+		},
+		{
+			MethodName: "GetEntity",
+			Handler:    _Epstein_GetEntity_Handler,
+		},
+		{
+			MethodName: "GetConnections",
+			Handler:    _Epstein_GetConnections_Handler,
+		},
+		{
+			MethodName: "GetDocument",
+			Handler:    _Epstein_GetDocument_Handler,
+		},
+		{
+			MethodName: "GetNetwork",
+			Handler:    _Epstein_GetNetwork_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "ExportEntities",
+			Handler:       _Epstein_ExportEntities_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "ExportDocuments",
+			Handler:       _Epstein_ExportDocuments_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "ExportTriples",
+			Handler:       _Epstein_ExportTriples_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "epstein.proto",
+}
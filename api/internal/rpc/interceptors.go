@@ -0,0 +1,115 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/subculture-collective/epstein-db/api/internal/auth"
+	"github.com/subculture-collective/epstein-db/api/internal/ratelimit"
+)
+
+// Config controls what the gRPC server lets callers do
+type Config struct {
+	// Timeout bounds a unary call, and each page of a streamed export,
+	// which as a whole may run for as long as the client keeps reading
+	Timeout time.Duration
+	// PerMinute is each API key's budget of calls; 0 disables limiting
+	PerMinute int
+}
+
+// guard authenticates and limits calls, and keeps internal errors from
+// reaching callers
+type guard struct {
+	auth    *auth.Authenticator
+	limiter *ratelimit.Limiter
+	cfg     Config
+}
+
+// admit checks a call's API key, sent as x-api-key or as a bearer token in
+// authorization metadata, against the caller's budget. Every call needs a
+// key: the exports can read the whole database.
+func (g *guard) admit(ctx context.Context) error {
+	md, _ := metadata.FromIncomingContext(ctx)
+	key := first(md, "x-api-key")
+	if key == "" {
+		key, _ = strings.CutPrefix(first(md, "authorization"), "Bearer ")
+		key = strings.TrimSpace(key)
+	}
+	if key == "" {
+		return status.Error(codes.Unauthenticated, "API key required")
+	}
+
+	p, err := g.auth.Authenticate(ctx, key)
+	if errors.Is(err, auth.ErrInvalidKey) {
+		return status.Error(codes.Unauthenticated, "invalid API key")
+	}
+	if err != nil {
+		return err
+	}
+
+	if g.cfg.PerMinute > 0 {
+		res := g.limiter.Allow("key:"+strconv.Itoa(p.KeyID), g.cfg.PerMinute, time.Now())
+		if !res.Allowed {
+			return status.Errorf(codes.ResourceExhausted, "rate limit exceeded, retry in %s", res.RetryIn.Round(time.Second))
+		}
+	}
+	return nil
+}
+
+func first(md metadata.MD, key string) string {
+	if v := md.Get(key); len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
+func (g *guard) unary(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if err := g.admit(ctx); err != nil {
+		return nil, toStatus(info.FullMethod, err)
+	}
+	if g.cfg.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, g.cfg.Timeout)
+		defer cancel()
+	}
+	resp, err := handler(ctx, req)
+	return resp, toStatus(info.FullMethod, err)
+}
+
+func (g *guard) stream(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := g.admit(ss.Context()); err != nil {
+		return toStatus(info.FullMethod, err)
+	}
+	return toStatus(info.FullMethod, handler(srv, ss))
+}
+
+// toStatus passes on errors that are already gRPC statuses and replaces the
+// rest, such as database errors, with a generic status, logging the detail
+func toStatus(method string, err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+
+	var pgErr *pgconn.PgError
+	switch {
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &pgErr) && pgErr.Code == "57014":
+		return status.Error(codes.DeadlineExceeded, "call timed out")
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, "call cancelled")
+	}
+	log.Printf("grpc %s: %v", method, err)
+	return status.Error(codes.Internal, "internal error")
+}
@@ -0,0 +1,408 @@
+// Package rpc serves the gRPC API defined in proto/epstein.proto
+package rpc
+
+//go:generate protoc -I proto --go_out=epsteinpb --go_opt=paths=source_relative --go-grpc_out=epsteinpb --go-grpc_opt=paths=source_relative proto/epstein.proto
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+
+	"github.com/subculture-collective/epstein-db/api/internal/auth"
+	"github.com/subculture-collective/epstein-db/api/internal/ratelimit"
+	pb "github.com/subculture-collective/epstein-db/api/internal/rpc/epsteinpb"
+	"github.com/subculture-collective/epstein-db/api/internal/store"
+)
+
+// exportPageSize is how many rows an export reads per query. Paging keeps
+// slow consumers from pinning a pool connection for the whole stream.
+const exportPageSize = 1000
+
+// Server implements the Epstein gRPC service
+type Server struct {
+	pb.UnimplementedEpsteinServer
+	pool    *pgxpool.Pool
+	timeout time.Duration
+}
+
+// NewServer creates a gRPC server with the Epstein service registered.
+// Every call must carry an API key that authenticator accepts.
+func NewServer(pool *pgxpool.Pool, authenticator *auth.Authenticator, cfg Config) *grpc.Server {
+	g := &guard{auth: authenticator, limiter: ratelimit.New(), cfg: cfg}
+	s := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(g.unary),
+		grpc.ChainStreamInterceptor(g.stream),
+	)
+	pb.RegisterEpsteinServer(s, &Server{pool: pool, timeout: cfg.Timeout})
+	// Lets grpcurl and similar tools discover the service
+	reflection.Register(s)
+	return s
+}
+
+const entityColumns = `id, canonical_name, entity_type::text, layer, description,
+	COALESCE(document_count, 0), COALESCE(connection_count, 0),
+	COALESCE(ARRAY(SELECT jsonb_array_elements_text(aliases)), '{}')`
+
+func scanEntity(row pgx.Row) (*pb.Entity, error) {
+	var e pb.Entity
+	err := row.Scan(&e.Id, &e.CanonicalName, &e.EntityType, &e.Layer, &e.Description,
+		&e.DocumentCount, &e.ConnectionCount, &e.Aliases)
+	if err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// documentColumns selects a document, with its text when $1 is true. gRPC
+// callers are anonymous, so documents of restricted datasets come with
+// their metadata only. Protected entities are left out of its entity IDs.
+const documentColumns = `d.id, d.doc_id, d.dataset_id, d.document_type,
+	CASE WHEN NOT dataset_restricted(d.dataset_id) THEN d.summary END,
+	CASE WHEN NOT dataset_restricted(d.dataset_id) THEN d.detailed_summary END,
+	d.date_earliest::text, d.date_latest::text, d.page_count,
+	CASE WHEN $1 AND NOT dataset_restricted(d.dataset_id) THEN d.full_text END,
+	ARRAY(SELECT de.entity_id FROM document_entities de
+		JOIN entities e ON e.id = de.entity_id AND NOT e.protected
+		WHERE de.document_id = d.id ORDER BY de.entity_id),
+	sensitivity_labels(d.id)`
+
+// scanDocument reads documentColumns, masking protected names in the
+// summaries and text
+func scanDocument(m *store.Masker, row pgx.Row) (*pb.Document, error) {
+	var d pb.Document
+	err := row.Scan(&d.Id, &d.DocId, &d.DatasetId, &d.DocumentType, &d.Summary, &d.DetailedSummary,
+		&d.DateEarliest, &d.DateLatest, &d.PageCount, &d.FullText, &d.EntityIds, &d.Sensitivity)
+	if err != nil {
+		return nil, err
+	}
+	m.MaskPtr(d.Summary)
+	m.MaskPtr(d.DetailedSummary)
+	m.MaskPtr(d.FullText)
+	return &d, nil
+}
+
+// limit applies a default and a cap to a requested page size
+func limit(requested, def, max int32) int32 {
+	if requested <= 0 {
+		return def
+	}
+	if requested > max {
+		return max
+	}
+	return requested
+}
+
+// SearchEntities searches entities by name
+func (s *Server) SearchEntities(ctx context.Context, req *pb.SearchEntitiesRequest) (*pb.SearchEntitiesResponse, error) {
+	if req.EntityType != "" && !slices.Contains(store.EntityTypes, req.EntityType) {
+		return nil, status.Errorf(codes.InvalidArgument, "entity_type must be one of %s", strings.Join(store.EntityTypes, ", "))
+	}
+	rows, err := s.pool.Query(ctx, `
+		SELECT `+entityColumns+`
+		FROM entities
+		WHERE ($1 = '' OR canonical_name ILIKE $5 OR canonical_name % $1)
+		  AND ($2 = '' OR entity_type = $2::entity_type)
+		  AND ($3::int IS NULL OR layer = $3)
+		  AND deleted_at IS NULL AND NOT protected
+		ORDER BY
+			CASE WHEN $1 != '' THEN similarity(canonical_name, $1) ELSE 0 END DESC,
+			document_count DESC
+		LIMIT $4
+	`, req.Query, req.EntityType, req.Layer, limit(req.Limit, 20, 100), store.Contains(req.Query))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	resp := &pb.SearchEntitiesResponse{}
+	for rows.Next() {
+		e, err := scanEntity(rows)
+		if err != nil {
+			return nil, err
+		}
+		resp.Entities = append(resp.Entities, e)
+	}
+	return resp, rows.Err()
+}
+
+// GetEntity returns a single entity
+func (s *Server) GetEntity(ctx context.Context, req *pb.GetEntityRequest) (*pb.Entity, error) {
+	e, err := scanEntity(s.pool.QueryRow(ctx, `SELECT `+entityColumns+` FROM entities WHERE id = $1 AND deleted_at IS NULL AND NOT protected`, req.Id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, status.Error(codes.NotFound, "entity not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// GetConnections returns the entities that share the most documents with
+// an entity
+func (s *Server) GetConnections(ctx context.Context, req *pb.GetConnectionsRequest) (*pb.GetConnectionsResponse, error) {
+	rows, err := s.pool.Query(ctx, `
+		SELECT `+entityColumns+`, c.shared_docs
+		FROM (
+			SELECT de2.entity_id, COUNT(DISTINCT de1.document_id) AS shared_docs
+			FROM document_entities de1
+			JOIN document_entities de2 ON de1.document_id = de2.document_id AND de1.entity_id != de2.entity_id
+			WHERE de1.entity_id = $1
+			  AND de2.entity_id NOT IN (SELECT id FROM entities WHERE protected)
+			  AND NOT EXISTS (SELECT 1 FROM entities e WHERE e.id = $1 AND e.deleted_at IS NOT NULL)
+			GROUP BY de2.entity_id
+			ORDER BY shared_docs DESC
+			LIMIT $2
+		) c
+		JOIN entities ON entities.id = c.entity_id
+		ORDER BY c.shared_docs DESC
+	`, req.EntityId, limit(req.Limit, 50, 200))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	resp := &pb.GetConnectionsResponse{}
+	for rows.Next() {
+		var e pb.Entity
+		var shared int32
+		if err := rows.Scan(&e.Id, &e.CanonicalName, &e.EntityType, &e.Layer, &e.Description,
+			&e.DocumentCount, &e.ConnectionCount, &e.Aliases, &shared); err != nil {
+			return nil, err
+		}
+		resp.Connections = append(resp.Connections, &pb.Connection{Entity: &e, SharedDocs: shared})
+	}
+	return resp, rows.Err()
+}
+
+// GetDocument returns a single document, optionally with its full text
+func (s *Server) GetDocument(ctx context.Context, req *pb.GetDocumentRequest) (*pb.Document, error) {
+	m, err := store.LoadMasker(ctx, s.pool)
+	if err != nil {
+		return nil, err
+	}
+	d, err := scanDocument(m, s.pool.QueryRow(ctx, `
+		SELECT `+documentColumns+` FROM documents d WHERE d.id = $2 AND d.deleted_at IS NULL
+	`, req.IncludeText, req.Id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, status.Error(codes.NotFound, "document not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// GetNetwork returns the person/organization co-occurrence graph
+func (s *Server) GetNetwork(ctx context.Context, req *pb.GetNetworkRequest) (*pb.Network, error) {
+	minConn := req.MinConnections
+	if minConn <= 0 {
+		minConn = 2
+	}
+	maxNodes := limit(req.Limit, 1000, 10000)
+
+	rows, err := s.pool.Query(ctx, `
+		SELECT `+entityColumns+`
+		FROM entities
+		WHERE entity_type IN ('person', 'organization')
+		  AND connection_count >= $1
+		  AND deleted_at IS NULL AND NOT protected
+		ORDER BY connection_count DESC
+		LIMIT $2
+	`, minConn, maxNodes)
+	if err != nil {
+		return nil, err
+	}
+
+	network := &pb.Network{}
+	inGraph := make(map[int32]bool)
+	for rows.Next() {
+		e, err := scanEntity(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		inGraph[e.Id] = true
+		network.Nodes = append(network.Nodes, e)
+	}
+	rows.Close()
+
+	edgeRows, err := s.pool.Query(ctx, `
+		SELECT de1.entity_id, de2.entity_id, COUNT(DISTINCT de1.document_id) AS weight
+		FROM document_entities de1
+		JOIN document_entities de2 ON de1.document_id = de2.document_id
+			AND de1.entity_id < de2.entity_id
+		JOIN entities e1 ON de1.entity_id = e1.id
+		JOIN entities e2 ON de2.entity_id = e2.id
+		WHERE e1.entity_type IN ('person', 'organization')
+		  AND e2.entity_type IN ('person', 'organization')
+		  AND e1.connection_count >= $1
+		  AND e2.connection_count >= $1
+		  AND e1.deleted_at IS NULL AND e2.deleted_at IS NULL
+		  AND NOT e1.protected AND NOT e2.protected
+		GROUP BY de1.entity_id, de2.entity_id
+		HAVING COUNT(DISTINCT de1.document_id) >= 2
+		ORDER BY weight DESC
+		LIMIT $2
+	`, minConn, maxNodes*3)
+	if err != nil {
+		return nil, err
+	}
+	defer edgeRows.Close()
+
+	for edgeRows.Next() {
+		var e pb.Edge
+		if err := edgeRows.Scan(&e.Source, &e.Target, &e.Weight); err != nil {
+			return nil, err
+		}
+		if inGraph[e.Source] && inGraph[e.Target] {
+			network.Edges = append(network.Edges, &e)
+		}
+	}
+	return network, edgeRows.Err()
+}
+
+// withTimeout bounds ctx by timeout, if there is one
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// export pages through rows in ID order, sending each one, until a page
+// comes back short. Each page has timeout to be read in; sending isn't
+// bounded, so a slow consumer can still export everything.
+func export[T any](ctx context.Context, timeout time.Duration, afterID int32, page func(ctx context.Context, after int32) ([]T, int32, error), send func(T) error) error {
+	for {
+		pageCtx, cancel := withTimeout(ctx, timeout)
+		items, last, err := page(pageCtx, afterID)
+		cancel()
+		if err != nil {
+			return err
+		}
+		for _, item := range items {
+			if err := send(item); err != nil {
+				return err
+			}
+		}
+		if len(items) < exportPageSize {
+			return nil
+		}
+		afterID = last
+	}
+}
+
+// ExportEntities streams every entity
+func (s *Server) ExportEntities(req *pb.ExportRequest, stream pb.Epstein_ExportEntitiesServer) error {
+	return export(stream.Context(), s.timeout, req.AfterId, func(ctx context.Context, after int32) ([]*pb.Entity, int32, error) {
+		rows, err := s.pool.Query(ctx, `
+			SELECT `+entityColumns+` FROM entities WHERE id > $1 AND deleted_at IS NULL AND NOT protected ORDER BY id LIMIT $2
+		`, after, exportPageSize)
+		if err != nil {
+			return nil, 0, err
+		}
+		defer rows.Close()
+
+		var entities []*pb.Entity
+		for rows.Next() {
+			e, err := scanEntity(rows)
+			if err != nil {
+				return nil, 0, err
+			}
+			entities = append(entities, e)
+			after = e.Id
+		}
+		return entities, after, rows.Err()
+	}, stream.Send)
+}
+
+// ExportDocuments streams every document, optionally restricted to a
+// dataset and to documents without content warnings
+func (s *Server) ExportDocuments(req *pb.ExportRequest, stream pb.Epstein_ExportDocumentsServer) error {
+	ctx, cancel := withTimeout(stream.Context(), s.timeout)
+	m, err := store.LoadMasker(ctx, s.pool)
+	cancel()
+	if err != nil {
+		return err
+	}
+	return export(stream.Context(), s.timeout, req.AfterId, func(ctx context.Context, after int32) ([]*pb.Document, int32, error) {
+		rows, err := s.pool.Query(ctx, `
+			SELECT `+documentColumns+`
+			FROM documents d
+			WHERE d.id > $2 AND ($3 = 0 OR d.dataset_id = $3) AND d.deleted_at IS NULL
+			  AND `+store.Insensitive("d", "$5")+`
+			ORDER BY d.id
+			LIMIT $4
+		`, req.IncludeText, after, req.DatasetId, exportPageSize, req.ExcludeSensitive)
+		if err != nil {
+			return nil, 0, err
+		}
+		defer rows.Close()
+
+		var documents []*pb.Document
+		for rows.Next() {
+			d, err := scanDocument(m, rows)
+			if err != nil {
+				return nil, 0, err
+			}
+			documents = append(documents, d)
+			after = d.Id
+		}
+		return documents, after, rows.Err()
+	}, stream.Send)
+}
+
+// ExportTriples streams every triple, optionally restricted to a dataset
+// and to documents without content warnings. An export is a bulk copy, so
+// sentences from documents of restricted datasets are left out whatever
+// the caller's key.
+func (s *Server) ExportTriples(req *pb.ExportRequest, stream pb.Epstein_ExportTriplesServer) error {
+	ctx, cancel := withTimeout(stream.Context(), s.timeout)
+	m, err := store.LoadMasker(ctx, s.pool)
+	cancel()
+	if err != nil {
+		return err
+	}
+	return export(stream.Context(), s.timeout, req.AfterId, func(ctx context.Context, after int32) ([]*pb.Triple, int32, error) {
+		rows, err := s.pool.Query(ctx, `
+			SELECT t.id, t.document_id, t.subject_id, t.predicate, t.object_id,
+				   t.confidence, CASE WHEN NOT dataset_restricted(d.dataset_id) THEN t.sentence END, t.extraction_method
+			FROM triples t
+			JOIN documents d ON d.id = t.document_id
+			WHERE t.id > $1 AND ($2 = 0 OR d.dataset_id = $2) AND d.deleted_at IS NULL
+			  AND `+store.Insensitive("d", "$4")+`
+			  AND NOT EXISTS (
+				  SELECT 1 FROM entities e
+				  WHERE e.id IN (t.subject_id, t.object_id) AND (e.protected OR e.deleted_at IS NOT NULL)
+			  )
+			ORDER BY t.id
+			LIMIT $3
+		`, after, req.DatasetId, exportPageSize, req.ExcludeSensitive)
+		if err != nil {
+			return nil, 0, err
+		}
+		defer rows.Close()
+
+		var triples []*pb.Triple
+		for rows.Next() {
+			var t pb.Triple
+			if err := rows.Scan(&t.Id, &t.DocumentId, &t.SubjectId, &t.Predicate, &t.ObjectId,
+				&t.Confidence, &t.Sentence, &t.ExtractionMethod); err != nil {
+				return nil, 0, err
+			}
+			m.MaskPtr(t.Sentence)
+			triples = append(triples, &t)
+			after = t.Id
+		}
+		return triples, after, rows.Err()
+	}, stream.Send)
+}
@@ -0,0 +1,89 @@
+package scheduler
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// Task is a unit of scheduled work
+type Task func(ctx context.Context) error
+
+type entry struct {
+	name string
+	next func(now time.Time) time.Time
+	task Task
+}
+
+// Scheduler runs tasks on fixed intervals or at a time of day
+type Scheduler struct {
+	entries []entry
+}
+
+// New creates an empty scheduler
+func New() *Scheduler {
+	return &Scheduler{}
+}
+
+// Every runs task every interval, starting one interval after Run
+func (s *Scheduler) Every(name string, interval time.Duration, task Task) {
+	s.entries = append(s.entries, entry{
+		name: name,
+		next: func(now time.Time) time.Time { return now.Add(interval) },
+		task: task,
+	})
+}
+
+// Daily runs task once a day at hour:minute UTC
+func (s *Scheduler) Daily(name string, hour, minute int, task Task) {
+	s.entries = append(s.entries, entry{
+		name: name,
+		next: func(now time.Time) time.Time {
+			now = now.UTC()
+			next := time.Date(now.Year(), now.Month(), now.Day(), hour, minute, 0, 0, time.UTC)
+			if !next.After(now) {
+				next = next.AddDate(0, 0, 1)
+			}
+			return next
+		},
+		task: task,
+	})
+}
+
+// Run blocks until ctx is cancelled, running each task at its scheduled
+// time. A task never overlaps with itself; failures are logged and the
+// task is rescheduled.
+func (s *Scheduler) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, e := range s.entries {
+		wg.Add(1)
+		go func(e entry) {
+			defer wg.Done()
+			s.loop(ctx, e)
+		}(e)
+	}
+	wg.Wait()
+}
+
+func (s *Scheduler) loop(ctx context.Context, e entry) {
+	for {
+		wait := time.Until(e.next(time.Now()))
+		log.Printf("scheduler: %s next run in %s", e.name, wait.Round(time.Second))
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		started := time.Now()
+		if err := e.task(ctx); err != nil {
+			log.Printf("scheduler: %s failed: %v", e.name, err)
+			continue
+		}
+		log.Printf("scheduler: %s finished in %s", e.name, time.Since(started).Round(time.Millisecond))
+	}
+}
@@ -0,0 +1,148 @@
+// Package sensitivity labels documents whose text needs a content warning:
+// sexually explicit content, references to minors and medical records. It
+// matches phrases rather than asking a model, so it is cheap enough to run
+// at ingest, and errs towards labeling: a label means a reader should be
+// warned, not that the document is certainly about that.
+package sensitivity
+
+import (
+	"context"
+	"regexp"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Labels
+const (
+	LabelExplicit = "explicit" // sexually explicit content
+	LabelMinors   = "minors"   // minors referenced
+	LabelMedical  = "medical"  // medical records or health information
+)
+
+// Labels lists every label
+var Labels = []string{LabelExplicit, LabelMinors, LabelMedical}
+
+// threshold is the score a label needs. Strong phrases score it alone;
+// weaker ones, which also turn up in unrelated text, need company.
+const threshold = 2
+
+// rule scores a label for each match of a pattern
+type rule struct {
+	label  string
+	weight int
+	re     *regexp.Regexp
+}
+
+var rules = []rule{
+	{LabelExplicit, 2, regexp.MustCompile(`(?i)\b(?:sexually explicit|sexual (?:intercourse|acts?|abuse|assault|contact)|oral sex|pornograph\w*|intercourse|molest\w*|naked|nude|genitals?|orgy)\b`)},
+	{LabelExplicit, 1, regexp.MustCompile(`(?i)\b(?:sexual\w*|massages?|topless|breasts?|lingerie|undress\w*|erotic)\b`)},
+
+	{LabelMinors, 2, regexp.MustCompile(`(?i)\b(?:(?:a|the) minor|minors|underage|under-age|under the age of (?:1[0-8]|eighteen|sixteen)|juveniles?)\b`)},
+	{LabelMinors, 2, regexp.MustCompile(`(?i)\b(?:[1-9]|1[0-7])[- ]years?[- ]old\b|\bage(?:d)? (?:[1-9]|1[0-7])\b`)},
+	{LabelMinors, 1, regexp.MustCompile(`(?i)\b(?:high school|middle school|schoolgirls?|(?:ninth|tenth|eleventh) grade|teen(?:age|ager)?s?|girls?)\b`)},
+
+	{LabelMedical, 2, regexp.MustCompile(`(?i)\b(?:medical records?|diagnos(?:is|es|ed)|prescri(?:ption|bed)|psychiatric|gynecolog\w*|HIV|STDs?|pregnan\w*|abortion)\b`)},
+	{LabelMedical, 1, regexp.MustCompile(`(?i)\b(?:patients?|hospital\w*|physician|medications?|therapy|therapist|treatment|clinic|\d+ ?mg)\b`)},
+}
+
+// Classify returns the labels that apply to text, in the order of Labels
+func Classify(text string) []string {
+	scores := make(map[string]int, len(Labels))
+	for _, r := range rules {
+		if scores[r.label] >= threshold {
+			continue
+		}
+		scores[r.label] += r.weight * len(r.re.FindAllStringIndex(text, threshold))
+	}
+	labels := []string{}
+	for _, label := range Labels {
+		if scores[label] >= threshold {
+			labels = append(labels, label)
+		}
+	}
+	return labels
+}
+
+// Update records the labels of a document's text and returns them
+func Update(ctx context.Context, tx pgx.Tx, documentID int, text string) ([]string, error) {
+	labels := Classify(text)
+	_, err := tx.Exec(ctx, `
+		INSERT INTO document_sensitivity (document_id, labels)
+		VALUES ($1, $2)
+		ON CONFLICT (document_id) DO UPDATE SET labels = EXCLUDED.labels, computed_at = NOW()
+	`, documentID, labels)
+	return labels, err
+}
+
+// Result summarizes a run
+type Result struct {
+	Documents  int `json:"documents" doc:"Documents read"`
+	Labeled    int `json:"labeled" doc:"Documents with any label, this run or earlier"`
+	DurationMs int `json:"durationMs"`
+}
+
+// batchSize bounds the documents read per query
+const batchSize = 500
+
+// staleSQL reads the next batch of documents after $2 whose text changed
+// since they were labeled, or every document if $1
+const staleSQL = `
+	SELECT d.id, COALESCE(d.full_text, '')
+	FROM documents d
+	LEFT JOIN document_sensitivity ds ON ds.document_id = d.id
+	WHERE d.id > $2
+	  AND ($1 OR ds.document_id IS NULL OR COALESCE(d.updated_at, d.created_at) > ds.computed_at)
+	ORDER BY d.id
+	LIMIT $3
+`
+
+// Run labels the documents whose text changed since the last run, or every
+// document when force is set, as after the rules change. Documents
+// ingested before this package existed are backfilled by the first run.
+func Run(ctx context.Context, pool *pgxpool.Pool, force bool) (*Result, error) {
+	started := time.Now()
+	result := &Result{}
+
+	after := 0
+	for {
+		rows, err := pool.Query(ctx, staleSQL, force, after, batchSize)
+		if err != nil {
+			return nil, err
+		}
+		type doc struct {
+			id   int
+			text string
+		}
+		docs, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (doc, error) {
+			var d doc
+			return d, row.Scan(&d.id, &d.text)
+		})
+		if err != nil {
+			return nil, err
+		}
+		if len(docs) == 0 {
+			break
+		}
+
+		for _, d := range docs {
+			err := pgx.BeginFunc(ctx, pool, func(tx pgx.Tx) error {
+				_, err := Update(ctx, tx, d.id, d.text)
+				return err
+			})
+			if err != nil {
+				return nil, err
+			}
+			result.Documents++
+		}
+		after = docs[len(docs)-1].id
+	}
+
+	err := pool.QueryRow(ctx, `SELECT COUNT(*) FROM document_sensitivity WHERE labels <> '{}'`).Scan(&result.Labeled)
+	if err != nil {
+		return nil, err
+	}
+	result.DurationMs = int(time.Since(started).Milliseconds())
+	return result, nil
+}
@@ -0,0 +1,127 @@
+package seo
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/subculture-collective/epstein-db/api/internal/store"
+)
+
+// Meta types
+const (
+	TypeEntity   = "entity"
+	TypeDocument = "document"
+)
+
+// Types lists what meta can describe
+var Types = []string{TypeEntity, TypeDocument}
+
+// DescriptionLength caps descriptions, about what link previews show
+const DescriptionLength = 200
+
+// Meta is what a link preview or search result shows for a permalink
+type Meta struct {
+	Type        string    `json:"type" enum:"entity,document"`
+	ID          int       `json:"id"`
+	Title       string    `json:"title"`
+	Description string    `json:"description"`
+	URL         string    `json:"url" doc:"Web app permalink"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+// Describe returns the meta of an entity or document, or pgx.ErrNoRows.
+// site is the web app's public URL. Meta is for crawlers and link
+// previews, so a document of a restricted dataset is described by its
+// metadata alone. Protected entities aren't described, and their names are
+// masked in descriptions.
+func Describe(ctx context.Context, pool *pgxpool.Pool, site, typ string, id int) (*Meta, error) {
+	m := &Meta{Type: typ, ID: id}
+	masker, err := store.LoadMasker(ctx, pool)
+	if err != nil {
+		return nil, err
+	}
+
+	if typ == TypeEntity {
+		var entityType, description string
+		var documents int
+		err := pool.QueryRow(ctx, `
+			SELECT canonical_name, entity_type::text, COALESCE(description, ''),
+				   COALESCE(document_count, 0), COALESCE(updated_at, created_at, NOW())
+			FROM entities WHERE id = $1 AND deleted_at IS NULL AND NOT protected
+		`, id).Scan(&m.Title, &entityType, &description, &documents, &m.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		if description == "" {
+			description = strings.ToUpper(entityType[:1]) + entityType[1:] + " mentioned in " + plural(documents, "document") + "."
+		}
+		m.Description = clip(masker.Mask(description))
+		m.URL = site + "/entities/" + strconv.Itoa(id)
+		return m, nil
+	}
+
+	var docType, summary, detailed, dataset string
+	var datasetID int
+	var pages *int
+	err = pool.QueryRow(ctx, `
+		SELECT d.doc_id, COALESCE(d.document_type, ''),
+			   CASE WHEN ds.visibility = 'restricted' THEN '' ELSE COALESCE(d.summary, '') END,
+			   CASE WHEN ds.visibility = 'restricted' THEN '' ELSE COALESCE(d.detailed_summary, '') END,
+			   d.dataset_id, COALESCE(ds.name, ''), d.page_count, COALESCE(d.updated_at, d.created_at, NOW())
+		FROM documents d
+		LEFT JOIN datasets ds ON ds.id = d.dataset_id
+		WHERE d.id = $1 AND d.deleted_at IS NULL
+	`, id).Scan(&m.Title, &docType, &summary, &detailed, &datasetID, &dataset, &pages, &m.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if docType != "" {
+		m.Title += ": " + docType
+	}
+	switch {
+	case summary != "":
+		m.Description = summary
+	case detailed != "":
+		m.Description = detailed
+	default:
+		if dataset == "" {
+			dataset = "Dataset " + strconv.Itoa(datasetID)
+		}
+		m.Description = "Document from " + dataset
+		if pages != nil {
+			m.Description += ", " + plural(*pages, "page")
+		}
+		m.Description += "."
+	}
+	m.Description = clip(masker.Mask(m.Description))
+	m.URL = site + "/documents/" + strconv.Itoa(id)
+	return m, nil
+}
+
+// clip shortens s to DescriptionLength at a word boundary
+func clip(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if len(s) <= DescriptionLength {
+		return s
+	}
+	cut := strings.LastIndexByte(s[:DescriptionLength], ' ')
+	if cut <= 0 {
+		cut = DescriptionLength
+		for cut > 0 && s[cut]&0xC0 == 0x80 {
+			cut--
+		}
+	}
+	return strings.TrimRight(s[:cut], " ,.;:") + "…"
+}
+
+func plural(n int, noun string) string {
+	s := strconv.Itoa(n) + " " + noun
+	if n != 1 {
+		s += "s"
+	}
+	return s
+}
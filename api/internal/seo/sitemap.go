@@ -0,0 +1,158 @@
+// Package seo helps search engines and link previews find their way around
+// the web app: sitemaps (sitemaps.org) of entity and document permalinks,
+// and the title and description to show for each.
+package seo
+
+import (
+	"context"
+	"encoding/xml"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// PageSize is how many IDs one sitemap page covers. Pages are ID ranges,
+// so they never shift as rows are added; gaps leave some short.
+const PageSize = 10000
+
+// namespace is the sitemaps.org schema
+const namespace = "http://www.sitemaps.org/schemas/sitemap/0.9"
+
+// Kinds lists what sitemaps are published, by their path segment
+var Kinds = []string{"entities", "documents"}
+
+// tables maps each kind to its table
+var tables = map[string]string{
+	"entities":  "entities",
+	"documents": "documents",
+}
+
+// listed maps each kind to the condition on its rows that are listed
+var listed = map[string]string{
+	"entities":  "deleted_at IS NULL AND NOT protected",
+	"documents": "deleted_at IS NULL",
+}
+
+// Index lists the sitemap pages
+type Index struct {
+	XMLName  xml.Name  `xml:"sitemapindex"`
+	XMLNS    string    `xml:"xmlns,attr"`
+	Sitemaps []Sitemap `xml:"sitemap"`
+	// Updated is the newest lastmod, for validators
+	Updated time.Time `xml:"-"`
+}
+
+// Sitemap is one page of an index
+type Sitemap struct {
+	Loc     string    `xml:"loc"`
+	LastMod time.Time `xml:"lastmod"`
+}
+
+// URLSet is one sitemap page
+type URLSet struct {
+	XMLName xml.Name `xml:"urlset"`
+	XMLNS   string   `xml:"xmlns,attr"`
+	URLs    []URL    `xml:"url"`
+	// Updated is the newest lastmod, for validators
+	Updated time.Time `xml:"-"`
+}
+
+// URL is a permalink
+type URL struct {
+	Loc     string    `xml:"loc"`
+	LastMod time.Time `xml:"lastmod"`
+}
+
+// Sitemaps returns the index of every non-empty page. base is the API's
+// public URL, where the pages are served.
+func Sitemaps(ctx context.Context, pool *pgxpool.Pool, base string) (*Index, error) {
+	idx := &Index{XMLNS: namespace}
+	for _, kind := range Kinds {
+		rows, err := pool.Query(ctx, `
+			SELECT (id - 1) / $1 AS page, MAX(COALESCE(updated_at, created_at, NOW()))
+			FROM `+tables[kind]+`
+			WHERE `+listed[kind]+`
+			GROUP BY page
+			ORDER BY page
+		`, PageSize)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var page int
+			var modified time.Time
+			if err := rows.Scan(&page, &modified); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			modified = modified.UTC().Truncate(time.Second)
+			idx.Sitemaps = append(idx.Sitemaps, Sitemap{
+				Loc:     base + "/sitemaps/" + kind + "/" + strconv.Itoa(page+1),
+				LastMod: modified,
+			})
+			if modified.After(idx.Updated) {
+				idx.Updated = modified
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return idx, nil
+}
+
+// Page returns page (from 1) of the kind's permalinks, or pgx.ErrNoRows if
+// it's empty. site is the web app's public URL.
+func Page(ctx context.Context, pool *pgxpool.Pool, site, kind string, page int) (*URLSet, error) {
+	set := &URLSet{XMLNS: namespace}
+	first := (page-1)*PageSize + 1
+
+	rows, err := pool.Query(ctx, `
+		SELECT id, COALESCE(updated_at, created_at, NOW())
+		FROM `+tables[kind]+`
+		WHERE id BETWEEN $1 AND $2 AND `+listed[kind]+`
+		ORDER BY id
+	`, first, first+PageSize-1)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int
+		var modified time.Time
+		if err := rows.Scan(&id, &modified); err != nil {
+			return nil, err
+		}
+		modified = modified.UTC().Truncate(time.Second)
+		set.URLs = append(set.URLs, URL{Loc: site + "/" + kind + "/" + strconv.Itoa(id), LastMod: modified})
+		if modified.After(set.Updated) {
+			set.Updated = modified
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(set.URLs) == 0 {
+		return nil, pgx.ErrNoRows
+	}
+	return set, nil
+}
+
+// Write writes v, an Index or URLSet, as an XML document
+func Write(w io.Writer, v any) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
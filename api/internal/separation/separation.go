@@ -0,0 +1,131 @@
+// Package separation computes degrees of separation: how many hops through
+// shared documents separate each person and organization from the core
+// entities. Entity layers follow from it: the core is layer 0, and an
+// entity n hops away is layer n, up to MaxLayer.
+package separation
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// MaxLayer is the outermost layer; entities further away have none
+const MaxLayer = 3
+
+// maxHops bounds the search. Six hops reach nearly everything in a
+// co-occurrence network, so distances beyond it say little.
+const maxHops = 6
+
+// Result summarizes a run
+type Result struct {
+	Core          int   `json:"core" doc:"Core entities distances were measured from"`
+	Reached       int   `json:"reached" doc:"Entities within reach, the core included"`
+	ByDistance    []int `json:"byDistance" doc:"Entities at each distance, from 0"`
+	LayersChanged int   `json:"layersChanged"`
+	DurationMs    int   `json:"durationMs"`
+}
+
+// neighborsSQL finds the people and organizations sharing a document with
+// any of $1
+const neighborsSQL = `
+	SELECT DISTINCT de2.entity_id
+	FROM document_entities de1
+	JOIN document_entities de2 ON de2.document_id = de1.document_id
+	JOIN entities e ON e.id = de2.entity_id AND e.entity_type IN ('person', 'organization')
+	WHERE de1.entity_id = ANY($1)
+`
+
+// layersSQL sets the layers of people, organizations and the core from
+// the distances, touching only the entities whose layer changes
+const layersSQL = `
+	UPDATE entities e
+	SET layer = l.layer
+	FROM (
+		SELECT x.id, CASE WHEN d.distance <= $1 THEN d.distance END AS layer
+		FROM entities x
+		LEFT JOIN entity_distances d ON d.entity_id = x.id
+		WHERE x.entity_type IN ('person', 'organization') OR d.entity_id IS NOT NULL
+	) l
+	WHERE e.id = l.id AND e.layer IS DISTINCT FROM l.layer
+`
+
+// Run measures the distance of every entity within reach from core,
+// breadth first, replaces the stored distances and updates the layers.
+// An empty core uses the entities now in layer 0.
+func Run(ctx context.Context, pool *pgxpool.Pool, core []int) (*Result, error) {
+	started := time.Now()
+
+	var err error
+	if len(core) == 0 {
+		err = pool.QueryRow(ctx, `SELECT COALESCE(array_agg(id ORDER BY id), '{}') FROM entities WHERE layer = 0`).Scan(&core)
+	} else {
+		err = pool.QueryRow(ctx, `SELECT COALESCE(array_agg(id ORDER BY id), '{}') FROM entities WHERE id = ANY($1)`, core).Scan(&core)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(core) == 0 {
+		return nil, errors.New("no core entities: set CORE_ENTITIES to existing entity IDs or put an entity in layer 0")
+	}
+
+	distance := make(map[int]int, len(core))
+	for _, id := range core {
+		distance[id] = 0
+	}
+	result := &Result{Core: len(core), ByDistance: []int{len(core)}}
+	frontier := core
+	for hop := 1; hop <= maxHops && len(frontier) > 0; hop++ {
+		rows, err := pool.Query(ctx, neighborsSQL, frontier)
+		if err != nil {
+			return nil, err
+		}
+		var next []int
+		for rows.Next() {
+			var id int
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			if _, seen := distance[id]; !seen {
+				distance[id] = hop
+				next = append(next, id)
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+		if len(next) > 0 {
+			result.ByDistance = append(result.ByDistance, len(next))
+		}
+		frontier = next
+	}
+	result.Reached = len(distance)
+
+	rows := make([][]any, 0, len(distance))
+	for id, d := range distance {
+		rows = append(rows, []any{id, d})
+	}
+	err = pgx.BeginFunc(ctx, pool, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, `DELETE FROM entity_distances`); err != nil {
+			return err
+		}
+		if _, err := tx.CopyFrom(ctx, pgx.Identifier{"entity_distances"}, []string{"entity_id", "distance"},
+			pgx.CopyFromRows(rows)); err != nil {
+			return err
+		}
+		tag, err := tx.Exec(ctx, layersSQL, MaxLayer)
+		result.LayersChanged = int(tag.RowsAffected())
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result.DurationMs = int(time.Since(started).Milliseconds())
+	return result, nil
+}
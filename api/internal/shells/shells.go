@@ -0,0 +1,265 @@
+package shells
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// JobKind is the jobs.kind for on-demand shell company analyses
+const JobKind = "shells"
+
+// Shell companies are looked for among the PPP borrowers linked to the
+// corpus: matched to an entity, or sharing an address or employer with a
+// matched record (see shared_attribute_groups). Sole proprietors are left
+// out. Each candidate is scored by the signals it shows; the score is the
+// sum of their weights.
+
+// Signal is a trait typical of shell companies
+type Signal struct {
+	Name        string  `json:"name"`
+	Description string  `json:"description"`
+	Weight      float64 `json:"weight"`
+}
+
+// Signal names
+const (
+	SignalAgentAddress   = "agent_address"
+	SignalLoansPerJob    = "loans_per_job"
+	SignalOnlyInCrossref = "only_in_crossref"
+	SignalNoGrants       = "no_grants"
+)
+
+// Signals are the traits scored, heaviest first. Their weights add up to 1.
+var Signals = []Signal{
+	{SignalAgentAddress, "Registered at a registered agent's address, or one shared by at least 5 differently named borrowers", 0.3},
+	{SignalLoansPerJob, "PPP loans over $20,833 per job reported, the program's cap for one employee, or no jobs reported", 0.3},
+	{SignalOnlyInCrossref, "Not matched to any entity mentioned in the documents, only linked through shared records", 0.25},
+	{SignalNoGrants, "No federal grants under its name", 0.15},
+}
+
+// SignalNames lists the signals by name
+func SignalNames() []string {
+	names := make([]string, len(Signals))
+	for i, s := range Signals {
+		names[i] = s.Name
+	}
+	return names
+}
+
+// MinScore is the lowest score a candidate is kept with
+const MinScore = 0.4
+
+// sharedAddress is how many differently named borrowers at one address
+// make it look like a registered agent's
+const sharedAddress = 5
+
+// maxLoanPerJob is the most a PPP loan could be per employee: 2.5 months of
+// the $100,000 yearly salary cap
+const maxLoanPerJob = 20833
+
+// Hit is a signal a candidate shows, with why
+type Hit struct {
+	Signal    string  `json:"signal"`
+	Weight    float64 `json:"weight"`
+	Rationale string  `json:"rationale"`
+}
+
+// Candidate is an organization that may be a shell company
+type Candidate struct {
+	ID           int       `json:"id"`
+	Organization string    `json:"organization" doc:"Normalized name"`
+	Name         string    `json:"name"`
+	Score        float64   `json:"score" doc:"0 to 1, the sum of the weights of the signals shown"`
+	Signals      []Hit     `json:"signals"`
+	LoanIDs      []int     `json:"loanIds" doc:"Its PPP loans"`
+	EntityIDs    []int     `json:"entityIds" doc:"Entities it is matched to or shares records with"`
+	GeneratedAt  time.Time `json:"generatedAt"`
+}
+
+// Result summarizes a run
+type Result struct {
+	Organizations int `json:"organizations" doc:"Organizations linked to the corpus that were scored"`
+	Candidates    int `json:"candidates" doc:"Those scoring at least MinScore"`
+	DurationMs    int `json:"durationMs"`
+}
+
+// organizationsSQL gathers what the signals need for each PPP borrower
+// linked to the corpus
+const organizationsSQL = `
+	WITH borrowers AS (
+		SELECT normalize_employer(p.borrower_name)                          AS org,
+			   (array_agg(p.borrower_name ORDER BY p.id))[1]                AS name,
+			   array_agg(DISTINCT p.id)                                     AS loan_ids,
+			   COALESCE(SUM(p.loan_amount), 0)::float8                      AS amount,
+			   COALESCE(SUM(p.jobs_retained), 0)                            AS jobs,
+			   MIN(normalize_address(p.borrower_address, p.borrower_zip))   AS address,
+			   COALESCE(array_agg(DISTINCT x.entity_id)
+						FILTER (WHERE x.entity_id IS NOT NULL), '{}')       AS matched
+		FROM ppp_loans p
+		LEFT JOIN entity_crossref_matches x
+		  ON x.source = 'ppp' AND x.source_id = p.id AND x.false_positive IS NOT TRUE
+		WHERE p.business_type IS NULL
+		   OR p.business_type !~* '(sole propriet|self-employed|independent contractor)'
+		GROUP BY 1
+	)
+	SELECT b.org, b.name, b.loan_ids, b.amount, b.jobs, b.address,
+		   COALESCE(ag.names, 0),
+		   COALESCE(b.address ~ '\m(REGISTERED AGENT|CORPORATION TRUST|C O)\M', false),
+		   EXISTS (SELECT 1 FROM entities e WHERE e.id = ANY(b.matched) AND e.document_count > 0),
+		   EXISTS (SELECT 1 FROM federal_grants g WHERE normalize_employer(g.recipient_name) = b.org),
+		   linked.ids
+	FROM borrowers b
+	LEFT JOIN shared_attribute_groups ag ON ag.attribute = 'address' AND ag.value = b.address
+	LEFT JOIN shared_attribute_groups eg ON eg.attribute = 'employer' AND eg.value = b.org
+	CROSS JOIN LATERAL (
+		SELECT ARRAY(
+			SELECT DISTINCT unnest(b.matched || COALESCE(ag.entity_ids, '{}') || COALESCE(eg.entity_ids, '{}'))
+			ORDER BY 1
+		) AS ids
+	) linked
+	WHERE b.org IS NOT NULL AND cardinality(linked.ids) > 0
+`
+
+// Run scores the organizations linked to the corpus and replaces the stored
+// candidates with those scoring at least MinScore. It reads the shared
+// attribute groups, so run it after the stats refresh.
+func Run(ctx context.Context, pool *pgxpool.Pool) (*Result, error) {
+	started := time.Now()
+	result := &Result{}
+
+	rows, err := pool.Query(ctx, organizationsSQL)
+	if err != nil {
+		return nil, err
+	}
+	var candidates []Candidate
+	for rows.Next() {
+		var c Candidate
+		var o organization
+		if err := rows.Scan(&c.Organization, &c.Name, &c.LoanIDs, &o.amount, &o.jobs, &o.address,
+			&o.sharedNames, &o.agent, &o.mentioned, &o.grants, &c.EntityIDs); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		result.Organizations++
+
+		c.Signals = o.signals()
+		for _, h := range c.Signals {
+			c.Score += h.Weight
+		}
+		if c.Score >= MinScore {
+			candidates = append(candidates, c)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	err = pgx.BeginFunc(ctx, pool, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, `DELETE FROM shell_candidates`); err != nil {
+			return err
+		}
+		for _, c := range candidates {
+			_, err := tx.Exec(ctx, `
+				INSERT INTO shell_candidates (organization, name, score, signals, loan_ids, entity_ids)
+				VALUES ($1, $2, $3, $4, $5, $6)
+			`, c.Organization, c.Name, c.Score, c.Signals, c.LoanIDs, c.EntityIDs)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result.Candidates = len(candidates)
+	result.DurationMs = int(time.Since(started).Milliseconds())
+	return result, nil
+}
+
+// organization is what the signals are judged on
+type organization struct {
+	amount      float64
+	jobs        int64
+	address     *string
+	sharedNames int64 // differently named borrowers at the address, this one included
+	agent       bool  // the address names a registered agent
+	mentioned   bool  // matched to an entity in the documents
+	grants      bool
+}
+
+// signals returns the signals o shows, in the order of Signals
+func (o organization) signals() []Hit {
+	weight := func(name string) float64 {
+		i := slices.IndexFunc(Signals, func(s Signal) bool { return s.Name == name })
+		return Signals[i].Weight
+	}
+
+	hits := []Hit{}
+	switch {
+	case o.agent:
+		hits = append(hits, Hit{SignalAgentAddress, weight(SignalAgentAddress),
+			fmt.Sprintf("%s is a registered agent's address", *o.address)})
+	case o.sharedNames >= sharedAddress:
+		hits = append(hits, Hit{SignalAgentAddress, weight(SignalAgentAddress),
+			fmt.Sprintf("%s is shared by %d differently named borrowers", *o.address, o.sharedNames)})
+	}
+	switch {
+	case o.jobs <= 0:
+		hits = append(hits, Hit{SignalLoansPerJob, weight(SignalLoansPerJob),
+			fmt.Sprintf("$%.0f in PPP loans with no jobs reported", o.amount)})
+	case o.amount/float64(o.jobs) > maxLoanPerJob:
+		hits = append(hits, Hit{SignalLoansPerJob, weight(SignalLoansPerJob),
+			fmt.Sprintf("$%.0f in PPP loans for %d jobs, $%.0f a job", o.amount, o.jobs, o.amount/float64(o.jobs))})
+	}
+	if !o.mentioned {
+		hits = append(hits, Hit{SignalOnlyInCrossref, weight(SignalOnlyInCrossref),
+			"Linked to the corpus only through records sharing its address or name"})
+	}
+	if !o.grants {
+		hits = append(hits, Hit{SignalNoGrants, weight(SignalNoGrants), "No federal grants under its name"})
+	}
+	return hits
+}
+
+// Filter narrows List
+type Filter struct {
+	MinScore float64
+	Signal   string // only candidates showing this signal, when set
+	Limit    int
+	Offset   int
+}
+
+// List returns the stored candidates, highest scores first
+func List(ctx context.Context, pool *pgxpool.Pool, f Filter) ([]Candidate, error) {
+	rows, err := pool.Query(ctx, `
+		SELECT id, organization, name, score, signals, loan_ids, entity_ids, generated_at
+		FROM shell_candidates
+		WHERE score >= $1
+		  AND ($2 = '' OR signals @> jsonb_build_array(jsonb_build_object('signal', $2::text)))
+		ORDER BY score DESC, cardinality(entity_ids) DESC, id
+		LIMIT $3 OFFSET $4
+	`, f.MinScore, f.Signal, f.Limit, f.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	candidates := []Candidate{}
+	for rows.Next() {
+		var c Candidate
+		if err := rows.Scan(&c.ID, &c.Organization, &c.Name, &c.Score, &c.Signals,
+			&c.LoanIDs, &c.EntityIDs, &c.GeneratedAt); err != nil {
+			return nil, err
+		}
+		candidates = append(candidates, c)
+	}
+	return candidates, rows.Err()
+}
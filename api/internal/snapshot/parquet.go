@@ -0,0 +1,155 @@
+package snapshot
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// parquetBatch is the number of rows buffered per write
+const parquetBatch = 1000
+
+// writeParquet writes every table as a Parquet file in a new zip at path,
+// with the manifest as manifest.json. Parquet files are already compressed,
+// so the zip only stores them.
+func writeParquet(ctx context.Context, path string, source rowSource, manifest *Manifest) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	z := zip.NewWriter(f)
+
+	for _, t := range tables {
+		w, err := z.CreateHeader(&zip.FileHeader{Name: t.name + ".parquet", Method: zip.Store, Modified: manifest.CreatedAt})
+		if err != nil {
+			return err
+		}
+		info, err := writeParquetTable(ctx, w, t, source)
+		if err != nil {
+			return err
+		}
+		manifest.Tables = append(manifest.Tables, info)
+	}
+
+	w, err := z.CreateHeader(&zip.FileHeader{Name: "manifest.json", Method: zip.Deflate, Modified: manifest.CreatedAt})
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(manifest); err != nil {
+		return err
+	}
+
+	if err := z.Close(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+func writeParquetTable(ctx context.Context, out io.Writer, t table, source rowSource) (TableInfo, error) {
+	info := TableInfo{Name: t.name}
+
+	group := parquet.Group{}
+	for _, c := range t.columns {
+		group[c.name] = parquet.Optional(parquetNode(c.kind))
+	}
+	schema := parquet.NewSchema(t.name, group)
+
+	// Groups order their columns by name, not as declared
+	index := map[string]int{}
+	for i, path := range schema.Columns() {
+		index[path[0]] = i
+	}
+
+	h := sha256.New()
+	w := parquet.NewWriter(io.MultiWriter(out, h), schema, parquet.Compression(&parquet.Zstd))
+
+	batch := make([]parquet.Row, 0, parquetBatch)
+	flush := func() error {
+		_, err := w.WriteRows(batch)
+		batch = batch[:0]
+		return err
+	}
+
+	err := source(ctx, t, func(values []any) error {
+		row := make(parquet.Row, len(t.columns))
+		for i, c := range t.columns {
+			row[index[c.name]] = parquetValue(c.kind, values[i]).Level(0, 1, index[c.name])
+			if values[i] == nil {
+				row[index[c.name]] = parquet.NullValue().Level(0, 0, index[c.name])
+			}
+		}
+		batch = append(batch, row)
+		info.Rows++
+		if len(batch) == parquetBatch {
+			return flush()
+		}
+		return nil
+	})
+	if err != nil {
+		return info, err
+	}
+	if err := flush(); err != nil {
+		return info, err
+	}
+	if err := w.Close(); err != nil {
+		return info, err
+	}
+
+	info.SHA256 = hex.EncodeToString(h.Sum(nil))
+	return info, nil
+}
+
+func parquetNode(kind string) parquet.Node {
+	switch kind {
+	case kindInteger:
+		return parquet.Int(64)
+	case kindReal:
+		return parquet.Leaf(parquet.DoubleType)
+	case kindBoolean:
+		return parquet.Leaf(parquet.BooleanType)
+	case kindDate:
+		return parquet.Date()
+	case kindTimestamp:
+		return parquet.Timestamp(parquet.Microsecond)
+	default:
+		return parquet.String()
+	}
+}
+
+// parquetValue converts a non-null value of a column of kind
+func parquetValue(kind string, v any) parquet.Value {
+	switch v := v.(type) {
+	case nil:
+		return parquet.NullValue()
+	case int64:
+		return parquet.Int64Value(v)
+	case float64:
+		return parquet.DoubleValue(v)
+	case bool:
+		return parquet.BooleanValue(v)
+	case string:
+		return parquet.ByteArrayValue([]byte(v))
+	case time.Time:
+		if kind == kindDate {
+			days := v.Unix() / 86400
+			if v.Unix()%86400 < 0 {
+				days--
+			}
+			return parquet.Int32Value(int32(days))
+		}
+		return parquet.Int64Value(v.UnixMicro())
+	default:
+		return parquet.ByteArrayValue([]byte(fmt.Sprint(v)))
+	}
+}
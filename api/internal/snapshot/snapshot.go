@@ -0,0 +1,237 @@
+// Package snapshot exports the public tables for mirrors and offline
+// analysis, as a single SQLite database or a zip of Parquet files. Every
+// snapshot is read in one transaction, so its tables agree with each other,
+// and is written with a manifest naming the schema version it was taken at
+// and a SHA-256 checksum.
+package snapshot
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/subculture-collective/epstein-db/api/internal/changes"
+	"github.com/subculture-collective/epstein-db/api/internal/migrations"
+	"github.com/subculture-collective/epstein-db/api/internal/store"
+)
+
+// JobKind is the jobs.kind for snapshot jobs
+const JobKind = "snapshot"
+
+// Version is the snapshot layout version, bumped when tables or columns
+// change incompatibly
+const Version = 1
+
+// Formats
+const (
+	FormatSQLite  = "sqlite"
+	FormatParquet = "parquet"
+)
+
+// Formats lists every format
+var Formats = []string{FormatSQLite, FormatParquet}
+
+// ErrNotFound is returned for a snapshot name that doesn't exist
+var ErrNotFound = errors.New("snapshot: not found")
+
+// Params are the options accepted by a snapshot job
+type Params struct {
+	Format string `json:"format"`
+}
+
+// TableInfo describes one exported table
+type TableInfo struct {
+	Name   string `json:"name"`
+	Rows   int64  `json:"rows"`
+	SHA256 string `json:"sha256,omitempty" doc:"Checksum of the table's Parquet file"`
+}
+
+// Manifest describes what a snapshot holds
+type Manifest struct {
+	Version       int         `json:"version" doc:"Snapshot layout version"`
+	SchemaVersion int         `json:"schemaVersion" doc:"Database migration the data was exported at"`
+	Format        string      `json:"format" enum:"sqlite,parquet"`
+	CreatedAt     time.Time   `json:"createdAt"`
+	ChangeCursor  string      `json:"changeCursor,omitempty" doc:"Where to start following /api/changes after loading this snapshot"`
+	Tables        []TableInfo `json:"tables"`
+}
+
+// Snapshot is a written snapshot file
+type Snapshot struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256" doc:"Checksum of the whole file"`
+	Manifest
+}
+
+// rowSource streams the rows of a table to emit
+type rowSource func(ctx context.Context, t table, emit func(values []any) error) error
+
+// Write exports the public tables to a new file in dir. The file appears
+// under its final name only once complete, next to a .json sidecar holding
+// its manifest and checksum.
+func Write(ctx context.Context, pool *pgxpool.Pool, dir, format string) (*Snapshot, error) {
+	if !slices.Contains(Formats, format) {
+		return nil, fmt.Errorf("snapshot: unknown format %q", format)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	tx, err := pool.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	schema, err := migrations.Current(ctx, pool)
+	if err != nil {
+		return nil, err
+	}
+	cursor, err := changes.Head(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+	manifest := Manifest{
+		Version:       Version,
+		SchemaVersion: schema,
+		Format:        format,
+		CreatedAt:     time.Now().UTC().Truncate(time.Second),
+		ChangeCursor:  cursor.String(),
+	}
+
+	name := "epstein-db-" + manifest.CreatedAt.Format("20060102T150405Z") + "." + format
+	if format == FormatParquet {
+		name += ".zip"
+	}
+	final := filepath.Join(dir, name)
+	partial := final + ".partial"
+	defer os.Remove(partial)
+
+	masker, err := store.LoadMasker(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+
+	source := func(ctx context.Context, t table, emit func([]any) error) error {
+		var masked []int
+		for i, c := range t.columns {
+			if slices.Contains(t.masked, c.name) {
+				masked = append(masked, i)
+			}
+		}
+		rows, err := tx.Query(ctx, t.query)
+		if err != nil {
+			return fmt.Errorf("%s: %w", t.name, err)
+		}
+		defer rows.Close()
+		for rows.Next() {
+			values, err := rows.Values()
+			if err != nil {
+				return fmt.Errorf("%s: %w", t.name, err)
+			}
+			for _, i := range masked {
+				if text, ok := values[i].(string); ok {
+					values[i] = masker.Mask(text)
+				}
+			}
+			if err := emit(values); err != nil {
+				return fmt.Errorf("%s: %w", t.name, err)
+			}
+		}
+		return rows.Err()
+	}
+
+	if format == FormatSQLite {
+		err = writeSQLite(ctx, partial, source, &manifest)
+	} else {
+		err = writeParquet(ctx, partial, source, &manifest)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s := Snapshot{Name: name, Manifest: manifest}
+	if s.SHA256, s.Size, err = checksum(partial); err != nil {
+		return nil, err
+	}
+	sidecar, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(final+".json", sidecar, 0o644); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(partial, final); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// List returns the snapshots in dir, newest first
+func List(dir string) ([]Snapshot, error) {
+	sidecars, err := filepath.Glob(filepath.Join(dir, "epstein-db-*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	snapshots := []Snapshot{}
+	for _, path := range sidecars {
+		if _, err := os.Stat(strings.TrimSuffix(path, ".json")); err != nil {
+			continue // still being written, or removed
+		}
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var s Snapshot
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return nil, fmt.Errorf("snapshot: %s: %w", filepath.Base(path), err)
+		}
+		snapshots = append(snapshots, s)
+	}
+	slices.SortFunc(snapshots, func(a, b Snapshot) int { return b.CreatedAt.Compare(a.CreatedAt) })
+	return snapshots, nil
+}
+
+// Path returns the file of the snapshot called name in dir
+func Path(dir, name string) (string, error) {
+	if name != filepath.Base(name) || !strings.HasPrefix(name, "epstein-db-") || strings.HasSuffix(name, ".json") {
+		return "", ErrNotFound
+	}
+	path := filepath.Join(dir, name)
+	if _, err := os.Stat(path + ".json"); err != nil {
+		return "", ErrNotFound
+	}
+	if _, err := os.Stat(path); err != nil {
+		return "", ErrNotFound
+	}
+	return path, nil
+}
+
+func checksum(path string) (string, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(h.Sum(nil)), n, nil
+}
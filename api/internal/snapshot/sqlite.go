@@ -0,0 +1,124 @@
+package snapshot
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// sqliteTypes maps column kinds to SQLite column types. Dates and times are
+// ISO 8601 text, which SQLite's date functions understand.
+var sqliteTypes = map[string]string{
+	kindInteger:   "INTEGER",
+	kindReal:      "REAL",
+	kindText:      "TEXT",
+	kindBoolean:   "INTEGER",
+	kindDate:      "TEXT",
+	kindTimestamp: "TEXT",
+}
+
+// writeSQLite writes every table to a new SQLite database at path, with the
+// manifest in a snapshot_manifest table
+func writeSQLite(ctx context.Context, path string, source rowSource, manifest *Manifest) error {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	// Nothing to recover from a half-written file; skip the journal
+	if _, err := db.ExecContext(ctx, `PRAGMA journal_mode = OFF; PRAGMA synchronous = OFF`); err != nil {
+		return err
+	}
+
+	for _, t := range tables {
+		rows, err := writeSQLiteTable(ctx, db, t, source)
+		if err != nil {
+			return err
+		}
+		manifest.Tables = append(manifest.Tables, TableInfo{Name: t.name, Rows: rows})
+	}
+
+	raw, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+	_, err = db.ExecContext(ctx, `
+		CREATE TABLE snapshot_manifest (version INTEGER, schema_version INTEGER, created_at TEXT, manifest TEXT);
+	`)
+	if err != nil {
+		return err
+	}
+	_, err = db.ExecContext(ctx, `INSERT INTO snapshot_manifest VALUES (?, ?, ?, ?)`,
+		manifest.Version, manifest.SchemaVersion, manifest.CreatedAt.Format(time.RFC3339), string(raw))
+	return err
+}
+
+func writeSQLiteTable(ctx context.Context, db *sql.DB, t table, source rowSource) (int64, error) {
+	defs := make([]string, len(t.columns))
+	names := make([]string, len(t.columns))
+	for i, c := range t.columns {
+		defs[i] = c.name + " " + sqliteTypes[c.kind]
+		if i == 0 && c.name == "id" {
+			defs[i] += " PRIMARY KEY"
+		}
+		names[i] = c.name
+	}
+	if _, err := db.ExecContext(ctx, fmt.Sprintf("CREATE TABLE %s (%s)", t.name, strings.Join(defs, ", "))); err != nil {
+		return 0, err
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	insert, err := tx.PrepareContext(ctx, fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)",
+		t.name, strings.Join(names, ", "), strings.TrimSuffix(strings.Repeat("?, ", len(names)), ", ")))
+	if err != nil {
+		return 0, err
+	}
+	defer insert.Close()
+
+	var n int64
+	err = source(ctx, t, func(values []any) error {
+		for i, c := range t.columns {
+			values[i] = sqliteValue(c.kind, values[i])
+		}
+		n++
+		_, err := insert.ExecContext(ctx, values...)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	for _, col := range t.indexes {
+		_, err := db.ExecContext(ctx, fmt.Sprintf("CREATE INDEX idx_%s_%s ON %s (%s)", t.name, col, t.name, col))
+		if err != nil {
+			return 0, err
+		}
+	}
+	return n, nil
+}
+
+func sqliteValue(kind string, v any) any {
+	t, ok := v.(time.Time)
+	if !ok {
+		return v
+	}
+	if kind == kindDate {
+		return t.Format(time.DateOnly)
+	}
+	return t.UTC().Format(time.RFC3339Nano)
+}
@@ -0,0 +1,148 @@
+package snapshot
+
+// Column kinds. Queries cast every column so that values arrive as the Go
+// type the writers expect: int64, float64, string, bool or time.Time.
+const (
+	kindInteger   = "integer"
+	kindReal      = "real"
+	kindText      = "text"
+	kindBoolean   = "boolean"
+	kindDate      = "date"
+	kindTimestamp = "timestamp"
+)
+
+type column struct {
+	name string
+	kind string
+}
+
+// table is one exported table and the query that fills it
+type table struct {
+	name    string
+	columns []column
+	indexes []string // columns to index in SQLite
+	masked  []string // text columns in which protected names are masked
+	query   string
+}
+
+// tables are the public tables. Documents leave out their source file path
+// and processing state; cross-reference matches leave out who verified
+// them and confirmed false positives. Protected entities and their links
+// are left out, and their names masked in document text.
+var tables = []table{
+	{
+		name: "datasets",
+		columns: []column{
+			{"id", kindInteger}, {"name", kindText}, {"status", kindText},
+			{"document_count", kindInteger}, {"ready_at", kindTimestamp}, {"visibility", kindText},
+		},
+		query: `
+			SELECT id::bigint, name, status, document_count::bigint, ready_at, visibility
+			FROM datasets ORDER BY id`,
+	},
+	{
+		name: "documents",
+		columns: []column{
+			{"id", kindInteger}, {"doc_id", kindText}, {"dataset_id", kindInteger},
+			{"document_type", kindText}, {"summary", kindText}, {"detailed_summary", kindText},
+			{"date_earliest", kindDate}, {"date_latest", kindDate}, {"page_count", kindInteger},
+			{"full_text", kindText}, {"created_at", kindTimestamp}, {"updated_at", kindTimestamp},
+		},
+		indexes: []string{"doc_id", "dataset_id"},
+		masked:  []string{"summary", "detailed_summary", "full_text"},
+		// Snapshots are for public mirrors, so documents of restricted
+		// datasets are exported metadata-only
+		query: `
+			SELECT id::bigint, doc_id, dataset_id::bigint, document_type,
+				   CASE WHEN NOT dataset_restricted(dataset_id) THEN summary END,
+				   CASE WHEN NOT dataset_restricted(dataset_id) THEN detailed_summary END,
+				   date_earliest, date_latest, page_count::bigint,
+				   CASE WHEN NOT dataset_restricted(dataset_id) THEN full_text END,
+				   created_at, updated_at
+			FROM documents WHERE deleted_at IS NULL ORDER BY id`,
+	},
+	{
+		name: "entities",
+		columns: []column{
+			{"id", kindInteger}, {"canonical_name", kindText}, {"entity_type", kindText},
+			{"layer", kindInteger}, {"description", kindText}, {"aliases", kindText},
+			{"document_count", kindInteger}, {"connection_count", kindInteger},
+		},
+		indexes: []string{"canonical_name"},
+		masked:  []string{"description"},
+		query: `
+			SELECT id::bigint, canonical_name, entity_type::text, layer::bigint, description,
+				   aliases::text, document_count::bigint, connection_count::bigint
+			FROM entities WHERE deleted_at IS NULL AND NOT protected ORDER BY id`,
+	},
+	{
+		name: "document_entities",
+		columns: []column{
+			{"document_id", kindInteger}, {"entity_id", kindInteger}, {"mention_count", kindInteger},
+		},
+		indexes: []string{"document_id", "entity_id"},
+		query: `
+			SELECT de.document_id::bigint, de.entity_id::bigint, de.mention_count::bigint
+			FROM document_entities de
+			JOIN entities e ON e.id = de.entity_id AND NOT e.protected
+			ORDER BY de.document_id, de.entity_id`,
+	},
+	{
+		name: "triples",
+		columns: []column{
+			{"id", kindInteger}, {"document_id", kindInteger}, {"subject_id", kindInteger},
+			{"predicate", kindText}, {"object_id", kindInteger}, {"location_id", kindInteger},
+			{"timestamp", kindDate}, {"explicit_topic", kindText}, {"confidence", kindReal},
+		},
+		indexes: []string{"document_id", "subject_id", "object_id"},
+		query: `
+			SELECT t.id::bigint, t.document_id::bigint, t.subject_id::bigint, t.predicate, t.object_id::bigint,
+				   t.location_id::bigint, t.timestamp, t.explicit_topic, t.confidence::double precision
+			FROM triples t
+			JOIN documents d ON d.id = t.document_id AND d.deleted_at IS NULL
+			WHERE NOT EXISTS (
+				SELECT 1 FROM entities e
+				WHERE e.id IN (t.subject_id, t.object_id, t.location_id) AND (e.protected OR e.deleted_at IS NOT NULL)
+			)
+			ORDER BY t.id`,
+	},
+	{
+		name: "crossref_matches",
+		columns: []column{
+			{"entity_id", kindInteger}, {"source", kindText}, {"source_id", kindInteger},
+			{"match_score", kindReal}, {"match_method", kindText}, {"verified", kindBoolean},
+		},
+		indexes: []string{"entity_id"},
+		query: `
+			SELECT m.entity_id::bigint, m.source::text, m.source_id::bigint,
+				   m.match_score::double precision, m.match_method, COALESCE(m.verified, FALSE)
+			FROM entity_crossref_matches m
+			JOIN entities e ON e.id = m.entity_id AND e.deleted_at IS NULL AND NOT e.protected
+			WHERE NOT COALESCE(m.false_positive, FALSE)
+			ORDER BY m.id`,
+	},
+	{
+		name: "crossref_records",
+		columns: []column{
+			{"source", kindText}, {"source_id", kindInteger}, {"name", kindText},
+			{"amount", kindReal}, {"date", kindDate}, {"description", kindText},
+		},
+		query: `
+			SELECT DISTINCT m.source::text, m.source_id::bigint,
+				   COALESCE(p.borrower_name, f.contributor_name, g.recipient_name),
+				   COALESCE(p.loan_amount, f.amount, g.award_amount)::double precision,
+				   COALESCE(p.date_approved, f.contribution_date, g.award_date),
+				   CASE m.source
+					   WHEN 'ppp' THEN format('PPP loan from %s, status %s', p.lender, p.loan_status)
+					   WHEN 'fec' THEN format('Contribution to %s for candidate %s', f.committee_name, f.candidate_name)
+					   WHEN 'grants' THEN format('Grant from %s: %s', g.awarding_agency, g.description)
+				   END
+			FROM entity_crossref_matches m
+			LEFT JOIN ppp_loans p ON m.source = 'ppp' AND p.id = m.source_id
+			LEFT JOIN fec_contributions f ON m.source = 'fec' AND f.id = m.source_id
+			LEFT JOIN federal_grants g ON m.source = 'grants' AND g.id = m.source_id
+			JOIN entities e ON e.id = m.entity_id AND e.deleted_at IS NULL AND NOT e.protected
+			WHERE NOT COALESCE(m.false_positive, FALSE)
+			ORDER BY 1, 2`,
+	},
+}
@@ -0,0 +1,258 @@
+// Package stoplist keeps names out of the entity graph: OCR artifacts and
+// boilerplate such as court clerks and form headers that are named
+// everywhere and connect everyone. Blocking a name removes its entities'
+// document links and triples, and database triggers drop any new ones, so
+// re-running extraction doesn't bring them back. Names named in a large
+// share of a dataset's documents are suggested for an admin to block or
+// dismiss.
+package stoplist
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Statuses
+const (
+	StatusSuggested = "suggested"
+	StatusBlocked   = "blocked"
+	StatusDismissed = "dismissed"
+)
+
+// Statuses lists every entry status
+var Statuses = []string{StatusSuggested, StatusBlocked, StatusDismissed}
+
+// Suggestion thresholds: a name is suggested when it is named in at least
+// MinShare of the documents of a dataset with at least MinDocuments
+const (
+	MinDocuments = 20
+	MinShare     = 0.3
+)
+
+// ErrEmptyName is returned for a name with no letters or digits
+var ErrEmptyName = errors.New("stoplist: name has no letters or digits")
+
+// Entry is a name on the stoplist
+type Entry struct {
+	ID            int        `json:"id"`
+	Name          string     `json:"name"`
+	EntityType    *string    `json:"entityType" doc:"Only entities of this type; null for every type"`
+	Status        string     `json:"status" enum:"suggested,blocked,dismissed"`
+	Reason        *string    `json:"reason"`
+	DatasetID     *int       `json:"datasetId" doc:"Suggestions: the dataset the name is frequent in"`
+	DocumentShare *float64   `json:"documentShare" doc:"Suggestions: share of the dataset's documents naming it"`
+	Entities      int        `json:"entities" doc:"Entities with the name"`
+	Documents     int        `json:"documents" doc:"Documents still linked to those entities"`
+	CreatedAt     time.Time  `json:"createdAt"`
+	ReviewedAt    *time.Time `json:"reviewedAt"`
+	ReviewedBy    *int       `json:"reviewedBy"`
+}
+
+// Removed counts what blocking a name took out of the graph
+type Removed struct {
+	Entities int `json:"entities" doc:"Entities with the name"`
+	Links    int `json:"links" doc:"Document links removed"`
+	Triples  int `json:"triples" doc:"Triples removed"`
+}
+
+// matchesSQL selects the entities an entry applies to, as m
+const matchesSQL = `
+	SELECT e.id FROM entities e
+	WHERE normalize_name(e.canonical_name) = s.name_key
+	  AND (s.entity_type IS NULL OR e.entity_type = s.entity_type)
+`
+
+const columns = `s.id, s.name, s.entity_type::text, s.status, s.reason, s.dataset_id, s.document_share::float8,
+	(SELECT COUNT(*) FROM (` + matchesSQL + `) m),
+	(SELECT COUNT(DISTINCT de.document_id) FROM document_entities de WHERE de.entity_id IN (` + matchesSQL + `)),
+	s.created_at, s.reviewed_at, s.reviewed_by`
+
+func scan(row pgx.Row) (*Entry, error) {
+	var e Entry
+	err := row.Scan(&e.ID, &e.Name, &e.EntityType, &e.Status, &e.Reason, &e.DatasetID, &e.DocumentShare,
+		&e.Entities, &e.Documents, &e.CreatedAt, &e.ReviewedAt, &e.ReviewedBy)
+	if err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// List returns one page of entries, optionally in one status, newest first
+func List(ctx context.Context, pool *pgxpool.Pool, status string, limit, offset int) ([]Entry, error) {
+	rows, err := pool.Query(ctx, `
+		SELECT `+columns+`
+		FROM entity_stoplist s
+		WHERE $1 = '' OR s.status = $1
+		ORDER BY s.created_at DESC, s.id DESC
+		LIMIT $2 OFFSET $3
+	`, status, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []Entry{}
+	for rows.Next() {
+		e, err := scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *e)
+	}
+	return list, rows.Err()
+}
+
+// Block puts a name on the stoplist as blocked by reviewerKey, or blocks it
+// if it is already there, and removes its entities from the graph. An empty
+// entityType blocks the name for every type. It returns ErrEmptyName for a
+// name with no letters or digits.
+func Block(ctx context.Context, pool *pgxpool.Pool, reviewerKey int, name, entityType, reason string) (*Entry, *Removed, error) {
+	var entry *Entry
+	var removed *Removed
+	err := pgx.BeginFunc(ctx, pool, func(tx pgx.Tx) error {
+		var id int
+		var key string
+		err := tx.QueryRow(ctx, `
+			INSERT INTO entity_stoplist (name, name_key, entity_type, status, reason, reviewed_at, reviewed_by)
+			VALUES ($1, normalize_name($1), NULLIF($2, '')::entity_type, 'blocked', NULLIF($3, ''), NOW(), NULLIF($4, 0))
+			ON CONFLICT (name_key, (COALESCE(entity_type::text, ''))) DO UPDATE SET
+				status = 'blocked',
+				reason = COALESCE(EXCLUDED.reason, entity_stoplist.reason),
+				reviewed_at = NOW(),
+				reviewed_by = EXCLUDED.reviewed_by
+			RETURNING id, name_key
+		`, name, entityType, reason, reviewerKey).Scan(&id, &key)
+		if err != nil {
+			return err
+		}
+		if key == "" {
+			return ErrEmptyName
+		}
+		if removed, err = apply(ctx, tx, id); err != nil {
+			return err
+		}
+		entry, err = scan(tx.QueryRow(ctx, `SELECT `+columns+` FROM entity_stoplist s WHERE s.id = $1`, id))
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return entry, removed, nil
+}
+
+// Review sets an entry's status as reviewed by reviewerKey, removing its
+// entities from the graph when it is blocked, or returns pgx.ErrNoRows.
+// Links removed by an earlier block are not restored by dismissing it;
+// re-extract the documents for that.
+func Review(ctx context.Context, pool *pgxpool.Pool, id, reviewerKey int, status string) (*Entry, *Removed, error) {
+	var entry *Entry
+	removed := &Removed{}
+	err := pgx.BeginFunc(ctx, pool, func(tx pgx.Tx) error {
+		tag, err := tx.Exec(ctx, `
+			UPDATE entity_stoplist
+			SET status = $2, reviewed_at = NOW(), reviewed_by = NULLIF($3, 0)
+			WHERE id = $1
+		`, id, status, reviewerKey)
+		if err != nil {
+			return err
+		}
+		if tag.RowsAffected() == 0 {
+			return pgx.ErrNoRows
+		}
+		if status == StatusBlocked {
+			if removed, err = apply(ctx, tx, id); err != nil {
+				return err
+			}
+		}
+		entry, err = scan(tx.QueryRow(ctx, `SELECT `+columns+` FROM entity_stoplist s WHERE s.id = $1`, id))
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return entry, removed, nil
+}
+
+// apply removes the document and page links and triples of the entities
+// entry id applies to
+func apply(ctx context.Context, tx pgx.Tx, id int) (*Removed, error) {
+	var r Removed
+	err := tx.QueryRow(ctx, `
+		WITH hit AS (
+			SELECT m.id FROM entity_stoplist s, LATERAL (`+matchesSQL+`) m
+			WHERE s.id = $1
+		),
+		links AS (
+			DELETE FROM document_entities WHERE entity_id IN (SELECT id FROM hit)
+			RETURNING 1
+		),
+		page_links AS (
+			DELETE FROM page_entities WHERE entity_id IN (SELECT id FROM hit)
+		),
+		removed_triples AS (
+			DELETE FROM triples
+			WHERE subject_id IN (SELECT id FROM hit) OR object_id IN (SELECT id FROM hit)
+			RETURNING 1
+		)
+		SELECT (SELECT COUNT(*) FROM hit), (SELECT COUNT(*) FROM links), (SELECT COUNT(*) FROM removed_triples)
+	`, id).Scan(&r.Entities, &r.Links, &r.Triples)
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// SuggestResult summarizes a suggestion run
+type SuggestResult struct {
+	Suggested int `json:"suggested" doc:"Names newly suggested"`
+}
+
+// suggestSQL suggests the people, organizations and unknown entities named
+// in at least $2 of the documents of a dataset with at least $1, by their
+// most frequent dataset. The core (layer 0) is named everywhere for good
+// reason and never suggested, and names already on the list, dismissed
+// ones included, are left alone, as are names listed for every type.
+const suggestSQL = `
+	INSERT INTO entity_stoplist (name, name_key, entity_type, status, reason, dataset_id, document_share)
+	SELECT DISTINCT ON (normalize_name(e.canonical_name), e.entity_type)
+		e.canonical_name, normalize_name(e.canonical_name), e.entity_type, 'suggested',
+		format('Named in %s%% of the %s documents in dataset %s', round(f.share * 100), f.total, f.dataset_id),
+		f.dataset_id, f.share
+	FROM (
+		SELECT de.entity_id, d.dataset_id, t.total, COUNT(*)::float8 / t.total AS share
+		FROM document_entities de
+		JOIN documents d ON d.id = de.document_id
+		JOIN (
+			SELECT dataset_id, COUNT(*) AS total
+			FROM documents
+			GROUP BY dataset_id
+			HAVING COUNT(*) >= $1
+		) t ON t.dataset_id = d.dataset_id
+		GROUP BY de.entity_id, d.dataset_id, t.total
+		HAVING COUNT(*) >= $2 * t.total
+	) f
+	JOIN entities e ON e.id = f.entity_id
+	WHERE e.entity_type IN ('person', 'organization', 'unknown')
+	  AND e.layer IS DISTINCT FROM 0
+	  AND normalize_name(e.canonical_name) <> ''
+	  AND NOT EXISTS (
+		  SELECT 1 FROM entity_stoplist s
+		  WHERE s.name_key = normalize_name(e.canonical_name) AND s.entity_type IS NULL
+	  )
+	ORDER BY normalize_name(e.canonical_name), e.entity_type, f.share DESC
+	ON CONFLICT (name_key, (COALESCE(entity_type::text, ''))) DO NOTHING
+`
+
+// Suggest adds the names frequent enough in a dataset to look like
+// boilerplate to the stoplist for review
+func Suggest(ctx context.Context, pool *pgxpool.Pool) (*SuggestResult, error) {
+	tag, err := pool.Exec(ctx, suggestSQL, MinDocuments, MinShare)
+	if err != nil {
+		return nil, err
+	}
+	return &SuggestResult{Suggested: int(tag.RowsAffected())}, nil
+}
@@ -0,0 +1,60 @@
+package store
+
+import (
+	"context"
+	"errors"
+
+	"github.com/subculture-collective/epstein-db/api/internal/models"
+)
+
+// Dataset visibilities
+const (
+	VisibilityPublic     = "public"
+	VisibilityRestricted = "restricted"
+)
+
+// Visibilities lists the dataset visibilities
+var Visibilities = []string{VisibilityPublic, VisibilityRestricted}
+
+// ErrRestricted is returned for the content of a document of a restricted
+// dataset when the context doesn't allow it
+var ErrRestricted = errors.New("restricted")
+
+type restrictedKey struct{}
+
+// WithRestricted returns a context in which the store serves the content of
+// documents of restricted datasets. Without it those documents are
+// metadata-only: summaries are left out, their text and chunks return
+// ErrRestricted and searches don't find them.
+func WithRestricted(ctx context.Context) context.Context {
+	return context.WithValue(ctx, restrictedKey{}, true)
+}
+
+// CanReadRestricted reports whether ctx allows the content of documents of
+// restricted datasets
+func CanReadRestricted(ctx context.Context) bool {
+	ok, _ := ctx.Value(restrictedKey{}).(bool)
+	return ok
+}
+
+// Readable is the SQL condition that the content of document d may be
+// served, given CanReadRestricted as parameter param
+func Readable(d, param string) string {
+	return "(" + param + "::bool OR NOT dataset_restricted(" + d + ".dataset_id))"
+}
+
+// redactSummary leaves out the summary of a document of a restricted
+// dataset unless ctx allows it
+func redactSummary(ctx context.Context, d *models.DocumentSummary) {
+	if d.Restricted && !CanReadRestricted(ctx) {
+		d.Summary = nil
+	}
+}
+
+// redactDocument leaves out the summaries of a document of a restricted
+// dataset unless ctx allows it
+func redactDocument(ctx context.Context, d *models.Document) {
+	if d.Restricted && !CanReadRestricted(ctx) {
+		d.Summary, d.DetailedSummary = nil, nil
+	}
+}
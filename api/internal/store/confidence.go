@@ -0,0 +1,27 @@
+package store
+
+import "context"
+
+type minConfidenceKey struct{}
+
+// WithMinConfidence returns a context in which the store leaves out
+// document–entity links extracted with less than min confidence, e.g. names
+// read from poor OCR. It applies to the co-occurrence network, connections,
+// the co-occurrence matrix, mention timelines and the lists of a document's
+// entities and an entity's documents.
+func WithMinConfidence(ctx context.Context, min float64) context.Context {
+	return context.WithValue(ctx, minConfidenceKey{}, min)
+}
+
+// minConfidence returns the least link confidence ctx asks for, 0 for every
+// link
+func minConfidence(ctx context.Context) float64 {
+	min, _ := ctx.Value(minConfidenceKey{}).(float64)
+	return min
+}
+
+// confident is the SQL condition that link de, with no confidence counting
+// as certain, meets the least confidence in parameter param
+func confident(de, param string) string {
+	return "COALESCE(" + de + ".extraction_confidence, 1) >= " + param + "::float8"
+}
@@ -0,0 +1,156 @@
+package store
+
+import (
+	"context"
+
+	"github.com/subculture-collective/epstein-db/api/internal/models"
+)
+
+// SearchPPP finds PPP loans by borrower name, matched as match (one of
+// MatchModes), best matches first, then largest loans, unless sorted
+// otherwise
+func (s *Store) SearchPPP(ctx context.Context, query, match string, sort Sort, limit int) ([]models.PPPLoan, error) {
+	rows, err := s.read.Query(ctx, `
+		SELECT id, borrower_name, borrower_city, borrower_state, 
+			   loan_amount, forgiveness_amount, lender, date_approved,
+			   similarity(borrower_name, $1) AS score
+		FROM ppp_loans
+		WHERE $1 = '' OR borrower_name ILIKE $3 OR ($4 AND borrower_name % $1)
+		ORDER BY `+PPPSorts.OrderBy(sort)+`
+		LIMIT $2
+	`, query, limit, namePattern(query, match), fuzzy(match))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []models.PPPLoan
+	for rows.Next() {
+		var l models.PPPLoan
+		if err := rows.Scan(&l.ID, &l.BorrowerName, &l.BorrowerCity, &l.BorrowerState, &l.LoanAmount,
+			&l.ForgivenessAmount, &l.Lender, &l.DateApproved, &l.MatchScore); err != nil {
+			skip(ctx, "ppp_loans", l.ID, err)
+			continue
+		}
+		results = append(results, l)
+	}
+	return results, rows.Err()
+}
+
+// SearchFEC finds contributions by contributor name, matched as match,
+// optionally to a candidate, best matches first, then largest amounts,
+// unless sorted otherwise
+func (s *Store) SearchFEC(ctx context.Context, query, match, candidate string, sort Sort, limit int) ([]models.FECContribution, error) {
+	rows, err := s.read.Query(ctx, `
+		SELECT id, contributor_name, contributor_city, contributor_state,
+			   contributor_employer, contributor_occupation,
+			   candidate_name, committee_name, amount, contribution_date,
+			   similarity(contributor_name, $1) AS score
+		FROM fec_contributions
+		WHERE ($1 = '' OR contributor_name ILIKE $4 OR ($5 AND contributor_name % $1))
+		  AND ($2 = '' OR candidate_name ILIKE $6)
+		ORDER BY `+FECSorts.OrderBy(sort)+`
+		LIMIT $3
+	`, query, candidate, limit, namePattern(query, match), fuzzy(match), Contains(candidate))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []models.FECContribution
+	for rows.Next() {
+		var f models.FECContribution
+		if err := rows.Scan(&f.ID, &f.ContributorName, &f.ContributorCity, &f.ContributorState, &f.Employer, &f.Occupation,
+			&f.CandidateName, &f.CommitteeName, &f.Amount, &f.ContributionDate, &f.MatchScore); err != nil {
+			skip(ctx, "fec_contributions", f.ID, err)
+			continue
+		}
+		results = append(results, f)
+	}
+	return results, rows.Err()
+}
+
+// SearchGrants finds federal grants by recipient name, matched as match,
+// optionally from an agency, best matches first, then largest awards,
+// unless sorted otherwise
+func (s *Store) SearchGrants(ctx context.Context, query, match, agency string, sort Sort, limit int) ([]models.Grant, error) {
+	rows, err := s.read.Query(ctx, `
+		SELECT id, recipient_name, recipient_city, recipient_state,
+			   awarding_agency, funding_agency, award_amount, award_date,
+			   description, cfda_title,
+			   similarity(recipient_name, $1) AS score
+		FROM federal_grants
+		WHERE ($1 = '' OR recipient_name ILIKE $4 OR ($5 AND recipient_name % $1))
+		  AND ($2 = '' OR awarding_agency ILIKE $6)
+		ORDER BY `+GrantSorts.OrderBy(sort)+`
+		LIMIT $3
+	`, query, agency, limit, namePattern(query, match), fuzzy(match), Contains(agency))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []models.Grant
+	for rows.Next() {
+		var g models.Grant
+		if err := rows.Scan(&g.ID, &g.RecipientName, &g.RecipientCity, &g.RecipientState, &g.AwardingAgency, &g.FundingAgency,
+			&g.AwardAmount, &g.AwardDate, &g.Description, &g.CFDATitle, &g.MatchScore); err != nil {
+			skip(ctx, "federal_grants", g.ID, err)
+			continue
+		}
+		results = append(results, g)
+	}
+	return results, rows.Err()
+}
+
+// Attributes cross-reference records are grouped by in SharedAttributeGroups
+const (
+	AttributeAddress  = "address"  // PPP borrowers' street address
+	AttributeEmployer = "employer" // FEC contributors' employer, or the organization itself
+)
+
+// SharedAttributes lists the attributes records can be grouped by
+var SharedAttributes = []string{AttributeAddress, AttributeEmployer}
+
+// SharedAttributeFilter narrows SharedAttributeGroups
+type SharedAttributeFilter struct {
+	Attribute   string
+	MinEntities int // only groups with at least this many linked entities
+	Limit       int
+	Offset      int
+}
+
+// SharedAttributeGroups returns groups of differently named records sharing
+// a normalized address or employer, as of the last stats refresh. Groups
+// linked to the most entities come first, then the ones with most names.
+func (s *Store) SharedAttributeGroups(ctx context.Context, f SharedAttributeFilter) ([]models.SharedGroup, error) {
+	rows, err := s.read.Query(ctx, `
+		SELECT g.attribute, g.value, g.records, g.names, g.members,
+			   COALESCE((
+				   SELECT jsonb_agg(jsonb_build_object(
+							  'id', e.id, 'canonicalName', e.canonical_name,
+							  'entityType', e.entity_type, 'layer', e.layer)
+						  ORDER BY e.document_count DESC NULLS LAST, e.id)
+				   FROM entities e WHERE e.id = ANY(g.entity_ids) AND e.deleted_at IS NULL AND NOT e.protected
+			   ), '[]')
+		FROM shared_attribute_groups g
+		WHERE g.attribute = $1 AND cardinality(g.entity_ids) >= $2
+		ORDER BY cardinality(g.entity_ids) DESC, g.names DESC, g.value
+		LIMIT $3 OFFSET $4
+	`, f.Attribute, f.MinEntities, f.Limit, f.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	groups := []models.SharedGroup{}
+	for rows.Next() {
+		var g models.SharedGroup
+		if err := rows.Scan(&g.Attribute, &g.Value, &g.Records, &g.Names, &g.Members, &g.Entities); err != nil {
+			skip(ctx, "shared_attribute_groups", g.Value, err)
+			continue
+		}
+		groups = append(groups, g)
+	}
+	return groups, rows.Err()
+}
@@ -0,0 +1,192 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/subculture-collective/epstein-db/api/internal/models"
+)
+
+// topNewEntities is how many introduced entities DatasetAnalytics lists
+const topNewEntities = 25
+
+// ListDatasets returns the registered dataset releases, optionally only
+// those with status, in ID order unless sorted otherwise
+func (s *Store) ListDatasets(ctx context.Context, status string, sort Sort) ([]models.Dataset, error) {
+	rows, err := s.pool.Query(ctx, `
+		SELECT id, name, status, document_count, registered_at, ready_at, visibility
+		FROM datasets
+		WHERE ($1 = '' OR status = $1)
+		ORDER BY `+DatasetSorts.OrderBy(sort)+`
+	`, status)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var datasets []models.Dataset
+	for rows.Next() {
+		var d models.Dataset
+		if err := rows.Scan(&d.ID, &d.Name, &d.Status, &d.DocumentCount, &d.RegisteredAt, &d.ReadyAt, &d.Visibility); err != nil {
+			skip(ctx, "datasets", d.ID, err)
+			continue
+		}
+		datasets = append(datasets, d)
+	}
+	return datasets, rows.Err()
+}
+
+// SetDatasetVisibility makes a dataset public or restricted and returns
+// it, or ErrNotFound for an unknown dataset
+func (s *Store) SetDatasetVisibility(ctx context.Context, id int, visibility string) (*models.Dataset, error) {
+	var d models.Dataset
+	err := s.pool.QueryRow(ctx, `
+		UPDATE datasets SET visibility = $2 WHERE id = $1
+		RETURNING id, name, status, document_count, registered_at, ready_at, visibility
+	`, id, visibility).Scan(&d.ID, &d.Name, &d.Status, &d.DocumentCount, &d.RegisteredAt, &d.ReadyAt, &d.Visibility)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &d, nil
+}
+
+// DatasetAnalytics summarizes dataset id: its date coverage, the entities
+// it introduced, its overlap with other datasets and the quality of its
+// text. It returns ErrNotFound for an unknown dataset.
+func (s *Store) DatasetAnalytics(ctx context.Context, id int) (*models.DatasetAnalytics, error) {
+	a := models.DatasetAnalytics{
+		Dates:       models.DateCoverage{Years: []models.YearCount{}},
+		NewEntities: models.NewEntities{Top: []models.IntroducedEntity{}},
+		Overlap:     []models.DatasetOverlap{},
+	}
+	d := &a.Dataset
+	err := s.read.QueryRow(ctx, `
+		SELECT id, name, status, document_count, registered_at, ready_at, visibility
+		FROM datasets WHERE id = $1
+	`, id).Scan(&d.ID, &d.Name, &d.Status, &d.DocumentCount, &d.RegisteredAt, &d.ReadyAt, &d.Visibility)
+	if err != nil {
+		return nil, notFound(err)
+	}
+
+	var earliest, latest *time.Time
+	err = s.read.QueryRow(ctx, `
+		SELECT COUNT(*), COUNT(*) FILTER (WHERE date_earliest IS NULL AND date_latest IS NULL),
+			   MIN(COALESCE(date_earliest, date_latest)), MAX(COALESCE(date_latest, date_earliest)),
+			   COUNT(*) FILTER (WHERE COALESCE(full_text, '') = '')
+		FROM documents WHERE dataset_id = $1 AND deleted_at IS NULL
+	`, id).Scan(&a.Documents, &a.Dates.Undated, &earliest, &latest, &a.OCR.EmptyDocuments)
+	if err != nil {
+		return nil, err
+	}
+	a.Dates.Earliest, a.Dates.Latest = dateString(earliest), dateString(latest)
+
+	rows, err := s.read.Query(ctx, `
+		SELECT EXTRACT(YEAR FROM COALESCE(date_earliest, date_latest))::int, COUNT(*)
+		FROM documents
+		WHERE dataset_id = $1 AND COALESCE(date_earliest, date_latest) IS NOT NULL AND deleted_at IS NULL
+		GROUP BY 1 ORDER BY 1
+	`, id)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var y models.YearCount
+		if err := rows.Scan(&y.Year, &y.Documents); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		a.Dates.Years = append(a.Dates.Years, y)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// An entity is new if no document of an earlier dataset mentions it
+	rows, err = s.read.Query(ctx, `
+		WITH introduced AS (
+			SELECT de.entity_id, COUNT(*) AS documents
+			FROM document_entities de
+			JOIN documents d ON d.id = de.document_id
+			WHERE d.dataset_id = $1
+			  AND NOT EXISTS (
+				  SELECT 1 FROM document_entities de2
+				  JOIN documents d2 ON d2.id = de2.document_id
+				  WHERE de2.entity_id = de.entity_id AND d2.dataset_id < $1
+			  )
+			GROUP BY de.entity_id
+		)
+		SELECT e.id, e.canonical_name, e.entity_type, e.layer, i.documents, COUNT(*) OVER ()
+		FROM introduced i
+		JOIN entities e ON e.id = i.entity_id AND NOT e.protected
+		ORDER BY i.documents DESC, e.id
+		LIMIT $2
+	`, id, topNewEntities)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var e models.IntroducedEntity
+		if err := rows.Scan(&e.ID, &e.CanonicalName, &e.EntityType, &e.Layer, &e.Documents, &a.NewEntities.Count); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		a.NewEntities.Top = append(a.NewEntities.Top, e)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = s.read.Query(ctx, `
+		WITH mine AS (
+			SELECT DISTINCT de.entity_id
+			FROM document_entities de
+			JOIN documents d ON d.id = de.document_id
+			WHERE d.dataset_id = $1
+		)
+		SELECT d.dataset_id, COALESCE(ds.name, ''), COUNT(DISTINCT de.entity_id)
+		FROM document_entities de
+		JOIN mine m ON m.entity_id = de.entity_id
+		JOIN documents d ON d.id = de.document_id
+		LEFT JOIN datasets ds ON ds.id = d.dataset_id
+		WHERE d.dataset_id <> $1
+		GROUP BY d.dataset_id, ds.name
+		ORDER BY 3 DESC, d.dataset_id
+	`, id)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var o models.DatasetOverlap
+		if err := rows.Scan(&o.DatasetID, &o.Name, &o.SharedEntities); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		a.Overlap = append(a.Overlap, o)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	err = s.read.QueryRow(ctx, `
+		SELECT COUNT(*), COUNT(*) FILTER (WHERE p.source = 'ocr'),
+			   AVG(p.confidence), COUNT(*) FILTER (WHERE p.confidence < 60)
+		FROM document_pages p
+		JOIN documents d ON d.id = p.document_id
+		WHERE d.dataset_id = $1 AND d.deleted_at IS NULL
+	`, id).Scan(&a.OCR.Pages, &a.OCR.OCRPages, &a.OCR.MeanConfidence, &a.OCR.LowConfidencePages)
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+func dateString(t *time.Time) *string {
+	if t == nil {
+		return nil
+	}
+	s := t.Format(time.DateOnly)
+	return &s
+}
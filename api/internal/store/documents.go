@@ -0,0 +1,406 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/subculture-collective/epstein-db/api/internal/models"
+)
+
+// DocumentFilter narrows ListDocuments. Zero fields match everything.
+type DocumentFilter struct {
+	Type      string
+	DatasetID int
+	Tag       string // content tag
+	Sort      Sort
+	Limit     int
+	Offset    int
+}
+
+// ListDocuments returns one page of documents, in doc_id order unless
+// sorted otherwise
+func (s *Store) ListDocuments(ctx context.Context, f DocumentFilter) ([]models.DocumentSummary, error) {
+	rows, err := s.pool.Query(ctx, `
+		SELECT id, doc_id, dataset_id, document_type, summary, date_earliest, date_latest,
+			   dataset_restricted(dataset_id), sensitivity_labels(id)
+		FROM documents
+		WHERE deleted_at IS NULL
+		  AND ($1 = '' OR document_type = $1)
+		  AND ($2 = 0 OR dataset_id = $2)
+		  AND ($5 = '' OR content_tags ? $5)
+		  AND `+Insensitive("documents", "$6")+`
+		ORDER BY `+DocumentSorts.OrderBy(f.Sort)+`
+		LIMIT $3 OFFSET $4
+	`, f.Type, f.DatasetID, f.Limit, f.Offset, f.Tag, ExcludesSensitive(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var documents []models.DocumentSummary
+	for rows.Next() {
+		var d models.DocumentSummary
+		if err := rows.Scan(&d.ID, &d.DocID, &d.DatasetID, &d.DocumentType, &d.Summary, &d.DateEarliest, &d.DateLatest, &d.Restricted, &d.Sensitivity); err != nil {
+			skip(ctx, "documents", d.ID, err)
+			continue
+		}
+		redactSummary(ctx, &d)
+		documents = append(documents, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return documents, s.maskSummaries(ctx, documents)
+}
+
+// GetDocument returns one document without its text
+func (s *Store) GetDocument(ctx context.Context, id int) (*models.Document, error) {
+	var doc models.Document
+
+	err := s.pool.QueryRow(ctx, `
+		SELECT id, doc_id, dataset_id, document_type, summary, detailed_summary,
+			   date_earliest::text, date_latest::text, COALESCE(content_tags, '[]'), page_count,
+			   dataset_restricted(dataset_id), sensitivity_labels(id), document_dispute(id), COALESCE(updated_at, 'epoch')
+		FROM documents WHERE id = $1 AND deleted_at IS NULL
+	`, id).Scan(
+		&doc.ID, &doc.DocID, &doc.DatasetID, &doc.DocumentType,
+		&doc.Summary, &doc.DetailedSummary, &doc.DateEarliest,
+		&doc.DateLatest, &doc.ContentTags, &doc.PageCount, &doc.Restricted, &doc.Sensitivity, &doc.Dispute, &doc.UpdatedAt,
+	)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	redactDocument(ctx, &doc)
+	m, err := s.masker(ctx)
+	if err != nil {
+		return nil, err
+	}
+	m.MaskPtr(doc.Summary)
+	m.MaskPtr(doc.DetailedSummary)
+	return &doc, nil
+}
+
+// sampleRows is about how many rows RandomDocument samples to choose from
+const sampleRows = 1000
+
+// RandomDocument returns a document picked at random among those matching
+// f (Sort, Limit and Offset are ignored), or ErrNotFound if none do. It samples
+// table pages rather than sorting the whole table, widening the sample
+// when a narrow filter leaves it empty.
+func (s *Store) RandomDocument(ctx context.Context, f DocumentFilter) (*models.Document, error) {
+	var estimate float64
+	err := s.read.QueryRow(ctx, "SELECT GREATEST(reltuples, 1) FROM pg_class WHERE oid = 'documents'::regclass").Scan(&estimate)
+	if err != nil {
+		return nil, err
+	}
+
+	percent := min(100, sampleRows*100/estimate)
+	for {
+		var id int
+		err := s.read.QueryRow(ctx, `
+			SELECT id
+			FROM documents TABLESAMPLE SYSTEM ($1)
+			WHERE deleted_at IS NULL
+			  AND ($2 = '' OR document_type = $2)
+			  AND ($3 = 0 OR dataset_id = $3)
+			  AND `+Insensitive("documents", "$4")+`
+			ORDER BY random()
+			LIMIT 1
+		`, percent, f.Type, f.DatasetID, ExcludesSensitive(ctx)).Scan(&id)
+		if err == nil {
+			return s.GetDocument(ctx, id)
+		}
+		if !errors.Is(err, pgx.ErrNoRows) || percent >= 100 {
+			return nil, notFound(err)
+		}
+		percent = min(100, percent*10)
+	}
+}
+
+// DocumentUpdatedAt returns when a document last changed, without reading it
+func (s *Store) DocumentUpdatedAt(ctx context.Context, id int) (time.Time, error) {
+	var updatedAt time.Time
+	err := s.pool.QueryRow(ctx, "SELECT COALESCE(updated_at, 'epoch') FROM documents WHERE id = $1 AND deleted_at IS NULL", id).Scan(&updatedAt)
+	return updatedAt, notFound(err)
+}
+
+// DocumentText returns a document's full text, which may be null, or
+// ErrRestricted
+func (s *Store) DocumentText(ctx context.Context, id int) (*string, error) {
+	var text *string
+	var readable bool
+	err := s.pool.QueryRow(ctx, `
+		SELECT full_text, `+Readable("d", "$2")+`
+		FROM documents d WHERE id = $1 AND deleted_at IS NULL
+	`, id, CanReadRestricted(ctx)).Scan(&text, &readable)
+	if err == nil && !readable {
+		return nil, ErrRestricted
+	}
+	if err != nil {
+		return nil, notFound(err)
+	}
+	m, err := s.masker(ctx)
+	if err != nil {
+		return nil, err
+	}
+	m.MaskPtr(text)
+	return text, nil
+}
+
+// DocumentReadable returns ErrRestricted if the content of a document may
+// not be served in ctx, or ErrNotFound if there is no such document
+func (s *Store) DocumentReadable(ctx context.Context, id int) error {
+	var readable bool
+	err := s.pool.QueryRow(ctx, `
+		SELECT `+Readable("d", "$2")+` FROM documents d WHERE id = $1 AND deleted_at IS NULL
+	`, id, CanReadRestricted(ctx)).Scan(&readable)
+	if err == nil && !readable {
+		return ErrRestricted
+	}
+	return notFound(err)
+}
+
+// DocumentFile returns the path of a document's original file, which is
+// empty if it has none. It returns ErrRestricted if the content may not be
+// served in ctx, and ErrProtected if the document names a protected entity,
+// since a PDF or scan can't have the name masked.
+func (s *Store) DocumentFile(ctx context.Context, id int) (string, error) {
+	var path *string
+	var readable, protected bool
+	err := s.pool.QueryRow(ctx, `
+		SELECT d.file_path, `+Readable("d", "$2")+`,
+			   EXISTS (
+				   SELECT 1 FROM document_entities de
+				   JOIN entities e ON e.id = de.entity_id
+				   WHERE de.document_id = d.id AND e.protected
+			   )
+		FROM documents d WHERE d.id = $1 AND d.deleted_at IS NULL
+	`, id, CanReadRestricted(ctx)).Scan(&path, &readable, &protected)
+	if err != nil {
+		return "", notFound(err)
+	}
+	if !readable {
+		return "", ErrRestricted
+	}
+	if protected {
+		return "", ErrProtected
+	}
+	if path == nil {
+		return "", nil
+	}
+	return *path, nil
+}
+
+// DocumentChunks returns a page of a document's chunks in order, or
+// ErrRestricted
+func (s *Store) DocumentChunks(ctx context.Context, id, limit, offset int) ([]models.DocumentChunk, error) {
+	if err := s.DocumentReadable(ctx, id); errors.Is(err, ErrRestricted) {
+		return nil, err
+	}
+	rows, err := s.read.Query(ctx, `
+		SELECT id, chunk_index, content, char_start, char_end, model, embedding IS NOT NULL
+		FROM document_chunks
+		WHERE document_id = $1
+		  AND NOT EXISTS (SELECT 1 FROM documents d WHERE d.id = $1 AND d.deleted_at IS NOT NULL)
+		ORDER BY chunk_index
+		LIMIT $2 OFFSET $3
+	`, id, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	chunks := []models.DocumentChunk{}
+	for rows.Next() {
+		var c models.DocumentChunk
+		if err := rows.Scan(&c.ID, &c.Index, &c.Content, &c.Start, &c.End, &c.Model, &c.Embedded); err != nil {
+			return nil, err
+		}
+		chunks = append(chunks, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	m, err := s.masker(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range chunks {
+		chunks[i].Content = m.Mask(chunks[i].Content)
+	}
+	return chunks, nil
+}
+
+// DocumentEntities returns the entities mentioned in a document, most
+// mentioned first. Role quotes are the document's text, so like its summary
+// they are left out for a restricted dataset unless ctx allows it.
+func (s *Store) DocumentEntities(ctx context.Context, id int, role string) ([]models.DocumentEntity, error) {
+	rows, err := s.pool.Query(ctx, `
+		SELECT e.id, e.canonical_name, e.entity_type, e.layer, de.mention_count, de.extraction_confidence,
+			   de.role, CASE WHEN `+Readable("d", "$4")+` THEN de.role_quote END, link_dispute(e.id, de.document_id)
+		FROM entities e
+		JOIN document_entities de ON e.id = de.entity_id
+		JOIN documents d ON d.id = de.document_id
+		WHERE de.document_id = $1 AND ($2 = '' OR de.role = $2) AND `+confident("de", "$3")+`
+		  AND NOT e.protected
+		ORDER BY de.mention_count DESC
+	`, id, role, minConfidence(ctx), CanReadRestricted(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entities []models.DocumentEntity
+	for rows.Next() {
+		var e models.DocumentEntity
+		if err := rows.Scan(&e.ID, &e.CanonicalName, &e.EntityType, &e.Layer, &e.MentionCount, &e.Confidence, &e.Role, &e.RoleQuote, &e.Dispute); err != nil {
+			skip(ctx, "document_entities", e.ID, err)
+			continue
+		}
+		entities = append(entities, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	m, err := s.masker(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range entities {
+		m.MaskPtr(entities[i].RoleQuote)
+	}
+	return entities, nil
+}
+
+// searchTextSQL ranks documents matching a full-text query
+const searchTextSQL = `
+	SELECT id, doc_id, document_type, summary, sensitivity_labels(id),
+		   ts_rank(to_tsvector('english', full_text), plainto_tsquery('english', $1)) AS rank,
+		   ts_headline('english', full_text, plainto_tsquery('english', $1),
+		   			   'MaxWords=50, MinWords=20, StartSel=<mark>, StopSel=</mark>') AS snippet
+	FROM documents d
+	WHERE to_tsvector('english', full_text) @@ plainto_tsquery('english', $1)
+	  AND ` + searchScope + `
+	ORDER BY rank DESC
+	LIMIT $2
+`
+
+// SearchFilter is a search query and what it is scoped to. Zero scope
+// fields match everything.
+type SearchFilter struct {
+	Query     string
+	DatasetID int
+	Type      string
+	EntityID  int     // only documents mentioning this entity
+	Tag       string  // only documents with this content tag
+	Dedupe    bool    // keep only the best match of each set of near duplicates
+	Fuzziness float64 // n-gram searches only; see SearchNgrams
+	Limit     int
+}
+
+// searchScope narrows a search over documents d to SearchFilter's scope,
+// given as $3 to $8, and to those whose content may be served
+const searchScope = `d.deleted_at IS NULL
+	  AND ($7 OR NOT dataset_restricted(d.dataset_id))
+	  AND NOT ($8 AND sensitivity_labels(d.id) <> '{}')
+	  AND ($3 = 0 OR d.dataset_id = $3)
+	  AND ($4 = '' OR d.document_type = $4)
+	  AND ($5 = 0 OR EXISTS (
+		  SELECT 1 FROM document_entities de JOIN entities e ON e.id = de.entity_id
+		  WHERE de.document_id = d.id AND de.entity_id = $5 AND NOT e.protected))
+	  AND ($6 = '' OR d.content_tags ? $6)`
+
+// args are the query arguments of a search in ctx
+func (f SearchFilter) args(ctx context.Context) []any {
+	limit := f.Limit
+	if f.Dedupe {
+		limit *= dedupeFactor
+	}
+	return []any{f.Query, limit, f.DatasetID, f.Type, f.EntityID, f.Tag, CanReadRestricted(ctx), ExcludesSensitive(ctx)}
+}
+
+// SearchText runs a full-text query over document text, best matches first
+func (s *Store) SearchText(ctx context.Context, f SearchFilter) ([]models.SearchResult, error) {
+	rows, err := s.read.Query(ctx, searchTextSQL, f.args(ctx)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []models.SearchResult
+	for rows.Next() {
+		var r models.SearchResult
+		if err := rows.Scan(&r.ID, &r.DocID, &r.DocumentType, &r.Summary, &r.Sensitivity, &r.Rank, &r.Snippet); err != nil {
+			skip(ctx, "documents", r.ID, err)
+			continue
+		}
+		results = append(results, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if f.Dedupe {
+		if results, err = s.collapseDuplicates(ctx, results, f.Limit); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.maskResults(ctx, results); err != nil {
+		return nil, err
+	}
+	return results, s.markEntities(ctx, results)
+}
+
+// SearchTextPlan estimates the cost of SearchText
+func (s *Store) SearchTextPlan(ctx context.Context, f SearchFilter) (Plan, error) {
+	return s.explain(ctx, searchTextSQL, f.args(ctx)...)
+}
+
+// SearchTerms counts the searchable terms in a full-text query. A query of
+// only stop words or punctuation has none, and can't use the text index.
+func (s *Store) SearchTerms(ctx context.Context, query string) (int, error) {
+	var n int
+	err := s.read.QueryRow(ctx, `SELECT numnode(plainto_tsquery('english', $1))`, query).Scan(&n)
+	return n, err
+}
+
+// DocumentProvenance traces a document to its source file and OCR output
+func (s *Store) DocumentProvenance(ctx context.Context, id int) (*models.Provenance, error) {
+	p := models.Provenance{ID: id}
+	var source models.SourceFile
+	var lines models.LineRange
+	var ocr models.OCRSummary
+
+	err := s.pool.QueryRow(ctx, `
+		SELECT d.doc_id, d.dataset_id, d.file_path, d.source_line_start, d.source_line_end,
+			   s.id, s.filename, s.location, s.sha256, s.byte_size, s.document_count,
+			   s.first_doc_id, s.last_doc_id, s.ingested_at, s.completed_at,
+			   (SELECT COUNT(*) FROM document_pages p WHERE p.document_id = d.id),
+			   (SELECT AVG(confidence) FROM document_pages p WHERE p.document_id = d.id),
+			   (SELECT MAX(engine) FROM document_pages p WHERE p.document_id = d.id)
+		FROM documents d
+		LEFT JOIN source_files s ON s.id = d.source_file_id
+		WHERE d.id = $1 AND d.deleted_at IS NULL
+	`, id).Scan(
+		&p.DocID, &p.DatasetID, &p.FilePath, &lines.Start, &lines.End,
+		&source.ID, &source.Filename, &source.Location, &source.SHA256, &source.ByteSize, &source.DocumentCount,
+		&source.FirstDocID, &source.LastDocID, &source.IngestedAt, &source.CompletedAt,
+		&ocr.Pages, &ocr.MeanConfidence, &ocr.Engine,
+	)
+	if err != nil {
+		return nil, notFound(err)
+	}
+
+	if source.ID != nil {
+		p.SourceFile = &source
+	}
+	if lines.Start != nil {
+		p.Lines = &lines
+	}
+	if ocr.Pages > 0 {
+		p.OCR = &ocr
+	}
+	return &p, nil
+}
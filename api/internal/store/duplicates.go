@@ -0,0 +1,104 @@
+package store
+
+import (
+	"context"
+
+	"github.com/subculture-collective/epstein-db/api/internal/models"
+)
+
+// dedupeFactor is how many more results a deduplicated search fetches than
+// it returns, so collapsing duplicates still leaves a full page
+const dedupeFactor = 3
+
+// DocumentDuplicates returns the near duplicates of a document, as found by
+// package duplicates, most similar first, or nil if it has none
+func (s *Store) DocumentDuplicates(ctx context.Context, id int) ([]models.DuplicateDocument, error) {
+	rows, err := s.read.Query(ctx, `
+		SELECT d.id, d.doc_id, d.dataset_id, d.document_type, d.summary, d.date_earliest, d.date_latest,
+			   dataset_restricted(d.dataset_id), sensitivity_labels(d.id), 1 - bit_count((f1.simhash # f2.simhash)::bit(64))::float8 / 64 AS similarity
+		FROM document_duplicates dup1
+		JOIN document_duplicates dup2 ON dup2.cluster_id = dup1.cluster_id AND dup2.document_id <> dup1.document_id
+		JOIN documents d ON d.id = dup2.document_id
+		JOIN document_fingerprints f1 ON f1.document_id = dup1.document_id
+		JOIN document_fingerprints f2 ON f2.document_id = dup2.document_id
+		WHERE dup1.document_id = $1 AND d.deleted_at IS NULL
+		ORDER BY similarity DESC, d.id
+	`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var documents []models.DuplicateDocument
+	for rows.Next() {
+		var d models.DuplicateDocument
+		if err := rows.Scan(&d.ID, &d.DocID, &d.DatasetID, &d.DocumentType, &d.Summary, &d.DateEarliest, &d.DateLatest, &d.Restricted, &d.Sensitivity, &d.Similarity); err != nil {
+			skip(ctx, "documents", d.ID, err)
+			continue
+		}
+		redactSummary(ctx, &d.DocumentSummary)
+		documents = append(documents, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	m, err := s.masker(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range documents {
+		m.MaskPtr(documents[i].Summary)
+	}
+	return documents, nil
+}
+
+// collapseDuplicates keeps the best ranked result of each duplicate
+// cluster, counting the others under it, and returns at most limit results
+func (s *Store) collapseDuplicates(ctx context.Context, results []models.SearchResult, limit int) ([]models.SearchResult, error) {
+	if len(results) == 0 {
+		return results, nil
+	}
+	ids := make([]int, len(results))
+	for i, r := range results {
+		ids[i] = r.ID
+	}
+	rows, err := s.read.Query(ctx, `
+		SELECT document_id, cluster_id FROM document_duplicates WHERE document_id = ANY($1)
+	`, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	clusters := map[int]int{}
+	for rows.Next() {
+		var id, cluster int
+		if err := rows.Scan(&id, &cluster); err != nil {
+			return nil, err
+		}
+		clusters[id] = cluster
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Results are best first, so the first of a cluster is kept
+	kept := results[:0]
+	first := map[int]int{} // cluster to its index in kept
+	for _, r := range results {
+		cluster, ok := clusters[r.ID]
+		if !ok {
+			kept = append(kept, r)
+			continue
+		}
+		if i, seen := first[cluster]; seen {
+			kept[i].Collapsed++
+			continue
+		}
+		first[cluster] = len(kept)
+		kept = append(kept, r)
+	}
+	if len(kept) > limit {
+		kept = kept[:limit]
+	}
+	return kept, nil
+}
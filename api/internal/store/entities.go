@@ -0,0 +1,512 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/subculture-collective/epstein-db/api/internal/models"
+)
+
+// Stats returns the precomputed counts from the stats views, which
+// RefreshStats brings up to date. The views are read concurrently. One that
+// doesn't exist yet or was never populated is listed in Unavailable rather
+// than failing the whole call; any other error does.
+func (s *Store) Stats(ctx context.Context) (models.Stats, error) {
+	var (
+		stats models.Stats
+		mu    sync.Mutex
+	)
+	unavailable := func(view string, err error) error {
+		if !missingView(err) {
+			return err
+		}
+		mu.Lock()
+		stats.Unavailable = append(stats.Unavailable, view)
+		mu.Unlock()
+		return nil
+	}
+
+	// Each reader fills its own fields of stats
+	g, ctx := errgroup.WithContext(ctx)
+	g.Go(func() error { return unavailable("stats_totals", s.statsTotals(ctx, &stats)) })
+	g.Go(func() error { return unavailable("stats_by_dataset", s.statsByDataset(ctx, &stats)) })
+	g.Go(func() error { return unavailable("stats_by_entity_type", s.statsByEntityType(ctx, &stats)) })
+	if err := g.Wait(); err != nil {
+		return models.Stats{}, err
+	}
+	slices.Sort(stats.Unavailable)
+	return stats, nil
+}
+
+func (s *Store) statsTotals(ctx context.Context, stats *models.Stats) error {
+	tables := []models.TableStats{
+		{Table: "documents"}, {Table: "entities"}, {Table: "triples"}, {Table: "ppp_loans"},
+		{Table: "fec_contributions"}, {Table: "federal_grants"}, {Table: "pattern_findings"},
+	}
+	err := s.read.QueryRow(ctx, `
+		SELECT documents, entities, triples, ppp_loans, fec_records, grants, patterns,
+		       documents_changed_at, entities_changed_at, triples_changed_at, ppp_loans_changed_at,
+		       fec_records_changed_at, grants_changed_at, patterns_changed_at, refreshed_at
+		FROM stats_totals
+	`).Scan(&tables[0].Rows, &tables[1].Rows, &tables[2].Rows, &tables[3].Rows,
+		&tables[4].Rows, &tables[5].Rows, &tables[6].Rows,
+		&tables[0].LastChanged, &tables[1].LastChanged, &tables[2].LastChanged, &tables[3].LastChanged,
+		&tables[4].LastChanged, &tables[5].LastChanged, &tables[6].LastChanged, &stats.LastRefreshed)
+	if err != nil {
+		return err
+	}
+
+	stats.Documents, stats.Entities, stats.Triples = tables[0].Rows, tables[1].Rows, tables[2].Rows
+	stats.PPPLoans, stats.FECRecords, stats.Grants = tables[3].Rows, tables[4].Rows, tables[5].Rows
+	stats.Patterns = tables[6].Rows
+	stats.Tables = tables
+	return nil
+}
+
+func (s *Store) statsByDataset(ctx context.Context, stats *models.Stats) error {
+	rows, err := s.read.Query(ctx, `
+		SELECT dataset_id, name, documents, pages, entities
+		FROM stats_by_dataset
+		ORDER BY dataset_id
+	`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	stats.ByDataset = []models.DatasetStats{}
+	for rows.Next() {
+		var d models.DatasetStats
+		if err := rows.Scan(&d.DatasetID, &d.Name, &d.Documents, &d.Pages, &d.Entities); err != nil {
+			return err
+		}
+		stats.ByDataset = append(stats.ByDataset, d)
+	}
+	return rows.Err()
+}
+
+func (s *Store) statsByEntityType(ctx context.Context, stats *models.Stats) error {
+	rows, err := s.read.Query(ctx, `
+		SELECT entity_type::text, entities, mentions
+		FROM stats_by_entity_type
+		ORDER BY entities DESC
+	`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	stats.ByEntityType = []models.EntityTypeStats{}
+	for rows.Next() {
+		var t models.EntityTypeStats
+		if err := rows.Scan(&t.EntityType, &t.Entities, &t.Mentions); err != nil {
+			return err
+		}
+		stats.ByEntityType = append(stats.ByEntityType, t)
+	}
+	return rows.Err()
+}
+
+// missingView reports whether err means a stats view isn't there to read:
+// its migration hasn't run, or it was created but never refreshed
+func missingView(err error) bool {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return false
+	}
+	return pgErr.Code == "42P01" || // undefined_table
+		pgErr.Code == "55000" // object_not_in_prerequisite_state
+}
+
+// EntityTypes are the values of the entity_type enum
+var EntityTypes = []string{"person", "organization", "location", "date", "reference", "financial", "unknown"}
+
+// EntityTypeCounts returns every entity type with its entity count as of
+// the last stats refresh, including types no entity has yet, in the enum's
+// order
+func (s *Store) EntityTypeCounts(ctx context.Context) ([]models.TypeCount, error) {
+	rows, err := s.read.Query(ctx, `
+		SELECT t::text, COALESCE(st.entities, 0)
+		FROM unnest(enum_range(NULL::entity_type)) AS t
+		LEFT JOIN stats_by_entity_type st ON st.entity_type = t
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	types := []models.TypeCount{}
+	for rows.Next() {
+		var t models.TypeCount
+		if err := rows.Scan(&t.Type, &t.Count); err != nil {
+			return nil, err
+		}
+		types = append(types, t)
+	}
+	return types, rows.Err()
+}
+
+// DocumentTypeCounts returns the document types in use with their document
+// counts as of the last stats refresh, most common first
+func (s *Store) DocumentTypeCounts(ctx context.Context) ([]models.TypeCount, error) {
+	rows, err := s.read.Query(ctx, `
+		SELECT document_type, documents
+		FROM stats_by_document_type
+		ORDER BY documents DESC, document_type
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	types := []models.TypeCount{}
+	for rows.Next() {
+		var t models.TypeCount
+		if err := rows.Scan(&t.Type, &t.Count); err != nil {
+			return nil, err
+		}
+		types = append(types, t)
+	}
+	return types, rows.Err()
+}
+
+// RefreshStats recomputes the stats views. Readers see the old counts until
+// it finishes.
+func (s *Store) RefreshStats(ctx context.Context) error {
+	_, err := s.pool.Exec(ctx, `SELECT refresh_stats()`)
+	return err
+}
+
+// EntityFilter narrows SearchEntities. Empty fields match everything.
+type EntityFilter struct {
+	Query string
+	Match string // one of MatchModes; fuzzy when empty
+	Type  string
+	Layer string
+	Sort  Sort
+	Limit int
+}
+
+// SearchEntities finds entities by name, by default by substring or trigram
+// similarity, best matches first unless sorted otherwise
+func (s *Store) SearchEntities(ctx context.Context, f EntityFilter) ([]models.EntitySummary, error) {
+	rows, err := s.read.Query(ctx, `
+		SELECT id, canonical_name, entity_type, layer, dist.distance, document_count, connection_count, `+primaryImageSQL+`
+		FROM entities
+		LEFT JOIN entity_distances dist ON dist.entity_id = entities.id
+		WHERE deleted_at IS NULL AND NOT protected
+		  AND ($1 = '' OR canonical_name ILIKE $5 OR ($6 AND canonical_name % $1))
+		  AND ($2 = '' OR entity_type = $2::entity_type)
+		  AND ($3 = '' OR layer = $3::int)
+		ORDER BY `+EntitySorts.OrderBy(f.Sort)+`
+		LIMIT $4
+	`, f.Query, f.Type, f.Layer, f.Limit, namePattern(f.Query, f.Match), fuzzy(f.Match))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entities []models.EntitySummary
+	for rows.Next() {
+		var e models.EntitySummary
+		if err := rows.Scan(&e.ID, &e.CanonicalName, &e.EntityType, &e.Layer, &e.DistanceToCore, &e.DocumentCount, &e.ConnectionCount, &e.ImageID); err != nil {
+			skip(ctx, "entities", e.ID, err)
+			continue
+		}
+		entities = append(entities, e)
+	}
+	return entities, rows.Err()
+}
+
+// primaryImageSQL selects the primary image of the entity in the row
+const primaryImageSQL = `(SELECT m.id FROM entity_media m WHERE m.entity_id = entities.id AND m.is_primary)`
+
+// descriptionOriginSQL selects who wrote the current description of the
+// entity in the row
+const descriptionOriginSQL = `(SELECT r.origin FROM entity_description_revisions r
+	WHERE r.entity_id = entities.id AND entities.description IS NOT NULL
+	ORDER BY r.revision DESC LIMIT 1)`
+
+// GetEntity returns one entity with its cross-reference matches
+func (s *Store) GetEntity(ctx context.Context, id int) (*models.Entity, error) {
+	var entity models.Entity
+
+	err := s.pool.QueryRow(ctx, `
+		SELECT id, canonical_name, entity_type, layer, dist.distance, description, `+descriptionOriginSQL+`,
+			   document_count, connection_count, COALESCE(aliases, '[]'),
+			   COALESCE(ppp_matches, '[]'), COALESCE(fec_matches, '[]'), COALESCE(grants_matches, '[]'),
+			   `+primaryImageSQL+`, entity_dispute(id), GREATEST(COALESCE(updated_at, 'epoch'), dist.computed_at)
+		FROM entities
+		LEFT JOIN entity_distances dist ON dist.entity_id = entities.id
+		WHERE id = $1 AND deleted_at IS NULL AND NOT protected
+	`, id).Scan(
+		&entity.ID, &entity.CanonicalName, &entity.EntityType,
+		&entity.Layer, &entity.DistanceToCore, &entity.Description, &entity.DescriptionOrigin, &entity.DocumentCount,
+		&entity.ConnectionCount, &entity.Aliases,
+		&entity.PPPMatches, &entity.FECMatches, &entity.GrantsMatches,
+		&entity.ImageID, &entity.Dispute, &entity.UpdatedAt,
+	)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &entity, nil
+}
+
+// EntityConnections returns the entities that co-occur with entity id at
+// the given granularity, unless sorted otherwise most shared documents
+// first at document granularity and closest first at page granularity
+func (s *Store) EntityConnections(ctx context.Context, id int, granularity string, sort Sort, limit int) ([]models.Connection, error) {
+	if granularity == GranularityPage && sort.Key == "" {
+		sort.Key = "proximity"
+	}
+	query := `
+		SELECT 
+			e2.id AS id, e2.canonical_name AS canonical_name, e2.entity_type, e2.layer,
+			COUNT(DISTINCT d.id) AS shared_docs, NULL::float8 AS proximity
+		FROM document_entities de1
+		JOIN document_entities de2 ON de1.document_id = de2.document_id AND de1.entity_id != de2.entity_id
+		JOIN entities e2 ON de2.entity_id = e2.id
+		JOIN documents d ON de1.document_id = d.id
+		WHERE de1.entity_id = $1 AND NOT e2.protected
+		  AND ` + confident("de1", "$3") + ` AND ` + confident("de2", "$3") + `
+		GROUP BY e2.id, e2.canonical_name, e2.entity_type, e2.layer
+		ORDER BY ` + ConnectionSorts.OrderBy(sort) + `
+		LIMIT $2
+	`
+	if granularity == GranularityPage {
+		query = `
+		SELECT e.id AS id, e.canonical_name AS canonical_name, e.entity_type, e.layer,
+			COUNT(*) AS shared_docs, SUM(pairs.closeness) AS proximity
+		FROM (` + pagePairsSQL("p2.entity_id != p1.entity_id",
+			"WHERE p1.entity_id = $1 AND "+confident("de1", "$3")+" AND "+confident("de2", "$3")) + `) pairs
+		JOIN entities e ON e.id = pairs.target AND NOT e.protected
+		GROUP BY e.id, e.canonical_name, e.entity_type, e.layer
+		ORDER BY ` + ConnectionSorts.OrderBy(sort) + `
+		LIMIT $2
+	`
+	}
+
+	rows, err := s.read.Query(ctx, query, id, limit, minConfidence(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var connections []models.Connection
+	for rows.Next() {
+		var conn models.Connection
+		if err := rows.Scan(&conn.ID, &conn.CanonicalName, &conn.EntityType, &conn.Layer, &conn.SharedDocs, &conn.Proximity); err != nil {
+			skip(ctx, "entities", conn.ID, err)
+			continue
+		}
+		connections = append(connections, conn)
+	}
+	return connections, rows.Err()
+}
+
+// EntityDocumentFilter narrows EntityDocuments. Empty fields match
+// everything.
+type EntityDocumentFilter struct {
+	Role  string
+	From  *time.Time // documents whose dates overlap From and To; undated
+	To    *time.Time // documents are left out when either is set
+	Sort  Sort
+	Limit int
+}
+
+// EntityDocuments returns the documents that mention entity id, newest
+// first unless sorted otherwise, optionally only those where it has a role
+// or dated within a range
+func (s *Store) EntityDocuments(ctx context.Context, id int, f EntityDocumentFilter) ([]models.EntityDocument, error) {
+	rows, err := s.pool.Query(ctx, `
+		SELECT d.id, d.doc_id, d.dataset_id, d.document_type, d.summary, d.date_earliest, d.date_latest,
+		       dataset_restricted(d.dataset_id), sensitivity_labels(d.id), de.extraction_confidence, de.role,
+		       CASE WHEN `+Readable("d", "$8")+` THEN de.role_quote END, link_dispute(de.entity_id, d.id)
+		FROM documents d
+		JOIN document_entities de ON d.id = de.document_id
+		WHERE de.entity_id = $1 AND ($2 = '' OR de.role = $2) AND `+confident("de", "$4")+`
+		  AND NOT EXISTS (SELECT 1 FROM entities e WHERE e.id = $1 AND e.protected)
+		  AND `+Insensitive("d", "$5")+`
+		  AND ($6::date IS NULL OR COALESCE(d.date_latest, d.date_earliest) >= $6)
+		  AND ($7::date IS NULL OR COALESCE(d.date_earliest, d.date_latest) <= $7)
+		ORDER BY `+EntityDocumentSorts.OrderBy(f.Sort)+`
+		LIMIT $3
+	`, id, f.Role, f.Limit, minConfidence(ctx), ExcludesSensitive(ctx), f.From, f.To, CanReadRestricted(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var documents []models.EntityDocument
+	for rows.Next() {
+		var d models.EntityDocument
+		if err := rows.Scan(&d.ID, &d.DocID, &d.DatasetID, &d.DocumentType, &d.Summary, &d.DateEarliest, &d.DateLatest, &d.Restricted, &d.Sensitivity, &d.Confidence, &d.Role, &d.RoleQuote, &d.Dispute); err != nil {
+			skip(ctx, "documents", d.ID, err)
+			continue
+		}
+		redactSummary(ctx, &d.DocumentSummary)
+		documents = append(documents, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	m, err := s.masker(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range documents {
+		m.MaskPtr(documents[i].Summary)
+		m.MaskPtr(documents[i].RoleQuote)
+	}
+	return documents, nil
+}
+
+// EntitiesByID returns brief details of the given entities
+func (s *Store) EntitiesByID(ctx context.Context, ids []int) ([]models.EntityBrief, error) {
+	rows, err := s.pool.Query(ctx, `
+		SELECT id, canonical_name, entity_type, layer
+		FROM entities WHERE id = ANY($1) AND deleted_at IS NULL AND NOT protected
+	`, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entities []models.EntityBrief
+	for rows.Next() {
+		var e models.EntityBrief
+		if err := rows.Scan(&e.ID, &e.CanonicalName, &e.EntityType, &e.Layer); err != nil {
+			skip(ctx, "entities", e.ID, err)
+			continue
+		}
+		entities = append(entities, e)
+	}
+	return entities, rows.Err()
+}
+
+// MentionFilter selects the documents EntityMentions counts. Interval is
+// one of MentionIntervals.
+type MentionFilter struct {
+	EntityIDs []int
+	Interval  string
+	From      *time.Time
+	To        *time.Time
+}
+
+// MentionIntervals are the bucket sizes of a mention series
+var MentionIntervals = []string{"week", "month", "quarter", "year"}
+
+// EntityMentions counts the documents mentioning each entity per period of
+// the document's earliest date (or its latest, if that's all it has). Every
+// series covers the same periods, from the first to the last with any
+// mentions, zero-filled.
+func (s *Store) EntityMentions(ctx context.Context, f MentionFilter) (*models.Mentions, error) {
+	found, err := s.EntitiesByID(ctx, f.EntityIDs)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[int]models.EntityBrief, len(found))
+	for _, e := range found {
+		byID[e.ID] = e
+	}
+
+	m := &models.Mentions{Interval: f.Interval, Periods: []string{}, Series: []models.MentionSeries{}, Missing: []int{}}
+	index := make(map[int]int, len(found))
+	for _, id := range f.EntityIDs {
+		e, ok := byID[id]
+		if !ok {
+			m.Missing = append(m.Missing, id)
+			continue
+		}
+		index[id] = len(m.Series)
+		m.Series = append(m.Series, models.MentionSeries{Entity: e})
+	}
+
+	type count struct {
+		entity              int
+		period              time.Time
+		documents, mentions int
+	}
+	var counts []count
+	var first, last time.Time
+
+	rows, err := s.read.Query(ctx, `
+		SELECT de.entity_id, date_trunc($2, COALESCE(d.date_earliest, d.date_latest)::timestamp)::date,
+			   COUNT(*), COALESCE(SUM(COALESCE(de.mention_count, 1)), 0)
+		FROM document_entities de
+		JOIN documents d ON d.id = de.document_id
+		WHERE de.entity_id = ANY($1)
+		  AND ($3::date IS NULL OR COALESCE(d.date_earliest, d.date_latest) >= $3)
+		  AND ($4::date IS NULL OR COALESCE(d.date_earliest, d.date_latest) <= $4)
+		  AND `+confident("de", "$5")+`
+		GROUP BY 1, 2
+	`, f.EntityIDs, f.Interval, f.From, f.To, minConfidence(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var c count
+		var period *time.Time
+		if err := rows.Scan(&c.entity, &period, &c.documents, &c.mentions); err != nil {
+			return nil, err
+		}
+		i, ok := index[c.entity]
+		if !ok {
+			continue
+		}
+		if period == nil {
+			m.Series[i].Undated += c.documents
+			continue
+		}
+		c.period = *period
+		if first.IsZero() || c.period.Before(first) {
+			first = c.period
+		}
+		if c.period.After(last) {
+			last = c.period
+		}
+		counts = append(counts, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	periods := map[time.Time]int{}
+	if !first.IsZero() {
+		for p := first; !p.After(last); p = nextPeriod(p, f.Interval) {
+			periods[p] = len(m.Periods)
+			m.Periods = append(m.Periods, p.Format(time.DateOnly))
+		}
+	}
+	for i := range m.Series {
+		m.Series[i].Documents = make([]int, len(m.Periods))
+		m.Series[i].Mentions = make([]int, len(m.Periods))
+	}
+	for _, c := range counts {
+		series, p := &m.Series[index[c.entity]], periods[c.period]
+		series.Documents[p] = c.documents
+		series.Mentions[p] = c.mentions
+	}
+	return m, nil
+}
+
+// nextPeriod returns the start of the period after p
+func nextPeriod(p time.Time, interval string) time.Time {
+	switch interval {
+	case "week":
+		return p.AddDate(0, 0, 7)
+	case "quarter":
+		return p.AddDate(0, 3, 0)
+	case "year":
+		return p.AddDate(1, 0, 0)
+	default:
+		return p.AddDate(0, 1, 0)
+	}
+}
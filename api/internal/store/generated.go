@@ -0,0 +1,68 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/subculture-collective/epstein-db/api/internal/bio"
+	"github.com/subculture-collective/epstein-db/api/internal/narrative"
+)
+
+// EntityBio returns the stored biography of an entity. Like the entity
+// itself, it is not found once the entity is protected or deleted, and the
+// names of protected entities in it are masked.
+func (s *Store) EntityBio(ctx context.Context, id int) (*bio.Bio, error) {
+	b := bio.Bio{EntityID: id}
+	var citations []byte
+	err := s.pool.QueryRow(ctx, `
+		SELECT b.bio, b.citations, b.sources, b.model, b.generated_at
+		FROM entity_bios b
+		JOIN entities e ON e.id = b.entity_id
+		WHERE b.entity_id = $1 AND e.deleted_at IS NULL AND NOT e.protected
+	`, id).Scan(&b.Text, &citations, &b.Sources, &b.Model, &b.GeneratedAt)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	if err := json.Unmarshal(citations, &b.Citations); err != nil {
+		return nil, err
+	}
+
+	m, err := s.masker(ctx)
+	if err != nil {
+		return nil, err
+	}
+	b.Text = m.Mask(b.Text)
+	for i := range b.Citations {
+		b.Citations[i].Quote = m.Mask(b.Citations[i].Quote)
+	}
+	return &b, nil
+}
+
+// EntityNarrative returns the stored narrative of an entity, not found once
+// the entity is protected or deleted and with protected names masked
+func (s *Store) EntityNarrative(ctx context.Context, id int) (*narrative.Narrative, error) {
+	n := narrative.Narrative{EntityID: id}
+	var sentences []byte
+	err := s.pool.QueryRow(ctx, `
+		SELECT n.sentences, n.sources, n.model, n.stale, n.generated_at
+		FROM entity_narratives n
+		JOIN entities e ON e.id = n.entity_id
+		WHERE n.entity_id = $1 AND e.deleted_at IS NULL AND NOT e.protected
+	`, id).Scan(&sentences, &n.Sources, &n.Model, &n.Stale, &n.GeneratedAt)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	if err := json.Unmarshal(sentences, &n.Sentences); err != nil {
+		return nil, err
+	}
+
+	m, err := s.masker(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range n.Sentences {
+		n.Sentences[i].Text = m.Mask(n.Sentences[i].Text)
+		n.Sentences[i].Citation.Quote = m.Mask(n.Sentences[i].Citation.Quote)
+	}
+	return &n, nil
+}
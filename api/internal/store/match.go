@@ -0,0 +1,46 @@
+package store
+
+import "strings"
+
+// How name searches match the query
+const (
+	MatchFuzzy  = "fuzzy"  // substring or trigram similarity
+	MatchPrefix = "prefix" // names starting with the query
+	MatchExact  = "exact"  // the whole name, ignoring case
+)
+
+// MatchModes lists the name search modes, default first
+var MatchModes = []string{MatchFuzzy, MatchPrefix, MatchExact}
+
+// likeEscaper escapes LIKE's wildcards and its escape character, which is
+// backslash unless a query says otherwise
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// LikeEscape makes s match itself literally in a LIKE or ILIKE pattern
+func LikeEscape(s string) string {
+	return likeEscaper.Replace(s)
+}
+
+// Contains is the ILIKE pattern for names containing s
+func Contains(s string) string {
+	return "%" + LikeEscape(s) + "%"
+}
+
+// namePattern is the ILIKE pattern matching query under mode. Fuzzy
+// searches also match by trigram similarity, which namePattern leaves to
+// the query.
+func namePattern(query, mode string) string {
+	switch mode {
+	case MatchExact:
+		return LikeEscape(query)
+	case MatchPrefix:
+		return LikeEscape(query) + "%"
+	default:
+		return Contains(query)
+	}
+}
+
+// fuzzy reports whether mode also matches by similarity
+func fuzzy(mode string) bool {
+	return mode != MatchExact && mode != MatchPrefix
+}
@@ -0,0 +1,692 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/subculture-collective/epstein-db/api/internal/models"
+)
+
+// NetworkUpdatedAt dates the co-occurrence network. Changes to mentions
+// touch entities.updated_at via the stats trigger, so the newest entity
+// dates the whole network, unless the distances to the core or the edge
+// scores were recomputed since; each is replaced all at once, so any row
+// dates it.
+func (s *Store) NetworkUpdatedAt(ctx context.Context) (time.Time, error) {
+	var updatedAt time.Time
+	err := s.read.QueryRow(ctx, `
+		SELECT GREATEST(
+			COALESCE(MAX(updated_at), 'epoch'),
+			(SELECT computed_at FROM entity_distances LIMIT 1),
+			(SELECT computed_at FROM entity_edges LIMIT 1)
+		)
+		FROM entities
+	`).Scan(&updatedAt)
+	return updatedAt, err
+}
+
+// Co-occurrence granularities
+const (
+	GranularityDocument = "document" // named in the same document
+	GranularityPage     = "page"     // named within PageWindow pages of each other
+)
+
+// Granularities lists the co-occurrence granularities, default first
+var Granularities = []string{GranularityDocument, GranularityPage}
+
+// At page granularity mentions up to PageWindow pages apart co-occur, and
+// each page between them multiplies their closeness by PageDecay: 1 on the
+// same page, 0.5 on the next, 0.25 two pages on.
+const (
+	PageWindow = 3
+	PageDecay  = 0.5
+)
+
+// pagePairsSQL finds the pairs of entities named within PageWindow pages of
+// each other, with their closeness in each document they share, from
+// page_entities. pair relates p2.entity_id to p1.entity_id, and where
+// filters the pair's entities, e1 and e2, and their document links, de1
+// and de2.
+func pagePairsSQL(pair, where string) string {
+	return fmt.Sprintf(`
+		SELECT p1.entity_id AS source, p2.entity_id AS target,
+			   MAX(power(%g::float8, abs(p1.page_number - p2.page_number))) AS closeness
+		FROM page_entities p1
+		JOIN page_entities p2 ON p2.document_id = p1.document_id
+			AND p2.page_number BETWEEN p1.page_number - %d AND p1.page_number + %d
+			AND %s
+		JOIN entities e1 ON p1.entity_id = e1.id
+		JOIN entities e2 ON p2.entity_id = e2.id
+		JOIN document_entities de1 ON de1.document_id = p1.document_id AND de1.entity_id = p1.entity_id
+		JOIN document_entities de2 ON de2.document_id = p2.document_id AND de2.entity_id = p2.entity_id
+		%s
+		GROUP BY p1.document_id, p1.entity_id, p2.entity_id
+	`, PageDecay, PageWindow, PageWindow, pair, where)
+}
+
+// NetworkFilter narrows Network
+type NetworkFilter struct {
+	MinConnections int
+	Limit          int     // nodes; up to three times as many edges are kept
+	MinScore       float64 // only edges scored at least this, when positive
+	Granularity    string  // one of Granularities; empty for document
+	Sort           Sort    // which edges are kept: EdgeSorts
+}
+
+// networkPairsSQL counts co-occurrences between connected people and
+// organizations at the given granularity
+func networkPairsSQL(granularity string) string {
+	if granularity == GranularityPage {
+		return `
+		SELECT source, target, COUNT(*) AS weight, SUM(closeness) AS proximity
+		FROM (` + pagePairsSQL("p2.entity_id > p1.entity_id", `
+			WHERE e1.entity_type IN ('person', 'organization')
+			  AND e2.entity_type IN ('person', 'organization')
+			  AND e1.connection_count >= $1
+			  AND e2.connection_count >= $1
+			  AND `+confident("de1", "$4")+`
+			  AND `+confident("de2", "$4")) + `) pairs
+		GROUP BY source, target
+		HAVING SUM(closeness) >= 1`
+	}
+	return `
+		SELECT
+			de1.entity_id AS source,
+			de2.entity_id AS target,
+			COUNT(DISTINCT de1.document_id) AS weight,
+			NULL::float8 AS proximity
+		FROM document_entities de1
+		JOIN document_entities de2 ON de1.document_id = de2.document_id
+			AND de1.entity_id < de2.entity_id
+		JOIN entities e1 ON de1.entity_id = e1.id
+		JOIN entities e2 ON de2.entity_id = e2.id
+		WHERE e1.entity_type IN ('person', 'organization')
+		  AND e2.entity_type IN ('person', 'organization')
+		  AND e1.connection_count >= $1
+		  AND e2.connection_count >= $1
+		  AND ` + confident("de1", "$4") + `
+		  AND ` + confident("de2", "$4") + `
+		GROUP BY de1.entity_id, de2.entity_id
+		HAVING COUNT(DISTINCT de1.document_id) >= 2`
+}
+
+// networkEdgesSQL counts co-occurrences between connected people and
+// organizations, the expensive half of Network, with the scores of the
+// pairs from entity_edges
+func networkEdgesSQL(f NetworkFilter) string {
+	return `
+	SELECT w.source, w.target, w.weight, ed.score::float8, w.proximity
+	FROM (` + networkPairsSQL(f.Granularity) + `
+	) w
+	LEFT JOIN entity_edges ed ON ed.source_id = w.source AND ed.target_id = w.target
+	WHERE $3::float8 <= 0 OR ed.score >= $3
+	ORDER BY ` + EdgeSorts.OrderBy(f.Sort) + `
+	LIMIT $2
+`
+}
+
+// Network returns the most connected people and organizations and the
+// co-occurrence edges between them
+func (s *Store) Network(ctx context.Context, f NetworkFilter) ([]models.EntitySummary, []models.NetworkEdge, error) {
+	minConn, limit := f.MinConnections, f.Limit
+
+	// Get nodes (entities with sufficient connections)
+	nodeRows, err := s.read.Query(ctx, `
+		SELECT id, canonical_name, entity_type, layer, dist.distance, document_count, connection_count, `+primaryImageSQL+`
+		FROM entities
+		LEFT JOIN entity_distances dist ON dist.entity_id = entities.id
+		WHERE entity_type IN ('person', 'organization')
+		  AND connection_count >= $1
+		  AND deleted_at IS NULL AND NOT protected
+		ORDER BY connection_count DESC
+		LIMIT $2
+	`, minConn, limit)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer nodeRows.Close()
+
+	var nodes []models.EntitySummary
+	nodeIDs := make(map[int]bool)
+
+	for nodeRows.Next() {
+		var n models.EntitySummary
+		if err := nodeRows.Scan(&n.ID, &n.CanonicalName, &n.EntityType, &n.Layer, &n.DistanceToCore, &n.DocumentCount, &n.ConnectionCount, &n.ImageID); err != nil {
+			skip(ctx, "entities", n.ID, err)
+			continue
+		}
+
+		nodeIDs[n.ID] = true
+		nodes = append(nodes, n)
+	}
+	if err := nodeRows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	// Get edges (co-occurrence relationships)
+	edgeRows, err := s.read.Query(ctx, networkEdgesSQL(f), minConn, limit*3, f.MinScore, minConfidence(ctx))
+	if err != nil {
+		return nil, nil, err
+	}
+	defer edgeRows.Close()
+
+	var edges []models.NetworkEdge
+	for edgeRows.Next() {
+		var e models.NetworkEdge
+		if err := edgeRows.Scan(&e.Source, &e.Target, &e.Weight, &e.Score, &e.Proximity); err != nil {
+			skip(ctx, "document_entities", fmt.Sprintf("%d-%d", e.Source, e.Target), err)
+			continue
+		}
+
+		// Only include edges where both nodes are in our node set
+		if nodeIDs[e.Source] && nodeIDs[e.Target] {
+			edges = append(edges, e)
+		}
+	}
+	return nodes, edges, edgeRows.Err()
+}
+
+// NetworkPlan estimates the cost of Network's edge query
+func (s *Store) NetworkPlan(ctx context.Context, f NetworkFilter) (Plan, error) {
+	return s.explain(ctx, networkEdgesSQL(f), f.MinConnections, f.Limit*3, f.MinScore, minConfidence(ctx))
+}
+
+// LayerEntities returns the most connected people and organizations in a
+// network layer
+func (s *Store) LayerEntities(ctx context.Context, layer, limit int) ([]models.EntitySummary, error) {
+	rows, err := s.read.Query(ctx, `
+		SELECT id, canonical_name, entity_type, dist.distance, document_count, connection_count, `+primaryImageSQL+`
+		FROM entities
+		LEFT JOIN entity_distances dist ON dist.entity_id = entities.id
+		WHERE layer = $1 AND entity_type IN ('person', 'organization') AND deleted_at IS NULL AND NOT protected
+		ORDER BY connection_count DESC
+		LIMIT $2
+	`, layer, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entities []models.EntitySummary
+	for rows.Next() {
+		e := models.EntitySummary{Layer: &layer}
+		if err := rows.Scan(&e.ID, &e.CanonicalName, &e.EntityType, &e.DistanceToCore, &e.DocumentCount, &e.ConnectionCount, &e.ImageID); err != nil {
+			skip(ctx, "entities", e.ID, err)
+			continue
+		}
+		entities = append(entities, e)
+	}
+	return entities, rows.Err()
+}
+
+// PatternFilter narrows ListPatterns. Empty fields match everything.
+type PatternFilter struct {
+	Status string
+	Type   string
+	Sort   Sort
+}
+
+// ListPatterns returns the first 100 patterns, most recently discovered
+// first unless sorted otherwise
+func (s *Store) ListPatterns(ctx context.Context, f PatternFilter) ([]models.PatternSummary, error) {
+	rows, err := s.pool.Query(ctx, `
+		SELECT id, title, description, pattern_type, confidence, status, discovered_at
+		FROM pattern_findings
+		WHERE ($1 = '' OR status = $1)
+		  AND ($2 = '' OR pattern_type = $2)
+		ORDER BY `+PatternSorts.OrderBy(f.Sort)+`
+		LIMIT 100
+	`, f.Status, f.Type)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var patterns []models.PatternSummary
+	for rows.Next() {
+		var p models.PatternSummary
+		if err := rows.Scan(&p.ID, &p.Title, &p.Description, &p.PatternType, &p.Confidence, &p.Status, &p.DiscoveredAt); err != nil {
+			skip(ctx, "pattern_findings", p.ID, err)
+			continue
+		}
+		patterns = append(patterns, p)
+	}
+	return patterns, rows.Err()
+}
+
+// GetPattern returns one pattern with its evidence
+func (s *Store) GetPattern(ctx context.Context, id int) (*models.Pattern, error) {
+	var pattern models.Pattern
+
+	err := s.pool.QueryRow(ctx, `
+		SELECT id, title, description, pattern_type, entity_ids, evidence,
+			   confidence, status, notes, discovered_at, discovered_by
+		FROM pattern_findings WHERE id = $1
+	`, id).Scan(
+		&pattern.ID, &pattern.Title, &pattern.Description, &pattern.PatternType,
+		&pattern.EntityIDs, &pattern.Evidence, &pattern.Confidence,
+		&pattern.Status, &pattern.Notes, &pattern.DiscoveredAt, &pattern.DiscoveredBy,
+	)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &pattern, nil
+}
+
+// CooccurrenceMatrix counts the documents each pair of entities shares and
+// collects the relationships extracted between them. IDs that aren't
+// entities, or are protected, are reported as missing rather than failing
+// the request.
+func (s *Store) CooccurrenceMatrix(ctx context.Context, ids []int) (*models.Matrix, error) {
+	found, err := s.EntitiesByID(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[int]models.EntityBrief, len(found))
+	for _, e := range found {
+		byID[e.ID] = e
+	}
+
+	m := &models.Matrix{Entities: []models.EntityBrief{}, Missing: []int{}, Relations: []models.Relation{}}
+	index := make(map[int]int, len(found))
+	for _, id := range ids {
+		e, ok := byID[id]
+		if !ok {
+			m.Missing = append(m.Missing, id)
+			continue
+		}
+		index[id] = len(m.Entities)
+		m.Entities = append(m.Entities, e)
+	}
+	kept := make([]int, len(m.Entities))
+	for i, e := range m.Entities {
+		kept[i] = e.ID
+	}
+	m.Shared = make([][]int, len(m.Entities))
+	for i := range m.Shared {
+		m.Shared[i] = make([]int, len(m.Entities))
+	}
+
+	rows, err := s.read.Query(ctx, `
+		SELECT de1.entity_id, de2.entity_id, COUNT(*)
+		FROM document_entities de1
+		JOIN document_entities de2 ON de2.document_id = de1.document_id AND de2.entity_id >= de1.entity_id
+		WHERE de1.entity_id = ANY($1) AND de2.entity_id = ANY($1)
+		  AND `+confident("de1", "$2")+` AND `+confident("de2", "$2")+`
+		GROUP BY de1.entity_id, de2.entity_id
+	`, kept, minConfidence(ctx))
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var a, b, n int
+		if err := rows.Scan(&a, &b, &n); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		i, j := index[a], index[b]
+		m.Shared[i][j], m.Shared[j][i] = n, n
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = s.read.Query(ctx, `
+		SELECT subject_id, object_id, array_agg(DISTINCT predicate ORDER BY predicate), COUNT(*)
+		FROM triples
+		WHERE subject_id = ANY($1) AND object_id = ANY($1) AND subject_id <> object_id
+		GROUP BY subject_id, object_id
+		ORDER BY subject_id, object_id
+	`, kept)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var r models.Relation
+		if err := rows.Scan(&r.Subject, &r.Object, &r.Predicates, &r.Count); err != nil {
+			return nil, err
+		}
+		m.Relations = append(m.Relations, r)
+	}
+	return m, rows.Err()
+}
+
+// Kinds of money flow in a financial network
+const (
+	FlowContribution = "contribution" // donor to committee (FEC)
+	FlowGrant        = "grant"        // agency to grantee (federal grants)
+	FlowLoan         = "loan"         // lender to borrower (PPP)
+)
+
+// FinancialFilter narrows FinancialNetwork
+type FinancialFilter struct {
+	EntityIDs []int   // only flows to or from these entities, when set
+	Confirmed bool    // only crossref matches a researcher verified
+	MinAmount float64 // only flows totalling at least this much
+	Limit     int     // largest flows kept
+}
+
+// financialSQL totals the money moving through matched crossref records,
+// one row per source, target and kind of flow, largest first
+const financialSQL = `
+	WITH matches AS (
+		SELECT entity_id, source, source_id
+		FROM entity_crossref_matches
+		WHERE false_positive IS NOT TRUE
+		  AND (NOT $1 OR verified)
+		  AND (cardinality($2::int[]) = 0 OR entity_id = ANY($2))
+		  AND NOT EXISTS (SELECT 1 FROM entities e WHERE e.id = entity_id AND e.protected)
+	),
+	flows AS (
+		SELECT 'contribution' AS kind,
+			   'entity' AS source_kind, m.entity_id::text AS source_key, NULL AS source_label,
+			   'committee' AS target_kind, COALESCE(NULLIF(f.committee_id, ''), normalize_key(f.committee_name)) AS target_key,
+			   f.committee_name AS target_label, f.amount, f.contribution_date AS date
+		FROM matches m JOIN fec_contributions f ON m.source = 'fec' AND f.id = m.source_id
+		UNION ALL
+		SELECT 'grant', 'agency', normalize_key(g.awarding_agency), g.awarding_agency,
+			   'entity', m.entity_id::text, NULL, g.award_amount, g.award_date
+		FROM matches m JOIN federal_grants g ON m.source = 'grants' AND g.id = m.source_id
+		UNION ALL
+		SELECT 'loan', 'lender', normalize_key(p.lender), p.lender,
+			   'entity', m.entity_id::text, NULL, p.loan_amount, p.date_approved
+		FROM matches m JOIN ppp_loans p ON m.source = 'ppp' AND p.id = m.source_id
+	)
+	SELECT kind, source_kind, source_key, MIN(source_label), target_kind, target_key, MIN(target_label),
+		   COUNT(*), COALESCE(SUM(amount), 0)::float8, MIN(date)::text, MAX(date)::text
+	FROM flows
+	WHERE source_key IS NOT NULL AND target_key IS NOT NULL
+	GROUP BY kind, source_kind, source_key, target_kind, target_key
+	HAVING COALESCE(SUM(amount), 0) >= $3
+	ORDER BY 9 DESC
+	LIMIT $4
+`
+
+// FinancialNetwork builds a directed graph of money moving between entities
+// and the committees, agencies and lenders in the crossref data. A
+// committee, agency or lender named like an organization entity becomes
+// that entity, so money can be followed through it.
+func (s *Store) FinancialNetwork(ctx context.Context, f FinancialFilter) ([]models.FinancialNode, []models.FinancialEdge, error) {
+	ids := f.EntityIDs
+	if ids == nil {
+		ids = []int{}
+	}
+	rows, err := s.read.Query(ctx, financialSQL, f.Confirmed, ids, f.MinAmount, f.Limit)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	nodes := map[string]*models.FinancialNode{}
+	node := func(kind, key string, label *string) string {
+		id := kind + ":" + key
+		if nodes[id] == nil {
+			n := &models.FinancialNode{ID: id, Kind: kind}
+			if label != nil {
+				n.Label = *label
+			}
+			nodes[id] = n
+		}
+		return id
+	}
+
+	var edges []models.FinancialEdge
+	for rows.Next() {
+		var e models.FinancialEdge
+		var sourceKind, sourceKey, targetKind, targetKey string
+		var sourceLabel, targetLabel *string
+		if err := rows.Scan(&e.Kind, &sourceKind, &sourceKey, &sourceLabel, &targetKind, &targetKey, &targetLabel,
+			&e.Records, &e.Amount, &e.First, &e.Last); err != nil {
+			skip(ctx, "entity_crossref_matches", sourceKey+" -> "+targetKey, err)
+			continue
+		}
+		e.Source = node(sourceKind, sourceKey, sourceLabel)
+		e.Target = node(targetKind, targetKey, targetLabel)
+		edges = append(edges, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	merged, err := s.mergeOrganizations(ctx, nodes)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := s.labelEntities(ctx, nodes); err != nil {
+		return nil, nil, err
+	}
+
+	// Merging can make two flows one
+	byEnds := map[[3]string]int{}
+	out := []models.FinancialEdge{}
+	for _, e := range edges {
+		if id, ok := merged[e.Source]; ok {
+			e.Source = id
+		}
+		if id, ok := merged[e.Target]; ok {
+			e.Target = id
+		}
+		key := [3]string{e.Source, e.Target, e.Kind}
+		if i, ok := byEnds[key]; ok {
+			addFlow(&out[i], e)
+			continue
+		}
+		byEnds[key] = len(out)
+		out = append(out, e)
+	}
+
+	list := make([]models.FinancialNode, 0, len(nodes))
+	for _, n := range nodes {
+		list = append(list, *n)
+	}
+	slices.SortFunc(list, func(a, b models.FinancialNode) int { return strings.Compare(a.ID, b.ID) })
+	return list, out, nil
+}
+
+// addFlow folds another flow between the same ends into e
+func addFlow(e *models.FinancialEdge, o models.FinancialEdge) {
+	e.Amount += o.Amount
+	e.Records += o.Records
+	if o.First != nil && (e.First == nil || *o.First < *e.First) {
+		e.First = o.First
+	}
+	if o.Last != nil && (e.Last == nil || *o.Last > *e.Last) {
+		e.Last = o.Last
+	}
+}
+
+// mergeOrganizations replaces the committees, agencies and lenders named
+// like an organization entity with that entity's node, the most mentioned
+// one if several match. It returns the replaced node IDs mapped to the
+// entity node IDs.
+func (s *Store) mergeOrganizations(ctx context.Context, nodes map[string]*models.FinancialNode) (map[string]string, error) {
+	var ids, labels []string
+	for id, n := range nodes {
+		if n.Kind != "entity" && n.Label != "" {
+			ids = append(ids, id)
+			labels = append(labels, n.Label)
+		}
+	}
+	merged := map[string]string{}
+	if len(ids) == 0 {
+		return merged, nil
+	}
+
+	rows, err := s.read.Query(ctx, `
+		SELECT DISTINCT ON (q.id) q.id, e.id
+		FROM unnest($1::text[], $2::text[]) AS q(id, label)
+		JOIN entities e
+		  ON e.entity_type = 'organization'
+		 AND e.deleted_at IS NULL AND NOT e.protected
+		 AND normalize_employer(e.canonical_name) = normalize_employer(q.label)
+		ORDER BY q.id, e.document_count DESC NULLS LAST, e.id
+	`, ids, labels)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var nodeID string
+		var entityID int
+		if err := rows.Scan(&nodeID, &entityID); err != nil {
+			skip(ctx, "entities", nodeID, err)
+			continue
+		}
+		delete(nodes, nodeID)
+		merged[nodeID] = "entity:" + strconv.Itoa(entityID)
+		if nodes[merged[nodeID]] == nil {
+			nodes[merged[nodeID]] = &models.FinancialNode{ID: merged[nodeID], Kind: "entity"}
+		}
+	}
+	return merged, rows.Err()
+}
+
+// labelEntities fills in the entity nodes' IDs, names and types
+func (s *Store) labelEntities(ctx context.Context, nodes map[string]*models.FinancialNode) error {
+	var ids []int
+	for _, n := range nodes {
+		if n.Kind != "entity" {
+			continue
+		}
+		id, err := strconv.Atoi(strings.TrimPrefix(n.ID, "entity:"))
+		if err != nil {
+			return err
+		}
+		n.EntityID = &id
+		ids = append(ids, id)
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	rows, err := s.read.Query(ctx, `
+		SELECT id, canonical_name, entity_type::text FROM entities WHERE id = ANY($1) AND NOT protected
+	`, ids)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int
+		var name, entityType string
+		if err := rows.Scan(&id, &name, &entityType); err != nil {
+			skip(ctx, "entities", id, err)
+			continue
+		}
+		if n := nodes["entity:"+strconv.Itoa(id)]; n != nil {
+			n.Label, n.EntityType = name, &entityType
+		}
+	}
+	return rows.Err()
+}
+
+// egoNeighboursSQL reads up to $2 of the highest scored neighbours of each
+// entity in $1, leaving out those in $3
+const egoNeighboursSQL = `
+	SELECT n.other, n.score
+	FROM unnest($1::int[]) AS x(id)
+	CROSS JOIN LATERAL (
+		SELECT e.id AS other, ed.score
+		FROM entity_edges ed
+		JOIN entities e ON e.id = CASE WHEN ed.source_id = x.id THEN ed.target_id ELSE ed.source_id END
+		WHERE (ed.source_id = x.id OR ed.target_id = x.id)
+		  AND e.deleted_at IS NULL AND NOT e.protected
+		  AND NOT e.id = ANY($3)
+		ORDER BY ed.score DESC
+		LIMIT $2
+	) n
+	ORDER BY n.score DESC
+`
+
+// EgoNetwork returns entity id and the people and organizations most
+// strongly related to it, then at depth 2 those most strongly related to
+// them, up to limit entities in all, with the scored edges between them.
+// Relationships come from entity_edges, so the entity stands alone until
+// `worker edges` has run.
+func (s *Store) EgoNetwork(ctx context.Context, id, depth, limit int) ([]models.EgoNode, []models.NetworkEdge, error) {
+	center, err := s.EntitiesByID(ctx, []int{id})
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(center) == 0 {
+		return nil, nil, ErrNotFound
+	}
+
+	nodes := []models.EgoNode{{EntityBrief: center[0]}}
+	ids := []int{id}
+	frontier := []int{id}
+	for ring := 1; ring <= depth && len(nodes) < limit && len(frontier) > 0; ring++ {
+		// Share the room left between the ring's parents, so one prolific
+		// neighbour doesn't crowd out the rest
+		room := limit - len(nodes)
+		per := max(room/len(frontier), 2)
+
+		rows, err := s.read.Query(ctx, egoNeighboursSQL, frontier, per, ids)
+		if err != nil {
+			return nil, nil, err
+		}
+		var found []int
+		for rows.Next() {
+			var other int
+			var score float64
+			if err := rows.Scan(&other, &score); err != nil {
+				rows.Close()
+				return nil, nil, err
+			}
+			if !slices.Contains(found, other) && len(found) < room {
+				found = append(found, other)
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, nil, err
+		}
+
+		entities, err := s.EntitiesByID(ctx, found)
+		if err != nil {
+			return nil, nil, err
+		}
+		// Keep the order of the scores rather than EntitiesByID's
+		slices.SortFunc(entities, func(a, b models.EntityBrief) int {
+			return slices.Index(found, a.ID) - slices.Index(found, b.ID)
+		})
+		frontier = frontier[:0]
+		for _, e := range entities {
+			nodes = append(nodes, models.EgoNode{EntityBrief: e, Ring: ring})
+			ids = append(ids, e.ID)
+			frontier = append(frontier, e.ID)
+		}
+	}
+
+	rows, err := s.read.Query(ctx, `
+		SELECT source_id, target_id, documents, score
+		FROM entity_edges
+		WHERE source_id = ANY($1) AND target_id = ANY($1)
+		ORDER BY score DESC
+	`, ids)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	var edges []models.NetworkEdge
+	for rows.Next() {
+		var e models.NetworkEdge
+		var score float64
+		if err := rows.Scan(&e.Source, &e.Target, &e.Weight, &score); err != nil {
+			skip(ctx, "entity_edges", fmt.Sprintf("%d-%d", e.Source, e.Target), err)
+			continue
+		}
+		e.Score = &score
+		edges = append(edges, e)
+	}
+	return nodes, edges, rows.Err()
+}
@@ -0,0 +1,209 @@
+package store
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/subculture-collective/epstein-db/api/internal/models"
+)
+
+// searchNgramsSQL ranks documents with a passage sharing enough character
+// trigrams with the query, however the words are spelled. The threshold is
+// pg_trgm.word_similarity_threshold, which SearchNgrams sets for the query.
+const searchNgramsSQL = `
+	SELECT id, doc_id, document_type, summary, sensitivity_labels(id),
+		   word_similarity($1, full_text) AS rank, full_text
+	FROM documents d
+	WHERE full_text %> $1
+	  AND ` + searchScope + `
+	ORDER BY rank DESC
+	LIMIT $2
+`
+
+// minThreshold keeps the loosest n-gram search from matching every document
+// with a trigram in common with the query
+const minThreshold = 0.1
+
+// SearchNgrams finds documents containing a passage like f.Query, best
+// matches first, tolerating the character-level damage OCR does to words.
+// f.Fuzziness runs from 0, where the passage must have all of the query's
+// trigrams, to 1, the loosest.
+func (s *Store) SearchNgrams(ctx context.Context, f SearchFilter) ([]models.SearchResult, error) {
+	tx, err := s.read.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	threshold := strconv.FormatFloat(max(1-f.Fuzziness, minThreshold), 'f', -1, 64)
+	if _, err := tx.Exec(ctx, `SELECT set_config('pg_trgm.word_similarity_threshold', $1, true)`, threshold); err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.Query(ctx, searchNgramsSQL, f.args(ctx)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []models.SearchResult
+	for rows.Next() {
+		var r models.SearchResult
+		var text *string
+		if err := rows.Scan(&r.ID, &r.DocID, &r.DocumentType, &r.Summary, &r.Sensitivity, &r.Rank, &text); err != nil {
+			skip(ctx, "documents", r.ID, err)
+			continue
+		}
+		if text != nil {
+			r.Snippet = ngramSnippet(*text, f.Query)
+		}
+		results = append(results, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if f.Dedupe {
+		if results, err = s.collapseDuplicates(ctx, results, f.Limit); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.maskResults(ctx, results); err != nil {
+		return nil, err
+	}
+	return results, s.markEntities(ctx, results)
+}
+
+// SearchNgramsPlan estimates the cost of SearchNgrams
+func (s *Store) SearchNgramsPlan(ctx context.Context, f SearchFilter) (Plan, error) {
+	return s.explain(ctx, searchNgramsSQL, f.args(ctx)...)
+}
+
+// Trigrams counts the character trigrams pg_trgm extracts from query. A
+// query without any, such as one of only punctuation, matches nothing.
+func Trigrams(query string) int {
+	set := map[string]bool{}
+	for _, w := range words(query) {
+		for _, t := range wordTrigrams(w.text) {
+			set[t] = true
+		}
+	}
+	return len(set)
+}
+
+// snippetContext is how many words either side of the best passage a
+// snippet shows
+const snippetContext = 12
+
+// ngramSnippet finds the run of words in text most like query, the way
+// word_similarity does, and returns it marked up with some context.
+// ts_headline can't do this, as the misread words aren't its lexemes.
+func ngramSnippet(text, query string) *string {
+	q := words(query)
+	ws := words(text)
+	if len(q) == 0 || len(ws) == 0 {
+		return nil
+	}
+
+	want := map[string]bool{}
+	for _, w := range q {
+		for _, t := range wordTrigrams(w.text) {
+			want[t] = true
+		}
+	}
+	trigrams := make([][]string, len(ws))
+	for i, w := range ws {
+		trigrams[i] = wordTrigrams(w.text)
+	}
+
+	span := min(len(q), len(ws))
+	best, bestScore := 0, -1.0
+	for i := 0; i+span <= len(ws); i++ {
+		shared, union := 0, len(want)
+		seen := map[string]bool{}
+		for _, wt := range trigrams[i : i+span] {
+			for _, t := range wt {
+				if seen[t] {
+					continue
+				}
+				seen[t] = true
+				if want[t] {
+					shared++
+				} else {
+					union++
+				}
+			}
+		}
+		if score := float64(shared) / float64(union); score > bestScore {
+			best, bestScore = i, score
+		}
+	}
+
+	start := ws[max(best-snippetContext, 0)].start
+	end := ws[min(best+span+snippetContext, len(ws))-1].end
+	hitStart, hitEnd := ws[best].start, ws[best+span-1].end
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("… ")
+	}
+	b.WriteString(text[start:hitStart])
+	b.WriteString("<mark>")
+	b.WriteString(text[hitStart:hitEnd])
+	b.WriteString("</mark>")
+	b.WriteString(text[hitEnd:end])
+	if end < len(text) {
+		b.WriteString(" …")
+	}
+	snippet := b.String()
+	return &snippet
+}
+
+// word is a run of letters and digits and its byte offsets in the text
+type word struct {
+	text       string
+	start, end int
+}
+
+// words splits text into words the way pg_trgm does, lowercased
+func words(text string) []word {
+	var out []word
+	start := -1
+	for i, r := range text {
+		alnum := unicode.IsLetter(r) || unicode.IsDigit(r)
+		switch {
+		case alnum && start < 0:
+			start = i
+		case !alnum && start >= 0:
+			out = append(out, word{strings.ToLower(text[start:i]), start, i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		out = append(out, word{strings.ToLower(text[start:]), start, len(text)})
+	}
+	return out
+}
+
+// wordTrigrams returns a word's trigrams, padded as pg_trgm pads them with
+// two spaces before and one after
+func wordTrigrams(w string) []string {
+	padded := "  " + w + " "
+	var out []string
+	for i := 0; i < len(padded); {
+		j := i
+		for n := 0; n < 3 && j < len(padded); n++ {
+			_, size := utf8.DecodeRuneInString(padded[j:])
+			j += size
+		}
+		if utf8.RuneCountInString(padded[i:j]) < 3 {
+			break
+		}
+		out = append(out, padded[i:j])
+		_, size := utf8.DecodeRuneInString(padded[i:])
+		i += size
+	}
+	return out
+}
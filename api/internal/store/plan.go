@@ -0,0 +1,55 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+)
+
+// Plan is the planner's estimate for a query, used to turn away requests
+// that would be expensive before running them
+type Plan struct {
+	Cost     float64  // estimated total cost, in planner units
+	Rows     float64  // estimated rows returned
+	SeqScans []string // tables the plan reads in full
+}
+
+// planNode is the part of EXPLAIN (FORMAT JSON) output Plan needs
+type planNode struct {
+	NodeType     string     `json:"Node Type"`
+	RelationName string     `json:"Relation Name"`
+	TotalCost    float64    `json:"Total Cost"`
+	PlanRows     float64    `json:"Plan Rows"`
+	Plans        []planNode `json:"Plans"`
+}
+
+// explain plans sql without running it
+func (s *Store) explain(ctx context.Context, sql string, args ...any) (Plan, error) {
+	var raw []byte
+	if err := s.read.QueryRow(ctx, "EXPLAIN (FORMAT JSON) "+sql, args...).Scan(&raw); err != nil {
+		return Plan{}, err
+	}
+	var out []struct {
+		Plan planNode `json:"Plan"`
+	}
+	if err := json.Unmarshal(raw, &out); err != nil {
+		return Plan{}, err
+	}
+	if len(out) == 0 {
+		return Plan{}, errors.New("empty plan")
+	}
+
+	root := out[0].Plan
+	plan := Plan{Cost: root.TotalCost, Rows: root.PlanRows}
+	var walk func(n planNode)
+	walk = func(n planNode) {
+		if n.NodeType == "Seq Scan" {
+			plan.SeqScans = append(plan.SeqScans, n.RelationName)
+		}
+		for _, child := range n.Plans {
+			walk(child)
+		}
+	}
+	walk(root)
+	return plan, nil
+}
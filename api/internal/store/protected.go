@@ -0,0 +1,247 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/subculture-collective/epstein-db/api/internal/models"
+)
+
+// Protected entities, such as identified victims, are kept out of entity
+// search, connections, the network, triples and exports, and their names,
+// canonical or alias, are masked wherever the store returns document text,
+// summaries, snippets or quotes.
+
+// Masked replaces the name of a protected entity
+const Masked = "[protected]"
+
+// ErrProtected is returned for the original file of a document that names a
+// protected entity, which can't be masked
+var ErrProtected = errors.New("names a protected entity")
+
+// markTag matches a snippet's <mark> tags, which ts_headline may put inside
+// a name
+var markTag = regexp.MustCompile(`</?mark>`)
+
+// Masker masks the names of protected entities. The zero Masker masks
+// nothing.
+type Masker struct {
+	re *regexp.Regexp
+}
+
+// querier is a pool or a transaction
+type querier interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+}
+
+// LoadMasker reads the names of the protected entities
+func LoadMasker(ctx context.Context, q querier) (*Masker, error) {
+	rows, err := q.Query(ctx, `
+		SELECT e.canonical_name FROM entities e WHERE e.protected
+		UNION
+		SELECT a.original_name FROM entity_aliases a
+		JOIN entities e ON e.id = a.entity_id
+		WHERE e.protected
+	`)
+	if err != nil {
+		return nil, err
+	}
+	names, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, err
+	}
+	return newMasker(names), nil
+}
+
+// newMasker masks names, ignoring case and the spacing and snippet marks
+// between their words. Longer names are tried first, so that "Jane Doe" is
+// masked whole rather than as "Jane" and "Doe". A name is only masked as
+// whole words, bounded by anything but a letter, digit or underscore in any
+// script: regexp's \b only knows ASCII, so it never matches beside an É.
+func newMasker(names []string) *Masker {
+	var patterns []string
+	for _, name := range names {
+		words := strings.Fields(name)
+		if utf8.RuneCountInString(strings.Join(words, " ")) < minEntityName {
+			continue
+		}
+		for i, w := range words {
+			words[i] = regexp.QuoteMeta(w)
+		}
+		patterns = append(patterns, strings.Join(words, `(?:\s|</?mark>)+`))
+	}
+	if len(patterns) == 0 {
+		return &Masker{}
+	}
+	slices.SortFunc(patterns, func(a, b string) int { return len(b) - len(a) })
+	patterns = slices.Compact(patterns)
+	return &Masker{re: regexp.MustCompile(
+		`(?i)(?:^|` + nonWord + `)(` + strings.Join(patterns, "|") + `)(?:$|` + nonWord + `)`)}
+}
+
+// nonWord matches a rune that can't be part of a name's first or last word
+const nonWord = `[^\p{L}\p{N}_]`
+
+// Mask replaces the protected names in text
+func (m *Masker) Mask(text string) string {
+	if m == nil || m.re == nil {
+		return text
+	}
+	masked := m.replace(text)
+	if masked == text || !strings.Contains(text, markOpen) {
+		return masked
+	}
+	return balanceMarks(masked)
+}
+
+// replace replaces each name m.re matches in text. The boundaries around a
+// name are part of the match, so the search for the next name resumes at
+// the boundary after the last, which may also be the one before the next.
+func (m *Masker) replace(text string) string {
+	var b strings.Builder
+	last := 0
+	for last < len(text) {
+		loc := m.re.FindStringSubmatchIndex(text[last:])
+		if loc == nil {
+			break
+		}
+		b.WriteString(text[last : last+loc[2]])
+		b.WriteString(Masked)
+		last += loc[3]
+	}
+	if last == 0 {
+		return text
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
+
+// MaskPtr masks a nullable text in place
+func (m *Masker) MaskPtr(text *string) {
+	if text != nil {
+		*text = m.Mask(*text)
+	}
+}
+
+// balanceMarks drops the <mark> tags that masking a name left unpaired
+func balanceMarks(s string) string {
+	open := false
+	s = markTag.ReplaceAllStringFunc(s, func(tag string) string {
+		if (tag == markOpen) == open {
+			return ""
+		}
+		open = !open
+		return tag
+	})
+	if open {
+		s += markClose
+	}
+	return s
+}
+
+// protectedColumns selects a ProtectedEntity
+const protectedColumns = `id, canonical_name, entity_type::text, protected, protected_at, protected_by, protected_reason`
+
+func scanProtected(row pgx.Row) (*models.ProtectedEntity, error) {
+	var e models.ProtectedEntity
+	err := row.Scan(&e.ID, &e.CanonicalName, &e.EntityType, &e.Protected, &e.ProtectedAt, &e.ProtectedBy, &e.Reason)
+	if err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// ProtectedEntities returns the protected entities, most recently protected
+// first
+func (s *Store) ProtectedEntities(ctx context.Context, limit, offset int) ([]models.ProtectedEntity, error) {
+	rows, err := s.pool.Query(ctx, `
+		SELECT `+protectedColumns+`
+		FROM entities
+		WHERE protected AND deleted_at IS NULL
+		ORDER BY protected_at DESC NULLS LAST, id
+		LIMIT $1 OFFSET $2
+	`, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entities := []models.ProtectedEntity{}
+	for rows.Next() {
+		e, err := scanProtected(rows)
+		if err != nil {
+			return nil, err
+		}
+		entities = append(entities, *e)
+	}
+	return entities, rows.Err()
+}
+
+// SetEntityProtected protects an entity, or lifts its protection, and
+// returns it, or ErrNotFound for an unknown entity. Its updated_at moves
+// so that cached networks are rebuilt, and so does that of every document
+// naming it, whose masked summaries and text change: their ETags are keyed
+// on it, and the change log then purges their CDN keys.
+func (s *Store) SetEntityProtected(ctx context.Context, id int, protected bool, keyID int, reason string) (*models.ProtectedEntity, error) {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	e, err := scanProtected(tx.QueryRow(ctx, `
+		UPDATE entities SET
+			protected = $2,
+			protected_at = CASE WHEN $2 THEN NOW() END,
+			protected_by = CASE WHEN $2 THEN NULLIF($3, 0) END,
+			protected_reason = CASE WHEN $2 THEN NULLIF($4, '') END
+		WHERE id = $1 AND deleted_at IS NULL
+		RETURNING `+protectedColumns, id, protected, keyID, reason))
+	if err != nil {
+		return nil, notFound(err)
+	}
+	if _, err := tx.Exec(ctx, `
+		UPDATE documents SET updated_at = NOW()
+		WHERE id IN (SELECT document_id FROM document_entities WHERE entity_id = $1)
+	`, id); err != nil {
+		return nil, err
+	}
+	return e, tx.Commit(ctx)
+}
+
+// masker returns the Masker for the protected entities
+func (s *Store) masker(ctx context.Context) (*Masker, error) {
+	return LoadMasker(ctx, s.pool)
+}
+
+// maskSummaries masks the protected names in documents' summaries
+func (s *Store) maskSummaries(ctx context.Context, documents []models.DocumentSummary) error {
+	m, err := s.masker(ctx)
+	if err != nil {
+		return err
+	}
+	for i := range documents {
+		m.MaskPtr(documents[i].Summary)
+	}
+	return nil
+}
+
+// maskResults masks the protected names in search results' summaries and
+// snippets
+func (s *Store) maskResults(ctx context.Context, results []models.SearchResult) error {
+	m, err := s.masker(ctx)
+	if err != nil {
+		return err
+	}
+	for i := range results {
+		m.MaskPtr(results[i].Summary)
+		m.MaskPtr(results[i].Snippet)
+	}
+	return nil
+}
@@ -0,0 +1,28 @@
+package store
+
+import "testing"
+
+func TestMask(t *testing.T) {
+	m := newMasker([]string{"Jane Roe", "Éloise Roe", "Roe"})
+	cases := []struct {
+		name, text, want string
+	}{
+		{"whole name", "met Jane Roe in 2002", "met [protected] in 2002"},
+		{"case", "JANE ROE said", "[protected] said"},
+		{"longest first", "Jane Roe and Roe", "[protected] and [protected]"},
+		{"adjacent", "Roe,Roe Roe", "[protected],[protected] [protected]"},
+		{"inside a word", "Roebuck and Monroe", "Roebuck and Monroe"},
+		{"accented first letter", "Éloise Roe wrote", "[protected] wrote"},
+		{"accented case", "ÉLOISE ROE wrote", "[protected] wrote"},
+		{"accented neighbour", "ÉRoe and Roeé", "ÉRoe and Roeé"},
+		{"non-latin neighbour", "Жane Roe", "Жane [protected]"},
+		{"snippet marks", "<mark>Jane</mark> Roe", "<mark>[protected]</mark>"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := m.Mask(tc.text); got != tc.want {
+				t.Errorf("Mask(%q) = %q, want %q", tc.text, got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package store
+
+import (
+	"context"
+	"strings"
+
+	"github.com/subculture-collective/epstein-db/api/internal/models"
+)
+
+// ResolveFilter is a list of names to resolve to entities
+type ResolveFilter struct {
+	Names      []string
+	Match      string // one of MatchModes; fuzzy when empty
+	Type       string // only entities of this type, when set
+	Candidates int    // entities returned per name, best first
+}
+
+// ResolveEntities finds the entities each name most likely refers to,
+// matching it against canonical names and aliases alike. An entity's score
+// is its best-matching name's trigram similarity to the query, 1 for the
+// same name in another case. Results are in the order of f.Names; a blank
+// name resolves to nothing.
+func (s *Store) ResolveEntities(ctx context.Context, f ResolveFilter) ([]models.Resolution, error) {
+	resolutions := make([]models.Resolution, len(f.Names))
+	var names, patterns []string
+	var slot []int // index in resolutions of each name queried
+	for i, name := range f.Names {
+		name = strings.TrimSpace(name)
+		resolutions[i] = models.Resolution{Name: f.Names[i], Alternatives: []models.ResolveCandidate{}}
+		if name == "" {
+			continue
+		}
+		names = append(names, name)
+		patterns = append(patterns, namePattern(name, f.Match))
+		slot = append(slot, i)
+	}
+	if len(names) == 0 {
+		return resolutions, nil
+	}
+
+	rows, err := s.read.Query(ctx, `
+		SELECT q.i, r.id, r.canonical_name, r.entity_type::text, r.document_count, r.matched, r.score
+		FROM unnest($1::text[], $2::text[]) WITH ORDINALITY AS q(name, pattern, i)
+		CROSS JOIN LATERAL (
+			SELECT * FROM (
+				SELECT DISTINCT ON (e.id) e.id, e.canonical_name, e.entity_type, e.document_count,
+					   n.name AS matched,
+					   CASE WHEN lower(n.name) = lower(q.name) THEN 1
+							ELSE similarity(n.name, q.name) END AS score
+				FROM (
+					SELECT id AS entity_id, canonical_name AS name FROM entities
+					WHERE canonical_name ILIKE q.pattern OR ($3 AND canonical_name % q.name)
+					UNION ALL
+					SELECT entity_id, original_name FROM entity_aliases
+					WHERE original_name ILIKE q.pattern OR ($3 AND original_name % q.name)
+				) n
+				JOIN entities e ON e.id = n.entity_id
+				WHERE e.deleted_at IS NULL AND NOT e.protected AND ($4 = '' OR e.entity_type = $4::entity_type)
+				ORDER BY e.id, score DESC
+			) best
+			ORDER BY score DESC, document_count DESC NULLS LAST
+			LIMIT $5
+		) r
+		ORDER BY q.i, r.score DESC, r.document_count DESC NULLS LAST
+	`, names, patterns, fuzzy(f.Match), f.Type, f.Candidates)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var i int
+		var c models.ResolveCandidate
+		if err := rows.Scan(&i, &c.ID, &c.CanonicalName, &c.EntityType, &c.DocumentCount, &c.MatchedName, &c.Score); err != nil {
+			skip(ctx, "entities", c.ID, err)
+			continue
+		}
+		c.Alias = c.MatchedName != c.CanonicalName
+
+		r := &resolutions[slot[i-1]]
+		if r.Match == nil {
+			r.Match = &c
+		} else {
+			r.Alternatives = append(r.Alternatives, c)
+		}
+	}
+	return resolutions, rows.Err()
+}
@@ -0,0 +1,23 @@
+package store
+
+import "context"
+
+type excludeSensitiveKey struct{}
+
+// WithoutSensitive returns a context in which document listings and
+// searches leave out documents with any sensitivity label
+func WithoutSensitive(ctx context.Context) context.Context {
+	return context.WithValue(ctx, excludeSensitiveKey{}, true)
+}
+
+// ExcludesSensitive reports whether ctx leaves out labeled documents
+func ExcludesSensitive(ctx context.Context) bool {
+	ok, _ := ctx.Value(excludeSensitiveKey{}).(bool)
+	return ok
+}
+
+// Insensitive is the SQL condition that document d has no sensitivity
+// label, or that parameter param, ExcludesSensitive, is false
+func Insensitive(d, param string) string {
+	return "NOT (" + param + "::bool AND sensitivity_labels(" + d + ".id) <> '{}')"
+}
@@ -0,0 +1,236 @@
+package store
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/subculture-collective/epstein-db/api/internal/models"
+)
+
+// Snippets mark query hits with <mark>. markEntities also wraps the names
+// of entities mentioned in each result's document, canonical or alias, in
+// <span data-entity-id="..."> so a client can link them to entity pages.
+
+const (
+	markOpen  = "<mark>"
+	markClose = "</mark>"
+)
+
+// minEntityName is the shortest name marked in a snippet, in runes; shorter
+// ones are mostly initials and stray tokens
+const minEntityName = 3
+
+// entityName is one way an entity is written
+type entityName struct {
+	entity models.SnippetEntity
+	name   string
+}
+
+// markEntities marks the mentioned entities in each result's snippet and
+// lists the ones it found
+func (s *Store) markEntities(ctx context.Context, results []models.SearchResult) error {
+	if len(results) == 0 {
+		return nil
+	}
+	ids := make([]int, len(results))
+	for i, r := range results {
+		ids[i] = r.ID
+	}
+	names, err := s.mentionNames(ctx, ids)
+	if err != nil {
+		return err
+	}
+	for i := range results {
+		r := &results[i]
+		if r.Snippet == nil || len(names[r.ID]) == 0 {
+			continue
+		}
+		snippet, found := markNames(*r.Snippet, names[r.ID])
+		r.Snippet, r.Entities = &snippet, found
+	}
+	return nil
+}
+
+// mentionNames returns the names of the entities each document mentions,
+// longest first so that "Jeffrey Epstein" is marked rather than "Epstein"
+func (s *Store) mentionNames(ctx context.Context, documentIDs []int) (map[int][]entityName, error) {
+	rows, err := s.read.Query(ctx, `
+		SELECT de.document_id, e.id, e.canonical_name, e.entity_type::text, n.name
+		FROM document_entities de
+		JOIN entities e ON e.id = de.entity_id
+		CROSS JOIN LATERAL (
+			SELECT e.canonical_name AS name
+			UNION
+			SELECT original_name FROM entity_aliases a WHERE a.entity_id = e.id
+		) n
+		WHERE de.document_id = ANY($1) AND char_length(n.name) >= $2 AND NOT e.protected
+	`, documentIDs, minEntityName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	names := map[int][]entityName{}
+	for rows.Next() {
+		var docID int
+		var n entityName
+		if err := rows.Scan(&docID, &n.entity.ID, &n.entity.CanonicalName, &n.entity.EntityType, &n.name); err != nil {
+			skip(ctx, "entity_aliases", n.entity.ID, err)
+			continue
+		}
+		names[docID] = append(names[docID], n)
+	}
+	for _, list := range names {
+		slices.SortFunc(list, func(a, b entityName) int {
+			if c := cmp.Compare(len(b.name), len(a.name)); c != 0 {
+				return c
+			}
+			return cmp.Compare(a.entity.ID, b.entity.ID)
+		})
+	}
+	return names, rows.Err()
+}
+
+// markNames wraps whole-word, case-insensitive occurrences of names in
+// snippet, leaving the <mark> tags already there well nested, and returns
+// the entities it marked in order of first appearance
+func markNames(snippet string, names []entityName) (string, []models.SnippetEntity) {
+	// The text without tags, and where each of its bytes is in snippet
+	var plain strings.Builder
+	var at []int
+	for i := 0; i < len(snippet); {
+		if tag := tagAt(snippet, i); tag != "" {
+			i += len(tag)
+			continue
+		}
+		plain.WriteByte(snippet[i])
+		at = append(at, i)
+		i++
+	}
+	text := plain.String()
+
+	type span struct {
+		start, end int // in snippet
+		entity     models.SnippetEntity
+	}
+	var spans []span
+	for i := 0; i < len(text); {
+		if !wordStart(text, i) {
+			i++
+			continue
+		}
+		matched := false
+		for _, n := range names {
+			end := i + len(n.name)
+			if end > len(text) || !strings.EqualFold(text[i:end], n.name) || !wordEnd(text, end) {
+				continue
+			}
+			if start, stop, ok := balanced(snippet, at[i], at[end-1]+1); ok {
+				spans = append(spans, span{start, stop, n.entity})
+				i, matched = end, true
+				break
+			}
+		}
+		if !matched {
+			i++
+		}
+	}
+	if len(spans) == 0 {
+		return snippet, nil
+	}
+
+	var b strings.Builder
+	var found []models.SnippetEntity
+	seen := map[int]bool{}
+	last := 0
+	for _, sp := range spans {
+		b.WriteString(snippet[last:sp.start])
+		b.WriteString(`<span data-entity-id="` + strconv.Itoa(sp.entity.ID) + `">`)
+		b.WriteString(snippet[sp.start:sp.end])
+		b.WriteString("</span>")
+		last = sp.end
+		if !seen[sp.entity.ID] {
+			seen[sp.entity.ID] = true
+			found = append(found, sp.entity)
+		}
+	}
+	b.WriteString(snippet[last:])
+	return b.String(), found
+}
+
+// tagAt returns the <mark> tag starting at snippet[i], if any
+func tagAt(snippet string, i int) string {
+	for _, tag := range []string{markOpen, markClose} {
+		if strings.HasPrefix(snippet[i:], tag) {
+			return tag
+		}
+	}
+	return ""
+}
+
+// balanced widens snippet[start:end] over adjacent <mark> tags until the
+// tags inside it pair up, so wrapping it keeps the markup well nested. It
+// reports false when that isn't possible, as when a hit spans the end of
+// the name.
+func balanced(snippet string, start, end int) (int, int, bool) {
+	for {
+		opened, stray := 0, false
+		for i := start; i < end; {
+			switch tagAt(snippet, i) {
+			case markOpen:
+				opened++
+				i += len(markOpen)
+			case markClose:
+				if opened == 0 {
+					stray = true
+				} else {
+					opened--
+				}
+				i += len(markClose)
+			default:
+				i++
+			}
+		}
+		switch {
+		case !stray && opened == 0:
+			return start, end, true
+		case stray && strings.HasSuffix(snippet[:start], markOpen):
+			start -= len(markOpen)
+		case !stray && strings.HasPrefix(snippet[end:], markClose):
+			end += len(markClose)
+		default:
+			return 0, 0, false
+		}
+	}
+}
+
+// wordStart reports whether a word starts at text[i]
+func wordStart(text string, i int) bool {
+	r, _ := utf8.DecodeRuneInString(text[i:])
+	if !isWordRune(r) {
+		return false
+	}
+	if i == 0 {
+		return true
+	}
+	prev, _ := utf8.DecodeLastRuneInString(text[:i])
+	return !isWordRune(prev)
+}
+
+// wordEnd reports whether no word continues past text[:i]
+func wordEnd(text string, i int) bool {
+	if i == len(text) {
+		return true
+	}
+	r, _ := utf8.DecodeRuneInString(text[i:])
+	return !isWordRune(r)
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
@@ -0,0 +1,197 @@
+package store
+
+import (
+	"slices"
+	"strings"
+)
+
+// Sort is the order a list is requested in: one of its Sorts' keys, and
+// the direction. The zero Sort is the list's default order.
+type Sort struct {
+	Key  string
+	Desc *bool // nil for the key's natural direction
+}
+
+// Column is a key a list can be sorted by
+type Column struct {
+	Expr string // SQL expression ordered by
+	Desc bool   // natural direction, e.g. counts largest first
+	Then string // further ordering before the tiebreak, if Expr ties often
+}
+
+// Sorts whitelists the columns a list can be sorted by. Only these
+// expressions are ever written into a query, so keys can come straight from
+// clients. Tiebreak, a unique column, follows every key so pages are stable.
+type Sorts struct {
+	Columns  map[string]Column
+	Default  string
+	Tiebreak string
+}
+
+// Keys lists the sort keys, default first
+func (ss Sorts) Keys() []string {
+	keys := make([]string, 0, len(ss.Columns))
+	for k := range ss.Columns {
+		if k != ss.Default {
+			keys = append(keys, k)
+		}
+	}
+	slices.Sort(keys)
+	return append([]string{ss.Default}, keys...)
+}
+
+// OrderBy returns the ORDER BY list for s, falling back to the default for
+// an unknown key. Nulls always sort last.
+func (ss Sorts) OrderBy(s Sort) string {
+	col, ok := ss.Columns[s.Key]
+	if !ok {
+		col = ss.Columns[ss.Default]
+	}
+	desc := col.Desc
+	if s.Desc != nil {
+		desc = *s.Desc
+	}
+
+	dir := " ASC"
+	if desc {
+		dir = " DESC"
+	}
+	order := []string{col.Expr + dir + " NULLS LAST"}
+	if col.Then != "" {
+		order = append(order, col.Then)
+	}
+	if col.Expr != ss.Tiebreak {
+		order = append(order, ss.Tiebreak+dir)
+	}
+	return strings.Join(order, ", ")
+}
+
+// Sort orders for each list
+var (
+	EntitySorts = Sorts{
+		Columns: map[string]Column{
+			"relevance":       {Expr: "CASE WHEN $1 != '' THEN similarity(canonical_name, $1) ELSE 0 END", Desc: true, Then: "document_count DESC"},
+			"name":            {Expr: "canonical_name"},
+			"documentCount":   {Expr: "document_count", Desc: true},
+			"connectionCount": {Expr: "connection_count", Desc: true},
+			"distanceToCore":  {Expr: "dist.distance"},
+			"id":              {Expr: "id"},
+		},
+		Default:  "relevance",
+		Tiebreak: "id",
+	}
+	DocumentSorts = Sorts{
+		Columns: map[string]Column{
+			"docId":        {Expr: "doc_id"},
+			"dateEarliest": {Expr: "date_earliest"},
+			"dateLatest":   {Expr: "date_latest"},
+			"datasetId":    {Expr: "dataset_id"},
+			"id":           {Expr: "id"},
+		},
+		Default:  "docId",
+		Tiebreak: "id",
+	}
+	PatternSorts = Sorts{
+		Columns: map[string]Column{
+			"discoveredAt": {Expr: "discovered_at", Desc: true},
+			"confidence":   {Expr: "confidence", Desc: true},
+			"title":        {Expr: "title"},
+			"id":           {Expr: "id"},
+		},
+		Default:  "discoveredAt",
+		Tiebreak: "id",
+	}
+	TripleSorts = Sorts{
+		Columns: map[string]Column{
+			"confidence": {Expr: "t.confidence", Desc: true},
+			"predicate":  {Expr: "t.predicate"},
+			"id":         {Expr: "t.id"},
+		},
+		Default:  "confidence",
+		Tiebreak: "t.id",
+	}
+	EdgeSorts = Sorts{
+		Columns: map[string]Column{
+			"weight":    {Expr: "w.weight", Desc: true},
+			"score":     {Expr: "ed.score", Desc: true},
+			"proximity": {Expr: "w.proximity", Desc: true},
+		},
+		Default:  "weight",
+		Tiebreak: "(w.source, w.target)",
+	}
+	TimelineSorts = Sorts{
+		Columns: map[string]Column{
+			"date": {Expr: "e.event_date"},
+			"type": {Expr: "e.event_type"},
+			"id":   {Expr: "e.id"},
+		},
+		Default:  "date",
+		Tiebreak: "e.id",
+	}
+	// ConnectionSorts order by EntityConnections' output columns, which
+	// both of its granularities name alike
+	ConnectionSorts = Sorts{
+		Columns: map[string]Column{
+			"sharedDocs": {Expr: "shared_docs", Desc: true},
+			"proximity":  {Expr: "proximity", Desc: true},
+			"name":       {Expr: "canonical_name"},
+			"id":         {Expr: "id"},
+		},
+		Default:  "sharedDocs",
+		Tiebreak: "id",
+	}
+	EntityDocumentSorts = Sorts{
+		Columns: map[string]Column{
+			"date":       {Expr: "d.date_earliest", Desc: true},
+			"docId":      {Expr: "d.doc_id"},
+			"confidence": {Expr: "de.extraction_confidence", Desc: true},
+			"id":         {Expr: "d.id"},
+		},
+		Default:  "date",
+		Tiebreak: "d.id",
+	}
+	DatasetSorts = Sorts{
+		Columns: map[string]Column{
+			"id":            {Expr: "id"},
+			"name":          {Expr: "name"},
+			"documentCount": {Expr: "document_count", Desc: true},
+			"registeredAt":  {Expr: "registered_at", Desc: true},
+			"readyAt":       {Expr: "ready_at", Desc: true},
+		},
+		Default:  "id",
+		Tiebreak: "id",
+	}
+	PPPSorts = Sorts{
+		Columns: map[string]Column{
+			"relevance": {Expr: "CASE WHEN $1 != '' THEN similarity(borrower_name, $1) ELSE 0 END", Desc: true, Then: "loan_amount DESC NULLS LAST"},
+			"name":      {Expr: "borrower_name"},
+			"amount":    {Expr: "loan_amount", Desc: true},
+			"date":      {Expr: "date_approved", Desc: true},
+			"id":        {Expr: "id"},
+		},
+		Default:  "relevance",
+		Tiebreak: "id",
+	}
+	FECSorts = Sorts{
+		Columns: map[string]Column{
+			"relevance": {Expr: "CASE WHEN $1 != '' THEN similarity(contributor_name, $1) ELSE 0 END", Desc: true, Then: "amount DESC NULLS LAST"},
+			"name":      {Expr: "contributor_name"},
+			"amount":    {Expr: "amount", Desc: true},
+			"date":      {Expr: "contribution_date", Desc: true},
+			"id":        {Expr: "id"},
+		},
+		Default:  "relevance",
+		Tiebreak: "id",
+	}
+	GrantSorts = Sorts{
+		Columns: map[string]Column{
+			"relevance": {Expr: "CASE WHEN $1 != '' THEN similarity(recipient_name, $1) ELSE 0 END", Desc: true, Then: "award_amount DESC NULLS LAST"},
+			"name":      {Expr: "recipient_name"},
+			"amount":    {Expr: "award_amount", Desc: true},
+			"date":      {Expr: "award_date", Desc: true},
+			"id":        {Expr: "id"},
+		},
+		Default:  "relevance",
+		Tiebreak: "id",
+	}
+)
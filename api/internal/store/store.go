@@ -0,0 +1,34 @@
+// Package store holds the SQL behind the REST handlers. Each method maps
+// rows into the typed models of package models.
+package store
+
+import (
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ErrNotFound is returned when a single-row lookup matches nothing
+var ErrNotFound = errors.New("not found")
+
+// Store runs queries against a connection pool. Searches, the network and
+// other expensive reads go to read, which may be a replica.
+type Store struct {
+	pool *pgxpool.Pool
+	read *pgxpool.Pool
+}
+
+// New creates a store on top of pool, sending heavy reads to read. Pass the
+// same pool twice without a replica.
+func New(pool, read *pgxpool.Pool) *Store {
+	return &Store{pool: pool, read: read}
+}
+
+// notFound translates pgx's missing-row error into ErrNotFound
+func notFound(err error) error {
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrNotFound
+	}
+	return err
+}
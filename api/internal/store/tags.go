@@ -0,0 +1,99 @@
+package store
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/subculture-collective/epstein-db/api/internal/models"
+)
+
+// retag is the content tags of document d with the tags in parameter add
+// added and those in parameter remove removed, in alphabetical order
+func retag(add, remove string) string {
+	return `(
+		SELECT COALESCE(jsonb_agg(t ORDER BY t), '[]')
+		FROM (
+			SELECT jsonb_array_elements_text(COALESCE(d.content_tags, '[]'))
+			UNION
+			SELECT unnest(` + add + `::text[])
+		) tags(t)
+		WHERE t <> ALL(` + remove + `::text[])
+	)`
+}
+
+// retags is the SQL condition that retag would change document d's tags
+func retags(add, remove string) string {
+	return `(NOT COALESCE(d.content_tags, '[]') @> to_jsonb(` + add + `::text[])
+		OR COALESCE(d.content_tags, '[]') ?| ` + remove + `::text[])`
+}
+
+// TagCounts returns the content tags in use with their document counts,
+// most common first
+func (s *Store) TagCounts(ctx context.Context, limit, offset int) ([]models.TagCount, error) {
+	rows, err := s.read.Query(ctx, `
+		SELECT t, COUNT(*)
+		FROM documents d, jsonb_array_elements_text(d.content_tags) t
+		WHERE jsonb_typeof(d.content_tags) = 'array' AND d.deleted_at IS NULL
+		GROUP BY t
+		ORDER BY COUNT(*) DESC, t
+		LIMIT $1 OFFSET $2
+	`, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []models.TagCount{}
+	for rows.Next() {
+		var t models.TagCount
+		if err := rows.Scan(&t.Tag, &t.Documents); err != nil {
+			return nil, err
+		}
+		tags = append(tags, t)
+	}
+	return tags, rows.Err()
+}
+
+// TagDocument adds and removes content tags on a document and returns its
+// tags, or ErrNotFound. Removing wins over adding the same tag.
+func (s *Store) TagDocument(ctx context.Context, id int, add, remove []string) ([]string, error) {
+	var tags []string
+	err := s.pool.QueryRow(ctx, `
+		UPDATE documents d SET content_tags = `+retag("$2", "$3")+`
+		WHERE d.id = $1 AND d.deleted_at IS NULL AND `+retags("$2", "$3")+`
+		RETURNING d.content_tags
+	`, id, add, remove).Scan(&tags)
+	if errors.Is(err, pgx.ErrNoRows) {
+		// Nothing to change, or no such document
+		err = s.pool.QueryRow(ctx, `SELECT COALESCE(content_tags, '[]') FROM documents WHERE id = $1 AND deleted_at IS NULL`, id).Scan(&tags)
+	}
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return tags, nil
+}
+
+// TagDocuments adds and removes content tags on every document matching a
+// search, a full-text query within f's scope, or every document in scope
+// when f.Query is empty, and returns how many changed. f.Limit caps the
+// documents considered; 0 considers all of them.
+func (s *Store) TagDocuments(ctx context.Context, f SearchFilter, add, remove []string) (int, error) {
+	tag, err := s.pool.Exec(ctx, `
+		WITH hit AS (
+			SELECT d.id
+			FROM documents d
+			WHERE ($1 = '' OR to_tsvector('english', d.full_text) @@ plainto_tsquery('english', $1))
+			  AND `+searchScope+`
+			LIMIT NULLIF($2, 0)
+		)
+		UPDATE documents d SET content_tags = `+retag("$9", "$10")+`
+		FROM hit
+		WHERE d.id = hit.id AND `+retags("$9", "$10"),
+		append(f.args(ctx), add, remove)...)
+	if err != nil {
+		return 0, err
+	}
+	return int(tag.RowsAffected()), nil
+}
@@ -0,0 +1,169 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/subculture-collective/epstein-db/api/internal/models"
+)
+
+// TimelineFilter selects timeline events. With EntityIDs, MatchAll keeps
+// only events involving every entity rather than any of them.
+type TimelineFilter struct {
+	From      *time.Time
+	To        *time.Time
+	EntityIDs []int
+	MatchAll  bool
+	Type      string
+	Sort      Sort
+	Limit     int
+	Offset    int
+}
+
+// TimelineEventInput is a curated event as created or edited by a
+// researcher
+type TimelineEventInput struct {
+	Date        time.Time
+	Description string
+	Type        string
+	DocumentID  *int
+	EntityIDs   []int
+}
+
+// Timeline returns events, in date order unless sorted otherwise
+func (s *Store) Timeline(ctx context.Context, f TimelineFilter) ([]models.TimelineEvent, error) {
+	rows, err := s.read.Query(ctx, `
+		SELECT e.id, e.event_date, e.description, e.event_type, e.origin,
+			   e.document_id, d.doc_id, e.crossref::text, e.crossref_id,
+			   COALESCE((
+				   SELECT jsonb_agg(jsonb_build_object(
+					   'id', en.id, 'canonicalName', en.canonical_name,
+					   'entityType', en.entity_type, 'layer', en.layer) ORDER BY en.id)
+				   FROM timeline_event_entities ee
+				   JOIN entities en ON en.id = ee.entity_id
+				   WHERE ee.event_id = e.id AND en.deleted_at IS NULL AND NOT en.protected
+			   ), '[]')
+		FROM timeline_events e
+		LEFT JOIN documents d ON d.id = e.document_id
+		WHERE NOT e.hidden AND d.deleted_at IS NULL
+		  AND ($1::date IS NULL OR e.event_date >= $1)
+		  AND ($2::date IS NULL OR e.event_date <= $2)
+		  AND ($3 = '' OR e.event_type = $3)
+		  AND (cardinality($4::int[]) = 0 OR (
+			  SELECT COUNT(DISTINCT ee.entity_id) FROM timeline_event_entities ee
+			  WHERE ee.event_id = e.id AND ee.entity_id = ANY($4)
+		  ) >= CASE WHEN $5 THEN cardinality($4::int[]) ELSE 1 END)
+		ORDER BY `+TimelineSorts.OrderBy(f.Sort)+`
+		LIMIT $6 OFFSET $7
+	`, f.From, f.To, f.Type, f.EntityIDs, f.MatchAll, f.Limit, f.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []models.TimelineEvent{}
+	for rows.Next() {
+		var e models.TimelineEvent
+		var date time.Time
+		if err := rows.Scan(&e.ID, &date, &e.Description, &e.Type, &e.Origin,
+			&e.DocumentID, &e.DocID, &e.Crossref, &e.CrossrefID, &e.Entities); err != nil {
+			return nil, err
+		}
+		e.Date = date.Format(time.DateOnly)
+		events = append(events, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	m, err := s.masker(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range events {
+		events[i].Description = m.Mask(events[i].Description)
+	}
+	return events, nil
+}
+
+// CreateTimelineEvent adds a curated event
+func (s *Store) CreateTimelineEvent(ctx context.Context, in TimelineEventInput, keyID int) (int64, error) {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	var id int64
+	err = tx.QueryRow(ctx, `
+		INSERT INTO timeline_events (event_date, description, event_type, document_id, origin, created_by)
+		VALUES ($1, $2, $3, $4, 'manual', NULLIF($5, 0))
+		RETURNING id
+	`, in.Date, in.Description, in.Type, in.DocumentID, keyID).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+	if err := setEventEntities(ctx, tx, id, in.EntityIDs); err != nil {
+		return 0, err
+	}
+	return id, tx.Commit(ctx)
+}
+
+// UpdateTimelineEvent replaces the details and entities of an event,
+// extracted or curated
+func (s *Store) UpdateTimelineEvent(ctx context.Context, id int64, in TimelineEventInput) error {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx, `
+		UPDATE timeline_events
+		SET event_date = $2, description = $3, event_type = $4,
+			document_id = COALESCE($5, document_id), updated_at = NOW()
+		WHERE id = $1 AND NOT hidden
+	`, id, in.Date, in.Description, in.Type, in.DocumentID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM timeline_event_entities WHERE event_id = $1`, id); err != nil {
+		return err
+	}
+	if err := setEventEntities(ctx, tx, id, in.EntityIDs); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// DeleteTimelineEvent removes a curated event. Extracted events are hidden
+// instead, so that extraction doesn't bring them back.
+func (s *Store) DeleteTimelineEvent(ctx context.Context, id int64) error {
+	var origin string
+	err := s.pool.QueryRow(ctx, `
+		SELECT origin FROM timeline_events WHERE id = $1 AND NOT hidden
+	`, id).Scan(&origin)
+	if err != nil {
+		return notFound(err)
+	}
+
+	if origin == "manual" {
+		_, err = s.pool.Exec(ctx, `DELETE FROM timeline_events WHERE id = $1`, id)
+	} else {
+		_, err = s.pool.Exec(ctx, `UPDATE timeline_events SET hidden = TRUE, updated_at = NOW() WHERE id = $1`, id)
+	}
+	return err
+}
+
+func setEventEntities(ctx context.Context, tx pgx.Tx, id int64, entityIDs []int) error {
+	_, err := tx.Exec(ctx, `
+		INSERT INTO timeline_event_entities (event_id, entity_id)
+		SELECT $1, unnest($2::int[])
+		ON CONFLICT DO NOTHING
+	`, id, entityIDs)
+	return err
+}
@@ -0,0 +1,99 @@
+package store
+
+import (
+	"context"
+
+	"github.com/subculture-collective/epstein-db/api/internal/models"
+)
+
+// TripleFilter narrows SearchTriples. Zero fields match everything.
+type TripleFilter struct {
+	Predicates []string
+	SubjectID  int
+	ObjectID   int
+	EntityID   int // either side
+	DocumentID int
+	Method     string
+	Sort       Sort
+	Limit      int
+	Offset     int
+}
+
+// SearchTriples returns one page of triples with their provenance, most
+// confident first unless sorted otherwise. The sentence a triple came from
+// is left out for a restricted dataset unless ctx allows it.
+func (s *Store) SearchTriples(ctx context.Context, f TripleFilter) ([]models.Triple, error) {
+	rows, err := s.read.Query(ctx, `
+		SELECT t.id, t.predicate, t.confidence, t.extraction_method,
+			   t.subject_id, s.canonical_name, t.object_id, o.canonical_name,
+			   d.id, d.doc_id, CASE WHEN `+Readable("d", "$9")+` THEN t.sentence END, t.sentence_start, t.sentence_end
+		FROM triples t
+		JOIN entities s ON t.subject_id = s.id
+		JOIN entities o ON t.object_id = o.id
+		JOIN documents d ON t.document_id = d.id
+		WHERE d.deleted_at IS NULL AND s.deleted_at IS NULL AND o.deleted_at IS NULL
+		  AND NOT s.protected AND NOT o.protected
+		  AND (cardinality($1::text[]) = 0 OR t.predicate = ANY($1))
+		  AND ($2 = 0 OR t.subject_id = $2)
+		  AND ($3 = 0 OR t.object_id = $3)
+		  AND ($4 = 0 OR t.subject_id = $4 OR t.object_id = $4)
+		  AND ($5 = 0 OR t.document_id = $5)
+		  AND ($6 = '' OR t.extraction_method = $6)
+		ORDER BY `+TripleSorts.OrderBy(f.Sort)+`
+		LIMIT $7 OFFSET $8
+	`, f.Predicates, f.SubjectID, f.ObjectID, f.EntityID, f.DocumentID, f.Method, f.Limit, f.Offset, CanReadRestricted(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var triples []models.Triple
+	for rows.Next() {
+		var t models.Triple
+		if err := rows.Scan(&t.ID, &t.Predicate, &t.Confidence, &t.Method,
+			&t.Subject.ID, &t.Subject.CanonicalName, &t.Object.ID, &t.Object.CanonicalName,
+			&t.Provenance.DocumentID, &t.Provenance.DocID, &t.Provenance.Sentence,
+			&t.Provenance.SentenceStart, &t.Provenance.SentenceEnd); err != nil {
+			skip(ctx, "triples", t.ID, err)
+			continue
+		}
+		triples = append(triples, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	m, err := s.masker(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range triples {
+		m.MaskPtr(triples[i].Provenance.Sentence)
+	}
+	return triples, nil
+}
+
+// Predicates returns the 500 most used predicates with their counts
+func (s *Store) Predicates(ctx context.Context) ([]models.PredicateCount, error) {
+	rows, err := s.read.Query(ctx, `
+		SELECT predicate, COUNT(*) AS n
+		FROM triples
+		GROUP BY predicate
+		ORDER BY n DESC
+		LIMIT 500
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var predicates []models.PredicateCount
+	for rows.Next() {
+		var p models.PredicateCount
+		if err := rows.Scan(&p.Predicate, &p.Count); err != nil {
+			skip(ctx, "triples", p.Predicate, err)
+			continue
+		}
+		predicates = append(predicates, p)
+	}
+	return predicates, rows.Err()
+}
@@ -0,0 +1,53 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"github.com/subculture-collective/epstein-db/api/internal/models"
+)
+
+// Warnings collects the rows skipped while serving one request
+type Warnings struct {
+	mu   sync.Mutex
+	list []models.Warning
+}
+
+type warningsKey struct{}
+
+// WithWarnings returns a context in which the store records skipped rows,
+// and the collector they are recorded in
+func WithWarnings(ctx context.Context) (context.Context, *Warnings) {
+	w := &Warnings{}
+	return context.WithValue(ctx, warningsKey{}, w), w
+}
+
+// List returns the warnings recorded so far, or nil if there are none
+func (w *Warnings) List() []models.Warning {
+	if w == nil {
+		return nil
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.list) == 0 {
+		return nil
+	}
+	return append([]models.Warning(nil), w.list...)
+}
+
+// skip logs a row that failed to scan and records it in ctx's collector,
+// if any, so the response can say it is incomplete
+func skip(ctx context.Context, table string, row any, err error) {
+	id := fmt.Sprint(row)
+	slog.WarnContext(ctx, "store: skipped unreadable row", "table", table, "row", id, "err", err)
+
+	w, _ := ctx.Value(warningsKey{}).(*Warnings)
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	w.list = append(w.list, models.Warning{Table: table, Row: id, Message: err.Error()})
+	w.mu.Unlock()
+}
@@ -0,0 +1,107 @@
+// Package strength scores the relationships between people and
+// organizations beyond counting the documents they share. A shared
+// document counts for less the more names it mentions, in the manner of
+// TF-IDF: a flight log or contact book naming hundreds of people says
+// little about any two of them, a two-party letter says a lot. Mentions of
+// both on the same page and triples extracted between the pair add to the
+// score.
+package strength
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Weights tune the score
+type Weights struct {
+	PageBoost    float64 // extra weight of a shared document with a page naming both, as a fraction of its own
+	TripleWeight float64 // added per triple between the pair
+}
+
+// Result summarizes a run
+type Result struct {
+	Edges      int `json:"edges" doc:"Pairs scored"`
+	SamePage   int `json:"samePage" doc:"Pairs named on the same page of at least one document"`
+	Typed      int `json:"typed" doc:"Pairs with triples between them"`
+	DurationMs int `json:"durationMs"`
+}
+
+// edgesSQL scores every pair sharing at least two documents, or one and a
+// triple. A shared document weighs 1 when it names only the pair, falling
+// off with the log of the people and organizations it names, and grows
+// with the log of the fewer mentions of the two. Pages come from
+// page_entities, so documents not yet indexed by package pages share none.
+const edgesSQL = `
+	INSERT INTO entity_edges (source_id, target_id, documents, same_page, triples, score)
+	WITH mentions AS (
+		SELECT de.document_id, de.entity_id, GREATEST(COALESCE(de.mention_count, 1), 1) AS mentions,
+			   COALESCE((
+				   SELECT array_agg(pe.page_number)
+				   FROM page_entities pe
+				   WHERE pe.document_id = de.document_id AND pe.entity_id = de.entity_id
+			   ), '{}') AS pages
+		FROM document_entities de
+		JOIN entities e ON e.id = de.entity_id AND e.entity_type IN ('person', 'organization')
+	),
+	sizes AS (
+		SELECT document_id, COUNT(*) AS names
+		FROM mentions
+		GROUP BY document_id
+	),
+	pairs AS (
+		SELECT m1.entity_id AS source_id, m2.entity_id AS target_id,
+			   COUNT(*) AS documents,
+			   COUNT(*) FILTER (WHERE m1.pages && m2.pages) AS same_page,
+			   SUM(
+				   (1 + ln(LEAST(m1.mentions, m2.mentions))) / (1 + ln(s.names / 2.0))
+				   * CASE WHEN m1.pages && m2.pages THEN 1 + $1::float8 ELSE 1 END
+			   ) AS score
+		FROM mentions m1
+		JOIN mentions m2 ON m2.document_id = m1.document_id AND m2.entity_id > m1.entity_id
+		JOIN sizes s ON s.document_id = m1.document_id
+		GROUP BY m1.entity_id, m2.entity_id
+	),
+	typed AS (
+		SELECT LEAST(subject_id, object_id) AS source_id, GREATEST(subject_id, object_id) AS target_id,
+			   COUNT(*) AS triples
+		FROM triples
+		WHERE subject_id <> object_id
+		GROUP BY 1, 2
+	)
+	SELECT p.source_id, p.target_id, p.documents, p.same_page, COALESCE(t.triples, 0),
+		   p.score + $2::float8 * COALESCE(t.triples, 0)
+	FROM pairs p
+	LEFT JOIN typed t ON t.source_id = p.source_id AND t.target_id = p.target_id
+	WHERE p.documents >= 2 OR t.triples > 0
+`
+
+// Run rescores every pair and replaces the stored edges
+func Run(ctx context.Context, pool *pgxpool.Pool, w Weights) (*Result, error) {
+	started := time.Now()
+	result := &Result{}
+
+	err := pgx.BeginFunc(ctx, pool, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, `DELETE FROM entity_edges`); err != nil {
+			return err
+		}
+		tag, err := tx.Exec(ctx, edgesSQL, w.PageBoost, w.TripleWeight)
+		if err != nil {
+			return err
+		}
+		result.Edges = int(tag.RowsAffected())
+
+		return tx.QueryRow(ctx, `
+			SELECT COUNT(*) FILTER (WHERE same_page > 0), COUNT(*) FILTER (WHERE triples > 0)
+			FROM entity_edges
+		`).Scan(&result.SamePage, &result.Typed)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result.DurationMs = int(time.Since(started).Milliseconds())
+	return result, nil
+}
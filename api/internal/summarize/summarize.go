@@ -0,0 +1,243 @@
+package summarize
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/subculture-collective/epstein-db/api/internal/jobs"
+	"github.com/subculture-collective/epstein-db/api/internal/llm"
+)
+
+// JobKind is the jobs.kind for summarization jobs
+const JobKind = "summarize"
+
+const systemPrompt = `You are an expert document analyst summarizing legal documents, financial records, and correspondence related to the Jeffrey Epstein case.
+
+Write strictly from the document text. Every claim in the summaries must be supported by a passage you quote exactly in "citations". If the text is illegible or empty, say so rather than guessing.`
+
+// ErrBudgetExhausted stops a run once its token budget is spent
+var ErrBudgetExhausted = errors.New("summarize: token budget exhausted")
+
+// Params are the options accepted by a summarization job
+type Params struct {
+	DatasetID int  `json:"datasetId"`
+	Force     bool `json:"force"` // re-summarize documents that already have a summary
+}
+
+// Config holds rate-limit and cost controls
+type Config struct {
+	RequestsPerMinute int
+	MaxTokens         int // total input+output tokens per run, 0 for unlimited
+	MaxDocumentChars  int
+}
+
+// DefaultConfig returns conservative defaults
+func DefaultConfig() Config {
+	return Config{
+		RequestsPerMinute: 50,
+		MaxTokens:         2_000_000,
+		MaxDocumentChars:  100_000,
+	}
+}
+
+// Result is stored on the job when it finishes
+type Result struct {
+	Summarized   int `json:"summarized"`
+	Failed       int `json:"failed"`
+	InputTokens  int `json:"inputTokens"`
+	OutputTokens int `json:"outputTokens"`
+}
+
+// Citation is a quoted source passage and its location in full_text
+type Citation struct {
+	Quote string `json:"quote"`
+	Start int    `json:"start"`
+	End   int    `json:"end"`
+}
+
+type summary struct {
+	Summary         string `json:"summary"`
+	DetailedSummary string `json:"detailedSummary"`
+	Citations       []struct {
+		Quote string `json:"quote"`
+	} `json:"citations"`
+}
+
+// Worker generates summary and detailed_summary for documents
+type Worker struct {
+	pool   *pgxpool.Pool
+	queue  *jobs.Queue
+	client llm.Client
+	cfg    Config
+}
+
+// NewWorker creates a summarization worker
+func NewWorker(pool *pgxpool.Pool, client llm.Client, cfg Config) *Worker {
+	return &Worker{
+		pool:   pool,
+		queue:  jobs.NewQueue(pool),
+		client: client,
+		cfg:    cfg,
+	}
+}
+
+// HandleJob processes a queued summarization job
+func (w *Worker) HandleJob(ctx context.Context, job *jobs.Job) (any, error) {
+	var params Params
+	if err := job.DecodeParams(&params); err != nil {
+		return nil, err
+	}
+
+	return w.Run(ctx, params, func(done, total int) {
+		if err := w.queue.SetProgress(ctx, job.ID, done, total); err != nil {
+			log.Printf("summarize: job %d progress: %v", job.ID, err)
+		}
+	})
+}
+
+// Run summarizes every matching document, calling progress after each one
+func (w *Worker) Run(ctx context.Context, params Params, progress func(done, total int)) (Result, error) {
+	var result Result
+
+	ids, err := w.pending(ctx, params)
+	if err != nil {
+		return result, err
+	}
+
+	interval := time.Minute / time.Duration(max(w.cfg.RequestsPerMinute, 1))
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for i, id := range ids {
+		if w.cfg.MaxTokens > 0 && result.InputTokens+result.OutputTokens >= w.cfg.MaxTokens {
+			return result, ErrBudgetExhausted
+		}
+
+		select {
+		case <-ctx.Done():
+			return result, ctx.Err()
+		case <-ticker.C:
+		}
+
+		in, out, err := w.summarizeDocument(ctx, id)
+		result.InputTokens += in
+		result.OutputTokens += out
+		if err != nil {
+			log.Printf("summarize: document %d: %v", id, err)
+			result.Failed++
+		} else {
+			result.Summarized++
+		}
+
+		if progress != nil {
+			progress(i+1, len(ids))
+		}
+	}
+
+	return result, nil
+}
+
+func (w *Worker) pending(ctx context.Context, params Params) ([]int, error) {
+	rows, err := w.pool.Query(ctx, `
+		SELECT id FROM documents
+		WHERE ($1 = 0 OR dataset_id = $1)
+		  AND full_text IS NOT NULL AND full_text != ''
+		  AND ($2 OR summary IS NULL OR detailed_summary IS NULL)
+		ORDER BY id
+	`, params.DatasetID, params.Force)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+func (w *Worker) summarizeDocument(ctx context.Context, id int) (int, int, error) {
+	var docID, text string
+	err := w.pool.QueryRow(ctx, `
+		SELECT doc_id, full_text FROM documents WHERE id = $1
+	`, id).Scan(&docID, &text)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	truncated := text
+	if len(truncated) > w.cfg.MaxDocumentChars {
+		// Back off to the start of a rune so the prompt stays valid UTF-8
+		cut := w.cfg.MaxDocumentChars
+		for cut > 0 && !utf8.RuneStart(truncated[cut]) {
+			cut--
+		}
+		truncated = truncated[:cut] + "\n\n[TRUNCATED - document continues...]"
+	}
+
+	prompt := fmt.Sprintf(`Summarize document %s.
+
+<document>
+%s
+</document>
+
+Respond with a JSON object:
+{
+  "summary": "One sentence summary of the document",
+  "detailedSummary": "A paragraph explaining the document's content and significance",
+  "citations": [{"quote": "exact passage from the document supporting the summary"}]
+}
+
+Return ONLY valid JSON.`, docID, truncated)
+
+	resp, err := w.client.Complete(ctx, llm.UserPrompt(systemPrompt, prompt, 2048))
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var s summary
+	if err := llm.DecodeJSON(resp.Text, &s); err != nil {
+		return resp.InputTokens, resp.OutputTokens, err
+	}
+	if s.Summary == "" {
+		return resp.InputTokens, resp.OutputTokens, errors.New("empty summary")
+	}
+
+	// Only keep citations that can be found in the source text
+	citations := []Citation{}
+	for _, c := range s.Citations {
+		quote := strings.TrimSpace(c.Quote)
+		if quote == "" {
+			continue
+		}
+		if start := strings.Index(text, quote); start >= 0 {
+			citations = append(citations, Citation{Quote: quote, Start: start, End: start + len(quote)})
+		}
+	}
+
+	_, err = w.pool.Exec(ctx, `
+		UPDATE documents SET
+			summary = $2,
+			detailed_summary = $3,
+			summary_citations = $4,
+			summary_model = $5,
+			summarized_at = NOW(),
+			updated_at = NOW()
+		WHERE id = $1
+	`, id, s.Summary, s.DetailedSummary, citations, resp.Model)
+
+	return resp.InputTokens, resp.OutputTokens, err
+}
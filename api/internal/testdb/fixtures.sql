@@ -0,0 +1,63 @@
+-- Seed data for integration tests. Small enough to reason about by hand:
+-- three people and an organization co-occurring across three documents.
+-- Around them are the rows callers mustn't all see: a document of a
+-- restricted dataset, a protected person named in it and in document 3, and
+-- a soft-deleted document. A researcher key, test-researcher-key, can read
+-- the restricted one.
+
+INSERT INTO api_keys (id, name, key_hash, key_prefix, role) VALUES
+    (1, 'Handler tests', encode(sha256('test-researcher-key'::bytea), 'hex'), 'test-res', 'researcher');
+
+INSERT INTO datasets (id, name, status, document_count, ready_at, visibility) VALUES
+    (1, 'DataSet 1', 'ready', 3, NOW(), 'public'),
+    (2, 'DataSet 2', 'registered', 0, NULL, 'public'),
+    (3, 'DataSet 3', 'ready', 1, NOW(), 'restricted');
+
+INSERT INTO documents (id, doc_id, dataset_id, full_text, page_count, summary, document_type) VALUES
+    (1, 'EFTA00000001', 1, 'Jeffrey Epstein met Jane Roe at the Palm Beach residence.', 1, 'A meeting in Palm Beach.', 'email'),
+    (2, 'EFTA00000002', 1, 'Jane Roe wired funds to Acme Holdings on behalf of Jeffrey Epstein.', 2, 'A wire transfer.', 'financial record'),
+    (3, 'EFTA00000003', 1, 'John Doe and Jane Roe were deposed about Acme Holdings.', 4, 'A deposition naming Mary Major.', 'deposition'),
+    (4, 'EFTA00000004', 3, 'John Doe flew with Mary Major to Palm Beach.', 1, 'John Doe and Mary Major flew to Palm Beach.', 'flight log');
+
+INSERT INTO documents (id, doc_id, dataset_id, full_text, page_count, summary, document_type, deleted_at, deleted_reason) VALUES
+    (5, 'EFTA00000005', 1, 'Jane Roe flew to Palm Beach.', 1, 'A travel note.', 'email', NOW(), 'Duplicate ingest');
+
+INSERT INTO entities (id, canonical_name, entity_type, layer, document_count, connection_count) VALUES
+    (1, 'Jeffrey Epstein', 'person', 0, 2, 2),
+    (2, 'Jane Roe', 'person', 1, 3, 3),
+    (3, 'Acme Holdings', 'organization', 1, 2, 3),
+    (4, 'John Doe', 'person', 2, 2, 2),
+    (5, 'Palm Beach', 'location', NULL, 2, 0);
+
+INSERT INTO entities (id, canonical_name, entity_type, document_count, connection_count, protected, protected_at, protected_by, protected_reason) VALUES
+    (6, 'Mary Major', 'person', 2, 4, TRUE, NOW(), 1, 'Identified victim');
+
+INSERT INTO document_entities (document_id, entity_id, mention_count) VALUES
+    (1, 1, 1), (1, 2, 1), (1, 5, 1),
+    (2, 1, 1), (2, 2, 1), (2, 3, 1),
+    (3, 2, 1), (3, 3, 1), (3, 4, 1), (3, 6, 1),
+    (4, 5, 1), (4, 6, 1);
+
+INSERT INTO document_entities (document_id, entity_id, mention_count, role, role_quote) VALUES
+    (4, 4, 2, 'pilot', 'John Doe flew with Mary Major');
+
+INSERT INTO triples (document_id, subject_id, predicate, object_id, confidence, sentence, sentence_start, sentence_end, extraction_method) VALUES
+    (1, 1, 'met', 2, 0.9, 'Jeffrey Epstein met Jane Roe at the Palm Beach residence.', 0, 57, 'rule'),
+    (2, 2, 'paid', 3, 0.8, 'Jane Roe wired funds to Acme Holdings on behalf of Jeffrey Epstein.', 0, 67, 'llm'),
+    (4, 4, 'flew_to', 5, 0.7, 'John Doe flew with Mary Major to Palm Beach.', 0, 44, 'rule'),
+    (4, 4, 'flew_with', 6, 0.7, 'John Doe flew with Mary Major to Palm Beach.', 0, 44, 'rule'),
+    (5, 2, 'flew_to', 5, 0.7, 'Jane Roe flew to Palm Beach.', 0, 28, 'rule');
+
+INSERT INTO ppp_loans (loan_number, borrower_name, borrower_city, borrower_state, loan_amount, lender, date_approved) VALUES
+    ('1000000001', 'ACME HOLDINGS LLC', 'New York', 'NY', 150000.00, 'First Bank', '2020-05-01');
+
+INSERT INTO pattern_findings (title, description, pattern_type, entity_ids, evidence, confidence, status) VALUES
+    ('Payments through Acme', 'Funds routed through Acme Holdings.', 'financial_flow', ARRAY[2, 3], '{"documents": ["EFTA00000002"]}', 0.7, 'hypothesis');
+
+-- Explicit ids above don't advance the sequences
+SELECT setval('documents_id_seq', (SELECT MAX(id) FROM documents));
+SELECT setval('entities_id_seq', (SELECT MAX(id) FROM entities));
+SELECT setval('api_keys_id_seq', (SELECT MAX(id) FROM api_keys));
+
+-- The stats views were built empty by the migrations
+SELECT refresh_stats();
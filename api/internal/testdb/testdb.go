@@ -0,0 +1,175 @@
+// Package testdb provides a throwaway, migrated and seeded Postgres for
+// integration tests.
+//
+// Set TEST_DATABASE_URL to use an existing server; each Start creates and
+// later drops its own database there. Otherwise Start runs the same image as
+// docker-compose.yml in a container on a random port, which needs docker on
+// PATH.
+package testdb
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/subculture-collective/epstein-db/api/internal/migrations"
+)
+
+// Image matches the postgres service in docker-compose.yml
+const Image = "pgvector/pgvector:pg16"
+
+//go:embed fixtures.sql
+var fixtures string
+
+// DB is a migrated database loaded with fixtures.sql
+type DB struct {
+	Pool *pgxpool.Pool
+	URL  string
+
+	cleanup func() error
+}
+
+// Start brings up a database, applies every migration and loads the fixtures
+func Start(ctx context.Context) (*DB, error) {
+	var (
+		d   *DB
+		err error
+	)
+	if base := os.Getenv("TEST_DATABASE_URL"); base != "" {
+		d, err = createDatabase(ctx, base)
+	} else {
+		d, err = runContainer(ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := d.setup(ctx); err != nil {
+		d.Close()
+		return nil, err
+	}
+	return d, nil
+}
+
+// Close releases the pool and removes the database or container
+func (d *DB) Close() error {
+	if d.Pool != nil {
+		d.Pool.Close()
+	}
+	return d.cleanup()
+}
+
+func (d *DB) setup(ctx context.Context) error {
+	pool, err := pgxpool.New(ctx, d.URL)
+	if err != nil {
+		return fmt.Errorf("connect: %w", err)
+	}
+	d.Pool = pool
+
+	if err := waitReady(ctx, pool); err != nil {
+		return err
+	}
+	if _, err := migrations.Up(ctx, pool); err != nil {
+		return fmt.Errorf("migrate: %w", err)
+	}
+	if _, err := pool.Exec(ctx, fixtures); err != nil {
+		return fmt.Errorf("load fixtures: %w", err)
+	}
+	return nil
+}
+
+// createDatabase makes a uniquely named database on an existing server
+func createDatabase(ctx context.Context, base string) (*DB, error) {
+	u, err := url.Parse(base)
+	if err != nil {
+		return nil, fmt.Errorf("parse TEST_DATABASE_URL: %w", err)
+	}
+	name := fmt.Sprintf("epstein_test_%d", time.Now().UnixNano())
+
+	admin, err := pgx.Connect(ctx, base)
+	if err != nil {
+		return nil, fmt.Errorf("connect to TEST_DATABASE_URL: %w", err)
+	}
+	defer admin.Close(ctx)
+
+	if _, err := admin.Exec(ctx, "CREATE DATABASE "+name); err != nil {
+		return nil, fmt.Errorf("create database: %w", err)
+	}
+
+	u.Path = "/" + name
+	return &DB{
+		URL: u.String(),
+		cleanup: func() error {
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			conn, err := pgx.Connect(ctx, base)
+			if err != nil {
+				return err
+			}
+			defer conn.Close(ctx)
+			_, err = conn.Exec(ctx, "DROP DATABASE IF EXISTS "+name+" WITH (FORCE)")
+			return err
+		},
+	}, nil
+}
+
+// runContainer starts Image with docker and publishes it on a random port
+func runContainer(ctx context.Context) (*DB, error) {
+	out, err := exec.CommandContext(ctx, "docker", "run", "-d", "--rm",
+		"-e", "POSTGRES_USER=epstein",
+		"-e", "POSTGRES_PASSWORD=epstein_test",
+		"-e", "POSTGRES_DB=epstein",
+		"-p", "127.0.0.1::5432",
+		Image,
+	).Output()
+	if err != nil {
+		return nil, fmt.Errorf("docker run: %w", err)
+	}
+	id := strings.TrimSpace(string(out))
+	remove := func() error {
+		return exec.Command("docker", "rm", "-f", id).Run()
+	}
+
+	out, err = exec.CommandContext(ctx, "docker", "port", id, "5432/tcp").Output()
+	if err != nil {
+		remove()
+		return nil, fmt.Errorf("docker port: %w", err)
+	}
+	// One line per address family; the first is enough
+	addr, _, _ := strings.Cut(strings.TrimSpace(string(out)), "\n")
+
+	return &DB{
+		URL:     "postgresql://epstein:epstein_test@" + addr + "/epstein?sslmode=disable",
+		cleanup: remove,
+	}, nil
+}
+
+// waitReady polls until the server accepts queries. A fresh container
+// restarts once after initdb, so a single successful ping isn't proof.
+func waitReady(ctx context.Context, pool *pgxpool.Pool) error {
+	ctx, cancel := context.WithTimeout(ctx, 60*time.Second)
+	defer cancel()
+
+	ok := 0
+	for ok < 3 {
+		if err := pool.Ping(ctx); err != nil {
+			ok = 0
+		} else {
+			ok++
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("database not ready: %w", ctx.Err())
+		case <-time.After(500 * time.Millisecond):
+		}
+	}
+	return nil
+}
@@ -0,0 +1,261 @@
+// Package thumbnails renders the pages of documents' stored PDFs and
+// images as JPEGs at a few widths, for document browsers showing a grid of
+// pages. PDFs are rasterized with pdftoppm, like package ocr does, once at
+// the largest size and scaled down from there. Thumbnails are kept under
+// the media directory, beside entity images.
+package thumbnails
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	_ "image/png" // registers PNG with image.Decode
+	"io/fs"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/subculture-collective/epstein-db/api/internal/media"
+)
+
+// Sizes
+const (
+	SizeSmall  = "small"
+	SizeMedium = "medium"
+	SizeLarge  = "large"
+)
+
+// Sizes lists the thumbnail sizes, smallest first
+var Sizes = []string{SizeSmall, SizeMedium, SizeLarge}
+
+// Widths are the widths of the sizes in pixels. Pages narrower than a size
+// aren't enlarged.
+var Widths = map[string]int{
+	SizeSmall:  200,
+	SizeMedium: 600,
+	SizeLarge:  1200,
+}
+
+// ErrNotFound is returned for a page without a thumbnail
+var ErrNotFound = errors.New("thumbnails: not rendered")
+
+// Result summarizes a run
+type Result struct {
+	Documents  int `json:"documents" doc:"Documents rendered"`
+	Pages      int `json:"pages" doc:"Pages rendered"`
+	Skipped    int `json:"skipped" doc:"Documents whose file is missing or unreadable"`
+	DurationMs int `json:"durationMs"`
+}
+
+// staleSQL reads the next batch of documents after $2 with a stored PDF or
+// image that changed since its pages were rendered, or every one if $1
+const staleSQL = `
+	SELECT d.id, d.file_path
+	FROM documents d
+	LEFT JOIN (
+		SELECT document_id, MIN(rendered_at) AS rendered_at
+		FROM page_thumbnails
+		GROUP BY document_id
+	) t ON t.document_id = d.id
+	WHERE d.id > $2
+	  AND lower(d.file_path) ~ '\.(pdf|png|jpe?g)$'
+	  AND ($1 OR t.rendered_at IS NULL OR COALESCE(d.updated_at, d.created_at) > t.rendered_at)
+	ORDER BY d.id
+	LIMIT $3
+`
+
+// batchSize bounds the documents read per query
+const batchSize = 100
+
+// Path is where the thumbnail of page n of a document at size is kept
+// under dir
+func Path(dir string, documentID, page int, size string) string {
+	return filepath.Join(dir, "pages", strconv.Itoa(documentID), fmt.Sprintf("%d-%s.jpg", page, size))
+}
+
+// Find returns the thumbnail of page n of a document at size, or
+// ErrNotFound if it hasn't been rendered
+func Find(dir string, documentID, page int, size string) (string, error) {
+	name := Path(dir, documentID, page, size)
+	if _, err := os.Stat(name); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return "", ErrNotFound
+		}
+		return "", err
+	}
+	return name, nil
+}
+
+// Run renders the pages of the documents whose files changed since they
+// were last rendered, or of every document with a PDF or image when force
+// is set, into dir. A document whose file can't be read is skipped and
+// tried again on the next run.
+func Run(ctx context.Context, pool *pgxpool.Pool, dir string, force bool) (*Result, error) {
+	started := time.Now()
+	result := &Result{}
+
+	after := 0
+	for {
+		rows, err := pool.Query(ctx, staleSQL, force, after, batchSize)
+		if err != nil {
+			return nil, err
+		}
+		type doc struct {
+			id   int
+			path string
+		}
+		docs, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (doc, error) {
+			var d doc
+			return d, row.Scan(&d.id, &d.path)
+		})
+		if err != nil {
+			return nil, err
+		}
+		if len(docs) == 0 {
+			break
+		}
+
+		for _, d := range docs {
+			pages, err := render(ctx, pool, dir, d.id, d.path)
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			if err != nil {
+				log.Printf("thumbnails: document %d: %v", d.id, err)
+				result.Skipped++
+				continue
+			}
+			result.Documents++
+			result.Pages += pages
+		}
+		after = docs[len(docs)-1].id
+	}
+
+	result.DurationMs = int(time.Since(started).Milliseconds())
+	return result, nil
+}
+
+// thumbnail is a rendered file
+type thumbnail struct {
+	page          int
+	size          string
+	width, height int
+	bytes         int
+}
+
+// render renders every page of one document's file at every size,
+// replacing its earlier thumbnails, and returns the number of pages
+func render(ctx context.Context, pool *pgxpool.Pool, dir string, documentID int, path string) (int, error) {
+	if _, err := os.Stat(path); err != nil {
+		return 0, err
+	}
+
+	images := []string{path}
+	if strings.EqualFold(filepath.Ext(path), ".pdf") {
+		tmp, err := os.MkdirTemp("", "thumbnails-")
+		if err != nil {
+			return 0, err
+		}
+		defer os.RemoveAll(tmp)
+		if images, err = rasterize(ctx, path, tmp, Widths[SizeLarge]); err != nil {
+			return 0, err
+		}
+	}
+
+	docDir := filepath.Dir(Path(dir, documentID, 1, SizeSmall))
+	if err := os.RemoveAll(docDir); err != nil {
+		return 0, err
+	}
+
+	var rendered []thumbnail
+	for i, name := range images {
+		page := i + 1
+		src, err := decode(name)
+		if err != nil {
+			return 0, fmt.Errorf("page %d: %w", page, err)
+		}
+		for _, size := range Sizes {
+			img := src
+			if src.Bounds().Dx() > Widths[size] {
+				img = media.Resize(src, Widths[size])
+			}
+			var buf bytes.Buffer
+			if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 80}); err != nil {
+				return 0, err
+			}
+			if err := media.WriteFile(Path(dir, documentID, page, size), buf.Bytes()); err != nil {
+				return 0, err
+			}
+			b := img.Bounds()
+			rendered = append(rendered, thumbnail{page: page, size: size, width: b.Dx(), height: b.Dy(), bytes: buf.Len()})
+		}
+	}
+
+	err := pgx.BeginFunc(ctx, pool, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, `DELETE FROM page_thumbnails WHERE document_id = $1`, documentID); err != nil {
+			return err
+		}
+		for _, t := range rendered {
+			_, err := tx.Exec(ctx, `
+				INSERT INTO page_thumbnails (document_id, page_number, size, width, height, byte_size)
+				VALUES ($1, $2, $3, $4, $5, $6)
+			`, documentID, t.page, t.size, t.width, t.height, t.bytes)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(images), nil
+}
+
+func decode(name string) (image.Image, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, _, err := image.Decode(f)
+	return img, err
+}
+
+// rasterize renders each PDF page to a JPEG width pixels wide with
+// pdftoppm and returns the image paths in page order
+func rasterize(ctx context.Context, pdfPath, dir string, width int) ([]string, error) {
+	binary := os.Getenv("PDFTOPPM_PATH")
+	if binary == "" {
+		binary = "pdftoppm"
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, binary, "-scale-to-x", strconv.Itoa(width), "-scale-to-y", "-1",
+		"-jpeg", pdfPath, filepath.Join(dir, "page"))
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("pdftoppm: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	images, err := filepath.Glob(filepath.Join(dir, "page-*.jpg"))
+	if err != nil {
+		return nil, err
+	}
+
+	// pdftoppm zero-pads page numbers to a common width, so lexical order
+	// is page order
+	sort.Strings(images)
+	return images, nil
+}
@@ -0,0 +1,120 @@
+// Package timeline builds the corpus-wide chronology. Extract turns dated
+// relationships and verified public-record matches into timeline events;
+// researchers add and correct events through the API.
+package timeline
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Event types
+const (
+	TypeFlight        = "flight"
+	TypeMeeting       = "meeting"
+	TypeCommunication = "communication"
+	TypeLegal         = "legal"
+	TypeFinancial     = "financial"
+	TypeRelationship  = "relationship"
+	TypeOther         = "other"
+)
+
+// Types lists every event type
+var Types = []string{TypeFlight, TypeMeeting, TypeCommunication, TypeLegal, TypeFinancial, TypeRelationship, TypeOther}
+
+// Event origins
+const (
+	OriginExtracted = "extracted"
+	OriginManual    = "manual"
+)
+
+// Result counts the events added by an extraction run
+type Result struct {
+	Relationships int64 `json:"relationships"`
+	Records       int64 `json:"records"`
+}
+
+// Extract adds an event for every dated relationship and every dated,
+// verified cross-reference record that doesn't have one yet, and links each
+// extracted event to the entities involved. Running it again only picks up
+// what is new.
+func Extract(ctx context.Context, pool *pgxpool.Pool) (Result, error) {
+	var r Result
+
+	// Dated triples, typed by their predicate
+	tag, err := pool.Exec(ctx, `
+		INSERT INTO timeline_events (event_date, description, event_type, document_id, triple_id, origin)
+		SELECT t.timestamp,
+			   s.canonical_name || ' ' || replace(t.predicate, '_', ' ') || ' ' || o.canonical_name ||
+			   COALESCE(' in ' || l.canonical_name, ''),
+			   CASE
+				   WHEN t.predicate ~* '\m(fl(ew|y|ies|ying|own|ight)|pilot)' THEN 'flight'
+				   WHEN t.predicate ~* '\m(met|meet|visit|din(ed|ner)|stay)' THEN 'meeting'
+				   WHEN t.predicate ~* '\m(call|e?mail|wr(ote|ite)|messag|letter|phone|text)' THEN 'communication'
+				   WHEN t.predicate ~* '\m(su(e|ed|ing)|charg|testif|depos|arrest|indict|plea|convict|sentenc)' THEN 'legal'
+				   WHEN t.predicate ~* '\m(paid|pa(y|ys|ying)|wire|transfer|donat|loan|bought|sold|fund)' THEN 'financial'
+				   ELSE 'relationship'
+			   END,
+			   t.document_id, t.id, 'extracted'
+		FROM triples t
+		JOIN entities s ON s.id = t.subject_id
+		JOIN entities o ON o.id = t.object_id
+		LEFT JOIN entities l ON l.id = t.location_id
+		WHERE t.timestamp IS NOT NULL
+		ON CONFLICT (triple_id) WHERE triple_id IS NOT NULL DO NOTHING
+	`)
+	if err != nil {
+		return r, err
+	}
+	r.Relationships = tag.RowsAffected()
+
+	_, err = pool.Exec(ctx, `
+		INSERT INTO timeline_event_entities (event_id, entity_id)
+		SELECT e.id, entity_id
+		FROM timeline_events e
+		JOIN triples t ON t.id = e.triple_id
+		CROSS JOIN LATERAL unnest(ARRAY[t.subject_id, t.object_id, t.location_id]) entity_id
+		WHERE entity_id IS NOT NULL
+		ON CONFLICT DO NOTHING
+	`)
+	if err != nil {
+		return r, err
+	}
+
+	// Dated public records matched to at least one entity
+	tag, err = pool.Exec(ctx, `
+		INSERT INTO timeline_events (event_date, description, event_type, crossref, crossref_id, origin)
+		SELECT date, description, 'financial', source, source_id, 'extracted'
+		FROM (
+			SELECT DISTINCT m.source, m.source_id,
+				   COALESCE(p.date_approved, f.contribution_date, g.award_date) AS date,
+				   CASE m.source
+					   WHEN 'ppp' THEN format('PPP loan of $%s to %s from %s', p.loan_amount, p.borrower_name, p.lender)
+					   WHEN 'fec' THEN format('Political contribution of $%s by %s to %s', f.amount, f.contributor_name, f.committee_name)
+					   WHEN 'grants' THEN format('Federal grant of $%s to %s from %s', g.award_amount, g.recipient_name, g.awarding_agency)
+				   END AS description
+			FROM entity_crossref_matches m
+			LEFT JOIN ppp_loans p ON m.source = 'ppp' AND p.id = m.source_id
+			LEFT JOIN fec_contributions f ON m.source = 'fec' AND f.id = m.source_id
+			LEFT JOIN federal_grants g ON m.source = 'grants' AND g.id = m.source_id
+			WHERE m.verified AND NOT m.false_positive
+		) records
+		WHERE date IS NOT NULL AND description IS NOT NULL
+		ON CONFLICT (crossref, crossref_id) WHERE crossref IS NOT NULL AND origin = 'extracted' DO NOTHING
+	`)
+	if err != nil {
+		return r, err
+	}
+	r.Records = tag.RowsAffected()
+
+	_, err = pool.Exec(ctx, `
+		INSERT INTO timeline_event_entities (event_id, entity_id)
+		SELECT e.id, m.entity_id
+		FROM timeline_events e
+		JOIN entity_crossref_matches m ON m.source = e.crossref AND m.source_id = e.crossref_id
+		WHERE e.origin = 'extracted' AND m.verified AND NOT m.false_positive
+		ON CONFLICT DO NOTHING
+	`)
+	return r, err
+}
@@ -0,0 +1,22 @@
+// Package timeout bounds how long a request may spend on its work
+package timeout
+
+import (
+	"context"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Middleware gives each request's user context a deadline d from now.
+// Handlers that pass c.UserContext() to queries have them cancelled once it
+// passes, and the error handler reports 504.
+func Middleware(d time.Duration) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx, cancel := context.WithTimeout(c.UserContext(), d)
+		defer cancel()
+
+		c.SetUserContext(ctx)
+		return c.Next()
+	}
+}
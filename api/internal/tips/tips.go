@@ -0,0 +1,196 @@
+// Package tips keeps the moderation queue of leads submitted by the public.
+// A tip is free text, optionally pointing at documents and entities; admins
+// accept or reject it, and can turn an accepted tip into a pattern finding
+// for researchers to investigate.
+package tips
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Statuses
+const (
+	StatusPending  = "pending"
+	StatusAccepted = "accepted"
+	StatusRejected = "rejected"
+)
+
+// Statuses lists every tip status
+var Statuses = []string{StatusPending, StatusAccepted, StatusRejected}
+
+var (
+	// ErrNoReference is returned when a tip points at a document or entity
+	// that doesn't exist
+	ErrNoReference = errors.New("tips: no such document or entity")
+	// ErrConverted is returned when converting a tip that already has a
+	// pattern finding
+	ErrConverted = errors.New("tips: already converted")
+)
+
+// Tip is a submitted lead and its review
+type Tip struct {
+	ID          int64      `json:"id"`
+	Body        string     `json:"body"`
+	Contact     *string    `json:"contact"`
+	DocumentIDs []int      `json:"documentIds"`
+	EntityIDs   []int      `json:"entityIds"`
+	KeyID       *int       `json:"keyId" doc:"API key of the submitter, if any"`
+	Status      string     `json:"status" enum:"pending,accepted,rejected"`
+	SubmittedAt time.Time  `json:"submittedAt"`
+	ReviewedAt  *time.Time `json:"reviewedAt"`
+	ReviewedBy  *int       `json:"reviewedBy"`
+	ReviewNote  *string    `json:"reviewNote"`
+	PatternID   *int       `json:"patternId" doc:"Pattern finding made from the tip"`
+}
+
+// Submission is a new tip
+type Submission struct {
+	Body        string
+	Contact     string
+	DocumentIDs []int
+	EntityIDs   []int
+	KeyID       int // 0 when anonymous
+}
+
+// Pattern describes the pattern finding to make from a tip
+type Pattern struct {
+	Title       string
+	Description string
+	Type        string
+	Confidence  *float64
+}
+
+const columns = `id, body, contact, document_ids, entity_ids, key_id, status,
+	submitted_at, reviewed_at, reviewed_by, review_note, pattern_id`
+
+func scan(row pgx.Row) (*Tip, error) {
+	var t Tip
+	err := row.Scan(&t.ID, &t.Body, &t.Contact, &t.DocumentIDs, &t.EntityIDs, &t.KeyID, &t.Status,
+		&t.SubmittedAt, &t.ReviewedAt, &t.ReviewedBy, &t.ReviewNote, &t.PatternID)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// Submit queues a tip. It returns ErrNoReference if a referenced document
+// or entity doesn't exist.
+func Submit(ctx context.Context, pool *pgxpool.Pool, s Submission) (*Tip, error) {
+	var documents, entities int
+	err := pool.QueryRow(ctx, `
+		SELECT (SELECT COUNT(*) FROM documents WHERE id = ANY($1)),
+			   (SELECT COUNT(*) FROM entities WHERE id = ANY($2))
+	`, s.DocumentIDs, s.EntityIDs).Scan(&documents, &entities)
+	if err != nil {
+		return nil, err
+	}
+	if documents != len(s.DocumentIDs) || entities != len(s.EntityIDs) {
+		return nil, ErrNoReference
+	}
+
+	return scan(pool.QueryRow(ctx, `
+		INSERT INTO tips (body, contact, document_ids, entity_ids, key_id)
+		VALUES ($1, NULLIF($2, ''), $3, $4, NULLIF($5, 0))
+		RETURNING `+columns,
+		s.Body, s.Contact, s.DocumentIDs, s.EntityIDs, s.KeyID))
+}
+
+// List returns one page of tips, optionally in one status, oldest first so
+// the queue is worked in order
+func List(ctx context.Context, pool *pgxpool.Pool, status string, limit, offset int) ([]Tip, error) {
+	rows, err := pool.Query(ctx, `
+		SELECT `+columns+`
+		FROM tips
+		WHERE $1 = '' OR status = $1
+		ORDER BY submitted_at, id
+		LIMIT $2 OFFSET $3
+	`, status, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []Tip{}
+	for rows.Next() {
+		t, err := scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *t)
+	}
+	return list, rows.Err()
+}
+
+// Get returns one tip, or pgx.ErrNoRows
+func Get(ctx context.Context, pool *pgxpool.Pool, id int64) (*Tip, error) {
+	return scan(pool.QueryRow(ctx, `SELECT `+columns+` FROM tips WHERE id = $1`, id))
+}
+
+// Review sets a tip's status and note as reviewed by reviewerKey, or
+// returns pgx.ErrNoRows
+func Review(ctx context.Context, pool *pgxpool.Pool, id int64, reviewerKey int, status, note string) (*Tip, error) {
+	return scan(pool.QueryRow(ctx, `
+		UPDATE tips
+		SET status = $2, review_note = NULLIF($3, ''), reviewed_at = NOW(), reviewed_by = NULLIF($4, 0)
+		WHERE id = $1
+		RETURNING `+columns,
+		id, status, note, reviewerKey))
+}
+
+// Convert makes a hypothesis pattern finding from a tip, with the tip's
+// entities and its text and documents as evidence, and marks the tip
+// accepted. It returns pgx.ErrNoRows for an unknown tip and ErrConverted
+// if the tip already has a pattern.
+func Convert(ctx context.Context, pool *pgxpool.Pool, id int64, reviewerKey int, p Pattern) (*Tip, error) {
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	t, err := scan(tx.QueryRow(ctx, `SELECT `+columns+` FROM tips WHERE id = $1 FOR UPDATE`, id))
+	if err != nil {
+		return nil, err
+	}
+	if t.PatternID != nil {
+		return nil, ErrConverted
+	}
+
+	evidence, err := json.Marshal(map[string]any{
+		"source":      "tip",
+		"tipId":       t.ID,
+		"tip":         t.Body,
+		"documentIds": t.DocumentIDs,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var patternID int
+	err = tx.QueryRow(ctx, `
+		INSERT INTO pattern_findings (title, description, pattern_type, entity_ids, evidence, confidence, status, discovered_by)
+		VALUES ($1, $2, NULLIF($3, ''), $4, $5, $6, 'hypothesis', $7)
+		RETURNING id
+	`, p.Title, p.Description, p.Type, t.EntityIDs, evidence, p.Confidence, "tip:"+strconv.FormatInt(t.ID, 10)).Scan(&patternID)
+	if err != nil {
+		return nil, err
+	}
+
+	t, err = scan(tx.QueryRow(ctx, `
+		UPDATE tips
+		SET status = 'accepted', pattern_id = $2, reviewed_at = NOW(), reviewed_by = NULLIF($3, 0)
+		WHERE id = $1
+		RETURNING `+columns,
+		id, patternID, reviewerKey))
+	if err != nil {
+		return nil, err
+	}
+	return t, tx.Commit(ctx)
+}
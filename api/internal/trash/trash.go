@@ -0,0 +1,264 @@
+// Package trash soft-deletes and restores documents and entities. A
+// deleted row keeps its data, marked with deleted_at, and the public API
+// leaves it out until it is restored. Its document links are moved to
+// deleted_document_entities, which takes it out of co-occurrence, the
+// network and entity counts at once; restoring moves back the ones whose
+// other side isn't deleted too.
+package trash
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/subculture-collective/epstein-db/api/internal/recount"
+)
+
+// Kinds
+const (
+	KindDocument = "document"
+	KindEntity   = "entity"
+)
+
+// Kinds lists what can be deleted
+var Kinds = []string{KindDocument, KindEntity}
+
+var (
+	// ErrNotFound is returned for a missing row, or a row that isn't
+	// deleted when restoring
+	ErrNotFound = errors.New("trash: not found")
+
+	// ErrDeleted is returned when deleting a row already deleted
+	ErrDeleted = errors.New("trash: already deleted")
+)
+
+// Item is a deleted document or entity
+type Item struct {
+	Kind      string    `json:"kind" enum:"document,entity"`
+	ID        int       `json:"id"`
+	Name      string    `json:"name" doc:"The document's doc ID or the entity's canonical name"`
+	DeletedAt time.Time `json:"deletedAt"`
+	DeletedBy *int      `json:"deletedBy" doc:"API key that deleted it"`
+	Reason    *string   `json:"reason"`
+	Links     int       `json:"links" doc:"Document-entity links set aside with it"`
+}
+
+// table returns the table of a kind, its column in document_entities and
+// the column naming its rows
+func table(kind string) (table, linkColumn, name string) {
+	if kind == KindEntity {
+		return "entities", "entity_id", "canonical_name"
+	}
+	return "documents", "document_id", "doc_id"
+}
+
+// List returns one page of deleted rows of a kind, or of both kinds when
+// kind is empty, most recently deleted first
+func List(ctx context.Context, pool *pgxpool.Pool, kind string, limit, offset int) ([]Item, error) {
+	rows, err := pool.Query(ctx, `
+		SELECT kind, id, name, deleted_at, deleted_by, deleted_reason,
+			   (SELECT COUNT(*) FROM deleted_document_entities x
+			    WHERE x.deleted_with = t.kind AND x.deleted_with_id = t.id)
+		FROM (
+			SELECT 'document' AS kind, id, doc_id AS name, deleted_at, deleted_by, deleted_reason
+			FROM documents WHERE deleted_at IS NOT NULL AND $1 IN ('', 'document')
+			UNION ALL
+			SELECT 'entity', id, canonical_name, deleted_at, deleted_by, deleted_reason
+			FROM entities WHERE deleted_at IS NOT NULL AND $1 IN ('', 'entity')
+		) t
+		ORDER BY deleted_at DESC, kind, id
+		LIMIT $2 OFFSET $3
+	`, kind, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	items, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (Item, error) {
+		var it Item
+		err := row.Scan(&it.Kind, &it.ID, &it.Name, &it.DeletedAt, &it.DeletedBy, &it.Reason, &it.Links)
+		return it, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if items == nil {
+		items = []Item{}
+	}
+	return items, nil
+}
+
+// link is a moved document_entities row
+type link struct {
+	document, entity int
+}
+
+// linkColumns lists the columns of document_entities, which
+// deleted_document_entities starts with
+func linkColumns(ctx context.Context, tx pgx.Tx) (string, error) {
+	var columns string
+	err := tx.QueryRow(ctx, `
+		SELECT string_agg(quote_ident(column_name), ', ' ORDER BY ordinal_position)
+		FROM information_schema.columns
+		WHERE table_schema = current_schema() AND table_name = 'document_entities'
+	`).Scan(&columns)
+	return columns, err
+}
+
+func collectLinks(rows pgx.Rows) ([]link, error) {
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (link, error) {
+		var l link
+		return l, row.Scan(&l.document, &l.entity)
+	})
+}
+
+// Delete soft-deletes a document or entity for keyID and sets its links
+// aside. It returns ErrNotFound if there is no such row and ErrDeleted if
+// it is deleted already.
+func Delete(ctx context.Context, pool *pgxpool.Pool, kind string, id, keyID int, reason string) (*Item, error) {
+	tbl, column, name := table(kind)
+	item := &Item{Kind: kind, ID: id}
+	var links []link
+	err := pgx.BeginFunc(ctx, pool, func(tx pgx.Tx) error {
+		var deleted *time.Time
+		err := tx.QueryRow(ctx, `SELECT `+name+`, deleted_at FROM `+tbl+` WHERE id = $1 FOR UPDATE`, id).
+			Scan(&item.Name, &deleted)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if deleted != nil {
+			return ErrDeleted
+		}
+
+		err = tx.QueryRow(ctx, `
+			UPDATE `+tbl+` SET deleted_at = NOW(), deleted_by = NULLIF($2, 0), deleted_reason = NULLIF($3, '')
+			WHERE id = $1
+			RETURNING deleted_at, deleted_by, deleted_reason
+		`, id, keyID, reason).Scan(&item.DeletedAt, &item.DeletedBy, &item.Reason)
+		if err != nil {
+			return err
+		}
+
+		columns, err := linkColumns(ctx, tx)
+		if err != nil {
+			return err
+		}
+		rows, err := tx.Query(ctx, `
+			WITH moved AS (
+				DELETE FROM document_entities WHERE `+column+` = $1 RETURNING *
+			), kept AS (
+				INSERT INTO deleted_document_entities (`+columns+`, deleted_with, deleted_with_id)
+				SELECT `+columns+`, $2, $1 FROM moved
+			)
+			SELECT document_id, entity_id FROM moved
+		`, id, kind)
+		if err != nil {
+			return err
+		}
+		links, err = collectLinks(rows)
+		item.Links = len(links)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return item, recountLinked(ctx, pool, kind, id, links)
+}
+
+// Restore undeletes a document or entity and puts back its links, except
+// those to a row that is itself deleted, which stay aside with that row.
+// The item returned says when it had been deleted. It returns ErrNotFound
+// unless the row is deleted.
+func Restore(ctx context.Context, pool *pgxpool.Pool, kind string, id int) (*Item, error) {
+	tbl, _, name := table(kind)
+	otherKind := KindDocument
+	if kind == KindDocument {
+		otherKind = KindEntity
+	}
+	other, otherColumn, _ := table(otherKind)
+
+	item := &Item{Kind: kind, ID: id}
+	var links []link
+	err := pgx.BeginFunc(ctx, pool, func(tx pgx.Tx) error {
+		err := tx.QueryRow(ctx, `
+			SELECT `+name+`, deleted_at, deleted_by, deleted_reason FROM `+tbl+`
+			WHERE id = $1 AND deleted_at IS NOT NULL
+			FOR UPDATE
+		`, id).Scan(&item.Name, &item.DeletedAt, &item.DeletedBy, &item.Reason)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(ctx, `
+			UPDATE `+tbl+` SET deleted_at = NULL, deleted_by = NULL, deleted_reason = NULL WHERE id = $1
+		`, id)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(ctx, `
+			UPDATE deleted_document_entities x
+			SET deleted_with = $3, deleted_with_id = x.`+otherColumn+`
+			FROM `+other+` o
+			WHERE x.deleted_with = $2 AND x.deleted_with_id = $1
+			  AND o.id = x.`+otherColumn+` AND o.deleted_at IS NOT NULL
+		`, id, kind, otherKind)
+		if err != nil {
+			return err
+		}
+
+		columns, err := linkColumns(ctx, tx)
+		if err != nil {
+			return err
+		}
+		rows, err := tx.Query(ctx, `
+			WITH moved AS (
+				DELETE FROM deleted_document_entities
+				WHERE deleted_with = $2 AND deleted_with_id = $1
+				RETURNING *
+			), restored AS (
+				INSERT INTO document_entities (`+columns+`)
+				SELECT `+columns+` FROM moved
+				ON CONFLICT DO NOTHING
+			)
+			SELECT document_id, entity_id FROM moved
+		`, id, kind)
+		if err != nil {
+			return err
+		}
+		links, err = collectLinks(rows)
+		item.Links = len(links)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return item, recountLinked(ctx, pool, kind, id, links)
+}
+
+// recountLinked corrects the counts of the entities whose links moved and
+// of those sharing a document with a deleted or restored entity
+func recountLinked(ctx context.Context, pool *pgxpool.Pool, kind string, id int, links []link) error {
+	if len(links) == 0 {
+		return nil
+	}
+	var opts recount.Options
+	if kind == KindEntity {
+		opts.EntityIDs = []int{id}
+		for _, l := range links {
+			opts.DocumentIDs = append(opts.DocumentIDs, l.document)
+		}
+	} else {
+		for _, l := range links {
+			opts.EntityIDs = append(opts.EntityIDs, l.entity)
+		}
+	}
+	_, err := recount.Run(ctx, pool, opts)
+	return err
+}
@@ -0,0 +1,199 @@
+package trending
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/subculture-collective/epstein-db/api/internal/models"
+	"github.com/subculture-collective/epstein-db/api/internal/store"
+)
+
+// Size is how many items each list holds
+const Size = 10
+
+// Trending is everything the landing page shows
+type Trending struct {
+	MostViewed      []ViewedEntity          `json:"mostViewed" doc:"Entities read most over the last 7 days"`
+	RecentlyUpdated []UpdatedEntity         `json:"recentlyUpdated"`
+	NewestDocuments []NewDocument           `json:"newestDocuments"`
+	NewestPatterns  []models.PatternSummary `json:"newestPatterns" doc:"Newest pattern findings that haven't been rejected"`
+	GeneratedAt     time.Time               `json:"generatedAt"`
+}
+
+// ViewedEntity is an entity with its views over the window
+type ViewedEntity struct {
+	models.EntitySummary
+	Views int64 `json:"views"`
+}
+
+// UpdatedEntity is an entity with when it last changed
+type UpdatedEntity struct {
+	models.EntitySummary
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// NewDocument is a document with when it was added
+type NewDocument struct {
+	models.DocumentSummary
+	AddedAt time.Time `json:"addedAt"`
+}
+
+// Load reads every list as of now
+func Load(ctx context.Context, pool *pgxpool.Pool, now time.Time) (*Trending, error) {
+	t := &Trending{
+		MostViewed:      []ViewedEntity{},
+		RecentlyUpdated: []UpdatedEntity{},
+		NewestDocuments: []NewDocument{},
+		NewestPatterns:  []models.PatternSummary{},
+		GeneratedAt:     now.UTC().Truncate(time.Second),
+	}
+
+	rows, err := pool.Query(ctx, `
+		SELECT e.id, e.canonical_name, e.entity_type, e.layer, dist.distance, e.document_count, e.connection_count, v.views
+		FROM (
+			SELECT entity_id, SUM(views) AS views
+			FROM entity_views
+			WHERE day >= $1::date
+			  AND entity_id IN (SELECT id FROM entities WHERE deleted_at IS NULL AND NOT protected)
+			GROUP BY entity_id
+			ORDER BY views DESC
+			LIMIT $2
+		) v
+		JOIN entities e ON e.id = v.entity_id
+		LEFT JOIN entity_distances dist ON dist.entity_id = e.id
+		ORDER BY v.views DESC, e.id
+	`, now.Add(-Window).UTC().Format(time.DateOnly), Size)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var e ViewedEntity
+		if err := rows.Scan(&e.ID, &e.CanonicalName, &e.EntityType, &e.Layer, &e.DistanceToCore, &e.DocumentCount, &e.ConnectionCount, &e.Views); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		t.MostViewed = append(t.MostViewed, e)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = pool.Query(ctx, `
+		SELECT id, canonical_name, entity_type, layer, dist.distance, document_count, connection_count,
+			   COALESCE(updated_at, created_at, NOW())
+		FROM entities
+		LEFT JOIN entity_distances dist ON dist.entity_id = entities.id
+		WHERE deleted_at IS NULL AND NOT protected
+		ORDER BY updated_at DESC NULLS LAST, id DESC
+		LIMIT $1
+	`, Size)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var e UpdatedEntity
+		if err := rows.Scan(&e.ID, &e.CanonicalName, &e.EntityType, &e.Layer, &e.DistanceToCore, &e.DocumentCount, &e.ConnectionCount, &e.UpdatedAt); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		t.RecentlyUpdated = append(t.RecentlyUpdated, e)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	masker, err := store.LoadMasker(ctx, pool)
+	if err != nil {
+		return nil, err
+	}
+
+	// The result is cached for every caller, so summaries of restricted
+	// documents are always left out
+	rows, err = pool.Query(ctx, `
+		SELECT id, doc_id, dataset_id, document_type,
+			   CASE WHEN NOT dataset_restricted(dataset_id) THEN summary END,
+			   date_earliest::text, date_latest::text, dataset_restricted(dataset_id), sensitivity_labels(id),
+			   COALESCE(created_at, NOW())
+		FROM documents
+		WHERE deleted_at IS NULL
+		ORDER BY id DESC
+		LIMIT $1
+	`, Size)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var d NewDocument
+		if err := rows.Scan(&d.ID, &d.DocID, &d.DatasetID, &d.DocumentType, &d.Summary, &d.DateEarliest, &d.DateLatest, &d.Restricted, &d.Sensitivity, &d.AddedAt); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		masker.MaskPtr(d.Summary)
+		t.NewestDocuments = append(t.NewestDocuments, d)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = pool.Query(ctx, `
+		SELECT id, title, description, COALESCE(pattern_type, ''), confidence,
+			   COALESCE(status, 'hypothesis'), COALESCE(discovered_at, NOW())::text
+		FROM pattern_findings
+		WHERE status IS DISTINCT FROM 'rejected'
+		ORDER BY discovered_at DESC NULLS LAST, id DESC
+		LIMIT $1
+	`, Size)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var p models.PatternSummary
+		if err := rows.Scan(&p.ID, &p.Title, &p.Description, &p.PatternType, &p.Confidence, &p.Status, &p.DiscoveredAt); err != nil {
+			return nil, err
+		}
+		p.Description = masker.Mask(p.Description)
+		t.NewestPatterns = append(t.NewestPatterns, p)
+	}
+	return t, rows.Err()
+}
+
+// Cache holds the latest Trending for a while, so a busy landing page
+// costs a handful of queries a minute
+type Cache struct {
+	pool *pgxpool.Pool
+	ttl  time.Duration
+
+	mu      sync.Mutex
+	current *Trending
+	expires time.Time
+}
+
+// NewCache returns a cache that reloads after ttl
+func NewCache(pool *pgxpool.Pool, ttl time.Duration) *Cache {
+	return &Cache{pool: pool, ttl: ttl}
+}
+
+// Get returns the cached lists, loading them if they have expired.
+// Concurrent callers wait for one load.
+func (c *Cache) Get(ctx context.Context) (*Trending, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if c.current != nil && now.Before(c.expires) {
+		return c.current, nil
+	}
+	t, err := Load(ctx, c.pool, now)
+	if err != nil {
+		return nil, err
+	}
+	c.current, c.expires = t, now.Add(c.ttl)
+	return t, nil
+}
@@ -0,0 +1,100 @@
+// Package trending collects what the landing page shows: the entities read
+// most over the last week, the entities changed most recently, and the
+// newest documents and pattern findings.
+package trending
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Window is how far back views count toward the most-viewed list
+const Window = 7 * 24 * time.Hour
+
+// Counter tallies entity views in memory and adds them to entity_views in
+// batches, so reads never wait on a write
+type Counter struct {
+	mu     sync.Mutex
+	counts map[int]int64
+}
+
+// NewCounter returns an empty counter
+func NewCounter() *Counter {
+	return &Counter{counts: map[int]int64{}}
+}
+
+// Add counts one view of an entity
+func (c *Counter) Add(entityID int) {
+	c.mu.Lock()
+	c.counts[entityID]++
+	c.mu.Unlock()
+}
+
+// Flush adds the views counted so far to today's totals. On failure the
+// views are kept for the next flush.
+func (c *Counter) Flush(ctx context.Context, pool *pgxpool.Pool) error {
+	c.mu.Lock()
+	counts := c.counts
+	c.counts = map[int]int64{}
+	c.mu.Unlock()
+	if len(counts) == 0 {
+		return nil
+	}
+
+	day := time.Now().UTC().Format(time.DateOnly)
+	batch := &pgx.Batch{}
+	for id, n := range counts {
+		// Entities deleted since they were read are skipped
+		batch.Queue(`
+			INSERT INTO entity_views (entity_id, day, views)
+			SELECT id, $2, $3 FROM entities WHERE id = $1
+			ON CONFLICT (entity_id, day) DO UPDATE SET views = entity_views.views + EXCLUDED.views
+		`, id, day, n)
+	}
+	if err := pool.SendBatch(ctx, batch).Close(); err != nil {
+		c.mu.Lock()
+		for id, n := range counts {
+			c.counts[id] += n
+		}
+		c.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// Run flushes every interval until ctx is cancelled, then once more
+func (c *Counter) Run(ctx context.Context, pool *pgxpool.Pool, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			if err := c.Flush(flushCtx, pool); err != nil {
+				log.Printf("trending: flush views: %v", err)
+			}
+			cancel()
+			return
+		case <-ticker.C:
+			if err := c.Flush(ctx, pool); err != nil {
+				log.Printf("trending: flush views: %v", err)
+			}
+		}
+	}
+}
+
+// Prune deletes view totals of days before the trending window, returning
+// how many rows it removed
+func Prune(ctx context.Context, pool *pgxpool.Pool, now time.Time) (int64, error) {
+	tag, err := pool.Exec(ctx, `DELETE FROM entity_views WHERE day < $1::date`,
+		now.Add(-Window).UTC().Format(time.DateOnly))
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
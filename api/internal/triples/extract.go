@@ -0,0 +1,144 @@
+package triples
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/subculture-collective/epstein-db/api/internal/llm"
+)
+
+const systemPrompt = `You are an expert document analyst extracting relationships from legal documents, financial records, and correspondence related to the Jeffrey Epstein case.
+
+Extract subject-predicate-object relationships between the listed entities only. Every relationship must be stated in a single sentence of the document; quote that sentence exactly. Do not infer relationships that are not written down.
+
+Use short snake_case predicates such as flew_with, met_with, paid, employed, represented, introduced, visited, called, owns, member_of.`
+
+// maxChunkChars keeps each LLM request well inside the model's context
+const maxChunkChars = 12000
+
+type llmTriple struct {
+	Subject    string  `json:"subject"`
+	Predicate  string  `json:"predicate"`
+	Object     string  `json:"object"`
+	Sentence   string  `json:"sentence"`
+	Confidence float64 `json:"confidence"`
+}
+
+// ExtractWithLLM asks the model for triples between known document entities,
+// chunking long documents on sentence boundaries
+func ExtractWithLLM(ctx context.Context, client llm.Client, sentences []Sentence, entities []Entity) ([]Triple, error) {
+	if len(entities) < 2 {
+		return nil, nil
+	}
+
+	byName := make(map[string]int)
+	var roster strings.Builder
+	for _, e := range entities {
+		for _, name := range e.Names {
+			byName[strings.ToLower(name)] = e.ID
+		}
+		fmt.Fprintf(&roster, "- %s (%s)", e.Names[0], e.Type)
+		if len(e.Names) > 1 {
+			fmt.Fprintf(&roster, " aka %s", strings.Join(e.Names[1:], ", "))
+		}
+		roster.WriteString("\n")
+	}
+
+	var triples []Triple
+	for _, chunk := range chunkSentences(sentences, maxChunkChars) {
+		var text strings.Builder
+		for _, s := range chunk {
+			text.WriteString(s.Text)
+			text.WriteString("\n")
+		}
+
+		prompt := fmt.Sprintf(`Entities:
+%s
+<document>
+%s</document>
+
+Respond with a JSON object:
+{"triples": [{"subject": "entity name", "predicate": "snake_case_verb", "object": "entity name", "sentence": "exact sentence", "confidence": 0.0-1.0}]}
+
+Return ONLY valid JSON.`, roster.String(), text.String())
+
+		resp, err := client.Complete(ctx, llm.UserPrompt(systemPrompt, prompt, 4096))
+		if err != nil {
+			return triples, err
+		}
+
+		var parsed struct {
+			Triples []llmTriple `json:"triples"`
+		}
+		if err := llm.DecodeJSON(resp.Text, &parsed); err != nil {
+			return triples, err
+		}
+
+		for _, t := range parsed.Triples {
+			subjID, ok1 := byName[strings.ToLower(t.Subject)]
+			objID, ok2 := byName[strings.ToLower(t.Object)]
+			if !ok1 || !ok2 || subjID == objID || t.Predicate == "" {
+				continue
+			}
+
+			sentence, ok := locateSentence(chunk, t.Sentence)
+			if !ok {
+				// Unverifiable quote - drop rather than store a hallucination
+				continue
+			}
+
+			triples = append(triples, Triple{
+				SubjectID:  subjID,
+				Predicate:  normalizePredicate(t.Predicate),
+				ObjectID:   objID,
+				Sentence:   sentence,
+				Confidence: t.Confidence,
+				Method:     "llm",
+			})
+		}
+	}
+
+	return triples, nil
+}
+
+func chunkSentences(sentences []Sentence, maxChars int) [][]Sentence {
+	var chunks [][]Sentence
+	var current []Sentence
+	size := 0
+
+	for _, s := range sentences {
+		if size+len(s.Text) > maxChars && len(current) > 0 {
+			chunks = append(chunks, current)
+			current, size = nil, 0
+		}
+		current = append(current, s)
+		size += len(s.Text) + 1
+	}
+	if len(current) > 0 {
+		chunks = append(chunks, current)
+	}
+
+	return chunks
+}
+
+func locateSentence(sentences []Sentence, quote string) (Sentence, bool) {
+	quote = strings.ToLower(strings.Join(strings.Fields(quote), " "))
+	if quote == "" {
+		return Sentence{}, false
+	}
+
+	for _, s := range sentences {
+		text := strings.ToLower(s.Text)
+		if strings.Contains(text, quote) || strings.Contains(quote, text) {
+			return s, true
+		}
+	}
+
+	return Sentence{}, false
+}
+
+func normalizePredicate(p string) string {
+	p = strings.ToLower(strings.TrimSpace(p))
+	return strings.Join(strings.Fields(strings.ReplaceAll(p, "-", " ")), "_")
+}
@@ -0,0 +1,146 @@
+package triples
+
+import (
+	"regexp"
+	"sort"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/subculture-collective/epstein-db/api/internal/fold"
+)
+
+// Entity is a document entity that triples can refer to
+type Entity struct {
+	ID    int
+	Type  string
+	Names []string // canonical name followed by aliases
+}
+
+// Triple is an extracted subject-predicate-object relationship
+type Triple struct {
+	SubjectID  int
+	Predicate  string
+	ObjectID   int
+	Sentence   Sentence
+	Confidence float64
+	Method     string
+}
+
+type rule struct {
+	predicate string
+	pattern   *regexp.Regexp
+}
+
+// rules match the text between two entity mentions. Each must match the
+// whole gap so that long clauses don't produce spurious relationships.
+var rules = []rule{
+	{"flew_with", regexp.MustCompile(`(?i)^\s*(flew|traveled|travelled|flying)\s+(with|alongside)\s*$`)},
+	{"flew_to", regexp.MustCompile(`(?i)^\s*(flew|traveled|travelled)\s+(to|into)\s*$`)},
+	{"met_with", regexp.MustCompile(`(?i)^\s*(met|meets|meeting)(\s+with)?\s*$`)},
+	{"paid", regexp.MustCompile(`(?i)^\s*(paid|wired|transferred \S+ to|sent \S+ to)\s*$`)},
+	{"employed", regexp.MustCompile(`(?i)^\s*(employed|hired|retained)\s*$`)},
+	{"employed_by", regexp.MustCompile(`(?i)^\s*,?\s*(an? )?(employee|assistant|pilot|attorney|lawyer|counsel|staff member)\s+(of|for|to)\s*$`)},
+	{"works_for", regexp.MustCompile(`(?i)^\s*(works|worked|working)\s+(for|at)\s*$`)},
+	{"introduced", regexp.MustCompile(`(?i)^\s*introduced\s*$`)},
+	{"represented", regexp.MustCompile(`(?i)^\s*(represented|represents|represent)\s*$`)},
+	{"sued", regexp.MustCompile(`(?i)^\s*(sued|filed (a )?(suit|complaint) against)\s*$`)},
+	{"visited", regexp.MustCompile(`(?i)^\s*(visited|stayed at|went to)\s*$`)},
+	{"called", regexp.MustCompile(`(?i)^\s*(called|phoned|telephoned|emailed|wrote to)\s*$`)},
+	{"owns", regexp.MustCompile(`(?i)^\s*(owns|owned|purchased|bought)\s*$`)},
+	{"member_of", regexp.MustCompile(`(?i)^\s*,?\s*(a )?(member|director|officer|trustee|partner)\s+(of|at)\s*$`)},
+}
+
+type mention struct {
+	entityID int
+	start    int
+	end      int
+}
+
+// findMentions locates entity names inside a sentence, preferring the longest
+// name when mentions overlap
+func findMentions(sentence string, entities []Entity) []mention {
+	var found []mention
+	for _, e := range entities {
+		for _, name := range e.Names {
+			if len(name) < 3 {
+				continue
+			}
+			for offset := 0; ; {
+				start, end := fold.Index(sentence[offset:], name)
+				if start < 0 {
+					break
+				}
+				start, end = offset+start, offset+end
+				if wordBoundary(sentence, start, end) {
+					found = append(found, mention{entityID: e.ID, start: start, end: end})
+				}
+				offset = end
+			}
+		}
+	}
+
+	sort.Slice(found, func(i, j int) bool {
+		if found[i].start != found[j].start {
+			return found[i].start < found[j].start
+		}
+		return found[i].end > found[j].end
+	})
+
+	var mentions []mention
+	lastEnd := -1
+	for _, m := range found {
+		if m.start < lastEnd {
+			continue
+		}
+		mentions = append(mentions, m)
+		lastEnd = m.end
+	}
+
+	return mentions
+}
+
+func wordBoundary(s string, start, end int) bool {
+	isWord := func(r rune) bool {
+		return unicode.IsLetter(r) || unicode.IsDigit(r)
+	}
+	if r, _ := utf8.DecodeLastRuneInString(s[:start]); start > 0 && isWord(r) {
+		return false
+	}
+	if r, _ := utf8.DecodeRuneInString(s[end:]); end < len(s) && isWord(r) {
+		return false
+	}
+	return true
+}
+
+// ApplyRules extracts triples from adjacent entity mentions whose connecting
+// text matches one of the predicate patterns
+func ApplyRules(sentences []Sentence, entities []Entity) []Triple {
+	var triples []Triple
+
+	for _, s := range sentences {
+		mentions := findMentions(s.Text, entities)
+		for i := 0; i+1 < len(mentions); i++ {
+			subj, obj := mentions[i], mentions[i+1]
+			if subj.entityID == obj.entityID {
+				continue
+			}
+
+			gap := s.Text[subj.end:obj.start]
+			for _, r := range rules {
+				if r.pattern.MatchString(gap) {
+					triples = append(triples, Triple{
+						SubjectID:  subj.entityID,
+						Predicate:  r.predicate,
+						ObjectID:   obj.entityID,
+						Sentence:   s,
+						Confidence: 0.7,
+						Method:     "rule",
+					})
+					break
+				}
+			}
+		}
+	}
+
+	return triples
+}
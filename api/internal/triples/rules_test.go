@@ -0,0 +1,42 @@
+package triples
+
+import "testing"
+
+func TestApplyRules(t *testing.T) {
+	entities := []Entity{
+		{ID: 1, Names: []string{"Jane Roe"}},
+		{ID: 2, Names: []string{"John Doe", "Doe"}},
+		{ID: 3, Names: []string{"İstanbul"}},
+		{ID: 4, Names: []string{"Straße Holdings"}},
+	}
+	cases := []struct {
+		name, text string
+		want       []Triple
+	}{
+		{"plain", "Jane Roe met with John Doe.", []Triple{{SubjectID: 1, Predicate: "met_with", ObjectID: 2}}},
+		{"case", "JANE ROE met with john doe.", []Triple{{SubjectID: 1, Predicate: "met_with", ObjectID: 2}}},
+		{"longest name", "Jane Roe met John Doe.", []Triple{{SubjectID: 1, Predicate: "met_with", ObjectID: 2}}},
+		{"inside a word", "Jane Roe met Doering.", nil},
+		// Lowering these changes their length, which once shifted the gaps
+		// between mentions
+		{"dotted capital I before", "İİİİ: Jane Roe met with John Doe.", []Triple{{SubjectID: 1, Predicate: "met_with", ObjectID: 2}}},
+		{"capital sharp s before", "ẞẞ Jane Roe met with John Doe.", []Triple{{SubjectID: 1, Predicate: "met_with", ObjectID: 2}}},
+		{"dotted capital I in a name", "Jane Roe flew to İstanbul.", []Triple{{SubjectID: 1, Predicate: "flew_to", ObjectID: 3}}},
+		{"capital sharp s in a name", "STRAẞE HOLDINGS paid Jane Roe.", []Triple{{SubjectID: 4, Predicate: "paid", ObjectID: 1}}},
+		{"accented neighbour", "Jane Roe met Doeé.", nil},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := ApplyRules([]Sentence{{Text: tc.text}}, entities)
+			if len(got) != len(tc.want) {
+				t.Fatalf("ApplyRules(%q) = %+v, want %+v", tc.text, got, tc.want)
+			}
+			for i, w := range tc.want {
+				g := got[i]
+				if g.SubjectID != w.SubjectID || g.Predicate != w.Predicate || g.ObjectID != w.ObjectID {
+					t.Fatalf("ApplyRules(%q) = %+v, want %+v", tc.text, got, tc.want)
+				}
+			}
+		})
+	}
+}
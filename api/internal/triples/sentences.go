@@ -0,0 +1,85 @@
+package triples
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Sentence is a span of document text
+type Sentence struct {
+	Text  string
+	Start int
+	End   int
+}
+
+// common abbreviations that end in a period but don't end a sentence
+var abbreviations = map[string]bool{
+	"mr": true, "mrs": true, "ms": true, "dr": true, "jr": true, "sr": true,
+	"st": true, "inc": true, "co": true, "corp": true, "ltd": true, "vs": true,
+	"no": true, "esq": true, "u.s": true, "p.a": true,
+}
+
+// SplitSentences breaks text into sentences, keeping character offsets so
+// that every triple can point back into documents.full_text
+func SplitSentences(text string) []Sentence {
+	var sentences []Sentence
+
+	start := 0
+	for i := 0; i < len(text); i++ {
+		ch := text[i]
+
+		boundary := false
+		switch ch {
+		case '.', '?', '!':
+			if i+1 == len(text) || isSpace(text[i+1]) {
+				boundary = ch != '.' || !isAbbreviation(text[start:i])
+			}
+		case '\n':
+			// Blank lines separate paragraphs and form headers in OCR output
+			boundary = i+1 < len(text) && text[i+1] == '\n'
+		}
+
+		if boundary {
+			sentences = appendSentence(sentences, text, start, i+1)
+			start = i + 1
+		}
+	}
+
+	return appendSentence(sentences, text, start, len(text))
+}
+
+func appendSentence(sentences []Sentence, text string, start, end int) []Sentence {
+	for start < end && isSpace(text[start]) {
+		start++
+	}
+	for end > start && isSpace(text[end-1]) {
+		end--
+	}
+	if end-start < 3 {
+		return sentences
+	}
+
+	return append(sentences, Sentence{
+		Text:  strings.Join(strings.Fields(text[start:end]), " "),
+		Start: start,
+		End:   end,
+	})
+}
+
+func isAbbreviation(before string) bool {
+	idx := strings.LastIndexFunc(before, func(r rune) bool {
+		return unicode.IsSpace(r)
+	})
+	word := strings.ToLower(before[idx+1:])
+
+	// Single initials such as "J." in "J. Epstein"
+	if len(word) == 1 {
+		return true
+	}
+
+	return abbreviations[word]
+}
+
+func isSpace(b byte) bool {
+	return b == ' ' || b == '\n' || b == '\t' || b == '\r'
+}
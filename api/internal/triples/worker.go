@@ -0,0 +1,208 @@
+package triples
+
+import (
+	"context"
+	"log"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/subculture-collective/epstein-db/api/internal/llm"
+)
+
+// Config controls a triple extraction run
+type Config struct {
+	BatchSize int
+	UseRules  bool
+	UseLLM    bool
+}
+
+// DefaultConfig returns the settings used by the worker command
+func DefaultConfig() Config {
+	return Config{
+		BatchSize: 50,
+		UseRules:  true,
+		UseLLM:    true,
+	}
+}
+
+// Stats summarizes a worker run
+type Stats struct {
+	Documents int
+	Triples   int
+	Failed    int
+}
+
+// Worker extracts triples from documents that haven't been processed yet
+type Worker struct {
+	pool   *pgxpool.Pool
+	client llm.Client
+	cfg    Config
+}
+
+// NewWorker creates a triple extraction worker. client may be nil when only
+// rule-based extraction is enabled.
+func NewWorker(pool *pgxpool.Pool, client llm.Client, cfg Config) *Worker {
+	if client == nil {
+		cfg.UseLLM = false
+	}
+	return &Worker{pool: pool, client: client, cfg: cfg}
+}
+
+// Run processes one batch of documents
+func (w *Worker) Run(ctx context.Context) (Stats, error) {
+	var stats Stats
+
+	rows, err := w.pool.Query(ctx, `
+		SELECT id, full_text
+		FROM documents
+		WHERE triples_extracted_at IS NULL AND full_text IS NOT NULL
+		ORDER BY id
+		LIMIT $1
+	`, w.cfg.BatchSize)
+	if err != nil {
+		return stats, err
+	}
+
+	type pending struct {
+		id   int
+		text string
+	}
+	var docs []pending
+	for rows.Next() {
+		var d pending
+		if err := rows.Scan(&d.id, &d.text); err != nil {
+			rows.Close()
+			return stats, err
+		}
+		docs = append(docs, d)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return stats, err
+	}
+
+	for _, d := range docs {
+		if ctx.Err() != nil {
+			return stats, ctx.Err()
+		}
+
+		n, err := w.processDocument(ctx, d.id, d.text)
+		if err != nil {
+			log.Printf("triples: document %d: %v", d.id, err)
+			stats.Failed++
+			continue
+		}
+		stats.Documents++
+		stats.Triples += n
+	}
+
+	return stats, nil
+}
+
+func (w *Worker) processDocument(ctx context.Context, docID int, text string) (int, error) {
+	entities, err := w.documentEntities(ctx, docID)
+	if err != nil {
+		return 0, err
+	}
+
+	sentences := SplitSentences(text)
+
+	var triples []Triple
+	if w.cfg.UseRules {
+		triples = append(triples, ApplyRules(sentences, entities)...)
+	}
+	if w.cfg.UseLLM {
+		extracted, err := ExtractWithLLM(ctx, w.client, sentences, entities)
+		if err != nil {
+			return 0, err
+		}
+		triples = append(triples, extracted...)
+	}
+
+	triples = dedupe(triples)
+	if err := w.store(ctx, docID, triples); err != nil {
+		return 0, err
+	}
+
+	return len(triples), nil
+}
+
+func (w *Worker) documentEntities(ctx context.Context, docID int) ([]Entity, error) {
+	rows, err := w.pool.Query(ctx, `
+		SELECT e.id, e.entity_type::text, e.canonical_name,
+			   COALESCE(ARRAY(SELECT jsonb_array_elements_text(e.aliases)), '{}')
+		FROM entities e
+		JOIN document_entities de ON de.entity_id = e.id
+		WHERE de.document_id = $1
+		  AND e.entity_type IN ('person', 'organization', 'location')
+	`, docID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entities []Entity
+	for rows.Next() {
+		var e Entity
+		var name string
+		var aliases []string
+		if err := rows.Scan(&e.ID, &e.Type, &name, &aliases); err != nil {
+			return nil, err
+		}
+		e.Names = append([]string{name}, aliases...)
+		entities = append(entities, e)
+	}
+
+	return entities, rows.Err()
+}
+
+// dedupe drops repeated relationships from the same sentence, keeping the
+// first (rule-based triples come first)
+func dedupe(triples []Triple) []Triple {
+	type key struct {
+		subj, obj, start int
+		pred             string
+	}
+	seen := make(map[key]bool)
+
+	var out []Triple
+	for _, t := range triples {
+		k := key{t.SubjectID, t.ObjectID, t.Sentence.Start, t.Predicate}
+		if seen[k] {
+			continue
+		}
+		seen[k] = true
+		out = append(out, t)
+	}
+
+	return out
+}
+
+func (w *Worker) store(ctx context.Context, docID int, triples []Triple) error {
+	return pgx.BeginFunc(ctx, w.pool, func(tx pgx.Tx) error {
+		// Re-runs replace earlier machine-extracted triples for this document
+		if _, err := tx.Exec(ctx, `
+			DELETE FROM triples WHERE document_id = $1 AND extraction_method IS NOT NULL
+		`, docID); err != nil {
+			return err
+		}
+
+		for i, t := range triples {
+			if _, err := tx.Exec(ctx, `
+				INSERT INTO triples
+					(document_id, subject_id, predicate, object_id, confidence, sequence_order,
+					 sentence, sentence_start, sentence_end, extraction_method)
+				VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+			`, docID, t.SubjectID, t.Predicate, t.ObjectID, t.Confidence, i,
+				t.Sentence.Text, t.Sentence.Start, t.Sentence.End, t.Method); err != nil {
+				return err
+			}
+		}
+
+		_, err := tx.Exec(ctx, `
+			UPDATE documents SET triples_extracted_at = NOW() WHERE id = $1
+		`, docID)
+		return err
+	})
+}
@@ -0,0 +1,132 @@
+// Package upload guards request bodies: it caps their size, server-wide and
+// per route, checks their content type, and checks uploaded files by their
+// content rather than their names. The server streams bodies instead of
+// buffering them, and multipart files past a few kilobytes are spooled to
+// temporary files (under TMPDIR) as the form is read.
+package upload
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/subculture-collective/epstein-db/api/internal/apierr"
+)
+
+// sniffLen is how much of a file is read to tell its type
+const sniffLen = 512
+
+// Route overrides the body limit for the paths matching Pattern, in the
+// syntax of path.Match, e.g. /api/admin/entities/*/media
+type Route struct {
+	Pattern string
+	Max     int64
+}
+
+// Limit rejects request bodies over max bytes, or over the Max of the
+// first route matching the path, with 413. Bodies must declare their
+// length: a streamed body can't be cut short once a handler is reading it,
+// so chunked bodies are refused with 411.
+func Limit(max int64, routes ...Route) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		length := c.Request().Header.ContentLength()
+		if length == -1 {
+			return apierr.New(fiber.StatusLengthRequired, apierr.CodeBadRequest,
+				"request bodies must have a Content-Length")
+		}
+
+		limit := max
+		for _, r := range routes {
+			if ok, _ := path.Match(r.Pattern, c.Path()); ok {
+				limit = r.Max
+				break
+			}
+		}
+		if int64(length) > limit {
+			return TooLarge(limit)
+		}
+		return c.Next()
+	}
+}
+
+// Accept rejects requests with a body whose content type isn't one of
+// types, with 415
+func Accept(types ...string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if c.Request().Header.ContentLength() == 0 {
+			return c.Next()
+		}
+		mediaType, _, err := mime.ParseMediaType(c.Get(fiber.HeaderContentType))
+		if err != nil || !slices.Contains(types, mediaType) {
+			return apierr.New(fiber.StatusUnsupportedMediaType, apierr.CodeUnsupported,
+				"Content-Type must be "+strings.Join(types, " or "))
+		}
+		return c.Next()
+	}
+}
+
+// TooLarge is a body or file over limit bytes
+func TooLarge(limit int64) *apierr.Error {
+	return apierr.New(fiber.StatusRequestEntityTooLarge, apierr.CodeTooLarge,
+		"request body must be at most "+Size(limit))
+}
+
+// File returns the file uploaded as field with its content type, checking
+// that it is at most max bytes and that its content, whatever its name
+// says, is one of types
+func File(c *fiber.Ctx, field string, max int64, types ...string) (*multipart.FileHeader, string, error) {
+	header, err := c.FormFile(field)
+	if err != nil {
+		return nil, "", apierr.InvalidParam(field, "is required")
+	}
+	if header.Size > max {
+		return nil, "", apierr.InvalidParam(field, "must be at most "+Size(max))
+	}
+
+	f, err := header.Open()
+	if err != nil {
+		return nil, "", err
+	}
+	defer f.Close()
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, "", err
+	}
+
+	contentType := Sniff(head[:n])
+	if !slices.Contains(types, contentType) {
+		return nil, "", apierr.New(fiber.StatusUnsupportedMediaType, apierr.CodeUnsupported,
+			field+" must be "+strings.Join(types, " or "))
+	}
+	return header, contentType, nil
+}
+
+// Sniff returns the media type of a file from its first bytes, as
+// http.DetectContentType does, but without parameters and recognising TIFF
+func Sniff(head []byte) string {
+	if bytes.HasPrefix(head, []byte("II*\x00")) || bytes.HasPrefix(head, []byte("MM\x00*")) {
+		return "image/tiff"
+	}
+	mediaType, _, _ := strings.Cut(http.DetectContentType(head), ";")
+	return mediaType
+}
+
+// Size formats a byte count for messages, e.g. 4 MB
+func Size(n int64) string {
+	switch {
+	case n >= 1<<20 && n%(1<<20) == 0:
+		return strconv.FormatInt(n>>20, 10) + " MB"
+	case n >= 1<<10 && n%(1<<10) == 0:
+		return strconv.FormatInt(n>>10, 10) + " KB"
+	}
+	return strconv.FormatInt(n, 10) + " bytes"
+}
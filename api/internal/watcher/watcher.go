@@ -0,0 +1,322 @@
+package watcher
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/subculture-collective/epstein-db/api/internal/embeddings"
+	"github.com/subculture-collective/epstein-db/api/internal/events"
+	"github.com/subculture-collective/epstein-db/api/internal/ingest"
+	"github.com/subculture-collective/epstein-db/api/internal/jobs"
+	"github.com/subculture-collective/epstein-db/api/internal/recount"
+	"github.com/subculture-collective/epstein-db/api/internal/summarize"
+)
+
+// Config selects where releases are discovered and what runs after ingestion
+type Config struct {
+	Dir         string        // directory of release .txt files
+	ManifestURL string        // JSON manifest of releases
+	Interval    time.Duration // how often to look for new releases
+	PostIngest  []string      // shell commands run with DATASET_ID set, e.g. NER
+	WebhookURL  string        // notified when a dataset becomes queryable
+}
+
+// ConfigFromEnv reads WATCH_DIR, WATCH_MANIFEST_URL, WATCH_INTERVAL,
+// WATCH_POST_INGEST (semicolon-separated) and WATCH_WEBHOOK_URL
+func ConfigFromEnv() Config {
+	cfg := Config{
+		Dir:         os.Getenv("WATCH_DIR"),
+		ManifestURL: os.Getenv("WATCH_MANIFEST_URL"),
+		Interval:    15 * time.Minute,
+		WebhookURL:  os.Getenv("WATCH_WEBHOOK_URL"),
+	}
+	if d, err := time.ParseDuration(os.Getenv("WATCH_INTERVAL")); err == nil && d > 0 {
+		cfg.Interval = d
+	}
+	for _, cmd := range strings.Split(os.Getenv("WATCH_POST_INGEST"), ";") {
+		if cmd = strings.TrimSpace(cmd); cmd != "" {
+			cfg.PostIngest = append(cfg.PostIngest, cmd)
+		}
+	}
+	return cfg
+}
+
+// Release is a file that can be ingested as a dataset
+type Release struct {
+	Name      string `json:"name"`
+	URL       string `json:"url"`
+	DatasetID int    `json:"datasetId"` // optional; the next free ID is used otherwise
+	path      string
+}
+
+func (r Release) source() string {
+	if r.path != "" {
+		return r.path
+	}
+	return r.URL
+}
+
+// Watcher discovers new releases and ingests them end to end
+type Watcher struct {
+	pool *pgxpool.Pool
+	cfg  Config
+	http *http.Client
+}
+
+// New creates a release watcher
+func New(pool *pgxpool.Pool, cfg Config) *Watcher {
+	return &Watcher{
+		pool: pool,
+		cfg:  cfg,
+		http: &http.Client{Timeout: 30 * time.Minute},
+	}
+}
+
+// Run scans for releases every interval until ctx is cancelled
+func (w *Watcher) Run(ctx context.Context) error {
+	if w.cfg.Dir == "" && w.cfg.ManifestURL == "" {
+		return errors.New("watcher: set WATCH_DIR or WATCH_MANIFEST_URL")
+	}
+
+	for {
+		if err := w.Scan(ctx); err != nil {
+			log.Printf("watcher: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(w.cfg.Interval):
+		}
+	}
+}
+
+// Scan ingests every release that hasn't been registered yet
+func (w *Watcher) Scan(ctx context.Context) error {
+	releases, err := w.discover(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, r := range releases {
+		var exists bool
+		err := w.pool.QueryRow(ctx, `
+			SELECT EXISTS (SELECT 1 FROM datasets WHERE source = $1)
+		`, r.source()).Scan(&exists)
+		if err != nil {
+			return err
+		}
+		if exists {
+			continue
+		}
+
+		if err := w.process(ctx, r); err != nil {
+			log.Printf("watcher: %s: %v", r.source(), err)
+		}
+	}
+
+	return nil
+}
+
+func (w *Watcher) discover(ctx context.Context) ([]Release, error) {
+	var releases []Release
+
+	if w.cfg.Dir != "" {
+		paths, err := filepath.Glob(filepath.Join(w.cfg.Dir, "*.txt"))
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(paths)
+		for _, p := range paths {
+			releases = append(releases, Release{
+				Name: strings.TrimSuffix(filepath.Base(p), ".txt"),
+				path: p,
+			})
+		}
+	}
+
+	if w.cfg.ManifestURL != "" {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, w.cfg.ManifestURL, nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := w.http.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("manifest: status %d", resp.StatusCode)
+		}
+
+		var manifest []Release
+		if err := json.NewDecoder(resp.Body).Decode(&manifest); err != nil {
+			return nil, fmt.Errorf("manifest: %w", err)
+		}
+		releases = append(releases, manifest...)
+	}
+
+	return releases, nil
+}
+
+func (w *Watcher) process(ctx context.Context, r Release) error {
+	datasetID, err := w.register(ctx, r)
+	if err != nil {
+		return err
+	}
+	log.Printf("watcher: registered dataset %d from %s", datasetID, r.source())
+
+	fail := func(err error) error {
+		w.pool.Exec(context.WithoutCancel(ctx), `
+			UPDATE datasets SET status = 'failed', error_message = $2 WHERE id = $1
+		`, datasetID, err.Error())
+		return err
+	}
+
+	body, err := w.open(ctx, r)
+	if err != nil {
+		return fail(err)
+	}
+	stats, err := ingest.Load(ctx, w.pool, datasetID, r.source(), body)
+	body.Close()
+	if err != nil {
+		return fail(err)
+	}
+	log.Printf("watcher: dataset %d: loaded %d documents", datasetID, stats.Documents)
+
+	if _, err := w.pool.Exec(ctx, `
+		UPDATE datasets SET status = 'processing', document_count = $2 WHERE id = $1
+	`, datasetID, stats.Documents); err != nil {
+		return fail(err)
+	}
+
+	// Entity extraction and linking run in the extraction pipeline
+	for _, command := range w.cfg.PostIngest {
+		cmd := exec.CommandContext(ctx, "sh", "-c", command)
+		cmd.Env = append(os.Environ(), fmt.Sprintf("DATASET_ID=%d", datasetID))
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			return fail(fmt.Errorf("%s: %w", command, err))
+		}
+	}
+
+	if _, err := recount.Run(ctx, w.pool, recount.Options{DocumentIDs: stats.IDs}); err != nil {
+		return fail(err)
+	}
+
+	queue := jobs.NewQueue(w.pool)
+	if _, err := queue.Enqueue(ctx, summarize.JobKind, summarize.Params{DatasetID: datasetID}); err != nil {
+		return fail(err)
+	}
+	if _, err := queue.Enqueue(ctx, embeddings.JobKind, embeddings.Params{DatasetID: datasetID}); err != nil {
+		return fail(err)
+	}
+
+	if _, err := w.pool.Exec(ctx, `
+		UPDATE datasets SET status = 'ready', ready_at = NOW() WHERE id = $1
+	`, datasetID); err != nil {
+		return fail(err)
+	}
+
+	w.announce(ctx, datasetID, r.Name, stats.Documents)
+	return nil
+}
+
+func (w *Watcher) register(ctx context.Context, r Release) (int, error) {
+	var id int
+	err := pgx.BeginFunc(ctx, w.pool, func(tx pgx.Tx) error {
+		// Serialize ID allocation between concurrent watchers
+		if _, err := tx.Exec(ctx, `LOCK TABLE datasets IN EXCLUSIVE MODE`); err != nil {
+			return err
+		}
+
+		id = r.DatasetID
+		if id == 0 {
+			err := tx.QueryRow(ctx, `
+				SELECT GREATEST(
+					(SELECT COALESCE(MAX(id), 0) FROM datasets),
+					(SELECT COALESCE(MAX(dataset_id), 0) FROM documents)
+				) + 1
+			`).Scan(&id)
+			if err != nil {
+				return err
+			}
+		}
+
+		_, err := tx.Exec(ctx, `
+			INSERT INTO datasets (id, name, source, status) VALUES ($1, $2, $3, 'ingesting')
+		`, id, r.Name, r.source())
+		return err
+	})
+	return id, err
+}
+
+func (w *Watcher) open(ctx context.Context, r Release) (io.ReadCloser, error) {
+	if r.path != "" {
+		return os.Open(r.path)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := w.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("download: status %d", resp.StatusCode)
+	}
+	return resp.Body, nil
+}
+
+func (w *Watcher) announce(ctx context.Context, datasetID int, name string, documents int) {
+	data := map[string]any{
+		"datasetId": datasetID,
+		"name":      name,
+		"documents": documents,
+	}
+
+	if err := events.Publish(ctx, w.pool, events.DatasetReady, data); err != nil {
+		log.Printf("watcher: publish: %v", err)
+	}
+
+	if w.cfg.WebhookURL == "" {
+		return
+	}
+
+	payload, _ := json.Marshal(events.Event{Type: events.DatasetReady, Data: data, Time: time.Now().UTC()})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.cfg.WebhookURL, bytes.NewReader(payload))
+	if err != nil {
+		log.Printf("watcher: webhook: %v", err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := w.http.Do(req)
+	if err != nil {
+		log.Printf("watcher: webhook: %v", err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		log.Printf("watcher: webhook: status %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,210 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/subculture-collective/epstein-db/api/internal/changes"
+)
+
+// changedRow identifies a row in the change log
+type changedRow struct {
+	table string
+	id    int64
+}
+
+// Dispatcher turns changes into events and queues a delivery of each to
+// every webhook subscribed to it. It is a changes.Publisher; run it with
+// changes.Follow.
+type Dispatcher struct {
+	pool *pgxpool.Pool
+}
+
+// NewDispatcher creates a dispatcher
+func NewDispatcher(pool *pgxpool.Pool) *Dispatcher {
+	return &Dispatcher{pool: pool}
+}
+
+// Publish queues the events in a batch of changes. Entity updates are
+// announced once per batch, listing the entities; documents, patterns and
+// confirmed matches once each, ever, however often the batch is replayed.
+func (d *Dispatcher) Publish(ctx context.Context, list []changes.Change) error {
+	var entities, documents, patterns, matches []int64
+	at := map[changedRow]time.Time{}
+	for _, c := range list {
+		at[changedRow{c.Table, c.RowID}] = c.ChangedAt
+		switch {
+		case c.Table == "entities" && c.Op == changes.OpUpdate:
+			entities = append(entities, c.RowID)
+		case c.Table == "documents" && c.Op == changes.OpInsert:
+			documents = append(documents, c.RowID)
+		case c.Table == "pattern_findings" && c.Op == changes.OpInsert:
+			patterns = append(patterns, c.RowID)
+		case c.Table == "entity_crossref_matches" && c.Op != changes.OpDelete:
+			matches = append(matches, c.RowID)
+		}
+	}
+
+	tx, err := d.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if len(entities) > 0 {
+		slices.Sort(entities)
+		entities = slices.Compact(entities)
+		last := list[len(list)-1].ChangedAt
+		err := enqueue(ctx, tx, EntityUpdated, map[string]any{"ids": entities, "count": len(entities)}, "", nil, last)
+		if err != nil {
+			return err
+		}
+	}
+	if err := d.documents(ctx, tx, documents, at); err != nil {
+		return err
+	}
+	if err := d.patterns(ctx, tx, patterns, at); err != nil {
+		return err
+	}
+	if err := d.matches(ctx, tx, matches, at); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func (d *Dispatcher) documents(ctx context.Context, tx pgx.Tx, ids []int64, at map[changedRow]time.Time) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	rows, err := tx.Query(ctx, `
+		SELECT id, doc_id, dataset_id FROM documents WHERE id = ANY($1) ORDER BY id
+	`, ids)
+	if err != nil {
+		return err
+	}
+	type document struct {
+		ID        int64  `json:"id"`
+		DocID     string `json:"docId"`
+		DatasetID int    `json:"datasetId"`
+	}
+	docs, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (document, error) {
+		var doc document
+		err := row.Scan(&doc.ID, &doc.DocID, &doc.DatasetID)
+		return doc, err
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, doc := range docs {
+		if err := enqueue(ctx, tx, DocumentCreated, doc, dedupe("document", doc.ID), &doc.DatasetID, at[changedRow{"documents", doc.ID}]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *Dispatcher) patterns(ctx context.Context, tx pgx.Tx, ids []int64, at map[changedRow]time.Time) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	rows, err := tx.Query(ctx, `
+		SELECT id, title, pattern_type, confidence FROM pattern_findings WHERE id = ANY($1) ORDER BY id
+	`, ids)
+	if err != nil {
+		return err
+	}
+	type pattern struct {
+		ID          int64    `json:"id"`
+		Title       string   `json:"title"`
+		PatternType *string  `json:"patternType"`
+		Confidence  *float64 `json:"confidence"`
+	}
+	found, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (pattern, error) {
+		var p pattern
+		err := row.Scan(&p.ID, &p.Title, &p.PatternType, &p.Confidence)
+		return p, err
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, p := range found {
+		if err := enqueue(ctx, tx, PatternCreated, p, dedupe("pattern", p.ID), nil, at[changedRow{"pattern_findings", p.ID}]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// matches announces the changed matches that are now confirmed: verified
+// and not marked false positives
+func (d *Dispatcher) matches(ctx context.Context, tx pgx.Tx, ids []int64, at map[changedRow]time.Time) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	rows, err := tx.Query(ctx, `
+		SELECT m.id, m.entity_id, e.canonical_name, m.source::text, m.source_id, m.match_score
+		FROM entity_crossref_matches m
+		JOIN entities e ON e.id = m.entity_id
+		WHERE m.id = ANY($1) AND m.verified AND NOT COALESCE(m.false_positive, FALSE)
+		ORDER BY m.id
+	`, ids)
+	if err != nil {
+		return err
+	}
+	type match struct {
+		ID         int64   `json:"id"`
+		EntityID   int64   `json:"entityId"`
+		EntityName string  `json:"entityName"`
+		Source     string  `json:"source"`
+		SourceID   int64   `json:"sourceId"`
+		Score      float64 `json:"score"`
+	}
+	confirmed, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (match, error) {
+		var m match
+		err := row.Scan(&m.ID, &m.EntityID, &m.EntityName, &m.Source, &m.SourceID, &m.Score)
+		return m, err
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, m := range confirmed {
+		if err := enqueue(ctx, tx, CrossrefConfirmed, m, dedupe("crossref", m.ID), nil, at[changedRow{"entity_crossref_matches", m.ID}]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// enqueue queues an event for every webhook subscribed to it that existed
+// when the change was made, so new webhooks aren't sent the past. A dataset
+// narrows document events to webhooks for that dataset or for all of them.
+// Events with a dedupe key are queued at most once per webhook.
+func enqueue(ctx context.Context, tx pgx.Tx, eventType string, data any, dedupeKey string, datasetID *int, at time.Time) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx, `
+		INSERT INTO webhook_deliveries (webhook_id, event_type, data, dedupe_key)
+		SELECT id, $1, $2, NULLIF($3, '')
+		FROM webhooks
+		WHERE $1 = ANY(events)
+		  AND ($4::integer IS NULL OR dataset_id IS NULL OR dataset_id = $4)
+		  AND created_at <= $5
+		ON CONFLICT (webhook_id, dedupe_key) WHERE dedupe_key IS NOT NULL DO NOTHING
+	`, eventType, payload, dedupeKey, datasetID, at)
+	return err
+}
+
+func dedupe(kind string, id int64) string {
+	return kind + ":" + strconv.FormatInt(id, 10)
+}
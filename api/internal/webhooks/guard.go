@@ -0,0 +1,50 @@
+package webhooks
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"strings"
+	"syscall"
+)
+
+// ErrPrivateAddress is returned for a webhook URL that is, or resolves to,
+// an address on the server's own host or networks. Webhook URLs come from
+// API key holders, who mustn't be able to make the server post to its
+// database, metadata service or admin listener.
+var ErrPrivateAddress = errors.New("webhook address is not public")
+
+// Public reports whether ip may receive webhooks: not loopback, private,
+// link-local, multicast or unspecified
+func Public(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	return ip.IsValid() && !ip.IsLoopback() && !ip.IsPrivate() && !ip.IsUnspecified() &&
+		!ip.IsLinkLocalUnicast() && !ip.IsLinkLocalMulticast() && !ip.IsInterfaceLocalMulticast() &&
+		!ip.IsMulticast()
+}
+
+// PublicHost reports whether host, the host of a webhook URL, may be public.
+// Names are only resolved when a delivery is sent, since what they resolve
+// to can change; here only addresses and localhost are ruled out.
+func PublicHost(host string) bool {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return false
+	}
+	ip, err := netip.ParseAddr(host)
+	return err != nil || Public(ip)
+}
+
+// dialControl refuses connections to addresses that aren't public. It runs
+// after the name is resolved, for each address tried, so a name can't be
+// pointed at a private address once the webhook has been created.
+func dialControl(network, address string, _ syscall.RawConn) error {
+	addr, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrPrivateAddress, address)
+	}
+	if !Public(addr.Addr()) {
+		return fmt.Errorf("%w: %s", ErrPrivateAddress, addr.Addr())
+	}
+	return nil
+}
@@ -0,0 +1,245 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/subculture-collective/epstein-db/api/internal/events"
+)
+
+// Request headers
+const (
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderDelivery  = "X-Webhook-Delivery"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+// maxError caps the error kept when a delivery fails
+const maxError = 500
+
+// Config controls delivery
+type Config struct {
+	MaxAttempts int           // attempts before a delivery is marked failed
+	Backoff     time.Duration // wait after the first failure, doubled after each
+	MaxBackoff  time.Duration
+	Timeout     time.Duration // per request
+	Batch       int           // deliveries claimed at a time
+}
+
+// DefaultConfig retries for about a day before giving up
+func DefaultConfig() Config {
+	return Config{
+		MaxAttempts: 14,
+		Backoff:     30 * time.Second,
+		MaxBackoff:  6 * time.Hour,
+		Timeout:     10 * time.Second,
+		Batch:       50,
+	}
+}
+
+// Sign returns the signature of a request: the hex HMAC-SHA256, keyed by
+// the webhook's secret, of the timestamp header, a period and the body.
+// Receivers should compute the same and compare in constant time, and
+// reject old timestamps to stop replays.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Sender posts queued deliveries
+type Sender struct {
+	pool *pgxpool.Pool
+	http *http.Client
+	cfg  Config
+}
+
+// NewSender creates a sender. Redirects are not followed: a webhook URL
+// must answer itself. Deliveries are only sent to public addresses, and
+// never through a proxy, which would hide where they go.
+func NewSender(pool *pgxpool.Pool, cfg Config) *Sender {
+	return &Sender{
+		pool: pool,
+		http: &http.Client{
+			Timeout: cfg.Timeout,
+			Transport: &http.Transport{
+				DialContext:         (&net.Dialer{Timeout: cfg.Timeout, Control: dialControl}).DialContext,
+				TLSHandshakeTimeout: cfg.Timeout,
+				MaxIdleConnsPerHost: 2,
+				IdleConnTimeout:     90 * time.Second,
+			},
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		cfg: cfg,
+	}
+}
+
+// Run sends due deliveries every interval until ctx is done
+func (s *Sender) Run(ctx context.Context, interval time.Duration) error {
+	for {
+		n, err := s.SendDue(ctx)
+		if err != nil {
+			log.Printf("webhooks: %v", err)
+		}
+		// A full batch means there is probably more waiting
+		if err == nil && n == s.cfg.Batch {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(interval):
+		}
+	}
+}
+
+// due is a claimed delivery with where to send it
+type due struct {
+	id        int64
+	event     string
+	data      json.RawMessage
+	attempts  int
+	createdAt time.Time
+	url       string
+	secret    string
+}
+
+// SendDue attempts one batch of due deliveries and returns how many
+func (s *Sender) SendDue(ctx context.Context) (int, error) {
+	// Claim the batch by pushing its next attempt out past the time it can
+	// take to send, so that other senders leave it alone
+	lease := time.Duration(s.cfg.Batch) * s.cfg.Timeout
+	rows, err := s.pool.Query(ctx, `
+		UPDATE webhook_deliveries d
+		SET next_attempt_at = NOW() + $2 * INTERVAL '1 millisecond'
+		FROM webhooks w
+		WHERE w.id = d.webhook_id AND d.id IN (
+			SELECT id FROM webhook_deliveries
+			WHERE status = 'pending' AND next_attempt_at <= NOW()
+			ORDER BY next_attempt_at
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING d.id, d.event_type, d.data, d.attempts, d.created_at, w.url, w.secret
+	`, s.cfg.Batch, lease.Milliseconds())
+	if err != nil {
+		return 0, err
+	}
+	batch, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (due, error) {
+		var d due
+		err := row.Scan(&d.id, &d.event, &d.data, &d.attempts, &d.createdAt, &d.url, &d.secret)
+		return d, err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	for _, d := range batch {
+		status, sendErr := s.send(ctx, d)
+		if err := s.record(ctx, d, status, sendErr); err != nil {
+			return len(batch), err
+		}
+	}
+	return len(batch), nil
+}
+
+// send posts one delivery, returning the response status if there was one
+func (s *Sender) send(ctx context.Context, d due) (int, error) {
+	body, err := json.Marshal(events.Event{Type: d.event, Data: d.data, Time: d.createdAt.UTC()})
+	if err != nil {
+		return 0, err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "epstein-db-webhooks")
+	req.Header.Set(HeaderEvent, d.event)
+	req.Header.Set(HeaderDelivery, strconv.FormatInt(d.id, 10))
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderSignature, Sign(d.secret, timestamp, body))
+
+	resp, err := s.http.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	// The body isn't kept: it is the receiver's, and the webhook's owner
+	// sees the error
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// record stores the outcome of an attempt, scheduling the next one or
+// giving up
+func (s *Sender) record(ctx context.Context, d due, status int, sendErr error) error {
+	var responseStatus *int
+	if status != 0 {
+		responseStatus = &status
+	}
+	attempts := d.attempts + 1
+
+	if sendErr == nil {
+		_, err := s.pool.Exec(ctx, `
+			UPDATE webhook_deliveries
+			SET status = 'delivered', attempts = $2, response_status = $3, error = NULL, delivered_at = NOW()
+			WHERE id = $1
+		`, d.id, attempts, responseStatus)
+		return err
+	}
+
+	next := StatusPending
+	if attempts >= s.cfg.MaxAttempts {
+		next = StatusFailed
+	}
+	_, err := s.pool.Exec(ctx, `
+		UPDATE webhook_deliveries
+		SET status = $2, attempts = $3, response_status = $4, error = $5,
+			next_attempt_at = NOW() + $6 * INTERVAL '1 millisecond'
+		WHERE id = $1
+	`, d.id, next, attempts, responseStatus, truncate(sendErr.Error()), s.backoff(attempts).Milliseconds())
+	return err
+}
+
+// backoff is the wait after the given number of failed attempts
+func (s *Sender) backoff(attempts int) time.Duration {
+	wait := s.cfg.Backoff
+	for i := 1; i < attempts && wait < s.cfg.MaxBackoff; i++ {
+		wait *= 2
+	}
+	return min(wait, s.cfg.MaxBackoff)
+}
+
+// truncate shortens s to maxError bytes of valid UTF-8, which Postgres
+// requires of text
+func truncate(s string) string {
+	if len(s) > maxError {
+		s = s[:maxError]
+	}
+	return strings.ToValidUTF8(s, "")
+}
@@ -0,0 +1,160 @@
+// Package webhooks notifies subscribers of changes to the data. Events are
+// derived from the change log (see package changes) by a Dispatcher, which
+// queues a delivery for every matching subscription; a Sender posts them,
+// signed with the subscription's secret, retrying failures with
+// exponential backoff.
+package webhooks
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/subculture-collective/epstein-db/api/internal/events"
+)
+
+// Event types
+const (
+	EntityUpdated     = events.EntityUpdated
+	PatternCreated    = events.PatternCreated
+	DocumentCreated   = "document.created"
+	CrossrefConfirmed = "crossref.confirmed"
+)
+
+// EventTypes lists every event a webhook can subscribe to
+var EventTypes = []string{EntityUpdated, DocumentCreated, CrossrefConfirmed, PatternCreated}
+
+// Delivery statuses
+const (
+	StatusPending   = "pending"
+	StatusDelivered = "delivered"
+	StatusFailed    = "failed"
+)
+
+// Webhook is a subscription. Webhooks are visible only to the API key that
+// created them.
+type Webhook struct {
+	ID        int64     `json:"id"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	DatasetID *int      `json:"datasetId" doc:"Only documents in this dataset; other events are unaffected"`
+	Secret    string    `json:"secret,omitempty" doc:"Signing key, returned only when the webhook is created"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Delivery is one event sent, or being sent, to a webhook
+type Delivery struct {
+	ID             int64           `json:"id"`
+	WebhookID      int64           `json:"webhookId"`
+	Event          string          `json:"event"`
+	Data           json.RawMessage `json:"data"`
+	Status         string          `json:"status" enum:"pending,delivered,failed"`
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  *time.Time      `json:"nextAttemptAt" doc:"Set while pending"`
+	ResponseStatus *int            `json:"responseStatus" doc:"HTTP status of the last attempt"`
+	Error          *string         `json:"error" doc:"Why the last attempt failed"`
+	CreatedAt      time.Time       `json:"createdAt"`
+	DeliveredAt    *time.Time      `json:"deliveredAt"`
+}
+
+// Create subscribes url to eventTypes for keyID, with a new secret
+func Create(ctx context.Context, pool *pgxpool.Pool, keyID int, url string, eventTypes []string, datasetID *int) (*Webhook, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+
+	w := Webhook{URL: url, Events: eventTypes, DatasetID: datasetID, Secret: "whsec_" + hex.EncodeToString(secret)}
+	err := pool.QueryRow(ctx, `
+		INSERT INTO webhooks (key_id, url, events, dataset_id, secret)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at
+	`, keyID, url, eventTypes, datasetID, w.Secret).Scan(&w.ID, &w.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &w, nil
+}
+
+// List returns the webhooks of keyID, newest first
+func List(ctx context.Context, pool *pgxpool.Pool, keyID int) ([]Webhook, error) {
+	rows, err := pool.Query(ctx, `
+		SELECT id, url, events, dataset_id, created_at
+		FROM webhooks
+		WHERE key_id = $1
+		ORDER BY id DESC
+	`, keyID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	webhooks := []Webhook{}
+	for rows.Next() {
+		var w Webhook
+		if err := rows.Scan(&w.ID, &w.URL, &w.Events, &w.DatasetID, &w.CreatedAt); err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, w)
+	}
+	return webhooks, rows.Err()
+}
+
+// Delete removes a webhook of keyID and its delivery log. It returns
+// pgx.ErrNoRows if keyID has no such webhook.
+func Delete(ctx context.Context, pool *pgxpool.Pool, keyID int, id int64) error {
+	tag, err := pool.Exec(ctx, `DELETE FROM webhooks WHERE id = $1 AND key_id = $2`, id, keyID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+// Deliveries returns the delivery log of a webhook of keyID, newest first,
+// optionally only those with status. It returns pgx.ErrNoRows if keyID has
+// no such webhook.
+func Deliveries(ctx context.Context, pool *pgxpool.Pool, keyID int, id int64, status string, limit, offset int) ([]Delivery, error) {
+	var owned bool
+	err := pool.QueryRow(ctx, `
+		SELECT EXISTS (SELECT 1 FROM webhooks WHERE id = $1 AND key_id = $2)
+	`, id, keyID).Scan(&owned)
+	if err != nil {
+		return nil, err
+	}
+	if !owned {
+		return nil, pgx.ErrNoRows
+	}
+
+	rows, err := pool.Query(ctx, `
+		SELECT id, webhook_id, event_type, data, status, attempts,
+			   CASE WHEN status = 'pending' THEN next_attempt_at END,
+			   response_status, error, created_at, delivered_at
+		FROM webhook_deliveries
+		WHERE webhook_id = $1 AND ($2 = '' OR status = $2)
+		ORDER BY id DESC
+		LIMIT $3 OFFSET $4
+	`, id, status, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []Delivery{}
+	for rows.Next() {
+		var d Delivery
+		if err := rows.Scan(&d.ID, &d.WebhookID, &d.Event, &d.Data, &d.Status, &d.Attempts,
+			&d.NextAttemptAt, &d.ResponseStatus, &d.Error, &d.CreatedAt, &d.DeliveredAt); err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
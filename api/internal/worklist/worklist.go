@@ -0,0 +1,264 @@
+// Package worklist lists documents missing a derived field, a summary,
+// dates, a type or any entities, for curators to fill and pipelines to
+// rerun. A curator claims the missing field of a document, or an admin
+// assigns it to another key, so that work isn't done twice. Claims lapse
+// after ClaimTTL.
+package worklist
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Fields
+const (
+	FieldSummary  = "summary"
+	FieldDates    = "dates"
+	FieldType     = "type"
+	FieldEntities = "entities"
+)
+
+// Fields lists the fields a document can be missing
+var Fields = []string{FieldSummary, FieldDates, FieldType, FieldEntities}
+
+// Claim filters
+const (
+	ClaimUnclaimed = "unclaimed"
+	ClaimClaimed   = "claimed"
+	ClaimMine      = "mine"
+)
+
+// ClaimFilters lists the claim filters
+var ClaimFilters = []string{ClaimUnclaimed, ClaimClaimed, ClaimMine}
+
+// ClaimTTL is how long a claim holds
+const ClaimTTL = 7 * 24 * time.Hour
+
+// ErrUnknownKey is returned when assigning to a missing or revoked key
+var ErrUnknownKey = errors.New("worklist: no such API key")
+
+// missingSQL matches the documents d missing each field
+var missingSQL = map[string]string{
+	FieldSummary:  `(d.summary IS NULL OR btrim(d.summary) = '')`,
+	FieldDates:    `(d.date_earliest IS NULL AND d.date_latest IS NULL)`,
+	FieldType:     `(d.document_type IS NULL OR btrim(d.document_type) = '')`,
+	FieldEntities: `NOT EXISTS (SELECT 1 FROM document_entities de WHERE de.document_id = d.id)`,
+}
+
+// Claim is who is filling a document's field
+type Claim struct {
+	KeyID      int       `json:"keyId"`
+	AssignedBy *int      `json:"assignedBy" doc:"The admin who assigned it; null when claimed by the key itself"`
+	ClaimedAt  time.Time `json:"claimedAt"`
+	ExpiresAt  time.Time `json:"expiresAt"`
+}
+
+// Item is a document missing the field
+type Item struct {
+	ID             int     `json:"id"`
+	DocID          string  `json:"docId"`
+	DatasetID      *int    `json:"datasetId"`
+	AnalysisStatus *string `json:"analysisStatus"`
+	PageCount      *int    `json:"pageCount"`
+	Claim          *Claim  `json:"claim"`
+}
+
+// DatasetCount counts a dataset's documents missing the field
+type DatasetCount struct {
+	DatasetID *int    `json:"datasetId"`
+	Name      *string `json:"name"`
+	Missing   int     `json:"missing"`
+	Claimed   int     `json:"claimed"`
+}
+
+// Filter selects worklist items
+type Filter struct {
+	Field     string
+	DatasetID int
+	Claim     string // one of ClaimFilters, or empty for all
+	KeyID     int    // the caller, for ClaimMine
+	Limit     int
+	Offset    int
+}
+
+// Page is one page of the worklist
+type Page struct {
+	Items    []Item         `json:"items"`
+	Total    int            `json:"total" doc:"Documents matching the filters"`
+	Datasets []DatasetCount `json:"datasets" doc:"Documents missing the field in every dataset, whatever the other filters"`
+}
+
+// activeClaimSQL joins a document's unexpired claim on field $1, as c
+const activeClaimSQL = `
+	LEFT JOIN worklist_claims c ON c.document_id = d.id AND c.field = $1 AND c.expires_at > NOW()`
+
+// List returns the documents missing f.Field, oldest first, and counts per
+// dataset
+func List(ctx context.Context, pool *pgxpool.Pool, f Filter) (*Page, error) {
+	where := `
+		WHERE ` + missingSQL[f.Field] + `
+		  AND ($2 = 0 OR d.dataset_id = $2)
+		  AND ($3 = '' OR ($3 = 'unclaimed' AND c.key_id IS NULL)
+		       OR ($3 = 'claimed' AND c.key_id IS NOT NULL) OR ($3 = 'mine' AND c.key_id = $4))`
+	args := []any{f.Field, f.DatasetID, f.Claim, f.KeyID}
+
+	page := &Page{Items: []Item{}}
+	err := pool.QueryRow(ctx, `SELECT COUNT(*) FROM documents d`+activeClaimSQL+where, args...).Scan(&page.Total)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := pool.Query(ctx, `
+		SELECT d.id, d.doc_id, d.dataset_id, d.analysis_status, d.page_count,
+			   c.key_id, c.assigned_by, c.claimed_at, c.expires_at
+		FROM documents d`+activeClaimSQL+where+`
+		ORDER BY d.id
+		LIMIT $5 OFFSET $6
+	`, append(args, f.Limit, f.Offset)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var it Item
+		var keyID *int
+		var claim Claim
+		var claimedAt, expiresAt *time.Time
+		err := rows.Scan(&it.ID, &it.DocID, &it.DatasetID, &it.AnalysisStatus, &it.PageCount,
+			&keyID, &claim.AssignedBy, &claimedAt, &expiresAt)
+		if err != nil {
+			return nil, err
+		}
+		if keyID != nil {
+			claim.KeyID, claim.ClaimedAt, claim.ExpiresAt = *keyID, *claimedAt, *expiresAt
+			it.Claim = &claim
+		}
+		page.Items = append(page.Items, it)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = pool.Query(ctx, `
+		SELECT d.dataset_id, ds.name, COUNT(*), COUNT(c.key_id)
+		FROM documents d`+activeClaimSQL+`
+		LEFT JOIN datasets ds ON ds.id = d.dataset_id
+		WHERE `+missingSQL[f.Field]+`
+		GROUP BY d.dataset_id, ds.name
+		ORDER BY COUNT(*) DESC, d.dataset_id
+	`, f.Field)
+	if err != nil {
+		return nil, err
+	}
+	page.Datasets, err = pgx.CollectRows(rows, func(row pgx.CollectableRow) (DatasetCount, error) {
+		var dc DatasetCount
+		return dc, row.Scan(&dc.DatasetID, &dc.Name, &dc.Missing, &dc.Claimed)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if page.Datasets == nil {
+		page.Datasets = []DatasetCount{}
+	}
+	return page, nil
+}
+
+// ClaimResult sorts the documents of a claim
+type ClaimResult struct {
+	Claimed  []int `json:"claimed" doc:"Claimed for the key, or already its"`
+	Taken    []int `json:"taken" doc:"Held by another key; reassign to take them"`
+	Complete []int `json:"complete" doc:"Not missing the field, or not found"`
+}
+
+// ClaimDocuments claims field of the documents ids for keyID. assignedBy
+// is the caller when assigning to another key, or 0. Documents claimed by
+// another key are left alone unless reassign is set. It returns
+// ErrUnknownKey if keyID isn't a current key.
+func ClaimDocuments(ctx context.Context, pool *pgxpool.Pool, field string, ids []int, keyID, assignedBy int, reassign bool) (*ClaimResult, error) {
+	result := &ClaimResult{Claimed: []int{}, Taken: []int{}, Complete: []int{}}
+	err := pgx.BeginFunc(ctx, pool, func(tx pgx.Tx) error {
+		var exists bool
+		err := tx.QueryRow(ctx, `
+			SELECT EXISTS (SELECT 1 FROM api_keys WHERE id = $1 AND revoked_at IS NULL)
+		`, keyID).Scan(&exists)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return ErrUnknownKey
+		}
+
+		rows, err := tx.Query(ctx, `
+			SELECT d.id FROM documents d
+			WHERE d.id = ANY($1) AND `+missingSQL[field]+`
+		`, ids)
+		if err != nil {
+			return err
+		}
+		missing, err := pgx.CollectRows(rows, pgx.RowTo[int])
+		if err != nil {
+			return err
+		}
+
+		rows, err = tx.Query(ctx, `
+			INSERT INTO worklist_claims (document_id, field, key_id, assigned_by, expires_at)
+			SELECT id, $2, $3, NULLIF($4, 0), NOW() + $5::interval FROM unnest($1::int[]) id
+			ON CONFLICT (document_id, field) DO UPDATE SET
+				key_id = EXCLUDED.key_id,
+				assigned_by = EXCLUDED.assigned_by,
+				claimed_at = NOW(),
+				expires_at = EXCLUDED.expires_at
+			WHERE worklist_claims.expires_at <= NOW() OR worklist_claims.key_id = EXCLUDED.key_id OR $6
+			RETURNING document_id
+		`, missing, field, keyID, assignedBy, ClaimTTL, reassign)
+		if err != nil {
+			return err
+		}
+		claimed, err := pgx.CollectRows(rows, pgx.RowTo[int])
+		if err != nil {
+			return err
+		}
+
+		isMissing, isClaimed := map[int]bool{}, map[int]bool{}
+		for _, id := range missing {
+			isMissing[id] = true
+		}
+		for _, id := range claimed {
+			isClaimed[id] = true
+		}
+		for _, id := range ids {
+			switch {
+			case isClaimed[id]:
+				result.Claimed = append(result.Claimed, id)
+			case isMissing[id]:
+				result.Taken = append(result.Taken, id)
+			default:
+				result.Complete = append(result.Complete, id)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Release drops the claim on a document's field, returning pgx.ErrNoRows
+// if there is none
+func Release(ctx context.Context, pool *pgxpool.Pool, documentID int, field string) error {
+	tag, err := pool.Exec(ctx, `
+		DELETE FROM worklist_claims WHERE document_id = $1 AND field = $2 AND expires_at > NOW()
+	`, documentID, field)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
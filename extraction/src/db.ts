@@ -124,19 +124,45 @@ export async function upsertEntity(entity: {
   return result.rows[0].id;
 }
 
+// Whether a name is on the entity stoplist. The database drops links and
+// triples for blocked names anyway; checking first keeps their entities
+// from being created.
+export async function isNameStopped(name: string, entityType: string): Promise<boolean> {
+  const result = await pool.query(
+    `SELECT name_stopped($1, $2::entity_type) AS stopped`,
+    [name, entityType]
+  );
+  return result.rows[0].stopped;
+}
+
 export async function linkEntityToDocument(
   entityId: number,
   documentId: number,
   mentionCount: number = 1,
-  contextSnippet?: string
+  contextSnippet?: string,
+  confidence: number = 1
 ): Promise<void> {
   await pool.query(
-    `INSERT INTO document_entities (document_id, entity_id, mention_count, context_snippet)
-     VALUES ($1, $2, $3, $4)
+    `INSERT INTO document_entities (document_id, entity_id, mention_count, context_snippet, extraction_confidence)
+     VALUES ($1, $2, $3, $4, $5)
      ON CONFLICT (document_id, entity_id) DO UPDATE SET
-       mention_count = document_entities.mention_count + EXCLUDED.mention_count`,
-    [documentId, entityId, mentionCount, contextSnippet]
+       mention_count = document_entities.mention_count + EXCLUDED.mention_count,
+       extraction_confidence = GREATEST(document_entities.extraction_confidence, EXCLUDED.extraction_confidence)`,
+    [documentId, entityId, mentionCount, contextSnippet, confidence]
+  );
+}
+
+// Mean OCR confidence of a document's pages, 0 to 1, or 1 if it has no
+// OCR'd pages
+export async function getOcrConfidence(documentId: number): Promise<number> {
+  const result = await pool.query(
+    `SELECT AVG(confidence) / 100 AS confidence
+     FROM document_pages
+     WHERE document_id = $1 AND confidence IS NOT NULL`,
+    [documentId]
   );
+  const confidence = result.rows[0]?.confidence;
+  return confidence == null ? 1 : Math.min(Math.max(Number(confidence), 0), 1);
 }
 
 export async function insertTriple(triple: {
@@ -15,6 +15,7 @@ export const EntitySchema = z.object({
   name: z.string(),
   type: z.enum(['person', 'organization', 'location', 'date', 'reference', 'financial']),
   context: z.string().optional(),
+  confidence: z.number().min(0).max(1).optional(),
 });
 
 export const TripleSchema = z.object({
@@ -62,6 +63,7 @@ Be thorough but precise. If information is unclear or partially redacted, note w
 IMPORTANT: 
 - Normalize names where possible (e.g., "J. Epstein" → "Jeffrey Epstein" if context confirms)
 - Include context snippets for important entities
+- Give each entity a confidence from 0 to 1: lower it when the name is garbled, partially redacted or may be an OCR misreading
 - Extract temporal information when available
 - Tag relationships with relevant categories (legal, financial, travel, social, etc.)`;
 
@@ -80,7 +82,7 @@ Respond with a JSON object matching this schema:
   "dateLatest": "YYYY-MM-DD or null if no dates",
   "contentTags": ["tag1", "tag2", ...],
   "entities": [
-    {"name": "Full Name", "type": "person|organization|location|date|reference|financial", "context": "brief context"}
+    {"name": "Full Name", "type": "person|organization|location|date|reference|financial", "context": "brief context", "confidence": 0.0-1.0}
   ],
   "triples": [
     {
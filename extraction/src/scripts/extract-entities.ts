@@ -11,6 +11,8 @@ import {
   getDocumentsPendingAnalysis,
   updateDocumentAnalysis,
   upsertEntity,
+  isNameStopped,
+  getOcrConfidence,
   linkEntityToDocument,
   insertTriple,
   pool,
@@ -62,10 +64,18 @@ async function processDocument(doc: {
       contentTags: analysis.contentTags,
     });
 
+    // Links are only as sure as the text they were read from
+    const ocrConfidence = await getOcrConfidence(doc.id);
+
     // Insert entities and get their IDs
     const entityIdMap = new Map<string, number>();
     
     for (const entity of analysis.entities) {
+      // Skip names on the stoplist
+      if (await isNameStopped(entity.name, entity.type)) {
+        continue;
+      }
+
       const entityId = await upsertEntity({
         canonicalName: entity.name,
         entityType: entity.type,
@@ -73,7 +83,13 @@ async function processDocument(doc: {
       entityIdMap.set(entity.name.toLowerCase(), entityId);
 
       // Link entity to document
-      await linkEntityToDocument(entityId, doc.id, 1, entity.context);
+      await linkEntityToDocument(
+        entityId,
+        doc.id,
+        1,
+        entity.context,
+        (entity.confidence ?? 1) * ocrConfidence
+      );
     }
 
     totalEntities += analysis.entities.length;
@@ -81,6 +97,12 @@ async function processDocument(doc: {
     // Insert triples
     for (let i = 0; i < analysis.triples.length; i++) {
       const triple = analysis.triples[i];
+      if (
+        (await isNameStopped(triple.subject, triple.subjectType)) ||
+        (await isNameStopped(triple.object, triple.objectType))
+      ) {
+        continue;
+      }
       
       // Get or create subject entity
       let subjectId = entityIdMap.get(triple.subject.toLowerCase());